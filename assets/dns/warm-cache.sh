@@ -0,0 +1,32 @@
+#!/bin/bash
+set -uo pipefail
+
+# NAMES is a space-delimited list of fully qualified names.  Once CoreDNS in
+# the pod's network namespace answers queries, resolve each name's A and AAAA
+# records through it so that its cache has them, and then create READY_FILE,
+# which the container's readiness probe checks, so that the pod becomes ready
+# only after its cache is warm.  Each query is bounded by dig's timeout and
+# retries, so an unreachable upstream delays readiness by at most a few
+# seconds.
+until dig +time=1 +tries=1 -p "${PORT}" @127.0.0.1 "${CLUSTER_DOMAIN}." SOA >/dev/null 2>&1; do
+  sleep 1
+done
+
+pids=()
+for name in ${NAMES}; do
+  for type in A AAAA; do
+    dig +time=2 +tries=2 -p "${PORT}" @127.0.0.1 "${name}" "${type}" >/dev/null 2>&1 &
+    pids+=("$!")
+  done
+done
+failed=0
+for pid in "${pids[@]}"; do
+  wait "${pid}" || failed=$((failed + 1))
+done
+echo "resolved ${#pids[@]} queries for cache warm-up, ${failed} failed"
+
+touch "${READY_FILE}"
+
+trap 'exit 0' TERM INT
+sleep infinity &
+wait
@@ -12,7 +12,29 @@ IFS=', ' read -r -a services <<< "${SERVICES}"
 # Make a temporary file with the old hosts file's attributes.
 cp -f --attributes-only "${HOSTS_FILE}" "${TEMP_FILE}"
 
+# update_from_entries_file replaces our custom entries in /etc/hosts with the
+# entries in HOSTS_ENTRIES_FILE, which the operator publishes in a configmap
+# whenever the services' cluster IPs change.
+update_from_entries_file() {
+  grep -v "# ${OPENSHIFT_MARKER}" "${HOSTS_FILE}" > "${TEMP_FILE}"
+  while read -r entry; do
+    if [[ -n "${entry}" ]]; then
+      echo "${entry} # ${OPENSHIFT_MARKER}" >> "${TEMP_FILE}"
+    fi
+  done < "${HOSTS_ENTRIES_FILE}"
+  cmp -s "${TEMP_FILE}" "${HOSTS_FILE}" || cp -f "${TEMP_FILE}" "${HOSTS_FILE}"
+}
+
 while true; do
+  # Prefer the entries that the operator publishes.  Reading the mounted
+  # configmap is cheap, so check it frequently to pick up changes promptly.
+  # Fall back to querying cluster DNS if the configmap has not been created.
+  if [[ -n "${HOSTS_ENTRIES_FILE-}" && -f "${HOSTS_ENTRIES_FILE}" ]]; then
+    update_from_entries_file
+    sleep 5 & wait
+    continue
+  fi
+
   declare -A svc_ips
   for svc in "${services[@]}"; do
     # Fetch service IP from cluster dns if present. We make several tries
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/openshift/cluster-dns-operator/pkg/operator/backup"
+	operatorclient "github.com/openshift/cluster-dns-operator/pkg/operator/client"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+)
+
+// runBackupCommand runs the export or import subcommand with the given name
+// and arguments and returns a Boolean value indicating whether name is the
+// name of one of those subcommands.
+func runBackupCommand(name string, args []string) (bool, error) {
+	switch name {
+	case "export":
+		return true, runExport(args)
+	case "import":
+		return true, runImport(args)
+	}
+	return false, nil
+}
+
+// runExport writes an archive of the cluster's DNS configuration to the file
+// that the --file flag names, or to standard output.
+func runExport(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	file := flags.String("file", "-", "path of the archive to write, or - for standard output")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	c, err := newClusterClient()
+	if err != nil {
+		return err
+	}
+	var w io.Writer = os.Stdout
+	if *file != "-" {
+		f, err := os.Create(*file)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", *file, err)
+		}
+		defer f.Close()
+		w = f
+	}
+	return backup.Export(context.TODO(), c, w)
+}
+
+// runImport restores the DNS configuration in the archive that the --file
+// flag names, or that standard input provides.
+func runImport(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	file := flags.String("file", "-", "path of the archive to read, or - for standard input")
+	dryRun := flags.Bool("dry-run", false, "log the changes that would be made without making them")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	c, err := newClusterClient()
+	if err != nil {
+		return err
+	}
+	var r io.Reader = os.Stdin
+	if *file != "-" {
+		f, err := os.Open(*file)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", *file, err)
+		}
+		defer f.Close()
+		r = f
+	}
+	return backup.Import(context.TODO(), c, r, *dryRun)
+}
+
+// newClusterClient returns a client for the cluster that the kubeconfig
+// specifies.
+func newClusterClient() (client.Client, error) {
+	kubeConfig, err := config.GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get kube config: %w", err)
+	}
+	return client.New(kubeConfig, client.Options{Scheme: operatorclient.GetScheme()})
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/openshift/cluster-dns-operator/pkg/operator/dohforwarder"
+
+	"github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/controller-runtime/pkg/manager/signals"
+)
+
+// routeFlag is a flag that may be repeated, each time with a route for the
+// DNS-over-HTTPS forwarder.
+type routeFlag []dohforwarder.Route
+
+func (f *routeFlag) String() string {
+	var routes []string
+	for _, route := range *f {
+		routes = append(routes, route.String())
+	}
+	return strings.Join(routes, ",")
+}
+
+func (f *routeFlag) Set(value string) error {
+	route, err := dohforwarder.ParseRoute(value)
+	if err != nil {
+		return err
+	}
+	*f = append(*f, route)
+	return nil
+}
+
+// runDoHForwarderCommand runs the doh-forwarder subcommand with the given
+// arguments if name is its name and returns a Boolean value indicating whether
+// it is.
+func runDoHForwarderCommand(name string, args []string) (bool, error) {
+	if name != "doh-forwarder" {
+		return false, nil
+	}
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	var routes routeFlag
+	flags.Var(&routes, "route", "a listen address and the DNS-over-HTTPS upstream to forward its queries to, as <address>=<https URL>; may be repeated")
+	shutdownDelay := flags.Duration("shutdown-delay", 0, "how long to keep forwarding queries after a termination signal")
+	if err := flags.Parse(args); err != nil {
+		return true, err
+	}
+	if len(routes) == 0 {
+		return true, fmt.Errorf("at least one --route is required")
+	}
+	signalCtx := signals.SetupSignalHandler()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-signalCtx.Done()
+		logrus.Infof("received termination signal; forwarding queries for %s before shutting down", *shutdownDelay)
+		time.Sleep(*shutdownDelay)
+		cancel()
+	}()
+	return true, dohforwarder.New(routes).Start(ctx)
+}
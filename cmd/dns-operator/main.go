@@ -2,6 +2,7 @@ package main
 
 import (
 	"os"
+	"strconv"
 
 	"github.com/openshift/cluster-dns-operator/pkg/operator"
 	operatorconfig "github.com/openshift/cluster-dns-operator/pkg/operator/config"
@@ -17,6 +18,35 @@ import (
 const operatorNamespace = "openshift-dns-operator"
 
 func main() {
+	// The export and import subcommands back up and restore the DNS
+	// configuration, the doh-forwarder subcommand forwards queries in a
+	// sidecar of the dns pods, and the query subcommand compares the
+	// resolution of a name through the cluster's DNS, instead of running
+	// the operator.
+	if len(os.Args) > 1 {
+		ok, err := runBackupCommand(os.Args[1], os.Args[2:])
+		if err != nil {
+			logrus.Fatalf("failed to %s dns configuration: %v", os.Args[1], err)
+		}
+		if ok {
+			return
+		}
+		ok, err = runDoHForwarderCommand(os.Args[1], os.Args[2:])
+		if err != nil {
+			logrus.Fatalf("failed to run %s: %v", os.Args[1], err)
+		}
+		if ok {
+			return
+		}
+		ok, err = runQueryCommand(os.Args[1], os.Args[2:])
+		if err != nil {
+			logrus.Fatalf("failed to run %s: %v", os.Args[1], err)
+		}
+		if ok {
+			return
+		}
+	}
+
 	metrics.DefaultBindAddress = "127.0.0.1:60000"
 
 	// Collect operator configuration.
@@ -39,12 +69,117 @@ func main() {
 		logrus.Fatalf("KUBE_RBAC_PROXY_IMAGE environment variable is required")
 	}
 
+	// The operator's own image is optional; without it, DNS-over-HTTPS
+	// upstreams are not supported.
+	operatorImage := os.Getenv("OPERATOR_IMAGE")
+
+	verifyOperandImages := false
+	if v := os.Getenv("VERIFY_OPERAND_IMAGES"); len(v) != 0 {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			logrus.Fatalf("invalid VERIFY_OPERAND_IMAGES environment variable %q: %v", v, err)
+		}
+		verifyOperandImages = b
+	}
+
+	consoleAPI := false
+	if v := os.Getenv("ENABLE_CONSOLE_API"); len(v) != 0 {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			logrus.Fatalf("invalid ENABLE_CONSOLE_API environment variable %q: %v", v, err)
+		}
+		consoleAPI = b
+	}
+
+	metricsAuthentication := os.Getenv("METRICS_AUTHENTICATION")
+	switch metricsAuthentication {
+	case "":
+		metricsAuthentication = operatorconfig.MetricsAuthenticationKubeRBACProxy
+	case operatorconfig.MetricsAuthenticationKubeRBACProxy, operatorconfig.MetricsAuthenticationTokenReview:
+	default:
+		logrus.Fatalf("invalid METRICS_AUTHENTICATION environment variable %q: must be %q or %q", metricsAuthentication, operatorconfig.MetricsAuthenticationKubeRBACProxy, operatorconfig.MetricsAuthenticationTokenReview)
+	}
+
+	metricsSummary := false
+	if v := os.Getenv("ENABLE_METRICS_SUMMARY"); len(v) != 0 {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			logrus.Fatalf("invalid ENABLE_METRICS_SUMMARY environment variable %q: %v", v, err)
+		}
+		metricsSummary = b
+	}
+	if metricsSummary && metricsAuthentication != operatorconfig.MetricsAuthenticationTokenReview {
+		logrus.Fatalf("ENABLE_METRICS_SUMMARY requires METRICS_AUTHENTICATION to be %q", operatorconfig.MetricsAuthenticationTokenReview)
+	}
+
+	dnsConfigAnalyzer := false
+	if v := os.Getenv("ENABLE_DNS_CONFIG_ANALYZER"); len(v) != 0 {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			logrus.Fatalf("invalid ENABLE_DNS_CONFIG_ANALYZER environment variable %q: %v", v, err)
+		}
+		dnsConfigAnalyzer = b
+	}
+
+	dnsHealthEndpoint := false
+	if v := os.Getenv("ENABLE_DNS_HEALTH_ENDPOINT"); len(v) != 0 {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			logrus.Fatalf("invalid ENABLE_DNS_HEALTH_ENDPOINT environment variable %q: %v", v, err)
+		}
+		dnsHealthEndpoint = b
+	}
+
+	chaosHooks := false
+	if v := os.Getenv("ENABLE_CHAOS_HOOKS"); len(v) != 0 {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			logrus.Fatalf("invalid ENABLE_CHAOS_HOOKS environment variable %q: %v", v, err)
+		}
+		chaosHooks = b
+	}
+	if chaosHooks {
+		logrus.Warning("chaos hooks are enabled; dns annotations can inject failures into cluster DNS")
+	}
+
+	dryRun := false
+	if v := os.Getenv("ENABLE_DRY_RUN"); len(v) != 0 {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			logrus.Fatalf("invalid ENABLE_DRY_RUN environment variable %q: %v", v, err)
+		}
+		dryRun = b
+	}
+	if dryRun {
+		logrus.Warning("dry-run mode is enabled; the operator logs the changes that it would make but writes nothing")
+	}
+
+	// Busy clusters can process more reconcile requests concurrently, and
+	// tiny clusters can keep the default of one to minimize API churn.
+	// The controllers' workqueue metrics, such as
+	// workqueue_queue_duration_seconds, are labeled with the controllers'
+	// names.
+	dnsControllerMaxConcurrentReconciles := parseMaxConcurrentReconciles("DNS_CONTROLLER_MAX_CONCURRENT_RECONCILES")
+	statusControllerMaxConcurrentReconciles := parseMaxConcurrentReconciles("STATUS_CONTROLLER_MAX_CONCURRENT_RECONCILES")
+
 	operatorConfig := operatorconfig.Config{
 		OperatorNamespace:      operatorNamespace,
 		OperatorReleaseVersion: releaseVersion,
 		CoreDNSImage:           coreDNSImage,
 		OpenshiftCLIImage:      cliImage,
 		KubeRBACProxyImage:     kubeRBACProxyImage,
+		OperatorImage:          operatorImage,
+		VerifyOperandImages:    verifyOperandImages,
+		MetricsAuthentication:  metricsAuthentication,
+		ConsoleAPI:             consoleAPI,
+		MetricsSummary:         metricsSummary,
+		DNSConfigAnalyzer:      dnsConfigAnalyzer,
+		DNSHealthEndpoint:      dnsHealthEndpoint,
+		ChaosHooks:             chaosHooks,
+		DryRun:                 dryRun,
+
+		DNSControllerMaxConcurrentReconciles:    dnsControllerMaxConcurrentReconciles,
+		StatusControllerMaxConcurrentReconciles: statusControllerMaxConcurrentReconciles,
 	}
 
 	kubeConfig, err := config.GetConfig()
@@ -60,3 +195,18 @@ func main() {
 		logrus.Fatalf("failed to start operator: %v", err)
 	}
 }
+
+// parseMaxConcurrentReconciles returns the number of concurrent reconciles in
+// the environment variable with the given name, or zero, which means the
+// default of one, if the variable is not set.
+func parseMaxConcurrentReconciles(name string) int {
+	v := os.Getenv(name)
+	if len(v) == 0 {
+		return 0
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		logrus.Fatalf("invalid %s environment variable %q: must be a positive integer", name, v)
+	}
+	return n
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	"github.com/openshift/cluster-dns-operator/pkg/operator/query"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// runQueryCommand runs the query subcommand with the given arguments if name is
+// its name and returns a Boolean value indicating whether it is.  The name to
+// resolve may precede or follow the flags, as in
+// "dns-operator query <name> --via pod".
+func runQueryCommand(name string, args []string) (bool, error) {
+	if name != "query" {
+		return false, nil
+	}
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	via := flags.String("via", strings.Join(query.Vias, ","), "comma-delimited ways to resolve the name: "+strings.Join(query.Vias, ", "))
+	dnsName := flags.String("dns", "default", "name of the dns whose service, pods, and upstreams to query")
+	pod := flags.String("pod", "", "name of the only dns pod to query with --via pod")
+	qtype := flags.String("type", "A", "record type to ask for, A or AAAA")
+	timeout := flags.Duration("timeout", 2*time.Second, "how long to wait for each response")
+	var queryName string
+	if len(args) != 0 && !strings.HasPrefix(args[0], "-") {
+		queryName, args = args[0], args[1:]
+	}
+	if err := flags.Parse(args); err != nil {
+		return true, err
+	}
+	if len(queryName) == 0 {
+		queryName = flags.Arg(0)
+	}
+	if len(queryName) == 0 {
+		return true, fmt.Errorf("usage: dns-operator query <name> [--via %s]", strings.Join(query.Vias, "|"))
+	}
+	opts := query.Options{
+		Name:    queryName,
+		Type:    *qtype,
+		Vias:    strings.Split(*via, ","),
+		Pod:     *pod,
+		Timeout: *timeout,
+	}
+
+	c, err := newClusterClient()
+	if err != nil {
+		return true, err
+	}
+	ctx := context.TODO()
+	dns := &operatorv1.DNS{}
+	if err := c.Get(ctx, types.NamespacedName{Name: *dnsName}, dns); err != nil {
+		return true, fmt.Errorf("failed to get dns %s: %w", *dnsName, err)
+	}
+	targets, err := query.Targets(ctx, c, dns, opts)
+	if err != nil {
+		return true, err
+	}
+	var results []query.Result
+	for _, target := range targets {
+		results = append(results, query.Resolve(ctx, target, opts.Name, opts.Type, opts.Timeout))
+	}
+	return true, query.WriteTable(os.Stdout, results)
+}
@@ -9,8 +9,12 @@ require (
 	github.com/kevinburke/go-bindata v3.11.0+incompatible
 	github.com/openshift/api v0.0.0-20210416094334-c22782737ea0
 	github.com/openshift/build-machinery-go v0.0.0-20210409131504-b1828cc0cdad
+	github.com/prometheus/client_golang v1.9.0
+	github.com/prometheus/client_model v0.2.0
+	github.com/prometheus/common v0.15.0
 	github.com/sirupsen/logrus v1.6.0
 	k8s.io/api v0.21.0
+	k8s.io/apiextensions-apiserver v0.21.0-beta.1
 	k8s.io/apimachinery v0.21.0
 	k8s.io/client-go v0.21.0
 	sigs.k8s.io/controller-runtime v0.9.0-alpha.1
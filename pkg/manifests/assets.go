@@ -0,0 +1,74 @@
+// Package manifests provides the manifests of the objects that the operator
+// creates for its operands.
+//
+// The manifests are embedded in the binary with go-bindata.  Other components,
+// such as installers and hosted control planes, can use Names, Read, and the
+// typed functions, such as DNSDaemonSet, to consume exactly the operator's
+// manifests rather than copies of them, and RegisterOverride to adjust the
+// manifests for their environments.  The generated functions in bindata.go are
+// not a stable API.
+package manifests
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Override is a hook that may replace the content of a manifest asset.  It is
+// called with the asset's name and content, which earlier overrides may have
+// replaced, and returns the content to use.  An override returns the content
+// unchanged for assets that it does not adjust.
+type Override func(name string, content []byte) ([]byte, error)
+
+var (
+	// overridesMutex guards overrides.
+	overridesMutex sync.RWMutex
+	// overrides are the registered overrides in the order in which they
+	// were registered.
+	overrides []Override
+)
+
+// RegisterOverride registers the given override.  Overrides apply, in the order
+// in which they were registered, to every subsequent read of an asset,
+// including the reads by the typed functions such as DNSDaemonSet.  Overrides
+// should be registered before any asset is read.
+func RegisterOverride(override Override) {
+	overridesMutex.Lock()
+	defer overridesMutex.Unlock()
+	overrides = append(overrides, override)
+}
+
+// Names returns the names of the manifest assets in sorted order.
+func Names() []string {
+	names := AssetNames()
+	sort.Strings(names)
+	return names
+}
+
+// Read returns the content of the named manifest asset with the registered
+// overrides applied.
+func Read(name string) ([]byte, error) {
+	content, err := Asset(name)
+	if err != nil {
+		return nil, err
+	}
+	overridesMutex.RLock()
+	defer overridesMutex.RUnlock()
+	for _, override := range overrides {
+		if content, err = override(name, content); err != nil {
+			return nil, fmt.Errorf("failed to override asset %s: %w", name, err)
+		}
+	}
+	return content, nil
+}
+
+// MustRead is like Read but panics if the asset does not exist or an override
+// fails.
+func MustRead(name string) []byte {
+	content, err := Read(name)
+	if err != nil {
+		panic(err)
+	}
+	return content
+}
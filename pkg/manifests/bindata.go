@@ -2,7 +2,7 @@
 // sources:
 // assets/dns/cluster-role-binding.yaml (223B)
 // assets/dns/cluster-role.yaml (492B)
-// assets/dns/daemonset.yaml (2.828kB)
+// assets/dns/daemonset.yaml (3.707kB)
 // assets/dns/metrics/cluster-role-binding.yaml (279B)
 // assets/dns/metrics/cluster-role.yaml (246B)
 // assets/dns/metrics/role-binding.yaml (293B)
@@ -10,8 +10,9 @@
 // assets/dns/namespace.yaml (417B)
 // assets/dns/service-account.yaml (85B)
 // assets/dns/service.yaml (520B)
+// assets/dns/warm-cache.sh (1.01kB)
 // assets/node-resolver/service-account.yaml (95B)
-// assets/node-resolver/update-node-resolver.sh (2.285kB)
+// assets/node-resolver/update-node-resolver.sh (3.193kB)
 
 package manifests
 
@@ -80,7 +81,7 @@ func (fi bindataFileInfo) Sys() interface{} {
 	return nil
 }
 
-var _assetsDnsClusterRoleBindingYaml = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x6c\xce\x31\x8e\x83\x40\x0c\x05\xd0\x7e\x4e\xe1\x0b\xc0\x6a\xbb\xd5\x74\x9b\xdc\x80\x48\xe9\xcd\x8c\x09\x0e\x60\xa3\xb1\x87\x22\xa7\x8f\x10\x4a\x45\x3a\x17\xfe\xff\xfd\x89\x25\x47\xb8\xce\xd5\x9c\x4a\xa7\x33\x5d\x58\x32\xcb\x23\xe0\xca\x77\x2a\xc6\x2a\x11\x4a\x8f\xa9\xc5\xea\xa3\x16\x7e\xa1\xb3\x4a\x3b\xfd\x59\xcb\xfa\xb3\xfd\x86\x85\x1c\x33\x3a\xc6\x00\x00\x20\xb8\x50\x04\x5d\x49\x6c\xe4\xc1\x9b\x2c\x16\xac\xf6\x4f\x4a\x6e\x31\x34\x70\x78\x37\x2a\x1b\x27\xfa\x4f\x49\xab\x78\xf8\xc4\xf6\xe7\xe3\xb6\x15\xd3\xa9\xa7\xe8\x4c\x1d\x0d\x3b\x74\x9a\x1d\xbe\xd3\xef\x00\x00\x00\xff\xff\xfa\x62\xe7\x50\xdf\x00\x00\x00")
+var _assetsDnsClusterRoleBindingYaml = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x6c\xce\x31\x8e\x83\x40\x0c\x05\xd0\x7e\x4e\xe1\x0b\xc0\x6a\xbb\xd5\x74\x9b\xdc\x80\x48\xe9\xcd\x8c\x09\x0e\x60\xa3\xb1\x87\x22\xa7\x8f\x10\x4a\x45\x3a\x17\xfe\xff\xfd\x89\x25\x47\xb8\xce\xd5\x9c\x4a\xa7\x33\x5d\x58\x32\xcb\x23\xe0\xca\x77\x2a\xc6\x2a\x11\x4a\x8f\xa9\xc5\xea\xa3\x16\x7e\xa1\xb3\x4a\x3b\xfd\x59\xcb\xfa\xb3\xfd\x86\x85\x1c\x33\x3a\xc6\x00\x00\x20\xb8\x50\x04\x5d\x49\x6c\xe4\xc1\x9b\x2c\x16\xac\xf6\x4f\x4a\x6e\x31\x34\x70\x78\x37\x2a\x1b\x27\xfa\x4f\x49\xab\x78\xf8\xc4\xf6\xe7\xe3\xb6\x15\xd3\xa9\xa7\xe8\x4c\x1d\x0d\x3b\x74\x9a\x1d\xbe\xd3\xef\x01\x00\xfa\x62\xe7\x50\xdf\x00\x00\x00")
 
 func assetsDnsClusterRoleBindingYamlBytes() ([]byte, error) {
 	return bindataRead(
@@ -100,7 +101,7 @@ func assetsDnsClusterRoleBindingYaml() (*asset, error) {
 	return a, nil
 }
 
-var _assetsDnsClusterRoleYaml = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x94\x90\xb1\x6e\xf3\x30\x0c\x84\x77\x3d\x85\x90\x3d\xfe\xf1\x6f\x85\xd7\x0e\xdd\x3b\x74\xa7\xa5\x2b\xcc\xda\x11\x05\x92\x72\xd0\x3e\x7d\xe1\xd8\x43\x11\xa3\x01\xba\xdd\x1d\xa4\xfb\x88\x9b\xb8\xe4\x3e\x3e\xcf\xcd\x1c\xfa\x2a\x33\x02\x55\x7e\x83\x1a\x4b\xe9\xa3\x0e\x94\x3a\x6a\x3e\x8a\xf2\x17\x39\x4b\xe9\xa6\x27\xeb\x58\xfe\x2d\xff\xc3\x05\x4e\x99\x9c\xfa\x10\x63\xa1\x0b\xfa\x28\x15\xc5\x46\x7e\xf7\x73\x2e\x16\xb4\xcd\xb0\x3e\x9c\x23\x55\x7e\x51\x69\xd5\xd6\x97\xe7\x78\x3a\x85\x18\x15\x26\x4d\x13\xf6\x0c\x25\x57\xe1\xe2\x76\x73\x06\x5d\x38\x61\x33\x55\xf2\x26\x56\x86\x55\xda\xf2\x05\x3a\xec\x7f\x67\x36\xbf\x89\x2b\x79\x1a\xc3\x11\x98\xd9\x92\x2c\xd0\xcf\xfd\xf8\x07\xf8\x99\xff\x5e\xbf\xee\x83\xe2\x9c\x7e\x0e\x74\x64\xb8\x4c\x28\x8a\x85\x71\xbd\x23\x24\x05\x39\x7e\x69\xbe\x5f\xfe\x58\x6c\x6d\xf8\x40\x72\x4a\x09\x66\x8f\x00\xdf\x01\x00\x00\xff\xff\xa8\x4a\xa0\x25\xec\x01\x00\x00")
+var _assetsDnsClusterRoleYaml = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x94\x90\xb1\x6e\xf3\x30\x0c\x84\x77\x3d\x85\x90\x3d\xfe\xf1\x6f\x85\xd7\x0e\xdd\x3b\x74\xa7\xa5\x2b\xcc\xda\x11\x05\x92\x72\xd0\x3e\x7d\xe1\xd8\x43\x11\xa3\x01\xba\x9d\x0e\xa7\xfb\x88\x9b\xb8\xe4\x3e\x3e\xcf\xcd\x1c\xfa\x2a\x33\x02\x55\x7e\x83\x1a\x4b\xe9\xa3\x0e\x94\x3a\x6a\x3e\x8a\xf2\x17\x39\x4b\xe9\xa6\x27\xeb\x58\xfe\x2d\xff\xc3\x05\x4e\x99\x9c\xfa\x10\x63\xa1\x0b\xfa\x28\x15\xc5\x46\x7e\xf7\x73\x2e\x16\xb4\xcd\xb0\x3e\x9c\x23\x55\x7e\x51\x69\xd5\xd6\xe4\x39\x9e\x4e\x21\x46\x85\x49\xd3\x84\xdd\x43\xc9\x55\xb8\xb8\xdd\x12\x06\x5d\x38\x61\x7b\x54\xc9\x9b\x58\x19\x56\x69\xf3\x17\xe8\xb0\xff\x9d\xd9\xfc\x26\xae\xe4\x69\x0c\x47\x60\x66\x4b\xb2\x40\x3f\xf7\xe3\x1f\xe0\x67\xfe\x7b\xfd\xba\x0f\x8a\x73\xfa\x39\xd0\x91\xe1\x32\xa1\x28\x16\xc6\xf5\x8e\x90\x14\xe4\xf8\xa5\xf9\x7e\xf9\x63\xb1\xb5\xe1\x03\xc9\x29\x25\x98\x3d\x02\x7c\x0f\x00\xa8\x4a\xa0\x25\xec\x01\x00\x00")
 
 func assetsDnsClusterRoleYamlBytes() ([]byte, error) {
 	return bindataRead(
@@ -120,7 +121,7 @@ func assetsDnsClusterRoleYaml() (*asset, error) {
 	return a, nil
 }
 
-var _assetsDnsDaemonsetYaml = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xa4\x56\xdd\x6e\x1b\x37\x13\xbd\xf7\x53\x0c\x64\x7c\xc8\x57\xc0\xab\x9f\x24\x4a\x5c\x02\xbe\x70\x25\x37\x0e\x10\xc7\x42\xa4\xb4\x17\x45\x21\xd0\xdc\x91\x96\x10\x97\xdc\xce\x0c\x95\x2c\x8a\xbe\x7b\xc1\x95\x25\xaf\x6c\x45\xf9\xe9\x8d\xb0\x9a\x39\x73\x38\x3c\x33\x1c\x72\x65\x7d\xae\x60\xac\xb1\x0c\x7e\x8a\x72\xa2\x2b\xfb\x1b\x12\xdb\xe0\x15\xe8\xaa\xe2\xde\x7a\x70\x72\x0a\x5e\x97\x78\xd6\xfc\x72\xa5\x0d\x82\xf6\x39\x38\x7d\x87\x8e\x41\x13\x02\xa3\x80\x16\xa0\xe8\xc5\x96\x78\xc2\x15\x1a\x75\x02\x20\x58\x56\x4e\x0b\xa6\x6f\x80\x12\x45\xe7\x5a\xf4\xe6\x1f\x80\xf6\x3e\x88\x16\x1b\x3c\x6f\x4d\x00\xa2\x69\x89\xd2\xfd\x14\x68\xe5\x82\xce\xbb\xa1\x42\xcf\x85\x5d\x48\xd7\x86\x5e\xa9\xbd\x5e\x62\x89\x5e\x14\x3c\xfb\xbb\x83\x8b\x05\x1a\xe9\x28\xe8\x4c\x08\x17\x48\x84\xf9\x38\x92\xf5\xcb\xa9\x29\x30\x8f\xce\xfa\x65\xe7\x9f\x67\x0d\xf5\x36\xa3\xe6\x1b\x69\x6d\x0d\x5e\x1a\x13\xa2\x97\xf7\xba\x44\x05\xb9\xe7\x7b\x6f\x45\x36\x90\x95\x7a\xe4\x34\xf3\xc6\xc9\x35\x0b\x96\x99\x0f\x39\x66\x86\xac\x58\xa3\xdd\x3d\xda\x04\x2f\xda\x7a\xa4\xdd\x16\xb2\x46\xa5\x36\x23\xc0\x29\xd8\x52\x2f\x11\x2c\x3f\x56\x6a\x8b\x68\xfc\x93\xe8\xdc\x24\x38\x6b\x6a\x05\x6f\x17\xef\x83\x4c\x08\x19\xbd\x3c\x88\x83\x54\x5a\xdf\x48\x76\x83\xcc\x29\xe4\x1e\xfe\xab\x76\xee\x4e\x9b\xd5\x2c\xbc\x0b\x4b\xbe\xf5\x57\x44\x81\x76\x71\x26\x94\xa5\x4e\x65\xfe\x03\x3a\x26\x10\xe6\x9e\x3b\xf0\xe7\xce\xad\x69\xc9\x8d\x2f\x33\xc1\x2f\x3a\x67\xd0\xe9\xa1\x98\xde\x3d\xb2\x37\x0a\x84\x0b\xeb\xb0\x1d\xb2\x0e\x2e\x96\x78\x93\x04\x6c\x15\x6f\xbb\xf7\x44\x63\x97\xd9\x06\xb4\xf3\x02\x94\x09\x3f\xd1\x52\x28\x68\xaf\xd0\x42\x10\xea\xfc\xd6\xbb\x5a\x81\x50\x7c\x08\xad\x02\xed\xaf\xb3\xd3\x7d\x12\x48\x14\x0c\x5f\x0c\x5f\xb4\x58\x9e\x56\x20\xd5\x35\x48\x30\xc1\x29\xf8\x38\x9e\x7c\x3f\x53\x26\xa6\x3a\xc8\x36\x1b\x3d\xb0\xa5\xec\xad\x47\xe6\x09\x85\x3b\x54\x2d\x7c\x21\x52\xbd\x41\x69\x9b\x00\xaa\x8d\x12\x29\xaa\xde\x77\x34\xa9\x9c\x0f\xce\x07\x7b\x66\x36\x05\xa6\x74\xae\x67\xb3\x49\xcb\x61\xbd\x15\xab\xdd\x18\x9d\xae\xa7\x68\x82\xcf\x59\xc1\xa0\xdf\xce\x16\xc9\x86\x7c\xe7\x6b\x6f\x90\xa3\x31\xc8\x3c\x2b\x08\xb9\x08\x2e\x57\xd0\x5e\x73\xa1\xad\x8b\x84\x2d\x6f\x3b\x36\x75\x70\x88\x72\x80\xd7\xd9\x35\x7e\xb7\x0e\x05\x6a\x27\xc5\x21\x21\xfa\xe7\xfd\x1f\x16\xe2\x55\xff\x48\xc6\xc3\xff\xa0\xc4\xb0\x55\x76\x0e\x91\x0c\xb2\xda\xeb\xe4\xbf\x22\xb2\xf0\xfe\x56\x4d\x15\x15\x0c\xfb\xe5\x9e\xb1\xc4\x32\x50\xad\xe0\x75\xff\xc6\x3e\x9a\x22\xab\x78\x87\x19\xdd\x69\x93\x55\x14\x3e\xd7\xdf\x31\x51\x9a\x43\xdd\xea\xf3\x2c\x73\x61\x29\x81\x25\x47\xa2\x3d\x3b\xa3\x89\x84\x99\xb3\x2c\xe8\x33\x9d\xe7\x84\xcc\x17\xea\xe7\xc1\xf0\xe5\x1e\x4e\x1c\x67\xc6\x56\x05\x52\xc6\xd1\x0a\xf2\xc5\xec\xdd\x74\x7e\x35\x1a\x5f\x5f\xcd\x3f\x4c\x2f\xe7\xbf\xbf\x9d\x5d\xcf\x2f\xaf\xa6\xf3\xc1\xf3\xf3\xf9\x9b\xd1\xcd\x7c\x7a\x7d\xf9\x7c\xf8\xea\xec\x01\x75\x35\x1a\x7f\x05\xf7\x84\x67\xf4\xcb\xe8\x9b\x78\x0e\xe2\x8e\xb0\xed\xed\x2c\x56\x2c\x84\xba\xbc\x48\xed\xa9\x7a\xbd\xc1\xf3\xd7\xdd\x7e\xb7\xdf\x1d\x24\x11\x5e\xf4\x9e\xaa\x80\x24\x59\x1a\x89\x17\xcd\x18\x13\xc7\xbd\x8a\xec\x5a\x0b\xa6\xef\xae\x21\x79\x12\x72\xef\xcf\x56\x58\x1f\x89\x5c\x61\xfd\xcd\x33\x6f\xaf\x3e\xdb\x49\x55\xa2\x90\x35\xfc\xc3\xad\x39\xf8\x42\x6b\xbe\x7c\x68\xcd\x2f\x0f\xff\xc7\xe3\xbd\xb5\xbb\x2f\x25\x9a\xb4\xf9\xda\xf8\xcf\x3d\x6f\xaf\xb9\x31\x2e\x74\x74\x5b\x75\x4f\x21\x5d\xc9\x53\x74\x68\x24\xd0\xd3\xb3\xd0\x3d\x69\xe7\xfb\xe4\x86\x3e\x7c\x4b\x6d\xac\x37\xba\x52\xad\xc3\x96\xde\x01\x47\xce\x1a\x80\x15\x2c\xf7\xf4\xcc\x60\x85\xb5\x82\xed\xdd\x79\x60\xde\x3d\x72\x65\x47\x84\x39\x05\x46\x43\x28\x47\xd3\x38\x05\x09\x0e\x69\xf3\xa0\x3a\x2c\x46\xac\x72\x2d\x38\x15\xd2\x82\xcb\x7a\x93\xae\xd4\x15\x2a\xf8\x10\x5c\x7a\x2e\x7d\x6c\x00\x8d\x9d\xda\x16\xb5\x5b\x63\x76\x3b\xbe\x4d\xb9\x7b\xb6\x39\x52\x5a\x43\xac\x5f\x42\xa9\x3f\x4f\x23\x2d\x11\x24\x80\x86\x2a\xb0\x15\xbb\x46\x58\x6b\x17\x77\x65\xd8\x62\x14\xf4\x77\x74\xef\x83\xa0\x82\x59\x81\x90\x37\x2f\xd0\xa6\xc9\xd3\xd2\x48\x40\x21\xfa\x9c\x41\x0a\x4c\x17\x98\x41\x2f\x69\xe2\xc5\x6a\x17\xfc\xff\xe8\x9d\x5d\x61\x83\xc8\xb1\x72\xa1\x4e\x4f\xc3\x16\xc5\x19\x7c\x2a\xac\x29\xb6\x4c\x79\xf8\xe4\x7f\x6a\x65\xf3\xd1\xeb\xb5\xb6\x4e\xdf\x39\x4c\x9d\xff\xbf\x93\x7f\x03\x00\x00\xff\xff\x44\x37\xeb\xeb\x0c\x0b\x00\x00")
+var _assetsDnsDaemonsetYaml = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xa4\x57\xdf\x6f\xdb\xc8\x11\x7e\xf7\x5f\x31\x90\x50\xa4\x05\x4c\xfd\x48\xe2\xbb\x94\x40\x1e\x5c\xcb\x4d\x82\xc6\x89\x10\x29\xed\x43\x51\x18\xeb\xe5\x90\x5c\x68\xb9\xbb\x37\x33\x94\x43\x14\xfd\xdf\x8b\xa5\x44\x8a\xb4\x1d\xdf\xe5\x0e\x32\x0c\x69\x67\xf6\xe3\xec\x37\xb3\xdf\x0c\x77\xc6\x65\x29\xac\x14\x56\xde\x6d\x50\xce\x54\x30\xff\x44\x62\xe3\x5d\x0a\x2a\x04\x9e\xef\x97\x67\x53\x70\xaa\xc2\xf3\xf6\x3f\x07\xa5\x11\x94\xcb\xc0\xaa\x3b\xb4\x0c\x8a\x10\x18\x05\x94\x00\xd5\x4e\x4c\x85\x67\x1c\x50\xa7\x67\x00\x82\x55\xb0\x4a\x30\x7e\x07\xa8\x50\x54\xa6\x44\x1d\x7e\x01\x28\xe7\xbc\x28\x31\xde\x71\xb7\x04\x20\x8a\x0a\x94\xd9\xbd\xa7\x9d\xf5\x2a\x9b\xf9\x80\x8e\x4b\x93\xcb\xcc\xf8\x79\xa5\x9c\x2a\xb0\x42\x27\x29\xbc\xf8\xef\x04\xf3\x1c\xb5\x4c\x52\x98\xac\x09\x73\x24\xc2\x6c\x55\x93\x71\xc5\x46\x97\x98\xd5\xd6\xb8\x62\xf2\xbf\x17\x3d\xf4\x14\x3e\xa2\x80\x94\x08\xda\xd6\x2c\x48\xa0\x6a\xf1\xac\x95\x45\x02\xdc\x1b\x2d\x90\x39\x86\xe0\x33\x86\x82\x94\xc6\xbc\xb6\xb6\x81\xfb\x12\x1d\x18\x19\xc0\xb4\x5b\x18\x32\x7f\xef\x40\x81\xf3\x19\x02\x7b\x90\x52\xb5\xe8\x0d\xb0\xf8\x00\x84\x1a\xcd\xde\xb8\x02\x7e\xa9\x91\x0c\x32\xdc\x61\xee\x09\x07\x38\x31\x94\x76\xb7\x61\xc8\xd0\xa2\x60\x36\xeb\xcd\xc7\x18\x93\x53\x8c\xb3\x5d\x7d\x87\xe4\x50\x90\x23\x19\xe8\xd4\x9d\xc5\x24\xe3\xa4\x8d\xbd\x4d\xd8\x44\xa8\xc6\x49\x8b\xd1\xe5\x20\x7e\x18\x69\x6f\x34\x5e\x6a\xed\x6b\x27\x9f\x54\x85\x69\x3c\xea\xd1\x1a\xc8\x78\x32\xd2\x5c\x59\xc5\x7c\x30\x72\xc3\x82\x55\x12\x83\x4b\x34\x19\x31\x5a\xd9\xa3\xb7\xf6\x4e\x94\x71\x48\x7d\xd2\x12\x70\x0f\x10\x01\xa6\x60\x2a\x55\x20\x18\x7e\x58\x1b\x9d\x47\x6b\x5f\xd7\xd6\xae\xbd\x35\xba\x49\xe1\x43\xfe\xc9\xcb\x9a\x90\xd1\x9d\xc8\x16\xa4\xca\xb8\xb6\x48\x6e\x90\x39\x6e\x39\xba\xff\x5d\x59\x7b\xa7\xf4\x6e\xeb\x3f\xfa\x82\x3f\xbb\x6b\x22\x4f\xfd\x3e\xed\xab\x4a\xc5\xc2\xfe\x37\x4c\xb4\x27\xcc\x1c\x4f\xe0\x3f\xbd\x59\x51\xc1\xad\x2d\xd1\xde\xe5\x93\x73\x98\xcc\x51\xf4\xfc\xe8\x39\xbf\xf2\x84\xb9\xb1\x38\xdc\xb2\xf7\xb6\xae\xf0\x26\x12\x38\x28\xd7\xee\xec\x11\xc6\x14\xc9\xc1\xa9\xb7\x02\x54\xd1\x7f\xad\xa4\x4c\x61\xf8\x84\x81\x07\xa1\xca\x3e\x3b\xdb\xa4\x10\x73\xd7\x1b\x82\xa7\xf1\x73\x7a\xde\xd7\x9e\x24\x85\x8b\x57\x17\xaf\x7a\x2b\x3c\x91\x01\x80\x40\x5e\xbc\xf6\x36\x85\xaf\xab\xf5\x8f\x23\x25\xa2\xc3\x93\x68\xdb\xab\x13\x5a\x8c\xde\x38\x64\x5e\x93\xbf\x3b\xde\xf3\xc3\x5f\x29\x12\xde\xa1\x0c\x97\x00\xc2\x81\x89\xb8\xab\x19\x1b\xda\x43\xbd\x59\xbe\x59\x8e\x96\x59\x97\x18\xe9\x7d\xbf\xdd\x9e\x9e\x09\x60\x9c\x11\xa3\xec\x0a\xad\x6a\x36\xa8\xbd\xcb\x38\x85\xe5\x62\xe0\x11\x90\x8c\xcf\x7a\xdb\xf0\x80\x5c\x6b\x8d\xcc\xdb\x92\x90\x4b\x6f\xb3\x14\x86\xcf\xcc\x95\xb1\x35\xe1\xc0\x3a\xdc\x1b\xd5\xcd\xd7\xf2\x04\xae\x35\x7b\xfc\x61\x1e\x4a\x54\x56\xca\xb1\xe5\x40\xc4\xe2\xcd\xe2\x77\x13\xf1\xd3\xe2\x99\x88\x2f\xfe\x00\x13\xa7\xbd\xd6\xe4\xa8\x1b\x6d\x47\x27\x9d\xc2\x3f\x10\x03\x28\xc7\xf7\x48\x43\xe1\x53\x02\x51\x4a\x41\xab\xa0\xb4\x91\x28\xa9\xc6\x62\xd4\x4a\x40\x97\x05\x6f\x9c\x0c\xeb\x76\xda\x56\x28\x79\x1b\x75\x99\xb0\xf2\x7b\xe4\xd6\xb9\x17\x03\x57\x44\x95\x86\x9c\x7c\xd5\x1a\x8e\xfa\xf6\x62\x8c\xd2\x63\xb7\xdd\x2a\x8a\x67\x12\xc8\x7f\x6b\xc0\x3b\xc0\x3d\x52\x73\x94\x6e\xf1\x21\xaa\x94\xcb\x62\xcc\x0e\xef\x47\x20\xdd\x19\xc4\x83\x91\x19\xc0\x65\x1e\x1b\x87\x94\x86\xa1\xf4\x7e\x77\x0e\x51\x2b\x56\x9f\x36\x47\xbd\x47\x86\xcd\x87\x77\xdb\xeb\x2f\x37\xf1\xa1\x23\xa4\x1d\x62\xe0\x01\x3b\xb9\x8f\x38\x08\x87\x22\x80\x60\xeb\xc2\xb8\x17\x0c\x2f\x17\x09\xb7\xd9\x02\xab\x2a\xcc\x6a\xbd\x1b\xc1\x1c\x2a\xfb\x3c\x72\xa8\x4b\xc8\x48\x19\xc7\x3d\xd3\x6d\x0f\x8a\x0d\x59\xd9\xf6\x8e\x81\x71\x90\x5b\x53\x94\x72\x6a\x2c\x51\x18\x70\x23\x3e\x8c\x4b\x12\xbf\x9d\xfa\xc5\x53\x2a\xca\x16\x31\x44\xa5\x5c\x5e\x0c\x65\x91\x90\x7d\x4d\x1a\x07\x5a\x15\x17\x7f\xa9\x91\x87\xfa\x15\x3f\x3a\xd4\x29\x5c\x2c\xaa\xd1\x62\x85\x95\xa7\x26\x85\x9f\x17\x37\xe6\x6c\xac\xa9\x6d\xc6\xe8\x4e\xe9\x43\xda\x7e\xa0\xb7\xb4\xf2\xde\xff\x4a\x20\x49\xac\x2f\xc4\xb3\x64\x48\xa7\x1e\x11\xd7\x19\x75\x4d\x98\x58\xc3\x82\x2e\x51\x59\x46\xc8\xfc\x36\xfd\xeb\xf2\xe2\xf5\xc8\x4f\x2c\x27\xda\x84\x12\x29\xe1\xda\x08\xf2\xdb\xed\xc7\xcd\xed\xf5\xd5\xea\xfd\xf5\xed\x97\xcd\xe5\xed\xbf\x3e\x6c\xdf\xdf\x5e\x5e\x6f\x6e\x97\x2f\xdf\xdc\xbe\xbb\xba\xb9\xdd\xbc\xbf\x7c\x79\xf1\xd3\xf9\xc9\xeb\xfa\x6a\xf5\x2b\x7e\x8f\x70\xae\xfe\x76\xf5\x9b\x70\x9e\xf4\x7b\x06\x6d\x74\xb2\x3a\xb0\x10\xaa\xea\x6d\x14\xec\x74\x3e\x5f\xbe\xfc\x79\xb6\x98\x2d\x66\xcb\x48\xc2\xab\xf9\x63\x16\x90\x24\x89\xcd\xf1\x6d\xdb\xd0\xc4\xf2\x3c\x90\xd9\x2b\xc1\xb9\x58\x9e\x69\x92\x47\x5b\x8e\xf6\x64\x87\xcd\x33\x3b\x77\xd8\xfc\xe6\xee\x37\xca\x4f\xd7\xb3\x2a\x14\x32\x9a\x7f\x77\x69\x2e\xbf\x53\x9a\xaf\x4f\xa5\xf9\xfd\x31\xe0\x61\xa3\x1f\x9c\xee\x7b\x81\x46\x3a\x7f\x6d\x10\xc8\x1c\x77\x03\xcf\x0a\x73\x55\xdb\x8e\xdd\x29\x5c\x5a\xeb\xef\x7b\x15\x39\xde\xe9\x56\x94\xa2\xf4\xb4\xd2\xd2\x09\xc8\x49\x34\x8c\x94\xa0\xa0\x52\x54\x18\x37\x3b\x7b\x34\x63\xbd\x8b\x63\xef\x7a\xdc\x3b\x5f\x77\xb2\x3f\x6d\xf5\x72\x83\x16\xb5\x78\x7a\x7c\xfd\x3a\xbc\x03\x45\x9c\x3e\xb8\xce\x4f\x8f\x48\x87\xd5\x1b\x35\x50\xa3\x29\xc4\x21\xf4\x99\xeb\x0d\x60\x04\xab\x01\xfd\x31\x01\x3b\x6c\x52\xe8\x06\xb7\x27\x9a\xed\x03\x53\xf2\x4c\x2e\xa6\xc0\xa8\x09\xe5\xd9\x30\xa6\x20\xde\x22\xb5\xb4\xf1\x63\xaf\x48\x46\x1d\x32\x25\xb8\x11\x52\x82\x45\x73\x08\x57\x9a\x80\x29\x7c\xf1\x36\xbe\x9d\x7c\x6d\x1d\xda\x75\x1a\xae\x74\x27\x9b\xc2\xf6\xf3\xea\x73\x3c\x96\x63\x93\x21\xc5\x48\x24\x76\xa9\x4a\x7d\xdb\xd4\x54\x20\x88\x07\x05\xc1\xb3\x11\xb3\x47\xd8\x2b\x5b\xf7\x69\xe8\x7c\x52\xe8\xe6\x81\x29\x7c\xf2\x82\x29\x6c\x4b\x84\xac\x7d\xe1\x1b\x75\x5a\x5f\xbb\x8c\xdb\xba\x09\x48\x1a\x9d\xc4\x01\xbe\xee\x66\xc0\x29\xfc\xb9\x76\xd6\xec\x0e\x7d\x3b\xc3\x60\x7d\x13\xdf\xc4\x06\x10\x5d\x53\x3a\x22\xc5\xf7\xa3\xbf\x0c\xa2\xf9\xea\xd4\x5e\x19\xab\xee\x2c\xa6\xb0\x5c\xfc\xe9\xec\xff\x03\x00\xd4\x56\x07\xd6\x7b\x0e\x00\x00")
 
 func assetsDnsDaemonsetYamlBytes() ([]byte, error) {
 	return bindataRead(
@@ -135,12 +136,12 @@ func assetsDnsDaemonsetYaml() (*asset, error) {
 		return nil, err
 	}
 
-	info := bindataFileInfo{name: "assets/dns/daemonset.yaml", size: 2828, mode: os.FileMode(420), modTime: time.Unix(1, 0)}
-	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x16, 0x26, 0xcf, 0x8f, 0xfa, 0xb9, 0x58, 0xdd, 0x1b, 0x48, 0x8, 0x80, 0x5e, 0x36, 0x3, 0x9, 0x74, 0xa9, 0xe9, 0x88, 0x18, 0x78, 0xe0, 0x5f, 0x82, 0x86, 0x5b, 0xdc, 0x4, 0x2e, 0x4d, 0xe9}}
+	info := bindataFileInfo{name: "assets/dns/daemonset.yaml", size: 3707, mode: os.FileMode(420), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x37, 0xe5, 0x42, 0x42, 0x2a, 0xbe, 0x77, 0x9, 0x5a, 0xd2, 0x19, 0xa3, 0x57, 0x85, 0xbe, 0x3c, 0xb3, 0xc7, 0xc4, 0xf9, 0x57, 0x18, 0xed, 0x3b, 0xc, 0x4f, 0x5c, 0xe4, 0xda, 0xd5, 0x5f, 0x12}}
 	return a, nil
 }
 
-var _assetsDnsMetricsClusterRoleBindingYaml = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x7c\x8f\xb1\x4a\x04\x41\x0c\x86\xfb\x79\x8a\xbc\xc0\xae\xd8\x1d\xd3\xa9\x85\xfd\x09\xf6\xb9\x99\x9c\x1b\x77\x27\x19\x92\xcc\x16\x3e\xbd\x2c\x8a\x08\xe2\xb5\x81\x7c\xdf\xff\xad\x2c\x35\xc3\xd3\x36\x3c\xc8\xce\xba\xd1\x23\x4b\x65\x79\x4b\xd8\xf9\x95\xcc\x59\x25\x83\x5d\xb0\xcc\x38\x62\x51\xe3\x0f\x0c\x56\x99\xd7\x93\xcf\xac\x77\xfb\x7d\x6a\x14\x58\x31\x30\x27\x00\xc1\x46\x19\xaa\xf8\xd4\x54\x38\xd4\x0e\x92\x8f\xcb\x3b\x95\xf0\x9c\x26\xf8\xd2\xbd\x90\xed\x5c\xe8\xa1\x14\x1d\x12\x3f\x7f\xdd\xb4\x51\x2c\x34\x7c\x5a\x4f\xfe\x7d\xf6\x8e\x85\x32\x68\x27\xf1\x85\xaf\xf1\x9b\x6c\xba\xd1\x99\xae\x87\xf9\x4f\xc7\x7f\x6b\x00\xb0\xf3\xb3\xe9\xe8\x37\xba\xd2\x67\x00\x00\x00\xff\xff\x5b\x52\x00\xaa\x17\x01\x00\x00")
+var _assetsDnsMetricsClusterRoleBindingYaml = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x7c\x8f\xb1\x4a\x04\x41\x0c\x86\xfb\x79\x8a\xbc\xc0\xae\xd8\x1d\xd3\xa9\x85\xfd\x09\xf6\xb9\x99\x9c\x1b\x77\x27\x19\x92\xcc\x16\x3e\xbd\x2c\x8a\x08\xe2\xb5\x81\x7c\xdf\xff\xad\x2c\x35\xc3\xd3\x36\x3c\xc8\xce\xba\xd1\x23\x4b\x65\x79\x4b\xd8\xf9\x95\xcc\x59\x25\x83\x5d\xb0\xcc\x38\x62\x51\xe3\x0f\x0c\x56\x99\xd7\x93\xcf\xac\x77\xfb\x7d\x6a\x14\x58\x31\x30\x27\x00\xc1\x46\x19\xaa\xf8\xd4\x54\x38\xd4\x0e\x92\x8f\xcb\x3b\x95\xf0\x9c\x26\xf8\xd2\xbd\x90\xed\x5c\xe8\xa1\x14\x1d\x12\x3f\x7f\xdd\xb4\x51\x2c\x34\x7c\x5a\x4f\xfe\x7d\xf6\x8e\x85\x32\x68\x27\xf1\x85\xaf\xf1\x9b\x6c\xba\xd1\x99\xae\x87\xf9\x4f\xc7\x7f\x6b\x00\xb0\xf3\xb3\xe9\xe8\x37\xba\xd2\xe7\x00\x5b\x52\x00\xaa\x17\x01\x00\x00")
 
 func assetsDnsMetricsClusterRoleBindingYamlBytes() ([]byte, error) {
 	return bindataRead(
@@ -160,7 +161,7 @@ func assetsDnsMetricsClusterRoleBindingYaml() (*asset, error) {
 	return a, nil
 }
 
-var _assetsDnsMetricsClusterRoleYaml = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x34\xcd\x31\x4b\x34\x41\x0c\x87\xf1\x7e\x3e\x45\xe0\xad\x77\x5f\xec\x64\x5a\x05\x3b\x0b\x05\xfb\xec\xce\xdf\xdb\x70\x3b\xc9\x90\x64\x0e\xf4\xd3\x8b\x70\xb6\x0f\x3f\x78\xfe\xd1\xd3\x39\x23\xe1\xe4\x76\x22\x48\x81\x86\x46\xdb\x17\x0d\xb7\x8e\x3c\x30\x83\xd2\x28\x76\xe7\x01\x7a\x7e\x7d\xa7\x8e\x74\xd9\x83\xa0\x6d\x98\x68\x16\x1e\xf2\x01\x0f\x31\xad\xe4\x1b\xef\x2b\xcf\x3c\xcc\xe5\x9b\x53\x4c\xd7\xeb\x63\xac\x62\xff\x6f\x0f\xe5\x2a\xda\xea\xdf\xf0\xcd\x4e\x94\x8e\xe4\xc6\xc9\xb5\x10\x29\x77\x54\x6a\x1a\x4b\x37\x95\x34\x17\xbd\x14\x9f\x27\xa2\x96\x85\x78\xc8\x8b\xdb\x1c\xf1\x4b\x17\xb2\x01\xe7\x34\x5f\x6d\x40\xe3\x90\xcf\x5c\xc5\x0a\x91\x23\x6c\xfa\x8e\x3b\x6b\x1a\x88\x42\x74\x83\x6f\xf7\x74\x41\x96\x9f\x00\x00\x00\xff\xff\x9f\xa8\x4d\x6c\xf6\x00\x00\x00")
+var _assetsDnsMetricsClusterRoleYaml = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x34\xcd\xb1\x4a\x04\x31\x10\x06\xe0\x3e\x4f\xf1\x83\xf5\xae\xd8\x49\x5a\x05\x3b\x0b\x05\xfb\xdc\xe6\xf7\x6e\xb8\xdd\x99\x30\x33\x39\xd0\xa7\x17\x41\xfb\x0f\xbe\x3b\x3c\xed\x33\x92\x0e\xb7\x9d\x01\x25\x3b\x3b\x4e\x5f\x18\x6e\x07\xf3\xc2\x19\x48\x43\x6c\xde\x06\xf1\xfc\xfa\x8e\x83\xe9\xb2\x05\xa8\x7d\x98\x68\x96\x36\xe4\x83\x1e\x62\x5a\xe1\xa7\xb6\xad\x6d\xe6\xc5\x5c\xbe\x5b\x8a\xe9\x7a\x7d\x8c\x55\xec\xfe\xf6\x50\xae\xa2\xbd\xfe\x87\x6f\xb6\xb3\x1c\xcc\xd6\x5b\xb6\x5a\x00\x6d\x07\x2b\xba\xc6\x72\x98\x4a\x9a\x8b\x9e\x8b\xcf\x9d\x51\xcb\x82\x36\xe4\xc5\x6d\x8e\xf8\xa5\x0b\x6c\xd0\x5b\x9a\xaf\x36\xa8\x71\x91\xcf\x5c\xc5\x0a\xe0\x0c\x9b\xbe\xf1\x8f\x75\x0d\x46\x01\x6e\xf4\x53\xd4\x02\x2c\x38\x33\xcb\xcf\x00\x9f\xa8\x4d\x6c\xf6\x00\x00\x00")
 
 func assetsDnsMetricsClusterRoleYamlBytes() ([]byte, error) {
 	return bindataRead(
@@ -180,7 +181,7 @@ func assetsDnsMetricsClusterRoleYaml() (*asset, error) {
 	return a, nil
 }
 
-var _assetsDnsMetricsRoleBindingYaml = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x94\xce\xb1\x4e\xc4\x40\x0c\x04\xd0\x7e\xbf\xc2\x3f\x90\x20\xba\xd3\x76\xd0\xd0\x1f\x12\xbd\x6f\xd7\x97\x98\x64\xed\x95\xed\x4d\xc1\xd7\x23\xa4\x48\x54\x20\x5d\x3b\x9a\xd1\x1b\xec\xfc\x41\xe6\xac\x92\xc1\x6e\x58\x66\x1c\xb1\xaa\xf1\x17\x06\xab\xcc\xdb\xc5\x67\xd6\xa7\xe3\x39\x6d\x2c\x35\xc3\x55\x77\x7a\x65\xa9\x2c\x4b\x6a\x14\x58\x31\x30\x27\x00\xc1\x46\x19\xba\x69\xa3\x58\x69\xf8\xb4\x5d\xfc\x8c\xbd\x63\xa1\x0c\xda\x49\x7c\xe5\x7b\x4c\x55\x3c\x99\xee\x74\xa5\xfb\xcf\x14\x3b\xbf\x99\x8e\xfe\x8f\x9f\x00\x7e\xf9\xbf\x34\x1f\xb7\x4f\x2a\xe1\x39\x4d\x67\xfb\x9d\xec\xe0\x42\x2f\xa5\xe8\x90\x78\xf0\x65\x53\xe1\x50\x63\x59\x20\x7d\x07\x00\x00\xff\xff\xb9\xd9\xab\x8d\x25\x01\x00\x00")
+var _assetsDnsMetricsRoleBindingYaml = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x94\xce\xb1\x4e\xc4\x40\x0c\x04\xd0\x7e\xbf\xc2\x3f\x90\x20\xba\xd3\x76\xd0\xd0\x1f\x12\xbd\x6f\xd7\x97\x98\x64\xed\x95\xed\x4d\xc1\xd7\x23\xa4\x48\x54\x20\x5d\x3b\x9a\xd1\x1b\xec\xfc\x41\xe6\xac\x92\xc1\x6e\x58\x66\x1c\xb1\xaa\xf1\x17\x06\xab\xcc\xdb\xc5\x67\xd6\xa7\xe3\x39\x6d\x2c\x35\xc3\x55\x77\x7a\x65\xa9\x2c\x4b\x6a\x14\x58\x31\x30\x27\x00\xc1\x46\x19\xba\x69\xa3\x58\x69\xf8\xb4\x5d\xfc\x8c\xbd\x63\xa1\x0c\xda\x49\x7c\xe5\x7b\x4c\x55\x3c\x99\xee\x74\xa5\xfb\xcf\x14\x3b\xbf\x99\x8e\xfe\x8f\x9f\x00\x7e\xf9\xbf\x34\x1f\xb7\x4f\x2a\xe1\x39\x4d\x67\xfb\x9d\xec\xe0\x42\x2f\xa5\xe8\x90\x78\xf0\x65\x53\xe1\x50\x63\x59\x20\x7d\x0f\x00\xb9\xd9\xab\x8d\x25\x01\x00\x00")
 
 func assetsDnsMetricsRoleBindingYamlBytes() ([]byte, error) {
 	return bindataRead(
@@ -200,7 +201,7 @@ func assetsDnsMetricsRoleBindingYaml() (*asset, error) {
 	return a, nil
 }
 
-var _assetsDnsMetricsRoleYaml = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x4c\x8e\xb1\x4e\xec\x40\x0c\x45\xfb\xf9\x0a\x6b\x5f\x9d\x7d\xa2\x5b\x4d\x8d\x44\x47\x01\x12\xbd\x77\xe6\x42\xac\x24\xe3\x91\xed\x04\xc1\xd7\xa3\xcd\x06\x89\xca\xf7\x1e\x59\x3e\xfe\x47\x2f\x3a\xc3\xa9\x01\x15\x95\xae\x5f\xd4\x4d\x17\xc4\x88\xd5\x29\x94\xbc\x18\x77\xd0\xe3\xf3\x2b\x2d\x08\x93\xe2\x84\x56\xbb\x4a\x8b\xc4\x5d\xde\x60\x2e\xda\x32\xd9\x95\xcb\x99\xd7\x18\xd5\xe4\x9b\x43\xb4\x9d\xa7\x8b\x9f\x45\xff\x6f\x0f\x69\x92\x56\xf3\x2e\x4a\x0b\x82\x2b\x07\xe7\x44\xd4\x78\x41\xfe\xe3\x1b\xa6\x8b\x1f\xd8\x3b\x17\x64\xd2\x8e\xe6\xa3\xbc\xc7\x50\x9b\x27\x5b\x67\x78\x4e\x03\x71\x97\x27\xd3\xb5\xfb\xed\xca\x40\xa7\x53\x22\x32\xb8\xae\x56\x70\x30\x87\x6d\x52\xe0\x7b\xf9\xfd\xf8\xde\xba\xd6\x5b\xd8\x60\xd7\x63\xf9\x03\xb1\xcf\x59\xfc\x1e\x3e\x39\xca\x98\x7e\x02\x00\x00\xff\xff\x29\x39\xda\x05\x1c\x01\x00\x00")
+var _assetsDnsMetricsRoleYaml = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x4c\x8e\xb1\x4e\xec\x40\x0c\x45\xfb\xf9\x0a\x6b\x5f\x9d\x7d\xa2\x5b\x4d\x8d\x44\x47\x01\x12\xbd\x77\xe6\x42\xac\x24\xe3\x91\xed\x04\xc1\xd7\xa3\xec\x46\x88\xca\xd7\x57\xd6\x39\xfe\x47\x2f\x3a\xc3\xa9\x01\x15\x95\xae\x5f\xd4\x4d\x17\xc4\x88\xd5\x29\x94\xbc\x18\x77\xd0\xe3\xf3\x2b\x2d\x08\x93\xe2\x84\x56\xbb\x4a\x8b\xc4\x5d\xde\x60\x2e\xda\x32\xd9\x95\xcb\x99\xd7\x18\xd5\xe4\x9b\x43\xb4\x9d\xa7\x8b\x9f\x45\xff\x6f\x0f\x69\x92\x56\xf3\x4d\x94\x16\x04\x57\x0e\xce\x89\xa8\xf1\x82\xfc\xc7\x37\x4c\x17\x3f\x6a\xef\x5c\x90\x49\x3b\x9a\x8f\xf2\x1e\x43\x6d\x9e\x6c\x9d\xe1\x39\x0d\xc4\x5d\x9e\x4c\xd7\xee\x3b\x65\xa0\xd3\x29\x11\x19\x5c\x57\x2b\x38\x3a\x87\x6d\x52\xb0\xf3\x86\xdf\x8f\xef\x5b\xd7\xba\x87\x0d\x76\x3d\x8e\x3f\x10\xb7\x39\x8b\xdf\xc3\x27\x47\x19\xd3\xcf\x00\x29\x39\xda\x05\x1c\x01\x00\x00")
 
 func assetsDnsMetricsRoleYamlBytes() ([]byte, error) {
 	return bindataRead(
@@ -220,7 +221,7 @@ func assetsDnsMetricsRoleYaml() (*asset, error) {
 	return a, nil
 }
 
-var _assetsDnsNamespaceYaml = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x64\x90\x41\x6e\xeb\x30\x0c\x44\xf7\x3e\xc5\x40\x7f\x9d\xfc\x76\xab\x3b\xb4\x9b\x02\xdd\x33\x16\x93\xb0\x96\x48\x43\xa4\x9d\xeb\x17\x4e\x82\x36\x45\x96\x02\x1f\xe6\xcd\x68\x12\x2d\x19\xef\xd4\xd8\x67\x1a\x79\xa0\x59\x3e\xb9\xbb\x98\x66\xac\xaf\x43\xe3\xa0\x42\x41\x79\x00\x48\xd5\x82\x42\x4c\x7d\x7b\x02\x36\xb3\xfa\x59\x8e\xb1\x17\xfb\xaf\x56\x78\xe7\x5c\x79\x0c\xeb\x19\x29\x5d\x91\x8b\xf5\xa9\x1a\x95\xfd\x1f\x96\x6a\xb5\x0b\x97\x8c\xd4\x48\xe9\xc4\x8d\x35\x36\x5e\xa9\x71\xfe\x8d\xdd\x15\xf5\x01\xa8\x74\xe0\x7a\x57\xfe\x83\x73\x60\xa5\xba\x30\xc2\x40\xab\x49\x41\xe1\x99\xb5\x88\x9e\x60\x8a\x69\x39\x30\xa8\x34\xf1\x6d\x04\xe2\x4c\x71\x07\x7c\x3b\xff\x84\x83\x66\xf1\xe7\x19\x7d\xd1\x5d\xe5\x95\x6b\x46\x7a\x49\x77\xe7\xb5\xef\x43\xaf\x66\x2a\x61\x7d\x33\x86\xa1\x9a\x4d\x38\x5a\xc7\x07\xf7\x55\x46\x7e\xbb\x5d\x61\x87\x2f\x1e\xc3\x21\x5b\x0b\xf1\xeb\xba\xdb\x27\x3f\x59\xc7\xba\x78\x70\x7f\x08\xce\x48\xd1\x17\x4e\xc3\x77\x00\x00\x00\xff\xff\xc8\x85\x12\x2a\xa1\x01\x00\x00")
+var _assetsDnsNamespaceYaml = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x64\x90\xc1\x6e\xe3\x30\x0c\x44\xef\xfe\x8a\x81\xf6\xec\xec\xee\x55\xff\xb0\x7b\x29\xd0\x3b\x63\x31\x09\x6b\x89\x34\x44\xda\xf9\xfd\xc2\x69\x90\xb4\xc8\x51\x98\x87\x79\x1a\xce\xa2\x25\xe3\x3f\x35\xf6\x85\x26\x1e\x68\x91\x77\xee\x2e\xa6\x19\xdb\xdf\xa1\x71\x50\xa1\xa0\x3c\x00\xa4\x6a\x41\x21\xa6\xbe\x3f\x01\x5b\x58\xfd\x22\xa7\x38\x88\xfd\x56\x2b\x3c\x3a\x57\x9e\xc2\x7a\x46\x4a\x37\xe4\x6a\x7d\xae\x46\xe5\xf0\x83\xa5\x5a\xed\xca\x25\x23\x35\x52\x3a\x73\x63\x8d\x9d\x57\x6a\x9c\x9f\xb5\x63\x51\x1f\x80\x4a\x47\xae\x77\xe5\x2f\x38\x07\x36\xaa\x2b\x23\x0c\xb4\x99\x14\x14\x5e\x58\x8b\xe8\x19\xa6\x98\xd7\x23\x83\x4a\x13\xdf\x47\x20\x2e\x14\x77\xc0\xf7\xf8\x51\x0e\x5a\xc4\x5f\x67\xf4\x55\xc7\xca\x1b\xd7\x8c\xf4\x27\xdd\x9d\xb7\xff\x3e\xb9\xb1\x99\x4a\x58\xdf\x8d\x61\xa8\x66\x33\x4e\xd6\xf1\xc6\x7d\x93\x89\xff\x7d\xa5\xb0\xe3\x07\x4f\xe1\x10\x45\x5c\xc4\xa1\x8f\x23\xbf\x58\xa7\xba\x7a\x70\xff\x56\x9c\x91\xa2\xaf\x9c\x86\xcf\x01\x00\xc8\x85\x12\x2a\xa1\x01\x00\x00")
 
 func assetsDnsNamespaceYamlBytes() ([]byte, error) {
 	return bindataRead(
@@ -240,7 +241,7 @@ func assetsDnsNamespaceYaml() (*asset, error) {
 	return a, nil
 }
 
-var _assetsDnsServiceAccountYaml = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x2c\xc9\xb1\x09\xc4\x30\x0c\x05\xd0\xde\x53\x68\x81\x2b\xae\x55\x77\x33\x1c\xa4\x17\xf2\x0f\x11\xc1\xb2\xb1\x14\xcf\x1f\x02\xe9\x1e\xbc\xd3\xbc\x32\xfd\x31\x97\x29\x7e\xaa\xfd\xf2\x2c\x32\x6c\xc3\x0c\xeb\xce\xb4\xbe\xa5\x21\xa5\x4a\x0a\x17\x22\x97\x06\xa6\xea\xf1\x3a\x86\x28\x98\xfa\x80\xc7\x61\x7b\x7e\x9e\xba\x03\x00\x00\xff\xff\x8e\x2c\xf1\x2e\x55\x00\x00\x00")
+var _assetsDnsServiceAccountYaml = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x00\x55\x00\xaa\xff\x6b\x69\x6e\x64\x3a\x20\x53\x65\x72\x76\x69\x63\x65\x41\x63\x63\x6f\x75\x6e\x74\x0a\x61\x70\x69\x56\x65\x72\x73\x69\x6f\x6e\x3a\x20\x76\x31\x0a\x6d\x65\x74\x61\x64\x61\x74\x61\x3a\x0a\x20\x20\x6e\x61\x6d\x65\x3a\x20\x64\x6e\x73\x0a\x20\x20\x6e\x61\x6d\x65\x73\x70\x61\x63\x65\x3a\x20\x6f\x70\x65\x6e\x73\x68\x69\x66\x74\x2d\x64\x6e\x73\x0a\x03\x00\x8e\x2c\xf1\x2e\x55\x00\x00\x00")
 
 func assetsDnsServiceAccountYamlBytes() ([]byte, error) {
 	return bindataRead(
@@ -260,7 +261,7 @@ func assetsDnsServiceAccountYaml() (*asset, error) {
 	return a, nil
 }
 
-var _assetsDnsServiceYaml = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x84\x91\x31\x6f\xe2\x40\x10\x85\x7b\xff\x8a\x27\xe8\x4e\xc0\x09\xdd\x51\x9c\xdb\xa3\x89\x52\x80\x14\x48\x3f\x5e\x4f\xcc\x8a\xf5\x8c\xb5\x33\x06\xf1\xef\x23\x4c\x42\x80\x14\x69\x56\xda\x7d\x9f\x3e\x3d\xbd\xdd\x47\xa9\x4b\xbc\x70\x3e\xc4\xc0\x05\x75\xf1\x95\xb3\x45\x95\x12\x87\x79\x31\x86\x50\xcb\x93\xe1\xb4\x8e\x02\x4f\x12\x55\x9c\x0c\x24\x35\x48\x44\x9d\x3c\xaa\x18\x28\x33\x8c\x1d\xe4\xc8\xbd\x78\x6c\xb9\xb0\x8e\x43\x59\x00\x63\x84\xd4\x9b\x73\x7e\x5a\xe3\x18\x53\x42\xc5\xa0\xde\xb5\x25\x8f\x81\x52\x3a\xa1\x25\xa1\x86\xeb\xd9\x00\x1b\x27\x0e\xae\x19\xd1\x1e\x8d\x40\xa7\xd9\xed\x2c\x9d\x0e\x95\x4a\xd4\x62\x05\x70\x09\x4a\x2c\xfe\x0c\x17\xa7\xdc\xb0\xaf\x87\xa7\x2b\x90\xd5\x35\x68\x2a\xb1\x5d\xae\xef\x05\x53\x0f\xdd\x8f\x92\x2f\xe8\x2a\xda\xfc\xbf\x15\xb5\xec\x39\x86\xdb\x36\xff\xe6\x8b\xbf\xdf\x54\x77\xd8\x83\x6a\x8c\xcd\x6a\xb9\x2a\xb1\x95\xa0\x6d\xcb\xe2\x38\xee\x58\x60\x97\xbf\x81\x6b\xa7\x49\x9b\x13\xde\x98\xbc\xcf\x8c\x86\x9c\xcf\x33\xb1\x50\x95\x3e\xf6\xfb\x84\x9e\xf9\x64\x97\xf5\x31\xc5\x68\xdf\x57\x9c\x85\x9d\x6d\x16\xf5\xf7\x4e\xcd\xcf\xa5\x47\xd7\xfc\xd7\xa8\x78\x0f\x00\x00\xff\xff\x82\x42\x75\xa4\x08\x02\x00\x00")
+var _assetsDnsServiceYaml = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x84\x91\x31\x6f\xe2\x40\x10\x85\x7b\xff\x8a\x27\xdc\x9d\x80\x13\xba\xa3\x38\xb7\x47\x13\xa5\x00\x29\x90\x7e\xbc\x9e\x98\x15\xbb\x33\xd6\xee\x18\xc4\xbf\x8f\x6c\x12\x02\xa4\x48\xb3\xd2\xea\x7d\xfa\xf4\xf4\xe6\xe0\xa5\xa9\xf0\xc2\xe9\xe8\x1d\x17\xd4\xf9\x57\x4e\xd9\xab\x54\x38\x2e\x8a\x12\x42\x91\xa7\xe3\x9b\x3b\x72\x3c\x0d\x54\x73\xc8\x20\x69\x40\x22\x6a\x64\x5e\x25\x83\x12\x23\xb3\x81\x0c\xa9\x17\xf3\x91\x8b\xdc\xb1\xab\x0a\xa0\x84\x0b\x7d\x36\x4e\x4f\x1b\x9c\x7c\x08\xa8\x19\xd4\x9b\x46\x32\xef\x28\x84\x33\x22\x09\xb5\xdc\xcc\x47\x38\x73\x60\x67\x9a\xe0\xf3\xa3\x11\xe8\x34\x59\x1e\xa4\xb3\xb1\x52\x85\x46\x72\x01\x5c\x82\x0a\xcb\x3f\xe3\xc7\x28\xb5\x6c\x1b\x4d\x76\x03\x24\x35\x75\x1a\x2a\xec\x56\x9b\x7b\xc1\xcc\x5c\xf7\xa3\xe4\x0b\xba\x8a\xb6\xff\x6f\x45\x91\x2d\x79\x77\xdb\xe6\xdf\x62\xf9\xf7\x9b\xea\x0e\x7b\x50\x95\xd8\xae\x57\xeb\x0a\x3b\x71\x1a\x23\x8b\xe1\xb4\x67\x41\xbe\xdc\x06\xa6\x9d\x06\x6d\xcf\x78\x63\xb2\x3e\x31\x5a\x32\x1e\x66\x62\xa1\x3a\x7c\xec\xf7\x09\x3d\xf3\x79\x1c\xaa\x1c\x1a\x4e\x0e\x7d\xcd\x49\xd8\x38\xcf\xbd\xfe\xde\x6b\xb6\xa1\xf4\xe4\x9a\xff\x9a\x14\xef\x03\x00\x82\x42\x75\xa4\x08\x02\x00\x00")
 
 func assetsDnsServiceYamlBytes() ([]byte, error) {
 	return bindataRead(
@@ -280,7 +281,27 @@ func assetsDnsServiceYaml() (*asset, error) {
 	return a, nil
 }
 
-var _assetsNodeResolverServiceAccountYaml = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x2c\xc8\x31\x0e\x02\x41\x08\x05\xd0\x7e\x4e\xc1\x05\xb6\xb0\xa5\xf3\x0c\x26\xf6\x64\xf8\x46\xa2\x0b\x13\xc0\x39\xbf\xcd\xb6\xef\x63\xae\x4c\x0f\xe4\xb6\x89\xfb\x9c\xf1\xf3\x1e\xb2\xec\x89\x2c\x0b\x67\xda\xb7\x71\xa2\x45\xa5\x85\x07\x91\xcb\x09\x26\x0f\xc5\x91\xa8\xf8\x6e\xe4\xa5\xb5\x64\x82\x29\x16\xbc\xde\xf6\xea\x43\xbd\xc6\x3f\x00\x00\xff\xff\x72\xbb\x64\x48\x5f\x00\x00\x00")
+var _assetsDnsWarmCacheSh = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x7c\x92\x4f\x6b\xeb\x38\x14\xc5\xf7\xfa\x14\xa7\x76\x68\x12\xf2\x3f\x9b\x59\x0c\x29\x35\x6d\x06\x0a\x6d\x32\x24\x99\xc5\x30\x0c\x45\xb6\xae\x6b\x51\x5b\x72\x25\xb9\x79\x26\xf5\x77\x7f\xc8\x76\xdf\x2b\x6f\xf1\x36\xc6\x48\x57\xe7\xfc\xee\xbd\x27\xbc\x5a\xc4\x52\x2d\x62\x6e\x33\x66\xc9\x61\x56\x69\x94\xb2\xa4\x94\xcb\x9c\xb1\x10\xbb\xe8\x69\x7b\x84\xb4\xe0\xb0\x25\x4f\x68\x26\x28\x97\x85\x74\x24\x90\x4b\xeb\xa0\x53\xa4\x55\x9e\xd7\x78\xab\x78\x2e\x53\x49\x02\x8a\x17\x64\xe7\xc0\x5e\x25\x84\x3b\x6d\xe8\x7e\x77\x84\x54\x2c\x84\xcb\x08\xa5\x16\x43\x0b\x45\xee\xac\xcd\x6b\x57\xeb\x75\xc1\x95\x3d\x93\xb1\x78\xab\xc8\x48\xb2\x53\x18\xb2\x3a\x7f\x27\x10\x4f\xb2\xb6\x6e\x68\x11\x81\x2b\x81\x28\x8a\x22\x16\xc2\x50\xa2\x8d\xb0\x70\x99\xd1\xd5\x4b\x06\xe9\x60\x35\x5c\xc6\x1d\xa4\xb3\x48\x78\x92\x11\x32\xee\x0b\xa8\x98\xb6\x2f\x5d\x46\x0a\x89\x21\xee\x08\x87\x6d\x74\xff\xef\xf3\x5f\x0f\x8f\xdb\x29\x0b\x71\xce\x64\x92\xf9\x4a\x24\x5a\x39\x2e\x15\x99\xa1\x85\x21\x2e\xa4\x22\x6b\x51\x1a\x1d\x13\x92\x8c\x92\x57\x3b\xfd\xe1\xd3\x37\x84\x98\x12\x5d\x50\x57\x5f\xb3\x10\x5a\xe5\x35\x78\xea\xc8\x7c\x41\x91\x16\x67\x6e\x8a\x39\xb0\xf5\x2d\xf9\x46\x6b\x3f\xd9\x58\x57\x4a\x90\x40\x5c\x43\xc8\x97\xa1\x85\x93\x05\xe9\xca\x79\xe2\xb6\x4d\xd7\x0d\xc4\x6a\x70\x85\x4a\x19\x3f\x11\x1e\xe7\x84\xaa\xb4\xce\x10\x2f\x20\x28\xe7\xf5\x57\xdc\xb8\x06\x77\x28\xb4\x75\xe0\x48\xe9\xcc\x42\x58\x4a\xb4\x12\x76\xce\x2a\xe5\x64\xee\xad\x30\xf1\x4e\x9b\x15\x26\xad\xc3\x66\x85\x59\x89\x60\x70\xf9\x7b\x7f\x38\x35\x01\x6e\x57\xeb\x3f\xe6\xcb\xf9\x72\xbe\xf2\x87\x77\x8f\xff\x1c\x4f\xdb\xc3\xf3\xfd\xfe\x29\x7a\xd8\x35\xf3\x00\xc7\x7d\x84\x9b\x85\xa0\xf7\x85\xaa\xf2\x1c\xeb\x9b\xeb\xd5\x9f\x10\x9a\x01\x36\x27\x2a\xb1\x62\x42\x2b\x62\xac\x94\xc2\x6e\x46\x63\x96\x6a\xd3\xee\x11\x52\x61\x70\x69\x83\xd5\xf4\x0f\xfc\x95\xab\xcb\xf6\x2a\x6a\xf7\xdb\x5f\xe0\x0b\xe7\xfa\x93\x73\xfd\x3b\x4e\xef\xd0\x04\xfe\xcf\x0b\x36\xc1\xaf\x88\xb8\x6e\x65\x3d\xd4\x64\x33\x0a\x06\x57\xc1\x98\x01\x2d\x69\xfb\xf1\xc1\x27\xb1\x59\xb6\xb8\xa5\x14\x1e\x29\x18\x5c\x7c\xfd\x7f\xb7\xff\x37\x41\x0f\x76\xe6\xd2\xf5\xe7\x4d\x80\x8f\x0f\xf4\xef\x06\xa3\x51\xf7\x87\x09\x56\xe3\x71\x37\x02\x4a\x32\x8d\xa0\x0f\xb4\xc0\xe0\x12\x7e\xca\x7d\xc6\x1d\xde\xad\x4b\xac\xcf\xc8\xac\x2a\xa7\x18\x5c\x3a\xa5\xa6\xd7\x0e\x18\x73\xba\x4a\x32\x6f\xfb\x33\xbc\x8d\x3f\x36\xbc\xc4\x90\xbe\x49\x87\xe5\x10\xa7\xed\xe1\x09\x0f\xbb\x13\xeb\xd6\x20\x55\x2a\x95\x74\x35\xae\xd9\x99\x4b\xc7\xbe\x0f\x00\x42\xc0\x58\x58\xf2\x03\x00\x00")
+
+func assetsDnsWarmCacheShBytes() ([]byte, error) {
+	return bindataRead(
+		_assetsDnsWarmCacheSh,
+		"assets/dns/warm-cache.sh",
+	)
+}
+
+func assetsDnsWarmCacheSh() (*asset, error) {
+	bytes, err := assetsDnsWarmCacheShBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "assets/dns/warm-cache.sh", size: 1010, mode: os.FileMode(420), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x31, 0xb3, 0xf9, 0xa8, 0x3b, 0x42, 0x20, 0x7e, 0xa7, 0x8b, 0xa0, 0x5f, 0x75, 0xae, 0x78, 0x6b, 0x33, 0x84, 0x41, 0xb8, 0x21, 0x75, 0x90, 0xf0, 0x88, 0x5a, 0x75, 0x24, 0xbf, 0x8f, 0xfb, 0xe7}}
+	return a, nil
+}
+
+var _assetsNodeResolverServiceAccountYaml = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x00\x5f\x00\xa0\xff\x6b\x69\x6e\x64\x3a\x20\x53\x65\x72\x76\x69\x63\x65\x41\x63\x63\x6f\x75\x6e\x74\x0a\x61\x70\x69\x56\x65\x72\x73\x69\x6f\x6e\x3a\x20\x76\x31\x0a\x6d\x65\x74\x61\x64\x61\x74\x61\x3a\x0a\x20\x20\x6e\x61\x6d\x65\x3a\x20\x6e\x6f\x64\x65\x2d\x72\x65\x73\x6f\x6c\x76\x65\x72\x0a\x20\x20\x6e\x61\x6d\x65\x73\x70\x61\x63\x65\x3a\x20\x6f\x70\x65\x6e\x73\x68\x69\x66\x74\x2d\x64\x6e\x73\x0a\x03\x00\x72\xbb\x64\x48\x5f\x00\x00\x00")
 
 func assetsNodeResolverServiceAccountYamlBytes() ([]byte, error) {
 	return bindataRead(
@@ -300,7 +321,7 @@ func assetsNodeResolverServiceAccountYaml() (*asset, error) {
 	return a, nil
 }
 
-var _assetsNodeResolverUpdateNodeResolverSh = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xb4\x56\x51\x73\xd3\x38\x17\x7d\xf7\xaf\x38\xb8\x19\xd2\x02\x6e\xfb\xcd\xc7\xf0\x40\x09\x4b\x96\xa6\x43\x06\xda\x64\x92\xb0\x3c\x74\xb2\x1d\xc5\xbe\x8e\xb5\x55\x24\x21\xc9\x0e\x99\x36\xff\x7d\x47\xb2\x9d\x14\xca\xee\xd3\x92\x07\x7b\xac\x5c\x9f\x7b\xcf\xd5\xb9\x47\x3e\x78\x72\xb2\xe0\xf2\x64\xc1\x6c\x11\x59\x72\x48\x4a\x05\xcd\x35\xe5\x8c\x8b\x28\x72\x86\x69\x74\xff\x52\x0b\x8b\x44\xe3\x1e\xdf\x98\x59\x5a\xdc\x72\x21\x70\x7f\x0f\x67\x4a\x3a\xc3\x9a\x71\x77\x06\xfa\xc6\x1d\x4e\xbb\x98\x0d\x26\x97\x51\x34\x1a\x0f\xae\xa6\x1f\x86\x17\xb3\x9b\xcb\xfe\xe4\xe3\x60\xd2\x8b\x95\x26\x69\x0b\x9e\xbb\x64\x49\x92\x0c\x73\x94\x25\x52\x65\x94\x18\xb2\x4a\x54\x64\xe2\xe8\xc3\x68\x3a\x9b\xde\x5c\x0c\x3f\x0d\x7a\xf1\x09\xb9\xf4\xa4\x50\xd6\xd9\x38\x9a\x0d\x2e\xc7\x8f\x96\x8f\xdd\x4a\xc7\x51\x34\xbc\x98\xf6\xba\x2f\xd0\x85\x21\x96\x21\x31\x48\x18\x2c\x99\x8a\xa7\x64\xf1\xe6\xcd\x1b\xc4\x9d\xbb\xe9\x60\xf2\xc7\xf0\xfd\x60\xba\x8d\xa3\xe8\x00\x97\xec\x96\xc0\xe0\x68\xa5\x95\x61\x66\x83\x9c\x0b\xc2\x9a\xbb\x02\xae\x20\x28\x91\x21\x24\x08\xeb\x5d\x0b\xe6\x9c\xe1\x8b\xd2\x91\x3d\x8e\x52\x8d\x24\x47\x92\xec\xd7\x12\x25\xc5\xc6\x27\xd9\x17\xbf\x8d\xfd\xf3\xae\x68\x9f\x75\x5d\xf8\x1c\x75\xbb\x32\x15\x01\x19\xa5\x82\x19\x42\xd2\x87\xad\xd2\x1b\xae\x6d\x04\xe4\xca\xf8\x27\x70\xe9\x01\x5a\x16\xd7\xef\xe6\xdb\xb8\x79\x0d\x38\xc0\x05\xb9\xb4\x68\x39\x62\x38\x46\x6e\xd4\x0a\xa9\x28\xad\x23\x83\x4c\x5a\xf0\x1c\xda\x90\x25\xe9\x8e\xf1\x85\xb0\xf2\x84\x2d\x55\x64\x98\x80\x33\x9c\x6c\x83\xe4\x14\x32\x05\xee\x5e\x63\x38\xae\x5e\xbe\xf0\xd7\x57\xe1\xfa\x12\xaa\x22\x83\xd9\xfb\x31\x98\xcc\xc2\xfa\x6e\xe5\x18\xb3\x82\xe0\xd6\x0a\x82\x59\x07\x25\x77\x70\x9e\x8f\xa7\x90\x91\x16\x6a\xb3\x22\xe9\x6c\xdd\xd6\x8f\xa5\xd9\x18\x28\xe9\x7b\x4b\x06\x23\x4d\x72\xea\x58\x7a\x8b\xc3\xd1\x74\xfc\xbf\xff\x1f\x21\x81\x2b\x94\x25\x5f\x8d\x54\xae\x81\xb3\xa5\xd6\xca\x38\x7c\x3e\x1f\x43\x28\x96\x2d\x98\x60\x32\x25\x63\x43\x4d\x86\xbe\x96\xdc\x90\xdf\xf6\xb4\xe0\x72\x89\xf3\xab\x29\x5c\x61\x54\xb9\x2c\x42\x99\x01\x25\x5d\x65\xb6\x77\xd8\xcd\xf8\x12\x89\x43\x1f\xef\xe2\xce\xdd\x55\xff\x72\xe0\x15\x31\x98\x6c\x63\x3c\xb7\x85\xcf\xe1\xdb\x5d\xa5\xdb\xe3\xce\xdd\xfb\x4f\x9f\xa7\xb3\xc1\xe4\xe6\x7c\x74\xd9\x1f\x5e\x6d\xe3\xfb\xa5\x21\x8d\xa4\x42\xfc\xe7\x59\xdc\x0d\xa0\xf5\x6f\x07\xda\xef\xff\x1a\x5c\x3c\x77\xa9\xc6\x73\x43\xce\x6c\x7a\xa7\xbf\xac\xf4\xff\x36\xcb\x51\x48\xe3\x55\xc0\xbd\x8c\x3b\x77\x4f\xfc\x16\x5c\x3f\x9b\x6f\xc3\x1f\x8d\x88\x01\xae\x6d\xef\xb0\x73\x48\x15\x13\x1e\x3b\x04\xf1\xf9\x36\x3e\x3a\x6a\x03\x72\x5c\x5f\x23\xee\xfc\x16\x23\xa1\xaf\x38\xc5\xd3\xa7\x3e\xf0\x80\xeb\x7a\x20\x90\x48\xc2\x29\xe6\xf3\x33\x3f\xb5\x72\xc7\xae\x99\xa6\xeb\xa6\xe0\x78\xde\x8b\x3b\x77\xed\x4b\xbb\xa8\x85\x21\x76\xdb\x3c\xe5\xbc\x29\x4d\x52\xd4\xdc\x22\xaf\xbf\xcf\x3a\x63\x8e\xb0\x77\x1c\x84\x51\xe7\x39\xd6\x84\x25\x39\x54\x4c\xf0\xec\xc1\x20\xda\xf0\xda\x17\xef\x25\x42\x78\x21\xa3\x7c\x04\xb1\x2e\x48\xfa\x82\x0d\x81\x5b\xa4\xca\x90\x1f\xd8\x16\x43\x95\x8e\x2d\x09\xca\x80\x69\x8e\x52\xb2\x8a\x71\xc1\x16\x5c\x70\xb7\x09\xe0\x53\xc7\x04\x81\x64\x98\x62\xa4\xaa\x14\x99\x37\x5e\xeb\x7c\xb3\x1f\xa4\xe1\x79\xf0\xb2\x16\x97\x5b\x64\x24\xc8\x51\x16\xb5\x9d\x4d\x64\xb3\xa7\xa1\x5b\xcf\xe6\xc9\x36\xfe\xbe\x99\x07\xf8\xbd\xe4\x22\x03\x83\xa4\xf5\x03\x47\xac\xed\xe6\x21\x25\x3f\xe2\xaa\x34\x48\x4b\xeb\xd4\x6a\x57\x5d\xce\x85\x23\x43\x99\x67\x15\x10\x77\x42\x39\x40\xe7\xee\xc7\x03\xa2\x36\xcd\xef\x4c\xf4\xed\x23\x1b\xad\xeb\xea\x6b\x4d\xc1\x01\xea\x73\x63\x9f\xd0\xdb\x67\x63\x9a\x3b\x19\xee\xfd\xf4\x49\x4b\xf6\x3b\x3f\x6d\xc4\xaa\x6b\xb5\xb6\x21\xb5\x78\xe6\xdb\x07\x61\x00\xa5\x85\x42\x50\xd3\xb6\x0e\x6d\x6f\x8f\xa7\x02\xff\x40\xf1\xed\x23\x4e\x35\x74\x23\xbe\xbd\xfc\x3c\xcf\xd9\xe8\x7c\xf4\xfa\x27\x32\x64\x4e\xad\x78\xca\x84\xd8\x78\x07\x67\x95\xe2\x19\x98\xdc\x80\xcb\x54\x49\xcb\xad\x23\xe9\xb0\xa0\x82\x55\x5c\x99\x06\x6b\x42\x5a\xb0\x94\x7e\xba\x6f\x2b\x95\xf1\x9c\x53\x86\x8a\x8c\xe5\x4a\x7a\x85\x48\xa2\x2c\xa8\xc5\x7b\xa8\xfe\xa1\xe8\x47\x3b\x75\x7f\x8f\xfa\x64\xfc\xf7\xb8\x96\x57\x1b\xe1\x75\xe9\xc7\xc4\xd0\x4a\x55\x94\xed\xd9\x04\x9d\xa5\x86\x98\xa3\x93\x5a\xb8\xc1\xf3\xf7\xa7\x2e\x52\xa5\x37\x48\x8b\xd2\x78\xb1\x86\x09\xb6\x82\x48\xe3\xd5\x29\x9e\x86\x0f\x92\x08\x28\xa5\xff\x9e\x69\x8f\xd7\xd0\xd9\xbf\x03\x00\x00\xff\xff\xb1\x48\x84\xd4\xed\x08\x00\x00")
+var _assetsNodeResolverUpdateNodeResolverSh = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xb4\x57\x6b\x6f\x1a\x3d\x16\xfe\x3e\xbf\xe2\xe9\x80\x4a\xf2\x36\x43\xb2\xda\x77\xfb\xe1\x4d\xe8\x96\x6d\x88\x8a\xda\x24\x08\x68\xf7\x43\xc4\x22\x33\x73\x60\xbc\x31\xb6\x6b\x7b\xa0\x88\xf0\xdf\x57\xf6\x0c\x97\xdc\x56\xda\x4b\xa5\x08\xc1\xcc\xf1\xb9\x3e\xcf\x73\x9c\xda\x9b\xd3\x09\x97\xa7\x13\x66\xf3\xc8\x92\x43\x52\x28\x68\xae\x69\xca\xb8\x88\x22\x67\x98\x46\xe3\x9f\x6a\x62\x91\x68\x3c\xe0\x27\x33\x33\x8b\x7b\x2e\x04\x1e\x1e\xe0\x4c\x41\xe7\x58\x32\xee\xce\x41\x3f\xb9\xc3\x59\x03\xc3\x4e\xff\x3a\x8a\x6e\x7b\x9d\x9b\xc1\xe7\xee\xd5\x70\x7c\xdd\xee\x7f\xe9\xf4\x5b\xb1\xd2\x24\x6d\xce\xa7\x2e\x99\x91\x24\xc3\x1c\x65\x89\x54\x19\x25\x86\xac\x12\x0b\x32\x71\xf4\xf9\x76\x30\x1c\x8c\xaf\xba\x5f\x3b\xad\xf8\x94\x5c\x7a\x9a\x2b\xeb\x6c\x1c\x0d\x3b\xd7\xbd\xf1\x55\xf7\x6b\xa7\x15\x9f\x92\x4b\x4f\x73\x65\x9d\x6d\xba\xb9\x8e\xa3\xa8\x7b\x35\x68\x35\x4e\xd0\x80\x21\x96\x21\x31\x48\x18\x2c\x99\x05\x4f\xc9\xe2\xe2\xe2\x02\x71\x7d\x3d\xe8\xf4\xbf\x77\x3f\x75\x06\x9b\x38\x8a\x6a\xb8\x66\xf7\x04\x06\x47\x73\xad\x0c\x33\x2b\x4c\xb9\x20\x2c\xb9\xcb\xe1\x72\x82\x12\x19\x42\x00\x4c\xb9\xa0\x86\x05\x73\xce\xf0\x49\xe1\xc8\x36\xa3\x54\x23\x99\x22\x49\xf6\xcf\x12\x25\xc5\x0a\x71\x7d\xbd\x4f\x7e\x13\xfb\xdf\xbb\xa4\xcb\xa8\x85\xce\x98\xa3\xf1\xd4\xa8\xf9\x98\xa4\x33\x9c\xec\xd8\x07\x80\x21\x2d\x98\x4f\x56\x15\x06\x69\x61\x9d\x9a\xa3\x32\x00\x97\xd8\x17\xbc\x4b\x31\xaa\x1d\x1a\x94\x71\x3b\x37\xc3\x7e\xb7\x53\xc6\x3f\xc1\x32\xe7\x69\x30\x85\xd2\xbe\xd5\xca\x40\x17\x13\xc1\x6d\x5e\x9e\x61\x48\x95\x9c\xf2\xd9\x9c\xe9\xa8\x86\x65\x4e\x92\x16\x64\xc2\x81\x6d\xef\x1a\x48\x45\x61\x1d\x19\x74\x7b\x16\x69\xce\xe4\x8c\x9a\xd1\x6b\x55\x1c\x1d\x63\x1d\x01\x33\x43\x1a\xc9\x02\x71\x0d\xf5\xf5\x53\x08\x6c\xe2\x67\x6d\xfa\xf0\xb4\x51\xf0\xa9\x87\xa6\x94\xc3\xf4\x31\x56\xe7\xc8\x54\x04\x00\x7c\x8a\xbb\x3b\x24\xd2\x9f\x0a\x6f\x36\x31\x46\xa3\x73\x9f\xb7\x0c\x06\x00\xa5\xb9\xda\xbf\xc6\x2b\x79\x7c\x78\x21\x30\x30\xe5\x11\x90\x29\x49\xb8\xd8\x67\x7a\xd8\xd8\x60\x97\xce\x35\x12\xfb\xe4\xfc\xb3\xca\x1e\x1e\x50\x62\xe5\xdf\xdb\x45\x9b\x28\x2a\x2b\x2e\xa9\x14\x0a\xad\xa1\x67\x68\x5a\xcd\x63\x3b\x69\x97\x33\xf7\xca\x44\x9b\x40\x9f\x58\xc6\xe5\x2c\x18\xcc\x55\x21\x1d\x65\xc1\xd1\x6e\xcc\xe0\x7e\x88\xc4\xf4\x09\xac\xf2\xdf\xd2\x7b\x70\x87\xa9\xa1\x1f\x05\x49\x27\x56\x70\x9e\xf6\xe9\x3d\x0a\x5d\x4d\xdb\x42\x1b\x35\xd7\x4e\xac\x9a\xc1\xd7\x15\x13\x02\x13\x96\xde\x7b\xd3\x1f\x05\x99\x95\x8f\xb8\x45\xc9\xe5\xcd\xc0\xcf\xc7\x27\xb0\x0f\x9a\x33\x0b\xa9\x1c\x26\x44\x12\xa9\x21\x4f\xfa\x66\xf4\x78\x90\xcf\xdb\x9c\x6c\x62\xbc\x7d\x5b\x35\xef\xa5\x29\x3c\x1e\xfa\x6b\x98\x0c\x33\xb5\x82\x48\xe3\x2f\x78\x1b\x54\x2a\x3c\x4a\x95\x74\x5c\x16\x14\x85\x89\xfb\x91\x53\x2a\x98\x21\x24\x6d\xd8\x45\x3a\xe6\xda\xfa\x57\xca\xf8\x5f\x9e\x2d\x71\x7d\xbd\x65\xc5\xdd\xc7\xd1\x26\xde\xe1\xb1\x86\x2b\x72\x69\xbe\xd5\x1b\x74\x7b\xf0\xfc\xde\xf5\x24\x93\xd6\xf7\x44\x1b\xb2\x24\x5d\x13\x7f\x27\xcc\xbd\xf8\x58\xcf\x36\x26\x10\x72\xad\x3c\x39\x85\x4c\x81\xbb\x3f\xd0\xed\x2d\x7e\x3f\xf1\x9f\xef\xc3\xe7\xef\x50\x9e\x9b\xc3\x4f\x3d\x30\x99\xf9\x27\xef\x77\x4f\x9a\x18\xe6\x04\xb7\x54\x10\xcc\x3a\x28\xb9\x73\xe7\xeb\x99\x2a\x83\x8c\xb4\x50\xab\x39\xc9\xad\x7e\x7c\x29\xcc\xca\x40\x49\xaf\x73\x64\x70\xab\x49\x0e\x9c\x1f\xea\xd1\xed\xa0\xf7\xa7\x3f\x1f\x23\x81\xcb\x95\x25\x9f\x8d\x54\xae\x72\x67\x0b\xad\x95\x71\xf8\x76\xd9\x83\x50\x2c\x9b\x30\xc1\x64\x4a\xc6\x86\x9c\x3c\x88\xb8\x09\xac\x4d\x73\x0f\x0a\x0f\x06\x97\x1b\x55\xcc\xf2\x90\x66\xf0\x92\xce\x33\xdb\x3a\x6a\x64\x7c\x86\xc4\xa1\x8d\x8f\x71\x7d\x7d\xd3\xbe\xee\x0c\x3a\xfd\xef\x81\x95\xef\x6c\xee\x63\xc4\xf5\xb5\x5d\xa4\x9b\x66\x7d\xfd\xe9\xeb\xb7\xc1\xb0\xd3\x1f\x5f\xde\x5e\xb7\xbb\x37\x9b\xf8\x61\xa7\x2f\xff\x38\x8f\x1b\x15\xe3\xfd\xdf\xce\x69\xbb\xfd\x6b\xfc\xe2\x9d\x4b\x35\xde\x19\x72\x66\xd5\x3a\xfb\x65\xa9\xff\x7f\xa3\x1c\x87\x4a\x3c\x0a\xb8\x87\x71\x7d\xfd\xc6\x8f\xe0\xee\xb7\xd1\x26\xbc\xa8\x40\x0c\x70\x6d\x5b\x47\xf5\x23\x5a\x30\xe1\x2b\x08\x46\x7c\xb4\x89\x8f\x8f\xb7\x06\x41\x77\xe3\xfa\x5f\x63\x24\xf4\x03\x67\x9e\x9d\x71\x7d\x5d\xe3\xba\x24\x04\x12\x49\x38\x7b\x2a\xc5\xd8\xb2\xe9\xae\x4a\x38\x1e\xb5\xe2\xfa\x7a\x7b\x68\x67\x35\x31\xc4\xee\xab\x5f\x41\x82\x4b\x11\xae\xb4\x38\x0a\xda\xf3\x2d\x70\xfc\x70\x19\x86\xb5\xcb\xa7\x58\x12\x66\xe4\xb0\x60\x82\x67\x07\x44\xf4\x3c\xa8\x79\xc2\x2d\xfd\x55\xc5\x6b\x50\xf1\xcc\x85\xdf\x7b\x3e\x61\x43\x41\x1d\x95\x21\x4f\xd8\xad\x0f\x55\x38\x36\x23\x28\x03\xa6\x39\x0a\xc9\x16\x8c\x0b\x36\xe1\x82\xbb\x55\x70\x3e\x70\x4c\xec\xf5\x39\x55\x85\xc8\x40\x3f\xb9\x75\x4f\xd6\x76\xa5\x8a\x5b\xbf\xdc\x22\x23\x41\xa5\x42\x1f\x0a\xe1\xb6\x5b\xbf\x8d\x92\xa7\x12\x57\xc3\xdf\x0a\x2e\x32\x30\x48\x5a\x1e\xdc\x4e\x4a\xb9\x39\x2c\xc9\x53\xfc\x85\x8b\xc4\x94\x0b\x47\x86\x32\xa8\xa2\xa4\xf4\xff\xbc\xa9\xab\xbc\xda\x5a\x53\x50\x80\xf2\x0e\xb7\x0f\xa8\xcc\xee\x2a\xb1\x83\xe1\x5e\x4f\xdf\x6c\x8b\x7d\xa4\xa7\x15\x58\xb5\x6f\xe0\xbe\x1f\xe1\xdb\x66\xb4\x39\x30\xdb\xef\x79\xae\x37\x28\x0d\xf0\x1a\x2b\xfe\xb3\x4b\xc0\x0e\x7c\x7b\xf8\xf9\x61\x0f\x6f\x2f\x6f\xff\x78\x01\x86\xcc\xa9\x39\x4f\x99\x28\x17\x28\x5b\x28\x9e\x81\xc9\x15\xb8\x4c\x95\xb4\xdc\x3a\x92\x7e\xfd\xe5\x6c\xc1\x95\xa9\x7c\xf5\xcb\x1b\xdf\x8b\x73\x9b\xab\x8c\x4f\x39\x65\x58\x90\xb1\x5c\x49\xbf\x3f\x24\x51\x16\xd0\x52\x5e\x3e\x1e\x27\xfd\x5f\xdf\x3c\xaa\xba\xb6\x16\x9e\x02\x9e\x26\x86\xe6\x6a\x41\xd9\xbe\x9a\x80\xb3\x72\x77\x9f\x96\xc0\x0d\x9a\xbf\xbf\x01\x23\x55\x7a\x85\x34\x2f\x8c\x67\x7e\x60\x70\xb9\x75\xdf\x9f\xed\xd7\x6e\x21\xfd\xff\x16\xd5\x48\xa3\x4c\x49\x8a\xfe\x35\x00\x51\x50\x61\x06\x79\x0c\x00\x00")
 
 func assetsNodeResolverUpdateNodeResolverShBytes() ([]byte, error) {
 	return bindataRead(
@@ -315,8 +336,8 @@ func assetsNodeResolverUpdateNodeResolverSh() (*asset, error) {
 		return nil, err
 	}
 
-	info := bindataFileInfo{name: "assets/node-resolver/update-node-resolver.sh", size: 2285, mode: os.FileMode(420), modTime: time.Unix(1, 0)}
-	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x6c, 0x92, 0xb2, 0xf8, 0x92, 0x59, 0xa3, 0xe9, 0x5, 0x26, 0x87, 0xcc, 0x55, 0xc, 0x2a, 0x85, 0x14, 0xd8, 0xc, 0x5, 0x92, 0x5f, 0x18, 0xb1, 0x27, 0x58, 0x79, 0x31, 0x9, 0xe4, 0x3b, 0xe7}}
+	info := bindataFileInfo{name: "assets/node-resolver/update-node-resolver.sh", size: 3193, mode: os.FileMode(420), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xdb, 0xc7, 0x70, 0x51, 0x76, 0x7e, 0x7f, 0x9c, 0x69, 0x4e, 0x13, 0xf5, 0xc0, 0x7e, 0x50, 0xcc, 0xd7, 0x92, 0x9e, 0xbb, 0xcd, 0x9b, 0xcc, 0x87, 0xd7, 0x3, 0x7, 0x15, 0x33, 0x12, 0x73, 0x42}}
 	return a, nil
 }
 
@@ -431,6 +452,8 @@ var _bindata = map[string]func() (*asset, error){
 
 	"assets/dns/service.yaml": assetsDnsServiceYaml,
 
+	"assets/dns/warm-cache.sh": assetsDnsWarmCacheSh,
+
 	"assets/node-resolver/service-account.yaml": assetsNodeResolverServiceAccountYaml,
 
 	"assets/node-resolver/update-node-resolver.sh": assetsNodeResolverUpdateNodeResolverSh,
@@ -440,11 +463,13 @@ var _bindata = map[string]func() (*asset, error){
 // directory embedded in the file by go-bindata.
 // For example if you run go-bindata on data/... and data contains the
 // following hierarchy:
-//     data/
-//       foo.txt
-//       img/
-//         a.png
-//         b.png
+//
+//	data/
+//	  foo.txt
+//	  img/
+//	    a.png
+//	    b.png
+//
 // then AssetDir("data") would return []string{"foo.txt", "img"},
 // AssetDir("data/img") would return []string{"a.png", "b.png"},
 // AssetDir("foo.txt") and AssetDir("notexist") would return an error, and
@@ -491,6 +516,7 @@ var _bintree = &bintree{nil, map[string]*bintree{
 			"namespace.yaml":       {assetsDnsNamespaceYaml, map[string]*bintree{}},
 			"service-account.yaml": {assetsDnsServiceAccountYaml, map[string]*bintree{}},
 			"service.yaml":         {assetsDnsServiceYaml, map[string]*bintree{}},
+			"warm-cache.sh":        {assetsDnsWarmCacheSh, map[string]*bintree{}},
 		}},
 		"node-resolver": {nil, map[string]*bintree{
 			"service-account.yaml":    {assetsNodeResolverServiceAccountYaml, map[string]*bintree{}},
@@ -12,20 +12,22 @@ import (
 )
 
 const (
+	// Names of the manifest assets, for use with Read.
 	DNSNamespaceAsset          = "assets/dns/namespace.yaml"
 	DNSServiceAccountAsset     = "assets/dns/service-account.yaml"
 	DNSClusterRoleAsset        = "assets/dns/cluster-role.yaml"
 	DNSClusterRoleBindingAsset = "assets/dns/cluster-role-binding.yaml"
 	DNSDaemonSetAsset          = "assets/dns/daemonset.yaml"
 	DNSServiceAsset            = "assets/dns/service.yaml"
+	DNSWarmCacheScriptAsset    = "assets/dns/warm-cache.sh"
 
 	MetricsClusterRoleAsset        = "assets/dns/metrics/cluster-role.yaml"
 	MetricsClusterRoleBindingAsset = "assets/dns/metrics/cluster-role-binding.yaml"
 	MetricsRoleAsset               = "assets/dns/metrics/role.yaml"
 	MetricsRoleBindingAsset        = "assets/dns/metrics/role-binding.yaml"
 
-	nodeResolverScriptAsset         = "assets/node-resolver/update-node-resolver.sh"
-	nodeResolverServiceAccountAsset = "assets/node-resolver/service-account.yaml"
+	NodeResolverScriptAsset         = "assets/node-resolver/update-node-resolver.sh"
+	NodeResolverServiceAccountAsset = "assets/node-resolver/service-account.yaml"
 
 	// OwningDNSLabel should be applied to any objects "owned by" a
 	// dns to aid in selection (especially in cases where an ownerref
@@ -33,8 +35,10 @@ const (
 	OwningDNSLabel = "dns.operator.openshift.io/owning-dns"
 )
 
+// MustAssetReader returns a reader of the named asset with the registered
+// overrides applied.  It panics if the asset cannot be read.
 func MustAssetReader(asset string) io.Reader {
-	return bytes.NewReader(MustAsset(asset))
+	return bytes.NewReader(MustRead(asset))
 }
 
 func DNSNamespace() *corev1.Namespace {
@@ -85,6 +89,12 @@ func DNSService() *corev1.Service {
 	return s
 }
 
+// DNSWarmCacheScript returns the script that the dns daemonset's warm-cache
+// sidecar runs to warm CoreDNS's cache before the pod becomes ready.
+func DNSWarmCacheScript() string {
+	return string(MustRead(DNSWarmCacheScriptAsset))
+}
+
 func MetricsClusterRole() *rbacv1.ClusterRole {
 	cr, err := NewClusterRole(MustAssetReader(MetricsClusterRoleAsset))
 	if err != nil {
@@ -118,11 +128,11 @@ func MetricsRoleBinding() *rbacv1.RoleBinding {
 }
 
 func NodeResolverScript() string {
-	return MustAssetString(nodeResolverScriptAsset)
+	return string(MustRead(NodeResolverScriptAsset))
 }
 
 func NodeResolverServiceAccount() *corev1.ServiceAccount {
-	sa, err := NewServiceAccount(MustAssetReader(nodeResolverServiceAccountAsset))
+	sa, err := NewServiceAccount(MustAssetReader(NodeResolverServiceAccountAsset))
 	if err != nil {
 		panic(err)
 	}
@@ -1,6 +1,8 @@
 package manifests
 
 import (
+	"bytes"
+	"errors"
 	"testing"
 )
 
@@ -11,6 +13,7 @@ func TestManifests(t *testing.T) {
 	DNSNamespace()
 	DNSDaemonSet()
 	DNSService()
+	DNSWarmCacheScript()
 
 	MetricsClusterRole()
 	MetricsClusterRoleBinding()
@@ -20,3 +23,53 @@ func TestManifests(t *testing.T) {
 	NodeResolverScript()
 	NodeResolverServiceAccount()
 }
+
+// TestNames verifies that every asset constant names an asset.
+func TestNames(t *testing.T) {
+	names := map[string]bool{}
+	for _, name := range Names() {
+		names[name] = true
+	}
+	for _, name := range []string{
+		DNSNamespaceAsset, DNSServiceAccountAsset, DNSClusterRoleAsset, DNSClusterRoleBindingAsset,
+		DNSDaemonSetAsset, DNSServiceAsset, DNSWarmCacheScriptAsset,
+		MetricsClusterRoleAsset, MetricsClusterRoleBindingAsset, MetricsRoleAsset, MetricsRoleBindingAsset,
+		NodeResolverScriptAsset, NodeResolverServiceAccountAsset,
+	} {
+		if !names[name] {
+			t.Errorf("expected asset %s in %v", name, Names())
+		}
+	}
+}
+
+// TestRegisterOverride verifies that overrides apply in order to the assets
+// that the typed functions read and that a failing override is reported.
+func TestRegisterOverride(t *testing.T) {
+	defer func() { overrides = nil }()
+
+	RegisterOverride(func(name string, content []byte) ([]byte, error) {
+		if name != DNSNamespaceAsset {
+			return content, nil
+		}
+		return bytes.Replace(content, []byte("name: openshift-dns"), []byte("name: hosted-dns"), 1), nil
+	})
+	RegisterOverride(func(name string, content []byte) ([]byte, error) {
+		if name != DNSNamespaceAsset {
+			return content, nil
+		}
+		return bytes.Replace(content, []byte("hosted-dns"), []byte("hosted-dns-1"), 1), nil
+	})
+	if ns := DNSNamespace(); ns.Name != "hosted-dns-1" {
+		t.Errorf("expected overridden namespace name, got %q", ns.Name)
+	}
+	if sa := DNSServiceAccount(); sa.Name != "dns" {
+		t.Errorf("expected unchanged service account name, got %q", sa.Name)
+	}
+
+	RegisterOverride(func(name string, content []byte) ([]byte, error) {
+		return nil, errors.New("boom")
+	})
+	if _, err := Read(DNSServiceAsset); err == nil {
+		t.Error("expected an error from a failing override")
+	}
+}
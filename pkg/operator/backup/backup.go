@@ -0,0 +1,236 @@
+// Package backup exports the effective DNS configuration of a cluster to an
+// archive and imports it on another cluster, for example to restore custom
+// forwarding configuration on a cluster that has been rebuilt.
+//
+// The archive is a gzipped tar file.  It has the DNS resources, which are the
+// source of truth and are restored on import, and the resources that the
+// operator rendered for them, which are included for reference only; the
+// operator renders them again after the DNS resources are restored.
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	operatorcontroller "github.com/openshift/cluster-dns-operator/pkg/operator/controller"
+
+	"github.com/sirupsen/logrus"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// dnsDirectory is the directory in the archive with the DNS
+	// resources.
+	dnsDirectory = "dnses"
+	// renderedDirectory is the directory in the archive with the
+	// resources that the operator rendered.
+	renderedDirectory = "rendered"
+)
+
+// Export writes an archive with all DNS resources and the configmaps,
+// daemonsets, and services that the operator rendered for them to the given
+// writer.  Rendered resources that do not exist are omitted.
+func Export(ctx context.Context, c client.Reader, w io.Writer) error {
+	dnsList := &operatorv1.DNSList{}
+	if err := c.List(ctx, dnsList); err != nil {
+		return fmt.Errorf("failed to list dnses: %w", err)
+	}
+	sort.Slice(dnsList.Items, func(i, j int) bool {
+		return dnsList.Items[i].Name < dnsList.Items[j].Name
+	})
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+	add := func(name string, obj client.Object) error {
+		data, err := json.MarshalIndent(obj, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s: %w", name, err)
+		}
+		header := &tar.Header{
+			Name:    name,
+			Mode:    0644,
+			Size:    int64(len(data)),
+			ModTime: now,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		return nil
+	}
+	addRendered := func(key types.NamespacedName, obj client.Object, gvk schema.GroupVersionKind) error {
+		kind := strings.ToLower(gvk.Kind)
+		if err := c.Get(ctx, key, obj); err != nil {
+			if errors.IsNotFound(err) {
+				return nil
+			}
+			return fmt.Errorf("failed to get %s %s: %w", kind, key, err)
+		}
+		cleanMetadata(obj)
+		obj.GetObjectKind().SetGroupVersionKind(gvk)
+		return add(path.Join(renderedDirectory, key.Namespace, kind, key.Name+".json"), obj)
+	}
+	configMapGVK := corev1.SchemeGroupVersion.WithKind("ConfigMap")
+	daemonSetGVK := appsv1.SchemeGroupVersion.WithKind("DaemonSet")
+	serviceGVK := corev1.SchemeGroupVersion.WithKind("Service")
+
+	for i := range dnsList.Items {
+		dns := &dnsList.Items[i]
+		cleanMetadata(dns)
+		dns.Status = operatorv1.DNSStatus{}
+		dns.SetGroupVersionKind(operatorv1.GroupVersion.WithKind("DNS"))
+		if err := add(path.Join(dnsDirectory, dns.Name+".json"), dns); err != nil {
+			return err
+		}
+		if err := addRendered(operatorcontroller.DNSConfigMapName(dns), &corev1.ConfigMap{}, configMapGVK); err != nil {
+			return err
+		}
+		if err := addRendered(operatorcontroller.DNSDaemonSetName(dns), &appsv1.DaemonSet{}, daemonSetGVK); err != nil {
+			return err
+		}
+		if err := addRendered(operatorcontroller.DNSServiceName(dns), &corev1.Service{}, serviceGVK); err != nil {
+			return err
+		}
+	}
+	if err := addRendered(operatorcontroller.NodeResolverConfigMapName(), &corev1.ConfigMap{}, configMapGVK); err != nil {
+		return err
+	}
+	if err := addRendered(operatorcontroller.NodeResolverDaemonSetName(), &appsv1.DaemonSet{}, daemonSetGVK); err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to close archive: %w", err)
+	}
+	return gz.Close()
+}
+
+// ReadDNSes reads the DNS resources from the archive that the given reader
+// provides.  Rendered resources in the archive are ignored.
+func ReadDNSes(r io.Reader) ([]operatorv1.DNS, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	var dnses []operatorv1.DNS
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive: %w", err)
+		}
+		if path.Dir(header.Name) != dnsDirectory || !strings.HasSuffix(header.Name, ".json") {
+			continue
+		}
+		dns := operatorv1.DNS{}
+		if err := json.NewDecoder(tr).Decode(&dns); err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", header.Name, err)
+		}
+		if len(dns.Name) == 0 {
+			return nil, fmt.Errorf("%s has no name", header.Name)
+		}
+		dnses = append(dnses, dns)
+	}
+	return dnses, nil
+}
+
+// Import restores the DNS resources in the archive that the given reader
+// provides.  A DNS that does not exist is created; a DNS that exists has its
+// spec replaced and the labels and annotations from the archive added.  If
+// dryRun is true, Import only logs the changes that it would make.
+func Import(ctx context.Context, c client.Client, r io.Reader, dryRun bool) error {
+	dnses, err := ReadDNSes(r)
+	if err != nil {
+		return err
+	}
+	if len(dnses) == 0 {
+		return fmt.Errorf("archive has no dnses")
+	}
+	for i := range dnses {
+		desired := &dnses[i]
+		current := &operatorv1.DNS{}
+		if err := c.Get(ctx, types.NamespacedName{Name: desired.Name}, current); err != nil {
+			if !errors.IsNotFound(err) {
+				return fmt.Errorf("failed to get dns %s: %w", desired.Name, err)
+			}
+			if dryRun {
+				logrus.Infof("would create dns %s", desired.Name)
+				continue
+			}
+			if err := c.Create(ctx, desired); err != nil {
+				return fmt.Errorf("failed to create dns %s: %w", desired.Name, err)
+			}
+			logrus.Infof("created dns %s", desired.Name)
+			continue
+		}
+		updated := current.DeepCopy()
+		updated.Spec = desired.Spec
+		updated.Labels = mergeStrings(updated.Labels, desired.Labels)
+		updated.Annotations = mergeStrings(updated.Annotations, desired.Annotations)
+		if dryRun {
+			logrus.Infof("would update dns %s", desired.Name)
+			continue
+		}
+		if err := c.Update(ctx, updated); err != nil {
+			return fmt.Errorf("failed to update dns %s: %w", desired.Name, err)
+		}
+		logrus.Infof("updated dns %s", desired.Name)
+	}
+	return nil
+}
+
+// cleanMetadata clears the metadata fields of the given object that the API
+// server sets and that would prevent the object from being created on
+// another cluster.
+func cleanMetadata(obj client.Object) {
+	obj.SetUID("")
+	obj.SetResourceVersion("")
+	obj.SetGeneration(0)
+	obj.SetSelfLink("")
+	obj.SetCreationTimestamp(metav1.Time{})
+	obj.SetManagedFields(nil)
+	obj.SetOwnerReferences(nil)
+	obj.SetFinalizers(nil)
+}
+
+// mergeStrings returns a copy of the given base map with the entries of the
+// given overlay map added.
+func mergeStrings(base, overlay map[string]string) map[string]string {
+	if len(base) == 0 && len(overlay) == 0 {
+		return base
+	}
+	result := make(map[string]string, len(base)+len(overlay))
+	for k, v := range base {
+		result[k] = v
+	}
+	for k, v := range overlay {
+		result[k] = v
+	}
+	return result
+}
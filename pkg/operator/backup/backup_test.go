@@ -0,0 +1,188 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"reflect"
+	"sort"
+	"testing"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// fakeClient is a client.Client that serves a fixed set of dnses and
+// configmaps and records creates and updates.
+type fakeClient struct {
+	client.Client
+	dnses      []operatorv1.DNS
+	configMaps map[client.ObjectKey]corev1.ConfigMap
+	created    []client.Object
+	updated    []client.Object
+}
+
+func (c *fakeClient) List(_ context.Context, list client.ObjectList, _ ...client.ListOption) error {
+	list.(*operatorv1.DNSList).Items = append([]operatorv1.DNS(nil), c.dnses...)
+	return nil
+}
+
+func (c *fakeClient) Get(_ context.Context, key client.ObjectKey, obj client.Object) error {
+	switch o := obj.(type) {
+	case *operatorv1.DNS:
+		for _, dns := range c.dnses {
+			if dns.Name == key.Name {
+				dns.DeepCopyInto(o)
+				return nil
+			}
+		}
+	case *corev1.ConfigMap:
+		if cm, ok := c.configMaps[key]; ok {
+			cm.DeepCopyInto(o)
+			return nil
+		}
+	}
+	return errors.NewNotFound(schema.GroupResource{}, key.Name)
+}
+
+func (c *fakeClient) Create(_ context.Context, obj client.Object, _ ...client.CreateOption) error {
+	c.created = append(c.created, obj)
+	return nil
+}
+
+func (c *fakeClient) Update(_ context.Context, obj client.Object, _ ...client.UpdateOption) error {
+	c.updated = append(c.updated, obj)
+	return nil
+}
+
+// TestExportImport verifies that Export writes the dnses and their rendered
+// resources without server-set metadata and status, and that Import creates
+// missing dnses and updates existing ones from the archive.
+func TestExportImport(t *testing.T) {
+	servers := []operatorv1.Server{{
+		Name:          "corp",
+		Zones:         []string{"corp.example.com"},
+		ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"10.0.0.53"}},
+	}}
+	source := &fakeClient{
+		dnses: []operatorv1.DNS{{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "default",
+				UID:             "1",
+				ResourceVersion: "42",
+				Finalizers:      []string{"dns.operator.openshift.io/dns-controller"},
+				Annotations:     map[string]string{"dns.operator.openshift.io/prefer-infra-nodes": "true"},
+			},
+			Spec:   operatorv1.DNSSpec{Servers: servers},
+			Status: operatorv1.DNSStatus{ClusterIP: "172.30.0.10"},
+		}},
+		configMaps: map[client.ObjectKey]corev1.ConfigMap{
+			{Namespace: "openshift-dns", Name: "dns-default"}: {
+				ObjectMeta: metav1.ObjectMeta{Namespace: "openshift-dns", Name: "dns-default", UID: "2"},
+				Data:       map[string]string{"Corefile": ".:5353 {\n}\n"},
+			},
+		},
+	}
+	var archive bytes.Buffer
+	if err := Export(context.Background(), source, &archive); err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+
+	expectedNames := []string{"dnses/default.json", "rendered/openshift-dns/configmap/dns-default.json"}
+	if names := archiveNames(t, archive.Bytes()); !reflect.DeepEqual(names, expectedNames) {
+		t.Errorf("expected archive entries %v, got %v", expectedNames, names)
+	}
+
+	dnses, err := ReadDNSes(bytes.NewReader(archive.Bytes()))
+	if err != nil {
+		t.Fatalf("failed to read dnses: %v", err)
+	}
+	if len(dnses) != 1 {
+		t.Fatalf("expected 1 dns, got %d", len(dnses))
+	}
+	dns := dnses[0]
+	if len(dns.UID) != 0 || len(dns.ResourceVersion) != 0 || len(dns.Finalizers) != 0 {
+		t.Errorf("expected server-set metadata to be cleared, got %+v", dns.ObjectMeta)
+	}
+	if len(dns.Status.ClusterIP) != 0 {
+		t.Errorf("expected status to be cleared, got %+v", dns.Status)
+	}
+	if !reflect.DeepEqual(dns.Spec.Servers, servers) {
+		t.Errorf("expected servers %+v, got %+v", servers, dns.Spec.Servers)
+	}
+
+	// Importing into a cluster without the dns creates it.
+	empty := &fakeClient{}
+	if err := Import(context.Background(), empty, bytes.NewReader(archive.Bytes()), false); err != nil {
+		t.Fatalf("failed to import: %v", err)
+	}
+	if len(empty.created) != 1 || len(empty.updated) != 0 {
+		t.Fatalf("expected 1 create and 0 updates, got %d and %d", len(empty.created), len(empty.updated))
+	}
+
+	// Importing into a cluster with a default dns updates it and keeps
+	// its other annotations.
+	rebuilt := &fakeClient{
+		dnses: []operatorv1.DNS{{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "default",
+				ResourceVersion: "7",
+				Annotations:     map[string]string{"foo": "bar"},
+			},
+		}},
+	}
+	if err := Import(context.Background(), rebuilt, bytes.NewReader(archive.Bytes()), true); err != nil {
+		t.Fatalf("failed to import with dry run: %v", err)
+	}
+	if len(rebuilt.created) != 0 || len(rebuilt.updated) != 0 {
+		t.Fatalf("expected no changes with dry run, got %d creates and %d updates", len(rebuilt.created), len(rebuilt.updated))
+	}
+	if err := Import(context.Background(), rebuilt, bytes.NewReader(archive.Bytes()), false); err != nil {
+		t.Fatalf("failed to import: %v", err)
+	}
+	if len(rebuilt.updated) != 1 {
+		t.Fatalf("expected 1 update, got %d", len(rebuilt.updated))
+	}
+	updated := rebuilt.updated[0].(*operatorv1.DNS)
+	if e, a := "7", updated.ResourceVersion; e != a {
+		t.Errorf("expected resource version %q, got %q", e, a)
+	}
+	expectedAnnotations := map[string]string{"foo": "bar", "dns.operator.openshift.io/prefer-infra-nodes": "true"}
+	if !reflect.DeepEqual(updated.Annotations, expectedAnnotations) {
+		t.Errorf("expected annotations %v, got %v", expectedAnnotations, updated.Annotations)
+	}
+	if !reflect.DeepEqual(updated.Spec.Servers, servers) {
+		t.Errorf("expected servers %+v, got %+v", servers, updated.Spec.Servers)
+	}
+}
+
+// archiveNames returns the sorted names of the entries in the given archive.
+func archiveNames(t *testing.T, archive []byte) []string {
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatalf("failed to read archive: %v", err)
+	}
+	tr := tar.NewReader(gz)
+	var names []string
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("failed to read archive: %v", err)
+		}
+		names = append(names, header.Name)
+	}
+	sort.Strings(names)
+	return names
+}
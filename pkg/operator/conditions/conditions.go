@@ -0,0 +1,386 @@
+// Package conditions enumerates the status condition types and reasons that
+// the operator sets on DNS resources and on the dns ClusterOperator, and
+// provides predicates over them.  Alerting pipelines and other operators
+// should use these constants and helpers rather than matching on string
+// literals.
+//
+// The Degraded and Available conditions of a DNS, and the Progressing
+// condition of the ClusterOperator, may combine several reasons into one
+// reason string; use SplitReason or HasReason to inspect such reasons.
+package conditions
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	configv1 "github.com/openshift/api/config/v1"
+	operatorv1 "github.com/openshift/api/operator/v1"
+)
+
+// Types of the conditions that the operator sets on a DNS in addition to
+// Degraded, Progressing, and Available.  Most of them are set only when the
+// feature that they describe is in use; see each condition's documentation in
+// the controller package.
+const (
+	TypeBlocklistsCurrent              = "BlocklistsCurrent"
+	TypeChaosTestMode                  = "ChaosTestMode"
+	TypeCRDSchemaCurrent               = "CRDSchemaCurrent"
+	TypeCustomCoreDNSImageCompatible   = "CustomCoreDNSImageCompatible"
+	TypeCustomCorefileApplied          = "CustomCorefileApplied"
+	TypeDefaultUpstreamsObserved       = "DefaultUpstreamsObserved"
+	TypeDeprecatedFieldsMigrated       = "DeprecatedFieldsMigrated"
+	TypeDNS64Active                    = "DNS64Active"
+	TypeDNSOverHTTPSAvailable          = "DNSOverHTTPSAvailable"
+	TypeDNSOverHTTPSUpstreamsReady     = "DNSOverHTTPSUpstreamsReady"
+	TypeDNSOverQUICUpstreamsSupported  = "DNSOverQUICUpstreamsSupported"
+	TypeDNSOverTLSAvailable            = "DNSOverTLSAvailable"
+	TypeExternalNamePolicyCompliant    = "ExternalNamePolicyCompliant"
+	TypeFleetConfigurationEnforced     = "FleetConfigurationEnforced"
+	TypeForwardingConfigMapApplied     = "ForwardingConfigMapApplied"
+	TypeForwardingLoopFree             = "ForwardingLoopFree"
+	TypeIdMForwardingConfigured        = "IdMForwardingConfigured"
+	TypeKubeletClusterDNSConsistent    = "KubeletClusterDNSConsistent"
+	TypeMetricsCertificateIssued       = "MetricsCertificateIssued"
+	TypeMetricsServingCertificate      = "MetricsServingCertificateAvailable"
+	TypeNodeCoveragePreserved          = "NodeCoveragePreserved"
+	TypeNodeTuningConfigured           = "NodeTuningConfigured"
+	TypeOperandImagesPinned            = "OperandImagesPinned"
+	TypeOperandImagesVerified          = "OperandImagesVerified"
+	TypeOperandsRemoved                = "OperandsRemoved"
+	TypeQueryACLsApplied               = "QueryACLsApplied"
+	TypeResponseRateLimitingConfigured = "ResponseRateLimitingConfigured"
+	TypeRewriteRulesApplied            = "RewriteRulesApplied"
+	TypeRolloutDeferred                = "RolloutDeferred"
+	TypeSecondaryZonesTransferred      = "SecondaryZonesTransferred"
+	TypeServiceUpToDate                = "ServiceUpToDate"
+	TypeStaticHostsApplied             = "StaticHostsApplied"
+	TypeSynthesizedRecordsApplied      = "SynthesizedRecordsApplied"
+	TypeTLSCertificatesExpiring        = "TLSCertificatesExpiring"
+	TypeUpstreamCABundlesValid         = "UpstreamCABundlesValid"
+	TypeUpstreamTemplatesResolved      = "UpstreamTemplatesResolved"
+	TypeUpstreamTLSConfigured          = "UpstreamTLSConfigured"
+	TypeUpstreamTLSReferencesGranted   = "UpstreamTLSReferencesGranted"
+	TypeUpstreamsValid                 = "UpstreamsValid"
+	TypeViewsApplied                   = "ViewsApplied"
+	TypeZoneCapacityAtRisk             = "ZoneCapacityAtRisk"
+	TypeZoneFilesServed                = "ZoneFilesServed"
+	TypeZoneTransfersAllowed           = "ZoneTransfersAllowed"
+)
+
+// ReasonAsExpected is the reason of any condition that reports the healthy
+// state.
+const ReasonAsExpected = "AsExpected"
+
+// Reasons of a DNS's Degraded condition.  When the DNS is degraded for more
+// than one reason, the condition's reason is the concatenation of the reasons.
+const (
+	ReasonNoService                              = "NoService"
+	ReasonNoDNSDaemonSet                         = "NoDNSDaemonSet"
+	ReasonNoDNSPodsDesired                       = "NoDNSPodsDesired"
+	ReasonNoDNSPodsAvailable                     = "NoDNSPodsAvailable"
+	ReasonInvalidDNSMaxUnavailable               = "InvalidDNSMaxUnavailable"
+	ReasonMaxUnavailableDNSPodsExceeded          = "MaxUnavailableDNSPodsExceeded"
+	ReasonDNSPortConflicts                       = "DNSPortConflicts"
+	ReasonNoNodeResolverDaemonSet                = "NoNodeResolverDaemonSet"
+	ReasonNoNodeResolverPodsDesired              = "NoNodeResolverPodsDesired"
+	ReasonNoNodeResolverPodsAvailable            = "NoNodeResolverPodsAvailable"
+	ReasonInvalidNodeResolverMaxUnavailable      = "InvalidNodeResolverMaxUnavailable"
+	ReasonMaxUnavailableNodeResolverPodsExceeded = "MaxUnavailableNodeResolverPodsExceeded"
+
+	// ReasonNoNodes is the reason of a DNS's Degraded=False condition
+	// when the cluster has no nodes, and of the DefaultUpstreamsObserved
+	// condition when no nodes match the DNS's node selector.
+	ReasonNoNodes = "NoNodes"
+	// ReasonWorkersUnschedulable is the reason of a DNS's Degraded=False
+	// condition during a control-plane window.
+	ReasonWorkersUnschedulable = "WorkersUnschedulable"
+)
+
+// ReasonReconciling is the reason of a DNS's Progressing=True condition.
+const ReasonReconciling = "Reconciling"
+
+// Reasons of a DNS's Available=False condition.  When the DNS is unavailable
+// for more than one reason, the condition's reason is the concatenation of the
+// reasons.  ReasonNoService is also an Available reason.
+const (
+	ReasonNoDaemonSet     = "NoDaemonSet"
+	ReasonNoDaemonSetPods = "NoDaemonSetPods"
+)
+
+// Reasons of the Degraded, Progressing, and Available conditions of a DNS
+// whose management state is Removed, and of its OperandsRemoved condition.
+const (
+	ReasonRemoved                = "Removed"
+	ReasonManagementStateRemoved = "ManagementStateRemoved"
+)
+
+// Reasons of the additional DNS conditions.
+const (
+	ReasonNoFaultsInjected = "NoFaultsInjected"
+	ReasonFaultsInjected   = "FaultsInjected"
+
+	ReasonPluginCheckFailed = "PluginCheckFailed"
+	ReasonCheckingPlugins   = "CheckingPlugins"
+	ReasonMissingPlugins    = "MissingPlugins"
+
+	ReasonObservationFailed = "ObservationFailed"
+	ReasonObserving         = "Observing"
+
+	ReasonForwardingLoopPrevented = "ForwardingLoopPrevented"
+
+	ReasonClusterDNSMismatch = "ClusterDNSMismatch"
+
+	ReasonSecretNotFound            = "SecretNotFound"
+	ReasonInvalidSecret             = "InvalidSecret"
+	ReasonSecretProviderUnavailable = "SecretProviderUnavailable"
+
+	ReasonCertManagerUnavailable = "CertManagerUnavailable"
+	ReasonCertificateNotReady    = "CertificateNotReady"
+
+	ReasonNodesLosingDNSPods      = "NodesLosingDNSPods"
+	ReasonNodePlacementChangeHeld = "NodePlacementChangeHeld"
+
+	ReasonNodeTuningUnavailable = "NodeTuningUnavailable"
+
+	ReasonPinningNotRequired      = "PinningNotRequired"
+	ReasonUnpinnedImagesAllowed   = "UnpinnedImagesAllowed"
+	ReasonUnpinnedImagesInUse     = "UnpinnedImagesInUse"
+	ReasonUnpinnedImagesRefused   = "UnpinnedImagesRefused"
+	ReasonImagePullFailed         = "ImagePullFailed"
+	ReasonVerifying               = "Verifying"
+	ReasonRecreationRequired      = "RecreationRequired"
+	ReasonRecreating              = "Recreating"
+	ReasonInvalidUpstreamsOmitted = "InvalidUpstreamsOmitted"
+
+	ReasonTemplateResolutionFailed = "TemplateResolutionFailed"
+
+	ReasonNoIdMResolversFound = "NoIdMResolversFound"
+	ReasonIdMResolversIgnored = "IdMResolversIgnored"
+
+	ReasonLocalEditsRejected   = "LocalEditsRejected"
+	ReasonNoFleetConfiguration = "NoFleetConfiguration"
+
+	ReasonRewriteRulesIgnored       = "RewriteRulesIgnored"
+	ReasonStaticHostsIgnored        = "StaticHostsIgnored"
+	ReasonSynthesizedRecordsIgnored = "SynthesizedRecordsIgnored"
+	ReasonCustomCorefileIgnored     = "CustomCorefileIgnored"
+	ReasonZoneFilesIgnored          = "ZoneFilesIgnored"
+	ReasonSecondaryZonesIgnored     = "SecondaryZonesIgnored"
+	ReasonZoneTransferFailed        = "ZoneTransferFailed"
+	ReasonZoneTransfersIgnored      = "ZoneTransfersIgnored"
+
+	ReasonCRDSchemaOutdated = "CRDSchemaOutdated"
+
+	// ReasonDeprecatedFieldsInUse is also the reason of the dns
+	// ClusterOperator's Upgradeable=False condition.
+	ReasonDeprecatedFieldsMigrated = "DeprecatedFieldsMigrated"
+	ReasonDeprecatedFieldsInUse    = "DeprecatedFieldsInUse"
+
+	ReasonForwarderImageUnavailable    = "ForwarderImageUnavailable"
+	ReasonDNSOverHTTPSUpstreamsOmitted = "DNSOverHTTPSUpstreamsOmitted"
+
+	ReasonCheckingQUICSupport = "CheckingQUICSupport"
+	ReasonQUICCheckFailed     = "QUICCheckFailed"
+	ReasonQUICUnsupported     = "QUICUnsupported"
+
+	ReasonUpstreamTLSIgnored           = "UpstreamTLSIgnored"
+	ReasonUpstreamTLSReferencesIgnored = "UpstreamTLSReferencesIgnored"
+
+	ReasonViewsIgnored = "ViewsIgnored"
+
+	ReasonQueryACLsIgnored = "QueryACLsIgnored"
+
+	ReasonRequiresCustomCoreDNSImage = "RequiresCustomCoreDNSImage"
+
+	ReasonForwardingZonesIgnored = "ForwardingZonesIgnored"
+
+	ReasonBlocklistsIgnored   = "BlocklistsIgnored"
+	ReasonStaleBlocklistFeeds = "StaleBlocklistFeeds"
+
+	ReasonInvalidCABundle      = "InvalidCABundle"
+	ReasonCABundleExpiringSoon = "CABundleExpiringSoon"
+
+	ReasonCertificatesExpiringSoon = "CertificatesExpiringSoon"
+	ReasonCertificateCheckFailed   = "CertificateCheckFailed"
+
+	ReasonOutsideRolloutWindow = "OutsideRolloutWindow"
+	ReasonUrgentRollout        = "UrgentRollout"
+
+	ReasonLastNodesScalingDown        = "LastNodesScalingDown"
+	ReasonLastNodesDeletionCandidates = "LastNodesDeletionCandidates"
+
+	ReasonNoIPv6ClusterNetwork = "NoIPv6ClusterNetwork"
+
+	ReasonExternalNameServicesNotAllowed = "ExternalNameServicesNotAllowed"
+	ReasonExternalNameServicesBlocked    = "ExternalNameServicesBlocked"
+)
+
+// Reasons of the dns ClusterOperator's conditions.  When the ClusterOperator
+// is progressing for more than one reason, the Progressing condition's reason
+// is the reasons joined with "And".
+const (
+	ReasonDNSDoesNotExist                   = "DNSDoesNotExist"
+	ReasonDNSDegraded                       = "DNSDegraded"
+	ReasonDNSNotDegraded                    = "DNSNotDegraded"
+	ReasonDNSUnavailable                    = "DNSUnavailable"
+	ReasonDNSReportsProgressingIsTrue       = "DNSReportsProgressingIsTrue"
+	ReasonDNSReportsProgressingIsUnknown    = "DNSReportsProgressingIsUnknown"
+	ReasonDNSDoesNotReportProgressingStatus = "DNSDoesNotReportProgressingStatus"
+	ReasonUpgradingOperator                 = "UpgradingOperator"
+	ReasonUpgradingCoreDNS                  = "UpgradingCoreDNS"
+	ReasonUpgradingOpenShiftCLI             = "UpgradingOpenShiftCLI"
+	ReasonUpgradingKubeRBACProxy            = "UpgradingKubeRBACProxy"
+)
+
+// ClusterOperatorReasonSeparator separates the reasons in a combined reason of
+// the ClusterOperator's Progressing condition.
+const ClusterOperatorReasonSeparator = "And"
+
+// combinableReasons are the reasons that a DNS's Degraded and Available
+// conditions concatenate, longest first so that a reason that is a prefix of
+// another does not match first.
+var combinableReasons = func() []string {
+	reasons := []string{
+		ReasonNoService,
+		ReasonNoDNSDaemonSet,
+		ReasonNoDNSPodsDesired,
+		ReasonNoDNSPodsAvailable,
+		ReasonInvalidDNSMaxUnavailable,
+		ReasonMaxUnavailableDNSPodsExceeded,
+		ReasonDNSPortConflicts,
+		ReasonNoNodeResolverDaemonSet,
+		ReasonNoNodeResolverPodsDesired,
+		ReasonNoNodeResolverPodsAvailable,
+		ReasonInvalidNodeResolverMaxUnavailable,
+		ReasonMaxUnavailableNodeResolverPodsExceeded,
+		ReasonNoDaemonSet,
+		ReasonNoDaemonSetPods,
+	}
+	sort.Slice(reasons, func(i, j int) bool {
+		return len(reasons[i]) > len(reasons[j])
+	})
+	return reasons
+}()
+
+// SplitReason returns the reasons that the given condition reason combines.
+// A reason that does not combine other reasons is returned as is.
+func SplitReason(reason string) []string {
+	var reasons []string
+	for _, part := range strings.Split(reason, ClusterOperatorReasonSeparator) {
+		if split, ok := splitConcatenatedReason(part); ok {
+			reasons = append(reasons, split...)
+		} else {
+			reasons = append(reasons, part)
+		}
+	}
+	return reasons
+}
+
+// splitConcatenatedReason splits the given concatenation of combinable
+// reasons and returns the reasons and a Boolean value indicating whether the
+// whole string consists of combinable reasons.
+func splitConcatenatedReason(reason string) ([]string, bool) {
+	var reasons []string
+	for len(reason) != 0 {
+		found := false
+		for _, r := range combinableReasons {
+			if strings.HasPrefix(reason, r) {
+				reasons = append(reasons, r)
+				reason = strings.TrimPrefix(reason, r)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, false
+		}
+	}
+	return reasons, len(reasons) != 0
+}
+
+// HasReason returns a Boolean value indicating whether the given condition
+// reason is, or combines, the given reason.
+func HasReason(reason, want string) bool {
+	for _, r := range SplitReason(reason) {
+		if r == want {
+			return true
+		}
+	}
+	return false
+}
+
+// FindCondition returns the condition of the given type among the given DNS
+// conditions, or nil if there is none.
+func FindCondition(conditions []operatorv1.OperatorCondition, conditionType string) *operatorv1.OperatorCondition {
+	for i := range conditions {
+		if conditions[i].Type == conditionType {
+			return &conditions[i]
+		}
+	}
+	return nil
+}
+
+// IsConditionTrue returns a Boolean value indicating whether the given DNS
+// conditions include a condition of the given type with status True.
+func IsConditionTrue(conditions []operatorv1.OperatorCondition, conditionType string) bool {
+	c := FindCondition(conditions, conditionType)
+	return c != nil && c.Status == operatorv1.ConditionTrue
+}
+
+// IsDegraded returns a Boolean value indicating whether the given DNS
+// conditions report that the DNS is degraded.
+func IsDegraded(conditions []operatorv1.OperatorCondition) bool {
+	return IsConditionTrue(conditions, operatorv1.OperatorStatusTypeDegraded)
+}
+
+// IsProgressing returns a Boolean value indicating whether the given DNS
+// conditions report that the DNS is progressing.
+func IsProgressing(conditions []operatorv1.OperatorCondition) bool {
+	return IsConditionTrue(conditions, operatorv1.OperatorStatusTypeProgressing)
+}
+
+// IsAvailable returns a Boolean value indicating whether the given DNS
+// conditions report that the DNS is available.
+func IsAvailable(conditions []operatorv1.OperatorCondition) bool {
+	return IsConditionTrue(conditions, operatorv1.OperatorStatusTypeAvailable)
+}
+
+// RolloutStuck returns a Boolean value indicating whether the given DNS
+// conditions report that the DNS has been progressing without any change in
+// its progress for longer than the given threshold as of the given time.  The
+// Progressing condition's transition time changes whenever its message does,
+// for example when another pod becomes available, so a rollout that is making
+// progress is not reported as stuck.
+func RolloutStuck(conditions []operatorv1.OperatorCondition, now time.Time, threshold time.Duration) bool {
+	c := FindCondition(conditions, operatorv1.OperatorStatusTypeProgressing)
+	if c == nil || c.Status != operatorv1.ConditionTrue {
+		return false
+	}
+	return now.Sub(c.LastTransitionTime.Time) > threshold
+}
+
+// FindClusterOperatorCondition returns the condition of the given type among
+// the given ClusterOperator conditions, or nil if there is none.
+func FindClusterOperatorCondition(conditions []configv1.ClusterOperatorStatusCondition, conditionType configv1.ClusterStatusConditionType) *configv1.ClusterOperatorStatusCondition {
+	for i := range conditions {
+		if conditions[i].Type == conditionType {
+			return &conditions[i]
+		}
+	}
+	return nil
+}
+
+// IsClusterOperatorDegraded returns a Boolean value indicating whether the
+// given ClusterOperator conditions report that the operator is degraded.
+func IsClusterOperatorDegraded(conditions []configv1.ClusterOperatorStatusCondition) bool {
+	c := FindClusterOperatorCondition(conditions, configv1.OperatorDegraded)
+	return c != nil && c.Status == configv1.ConditionTrue
+}
+
+// IsClusterOperatorAvailable returns a Boolean value indicating whether the
+// given ClusterOperator conditions report that the operator is available.
+func IsClusterOperatorAvailable(conditions []configv1.ClusterOperatorStatusCondition) bool {
+	c := FindClusterOperatorCondition(conditions, configv1.OperatorAvailable)
+	return c != nil && c.Status == configv1.ConditionTrue
+}
@@ -0,0 +1,68 @@
+package conditions
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestSplitReason verifies that SplitReason splits concatenated DNS reasons
+// and ClusterOperator reasons joined with "And", and returns other reasons as
+// they are.
+func TestSplitReason(t *testing.T) {
+	testCases := []struct {
+		reason   string
+		expected []string
+	}{
+		{ReasonAsExpected, []string{ReasonAsExpected}},
+		{ReasonNoDaemonSetPods, []string{ReasonNoDaemonSetPods}},
+		{ReasonNoDaemonSetPods + ReasonNoService, []string{ReasonNoDaemonSetPods, ReasonNoService}},
+		{ReasonNoDNSPodsAvailable + ReasonNoNodeResolverDaemonSet, []string{ReasonNoDNSPodsAvailable, ReasonNoNodeResolverDaemonSet}},
+		{ReasonDNSReportsProgressingIsTrue + ClusterOperatorReasonSeparator + ReasonUpgradingCoreDNS, []string{ReasonDNSReportsProgressingIsTrue, ReasonUpgradingCoreDNS}},
+		{"SomethingElse", []string{"SomethingElse"}},
+	}
+	for _, tc := range testCases {
+		if actual := SplitReason(tc.reason); !reflect.DeepEqual(actual, tc.expected) {
+			t.Errorf("%q: expected %v, got %v", tc.reason, tc.expected, actual)
+		}
+	}
+	if !HasReason(ReasonNoDaemonSetPods+ReasonNoService, ReasonNoService) {
+		t.Errorf("expected %q to have reason %q", ReasonNoDaemonSetPods+ReasonNoService, ReasonNoService)
+	}
+	if HasReason(ReasonNoDaemonSetPods, ReasonNoDaemonSet) {
+		t.Errorf("expected %q not to have reason %q", ReasonNoDaemonSetPods, ReasonNoDaemonSet)
+	}
+}
+
+// TestRolloutStuck verifies that RolloutStuck reports a DNS that has been
+// progressing without change for longer than the threshold.
+func TestRolloutStuck(t *testing.T) {
+	now := time.Now()
+	progressing := func(status operatorv1.ConditionStatus, age time.Duration) []operatorv1.OperatorCondition {
+		return []operatorv1.OperatorCondition{{
+			Type:               operatorv1.OperatorStatusTypeProgressing,
+			Status:             status,
+			Reason:             ReasonReconciling,
+			LastTransitionTime: metav1.NewTime(now.Add(-age)),
+		}}
+	}
+	testCases := []struct {
+		description string
+		conditions  []operatorv1.OperatorCondition
+		expected    bool
+	}{
+		{"no conditions", nil, false},
+		{"not progressing", progressing(operatorv1.ConditionFalse, time.Hour), false},
+		{"progressing recently", progressing(operatorv1.ConditionTrue, time.Minute), false},
+		{"progressing for too long", progressing(operatorv1.ConditionTrue, time.Hour), true},
+	}
+	for _, tc := range testCases {
+		if actual := RolloutStuck(tc.conditions, now, 30*time.Minute); actual != tc.expected {
+			t.Errorf("%s: expected %t, got %t", tc.description, tc.expected, actual)
+		}
+	}
+}
@@ -1,5 +1,16 @@
 package config
 
+const (
+	// MetricsAuthenticationKubeRBACProxy indicates that dns metrics are
+	// secured by a kube-rbac-proxy sidecar in each dns pod.
+	MetricsAuthenticationKubeRBACProxy = "KubeRBACProxy"
+
+	// MetricsAuthenticationTokenReview indicates that dns metrics are
+	// secured by a proxy inside the operator that authenticates scrapes
+	// using TokenReview and SubjectAccessReview.
+	MetricsAuthenticationTokenReview = "TokenReview"
+)
+
 // Config is configuration for the operator and should include things like
 // operated images, release version, etc.
 type Config struct {
@@ -18,4 +29,60 @@ type Config struct {
 	// KubeRBACProxyImage is the kube-rbac-proxy image to to use
 	// to secure the metrics endpoint.
 	KubeRBACProxyImage string
+
+	// OperatorImage is the operator's own image, which the dns pods run
+	// in a sidecar that forwards queries to DNS-over-HTTPS upstreams.  If
+	// it is empty, DNS-over-HTTPS upstreams are not supported.
+	OperatorImage string
+
+	// VerifyOperandImages indicates whether the operator should verify
+	// that new operand images can be pulled before rolling them out to
+	// the dns daemonset.
+	VerifyOperandImages bool
+
+	// MetricsAuthentication is the method used to secure dns metrics, either
+	// MetricsAuthenticationKubeRBACProxy or MetricsAuthenticationTokenReview.
+	MetricsAuthentication string
+
+	// ConsoleAPI indicates whether the operator should serve endpoints with
+	// summarized DNS topology and health data for the console plugin.
+	ConsoleAPI bool
+
+	// MetricsSummary indicates whether the operator should scrape the dns
+	// pods' metrics and report a cluster-level summary of them on the
+	// operator's metrics endpoint.  MetricsSummary requires
+	// MetricsAuthenticationTokenReview.
+	MetricsSummary bool
+
+	// DNSConfigAnalyzer indicates whether the operator should inspect the
+	// DNS settings of all pods in the cluster and report pods with
+	// problematic settings on the operator's metrics endpoint.
+	DNSConfigAnalyzer bool
+
+	// DNSHealthEndpoint indicates whether the operator should serve an
+	// unauthenticated endpoint that reports whether the DNS tier is
+	// healthy, for external load balancers' health checks.
+	DNSHealthEndpoint bool
+
+	// ChaosHooks indicates whether the operator should honor the chaos
+	// annotations on DNSes, which inject failures such as deleting dns
+	// pods or blackholing upstream resolvers for resilience testing.  It
+	// must not be enabled in production clusters.
+	ChaosHooks bool
+
+	// DryRun indicates whether the operator should run in dry-run mode, in
+	// which the API server validates the operator's writes without
+	// persisting them and the operator logs the change that each write
+	// would have made.
+	DryRun bool
+
+	// DNSControllerMaxConcurrentReconciles is the number of reconcile
+	// requests that the dns controller, which also manages the node
+	// resolver daemonset, processes concurrently.  Zero means one.
+	DNSControllerMaxConcurrentReconciles int
+
+	// StatusControllerMaxConcurrentReconciles is the number of reconcile
+	// requests that the status controller processes concurrently.  Zero
+	// means one.
+	StatusControllerMaxConcurrentReconciles int
 }
@@ -0,0 +1,362 @@
+// Package consoleapi implements HTTP endpoints in the operator that serve
+// summarized DNS topology and health data for an OpenShift console dynamic
+// plugin.  The endpoints let a console UI show forwarding zones and DNS pod
+// coverage without reading the underlying resources itself.
+//
+// Requests are authenticated with a bearer token and authorized with a
+// SubjectAccessReview: a user may use the endpoints if the user may get the
+// default DNS resource.
+package consoleapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	configv1 "github.com/openshift/api/config/v1"
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	operatorcontroller "github.com/openshift/cluster-dns-operator/pkg/operator/controller"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/httpauth"
+
+	"github.com/sirupsen/logrus"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// APIVersion is the version of the schema of the topology and
+	// insights responses.
+	APIVersion = "dns.operator.openshift.io/v1alpha1"
+	// TopologyKind is the kind of the topology response.
+	TopologyKind = "DNSTopology"
+	// ConfigDiffKind is the kind of the configuration diff response.
+	ConfigDiffKind = "DNSConfigDiff"
+
+	// InsightsPath is the path of the endpoint that serves the structured
+	// DNS insights that the operator publishes in the ClusterOperator's
+	// status extension.
+	InsightsPath = "/api/v1alpha1/insights"
+	// TopologyPath is the path of the endpoint that serves the DNS
+	// topology.
+	TopologyPath = "/api/v1alpha1/topology"
+	// DiffPath is the path of the endpoint that serves the differences
+	// between the operand objects that the operator rendered and the
+	// objects that exist in the cluster.
+	DiffPath = "/api/v1alpha1/diff"
+)
+
+// Topology describes the forwarding zones of the default DNS and the nodes
+// on which its pods run.
+type Topology struct {
+	// APIVersion is the version of the schema, APIVersion.
+	APIVersion string `json:"apiVersion"`
+	// Kind is the kind of the schema, TopologyKind.
+	Kind string `json:"kind"`
+
+	// Zones describes each forwarding zone server.
+	Zones []TopologyZone `json:"zones,omitempty"`
+	// Nodes describes each node in the cluster and the DNS pod on it.
+	Nodes []TopologyNode `json:"nodes,omitempty"`
+}
+
+// TopologyZone describes a forwarding zone server.
+type TopologyZone struct {
+	// Server is the name of the server in the DNS spec.
+	Server string `json:"server"`
+	// Zones are the zones that the server forwards.
+	Zones []string `json:"zones"`
+	// Upstreams are the resolvers to which the server forwards queries.
+	Upstreams []string `json:"upstreams"`
+}
+
+// TopologyNode describes a node and the DNS pod on it.
+type TopologyNode struct {
+	// Name is the name of the node.
+	Name string `json:"name"`
+	// Pod is the name of the DNS pod on the node, or empty if the node
+	// has no DNS pod.
+	Pod string `json:"pod,omitempty"`
+	// Ready indicates whether the DNS pod on the node is ready.
+	Ready bool `json:"ready"`
+}
+
+// ConfigDiff describes the differences between the operand objects that the
+// operator rendered during its most recent reconciliation and the objects that
+// exist in the cluster.
+type ConfigDiff struct {
+	// APIVersion is the version of the schema, APIVersion.
+	APIVersion string `json:"apiVersion"`
+	// Kind is the kind of the schema, ConfigDiffKind.
+	Kind string `json:"kind"`
+
+	// InSync indicates whether every rendered object exists and has the
+	// rendered fields, meaning that the operator has finished applying
+	// the configuration.
+	InSync bool `json:"inSync"`
+	// Objects describes each rendered object.
+	Objects []operatorcontroller.ObjectDiff `json:"objects,omitempty"`
+}
+
+// Server is an http.Handler that serves the console plugin endpoints.
+type Server struct {
+	// client is used to create TokenReviews and SubjectAccessReviews and
+	// to get the ClusterOperator and the DNS.  The DNS is cluster-scoped,
+	// and the operator's cache only serves the namespaces that it watches.
+	client client.Client
+	// cache is used to get the DNS's pods and nodes.
+	cache client.Reader
+	// desiredState has the operand objects that the operator rendered.
+	desiredState *operatorcontroller.DesiredState
+
+	listenAddress string
+	certFile      string
+	keyFile       string
+
+	// authenticate returns the user that the given bearer token
+	// identifies, or nil if the token is not valid.
+	authenticate func(ctx context.Context, token string) (*authenticationv1.UserInfo, error)
+	// authorize returns a Boolean value indicating whether the given
+	// user may use the endpoints.
+	authorize func(ctx context.Context, user *authenticationv1.UserInfo) (bool, error)
+
+	mux *http.ServeMux
+}
+
+// New returns a console plugin endpoint server that listens on the given
+// address and serves TLS using the given certificate and key files.  The diff
+// endpoint compares the objects in the given DesiredState with the objects in
+// the cluster.
+func New(client client.Client, cache client.Reader, desiredState *operatorcontroller.DesiredState, listenAddress, certFile, keyFile string) *Server {
+	s := &Server{
+		client:        client,
+		cache:         cache,
+		desiredState:  desiredState,
+		listenAddress: listenAddress,
+		certFile:      certFile,
+		keyFile:       keyFile,
+		mux:           http.NewServeMux(),
+	}
+	s.authenticate = s.tokenReview
+	s.authorize = s.subjectAccessReview
+	s.mux.HandleFunc(InsightsPath, s.serveInsights)
+	s.mux.HandleFunc(TopologyPath, s.serveTopology)
+	s.mux.HandleFunc(DiffPath, s.serveDiff)
+	return s
+}
+
+// Start runs the server until the given context is done.  Start implements
+// manager.Runnable.
+func (s *Server) Start(ctx context.Context) error {
+	return httpauth.ServeTLS(ctx, "console api", s.listenAddress, s.certFile, s.keyFile, s)
+}
+
+// ServeHTTP authenticates and authorizes the request and then dispatches it
+// to the endpoint for the request's path.
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ctx := req.Context()
+	token := httpauth.BearerToken(req)
+	if len(token) == 0 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	user, err := s.authenticate(ctx, token)
+	if err != nil {
+		logrus.Errorf("console api failed to authenticate request: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	allowed, err := s.authorize(ctx, user)
+	if err != nil {
+		logrus.Errorf("console api failed to authorize request for user %s: %v", user.Username, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if !allowed {
+		http.Error(w, fmt.Sprintf("Forbidden (user=%s, verb=get, resource=dnses, name=%s)", user.Username, operatorcontroller.DefaultDNSName), http.StatusForbidden)
+		return
+	}
+	s.mux.ServeHTTP(w, req)
+}
+
+// serveInsights serves the structured DNS insights from the ClusterOperator's
+// status extension.
+func (s *Server) serveInsights(w http.ResponseWriter, req *http.Request) {
+	co := &configv1.ClusterOperator{}
+	if err := s.client.Get(req.Context(), operatorcontroller.DNSClusterOperatorName(), co); err != nil {
+		if errors.IsNotFound(err) {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		logrus.Errorf("console api failed to get clusteroperator: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if len(co.Status.Extension.Raw) == 0 {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(co.Status.Extension.Raw); err != nil {
+		logrus.Warningf("console api failed to write insights: %v", err)
+	}
+}
+
+// serveTopology serves the topology of the default DNS.
+func (s *Server) serveTopology(w http.ResponseWriter, req *http.Request) {
+	topology, found, err := s.getTopology(req.Context())
+	if err != nil {
+		logrus.Errorf("console api failed to get dns topology: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(topology); err != nil {
+		logrus.Warningf("console api failed to write topology: %v", err)
+	}
+}
+
+// serveDiff serves the differences between the rendered operand objects and
+// the objects in the cluster.  The objects in the cluster are read with the
+// client rather than the cache so that the response reflects the latest
+// updates.
+func (s *Server) serveDiff(w http.ResponseWriter, req *http.Request) {
+	objects, err := s.desiredState.Diff(req.Context(), s.client)
+	if err != nil {
+		logrus.Errorf("console api failed to compute dns config diff: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	diff := ConfigDiff{
+		APIVersion: APIVersion,
+		Kind:       ConfigDiffKind,
+		InSync:     true,
+		Objects:    objects,
+	}
+	for _, object := range objects {
+		if object.State != operatorcontroller.ObjectInSync {
+			diff.InSync = false
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(diff); err != nil {
+		logrus.Warningf("console api failed to write dns config diff: %v", err)
+	}
+}
+
+// getTopology gets the default DNS, its pods, and nodes, and returns the
+// resulting topology and a Boolean value indicating whether the DNS exists.
+func (s *Server) getTopology(ctx context.Context) (Topology, bool, error) {
+	dns := &operatorv1.DNS{}
+	if err := s.client.Get(ctx, operatorcontroller.DefaultDNSNamespaceName(), dns); err != nil {
+		if errors.IsNotFound(err) {
+			return Topology{}, false, nil
+		}
+		return Topology{}, false, fmt.Errorf("failed to get dns: %w", err)
+	}
+	selector, err := metav1.LabelSelectorAsSelector(operatorcontroller.DNSDaemonSetPodSelector(dns))
+	if err != nil {
+		return Topology{}, false, err
+	}
+	podList := &corev1.PodList{}
+	listOpts := []client.ListOption{
+		client.MatchingLabelsSelector{Selector: selector},
+		client.InNamespace(operatorcontroller.DefaultOperandNamespace),
+	}
+	if err := s.cache.List(ctx, podList, listOpts...); err != nil {
+		return Topology{}, false, fmt.Errorf("failed to list dns pods: %w", err)
+	}
+	nodes, err := operatorcontroller.ListNodes(ctx, s.cache)
+	if err != nil {
+		return Topology{}, false, fmt.Errorf("failed to list nodes: %w", err)
+	}
+	return computeTopology(dns, podList.Items, nodes), true, nil
+}
+
+// computeTopology returns the topology for the given DNS, its pods, and the
+// cluster's nodes.
+func computeTopology(dns *operatorv1.DNS, pods []corev1.Pod, nodes []corev1.Node) Topology {
+	topology := Topology{
+		APIVersion: APIVersion,
+		Kind:       TopologyKind,
+	}
+	for _, server := range dns.Spec.Servers {
+		topology.Zones = append(topology.Zones, TopologyZone{
+			Server:    server.Name,
+			Zones:     append([]string{}, server.Zones...),
+			Upstreams: append([]string{}, server.ForwardPlugin.Upstreams...),
+		})
+	}
+	sort.Slice(topology.Zones, func(i, j int) bool {
+		return topology.Zones[i].Server < topology.Zones[j].Server
+	})
+
+	podsByNode := map[string]corev1.Pod{}
+	for _, pod := range pods {
+		if len(pod.Spec.NodeName) != 0 {
+			podsByNode[pod.Spec.NodeName] = pod
+		}
+	}
+	for _, node := range nodes {
+		entry := TopologyNode{Name: node.Name}
+		if pod, ok := podsByNode[node.Name]; ok {
+			entry.Pod = pod.Name
+			entry.Ready = podReady(&pod)
+		}
+		topology.Nodes = append(topology.Nodes, entry)
+	}
+	sort.Slice(topology.Nodes, func(i, j int) bool {
+		return topology.Nodes[i].Name < topology.Nodes[j].Name
+	})
+
+	return topology
+}
+
+// podReady returns a Boolean value indicating whether the given pod is ready.
+func podReady(pod *corev1.Pod) bool {
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// tokenReview authenticates the given bearer token using a TokenReview.
+func (s *Server) tokenReview(ctx context.Context, token string) (*authenticationv1.UserInfo, error) {
+	return httpauth.TokenReview(ctx, s.client, token)
+}
+
+// subjectAccessReview authorizes the given user using a SubjectAccessReview
+// for getting the default DNS.
+func (s *Server) subjectAccessReview(ctx context.Context, user *authenticationv1.UserInfo) (bool, error) {
+	return httpauth.SubjectAccessReview(ctx, s.client, user, authorizationv1.SubjectAccessReviewSpec{
+		ResourceAttributes: &authorizationv1.ResourceAttributes{
+			Group:    operatorv1.GroupName,
+			Resource: "dnses",
+			Name:     operatorcontroller.DefaultDNSName,
+			Verb:     "get",
+		},
+	})
+}
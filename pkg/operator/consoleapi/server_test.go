@@ -0,0 +1,191 @@
+package consoleapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	operatorcontroller "github.com/openshift/cluster-dns-operator/pkg/operator/controller"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/client-go/rest"
+
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// fakeClient is a client.Client that returns a fixed dns.
+type fakeClient struct {
+	client.Client
+	dns *operatorv1.DNS
+}
+
+func (c *fakeClient) Get(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+	c.dns.DeepCopyInto(obj.(*operatorv1.DNS))
+	return nil
+}
+
+// fakeCache is a client.Reader that gets objects from a multi-namespace cache,
+// like the operator's, and lists fixed pods and nodes.  Like the operator's
+// cache, it lists each node once per namespace.
+type fakeCache struct {
+	cache.Cache
+	namespaces int
+	pods       []corev1.Pod
+	nodes      []corev1.Node
+}
+
+func (c *fakeCache) List(_ context.Context, list client.ObjectList, _ ...client.ListOption) error {
+	switch l := list.(type) {
+	case *corev1.PodList:
+		l.Items = c.pods
+	case *corev1.NodeList:
+		l.Items = nil
+		for i := 0; i < c.namespaces; i++ {
+			l.Items = append(l.Items, c.nodes...)
+		}
+	}
+	return nil
+}
+
+// newFakeCache returns a fakeCache for the operator's and operand namespaces
+// with the given pods and nodes.
+func newFakeCache(t *testing.T, pods []corev1.Pod, nodes []corev1.Node) *fakeCache {
+	namespaces := []string{"openshift-dns-operator", operatorcontroller.DefaultOperandNamespace}
+	c, err := cache.MultiNamespacedCacheBuilder(namespaces)(&rest.Config{Host: "https://127.0.0.1:6443"}, cache.Options{
+		Mapper: meta.NewDefaultRESTMapper(nil),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &fakeCache{Cache: c, namespaces: len(namespaces), pods: pods, nodes: nodes}
+}
+
+func readyPod(name, node string, ready bool) corev1.Pod {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	return corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       corev1.PodSpec{NodeName: node},
+		Status: corev1.PodStatus{
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: status}},
+		},
+	}
+}
+
+func node(name string) corev1.Node {
+	return corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}}
+}
+
+// TestServeTopology verifies that the topology endpoint requires an
+// authorized user and reports zones and per-node pod coverage.
+func TestServeTopology(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{Name: "default"},
+		Spec: operatorv1.DNSSpec{
+			Servers: []operatorv1.Server{{
+				Name:          "foo",
+				Zones:         []string{"foo.com"},
+				ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"1.1.1.1"}},
+			}},
+		},
+	}
+	pods := []corev1.Pod{readyPod("dns-default-a", "node-a", true), readyPod("dns-default-b", "node-b", false)}
+	nodes := []corev1.Node{node("node-c"), node("node-b"), node("node-a")}
+	// The DNS is cluster-scoped, so it must be read through the client;
+	// the multi-namespace cache cannot get it.
+	s := New(&fakeClient{dns: dns}, newFakeCache(t, pods, nodes), nil, "", "", "")
+	s.authenticate = func(_ context.Context, token string) (*authenticationv1.UserInfo, error) {
+		switch token {
+		case "allowed", "forbidden":
+			return &authenticationv1.UserInfo{Username: token}, nil
+		}
+		return nil, nil
+	}
+	s.authorize = func(_ context.Context, user *authenticationv1.UserInfo) (bool, error) {
+		return user.Username == "allowed", nil
+	}
+
+	testCases := []struct {
+		description  string
+		method       string
+		token        string
+		expectStatus int
+	}{
+		{
+			description:  "no token",
+			method:       http.MethodGet,
+			expectStatus: http.StatusUnauthorized,
+		},
+		{
+			description:  "invalid token",
+			method:       http.MethodGet,
+			token:        "invalid",
+			expectStatus: http.StatusUnauthorized,
+		},
+		{
+			description:  "unauthorized user",
+			method:       http.MethodGet,
+			token:        "forbidden",
+			expectStatus: http.StatusForbidden,
+		},
+		{
+			description:  "unsupported method",
+			method:       http.MethodPost,
+			token:        "allowed",
+			expectStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			description:  "authorized user",
+			method:       http.MethodGet,
+			token:        "allowed",
+			expectStatus: http.StatusOK,
+		},
+	}
+	for _, tc := range testCases {
+		req := httptest.NewRequest(tc.method, TopologyPath, nil)
+		if len(tc.token) != 0 {
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		if w.Code != tc.expectStatus {
+			t.Errorf("%s: expected status %d, got %d: %s", tc.description, tc.expectStatus, w.Code, w.Body.String())
+			continue
+		}
+		if w.Code != http.StatusOK {
+			continue
+		}
+		var actual Topology
+		if err := json.Unmarshal(w.Body.Bytes(), &actual); err != nil {
+			t.Fatalf("%s: failed to decode response: %v", tc.description, err)
+		}
+		expected := Topology{
+			APIVersion: APIVersion,
+			Kind:       TopologyKind,
+			Zones: []TopologyZone{
+				{Server: "foo", Zones: []string{"foo.com"}, Upstreams: []string{"1.1.1.1"}},
+			},
+			Nodes: []TopologyNode{
+				{Name: "node-a", Pod: "dns-default-a", Ready: true},
+				{Name: "node-b", Pod: "dns-default-b", Ready: false},
+				{Name: "node-c"},
+			},
+		}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("%s: expected %#v, got %#v", tc.description, expected, actual)
+		}
+	}
+}
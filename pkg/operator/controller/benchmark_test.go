@@ -0,0 +1,144 @@
+package controller
+
+import (
+	"fmt"
+	"testing"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// The benchmarks in this file cover the operator's rendering and status
+// computation paths at sizes that are larger than typical clusters so that
+// regressions in CPU time and allocations show up before they affect large
+// clusters.  Run them with "make bench".
+
+const (
+	// benchmarkServers is the number of forwarding zone servers that the
+	// benchmarks configure.
+	benchmarkServers = 50
+	// benchmarkNodes is the number of nodes that the benchmarks simulate.
+	benchmarkNodes = 5000
+	// benchmarkCacheNamespaces is the number of namespaces that the
+	// operator's cache covers, and thus the number of times that the
+	// cache returns each cluster-scoped object.
+	benchmarkCacheNamespaces = 4
+)
+
+// benchmarkDNS returns a dns with benchmarkServers forwarding zone servers.
+func benchmarkDNS() *operatorv1.DNS {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DefaultDNSController,
+		},
+	}
+	for i := 0; i < benchmarkServers; i++ {
+		dns.Spec.Servers = append(dns.Spec.Servers, operatorv1.Server{
+			Name:  fmt.Sprintf("server-%d", i),
+			Zones: []string{fmt.Sprintf("zone-%d.example.com", i), fmt.Sprintf("zone-%d.example.org", i)},
+			ForwardPlugin: operatorv1.ForwardPlugin{
+				Upstreams: []string{fmt.Sprintf("10.0.%d.1", i), fmt.Sprintf("10.0.%d.2:5353", i), "172.30.0.10"},
+			},
+		})
+	}
+	return dns
+}
+
+// benchmarkNodeList returns benchmarkNodes nodes, each repeated once per
+// namespace that the operator's cache covers.  Every tenth node is a
+// control-plane node, and every other worker node is cordoned, as might be
+// the case during a rolling replacement of the workers.
+func benchmarkNodeList() []corev1.Node {
+	var nodes []corev1.Node
+	for i := 0; i < benchmarkNodes; i++ {
+		node := corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   fmt.Sprintf("node-%d", i),
+				Labels: map[string]string{},
+			},
+		}
+		if i%10 == 0 {
+			node.Labels[controlPlaneNodeRoleLabel] = ""
+		} else {
+			node.Labels[workerNodeRoleLabel] = ""
+			node.Spec.Unschedulable = i%2 == 0
+		}
+		nodes = append(nodes, node)
+	}
+	var all []corev1.Node
+	for i := 0; i < benchmarkCacheNamespaces; i++ {
+		all = append(all, nodes...)
+	}
+	return all
+}
+
+func BenchmarkLoopFreeServers(b *testing.B) {
+	dns := benchmarkDNS()
+	dnsAddresses := sets.NewString("172.30.0.10")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		loopFreeServers(dns.Spec.Servers, "cluster.local", dnsAddresses)
+	}
+}
+
+func BenchmarkDesiredDNSConfigMap(b *testing.B) {
+	dns := benchmarkDNS()
+	servers, _ := loopFreeServers(dns.Spec.Servers, "cluster.local", sets.NewString("172.30.0.10"))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := desiredDNSConfigMap(dns, corefileInputs{Servers: servers, ClusterDomain: "cluster.local", MetricsAddress: "127.0.0.1:9153"}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDesiredDNSDaemonSet(b *testing.B) {
+	dns := benchmarkDNS()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := desiredDNSDaemonSet(dns, "quay.io/openshift/coredns:test", "quay.io/openshift/origin-kube-rbac-proxy:test", "", DNSMetricsSecretName(dns), "", nil, nil, nil, nil, ""); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkComputeNodeTopology(b *testing.B) {
+	nodes := benchmarkNodeList()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		computeNodeTopology(distinctNodes(nodes))
+	}
+}
+
+func BenchmarkComputeDNSStatusConditions(b *testing.B) {
+	dns := benchmarkDNS()
+	topology := computeNodeTopology(distinctNodes(benchmarkNodeList()))
+	maxUnavailable := intstr.FromString("10%")
+	daemonset := &appsv1.DaemonSet{
+		Spec: appsv1.DaemonSetSpec{
+			UpdateStrategy: appsv1.DaemonSetUpdateStrategy{
+				Type:          appsv1.RollingUpdateDaemonSetStrategyType,
+				RollingUpdate: &appsv1.RollingUpdateDaemonSet{MaxUnavailable: &maxUnavailable},
+			},
+		},
+		Status: appsv1.DaemonSetStatus{
+			DesiredNumberScheduled: benchmarkNodes,
+			NumberAvailable:        benchmarkNodes - 100,
+		},
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		computeDNSStatusConditions(dns, "172.30.0.10", topology, true, daemonset, nil, true, daemonset)
+	}
+}
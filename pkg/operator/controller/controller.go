@@ -4,18 +4,23 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"reflect"
+	"time"
 
 	configv1 "github.com/openshift/api/config/v1"
 	operatorv1 "github.com/openshift/api/operator/v1"
 
 	"github.com/openshift/cluster-dns-operator/pkg/manifests"
 	operatorconfig "github.com/openshift/cluster-dns-operator/pkg/operator/config"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/statusbreaker"
+	"github.com/openshift/cluster-dns-operator/pkg/util/parallel"
 	"github.com/openshift/cluster-dns-operator/pkg/util/slice"
 
 	"github.com/sirupsen/logrus"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 
 	"github.com/apparentlymart/go-cidr/cidr"
 
@@ -27,8 +32,11 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 )
@@ -48,18 +56,43 @@ const (
 // controller that handles all the logic for implementing dns based on
 // DNS resources.
 //
-// The controller will be pre-configured to watch for DNS resources.
-func New(mgr manager.Manager, config operatorconfig.Config) (controller.Controller, error) {
+// The controller will be pre-configured to watch for DNS resources.  The
+// controller records the operand objects that it renders in the given
+// DesiredState, which may be nil.
+func New(mgr manager.Manager, config operatorconfig.Config, desiredState *DesiredState) (controller.Controller, error) {
+	// The operator may read secrets only in the operand namespace, so
+	// secrets are watched through a cache for that namespace rather than
+	// through the manager's multi-namespace cache.
+	secretCache, err := cache.New(mgr.GetConfig(), cache.Options{
+		Scheme:    mgr.GetScheme(),
+		Mapper:    mgr.GetRESTMapper(),
+		Namespace: DefaultOperandNamespace,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := mgr.Add(secretCache); err != nil {
+		return nil, err
+	}
 	reconciler := &reconciler{
-		Config: config,
-		client: mgr.GetClient(),
-		cache:  mgr.GetCache(),
+		Config:          config,
+		client:          mgr.GetClient(),
+		cache:           mgr.GetCache(),
+		secretCache:     secretCache,
+		desiredState:    desiredState,
+		tlsCertificates: newTLSCertificateExpiries(),
+	}
+	if err := metrics.Registry.Register(reconciler.tlsCertificates); err != nil {
+		return nil, fmt.Errorf("failed to register TLS certificate expiry metrics: %w", err)
 	}
-	c, err := controller.New(controllerName, mgr, controller.Options{Reconciler: reconciler})
+	c, err := controller.New(controllerName, mgr, controller.Options{
+		Reconciler:              reconciler,
+		MaxConcurrentReconciles: config.DNSControllerMaxConcurrentReconciles,
+	})
 	if err != nil {
 		return nil, err
 	}
-	if err := c.Watch(&source.Kind{Type: &operatorv1.DNS{}}, &handler.EnqueueRequestForObject{}); err != nil {
+	if err := c.Watch(&source.Kind{Type: &operatorv1.DNS{}}, &handler.EnqueueRequestForObject{}, dnsChangedPredicate); err != nil {
 		return nil, err
 	}
 	if err := c.Watch(&source.Kind{Type: &appsv1.DaemonSet{}}, &handler.EnqueueRequestForOwner{OwnerType: &operatorv1.DNS{}}); err != nil {
@@ -71,6 +104,93 @@ func New(mgr manager.Manager, config operatorconfig.Config) (controller.Controll
 	if err := c.Watch(&source.Kind{Type: &corev1.ConfigMap{}}, &handler.EnqueueRequestForOwner{OwnerType: &operatorv1.DNS{}}); err != nil {
 		return nil, err
 	}
+	if err := c.Watch(&source.Kind{Type: &networkingv1.NetworkPolicy{}}, &handler.EnqueueRequestForOwner{OwnerType: &operatorv1.DNS{}}); err != nil {
+		return nil, err
+	}
+	// The cluster administrator creates the rewrite rules, static hosts,
+	// synthesized records, and custom Corefile configmaps, so the dns does
+	// not own them.
+	if err := c.Watch(&source.Kind{Type: &corev1.ConfigMap{}}, handler.EnqueueRequestsFromMapFunc(func(o client.Object) []reconcile.Request {
+		return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: dnsForAdministratorConfigMap(o.GetNamespace(), o.GetName())}}}
+	}), predicate.NewPredicateFuncs(func(o client.Object) bool {
+		return len(dnsForAdministratorConfigMap(o.GetNamespace(), o.GetName())) != 0
+	})); err != nil {
+		return nil, err
+	}
+	// The cluster administrator creates the zone file configmaps and
+	// names them in the dns's ZoneFilesAnnotation annotation.
+	if err := c.Watch(&source.Kind{Type: &corev1.ConfigMap{}}, handler.EnqueueRequestsFromMapFunc(reconciler.dnsesForZoneFileConfigMap), predicate.NewPredicateFuncs(func(o client.Object) bool {
+		return o.GetNamespace() == DefaultOperandNamespace
+	})); err != nil {
+		return nil, err
+	}
+	// The cluster administrator creates the forwarding configmaps and
+	// names them in the dns's ForwardingConfigMapAnnotation annotation.
+	if err := c.Watch(&source.Kind{Type: &corev1.ConfigMap{}}, handler.EnqueueRequestsFromMapFunc(reconciler.dnsesForForwardingConfigMap), predicate.NewPredicateFuncs(func(o client.Object) bool {
+		return o.GetNamespace() == DefaultOperandNamespace
+	})); err != nil {
+		return nil, err
+	}
+	// The cluster administrator creates the blocklist configmaps and
+	// names them in the dns's BlocklistsAnnotation annotation.
+	if err := c.Watch(&source.Kind{Type: &corev1.ConfigMap{}}, handler.EnqueueRequestsFromMapFunc(reconciler.dnsesForBlocklistConfigMap), predicate.NewPredicateFuncs(func(o client.Object) bool {
+		return o.GetNamespace() == DefaultOperandNamespace
+	})); err != nil {
+		return nil, err
+	}
+	// The cluster administrator creates the CA bundle configmaps of the
+	// DNS-over-TLS upstreams, and rotates the bundles in them, and names
+	// them in the dns's UpstreamTLSAnnotation annotation.
+	if err := c.Watch(&source.Kind{Type: &corev1.ConfigMap{}}, handler.EnqueueRequestsFromMapFunc(reconciler.dnsesForUpstreamCABundleConfigMap), predicate.NewPredicateFuncs(func(o client.Object) bool {
+		return o.GetNamespace() == DefaultOperandNamespace
+	})); err != nil {
+		return nil, err
+	}
+	// The node resolver configmap has the cluster IPs of the node
+	// resolver services.
+	if err := c.Watch(&source.Kind{Type: &corev1.Service{}}, handler.EnqueueRequestsFromMapFunc(func(o client.Object) []reconcile.Request {
+		return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: DefaultDNSController}}}
+	}), predicate.NewPredicateFuncs(func(o client.Object) bool {
+		return isNodeResolverService(o.GetNamespace(), o.GetName())
+	})); err != nil {
+		return nil, err
+	}
+	// A custom metrics serving certificate secret, an upstream client
+	// certificate secret, or an IdM DNS server's CA certificate secret may
+	// be created or updated after the dns references it.
+	if err := c.Watch(source.NewKindWithCache(&corev1.Secret{}, secretCache), handler.EnqueueRequestsFromMapFunc(func(o client.Object) []reconcile.Request {
+		return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: DefaultDNSController}}}
+	}), predicate.NewPredicateFuncs(func(o client.Object) bool {
+		secret, ok := o.(*corev1.Secret)
+		return ok && secret.Namespace == DefaultOperandNamespace && secret.Type != corev1.SecretTypeServiceAccountToken
+	})); err != nil {
+		return nil, err
+	}
+	// The dns status depends on the cluster's node topology, which
+	// changes when nodes are added, removed, relabeled, or cordoned, and
+	// on the cluster autoscaler's scale-down taints.
+	if err := c.Watch(&source.Kind{Type: &corev1.Node{}}, handler.EnqueueRequestsFromMapFunc(func(o client.Object) []reconcile.Request {
+		return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: DefaultDNSController}}}
+	}), predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			old, new := e.ObjectOld.(*corev1.Node), e.ObjectNew.(*corev1.Node)
+			return old.Spec.Unschedulable != new.Spec.Unschedulable || !reflect.DeepEqual(old.Labels, new.Labels) || !reflect.DeepEqual(old.Spec.Taints, new.Spec.Taints)
+		},
+	}); err != nil {
+		return nil, err
+	}
+	// The dns pods reach DNS-over-HTTPS upstreams through the cluster-wide
+	// proxy.
+	if err := c.Watch(&source.Kind{Type: &configv1.Proxy{}}, handler.EnqueueRequestsFromMapFunc(func(o client.Object) []reconcile.Request {
+		return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: DefaultDNSController}}}
+	})); err != nil {
+		return nil, err
+	}
+	// Only the image verification pod is owned by the dns; pods that the
+	// daemonsets create are owned by their daemonsets.
+	if err := c.Watch(&source.Kind{Type: &corev1.Pod{}}, &handler.EnqueueRequestForOwner{OwnerType: &operatorv1.DNS{}}); err != nil {
+		return nil, err
+	}
 	return c, nil
 }
 
@@ -81,6 +201,42 @@ type reconciler struct {
 
 	client client.Client
 	cache  cache.Cache
+	// secretCache is a cache of the secrets in the operand namespace.
+	secretCache cache.Cache
+
+	// desiredState records the operand objects that the reconciler
+	// renders.  It may be nil.
+	desiredState *DesiredState
+	// tlsCertificates tracks when the certificates of the dnses' TLS
+	// upstreams and encrypted listeners expire.  It may be nil.
+	tlsCertificates *tlsCertificateExpiries
+}
+
+// useMetricsProxy returns a Boolean value indicating whether dns metrics are
+// served through the operator's metrics proxy rather than through a
+// kube-rbac-proxy sidecar in each dns pod.
+func (r *reconciler) useMetricsProxy() bool {
+	return r.MetricsAuthentication == operatorconfig.MetricsAuthenticationTokenReview
+}
+
+// coreDNSMetricsAddress returns the address on which CoreDNS should serve
+// metrics.  CoreDNS serves metrics only on the loopback interface unless the
+// operator's metrics proxy needs to reach it over the pod network, in which
+// case a network policy allows only the operator to reach the metrics port.
+func (r *reconciler) coreDNSMetricsAddress() string {
+	if r.useMetricsProxy() {
+		return fmt.Sprintf(":%d", CoreDNSMetricsPort)
+	}
+	return fmt.Sprintf("127.0.0.1:%d", CoreDNSMetricsPort)
+}
+
+// metricsProxyAddress returns the host:port address of the operator's metrics
+// proxy, or the empty string if the metrics proxy is not used.
+func (r *reconciler) metricsProxyAddress() string {
+	if !r.useMetricsProxy() {
+		return ""
+	}
+	return fmt.Sprintf("%s.%s.svc:%d", OperatorMetricsServiceName, r.OperatorNamespace, MetricsProxyPort)
 }
 
 // Reconcile expects request to refer to a dns and will do all the work
@@ -124,6 +280,8 @@ func (r *reconciler) Reconcile(ctx context.Context, request reconcile.Request) (
 			if err := r.ensureDNSDeleted(dns); err != nil {
 				errs = append(errs, fmt.Errorf("failed to ensure deletion for dns %s: %v", dns.Name, err))
 			}
+			r.desiredState.forget(dns.Name)
+			r.tlsCertificates.forget(dns.Name)
 
 			if len(errs) == 0 {
 				// Clean up the finalizer to allow the dns to be deleted.
@@ -137,13 +295,132 @@ func (r *reconciler) Reconcile(ctx context.Context, request reconcile.Request) (
 			}
 		} else if err := r.enforceDNSFinalizer(dns); err != nil {
 			errs = append(errs, fmt.Errorf("failed to enforce finalizer for dns %s: %v", dns.Name, err))
+		} else if effective, fleetConditions, err := r.ensureDNSFleetConfiguration(dns); err != nil {
+			// Do not risk applying local edits to a
+			// fleet-managed dns.
+			errs = append(errs, fmt.Errorf("failed to ensure fleet configuration for dns %s: %v", dns.Name, err))
+		} else if dnsManagementState(effective) == operatorv1.Removed {
+			// Remove the operands but keep the dns and its
+			// finalizer so that the operands can be restored.
+			r.desiredState.forget(dns.Name)
+			r.tlsCertificates.forget(dns.Name)
+			if err := r.ensureDNSRemoved(effective); err != nil {
+				errs = append(errs, fmt.Errorf("failed to remove operands for dns %s: %w", dns.Name, err))
+			}
 		} else {
-			// Handle everything else.
-			if err := r.ensureDNS(dns); err != nil {
-				errs = append(errs, fmt.Errorf("failed to ensure dns %s: %v", dns.Name, err))
+			// Handle everything else.  The operator applies the
+			// effective configuration, which ignores local edits
+			// to a fleet-managed dns.
+			dns = effective
+			if migrated, migrationCondition, err := r.ensureDeprecatedFieldsMigrated(dns); err != nil {
+				// Apply the configuration as is.
+				errs = append(errs, err)
+			} else {
+				dns = migrated
+				if migrationCondition != nil {
+					fleetConditions = append(fleetConditions, *migrationCondition)
+				}
+			}
+			schema, schemaCondition, err := r.currentDNSCRDSchema()
+			if err != nil {
+				// Assume that the schema is current.
+				errs = append(errs, err)
+			} else {
+				fleetConditions = append(fleetConditions, schemaCondition)
+			}
+			r.desiredState.begin(dns.Name)
+			err = r.ensureDNS(dns, fleetConditions, schema)
+			r.desiredState.commit(dns.Name)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to ensure dns %s: %w", dns.Name, err))
 			} else if err := r.ensureExternalNameForOpenshiftService(); err != nil {
 				errs = append(errs, fmt.Errorf("failed to ensure external name for openshift service: %v", err))
 			}
+			// Changes to additional node resolver services, to IdM
+			// DNS servers, to ExternalName services, and to
+			// cert-manager Certificates are not watched, so poll
+			// for them.
+			if len(nodeResolverAdditionalServices(dns)) != 0 || idmDiscoveryEnabled(dns) || externalNamePolicyEnabled(dns) || len(dns.Annotations[MetricsCertificateIssuerAnnotation]) != 0 {
+				result.RequeueAfter = nodeResolverResyncPeriod
+			}
+			// Check again whether the DNS CRD has been updated.
+			if schema.outdated() {
+				if result.RequeueAfter == 0 || crdSchemaCheckPeriod < result.RequeueAfter {
+					result.RequeueAfter = crdSchemaCheckPeriod
+				}
+			}
+			// Check again whether the CA bundles of the
+			// DNS-over-TLS upstreams expire soon.
+			if len(upstreamCABundleConfigMapNames(dns)) != 0 {
+				if result.RequeueAfter == 0 || upstreamCABundleCheckPeriod < result.RequeueAfter {
+					result.RequeueAfter = upstreamCABundleCheckPeriod
+				}
+			}
+			// Copy the client certificate secrets and CA bundle
+			// configmaps in other namespaces again, which are not
+			// watched.
+			if len(upstreamTLSReferences(dns)) != 0 {
+				if result.RequeueAfter == 0 || upstreamTLSReferenceSyncPeriod < result.RequeueAfter {
+					result.RequeueAfter = upstreamTLSReferenceSyncPeriod
+				}
+			}
+			// Check again whether the certificates of the TLS
+			// upstreams and encrypted listeners expire soon.
+			if r.tlsCertificates.tracking(dns.Name) {
+				if result.RequeueAfter == 0 || tlsCertificateCheckPeriod < result.RequeueAfter {
+					result.RequeueAfter = tlsCertificateCheckPeriod
+				}
+			}
+			// Check again whether port conflicts keep the dns
+			// pods from becoming ready.
+			if unready, err := r.dnsPodsUnready(dns); err != nil {
+				errs = append(errs, err)
+			} else if unready {
+				if result.RequeueAfter == 0 || portConflictCheckPeriod < result.RequeueAfter {
+					result.RequeueAfter = portConflictCheckPeriod
+				}
+			}
+			// Check again whether the dns pods have transferred
+			// the secondary zones.
+			if secondaryZonesEnabled(dns) {
+				if result.RequeueAfter == 0 || secondaryZoneCheckPeriod < result.RequeueAfter {
+					result.RequeueAfter = secondaryZoneCheckPeriod
+				}
+			}
+			// Check again whether the SecretProviderClasses that
+			// provide the dns's credentials have changed.
+			if usesSecretProviders(dns) {
+				if result.RequeueAfter == 0 || secretProviderClassCheckPeriod < result.RequeueAfter {
+					result.RequeueAfter = secretProviderClassCheckPeriod
+				}
+			}
+			// Check again whether the blocklist feeds are due to
+			// be fetched.
+			if blocklistsEnabled(dns) {
+				if result.RequeueAfter == 0 || blocklistCheckPeriod < result.RequeueAfter {
+					result.RequeueAfter = blocklistCheckPeriod
+				}
+			}
+			// Reconcile again when the resolv.conf probe pods
+			// become stale so that they are recreated.
+			if observeDefaultUpstreams(dns) {
+				next := defaultUpstreamsRefreshPeriod + time.Second
+				if result.RequeueAfter == 0 || next < result.RequeueAfter {
+					result.RequeueAfter = next
+				}
+			}
+			// Reconcile again when the next maintenance or rollout
+			// window starts or ends so that the servers switch
+			// upstreams and deferred rollouts proceed.
+			windows := append(maintenanceWindows(dns), rolloutWindows(dns)...)
+			if next, ok := nextMaintenanceTransition(windows, time.Now()); ok {
+				// Allow for clock skew between the computed
+				// transition and the requeue.
+				next += time.Second
+				if result.RequeueAfter == 0 || next < result.RequeueAfter {
+					result.RequeueAfter = next
+				}
+			}
 		}
 	}
 
@@ -151,6 +428,11 @@ func (r *reconciler) Reconcile(ctx context.Context, request reconcile.Request) (
 	if len(errs) > 0 {
 		logrus.Errorf("failed to reconcile request %s: %v", request, utilerrors.NewAggregate(errs))
 	}
+	// Returning an error would retry immediately, so back off until
+	// status writes resume if the API server is overloaded.
+	if retryAfter, ok := statusbreaker.RetryAfter(utilerrors.NewAggregate(errs)); ok {
+		return reconcile.Result{RequeueAfter: retryAfter}, nil
+	}
 	return result, utilerrors.NewAggregate(errs)
 }
 
@@ -219,9 +501,9 @@ func (r *reconciler) enforceDNSFinalizer(dns *operatorv1.DNS) error {
 
 // ensureDNSDeleted tries to delete related dns resources.
 func (r *reconciler) ensureDNSDeleted(dns *operatorv1.DNS) error {
-	// DNS specific configmap and service has owner reference to daemonset.
-	// So deletion of daemonset will trigger garbage collection of corresponding
-	// configmap and service resources.
+	// The dns's operands have owner references to the dns, so the garbage
+	// collector deletes them once the dns is deleted.  Delete the
+	// daemonset first so that the dns pods stop promptly.
 	if err := r.ensureDNSDaemonSetDeleted(dns); err != nil {
 		return fmt.Errorf("failed to delete daemonset for dns %s: %v", dns.Name, err)
 	}
@@ -242,52 +524,65 @@ func (r *reconciler) ensureDNSNamespace() error {
 		logrus.Infof("created dns namespace: %s", ns.Name)
 	}
 
-	if _, _, err := r.ensureDNSClusterRole(); err != nil {
-		return fmt.Errorf("failed to ensure dns cluster role for %s: %v", manifests.DNSClusterRole().Name, err)
-	}
-
-	crb := manifests.DNSClusterRoleBinding()
-	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: crb.Name}, crb); err != nil {
-		if !errors.IsNotFound(err) {
-			return fmt.Errorf("failed to get dns cluster role binding %s: %v", crb.Name, err)
-		}
-		if err := r.client.Create(context.TODO(), crb); err != nil {
-			return fmt.Errorf("failed to create dns cluster role binding %s: %v", crb.Name, err)
-		}
-		logrus.Infof("created dns cluster role binding: %s", crb.Name)
-	}
-
-	sa := manifests.DNSServiceAccount()
-	if err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: sa.Namespace, Name: sa.Name}, sa); err != nil {
-		if !errors.IsNotFound(err) {
-			return fmt.Errorf("failed to get dns service account %s/%s: %v", sa.Namespace, sa.Name, err)
-		}
-		if err := r.client.Create(context.TODO(), sa); err != nil {
-			return fmt.Errorf("failed to create dns service account %s/%s: %v", sa.Namespace, sa.Name, err)
-		}
-		logrus.Infof("created dns service account: %s/%s", sa.Namespace, sa.Name)
-	}
-
-	nodeResolverServiceAccount := manifests.NodeResolverServiceAccount()
-	nodeResolverServiceAccountName := types.NamespacedName{
-		Namespace: nodeResolverServiceAccount.Namespace,
-		Name:      nodeResolverServiceAccount.Name,
-	}
-	if err := r.client.Get(context.TODO(), nodeResolverServiceAccountName, nodeResolverServiceAccount); err != nil {
-		if !errors.IsNotFound(err) {
-			return fmt.Errorf("failed to get serviceaccount %s: %w", nodeResolverServiceAccountName, err)
-		}
-		if err := r.client.Create(context.TODO(), nodeResolverServiceAccount); err != nil {
-			return fmt.Errorf("failed to create serviceaccount %s: %w", nodeResolverServiceAccountName, err)
-		}
-		logrus.Infof("created serviceaccount %s", nodeResolverServiceAccountName)
-	}
-
-	return nil
+	// The remaining scaffolding only depends on the namespace.
+	return parallel.Run(
+		func() error {
+			if _, _, err := r.ensureDNSClusterRole(); err != nil {
+				return fmt.Errorf("failed to ensure dns cluster role for %s: %v", manifests.DNSClusterRole().Name, err)
+			}
+			return nil
+		},
+		func() error {
+			crb := manifests.DNSClusterRoleBinding()
+			if err := r.client.Get(context.TODO(), types.NamespacedName{Name: crb.Name}, crb); err != nil {
+				if !errors.IsNotFound(err) {
+					return fmt.Errorf("failed to get dns cluster role binding %s: %v", crb.Name, err)
+				}
+				if err := r.client.Create(context.TODO(), crb); err != nil {
+					return fmt.Errorf("failed to create dns cluster role binding %s: %v", crb.Name, err)
+				}
+				logrus.Infof("created dns cluster role binding: %s", crb.Name)
+			}
+			return nil
+		},
+		func() error {
+			sa := manifests.DNSServiceAccount()
+			if err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: sa.Namespace, Name: sa.Name}, sa); err != nil {
+				if !errors.IsNotFound(err) {
+					return fmt.Errorf("failed to get dns service account %s/%s: %v", sa.Namespace, sa.Name, err)
+				}
+				if err := r.client.Create(context.TODO(), sa); err != nil {
+					return fmt.Errorf("failed to create dns service account %s/%s: %v", sa.Namespace, sa.Name, err)
+				}
+				logrus.Infof("created dns service account: %s/%s", sa.Namespace, sa.Name)
+			}
+			return nil
+		},
+		func() error {
+			nodeResolverServiceAccount := manifests.NodeResolverServiceAccount()
+			nodeResolverServiceAccountName := types.NamespacedName{
+				Namespace: nodeResolverServiceAccount.Namespace,
+				Name:      nodeResolverServiceAccount.Name,
+			}
+			if err := r.client.Get(context.TODO(), nodeResolverServiceAccountName, nodeResolverServiceAccount); err != nil {
+				if !errors.IsNotFound(err) {
+					return fmt.Errorf("failed to get serviceaccount %s: %w", nodeResolverServiceAccountName, err)
+				}
+				if err := r.client.Create(context.TODO(), nodeResolverServiceAccount); err != nil {
+					return fmt.Errorf("failed to create serviceaccount %s: %w", nodeResolverServiceAccountName, err)
+				}
+				logrus.Infof("created serviceaccount %s", nodeResolverServiceAccountName)
+			}
+			return nil
+		},
+	)
 }
 
-// ensureMetricsIntegration ensures that dns prometheus metrics are integrated with openshift-monitoring for the given DNS.
-func (r *reconciler) ensureMetricsIntegration(dns *operatorv1.DNS, svc *corev1.Service, daemonsetRef metav1.OwnerReference) error {
+// ensureMetricsIntegration ensures that dns prometheus metrics are integrated
+// with openshift-monitoring for the given DNS.  If metricsCASecretName is
+// non-empty, prometheus verifies the metrics endpoint using the CA certificate
+// in that secret.
+func (r *reconciler) ensureMetricsIntegration(dns *operatorv1.DNS, svc *corev1.Service, daemonsetRef metav1.OwnerReference, metricsCASecretName string) error {
 	cr := manifests.MetricsClusterRole()
 	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: cr.Name}, cr); err != nil {
 		if !errors.IsNotFound(err) {
@@ -332,15 +627,17 @@ func (r *reconciler) ensureMetricsIntegration(dns *operatorv1.DNS, svc *corev1.S
 		logrus.Infof("created dns metrics role binding %s/%s", mrb.Namespace, mrb.Name)
 	}
 
-	if _, _, err := r.ensureServiceMonitor(dns, svc, daemonsetRef); err != nil {
+	if _, _, err := r.ensureServiceMonitor(dns, svc, daemonsetRef, r.metricsProxyAddress(), metricsCASecretName); err != nil {
 		return fmt.Errorf("failed to ensure servicemonitor for %s: %v", dns.Name, err)
 	}
 
 	return nil
 }
 
-// ensureDNS ensures all necessary dns resources exist for a given dns.
-func (r *reconciler) ensureDNS(dns *operatorv1.DNS) error {
+// ensureDNS ensures all necessary dns resources exist for a given dns.  The
+// given conditions are reported in addition to the conditions that ensureDNS
+// computes.
+func (r *reconciler) ensureDNS(dns *operatorv1.DNS, additionalConditions []operatorv1.OperatorCondition, schema *dnsCRDSchema) error {
 	// TODO: fetch this from higher level openshift resource when it is exposed
 	clusterDomain := "cluster.local"
 	clusterIP, err := r.getClusterIPFromNetworkConfig()
@@ -348,14 +645,146 @@ func (r *reconciler) ensureDNS(dns *operatorv1.DNS) error {
 		return fmt.Errorf("failed to get cluster IP from network config: %v", err)
 	}
 
-	errs := []error{}
+	inputs, doh, blackholed, conditions, errs := r.dnsCorefileInputs(dns, clusterIP, clusterDomain)
+	conditions = append(append([]operatorv1.OperatorCondition{}, additionalConditions...), conditions...)
+
+	coreDNSImage, kubeRBACProxyImage, imageConditions, imageErrs := r.dnsOperandImages(dns, inputs)
+	conditions = append(conditions, imageConditions...)
+	errs = append(errs, imageErrs...)
 
-	haveDNSDaemonset, dnsDaemonset, err := r.ensureDNSDaemonSet(dns)
+	// The coredns image that the daemonset uses must support the servers'
+	// DNS-over-QUIC upstreams.
+	var condition *operatorv1.OperatorCondition
+	inputs.Servers, condition, err = r.ensureDoQUpstreams(dns, inputs.Servers, coreDNSImage)
 	if err != nil {
-		errs = append(errs, fmt.Errorf("failed to ensure daemonset for dns %s: %v", dns.Name, err))
-	} else if !haveDNSDaemonset {
-		errs = append(errs, fmt.Errorf("failed to get daemonset for dns %s", dns.Name))
+		errs = append(errs, fmt.Errorf("failed to check DNS-over-QUIC support for dns %s: %v", dns.Name, err))
+	}
+	if condition != nil {
+		conditions = append(conditions, *condition)
+	}
+
+	var metricsCertificateRevision string
+	if revision, condition, err := r.ensureDNSMetricsCertificate(dns); err != nil {
+		errs = append(errs, fmt.Errorf("failed to ensure metrics certificate for dns %s: %v", dns.Name, err))
+	} else {
+		metricsCertificateRevision = revision
+		if condition != nil {
+			conditions = append(conditions, *condition)
+		}
+	}
+
+	metricsSecretName, customMetricsSecret, condition, err := r.metricsServingCertSecret(dns)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("failed to get metrics serving certificate for dns %s: %v", dns.Name, err))
+	}
+	if condition != nil {
+		conditions = append(conditions, *condition)
+	}
+	metricsCASecretName := ""
+	if customMetricsSecret {
+		metricsCASecretName = metricsSecretName
+	}
+
+	for _, transport := range encryptedTransports {
+		if listener, condition, err := r.encryptedListener(dns, transport); err != nil {
+			errs = append(errs, fmt.Errorf("failed to get %s serving certificate for dns %s: %v", transport.Name, dns.Name, err))
+		} else {
+			if listener != nil {
+				inputs.EncryptedListeners = append(inputs.EncryptedListeners, *listener)
+			}
+			if condition != nil {
+				conditions = append(conditions, *condition)
+			}
+		}
+	}
+
+	targets := tlsCertificateTargets(inputs.Servers, inputs.UpstreamTLS, doh, inputs.EncryptedListeners)
+	if condition, err := r.computeDNSTLSCertificatesExpiringCondition(dns, targets, time.Now()); err != nil {
+		errs = append(errs, fmt.Errorf("failed to check TLS certificate expiry for dns %s: %v", dns.Name, err))
+	} else if condition != nil {
+		conditions = append(conditions, *condition)
+	}
+
+	if condition, err := r.ensureDNSNodeTuning(dns); err != nil {
+		errs = append(errs, fmt.Errorf("failed to ensure node tuning for dns %s: %v", dns.Name, err))
+	} else if condition != nil {
+		conditions = append(conditions, *condition)
+	}
+
+	// Check the node placement before the daemonset is updated so that a
+	// change that would remove dns pods from nodes is held.
+	holdNodePlacement := false
+	if condition, hold, err := r.computeDNSNodeCoveragePreservedCondition(dns); err != nil {
+		errs = append(errs, err)
+	} else {
+		conditions = append(conditions, condition)
+		holdNodePlacement = hold
+	}
+
+	// Delete the service first if an immutable field must change so that
+	// it is recreated below.
+	if condition, err := r.ensureDNSServiceRecreated(dns, clusterIP); err != nil {
+		errs = append(errs, fmt.Errorf("failed to recreate service for dns %s: %v", dns.Name, err))
 	} else {
+		conditions = append(conditions, condition)
+	}
+
+	// The daemonset and service do not depend on each other, so ensure
+	// them concurrently.
+	var (
+		haveDNSDaemonset, haveSvc bool
+		dnsDaemonset              *appsv1.DaemonSet
+		rolloutCondition          *operatorv1.OperatorCondition
+		svc                       *corev1.Service
+	)
+	if err := parallel.Run(
+		func() (err error) {
+			haveDNSDaemonset, dnsDaemonset, rolloutCondition, err = r.ensureDNSDaemonSet(dns, coreDNSImage, kubeRBACProxyImage, metricsSecretName, metricsCertificateRevision, inputs.EncryptedListeners, doh, inputs.UpstreamTLS, inputs.ZoneTransfers, holdNodePlacement)
+			return err
+		},
+		func() (err error) {
+			haveSvc, svc, err = r.ensureDNSService(dns, clusterIP, inputs.EncryptedListeners)
+			return err
+		},
+	); err != nil {
+		errs = append(errs, fmt.Errorf("failed to ensure daemonset or service for dns %s: %w", dns.Name, err))
+	}
+	if !haveDNSDaemonset {
+		errs = append(errs, fmt.Errorf("failed to get daemonset for dns %s", dns.Name))
+	}
+	if !haveSvc {
+		errs = append(errs, fmt.Errorf("failed to get service for dns %s", dns.Name))
+	}
+	if rolloutCondition != nil {
+		conditions = append(conditions, *rolloutCondition)
+	}
+	// Ensure the configmap after the daemonset so that a configmap update
+	// that goes with a deferred daemonset rollout is deferred too.
+	// CoreDNS reloads the Corefile without a rollout, so otherwise the
+	// current pods would load a Corefile that may need the new pods'
+	// mounts or ports.
+	if _, _, err := r.ensureDNSConfigMap(dns, inputs, rolloutDeferred(rolloutCondition)); err != nil {
+		errs = append(errs, fmt.Errorf("failed to create configmap for dns %s: %v", dns.Name, err))
+	}
+	// CoreDNS serves metrics on the pod network only if the operator's
+	// metrics proxy scrapes them, and then only the operator may reach
+	// the metrics port.
+	if r.useMetricsProxy() {
+		if haveDNSDaemonset {
+			if err := r.ensureDNSNetworkPolicy(dns, dnsDaemonset); err != nil {
+				errs = append(errs, fmt.Errorf("failed to ensure network policy for dns %s: %w", dns.Name, err))
+			}
+		}
+	} else if err := r.ensureDNSNetworkPolicyDeleted(dns); err != nil {
+		errs = append(errs, fmt.Errorf("failed to delete network policy for dns %s: %w", dns.Name, err))
+	}
+	if !haveSvc {
+		// Set clusterIP to an empty string to cause ClusterOperator to
+		// report Available=False and Degraded=True.
+		clusterIP = ""
+	}
+
+	if haveDNSDaemonset && haveSvc {
 		trueVar := true
 		daemonsetRef := metav1.OwnerReference{
 			APIVersion: "apps/v1",
@@ -364,34 +793,325 @@ func (r *reconciler) ensureDNS(dns *operatorv1.DNS) error {
 			UID:        dnsDaemonset.UID,
 			Controller: &trueVar,
 		}
-
-		if _, _, err := r.ensureDNSConfigMap(dns, clusterDomain); err != nil {
-			errs = append(errs, fmt.Errorf("failed to create configmap for dns %s: %v", dns.Name, err))
-		}
-		if haveSvc, svc, err := r.ensureDNSService(dns, clusterIP, daemonsetRef); err != nil {
-			// Set clusterIP to an empty string to cause ClusterOperator to report
-			// Available=False and Degraded=True.
-			clusterIP = ""
-			errs = append(errs, fmt.Errorf("failed to create service for dns %s: %v", dns.Name, err))
-		} else if !haveSvc {
-			errs = append(errs, fmt.Errorf("failed to get service for dns %s", dns.Name))
-		} else if err := r.ensureMetricsIntegration(dns, svc, daemonsetRef); err != nil {
+		if err := r.ensureMetricsIntegration(dns, svc, daemonsetRef, metricsCASecretName); err != nil {
 			errs = append(errs, fmt.Errorf("failed to integrate metrics with openshift-monitoring for dns %s: %v", dns.Name, err))
 		}
 	}
 
+	if err := r.ensureNodeResolverConfigMap(dns, clusterDomain); err != nil {
+		errs = append(errs, fmt.Errorf("failed to ensure node resolver configmap for dns %s: %v", dns.Name, err))
+	}
 	haveNodeResolverDaemonset, nodeResolverDaemonset, err := r.ensureNodeResolverDaemonSet(dns, clusterIP, clusterDomain)
 	if err != nil {
 		errs = append(errs, err)
 	}
 
-	if err := r.syncDNSStatus(dns, clusterIP, clusterDomain, haveDNSDaemonset, dnsDaemonset, haveNodeResolverDaemonset, nodeResolverDaemonset); err != nil {
+	if condition, err := r.ensureDefaultUpstreamsObserved(dns); err != nil {
+		errs = append(errs, fmt.Errorf("failed to observe default upstreams for dns %s: %v", dns.Name, err))
+	} else if condition != nil {
+		conditions = append(conditions, *condition)
+	}
+
+	if haveSvc && len(svc.Spec.ClusterIP) != 0 {
+		if condition, err := r.computeDNSKubeletClusterDNSConsistentCondition(svc.Spec.ClusterIP); err != nil {
+			errs = append(errs, fmt.Errorf("failed to check kubelet cluster dns for dns %s: %v", dns.Name, err))
+		} else if condition != nil {
+			conditions = append(conditions, *condition)
+		}
+	}
+
+	if r.ChaosHooks {
+		if haveDNSDaemonset {
+			if err := r.ensureChaosPodsKilled(dns); err != nil {
+				errs = append(errs, fmt.Errorf("failed to inject chaos for dns %s: %v", dns.Name, err))
+			}
+		}
+		conditions = append(conditions, computeDNSChaosTestModeCondition(dns, blackholed))
+	}
+
+	if err := r.ensureDNSTopologySnapshot(dns, svc, inputs.Servers); err != nil {
+		errs = append(errs, fmt.Errorf("failed to ensure topology snapshot for dns %s: %v", dns.Name, err))
+	}
+
+	if condition, err := r.computeDNSZoneCapacityAtRiskCondition(dns); err != nil {
+		errs = append(errs, err)
+	} else {
+		conditions = append(conditions, condition)
+	}
+
+	topology, err := r.currentNodeTopology()
+	if err != nil {
+		errs = append(errs, err)
+	} else if err := r.computeControlPlaneWindow(dns, &topology); err != nil {
+		errs = append(errs, err)
+	}
+	var portConflicts []string
+	if haveDNSDaemonset {
+		if portConflicts, err = r.dnsPortConflicts(dns); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := r.syncDNSStatus(dns, clusterIP, clusterDomain, topology, haveDNSDaemonset, dnsDaemonset, portConflicts, haveNodeResolverDaemonset, nodeResolverDaemonset, conditions, schema); err != nil {
 		errs = append(errs, fmt.Errorf("failed to sync status of dns %q: %w", dns.Name, err))
+	} else if err := r.syncDNSObservedGeneration(dns); err != nil {
+		errs = append(errs, fmt.Errorf("failed to sync observed generation of dns %q: %w", dns.Name, err))
 	}
 
 	return utilerrors.NewAggregate(errs)
 }
 
+// dnsCorefileInputs computes what the given dns's Corefile is rendered
+// from.  It also returns the DNS-over-HTTPS forwarding configuration, the
+// servers that chaos testing blackholes, and the conditions and errors that
+// computing the inputs reported.
+func (r *reconciler) dnsCorefileInputs(dns *operatorv1.DNS, clusterIP, clusterDomain string) (corefileInputs, *dohForwarding, []string, []operatorv1.OperatorCondition, []error) {
+	var (
+		conditions []operatorv1.OperatorCondition
+		errs       []error
+		err        error
+	)
+	dnsServers := dns.Spec.Servers
+	if cmServers, condition, err := r.dnsForwardingConfigMapServers(dns, clusterDomain); err != nil {
+		errs = append(errs, err)
+	} else if condition != nil {
+		dnsServers = append(append([]operatorv1.Server{}, dns.Spec.Servers...), cmServers...)
+		conditions = append(conditions, *condition)
+	}
+	servers := applyMaintenanceUpstreams(dnsServers, activeMaintenanceUpstreams(maintenanceWindows(dns), time.Now()))
+	var blackholed []string
+	if r.ChaosHooks {
+		servers, blackholed = applyChaosBlackholes(servers, chaosBlackholeServers(dns))
+	}
+	if serversUseUpstreamTemplates(servers) {
+		facts := upstreamTemplateFacts{ClusterDomain: clusterDomain, ClusterDNSIP: clusterIP}
+		if facts.PlatformDNSServer, err = r.platformDNSServer(); err != nil {
+			errs = append(errs, err)
+		}
+		var condition operatorv1.OperatorCondition
+		servers, condition = resolveUpstreamTemplates(servers, facts)
+		conditions = append(conditions, condition)
+	}
+	servers, upstreamsCondition := validUpstreamServers(servers, fallbackServers(dns))
+	conditions = append(conditions, upstreamsCondition)
+	if dnsAddresses, err := r.dnsServiceAddresses(clusterIP); err != nil {
+		errs = append(errs, fmt.Errorf("failed to get dns service addresses: %v", err))
+	} else {
+		var condition operatorv1.OperatorCondition
+		servers, condition = loopFreeServers(servers, clusterDomain, dnsAddresses)
+		conditions = append(conditions, condition)
+	}
+	var doh *dohForwarding
+	if urls := dohUpstreamURLs(servers); len(urls) != 0 {
+		if forwarding, condition, err := r.dnsDoHForwarding(dns, urls); err != nil {
+			errs = append(errs, fmt.Errorf("failed to configure DNS-over-HTTPS upstreams for dns %s: %v", dns.Name, err))
+		} else {
+			doh = forwarding
+			conditions = append(conditions, condition)
+		}
+	}
+	servers = applyDoHForwarding(servers, doh)
+	if condition, err := r.ensureUpstreamTLSCopies(dns, time.Now()); err != nil {
+		errs = append(errs, fmt.Errorf("failed to copy DNS-over-TLS upstream secrets and configmaps for dns %s: %v", dns.Name, err))
+	} else if condition != nil {
+		conditions = append(conditions, *condition)
+	}
+	caBundles, caBundlesCondition, err := r.dnsUpstreamCABundles(dns, time.Now())
+	if err != nil {
+		errs = append(errs, fmt.Errorf("failed to get CA bundles of DNS-over-TLS upstreams for dns %s: %v", dns.Name, err))
+	} else if caBundlesCondition != nil {
+		conditions = append(conditions, *caBundlesCondition)
+	}
+	var upstreamTLSConfigs []upstreamTLS
+	if tlsServers, configs, condition, err := r.dnsUpstreamTLS(dns, servers, caBundles); err != nil {
+		errs = append(errs, fmt.Errorf("failed to configure DNS-over-TLS upstreams for dns %s: %v", dns.Name, err))
+		servers = tlsServers
+	} else {
+		servers, upstreamTLSConfigs = tlsServers, configs
+		if condition != nil {
+			conditions = append(conditions, *condition)
+		}
+	}
+	var idmResolvers []idmResolver
+	if idmDiscoveryEnabled(dns) {
+		var condition operatorv1.OperatorCondition
+		if idmResolvers, condition, err = r.discoverIdMResolvers(dns, servers, clusterDomain); err != nil {
+			errs = append(errs, err)
+		} else {
+			conditions = append(conditions, condition)
+		}
+	}
+	var rewriteRules []rewriteRule
+	if rules, condition, err := r.dnsRewriteRules(dns, servers, idmResolvers, clusterDomain); err != nil {
+		errs = append(errs, err)
+	} else {
+		rewriteRules = rules
+		if condition != nil {
+			conditions = append(conditions, *condition)
+		}
+	}
+	var staticHosts []staticHost
+	if hosts, condition, err := r.dnsStaticHosts(dns, servers, idmResolvers, clusterDomain); err != nil {
+		errs = append(errs, err)
+	} else {
+		staticHosts = hosts
+		if condition != nil {
+			conditions = append(conditions, *condition)
+		}
+	}
+	var dns64 *corefileDNS64
+	if config, condition, err := r.dnsDNS64(dns); err != nil {
+		errs = append(errs, err)
+	} else {
+		dns64 = config
+		if condition != nil {
+			conditions = append(conditions, *condition)
+		}
+	}
+	var synthesizedRecords []synthesizedRecord
+	if records, condition, err := r.dnsSynthesizedRecords(dns, servers, idmResolvers, clusterDomain); err != nil {
+		errs = append(errs, err)
+	} else {
+		synthesizedRecords = records
+		if condition != nil {
+			conditions = append(conditions, *condition)
+		}
+	}
+	if records, condition, err := r.dnsExternalNamePolicy(dns, clusterDomain); err != nil {
+		errs = append(errs, err)
+	} else {
+		synthesizedRecords = append(synthesizedRecords, records...)
+		if condition != nil {
+			conditions = append(conditions, *condition)
+		}
+	}
+
+	var zoneFiles []zoneFile
+	if zones, condition, err := r.dnsZoneFiles(dns, servers, idmResolvers, clusterDomain); err != nil {
+		errs = append(errs, err)
+	} else {
+		zoneFiles = zones
+		if condition != nil {
+			conditions = append(conditions, *condition)
+		}
+	}
+
+	var transfers []zoneTransfer
+	if list, condition, err := r.dnsZoneTransfers(dns, zoneFiles); err != nil {
+		errs = append(errs, err)
+	} else {
+		transfers = list
+		if condition != nil {
+			conditions = append(conditions, *condition)
+		}
+	}
+
+	var secondaryZones []secondaryZone
+	if zones, condition, err := r.dnsSecondaryZones(dns, servers, idmResolvers, zoneFiles, clusterDomain); err != nil {
+		errs = append(errs, err)
+	} else {
+		secondaryZones = zones
+		if condition != nil {
+			conditions = append(conditions, *condition)
+		}
+	}
+
+	var blocklist *dnsBlocklist
+	if list, condition, err := r.dnsBlocklist(dns, servers, idmResolvers, zoneFiles, secondaryZones, staticHosts, clusterDomain, time.Now()); err != nil {
+		errs = append(errs, fmt.Errorf("failed to get blocklists for dns %s: %w", dns.Name, err))
+	} else {
+		blocklist = list
+		if condition != nil {
+			conditions = append(conditions, *condition)
+		}
+	}
+
+	views, viewsCondition := dnsViews(dns, clusterDomain)
+	if viewsCondition != nil {
+		conditions = append(conditions, *viewsCondition)
+	}
+	if condition := computeResponseRateLimitingConfiguredCondition(dns); condition != nil {
+		conditions = append(conditions, *condition)
+	}
+	var queryACLs []queryACL
+	if acls, condition, err := r.dnsQueryACLs(dns); err != nil {
+		errs = append(errs, err)
+	} else {
+		queryACLs = acls
+		if condition != nil {
+			conditions = append(conditions, *condition)
+		}
+	}
+
+	var customCorefile *corefileCustom
+	if custom, condition, err := r.dnsCustomCorefile(dns, servers, idmResolvers, zoneFiles, secondaryZones, clusterDomain); err != nil {
+		errs = append(errs, err)
+	} else {
+		customCorefile = custom
+		if condition != nil {
+			conditions = append(conditions, *condition)
+		}
+	}
+
+	return corefileInputs{
+		Servers:            servers,
+		IdMResolvers:       idmResolvers,
+		RewriteRules:       rewriteRules,
+		StaticHosts:        staticHosts,
+		SynthesizedRecords: synthesizedRecords,
+		DNS64:              dns64,
+		Custom:             customCorefile,
+		ZoneFiles:          zoneFiles,
+		ZoneTransfers:      transfers,
+		SecondaryZones:     secondaryZones,
+		Views:              views,
+		QueryACLs:          queryACLs,
+		Blocklist:          blocklist,
+		UpstreamTLS:        upstreamTLSConfigs,
+		ClusterDomain:      clusterDomain,
+		MetricsAddress:     r.coreDNSMetricsAddress(),
+	}, doh, blackholed, conditions, errs
+}
+
+// dnsOperandImages returns the coredns and kube-rbac-proxy images for the
+// given dns's daemonset, along with the conditions and errors that checking
+// them reported.  The kube-rbac-proxy image is empty if the dns pods do not
+// need the sidecar.
+func (r *reconciler) dnsOperandImages(dns *operatorv1.DNS, inputs corefileInputs) (string, string, []operatorv1.OperatorCondition, []error) {
+	var (
+		conditions []operatorv1.OperatorCondition
+		errs       []error
+	)
+	coreDNSImage, kubeRBACProxyImage := r.CoreDNSImage, r.KubeRBACProxyImage
+	if r.useMetricsProxy() {
+		// The operator authenticates metrics scrapes itself, so the
+		// dns pods do not need a kube-rbac-proxy sidecar.
+		kubeRBACProxyImage = ""
+	}
+	// The custom image check needs the servers to render the Corefile.
+	coreDNSImage, condition, err := r.ensureCustomCoreDNSImage(dns, inputs.Servers, inputs.Views, inputs.QueryACLs, inputs.ClusterDomain, coreDNSImage)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("failed to check custom coredns image for dns %s: %v", dns.Name, err))
+	}
+	if condition != nil {
+		conditions = append(conditions, *condition)
+	}
+	coreDNSImage, kubeRBACProxyImage, pinnedCondition, err := r.ensureOperandImagesPinned(dns, coreDNSImage, kubeRBACProxyImage)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("failed to check operand image pinning for dns %s: %v", dns.Name, err))
+	}
+	conditions = append(conditions, pinnedCondition)
+	if r.VerifyOperandImages {
+		var condition operatorv1.OperatorCondition
+		coreDNSImage, kubeRBACProxyImage, condition, err = r.ensureOperandImagesVerified(dns, coreDNSImage, kubeRBACProxyImage)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to verify operand images for dns %s: %v", dns.Name, err))
+		}
+		conditions = append(conditions, condition)
+	}
+
+	return coreDNSImage, kubeRBACProxyImage, conditions, errs
+}
+
 // getClusterIPFromNetworkConfig will return 10th IP from the service CIDR range
 // defined in the cluster network config.
 func (r *reconciler) getClusterIPFromNetworkConfig() (string, error) {
@@ -0,0 +1,135 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	// DNSZoneCapacityAtRiskConditionType is the type of the DNS status
+	// condition that indicates whether the cluster autoscaler is scaling
+	// down every node in a zone on which the dns's pods can run, which
+	// would leave the zone without zone-local DNS capacity.
+	DNSZoneCapacityAtRiskConditionType = conditions.TypeZoneCapacityAtRisk
+
+	// toBeDeletedByClusterAutoscalerTaint is the taint that the cluster
+	// autoscaler puts on a node that it is draining and deleting.
+	toBeDeletedByClusterAutoscalerTaint = "ToBeDeletedByClusterAutoscaler"
+	// deletionCandidateOfClusterAutoscalerTaint is the taint that the
+	// cluster autoscaler puts on a node that it considers unneeded and may
+	// delete soon.
+	deletionCandidateOfClusterAutoscalerTaint = "DeletionCandidateOfClusterAutoscaler"
+)
+
+// zoneScaleDown describes the cluster autoscaler's scale-down of the nodes of
+// one zone on which dns pods can run.
+type zoneScaleDown struct {
+	// nodes is the set of names of the zone's nodes.
+	nodes sets.String
+	// deleting is the set of names of the zone's nodes that the cluster
+	// autoscaler is deleting.
+	deleting sets.String
+	// candidates is the set of names of the zone's nodes that the cluster
+	// autoscaler may delete soon, including the nodes that it is deleting.
+	candidates sets.String
+}
+
+// zoneScaleDowns groups the given nodes that match the given node selector by
+// zone and returns the cluster autoscaler's scale-down of each zone.  Nodes
+// without a zone form one group with the empty name.
+func zoneScaleDowns(nodes []corev1.Node, selector labels.Selector) map[string]*zoneScaleDown {
+	zones := map[string]*zoneScaleDown{}
+	for _, node := range nodes {
+		if !selector.Matches(labels.Set(node.Labels)) {
+			continue
+		}
+		zone := node.Labels[corev1.LabelTopologyZone]
+		z, ok := zones[zone]
+		if !ok {
+			z = &zoneScaleDown{nodes: sets.NewString(), deleting: sets.NewString(), candidates: sets.NewString()}
+			zones[zone] = z
+		}
+		z.nodes.Insert(node.Name)
+		for _, taint := range node.Spec.Taints {
+			switch taint.Key {
+			case toBeDeletedByClusterAutoscalerTaint:
+				z.deleting.Insert(node.Name)
+				z.candidates.Insert(node.Name)
+			case deletionCandidateOfClusterAutoscalerTaint:
+				z.candidates.Insert(node.Name)
+			}
+		}
+	}
+	return zones
+}
+
+// computeDNSZoneCapacityAtRiskCondition returns a status condition that
+// reports the zones in which the cluster autoscaler is deleting, or may soon
+// delete, every node on which the given dns's pods can run.
+func (r *reconciler) computeDNSZoneCapacityAtRiskCondition(dns *operatorv1.DNS) (operatorv1.OperatorCondition, error) {
+	nodes, err := ListNodes(context.TODO(), r.cache)
+	if err != nil {
+		return operatorv1.OperatorCondition{}, fmt.Errorf("failed to list nodes: %w", err)
+	}
+	zones := zoneScaleDowns(nodes, labels.SelectorFromSet(nodeSelectorForDNS(dns)))
+	return computeZoneCapacityAtRiskCondition(zones), nil
+}
+
+// computeZoneCapacityAtRiskCondition returns a status condition that reports
+// the given zones whose nodes are all being deleted or are all deletion
+// candidates.  The condition reports such zones as soon as the autoscaler
+// marks their last nodes as deletion candidates so that the loss of
+// zone-local DNS capacity does not go unnoticed.
+func computeZoneCapacityAtRiskCondition(zones map[string]*zoneScaleDown) operatorv1.OperatorCondition {
+	zoneNames := make([]string, 0, len(zones))
+	for zone := range zones {
+		zoneNames = append(zoneNames, zone)
+	}
+	sort.Strings(zoneNames)
+	var deleting, candidates []string
+	for _, zone := range zoneNames {
+		z := zones[zone]
+		zoneDesc := fmt.Sprintf("zone %s", zone)
+		if len(zone) == 0 {
+			zoneDesc = "nodes without a zone"
+		}
+		switch {
+		case z.deleting.Equal(z.nodes):
+			deleting = append(deleting, fmt.Sprintf("%s (%s)", zoneDesc, strings.Join(z.nodes.List(), ", ")))
+		case z.candidates.Equal(z.nodes):
+			candidates = append(candidates, fmt.Sprintf("%s (%s)", zoneDesc, strings.Join(z.nodes.List(), ", ")))
+		}
+	}
+
+	condition := operatorv1.OperatorCondition{
+		Type: DNSZoneCapacityAtRiskConditionType,
+	}
+	switch {
+	case len(deleting) != 0:
+		condition.Status = operatorv1.ConditionTrue
+		condition.Reason = conditions.ReasonLastNodesScalingDown
+		condition.Message = fmt.Sprintf("The cluster autoscaler is deleting every node that can run DNS pods in %s.", strings.Join(deleting, "; "))
+		if len(candidates) != 0 {
+			condition.Message += fmt.Sprintf("  It may also delete every such node in %s.", strings.Join(candidates, "; "))
+		}
+	case len(candidates) != 0:
+		condition.Status = operatorv1.ConditionTrue
+		condition.Reason = conditions.ReasonLastNodesDeletionCandidates
+		condition.Message = fmt.Sprintf("The cluster autoscaler may soon delete every node that can run DNS pods in %s.", strings.Join(candidates, "; "))
+	default:
+		condition.Status = operatorv1.ConditionFalse
+		condition.Reason = conditions.ReasonAsExpected
+		condition.Message = "The cluster autoscaler is not scaling down every node that can run DNS pods in any zone."
+	}
+	return condition
+}
@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	corev1 "k8s.io/api/core/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// TestComputeZoneCapacityAtRiskCondition verifies that the ZoneCapacityAtRisk
+// condition reports zones whose DNS-capable nodes are all being scaled down.
+func TestComputeZoneCapacityAtRiskCondition(t *testing.T) {
+	node := func(name, zone string, taints ...string) corev1.Node {
+		n := corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: map[string]string{"kubernetes.io/os": "linux"},
+			},
+		}
+		if len(zone) != 0 {
+			n.Labels[corev1.LabelTopologyZone] = zone
+		}
+		for _, key := range taints {
+			n.Spec.Taints = append(n.Spec.Taints, corev1.Taint{Key: key, Effect: corev1.TaintEffectNoSchedule})
+		}
+		return n
+	}
+	windows := node("windows-a", "a")
+	windows.Labels["kubernetes.io/os"] = "windows"
+	testCases := []struct {
+		description    string
+		nodes          []corev1.Node
+		expectStatus   operatorv1.ConditionStatus
+		expectReason   string
+		expectContains string
+	}{
+		{
+			description:  "no scale-down",
+			nodes:        []corev1.Node{node("a1", "a"), node("b1", "b")},
+			expectStatus: operatorv1.ConditionFalse,
+			expectReason: "AsExpected",
+		},
+		{
+			description:  "one of two nodes in a zone being deleted",
+			nodes:        []corev1.Node{node("a1", "a", toBeDeletedByClusterAutoscalerTaint), node("a2", "a")},
+			expectStatus: operatorv1.ConditionFalse,
+			expectReason: "AsExpected",
+		},
+		{
+			description:    "last node in a zone being deleted",
+			nodes:          []corev1.Node{node("a1", "a", toBeDeletedByClusterAutoscalerTaint), node("b1", "b"), windows},
+			expectStatus:   operatorv1.ConditionTrue,
+			expectReason:   "LastNodesScalingDown",
+			expectContains: "zone a (a1)",
+		},
+		{
+			description:    "last nodes in a zone are deletion candidates",
+			nodes:          []corev1.Node{node("a1", "a", deletionCandidateOfClusterAutoscalerTaint), node("a2", "a", toBeDeletedByClusterAutoscalerTaint), node("b1", "b")},
+			expectStatus:   operatorv1.ConditionTrue,
+			expectReason:   "LastNodesDeletionCandidates",
+			expectContains: "zone a (a1, a2)",
+		},
+		{
+			description:    "last node without a zone being deleted",
+			nodes:          []corev1.Node{node("x1", "", toBeDeletedByClusterAutoscalerTaint)},
+			expectStatus:   operatorv1.ConditionTrue,
+			expectReason:   "LastNodesScalingDown",
+			expectContains: "nodes without a zone (x1)",
+		},
+	}
+	selector := labels.SelectorFromSet(nodeSelectorForDNS(&operatorv1.DNS{}))
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			condition := computeZoneCapacityAtRiskCondition(zoneScaleDowns(tc.nodes, selector))
+			if condition.Status != tc.expectStatus || condition.Reason != tc.expectReason {
+				t.Errorf("expected status %s and reason %s, got %s and %s: %s", tc.expectStatus, tc.expectReason, condition.Status, condition.Reason, condition.Message)
+			}
+			if len(tc.expectContains) != 0 && !strings.Contains(condition.Message, tc.expectContains) {
+				t.Errorf("expected message to contain %q, got %q", tc.expectContains, condition.Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,631 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/manifests"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// DNSBlocklistsCurrentConditionType is the type of the DNS status
+	// condition that reports whether the dns sinkholes the names in the
+	// blocklists in its BlocklistsAnnotation annotation and whether its
+	// feeds have been fetched recently.  The condition is reported only if
+	// the dns has the annotation.
+	DNSBlocklistsCurrentConditionType = conditions.TypeBlocklistsCurrent
+
+	// blocklistKey is the key of the list in a blocklist configmap.
+	blocklistKey = "blocklist"
+	// blocklistFeedStatusAnnotation is the annotation on a dns's blocklist
+	// feeds configmap that records when the operator fetched each feed.
+	blocklistFeedStatusAnnotation = "dns.operator.openshift.io/blocklist-feed-status"
+
+	// defaultBlocklistRefreshInterval is how often the operator fetches a
+	// feed that does not set a refresh interval.
+	defaultBlocklistRefreshInterval = 24 * time.Hour
+	// minBlocklistRefreshInterval and maxBlocklistRefreshInterval bound
+	// the refresh interval of a feed.
+	minBlocklistRefreshInterval = 5 * time.Minute
+	maxBlocklistRefreshInterval = 7 * 24 * time.Hour
+	// blocklistFeedRetryPeriod is how long the operator waits to fetch a
+	// feed again after a fetch fails.
+	blocklistFeedRetryPeriod = 5 * time.Minute
+	// blocklistCheckPeriod is how often the operator checks whether the
+	// dns's feeds are due to be fetched.  Nothing else would reconcile the
+	// dns when a refresh interval elapses.
+	blocklistCheckPeriod = 5 * time.Minute
+	// blocklistFeedTimeout is how long the operator waits for a feed.
+	blocklistFeedTimeout = 30 * time.Second
+	// maxBlocklistFeedSize is the size in bytes of the largest feed that
+	// the operator accepts.
+	maxBlocklistFeedSize = 4 << 20
+	// maxBlocklistNames is the number of names that a dns sinkholes at
+	// most.  The names are in the dns's configmap, which, like any
+	// configmap, must be smaller than 1 MiB.
+	maxBlocklistNames = 10000
+)
+
+// blocklistFeedClient is the client with which the operator fetches blocklist
+// feeds.
+var blocklistFeedClient = &http.Client{Timeout: blocklistFeedTimeout}
+
+// blocklistSpec is an entry in the value of a dns's BlocklistsAnnotation
+// annotation.
+type blocklistSpec struct {
+	// Name is the name of the blocklist.
+	Name string `json:"name"`
+	// ConfigMap is the name of the configmap that has the list.
+	ConfigMap string `json:"configMap,omitempty"`
+	// URL is the URL of the feed that serves the list.
+	URL string `json:"url,omitempty"`
+	// RefreshInterval is how often the operator fetches the feed.
+	RefreshInterval string `json:"refreshInterval,omitempty"`
+}
+
+// blocklistSource is a validated blocklistSpec.
+type blocklistSource struct {
+	// Name is the name of the blocklist.
+	Name string
+	// ConfigMap is the name of the configmap that has the list, or empty
+	// if the list is a feed.
+	ConfigMap string
+	// URL is the URL of the feed, or empty if the list is in a configmap.
+	URL string
+	// RefreshInterval is how often the operator fetches the feed.
+	RefreshInterval time.Duration
+}
+
+// blocklistFeedStatus records the operator's fetches of a blocklist feed.
+type blocklistFeedStatus struct {
+	// URL is the URL from which the operator fetched the feed's names.
+	URL string `json:"url"`
+	// Fetched is when the operator last fetched the feed successfully.
+	Fetched time.Time `json:"fetched,omitempty"`
+	// Attempted is when the operator last tried to fetch the feed.
+	Attempted time.Time `json:"attempted"`
+	// Error is why the last attempt failed, if it did.
+	Error string `json:"error,omitempty"`
+	// Invalid is the number of invalid names in the feed's last
+	// successfully fetched list.
+	Invalid int `json:"invalid,omitempty"`
+}
+
+// blocklistFeeds is the content of a dns's blocklist feeds configmap.
+type blocklistFeeds struct {
+	// Names are the names that the operator last fetched from each feed,
+	// by the feed's blocklist name.
+	Names map[string][]string
+	// Status is the status of each feed, by the feed's blocklist name.
+	Status map[string]blocklistFeedStatus
+}
+
+// dnsBlocklist is the names that a dns sinkholes.
+type dnsBlocklist struct {
+	// Names are the sorted names, lowercase and without trailing dots.
+	Names []string
+}
+
+// Key returns the key of the blocklist in the dns's configmap.
+func (b *dnsBlocklist) Key() string {
+	return "blocklist.hosts"
+}
+
+// Data returns the blocklist in the format of /etc/hosts, which CoreDNS's hosts
+// plugin reads and rereads when it changes.
+func (b *dnsBlocklist) Data() string {
+	var data strings.Builder
+	for _, name := range b.Names {
+		data.WriteString("0.0.0.0 " + name + "\n")
+	}
+	return data.String()
+}
+
+// blocklistsEnabled returns a Boolean value indicating whether the given dns has
+// a non-empty BlocklistsAnnotation annotation.
+func blocklistsEnabled(dns *operatorv1.DNS) bool {
+	return len(strings.TrimSpace(dns.Annotations[BlocklistsAnnotation])) != 0
+}
+
+// blocklistConfigMapNames returns the names of the configmaps that the
+// blocklists in the given dns's BlocklistsAnnotation annotation name.
+func blocklistConfigMapNames(dns *operatorv1.DNS) sets.String {
+	names := sets.NewString()
+	var specs []blocklistSpec
+	if err := json.Unmarshal([]byte(dns.Annotations[BlocklistsAnnotation]), &specs); err != nil {
+		return names
+	}
+	for _, spec := range specs {
+		if len(spec.ConfigMap) != 0 {
+			names.Insert(spec.ConfigMap)
+		}
+	}
+	return names
+}
+
+// dnsBlocklist returns the names that the given dns sinkholes and a status
+// condition that reports them, or nil and a nil condition if the dns does not
+// have the BlocklistsAnnotation annotation.  The lists in configmaps are read
+// from the cache; the feeds that are due are fetched, and the dns's blocklist
+// feeds configmap is updated with their names.  The names that the given
+// servers, IdM DNS servers, zone files, secondary zones, or static hosts
+// answer, and names in the cluster domain, are not sinkholed, because the
+// default server block, which sinkholes the names, does not answer them or
+// answers them with the static hosts.
+func (r *reconciler) dnsBlocklist(dns *operatorv1.DNS, servers []operatorv1.Server, idmResolvers []idmResolver, zoneFiles []zoneFile, secondaryZones []secondaryZone, staticHosts []staticHost, clusterDomain string, now time.Time) (*dnsBlocklist, *operatorv1.OperatorCondition, error) {
+	if !blocklistsEnabled(dns) {
+		return nil, nil, r.ensureBlocklistFeedsDeleted(dns)
+	}
+	sources, problems := parseBlocklistSpecs(dns.Annotations[BlocklistsAnnotation])
+
+	feeds, err := r.currentBlocklistFeeds(dns)
+	if err != nil {
+		return nil, nil, err
+	}
+	var (
+		lists    [][]string
+		feedList []blocklistSource
+	)
+	for _, source := range sources {
+		if len(source.URL) != 0 {
+			refreshBlocklistFeed(source, &feeds, now, fetchBlocklistFeed)
+			feedList = append(feedList, source)
+			lists = append(lists, feeds.Names[source.Name])
+			if invalid := feeds.Status[source.Name].Invalid; invalid != 0 {
+				problems = append(problems, fmt.Sprintf("blocklist %s: %d names in the feed are not valid domain names", source.Name, invalid))
+			}
+			continue
+		}
+		name := types.NamespacedName{Namespace: DefaultOperandNamespace, Name: source.ConfigMap}
+		cm := &corev1.ConfigMap{}
+		if err := r.cache.Get(context.TODO(), name, cm); err != nil {
+			if errors.IsNotFound(err) {
+				problems = append(problems, fmt.Sprintf("blocklist %s: configmap %s does not exist", source.Name, source.ConfigMap))
+				continue
+			}
+			return nil, nil, fmt.Errorf("failed to get blocklist configmap %s/%s: %w", name.Namespace, name.Name, err)
+		}
+		data, ok := cm.Data[blocklistKey]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("blocklist %s: configmap %s has no %q key", source.Name, source.ConfigMap, blocklistKey))
+			continue
+		}
+		names, invalid := parseBlocklist(data)
+		if invalid != 0 {
+			problems = append(problems, fmt.Sprintf("blocklist %s: %d names in configmap %s are not valid domain names", source.Name, invalid, source.ConfigMap))
+		}
+		lists = append(lists, names)
+	}
+	pruneBlocklistFeeds(&feeds, feedList)
+	if len(feedList) == 0 {
+		if err := r.ensureBlocklistFeedsDeleted(dns); err != nil {
+			return nil, nil, err
+		}
+	} else if err := r.ensureBlocklistFeedsConfigMap(dns, feeds); err != nil {
+		return nil, nil, err
+	}
+
+	zones := otherServerZones(servers, idmResolvers)
+	for _, zone := range zoneFiles {
+		zones.Insert(normalizeZone(zone.Zone))
+	}
+	for _, zone := range secondaryZones {
+		zones.Insert(normalizeZone(zone.Zone))
+	}
+	hostNames := sets.NewString()
+	for _, host := range staticHosts {
+		hostNames.Insert(host.Names...)
+	}
+	names, excluded, total := mergeBlocklists(lists, clusterDomain, zones, hostNames)
+	if total > len(names)+excluded {
+		problems = append(problems, fmt.Sprintf("only %d of the %d names are sinkholed", len(names), total-excluded))
+	}
+
+	var stale []string
+	for _, feed := range feedList {
+		if reason, ok := blocklistFeedStale(feed, feeds.Status[feed.Name], now); ok {
+			stale = append(stale, fmt.Sprintf("feed %s %s", feed.Name, reason))
+		}
+	}
+	condition := computeBlocklistsCurrentCondition(len(sources), len(names), excluded, stale, problems)
+	if len(names) == 0 {
+		return nil, &condition, nil
+	}
+	return &dnsBlocklist{Names: names}, &condition, nil
+}
+
+// parseBlocklistSpecs parses the given value of a BlocklistsAnnotation
+// annotation and returns the valid blocklists, in order, and the problems with
+// the invalid blocklists, which are ignored.  A blocklist is invalid if its
+// name is not a valid label or is another blocklist's name, if it does not
+// have exactly one of a valid configmap name and an http or https URL, or if
+// it is a feed and its refresh interval is not valid.
+func parseBlocklistSpecs(value string) ([]blocklistSource, []string) {
+	var specs []blocklistSpec
+	if err := json.Unmarshal([]byte(value), &specs); err != nil {
+		return nil, []string{fmt.Sprintf("the annotation is not a JSON list of blocklists: %v", err)}
+	}
+	var (
+		sources  []blocklistSource
+		problems []string
+		seen     = sets.NewString()
+	)
+	for i, spec := range specs {
+		switch {
+		case len(spec.Name) == 0:
+			problems = append(problems, fmt.Sprintf("entry %d: name must be specified", i))
+			continue
+		case len(validation.IsDNS1123Label(spec.Name)) != 0:
+			problems = append(problems, fmt.Sprintf("blocklist %s: the name is not a valid label", spec.Name))
+			continue
+		case seen.Has(spec.Name):
+			problems = append(problems, fmt.Sprintf("blocklist %s: another entry has the name", spec.Name))
+			continue
+		case len(spec.ConfigMap) != 0 && len(spec.URL) != 0, len(spec.ConfigMap) == 0 && len(spec.URL) == 0:
+			problems = append(problems, fmt.Sprintf("blocklist %s: exactly one of configMap and url must be specified", spec.Name))
+			continue
+		}
+		seen.Insert(spec.Name)
+		source := blocklistSource{Name: spec.Name, ConfigMap: spec.ConfigMap, URL: spec.URL}
+		if len(spec.ConfigMap) != 0 {
+			if errs := validation.IsDNS1123Subdomain(spec.ConfigMap); len(errs) != 0 {
+				problems = append(problems, fmt.Sprintf("blocklist %s: %q is not a valid configmap name", spec.Name, spec.ConfigMap))
+				continue
+			}
+			sources = append(sources, source)
+			continue
+		}
+		if u, err := url.Parse(spec.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+			problems = append(problems, fmt.Sprintf("blocklist %s: %q is not an http or https URL", spec.Name, spec.URL))
+			continue
+		}
+		source.RefreshInterval = defaultBlocklistRefreshInterval
+		if len(spec.RefreshInterval) != 0 {
+			interval, err := time.ParseDuration(spec.RefreshInterval)
+			if err != nil || interval < minBlocklistRefreshInterval || interval > maxBlocklistRefreshInterval {
+				problems = append(problems, fmt.Sprintf("blocklist %s: refresh interval %q is not a duration between %s and %s", spec.Name, spec.RefreshInterval, minBlocklistRefreshInterval, maxBlocklistRefreshInterval))
+				continue
+			}
+			source.RefreshInterval = interval
+		}
+		sources = append(sources, source)
+	}
+	return sources, problems
+}
+
+// parseBlocklist parses the given list and returns its names, lowercase and
+// without trailing dots, and the number of invalid names, which are ignored.
+// Each line has one name or is an entry in the format of /etc/hosts, whose
+// address is ignored.  Text from "#" to the end of a line is a comment.
+// Addresses and single-label names, such as the "localhost" entries of hosts
+// files, are skipped.
+func parseBlocklist(data string) ([]string, int) {
+	var (
+		names   []string
+		invalid int
+	)
+	for _, line := range strings.Split(data, "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) > 1 {
+			// Hosts files have link-local addresses with zones,
+			// such as "fe80::1%lo0".
+			address := fields[0]
+			if i := strings.Index(address, "%"); i >= 0 {
+				address = address[:i]
+			}
+			if net.ParseIP(address) == nil {
+				invalid += len(fields)
+				continue
+			}
+			fields = fields[1:]
+		}
+		for _, field := range fields {
+			name := normalizeZone(field)
+			switch {
+			case net.ParseIP(field) != nil:
+			case !validDomainName(name):
+				invalid++
+			case strings.Count(name, ".") < 2:
+			default:
+				names = append(names, strings.TrimSuffix(name, "."))
+			}
+		}
+	}
+	return names, invalid
+}
+
+// mergeBlocklists returns the sorted names in the given lists without
+// duplicates, at most maxBlocklistNames of them, the number of names that are
+// excluded because they are in the given cluster domain or zones, which are
+// normalized with normalizeZone, or are one of the given static host names,
+// and the number of distinct names in the lists.
+func mergeBlocklists(lists [][]string, clusterDomain string, zones, hostNames sets.String) ([]string, int, int) {
+	all := sets.NewString()
+	for _, list := range lists {
+		all.Insert(list...)
+	}
+	clusterDomain = normalizeZone(clusterDomain)
+	var (
+		names    []string
+		excluded int
+	)
+	for _, name := range all.List() {
+		fqdn := name + "."
+		if nameInZone(fqdn, clusterDomain) || nameInAnyZone(fqdn, zones) || hostNames.Has(name) {
+			excluded++
+			continue
+		}
+		if len(names) < maxBlocklistNames {
+			names = append(names, name)
+		}
+	}
+	return names, excluded, all.Len()
+}
+
+// refreshBlocklistFeed fetches the given feed with the given function and
+// records the result in the given feeds if the feed's refresh interval has
+// elapsed since it was last fetched, if the retry period has elapsed since a
+// fetch failed, or if the feed has never been fetched from its URL.  The feed
+// keeps the names of its last successful fetch from the same URL until a fetch
+// succeeds.
+func refreshBlocklistFeed(feed blocklistSource, feeds *blocklistFeeds, now time.Time, fetch func(string) (string, error)) {
+	status, ok := feeds.Status[feed.Name]
+	switch {
+	case !ok || status.URL != feed.URL:
+		status = blocklistFeedStatus{URL: feed.URL}
+		delete(feeds.Names, feed.Name)
+	case len(status.Error) != 0 && now.Sub(status.Attempted) < blocklistFeedRetryPeriod:
+		return
+	case len(status.Error) == 0 && now.Sub(status.Fetched) < feed.RefreshInterval:
+		return
+	}
+	status.Attempted = now
+	data, err := fetch(feed.URL)
+	if err != nil {
+		status.Error = err.Error()
+		logrus.Infof("failed to fetch blocklist feed %s from %s: %v", feed.Name, feed.URL, err)
+	} else {
+		names, invalid := parseBlocklist(data)
+		status.Fetched, status.Error, status.Invalid = now, "", invalid
+		feeds.Names[feed.Name] = names
+		logrus.Infof("fetched %d names for blocklist feed %s from %s", len(names), feed.Name, feed.URL)
+	}
+	feeds.Status[feed.Name] = status
+}
+
+// pruneBlocklistFeeds removes the feeds other than the given ones from the
+// given feeds.
+func pruneBlocklistFeeds(feeds *blocklistFeeds, current []blocklistSource) {
+	names := sets.NewString()
+	for _, feed := range current {
+		names.Insert(feed.Name)
+	}
+	for name := range feeds.Status {
+		if !names.Has(name) {
+			delete(feeds.Status, name)
+		}
+	}
+	for name := range feeds.Names {
+		if !names.Has(name) {
+			delete(feeds.Names, name)
+		}
+	}
+}
+
+// blocklistFeedStale returns why the given feed with the given status is stale
+// and true if it has not been fetched from its URL or was last fetched more
+// than twice its refresh interval before the given time.
+func blocklistFeedStale(feed blocklistSource, status blocklistFeedStatus, now time.Time) (string, bool) {
+	lastError := ""
+	if len(status.Error) != 0 {
+		lastError = ": " + status.Error
+	}
+	switch {
+	case status.URL != feed.URL || status.Fetched.IsZero():
+		return "has not been fetched" + lastError, true
+	case now.Sub(status.Fetched) > 2*feed.RefreshInterval:
+		return fmt.Sprintf("was last fetched at %s", status.Fetched.UTC().Format(time.RFC3339)) + lastError, true
+	}
+	return "", false
+}
+
+// fetchBlocklistFeed returns the list that the feed at the given URL serves.
+func fetchBlocklistFeed(feedURL string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.TODO(), blocklistFeedTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feedURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := blocklistFeedClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("the feed responded with %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBlocklistFeedSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > maxBlocklistFeedSize {
+		return "", fmt.Errorf("the feed is larger than %d bytes", maxBlocklistFeedSize)
+	}
+	return string(data), nil
+}
+
+// currentBlocklistFeeds returns the content of the given dns's blocklist feeds
+// configmap, which is empty if the configmap does not exist.
+func (r *reconciler) currentBlocklistFeeds(dns *operatorv1.DNS) (blocklistFeeds, error) {
+	feeds := blocklistFeeds{
+		Names:  map[string][]string{},
+		Status: map[string]blocklistFeedStatus{},
+	}
+	cm := &corev1.ConfigMap{}
+	name := DNSBlocklistFeedsConfigMapName(dns)
+	if err := r.client.Get(context.TODO(), name, cm); err != nil {
+		if errors.IsNotFound(err) {
+			return feeds, nil
+		}
+		return feeds, fmt.Errorf("failed to get blocklist feeds configmap: %w", err)
+	}
+	if value, ok := cm.Annotations[blocklistFeedStatusAnnotation]; ok {
+		if err := json.Unmarshal([]byte(value), &feeds.Status); err != nil {
+			// Refetch the feeds rather than keep names whose
+			// origin is unknown.
+			logrus.Infof("ignoring invalid feed status in blocklist feeds configmap %s/%s: %v", cm.Namespace, cm.Name, err)
+			feeds.Status = map[string]blocklistFeedStatus{}
+			return feeds, nil
+		}
+	}
+	for key, data := range cm.Data {
+		feeds.Names[key] = strings.Fields(data)
+	}
+	return feeds, nil
+}
+
+// ensureBlocklistFeedsConfigMap ensures that the blocklist feeds configmap for
+// the given dns has the given feeds.
+func (r *reconciler) ensureBlocklistFeedsConfigMap(dns *operatorv1.DNS, feeds blocklistFeeds) error {
+	status, err := json.Marshal(feeds.Status)
+	if err != nil {
+		return fmt.Errorf("failed to encode blocklist feed status for dns %s: %w", dns.Name, err)
+	}
+	name := DNSBlocklistFeedsConfigMapName(dns)
+	desired := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name.Name,
+			Namespace: name.Namespace,
+			Labels: map[string]string{
+				manifests.OwningDNSLabel: DNSDaemonSetLabel(dns),
+			},
+			Annotations: map[string]string{
+				blocklistFeedStatusAnnotation: string(status),
+			},
+		},
+		Data: map[string]string{},
+	}
+	for feed, names := range feeds.Names {
+		desired.Data[feed] = strings.Join(names, "\n")
+	}
+	desired.SetOwnerReferences([]metav1.OwnerReference{dnsOwnerRef(dns)})
+
+	current := &corev1.ConfigMap{}
+	if err := r.client.Get(context.TODO(), name, current); err != nil {
+		if !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to get blocklist feeds configmap: %w", err)
+		}
+		if err := r.client.Create(context.TODO(), desired); err != nil {
+			return fmt.Errorf("failed to create blocklist feeds configmap: %w", err)
+		}
+		logrus.Infof("created blocklist feeds configmap %s/%s", desired.Namespace, desired.Name)
+		return nil
+	}
+	if current.Annotations[blocklistFeedStatusAnnotation] == desired.Annotations[blocklistFeedStatusAnnotation] && (len(current.Data) == 0 && len(desired.Data) == 0 || reflect.DeepEqual(current.Data, desired.Data)) {
+		return nil
+	}
+	updated := current.DeepCopy()
+	if updated.Annotations == nil {
+		updated.Annotations = map[string]string{}
+	}
+	updated.Annotations[blocklistFeedStatusAnnotation] = desired.Annotations[blocklistFeedStatusAnnotation]
+	updated.Data = desired.Data
+	if err := r.client.Update(context.TODO(), updated); err != nil {
+		return fmt.Errorf("failed to update blocklist feeds configmap: %w", err)
+	}
+	logrus.Infof("updated blocklist feeds configmap %s/%s", updated.Namespace, updated.Name)
+	return nil
+}
+
+// ensureBlocklistFeedsDeleted deletes the blocklist feeds configmap of the
+// given dns, which no longer has feeds, if it exists.
+func (r *reconciler) ensureBlocklistFeedsDeleted(dns *operatorv1.DNS) error {
+	cm := &corev1.ConfigMap{}
+	if err := r.cache.Get(context.TODO(), DNSBlocklistFeedsConfigMapName(dns), cm); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to get blocklist feeds configmap: %w", err)
+	}
+	if err := r.client.Delete(context.TODO(), cm); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete blocklist feeds configmap: %w", err)
+	}
+	logrus.Infof("deleted blocklist feeds configmap %s/%s", cm.Namespace, cm.Name)
+	return nil
+}
+
+// dnsesForBlocklistConfigMap returns reconcile requests for the dnses whose
+// BlocklistsAnnotation annotation names the given configmap.
+func (r *reconciler) dnsesForBlocklistConfigMap(o client.Object) []reconcile.Request {
+	dnses := &operatorv1.DNSList{}
+	if err := r.cache.List(context.TODO(), dnses); err != nil {
+		logrus.Errorf("failed to list dnses for configmap %s/%s: %v", o.GetNamespace(), o.GetName(), err)
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range dnses.Items {
+		if blocklistConfigMapNames(&dnses.Items[i]).Has(o.GetName()) {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: dnses.Items[i].Name}})
+		}
+	}
+	return requests
+}
+
+// computeBlocklistsCurrentCondition returns a status condition that reports the
+// given number of blocklists and of the names that are sinkholed and excluded,
+// the given stale feeds, and the given problems with the blocklists and names
+// that were ignored.  Stale feeds take precedence over ignored blocklists.
+func computeBlocklistsCurrentCondition(lists, names, excluded int, stale, problems []string) operatorv1.OperatorCondition {
+	condition := operatorv1.OperatorCondition{
+		Type: DNSBlocklistsCurrentConditionType,
+	}
+	summary := fmt.Sprintf("Sinkholing %d names from %d blocklists", names, lists)
+	if excluded != 0 {
+		summary += fmt.Sprintf("; %d names are not sinkholed because other server blocks, the cluster domain, or static hosts answer them", excluded)
+	}
+	switch {
+	case len(stale) != 0:
+		condition.Status = operatorv1.ConditionFalse
+		condition.Reason = conditions.ReasonStaleBlocklistFeeds
+		condition.Message = fmt.Sprintf("Some blocklist feeds are stale: %s.  %s.", strings.Join(stale, "; "), summary)
+		if len(problems) != 0 {
+			condition.Message += fmt.Sprintf("  Some blocklists were ignored: %s.", strings.Join(problems, "; "))
+		}
+	case len(problems) != 0:
+		condition.Status = operatorv1.ConditionFalse
+		condition.Reason = conditions.ReasonBlocklistsIgnored
+		condition.Message = fmt.Sprintf("Some blocklists were ignored: %s.  %s.", strings.Join(problems, "; "), summary)
+	default:
+		condition.Status = operatorv1.ConditionTrue
+		condition.Reason = conditions.ReasonAsExpected
+		condition.Message = summary + "."
+	}
+	return condition
+}
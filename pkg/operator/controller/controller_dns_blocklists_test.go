@@ -0,0 +1,230 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// TestParseBlocklistSpecs verifies that parseBlocklistSpecs returns the valid
+// blocklists, in order, with their refresh intervals, and ignores invalid
+// blocklists.
+func TestParseBlocklistSpecs(t *testing.T) {
+	value := `[
+		{"name": "corp", "configMap": "corp-blocklist"},
+		{"name": "malware", "url": "https://feeds.example.com/malware.txt", "refreshInterval": "6h"},
+		{"name": "ads", "url": "http://feeds.example.com/ads.txt"},
+		{"name": "corp", "configMap": "other-blocklist"},
+		{"name": "both", "configMap": "both", "url": "https://feeds.example.com/both.txt"},
+		{"name": "ftp", "url": "ftp://feeds.example.com/list.txt"},
+		{"name": "fast", "url": "https://feeds.example.com/fast.txt", "refreshInterval": "1m"},
+		{"name": "Bad_Name", "configMap": "bad"},
+		{"configMap": "unnamed"}
+	]`
+	sources, problems := parseBlocklistSpecs(value)
+	expected := []blocklistSource{
+		{Name: "corp", ConfigMap: "corp-blocklist"},
+		{Name: "malware", URL: "https://feeds.example.com/malware.txt", RefreshInterval: 6 * time.Hour},
+		{Name: "ads", URL: "http://feeds.example.com/ads.txt", RefreshInterval: defaultBlocklistRefreshInterval},
+	}
+	if !reflect.DeepEqual(sources, expected) {
+		t.Errorf("expected %#v, got %#v", expected, sources)
+	}
+	expectedProblems := []string{
+		"blocklist corp: another entry has the name",
+		"blocklist both: exactly one of configMap and url must be specified",
+		`blocklist ftp: "ftp://feeds.example.com/list.txt" is not an http or https URL`,
+		`blocklist fast: refresh interval "1m" is not a duration between 5m0s and 168h0m0s`,
+		"blocklist Bad_Name: the name is not a valid label",
+		"entry 8: name must be specified",
+	}
+	if !reflect.DeepEqual(problems, expectedProblems) {
+		t.Errorf("expected problems %q, got %q", expectedProblems, problems)
+	}
+}
+
+// TestParseBlocklist verifies that parseBlocklist accepts one name per line and
+// hosts files, skips addresses and single-label names, and counts invalid
+// names.
+func TestParseBlocklist(t *testing.T) {
+	data := `# Hosts file header
+127.0.0.1 localhost
+::1 localhost
+fe80::1%lo0 localhost
+0.0.0.0 0.0.0.0
+0.0.0.0 Ads.Example.com tracker.example.net.  # two names
+Malware.example.org
+bad_name.example.com
+||adblock.example.com^
+`
+	names, invalid := parseBlocklist(data)
+	expected := []string{"ads.example.com", "tracker.example.net", "malware.example.org"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %q, got %q", expected, names)
+	}
+	if invalid != 2 {
+		t.Errorf("expected 2 invalid names, got %d", invalid)
+	}
+}
+
+// TestMergeBlocklists verifies that mergeBlocklists deduplicates and sorts the
+// names and excludes names that the default server block does not answer.
+func TestMergeBlocklists(t *testing.T) {
+	lists := [][]string{
+		{"tracker.example.net", "ads.example.com", "api.svc.cluster.local"},
+		{"ads.example.com", "www.corp.example.com", "registry.example.com"},
+	}
+	names, excluded, total := mergeBlocklists(lists, "cluster.local", sets.NewString("corp.example.com."), sets.NewString("registry.example.com"))
+	if expected := []string{"ads.example.com", "tracker.example.net"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %q, got %q", expected, names)
+	}
+	if excluded != 3 || total != 5 {
+		t.Errorf("expected 3 excluded of 5 names, got %d of %d", excluded, total)
+	}
+
+	var many []string
+	for i := 0; i < maxBlocklistNames+1; i++ {
+		many = append(many, fmt.Sprintf("host%d.example.com", i))
+	}
+	names, _, total = mergeBlocklists([][]string{many}, "cluster.local", sets.NewString(), sets.NewString())
+	if len(names) != maxBlocklistNames || total != maxBlocklistNames+1 {
+		t.Errorf("expected %d of %d names, got %d of %d", maxBlocklistNames, maxBlocklistNames+1, len(names), total)
+	}
+}
+
+// TestRefreshBlocklistFeed verifies that refreshBlocklistFeed fetches a feed
+// only when it is due, keeps the feed's names when a fetch fails, and that the
+// feed becomes stale when it has not been fetched within twice its refresh
+// interval.
+func TestRefreshBlocklistFeed(t *testing.T) {
+	feed := blocklistSource{Name: "malware", URL: "https://feeds.example.com/malware.txt", RefreshInterval: time.Hour}
+	feeds := blocklistFeeds{Names: map[string][]string{}, Status: map[string]blocklistFeedStatus{}}
+	start := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	var (
+		fetches int
+		fail    bool
+	)
+	fetch := func(url string) (string, error) {
+		fetches++
+		if fail {
+			return "", fmt.Errorf("connection refused")
+		}
+		return "bad.example.com\n", nil
+	}
+
+	testCases := []struct {
+		description     string
+		now             time.Time
+		fail            bool
+		expectedFetches int
+		expectedStale   bool
+	}{
+		{"the first reconcile fetches the feed", start, false, 1, false},
+		{"the feed is not due", start.Add(30 * time.Minute), false, 1, false},
+		{"the refresh interval elapsed but the fetch fails", start.Add(time.Hour), true, 2, false},
+		{"the retry period has not elapsed", start.Add(time.Hour + time.Minute), true, 2, false},
+		{"the retry fails and the feed is stale", start.Add(2*time.Hour + time.Minute), true, 3, true},
+		{"a retry succeeds", start.Add(2*time.Hour + 10*time.Minute), false, 4, false},
+	}
+	for _, tc := range testCases {
+		fail = tc.fail
+		refreshBlocklistFeed(feed, &feeds, tc.now, fetch)
+		if fetches != tc.expectedFetches {
+			t.Errorf("%s: expected %d fetches, got %d", tc.description, tc.expectedFetches, fetches)
+		}
+		if expected := []string{"bad.example.com"}; !reflect.DeepEqual(feeds.Names[feed.Name], expected) {
+			t.Errorf("%s: expected names %q, got %q", tc.description, expected, feeds.Names[feed.Name])
+		}
+		if reason, stale := blocklistFeedStale(feed, feeds.Status[feed.Name], tc.now); stale != tc.expectedStale {
+			t.Errorf("%s: expected stale to be %t, got %t (%s)", tc.description, tc.expectedStale, stale, reason)
+		}
+	}
+
+	// A new URL discards the names from the old one.
+	fail = true
+	feed.URL = "https://mirror.example.com/malware.txt"
+	now := start.Add(3 * time.Hour)
+	refreshBlocklistFeed(feed, &feeds, now, fetch)
+	if len(feeds.Names[feed.Name]) != 0 {
+		t.Errorf("expected no names from the new URL, got %q", feeds.Names[feed.Name])
+	}
+	if reason, stale := blocklistFeedStale(feed, feeds.Status[feed.Name], now); !stale || reason != "has not been fetched: connection refused" {
+		t.Errorf("expected the feed to be stale because it has not been fetched, got %t (%s)", stale, reason)
+	}
+}
+
+// TestFetchBlocklistFeed verifies that fetchBlocklistFeed returns the feed's
+// list and fails for error responses.
+func TestFetchBlocklistFeed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/malware.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintln(w, "bad.example.com")
+	}))
+	defer server.Close()
+
+	if data, err := fetchBlocklistFeed(server.URL + "/malware.txt"); err != nil || data != "bad.example.com\n" {
+		t.Errorf("expected the list, got %q (%v)", data, err)
+	}
+	if _, err := fetchBlocklistFeed(server.URL + "/missing.txt"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("expected a 404 error, got %v", err)
+	}
+}
+
+// TestComputeBlocklistsCurrentCondition verifies that stale feeds take
+// precedence over ignored blocklists.
+func TestComputeBlocklistsCurrentCondition(t *testing.T) {
+	condition := computeBlocklistsCurrentCondition(2, 10, 1, nil, nil)
+	if condition.Status != operatorv1.ConditionTrue || condition.Reason != conditions.ReasonAsExpected {
+		t.Errorf("expected a true condition, got %#v", condition)
+	}
+	condition = computeBlocklistsCurrentCondition(2, 10, 0, nil, []string{"blocklist corp: configmap corp-blocklist does not exist"})
+	if condition.Status != operatorv1.ConditionFalse || condition.Reason != conditions.ReasonBlocklistsIgnored {
+		t.Errorf("expected a false condition with reason %s, got %#v", conditions.ReasonBlocklistsIgnored, condition)
+	}
+	condition = computeBlocklistsCurrentCondition(2, 10, 0, []string{"feed malware has not been fetched"}, []string{"blocklist corp: configmap corp-blocklist does not exist"})
+	if condition.Status != operatorv1.ConditionFalse || condition.Reason != conditions.ReasonStaleBlocklistFeeds || !strings.Contains(condition.Message, "corp-blocklist") {
+		t.Errorf("expected a false condition with reason %s that reports the ignored blocklist, got %#v", conditions.ReasonStaleBlocklistFeeds, condition)
+	}
+}
+
+// TestDesiredDNSConfigMapBlocklist verifies that the default server block's
+// hosts plugin reads the blocklist from the dns's configmap in addition to the
+// static hosts.
+func TestDesiredDNSConfigMapBlocklist(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DefaultDNSController,
+		},
+	}
+	hosts := []staticHost{{IP: "10.0.0.10", Names: []string{"registry.example.com"}}}
+	blocklist := &dnsBlocklist{Names: []string{"ads.example.com", "tracker.example.net"}}
+	cm, err := desiredDNSConfigMap(dns, corefileInputs{StaticHosts: hosts, Blocklist: blocklist, ClusterDomain: "cluster.local", MetricsAddress: "127.0.0.1:9153"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `    ready
+    hosts /etc/coredns/blocklist.hosts {
+        10.0.0.10 registry.example.com
+        fallthrough
+    }
+    kubernetes cluster.local in-addr.arpa ip6.arpa {`
+	if !strings.Contains(cm.Data["Corefile"], expected) {
+		t.Errorf("expected Corefile to contain:\n%s\ngot:\n%s", expected, cm.Data["Corefile"])
+	}
+	if expected := "0.0.0.0 ads.example.com\n0.0.0.0 tracker.example.net\n"; cm.Data["blocklist.hosts"] != expected {
+		t.Errorf("expected blocklist %q, got %q", expected, cm.Data["blocklist.hosts"])
+	}
+}
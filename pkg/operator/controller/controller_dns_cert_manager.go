@@ -0,0 +1,191 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// DNSMetricsCertificateIssuedConditionType is the type of the DNS
+	// status condition that indicates whether cert-manager has issued the
+	// metrics serving certificate that the MetricsCertificateIssuerAnnotation
+	// annotation requests.  The condition is reported only if the
+	// annotation is set.
+	DNSMetricsCertificateIssuedConditionType = conditions.TypeMetricsCertificateIssued
+
+	// metricsCertificateRevisionAnnotation is the annotation on the dns
+	// pod template with the revision of the metrics serving certificate
+	// that cert-manager issued, so that the pods restart and load the
+	// certificate when cert-manager renews it.
+	metricsCertificateRevisionAnnotation = "dns.operator.openshift.io/metrics-certificate-revision"
+
+	// certManagerGroup is the API group of cert-manager's resources.
+	certManagerGroup = "cert-manager.io"
+)
+
+// certificateGVK is the group, version, and kind of cert-manager's Certificate
+// resource.  The operator uses unstructured objects for Certificate resources
+// so that it does not depend on cert-manager's API.
+var certificateGVK = schema.GroupVersionKind{
+	Group:   certManagerGroup,
+	Version: "v1",
+	Kind:    "Certificate",
+}
+
+// metricsCertificateIssuer returns the kind and name of the cert-manager issuer
+// in the given dns's MetricsCertificateIssuerAnnotation annotation and a
+// Boolean value that is false if the annotation is not set or is invalid.  The
+// kind is "Issuer", for an issuer in the operand namespace, unless the value
+// has the prefix "ClusterIssuer/".
+func metricsCertificateIssuer(dns *operatorv1.DNS) (string, string, bool) {
+	value := strings.TrimSpace(dns.Annotations[MetricsCertificateIssuerAnnotation])
+	if len(value) == 0 {
+		return "", "", false
+	}
+	kind, name := "Issuer", value
+	if i := strings.Index(value, "/"); i >= 0 {
+		kind, name = value[:i], value[i+1:]
+	}
+	if (kind != "Issuer" && kind != "ClusterIssuer") || len(name) == 0 {
+		logrus.Warningf("ignoring invalid value %q for annotation %s on dns %s: the value must be <name>, Issuer/<name>, or ClusterIssuer/<name>", value, MetricsCertificateIssuerAnnotation, dns.Name)
+		return "", "", false
+	}
+	return kind, name, true
+}
+
+// ensureDNSMetricsCertificate ensures that the cert-manager Certificate for the
+// given dns's metrics serving certificate exists if the dns names an issuer
+// and does not exist otherwise.  If the dns names an issuer,
+// ensureDNSMetricsCertificate returns the revision of the issued certificate,
+// or the empty string if none has been issued, and a status condition that
+// describes the certificate; otherwise, it returns a nil condition.
+func (r *reconciler) ensureDNSMetricsCertificate(dns *operatorv1.DNS) (string, *operatorv1.OperatorCondition, error) {
+	name := DNSMetricsCertificateName(dns)
+	current := &unstructured.Unstructured{}
+	current.SetGroupVersionKind(certificateGVK)
+	haveCertificate := true
+	kind, issuer, enabled := metricsCertificateIssuer(dns)
+	if err := r.client.Get(context.TODO(), name, current); err != nil {
+		switch {
+		case meta.IsNoMatchError(err):
+			// cert-manager is not installed.
+			if !enabled {
+				return "", nil, nil
+			}
+			return "", &operatorv1.OperatorCondition{
+				Type:    DNSMetricsCertificateIssuedConditionType,
+				Status:  operatorv1.ConditionFalse,
+				Reason:  conditions.ReasonCertManagerUnavailable,
+				Message: "The cluster does not have the Certificate API; cert-manager may not be installed.",
+			}, nil
+		case errors.IsNotFound(err):
+			haveCertificate = false
+		default:
+			return "", nil, fmt.Errorf("failed to get certificate %s: %w", name, err)
+		}
+	}
+
+	if !enabled {
+		if !haveCertificate {
+			return "", nil, nil
+		}
+		if err := r.client.Delete(context.TODO(), current); err != nil && !errors.IsNotFound(err) {
+			return "", nil, fmt.Errorf("failed to delete certificate %s: %w", name, err)
+		}
+		logrus.Infof("deleted certificate %s", name)
+		return "", nil, nil
+	}
+
+	desired := desiredDNSMetricsCertificate(dns, kind, issuer)
+	switch {
+	case !haveCertificate:
+		if err := r.client.Create(context.TODO(), desired); err != nil {
+			return "", nil, fmt.Errorf("failed to create certificate %s: %w", name, err)
+		}
+		logrus.Infof("created certificate %s", name)
+		current = desired
+	case !reflect.DeepEqual(current.Object["spec"], desired.Object["spec"]):
+		updated := current.DeepCopy()
+		updated.Object["spec"] = desired.Object["spec"]
+		if err := r.client.Update(context.TODO(), updated); err != nil {
+			return "", nil, fmt.Errorf("failed to update certificate %s: %w", name, err)
+		}
+		logrus.Infof("updated certificate %s", name)
+		current = updated
+	}
+
+	revision, condition := certificateStatus(current)
+	condition.Type = DNSMetricsCertificateIssuedConditionType
+	return revision, &condition, nil
+}
+
+// desiredDNSMetricsCertificate returns the desired cert-manager Certificate for
+// the given dns's metrics serving certificate, which the issuer with the given
+// kind and name issues for the names of the dns's service.  cert-manager
+// stores the certificate in a secret with the Certificate's name.
+func desiredDNSMetricsCertificate(dns *operatorv1.DNS, kind, issuer string) *unstructured.Unstructured {
+	name := DNSMetricsCertificateName(dns)
+	svc := DNSServiceName(dns)
+	certificate := &unstructured.Unstructured{}
+	certificate.SetGroupVersionKind(certificateGVK)
+	certificate.SetNamespace(name.Namespace)
+	certificate.SetName(name.Name)
+	certificate.SetOwnerReferences([]metav1.OwnerReference{dnsOwnerRef(dns)})
+	certificate.Object["spec"] = map[string]interface{}{
+		"secretName": name.Name,
+		"commonName": svc.Name + "." + svc.Namespace + ".svc",
+		"dnsNames": []interface{}{
+			svc.Name + "." + svc.Namespace + ".svc",
+			svc.Name + "." + svc.Namespace + ".svc.cluster.local",
+		},
+		"issuerRef": map[string]interface{}{
+			"group": certManagerGroup,
+			"kind":  kind,
+			"name":  issuer,
+		},
+	}
+	return certificate
+}
+
+// certificateStatus returns the revision of the given cert-manager Certificate,
+// or the empty string if it has not been issued, and a status condition, without
+// a type, that reports the Certificate's Ready condition.
+func certificateStatus(certificate *unstructured.Unstructured) (string, operatorv1.OperatorCondition) {
+	revision := ""
+	if n, ok, _ := unstructured.NestedInt64(certificate.Object, "status", "revision"); ok {
+		revision = fmt.Sprintf("%d", n)
+	}
+	condition := operatorv1.OperatorCondition{
+		Status:  operatorv1.ConditionFalse,
+		Reason:  conditions.ReasonCertificateNotReady,
+		Message: fmt.Sprintf("cert-manager has not issued certificate %s/%s yet.", certificate.GetNamespace(), certificate.GetName()),
+	}
+	statusConditions, _, _ := unstructured.NestedSlice(certificate.Object, "status", "conditions")
+	for _, c := range statusConditions {
+		c, ok := c.(map[string]interface{})
+		if !ok || c["type"] != "Ready" {
+			continue
+		}
+		if c["status"] == "True" {
+			condition.Status = operatorv1.ConditionTrue
+			condition.Reason = conditions.ReasonAsExpected
+			condition.Message = fmt.Sprintf("cert-manager issued certificate %s/%s (revision %s).", certificate.GetNamespace(), certificate.GetName(), revision)
+		} else if message, ok := c["message"].(string); ok && len(message) != 0 {
+			condition.Message = fmt.Sprintf("Certificate %s/%s is not ready: %s", certificate.GetNamespace(), certificate.GetName(), message)
+		}
+	}
+	return revision, condition
+}
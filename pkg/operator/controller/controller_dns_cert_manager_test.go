@@ -0,0 +1,127 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// TestMetricsCertificateIssuer verifies that metricsCertificateIssuer parses
+// the issuer kind and name and that the metrics serving certificate secret
+// defaults to the secret that cert-manager issues.
+func TestMetricsCertificateIssuer(t *testing.T) {
+	testCases := []struct {
+		annotation   string
+		expectedKind string
+		expectedName string
+		expectedOK   bool
+	}{
+		{"", "", "", false},
+		{"ca-issuer", "Issuer", "ca-issuer", true},
+		{"Issuer/ca-issuer", "Issuer", "ca-issuer", true},
+		{" ClusterIssuer/letsencrypt ", "ClusterIssuer", "letsencrypt", true},
+		{"ClusterIssuer/", "", "", false},
+		{"Secret/ca-issuer", "", "", false},
+	}
+	for _, tc := range testCases {
+		dns := &operatorv1.DNS{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        DefaultDNSController,
+				Annotations: map[string]string{MetricsCertificateIssuerAnnotation: tc.annotation},
+			},
+		}
+		kind, name, ok := metricsCertificateIssuer(dns)
+		if kind != tc.expectedKind || name != tc.expectedName || ok != tc.expectedOK {
+			t.Errorf("%q: expected (%q, %q, %t), got (%q, %q, %t)", tc.annotation, tc.expectedKind, tc.expectedName, tc.expectedOK, kind, name, ok)
+		}
+		expectedSecret := ""
+		if tc.expectedOK {
+			expectedSecret = "dns-default-metrics-certificate"
+		}
+		if actual := customMetricsSecretName(dns); actual != expectedSecret {
+			t.Errorf("%q: expected metrics secret %q, got %q", tc.annotation, expectedSecret, actual)
+		}
+	}
+}
+
+// TestDesiredDNSMetricsCertificate verifies that the Certificate requests a
+// certificate for the dns service's names from the given issuer.
+func TestDesiredDNSMetricsCertificate(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DefaultDNSController,
+		},
+	}
+	certificate := desiredDNSMetricsCertificate(dns, "ClusterIssuer", "letsencrypt")
+	if certificate.GetNamespace() != "openshift-dns" || certificate.GetName() != "dns-default-metrics-certificate" {
+		t.Errorf("unexpected name %s/%s", certificate.GetNamespace(), certificate.GetName())
+	}
+	expected := map[string]interface{}{
+		"secretName": "dns-default-metrics-certificate",
+		"commonName": "dns-default.openshift-dns.svc",
+		"dnsNames": []interface{}{
+			"dns-default.openshift-dns.svc",
+			"dns-default.openshift-dns.svc.cluster.local",
+		},
+		"issuerRef": map[string]interface{}{
+			"group": "cert-manager.io",
+			"kind":  "ClusterIssuer",
+			"name":  "letsencrypt",
+		},
+	}
+	if !reflect.DeepEqual(certificate.Object["spec"], expected) {
+		t.Errorf("expected spec %v, got %v", expected, certificate.Object["spec"])
+	}
+}
+
+// TestCertificateStatus verifies that certificateStatus reports the
+// Certificate's revision and Ready condition.
+func TestCertificateStatus(t *testing.T) {
+	certificate := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	certificate.SetNamespace("openshift-dns")
+	certificate.SetName("dns-default-metrics-certificate")
+	if revision, condition := certificateStatus(certificate); revision != "" || condition.Status != operatorv1.ConditionFalse || condition.Reason != conditions.ReasonCertificateNotReady {
+		t.Errorf("expected no revision and a CertificateNotReady condition, got %q and %+v", revision, condition)
+	}
+
+	certificate.Object["status"] = map[string]interface{}{
+		"revision": int64(3),
+		"conditions": []interface{}{
+			map[string]interface{}{"type": "Issuing", "status": "True"},
+			map[string]interface{}{"type": "Ready", "status": "True"},
+		},
+	}
+	if revision, condition := certificateStatus(certificate); revision != "3" || condition.Status != operatorv1.ConditionTrue {
+		t.Errorf("expected revision 3 and a True condition, got %q and %+v", revision, condition)
+	}
+}
+
+// TestDesiredDNSDaemonSetMetricsCertificateRevision verifies that the pod
+// template records the revision of the metrics serving certificate so that
+// renewing the certificate restarts the pods.
+func TestDesiredDNSDaemonSetMetricsCertificateRevision(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DefaultDNSController,
+		},
+	}
+	current, err := desiredDNSDaemonSet(dns, "coredns", "kube-rbac-proxy", "", "dns-default-metrics-certificate", "1", nil, nil, nil, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := current.Spec.Template.Annotations[metricsCertificateRevisionAnnotation]; actual != "1" {
+		t.Errorf("expected revision annotation %q, got %q", "1", actual)
+	}
+	desired, err := desiredDNSDaemonSet(dns, "coredns", "kube-rbac-proxy", "", "dns-default-metrics-certificate", "2", nil, nil, nil, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed, _ := daemonsetConfigChanged(current, desired); !changed {
+		t.Errorf("expected a renewed certificate to change the daemonset")
+	}
+}
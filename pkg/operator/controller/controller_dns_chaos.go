@@ -0,0 +1,171 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// DNSChaosTestModeConditionType is the type of the DNS status
+	// condition that indicates that the operator's chaos hooks are enabled
+	// and describes the failures that the DNS's annotations inject.  The
+	// condition is reported only if the chaos hooks are enabled.
+	DNSChaosTestModeConditionType = conditions.TypeChaosTestMode
+
+	// chaosBlackholeUpstream is the upstream that replaces the upstreams
+	// of blackholed servers.  It is in TEST-NET-1 (RFC 5737), which is
+	// never routed, so queries to it time out.
+	chaosBlackholeUpstream = "192.0.2.1"
+)
+
+// chaosBlackholeServers returns the server names in the given dns's
+// ChaosBlackholeServersAnnotation annotation.
+func chaosBlackholeServers(dns *operatorv1.DNS) sets.String {
+	value, ok := dns.Annotations[ChaosBlackholeServersAnnotation]
+	if !ok {
+		return nil
+	}
+	return sets.NewString(strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\n'
+	})...)
+}
+
+// applyChaosBlackholes returns a copy of the given servers in which the
+// upstreams of each server whose name is in the given set are replaced with
+// an unroutable address, and the sorted names of the servers that it
+// replaced.
+func applyChaosBlackholes(servers []operatorv1.Server, names sets.String) ([]operatorv1.Server, []string) {
+	if names.Len() == 0 {
+		return servers, nil
+	}
+	var blackholed []string
+	result := make([]operatorv1.Server, 0, len(servers))
+	for _, server := range servers {
+		if names.Has(server.Name) {
+			server = *server.DeepCopy()
+			server.ForwardPlugin.Upstreams = []string{chaosBlackholeUpstream}
+			blackholed = append(blackholed, server.Name)
+		}
+		result = append(result, server)
+	}
+	sort.Strings(blackholed)
+	return result, blackholed
+}
+
+// chaosPodsToKill returns one running dns pod for each zone from the given
+// pods, using the given map of node names to zones.  Pods on nodes without a
+// zone are treated as one zone.  Within a zone, the pod with the least name is
+// chosen so that the choice is predictable.
+func chaosPodsToKill(pods []corev1.Pod, nodeZones map[string]string) []corev1.Pod {
+	podsByZone := map[string]corev1.Pod{}
+	for _, pod := range pods {
+		if pod.DeletionTimestamp != nil || len(pod.Spec.NodeName) == 0 || pod.Status.Phase != corev1.PodRunning {
+			continue
+		}
+		zone := nodeZones[pod.Spec.NodeName]
+		if chosen, ok := podsByZone[zone]; ok && chosen.Name <= pod.Name {
+			continue
+		}
+		podsByZone[zone] = pod
+	}
+	zones := make([]string, 0, len(podsByZone))
+	for zone := range podsByZone {
+		zones = append(zones, zone)
+	}
+	sort.Strings(zones)
+	result := make([]corev1.Pod, 0, len(zones))
+	for _, zone := range zones {
+		result = append(result, podsByZone[zone])
+	}
+	return result
+}
+
+// ensureChaosPodsKilled deletes one dns pod in each zone if the given dns's
+// ChaosKillPodsAnnotation annotation has a token that the operator has not
+// handled yet, and then records the token in the dns's
+// ChaosKillPodsHandledAnnotation annotation.
+func (r *reconciler) ensureChaosPodsKilled(dns *operatorv1.DNS) error {
+	token := dns.Annotations[ChaosKillPodsAnnotation]
+	if len(token) == 0 || token == dns.Annotations[ChaosKillPodsHandledAnnotation] {
+		return nil
+	}
+
+	nodes, err := ListNodes(context.TODO(), r.cache)
+	if err != nil {
+		return fmt.Errorf("failed to list nodes: %w", err)
+	}
+	nodeZones := map[string]string{}
+	for _, node := range nodes {
+		nodeZones[node.Name] = node.Labels[corev1.LabelTopologyZone]
+	}
+	selector, err := metav1.LabelSelectorAsSelector(DNSDaemonSetPodSelector(dns))
+	if err != nil {
+		return fmt.Errorf("failed to build pod selector for dns %s: %w", dns.Name, err)
+	}
+	podList := &corev1.PodList{}
+	listOpts := []client.ListOption{
+		client.MatchingLabelsSelector{Selector: selector},
+		client.InNamespace(DefaultOperandNamespace),
+	}
+	if err := r.cache.List(context.TODO(), podList, listOpts...); err != nil {
+		return fmt.Errorf("failed to list pods for dns %s: %w", dns.Name, err)
+	}
+	victims := chaosPodsToKill(podList.Items, nodeZones)
+	for i := range victims {
+		pod := &victims[i]
+		if err := r.client.Delete(context.TODO(), pod); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete pod %s/%s: %w", pod.Namespace, pod.Name, err)
+		}
+		logrus.Warningf("chaos hooks deleted dns pod %s/%s on node %s in zone %q for token %q", pod.Namespace, pod.Name, pod.Spec.NodeName, nodeZones[pod.Spec.NodeName], token)
+	}
+
+	// Use a merge patch so that the token is recorded even if the dns has
+	// changed since it was read.
+	updated := dns.DeepCopy()
+	updated.Annotations[ChaosKillPodsHandledAnnotation] = token
+	if err := r.client.Patch(context.TODO(), updated, client.MergeFrom(dns)); err != nil {
+		return fmt.Errorf("failed to record handled chaos token for dns %s: %w", dns.Name, err)
+	}
+	return nil
+}
+
+// computeDNSChaosTestModeCondition returns a status condition that reports
+// that the chaos hooks are enabled and describes the failures that they inject
+// for the given dns, given the names of the blackholed servers.
+func computeDNSChaosTestModeCondition(dns *operatorv1.DNS, blackholed []string) operatorv1.OperatorCondition {
+	faults := []string{}
+	if len(blackholed) != 0 {
+		faults = append(faults, fmt.Sprintf("the upstreams of servers %s are blackholed", strings.Join(blackholed, ", ")))
+	}
+	if token := dns.Annotations[ChaosKillPodsHandledAnnotation]; len(token) != 0 {
+		faults = append(faults, fmt.Sprintf("one dns pod per zone was deleted for token %s", strconv.Quote(token)))
+	}
+	condition := operatorv1.OperatorCondition{
+		Type:   DNSChaosTestModeConditionType,
+		Status: operatorv1.ConditionTrue,
+	}
+	if len(faults) == 0 {
+		condition.Reason = conditions.ReasonNoFaultsInjected
+		condition.Message = "Chaos hooks are enabled, but no faults are injected."
+		return condition
+	}
+	condition.Reason = conditions.ReasonFaultsInjected
+	condition.Message = fmt.Sprintf("Chaos hooks are enabled and inject faults: %s.", strings.Join(faults, "; "))
+	return condition
+}
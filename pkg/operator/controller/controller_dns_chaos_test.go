@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	corev1 "k8s.io/api/core/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestApplyChaosBlackholes verifies that applyChaosBlackholes replaces the
+// upstreams of the named servers only and does not modify its input.
+func TestApplyChaosBlackholes(t *testing.T) {
+	servers := []operatorv1.Server{
+		{Name: "foo", Zones: []string{"foo.com"}, ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"1.1.1.1"}}},
+		{Name: "bar", Zones: []string{"bar.com"}, ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"2.2.2.2"}}},
+	}
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{ChaosBlackholeServersAnnotation: "bar, baz"},
+		},
+	}
+	actual, blackholed := applyChaosBlackholes(servers, chaosBlackholeServers(dns))
+	if expected := []string{"bar"}; !reflect.DeepEqual(blackholed, expected) {
+		t.Errorf("expected blackholed servers %v, got %v", expected, blackholed)
+	}
+	if upstreams := actual[0].ForwardPlugin.Upstreams; !reflect.DeepEqual(upstreams, []string{"1.1.1.1"}) {
+		t.Errorf("expected server foo to keep its upstreams, got %v", upstreams)
+	}
+	if upstreams := actual[1].ForwardPlugin.Upstreams; !reflect.DeepEqual(upstreams, []string{chaosBlackholeUpstream}) {
+		t.Errorf("expected server bar to be blackholed, got %v", upstreams)
+	}
+	if upstreams := servers[1].ForwardPlugin.Upstreams; !reflect.DeepEqual(upstreams, []string{"2.2.2.2"}) {
+		t.Errorf("expected input to be unmodified, got %v", upstreams)
+	}
+}
+
+// TestChaosPodsToKill verifies that chaosPodsToKill chooses one running pod
+// per zone.
+func TestChaosPodsToKill(t *testing.T) {
+	pod := func(name, node string, phase corev1.PodPhase) corev1.Pod {
+		return corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       corev1.PodSpec{NodeName: node},
+			Status:     corev1.PodStatus{Phase: phase},
+		}
+	}
+	terminating := pod("dns-a0", "a1", corev1.PodRunning)
+	terminating.DeletionTimestamp = &metav1.Time{}
+	pods := []corev1.Pod{
+		pod("dns-a2", "a2", corev1.PodRunning),
+		pod("dns-a1", "a1", corev1.PodRunning),
+		terminating,
+		pod("dns-b0", "b1", corev1.PodPending),
+		pod("dns-b1", "b1", corev1.PodRunning),
+		pod("dns-x1", "x1", corev1.PodRunning),
+	}
+	nodeZones := map[string]string{
+		"a1": "zone-a",
+		"a2": "zone-a",
+		"b1": "zone-b",
+		"x1": "",
+	}
+	var names []string
+	for _, p := range chaosPodsToKill(pods, nodeZones) {
+		names = append(names, p.Name)
+	}
+	if expected := []string{"dns-x1", "dns-a1", "dns-b1"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %v, got %v", expected, names)
+	}
+}
@@ -4,7 +4,12 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
 	"text/template"
+	"time"
 
 	"github.com/openshift/cluster-dns-operator/pkg/manifests"
 
@@ -19,50 +24,404 @@ import (
 
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
 )
 
-var corefileTemplate = template.Must(template.New("Corefile").Parse(`{{range .Servers -}}
-# {{.Name}}
+// corefileTemplate is the template for the Corefile.  The server blocks for the
+// DNS's views come first, in the order of the DNS's ViewsAnnotation annotation;
+// each applies only to queries from the view's source CIDRs, which CoreDNS's
+// view plugin selects, so the view blocks must precede the other server blocks
+// for the same zones, which answer the other clients, and the first view that
+// selects a query answers it.  The server blocks for the DNS's servers follow,
+// ordered by server name and each with its zones in sorted order, followed by
+// the default server block.  A server block has the cancel plugin if the DNS
+// sets a query timeout for it.  A server block that falls back to the default
+// upstreams forwards to its own upstreams and then to the upstreams in
+// /etc/resolv.conf, in that order.  The server blocks for the IdM DNS servers
+// that the operator discovered follow, ordered by name; each forwards its
+// realm's zones over DNS-over-TLS and verifies the server's certificate with
+// the CA certificate that the dns's configmap provides.  The server blocks for
+// the dns's zone files follow, ordered by zone; each serves its zone
+// authoritatively from the copy of the zone file that the dns's configmap
+// provides, and the Corefile includes a hash of the zone file so that CoreDNS
+// reloads its configuration, and thus the zone, when the file changes.  The
+// server blocks for the dns's secondary zones follow, ordered by zone; each
+// transfers its zone from the zone's primaries.  If the dns serves
+// DNS-over-TLS, a DNS-over-TLS server block follows, which terminates TLS with
+// the serving certificate that the dns pods mount and forwards queries to the
+// pod's own DNS listener so that they are answered by the same server blocks as
+// plain DNS queries.  The default server block appends the dns's search suffix
+// to single-label names and then rewrites names with the rules in the dns's
+// rewrite rules configmap, in the configmap's order, and answers queries for
+// the entries in the dns's static hosts configmap, the names in the dns's
+// blocklists, which it sinkholes, and the records in the dns's synthesized
+// records configmap before it queries the cluster's services and the upstreams.
+// The default server block has the cache plugin; the other server blocks have
+// it only if the dns enables serve_stale, so that their responses can be served
+// stale while their upstreams are unreachable.  Every server block has the
+// timeouts plugin if the DNS sets server timeouts; the server blocks share a
+// listener, so they must have the same timeouts.  Every server block has the
+// prometheus plugin with the same address so that CoreDNS's request metrics
+// have a zone label for each server block's zones; the set of label values is
+// thus bounded by the DNS's servers.  The server blocks of the servers, zone
+// files, and secondary zones that the DNS excludes from metrics omit the
+// prometheus plugin, so their queries never appear in metrics.  Every server
+// block's errors plugin consolidates errors by kind if the DNS enables query
+// privacy, so that CoreDNS does not log query names.  Every server block has
+// the bufsize plugin, and the minimal plugin if the DNS enables minimal
+// responses, with the same UDP truncation policy, the dns64 plugin if DNS64 is
+// active, and the loadbalance plugin if the DNS sets preferred answer prefixes.
+// The server blocks from the dns's custom Corefile configmap precede the
+// default server block, and the plugins from the configmap follow the default
+// server block's generated plugins, each ordered by key.  The forward plugin in
+// each server block has the options that the DNS sets for that server block, if
+// any, and the client certificate with which it authenticates to the server
+// block's DNS-over-TLS upstreams, if any; if the dns's configmap provides a CA
+// bundle that verifies the upstreams, the server block's comment includes a
+// hash of the bundle so that CoreDNS reloads its configuration when the bundle
+// changes.  Every server block has the acl plugin if the dns has query ACLs,
+// with the ACLs in the order of the dns's QueryACLsAnnotation annotation, so
+// that the first ACL that matches a query's source applies in every server
+// block.  Every server block has the rrl plugin if the dns enables response
+// rate limiting.  Within each block, plugins appear in a fixed order.
+// Rendering the same DNS therefore always produces the same Corefile,
+// regardless of the order of the DNS's servers and zones.
+var corefileTemplate = template.Must(template.New("Corefile").Funcs(template.FuncMap{"quote": corefileQuote}).Parse(`{{range .Views -}}
+# view {{.Name}}
 {{range .Zones}}{{.}}:5353 {{end}}{
-    {{with .ForwardPlugin -}}
-    forward .{{range .Upstreams}} {{.}}{{end}}
+    view {{.Name}} {
+        expr {{.Expression}}
+    }
+    {{- template "acl" $.QueryACLs}}
+    {{- template "rrl" $.RRL}}
+    {{- with .Upstreams}}
+    forward .{{range .}} {{.}}{{end}}
+    {{- else}}
+    template ANY ANY {
+        rcode {{.Response}}
+    }
     {{- end}}
-    errors
-    bufsize 1232
+    {{- template "errors" $.ErrorConsolidations}}
+    {{- template "udp" $.UDPTruncation}}
+    {{- template "dns64" $.DNS64}}
+    {{- template "prefer" $.PreferredPrefixes}}
+    prometheus {{$.MetricsAddress}}
+    {{- template "timeouts" $.ServerTimeouts}}
+}
+{{end -}}
+{{range .Servers -}}
+# {{.Name}}{{with .TLS}}{{with .CABundle}} (CA {{.Hash}}){{end}}{{end}}
+{{range .Zones}}{{.}}:5353 {{end}}{
+    {{- template "acl" $.QueryACLs}}
+    {{- template "rrl" $.RRL}}
+    forward .{{range .ForwardPlugin.Upstreams}} {{.}}{{end}}
+    {{- if .FallbackToDefaultUpstreams}} /etc/resolv.conf{{end}}
+    {{- if or .FallbackToDefaultUpstreams .Forward .TLS}} {
+        {{- if .FallbackToDefaultUpstreams}}
+        policy sequential
+        {{- end}}
+        {{- with .TLS}}
+        tls {{.CertDir}}/tls.crt {{.CertDir}}/tls.key{{with .CABundle}} {{$.ConfigDir}}/{{.Key}}{{else}}{{if .HasCA}} {{.CertDir}}/ca.crt{{end}}{{end}}
+        {{- with .ServerName}}
+        tls_servername {{.}}
+        {{- end}}
+        {{- end}}
+        {{- template "forward" .Forward}}
+    }
+    {{- end}}
+    {{- template "errors" $.ErrorConsolidations}}
+    {{- template "udp" $.UDPTruncation}}
+    {{- template "dns64" $.DNS64}}
+    {{- template "prefer" $.PreferredPrefixes}}
+    {{- if not ($.MetricsExcluded.Server .Name)}}
+    prometheus {{$.MetricsAddress}}
+    {{- end}}
+    {{- if $.Cache.ServeStale}}{{template "cache" $.Cache}}{{end}}
+    {{- with .QueryTimeout}}
+    cancel {{.}}
+    {{- end}}
+    {{- template "timeouts" $.ServerTimeouts}}
+}
+{{end -}}
+{{range .IdMResolvers -}}
+# {{.Name}} (CA {{.CAHash}})
+{{range .Zones}}{{.}}:5353 {{end}}{
+    {{- template "acl" $.QueryACLs}}
+    {{- template "rrl" $.RRL}}
+    forward . {{.Upstream}} {
+        tls {{$.ConfigDir}}/{{.CAKey}}
+        tls_servername {{.TLSServerName}}
+        {{- template "forward" $.Forward}}
+    }
+    {{- template "errors" $.ErrorConsolidations}}
+    {{- template "udp" $.UDPTruncation}}
+    {{- template "dns64" $.DNS64}}
+    {{- template "prefer" $.PreferredPrefixes}}
+    prometheus {{$.MetricsAddress}}
+    {{- if $.Cache.ServeStale}}{{template "cache" $.Cache}}{{end}}
+    {{- template "timeouts" $.ServerTimeouts}}
+}
+{{end -}}
+{{range .ZoneFiles -}}
+# zone {{.Zone}} (configmap {{.ConfigMap}}, serial {{.Serial}}, hash {{.Hash}})
+{{.Zone}}:5353 {
+    {{- template "acl" $.QueryACLs}}
+    {{- template "rrl" $.RRL}}
+    file {{$.ConfigDir}}/{{.Key}} {{.Zone}}
+    {{- with $.ZoneTransfers.For .Zone}}
+    {{- if .TSIGSecret}}
+    tsig {{.Zone}} {
+        secrets {{.TSIGKeyFile}}
+        require AXFR IXFR
+    }
+    {{- end}}
+    transfer {{.Zone}} {
+        to{{range .To}} {{.}}{{end}}
+    }
+    {{- end}}
+    {{- template "errors" $.ErrorConsolidations}}
+    {{- template "udp" $.UDPTruncation}}
+    {{- template "dns64" $.DNS64}}
+    {{- template "prefer" $.PreferredPrefixes}}
+    {{- if not ($.MetricsExcluded.Zone .Zone)}}
+    prometheus {{$.MetricsAddress}}
+    {{- end}}
+    {{- template "timeouts" $.ServerTimeouts}}
+}
+{{end -}}
+{{range .SecondaryZones -}}
+# secondary {{.Zone}}
+{{.Zone}}:5353 {
+    {{- template "acl" $.QueryACLs}}
+    {{- template "rrl" $.RRL}}
+    secondary {
+        transfer from{{range .Primaries}} {{.}}{{end}}
+    }
+    {{- template "errors" $.ErrorConsolidations}}
+    {{- template "udp" $.UDPTruncation}}
+    {{- template "dns64" $.DNS64}}
+    {{- template "prefer" $.PreferredPrefixes}}
+    {{- if not ($.MetricsExcluded.Zone .Zone)}}
+    prometheus {{$.MetricsAddress}}
+    {{- end}}
+    {{- template "timeouts" $.ServerTimeouts}}
 }
 {{end -}}
+{{range .EncryptedListeners -}}
+# {{.Name}}
+{{.Scheme}}://.:{{.ContainerPort}} {
+    tls {{.CertDir}}/tls.crt {{.CertDir}}/tls.key
+    {{- template "acl" $.QueryACLs}}
+    {{- template "rrl" $.RRL}}
+    forward . 127.0.0.1:{{$.Port}}
+    {{- template "errors" $.ErrorConsolidations}}
+    prometheus {{$.MetricsAddress}}
+    {{- template "timeouts" $.ServerTimeouts}}
+}
+{{end -}}
+{{range .CustomServers -}}
+# {{.Key}}
+{{range .Lines}}{{.}}
+{{end -}}
+{{end -}}
 .:5353 {
-    bufsize 1232
-    errors
+    {{- template "acl" .QueryACLs}}
+    {{- template "rrl" .RRL}}
+    {{- template "udp" .UDPTruncation}}
+    {{- template "dns64" .DNS64}}
+    {{- template "prefer" .PreferredPrefixes}}
+    {{- template "errors" $.ErrorConsolidations}}
+    {{- with .DefaultQueryTimeout}}
+    cancel {{.}}
+    {{- end}}
+    {{- template "timeouts" .ServerTimeouts}}
     health {
         lameduck 20s
     }
     ready
+    {{- with .SearchSuffix}}
+    rewrite continue name regex ^([^.]+)\.$ {1}.{{.}} answer auto
+    {{- end}}
+    {{- range .RewriteRules}}
+    rewrite name {{.Match}} {{.From}} {{.To}} answer auto
+    {{- end}}
+    {{- if or .StaticHosts .Blocklist}}
+    hosts{{with .Blocklist}} {{$.ConfigDir}}/{{.Key}}{{end}} {
+        {{- range .StaticHosts}}
+        {{.IP}}{{range .Names}} {{.}}{{end}}
+        {{- end}}
+        fallthrough
+    }
+    {{- end}}
+    {{- range .SynthesizedRecords}}
+    template {{.Class}} {{.Type}} {{.Zone}} {
+        {{- range .Match}}
+        match {{quote .}}
+        {{- end}}
+        {{- range .Answer}}
+        answer {{quote .}}
+        {{- end}}
+        {{- range .Additional}}
+        additional {{quote .}}
+        {{- end}}
+        {{- range .Authority}}
+        authority {{quote .}}
+        {{- end}}
+        {{- with .Rcode}}
+        rcode {{.}}
+        {{- end}}
+        {{- if .Fallthrough}}
+        fallthrough
+        {{- end}}
+    }
+    {{- end}}
     kubernetes {{.ClusterDomain}} in-addr.arpa ip6.arpa {
         pods insecure
         fallthrough in-addr.arpa ip6.arpa
     }
-    prometheus 127.0.0.1:9153
+    prometheus {{.MetricsAddress}}
     forward . /etc/resolv.conf {
         policy sequential
+        {{- template "forward" .DefaultForward}}
     }
-    cache 900 {
-        denial 9984 30
-    }
+    {{- template "cache" .Cache}}
     reload
+    {{- range .CustomOverrides}}
+    # {{.Key}}
+    {{- range .Lines}}
+    {{.}}
+    {{- end}}
+    {{- end}}
 }
+{{- define "cache"}}
+    cache {{.SuccessTTL}} {
+        {{- with .SuccessMaxEntries}}
+        success {{.}}
+        {{- end}}
+        denial {{.DenialMaxEntries}} {{.DenialTTL}}
+        {{- with .ServeStale}}
+        serve_stale {{.}}
+        {{- end}}
+        {{- with .Prefetch}}
+        prefetch {{.Amount}} {{.Duration}} {{.Percentage}}%
+        {{- end}}
+    }
+{{- end}}
+{{- define "acl"}}
+{{- with .}}
+    acl {
+        {{- range .}}
+        {{.Action}} net{{range .SourceCIDRs}} {{.}}{{end}}
+        {{- end}}
+    }
+{{- end}}
+{{- end}}
+{{- define "rrl"}}
+{{- with .}}
+    rrl {
+        window {{.Window}}
+        ipv4-prefix-length {{.IPv4PrefixLength}}
+        ipv6-prefix-length {{.IPv6PrefixLength}}
+        {{- with .ResponsesPerSecond}}
+        responses-per-second {{.}}
+        {{- end}}
+        {{- with .NodataPerSecond}}
+        nodata-per-second {{.}}
+        {{- end}}
+        {{- with .NXDomainsPerSecond}}
+        nxdomains-per-second {{.}}
+        {{- end}}
+        {{- with .ErrorsPerSecond}}
+        errors-per-second {{.}}
+        {{- end}}
+        {{- with .RequestsPerSecond}}
+        requests-per-second {{.}}
+        {{- end}}
+        slip-ratio {{.SlipRatio}}
+        {{- if .ReportOnly}}
+        report-only
+        {{- end}}
+    }
+{{- end}}
+{{- end}}
+{{- define "errors"}}
+    errors
+    {{- with .}} {
+        {{- range .}}
+        consolidate {{.Period}} {{quote .Pattern}}
+        {{- end}}
+    }
+    {{- end}}
+{{- end}}
+{{- define "udp"}}
+    bufsize {{.MaxSize}}
+    {{- if .MinimalResponses}}
+    minimal
+    {{- end}}
+{{- end}}
+{{- define "dns64"}}
+{{- with .}}
+    dns64 {
+        prefix {{.Prefix}}
+        {{- if .TranslateAll}}
+        translate_all
+        {{- end}}
+    }
+{{- end}}
+{{- end}}
+{{- define "prefer"}}
+{{- with .}}
+    loadbalance round_robin {
+        prefer{{range .}} {{.}}{{end}}
+    }
+{{- end}}
+{{- end}}
+{{- define "forward"}}
+{{- with .}}
+{{- with .MaxConcurrent}}
+        max_concurrent {{.}}
+{{- end}}
+{{- with .Expire}}
+        expire {{.}}
+{{- end}}
+{{- with .HealthCheck}}
+        health_check {{.}}
+{{- end}}
+{{- end}}
+{{- end}}
+{{- define "timeouts"}}
+{{- with .}}
+    timeouts {
+        {{- with .Read}}
+        read {{.}}
+        {{- end}}
+        {{- with .Write}}
+        write {{.}}
+        {{- end}}
+        {{- with .Idle}}
+        idle {{.}}
+        {{- end}}
+    }
+{{- end}}
+{{- end}}
 `))
 
-// ensureDNSConfigMap ensures that a configmap exists for a given DNS.
-func (r *reconciler) ensureDNSConfigMap(dns *operatorv1.DNS, clusterDomain string) (bool, *corev1.ConfigMap, error) {
+// ensureDNSConfigMap ensures that a configmap exists for a given DNS.  The
+// Corefile has the given servers, which may be a subset of the DNS's servers,
+// the given IdM DNS servers, and the given views, and CoreDNS serves metrics on
+// the given address.  If deferUpdate is true, because a rollout of the dns's
+// daemonset is deferred, an existing configmap is not updated so that CoreDNS
+// does not load a Corefile that the daemonset's current pods may not support.
+func (r *reconciler) ensureDNSConfigMap(dns *operatorv1.DNS, inputs corefileInputs, deferUpdate bool) (bool, *corev1.ConfigMap, error) {
 	haveCM, current, err := r.currentDNSConfigMap(dns)
 	if err != nil {
 		return false, nil, fmt.Errorf("failed to get configmap: %v", err)
 	}
-	desired, err := desiredDNSConfigMap(dns, clusterDomain)
+	desired, err := desiredDNSConfigMap(dns, inputs)
 	if err != nil {
 		return haveCM, current, fmt.Errorf("failed to build configmap: %v", err)
 	}
+	r.desiredState.record(dns.Name, desired)
 
 	switch {
 	case !haveCM:
@@ -71,6 +430,10 @@ func (r *reconciler) ensureDNSConfigMap(dns *operatorv1.DNS, clusterDomain strin
 		}
 		logrus.Infof("created configmap: %s", desired.Name)
 		return r.currentDNSConfigMap(dns)
+	case haveCM && deferUpdate:
+		if changed, _ := corefileChanged(current, desired); changed {
+			logrus.Infof("deferring update of configmap %s/%s until the daemonset rolls out", current.Namespace, current.Name)
+		}
 	case haveCM:
 		if updated, err := r.updateDNSConfigMap(current, desired); err != nil {
 			return true, current, err
@@ -93,17 +456,146 @@ func (r *reconciler) currentDNSConfigMap(dns *operatorv1.DNS) (bool, *corev1.Con
 	return true, current, nil
 }
 
-func desiredDNSConfigMap(dns *operatorv1.DNS, clusterDomain string) (*corev1.ConfigMap, error) {
+// corefileInputs holds what the dns configmap is rendered from, other than
+// the DNS itself.
+type corefileInputs struct {
+	// Servers are the server blocks for the DNS's upstream resolvers.
+	Servers []operatorv1.Server
+	// IdMResolvers are the discovered IdM resolvers.
+	IdMResolvers []idmResolver
+	// RewriteRules are the rules for the rewrite plugin.
+	RewriteRules []rewriteRule
+	// StaticHosts are the entries for the hosts plugin.
+	StaticHosts []staticHost
+	// SynthesizedRecords are the records for the template plugin.
+	SynthesizedRecords []synthesizedRecord
+	// DNS64 is the configuration of the dns64 plugin, or nil if DNS64 is
+	// disabled.
+	DNS64 *corefileDNS64
+	// Custom holds the custom Corefile snippets, or nil if there are none.
+	Custom *corefileCustom
+	// ZoneFiles are the zones that CoreDNS serves from files.
+	ZoneFiles []zoneFile
+	// ZoneTransfers are the zone files' zones that secondaries may
+	// transfer.
+	ZoneTransfers []zoneTransfer
+	// SecondaryZones are the zones that CoreDNS transfers from primaries.
+	SecondaryZones []secondaryZone
+	// Views are the server blocks that the view plugin selects.
+	Views []dnsView
+	// QueryACLs are the rules for the acl plugin.
+	QueryACLs []queryACL
+	// Blocklist is the blocklist for the hosts plugin, or nil if there is
+	// none.
+	Blocklist *dnsBlocklist
+	// EncryptedListeners are the DNS-over-TLS and DNS-over-HTTPS
+	// listeners.
+	EncryptedListeners []encryptedListener
+	// UpstreamTLS are the client TLS configurations of the servers'
+	// DNS-over-TLS upstreams.
+	UpstreamTLS []upstreamTLS
+	// ClusterDomain is the cluster domain, or empty for "cluster.local".
+	ClusterDomain string
+	// MetricsAddress is the address on which CoreDNS serves metrics.
+	MetricsAddress string
+}
+
+func desiredDNSConfigMap(dns *operatorv1.DNS, inputs corefileInputs) (*corev1.ConfigMap, error) {
+	clusterDomain := inputs.ClusterDomain
 	if len(clusterDomain) == 0 {
 		clusterDomain = "cluster.local"
 	}
+	custom := inputs.Custom
+	if custom == nil {
+		custom = &corefileCustom{}
+	}
 
+	timeouts := queryTimeouts(dns)
+	fallback := fallbackServers(dns)
+	forwarding := forwardSettings(dns)
+	var corefileServers []corefileServer
+	for _, server := range sortedServers(inputs.Servers) {
+		corefileServers = append(corefileServers, corefileServer{
+			Server:                     server,
+			QueryTimeout:               timeouts[server.Name],
+			FallbackToDefaultUpstreams: fallback.Has(server.Name),
+			Forward:                    forwarding.forServer(server.Name),
+			TLS:                        upstreamTLSForServer(inputs.UpstreamTLS, server.Name),
+		})
+	}
+	otherZones := otherServerZones(inputs.Servers, inputs.IdMResolvers)
+	for _, zone := range inputs.ZoneFiles {
+		otherZones.Insert(normalizeZone(zone.Zone))
+	}
+	for _, zone := range inputs.SecondaryZones {
+		otherZones.Insert(normalizeZone(zone.Zone))
+	}
+	idmResolvers := append([]idmResolver(nil), inputs.IdMResolvers...)
+	sort.Slice(idmResolvers, func(i, j int) bool {
+		return idmResolvers[i].Name < idmResolvers[j].Name
+	})
 	corefileParameters := struct {
-		ClusterDomain string
-		Servers       interface{}
+		ClusterDomain       string
+		ConfigDir           string
+		Port                int
+		MetricsAddress      string
+		DefaultQueryTimeout time.Duration
+		ServerTimeouts      *corefileServerTimeouts
+		Forward             *corefileForward
+		DefaultForward      *corefileForward
+		Cache               corefileCache
+		ErrorConsolidations []errorConsolidation
+		UDPTruncation       corefileUDPTruncation
+		DNS64               *corefileDNS64
+		PreferredPrefixes   []string
+		MetricsExcluded     metricsExclusion
+		Servers             []corefileServer
+		IdMResolvers        []idmResolver
+		Views               []dnsView
+		QueryACLs           []queryACL
+		RRL                 *corefileRRL
+		ZoneFiles           []zoneFile
+		ZoneTransfers       zoneTransfers
+		SecondaryZones      []secondaryZone
+		EncryptedListeners  []encryptedListener
+		SearchSuffix        string
+		RewriteRules        []rewriteRule
+		StaticHosts         []staticHost
+		Blocklist           *dnsBlocklist
+		SynthesizedRecords  []synthesizedRecord
+		CustomServers       []corefileSnippet
+		CustomOverrides     []corefileSnippet
 	}{
-		ClusterDomain: clusterDomain,
-		Servers:       dns.Spec.Servers,
+		ClusterDomain:       clusterDomain,
+		ConfigDir:           coreDNSConfigDir,
+		Port:                CoreDNSPort,
+		MetricsAddress:      inputs.MetricsAddress,
+		DefaultQueryTimeout: timeouts[defaultServerBlockName],
+		ServerTimeouts:      serverTimeouts(dns),
+		Forward:             forwarding.forServer(""),
+		DefaultForward:      forwarding.forServer(defaultServerBlockName),
+		Cache:               cacheSettings(dns),
+		ErrorConsolidations: errorConsolidations(queryPrivacyMode(dns)),
+		UDPTruncation:       udpTruncationPolicy(dns),
+		DNS64:               inputs.DNS64,
+		PreferredPrefixes:   preferredAnswerPrefixes(dns),
+		MetricsExcluded:     metricsExcluded(dns),
+		Servers:             corefileServers,
+		IdMResolvers:        idmResolvers,
+		Views:               inputs.Views,
+		QueryACLs:           inputs.QueryACLs,
+		RRL:                 responseRateLimiting(dns),
+		ZoneFiles:           inputs.ZoneFiles,
+		ZoneTransfers:       inputs.ZoneTransfers,
+		SecondaryZones:      inputs.SecondaryZones,
+		EncryptedListeners:  inputs.EncryptedListeners,
+		SearchSuffix:        searchSuffix(dns, clusterDomain, otherZones),
+		RewriteRules:        inputs.RewriteRules,
+		StaticHosts:         inputs.StaticHosts,
+		Blocklist:           inputs.Blocklist,
+		SynthesizedRecords:  inputs.SynthesizedRecords,
+		CustomServers:       custom.Servers,
+		CustomOverrides:     custom.Overrides,
 	}
 	corefile := new(bytes.Buffer)
 	if err := corefileTemplate.Execute(corefile, corefileParameters); err != nil {
@@ -123,11 +615,390 @@ func desiredDNSConfigMap(dns *operatorv1.DNS, clusterDomain string) (*corev1.Con
 			"Corefile": corefile.String(),
 		},
 	}
+	for _, resolver := range idmResolvers {
+		cm.Data[resolver.CAKey()] = resolver.CABundle
+	}
+	for _, zone := range inputs.ZoneFiles {
+		cm.Data[zone.Key()] = zone.Data
+	}
+	if inputs.Blocklist != nil {
+		cm.Data[inputs.Blocklist.Key()] = inputs.Blocklist.Data()
+	}
+	for _, config := range inputs.UpstreamTLS {
+		if config.CABundle != nil {
+			cm.Data[config.CABundle.Key()] = config.CABundle.Bundle
+		}
+	}
 	cm.SetOwnerReferences([]metav1.OwnerReference{dnsOwnerRef(dns)})
 
+	hash, err := computeHash(cm.Data)
+	if err != nil {
+		return nil, err
+	}
+	setDesiredHash(cm, hash)
+
 	return cm, nil
 }
 
+// corefileServer is a server block in the Corefile.
+type corefileServer struct {
+	operatorv1.Server
+	// QueryTimeout is the timeout for the cancel plugin, or zero if the
+	// server block does not use the cancel plugin.
+	QueryTimeout time.Duration
+	// FallbackToDefaultUpstreams indicates whether the server block
+	// forwards queries to the default upstreams when its own upstreams
+	// fail.
+	FallbackToDefaultUpstreams bool
+	// Forward is the configuration of the server block's forward plugin,
+	// or nil if the server block uses CoreDNS's defaults.
+	Forward *corefileForward
+	// TLS is the client TLS configuration of the server block's
+	// DNS-over-TLS upstreams, or nil if they use CoreDNS's defaults.
+	TLS *upstreamTLS
+}
+
+// fallbackServers returns the server names in the given dns's
+// FallbackToDefaultUpstreamsAnnotation annotation.
+func fallbackServers(dns *operatorv1.DNS) sets.String {
+	list := dns.Annotations[FallbackToDefaultUpstreamsAnnotation]
+	return sets.NewString(strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' })...)
+}
+
+// defaultServerBlockName is the name that refers to the default server block
+// in the QueryTimeoutsAnnotation annotation.
+const defaultServerBlockName = "."
+
+// maxQueryTimeout is the longest query timeout that the
+// QueryTimeoutsAnnotation annotation may set.  Clients typically give up on a
+// query well before this.
+const maxQueryTimeout = time.Minute
+
+// queryTimeouts returns a map of server names to the query timeouts in the
+// given dns's QueryTimeoutsAnnotation annotation.  The default server block
+// has the name defaultServerBlockName.  Invalid entries are logged and
+// ignored.
+func queryTimeouts(dns *operatorv1.DNS) map[string]time.Duration {
+	timeouts := map[string]time.Duration{}
+	list := dns.Annotations[QueryTimeoutsAnnotation]
+	for _, entry := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' }) {
+		i := strings.Index(entry, "=")
+		if i < 1 {
+			logrus.Warningf("ignoring malformed query timeout %q in annotation %s on dns %s", entry, QueryTimeoutsAnnotation, dns.Name)
+			continue
+		}
+		timeout, err := time.ParseDuration(entry[i+1:])
+		if err != nil || timeout <= 0 || timeout > maxQueryTimeout {
+			logrus.Warningf("ignoring query timeout %q in annotation %s on dns %s: the timeout must be a positive duration of at most %v", entry, QueryTimeoutsAnnotation, dns.Name, maxQueryTimeout)
+			continue
+		}
+		timeouts[entry[:i]] = timeout
+	}
+	return timeouts
+}
+
+// corefileServerTimeouts are the timeouts for the timeouts plugin.  A zero
+// timeout is left at CoreDNS's default.
+type corefileServerTimeouts struct {
+	Read  time.Duration
+	Write time.Duration
+	Idle  time.Duration
+}
+
+// minServerTimeout and maxServerTimeout are the bounds that the timeouts
+// plugin accepts for each timeout.
+const (
+	minServerTimeout = time.Second
+	maxServerTimeout = 24 * time.Hour
+)
+
+// serverTimeouts returns the timeouts in the given dns's
+// ServerTimeoutsAnnotation annotation, or nil if the annotation sets no valid
+// timeouts.  Invalid entries are logged and ignored.
+func serverTimeouts(dns *operatorv1.DNS) *corefileServerTimeouts {
+	var timeouts corefileServerTimeouts
+	list := dns.Annotations[ServerTimeoutsAnnotation]
+	for _, entry := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' }) {
+		i := strings.Index(entry, "=")
+		if i < 1 {
+			logrus.Warningf("ignoring malformed server timeout %q in annotation %s on dns %s", entry, ServerTimeoutsAnnotation, dns.Name)
+			continue
+		}
+		timeout, err := time.ParseDuration(entry[i+1:])
+		if err != nil || timeout < minServerTimeout || timeout > maxServerTimeout {
+			logrus.Warningf("ignoring server timeout %q in annotation %s on dns %s: the timeout must be a duration between %v and %v", entry, ServerTimeoutsAnnotation, dns.Name, minServerTimeout, maxServerTimeout)
+			continue
+		}
+		switch entry[:i] {
+		case "read":
+			timeouts.Read = timeout
+		case "write":
+			timeouts.Write = timeout
+		case "idle":
+			timeouts.Idle = timeout
+		default:
+			logrus.Warningf("ignoring unknown server timeout %q in annotation %s on dns %s: the timeout must be read, write, or idle", entry, ServerTimeoutsAnnotation, dns.Name)
+		}
+	}
+	if timeouts == (corefileServerTimeouts{}) {
+		return nil
+	}
+	return &timeouts
+}
+
+// corefileForward is the configuration of the forward plugin's options.  A
+// zero setting is left at CoreDNS's default.
+type corefileForward struct {
+	// MaxConcurrent is the largest number of queries that the server
+	// block forwards concurrently.
+	MaxConcurrent int
+	// Expire is the duration after which cached connections to the
+	// upstreams expire.
+	Expire time.Duration
+	// HealthCheck is the interval between health checks of the upstreams.
+	HealthCheck time.Duration
+}
+
+// Bounds of the settings in the ForwardingAnnotation annotation.
+const (
+	minForwardMaxConcurrent = 1
+	maxForwardMaxConcurrent = 1000000
+	minForwardExpire        = time.Second
+	maxForwardExpire        = time.Hour
+	minForwardHealthCheck   = 100 * time.Millisecond
+	maxForwardHealthCheck   = time.Minute
+)
+
+// forwardingSettings maps server names to the forward plugin settings that the
+// ForwardingAnnotation annotation sets for them.  The empty name has the
+// settings for every server block.
+type forwardingSettings map[string]corefileForward
+
+// forServer returns the forward plugin settings for the server block with the
+// given name, which is defaultServerBlockName for the default server block or
+// the empty string for a server block without settings of its own, or nil if
+// the server block uses CoreDNS's defaults.
+func (s forwardingSettings) forServer(name string) *corefileForward {
+	settings := s[""]
+	if len(name) != 0 {
+		server := s[name]
+		if server.MaxConcurrent != 0 {
+			settings.MaxConcurrent = server.MaxConcurrent
+		}
+		if server.Expire != 0 {
+			settings.Expire = server.Expire
+		}
+		if server.HealthCheck != 0 {
+			settings.HealthCheck = server.HealthCheck
+		}
+	}
+	if settings == (corefileForward{}) {
+		return nil
+	}
+	return &settings
+}
+
+// forwardSettings returns the forward plugin settings in the given dns's
+// ForwardingAnnotation annotation.  Invalid entries are logged and ignored.
+func forwardSettings(dns *operatorv1.DNS) forwardingSettings {
+	settings := forwardingSettings{}
+	list := dns.Annotations[ForwardingAnnotation]
+	for _, entry := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' }) {
+		i := strings.Index(entry, "=")
+		if i < 1 {
+			logrus.Warningf("ignoring malformed forwarding setting %q in annotation %s on dns %s", entry, ForwardingAnnotation, dns.Name)
+			continue
+		}
+		server, setting, value := "", entry[:i], entry[i+1:]
+		if j := strings.Index(setting, "/"); j >= 0 {
+			server, setting = setting[:j], setting[j+1:]
+			if len(server) == 0 {
+				logrus.Warningf("ignoring malformed forwarding setting %q in annotation %s on dns %s", entry, ForwardingAnnotation, dns.Name)
+				continue
+			}
+		}
+		forward := settings[server]
+		switch setting {
+		case "max-concurrent":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < minForwardMaxConcurrent || n > maxForwardMaxConcurrent {
+				logrus.Warningf("ignoring forwarding setting %q in annotation %s on dns %s: the number of queries must be between %d and %d", entry, ForwardingAnnotation, dns.Name, minForwardMaxConcurrent, maxForwardMaxConcurrent)
+				continue
+			}
+			forward.MaxConcurrent = n
+		case "expire":
+			d, err := time.ParseDuration(value)
+			if err != nil || d < minForwardExpire || d > maxForwardExpire {
+				logrus.Warningf("ignoring forwarding setting %q in annotation %s on dns %s: the duration must be between %v and %v", entry, ForwardingAnnotation, dns.Name, minForwardExpire, maxForwardExpire)
+				continue
+			}
+			forward.Expire = d
+		case "health-check":
+			d, err := time.ParseDuration(value)
+			if err != nil || d < minForwardHealthCheck || d > maxForwardHealthCheck {
+				logrus.Warningf("ignoring forwarding setting %q in annotation %s on dns %s: the interval must be between %v and %v", entry, ForwardingAnnotation, dns.Name, minForwardHealthCheck, maxForwardHealthCheck)
+				continue
+			}
+			forward.HealthCheck = d
+		default:
+			logrus.Warningf("ignoring unknown forwarding setting %q in annotation %s on dns %s: the setting must be max-concurrent, expire, or health-check", entry, ForwardingAnnotation, dns.Name)
+			continue
+		}
+		settings[server] = forward
+	}
+	return settings
+}
+
+// corefileCache is the configuration of the cache plugin.  TTLs are in
+// seconds.  A zero SuccessMaxEntries is left at CoreDNS's default, a zero
+// ServeStale disables serve_stale, and a nil Prefetch disables prefetching.
+type corefileCache struct {
+	SuccessTTL        int
+	SuccessMaxEntries int
+	DenialTTL         int
+	DenialMaxEntries  int
+	// ServeStale is how long past their TTLs cached responses are served
+	// when the upstreams cannot be reached.
+	ServeStale time.Duration
+	// Prefetch is the configuration of the cache's prefetching of popular
+	// records.
+	Prefetch *corefilePrefetch
+}
+
+// corefilePrefetch is the configuration of the cache plugin's prefetch
+// option.  CoreDNS refreshes a cached response before it expires if the
+// response was requested at least Amount times, with no gap of Duration or
+// more between requests, and only Percentage percent of its TTL remains.
+type corefilePrefetch struct {
+	Amount     int
+	Duration   time.Duration
+	Percentage int
+}
+
+// defaultPrefetch is the prefetch configuration of a dns whose CacheAnnotation
+// annotation enables prefetching without setting all of its settings.  These
+// are CoreDNS's defaults.
+var defaultPrefetch = corefilePrefetch{
+	Amount:     2,
+	Duration:   time.Minute,
+	Percentage: 10,
+}
+
+// defaultCache is the cache configuration of a dns without the CacheAnnotation
+// annotation.
+var defaultCache = corefileCache{
+	SuccessTTL:       900,
+	DenialTTL:        30,
+	DenialMaxEntries: 9984,
+}
+
+// Bounds of the settings in the CacheAnnotation annotation.  CoreDNS shards
+// its cache 256 ways, so smaller caches are not useful.
+const (
+	minCacheTTL        = time.Second
+	maxCacheTTL        = 24 * time.Hour
+	minCacheMaxEntries = 256
+	maxCacheMaxEntries = 1 << 20
+	minPrefetchAmount  = 1
+	maxPrefetchAmount  = 1000
+	minPrefetchPercent = 0
+	maxPrefetchPercent = 100
+)
+
+// cacheSettings returns the cache configuration in the given dns's
+// CacheAnnotation annotation, with defaults for the settings that the
+// annotation does not set.  Invalid entries are logged and ignored.
+func cacheSettings(dns *operatorv1.DNS) corefileCache {
+	cache := defaultCache
+	prefetch, prefetchEnabled := defaultPrefetch, false
+	list := dns.Annotations[CacheAnnotation]
+	for _, entry := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' }) {
+		i := strings.Index(entry, "=")
+		if i < 1 {
+			logrus.Warningf("ignoring malformed cache setting %q in annotation %s on dns %s", entry, CacheAnnotation, dns.Name)
+			continue
+		}
+		value := entry[i+1:]
+		switch setting := entry[:i]; setting {
+		case "success-ttl", "denial-ttl":
+			ttl, err := time.ParseDuration(value)
+			if err != nil || ttl < minCacheTTL || ttl > maxCacheTTL || ttl%time.Second != 0 {
+				logrus.Warningf("ignoring cache setting %q in annotation %s on dns %s: the TTL must be a duration of whole seconds between %v and %v", entry, CacheAnnotation, dns.Name, minCacheTTL, maxCacheTTL)
+				continue
+			}
+			if setting == "success-ttl" {
+				cache.SuccessTTL = int(ttl.Seconds())
+			} else {
+				cache.DenialTTL = int(ttl.Seconds())
+			}
+		case "serve-stale":
+			d, err := time.ParseDuration(value)
+			if err != nil || d < minCacheTTL || d > maxCacheTTL {
+				logrus.Warningf("ignoring cache setting %q in annotation %s on dns %s: the duration must be between %v and %v", entry, CacheAnnotation, dns.Name, minCacheTTL, maxCacheTTL)
+				continue
+			}
+			cache.ServeStale = d
+		case "prefetch-amount":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < minPrefetchAmount || n > maxPrefetchAmount {
+				logrus.Warningf("ignoring cache setting %q in annotation %s on dns %s: the amount must be between %d and %d", entry, CacheAnnotation, dns.Name, minPrefetchAmount, maxPrefetchAmount)
+				continue
+			}
+			prefetch.Amount = n
+			prefetchEnabled = true
+		case "prefetch-duration":
+			d, err := time.ParseDuration(value)
+			if err != nil || d < minCacheTTL || d > maxCacheTTL {
+				logrus.Warningf("ignoring cache setting %q in annotation %s on dns %s: the duration must be between %v and %v", entry, CacheAnnotation, dns.Name, minCacheTTL, maxCacheTTL)
+				continue
+			}
+			prefetch.Duration = d
+			prefetchEnabled = true
+		case "prefetch-percentage":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < minPrefetchPercent || n > maxPrefetchPercent {
+				logrus.Warningf("ignoring cache setting %q in annotation %s on dns %s: the percentage must be between %d and %d", entry, CacheAnnotation, dns.Name, minPrefetchPercent, maxPrefetchPercent)
+				continue
+			}
+			prefetch.Percentage = n
+			prefetchEnabled = true
+		case "success-max-entries", "denial-max-entries":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < minCacheMaxEntries || n > maxCacheMaxEntries {
+				logrus.Warningf("ignoring cache setting %q in annotation %s on dns %s: the number of entries must be between %d and %d", entry, CacheAnnotation, dns.Name, minCacheMaxEntries, maxCacheMaxEntries)
+				continue
+			}
+			if setting == "success-max-entries" {
+				cache.SuccessMaxEntries = n
+			} else {
+				cache.DenialMaxEntries = n
+			}
+		default:
+			logrus.Warningf("ignoring unknown cache setting %q in annotation %s on dns %s: the setting must be success-ttl, denial-ttl, success-max-entries, denial-max-entries, serve-stale, prefetch-amount, prefetch-duration, or prefetch-percentage", entry, CacheAnnotation, dns.Name)
+		}
+	}
+	if prefetchEnabled {
+		cache.Prefetch = &prefetch
+	}
+	return cache
+}
+
+// sortedServers returns a copy of the given servers ordered by name, each with
+// its zones in sorted order.  The order of upstreams is significant for some
+// forwarding policies, so upstreams are not reordered.
+func sortedServers(servers []operatorv1.Server) []operatorv1.Server {
+	sorted := make([]operatorv1.Server, 0, len(servers))
+	for i := range servers {
+		server := *servers[i].DeepCopy()
+		sort.Strings(server.Zones)
+		sorted = append(sorted, server)
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
+
 func (r *reconciler) updateDNSConfigMap(current, desired *corev1.ConfigMap) (bool, error) {
 	changed, updated := corefileChanged(current, desired)
 	if !changed {
@@ -144,10 +1015,119 @@ func (r *reconciler) updateDNSConfigMap(current, desired *corev1.ConfigMap) (boo
 }
 
 func corefileChanged(current, expected *corev1.ConfigMap) (bool, *corev1.ConfigMap) {
-	if cmp.Equal(current.Data, expected.Data, cmpopts.EquateEmpty()) {
+	if cmp.Equal(current.Data, expected.Data, cmpopts.EquateEmpty()) && !hashChanged(current.Annotations, expected.Annotations) {
 		return false, current
 	}
 	updated := current.DeepCopy()
 	updated.Data = expected.Data
+	setDesiredHash(updated, expected.Annotations[DesiredHashAnnotation])
 	return true, updated
 }
+
+// corefileUDPTruncation is the policy by which CoreDNS truncates UDP responses.
+type corefileUDPTruncation struct {
+	// MaxSize is the largest UDP response, in bytes, that CoreDNS sends.
+	// CoreDNS truncates larger responses and sets the TC bit so that the
+	// client retries over TCP.
+	MaxSize int
+	// MinimalResponses indicates whether CoreDNS omits the authority and
+	// additional sections from responses that do not require them, so that
+	// fewer responses exceed MaxSize.
+	MinimalResponses bool
+}
+
+// defaultUDPTruncation is the UDP truncation policy of a dns without the
+// UDPTruncationAnnotation annotation.  1232 bytes avoids IP fragmentation on
+// common networks.
+var defaultUDPTruncation = corefileUDPTruncation{
+	MaxSize: 1232,
+}
+
+// Bounds of the max-size setting in the UDPTruncationAnnotation annotation.
+// Every DNS client accepts 512 bytes, and CoreDNS's bufsize plugin accepts no
+// more than 4096 bytes.
+const (
+	minUDPMaxSize = 512
+	maxUDPMaxSize = 4096
+)
+
+// udpTruncationPolicy returns the UDP truncation policy in the given dns's
+// UDPTruncationAnnotation annotation, with defaults for the settings that the
+// annotation does not set.  Invalid entries are logged and ignored.
+func udpTruncationPolicy(dns *operatorv1.DNS) corefileUDPTruncation {
+	policy := defaultUDPTruncation
+	list := dns.Annotations[UDPTruncationAnnotation]
+	for _, entry := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' }) {
+		i := strings.Index(entry, "=")
+		if i < 1 {
+			logrus.Warningf("ignoring malformed UDP truncation setting %q in annotation %s on dns %s", entry, UDPTruncationAnnotation, dns.Name)
+			continue
+		}
+		value := entry[i+1:]
+		switch setting := entry[:i]; setting {
+		case "max-size":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < minUDPMaxSize || n > maxUDPMaxSize {
+				logrus.Warningf("ignoring UDP truncation setting %q in annotation %s on dns %s: the size must be between %d and %d bytes", entry, UDPTruncationAnnotation, dns.Name, minUDPMaxSize, maxUDPMaxSize)
+				continue
+			}
+			policy.MaxSize = n
+		case "minimal-responses":
+			minimal, err := strconv.ParseBool(value)
+			if err != nil {
+				logrus.Warningf("ignoring UDP truncation setting %q in annotation %s on dns %s: the value must be a Boolean value", entry, UDPTruncationAnnotation, dns.Name)
+				continue
+			}
+			policy.MinimalResponses = minimal
+		default:
+			logrus.Warningf("ignoring unknown UDP truncation setting %q in annotation %s on dns %s: the setting must be max-size or minimal-responses", entry, UDPTruncationAnnotation, dns.Name)
+		}
+	}
+	return policy
+}
+
+// searchSuffix returns the domain in the given dns's SearchSuffixAnnotation
+// annotation, fully qualified and lowercase, or the empty string if the dns
+// does not have the annotation.  A suffix that is invalid, that is the root
+// zone, that contains the given cluster domain, or that is in one of the given
+// zones, which are normalized with normalizeZone, is logged and ignored.
+func searchSuffix(dns *operatorv1.DNS, clusterDomain string, zones sets.String) string {
+	value := strings.TrimSpace(dns.Annotations[SearchSuffixAnnotation])
+	if len(value) == 0 {
+		return ""
+	}
+	suffix := normalizeZone(value)
+	switch {
+	case suffix == "." || !validDomainName(suffix):
+		logrus.Warningf("ignoring invalid search suffix %q in annotation %s on dns %s", value, SearchSuffixAnnotation, dns.Name)
+	case nameInZone(normalizeZone(clusterDomain), suffix):
+		logrus.Warningf("ignoring search suffix %q in annotation %s on dns %s: the suffix contains the cluster domain", value, SearchSuffixAnnotation, dns.Name)
+	case nameInAnyZone(suffix, zones):
+		logrus.Warningf("ignoring search suffix %q in annotation %s on dns %s: the suffix is in a zone that another server serves", value, SearchSuffixAnnotation, dns.Name)
+	default:
+		return suffix
+	}
+	return ""
+}
+
+// preferredAnswerPrefixes returns the prefixes in the given dns's
+// PreferredAnswerPrefixesAnnotation annotation, in order and without
+// duplicates.  Invalid prefixes are logged and ignored.
+func preferredAnswerPrefixes(dns *operatorv1.DNS) []string {
+	var prefixes []string
+	seen := sets.NewString()
+	list := dns.Annotations[PreferredAnswerPrefixesAnnotation]
+	for _, entry := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' }) {
+		_, prefix, err := net.ParseCIDR(entry)
+		if err != nil {
+			logrus.Warningf("ignoring invalid prefix %q in annotation %s on dns %s: %v", entry, PreferredAnswerPrefixesAnnotation, dns.Name, err)
+			continue
+		}
+		if seen.Has(prefix.String()) {
+			continue
+		}
+		seen.Insert(prefix.String())
+		prefixes = append(prefixes, prefix.String())
+	}
+	return prefixes
+}
@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"strings"
 	"testing"
 
 	operatorv1 "github.com/openshift/api/operator/v1"
@@ -25,7 +26,7 @@ func TestDesiredDNSConfigmap(t *testing.T) {
 				},
 				{
 					Name:  "bar",
-					Zones: []string{"bar.com", "example.com"},
+					Zones: []string{"example.com", "bar.com"},
 					ForwardPlugin: operatorv1.ForwardPlugin{
 						Upstreams: []string{"3.3.3.3"},
 					},
@@ -33,17 +34,507 @@ func TestDesiredDNSConfigmap(t *testing.T) {
 			},
 		},
 	}
-	expectedCorefile := `# foo
+	expectedCorefile := `# bar
+bar.com:5353 example.com:5353 {
+    forward . 3.3.3.3
+    errors
+    bufsize 1232
+    prometheus 127.0.0.1:9153
+}
+# foo
 foo.com:5353 {
     forward . 1.1.1.1 2.2.2.2:5353
     errors
     bufsize 1232
+    prometheus 127.0.0.1:9153
 }
-# bar
-bar.com:5353 example.com:5353 {
+.:5353 {
+    bufsize 1232
+    errors
+    health {
+        lameduck 20s
+    }
+    ready
+    kubernetes cluster.local in-addr.arpa ip6.arpa {
+        pods insecure
+        fallthrough in-addr.arpa ip6.arpa
+    }
+    prometheus 127.0.0.1:9153
+    forward . /etc/resolv.conf {
+        policy sequential
+    }
+    cache 900 {
+        denial 9984 30
+    }
+    reload
+}
+`
+	if cm, err := desiredDNSConfigMap(dns, corefileInputs{Servers: dns.Spec.Servers, ClusterDomain: clusterDomain, MetricsAddress: "127.0.0.1:9153"}); err != nil {
+		t.Errorf("invalid dns configmap: %v", err)
+	} else if cm.Data["Corefile"] != expectedCorefile {
+		t.Errorf("unexpected Corefile; got:\n%s\nexpected:\n%s\n", cm.Data["Corefile"], expectedCorefile)
+	}
+}
+
+// TestDesiredDNSConfigMapStableOrder verifies that the order of a DNS's
+// servers and zones does not affect the rendered Corefile or its hash.
+func TestDesiredDNSConfigMapStableOrder(t *testing.T) {
+	servers := []operatorv1.Server{
+		{
+			Name:          "foo",
+			Zones:         []string{"foo.com", "foo.org"},
+			ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"1.1.1.1", "2.2.2.2"}},
+		},
+		{
+			Name:          "bar",
+			Zones:         []string{"bar.com"},
+			ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"3.3.3.3"}},
+		},
+	}
+	reordered := []operatorv1.Server{*servers[1].DeepCopy(), *servers[0].DeepCopy()}
+	reordered[1].Zones = []string{"foo.org", "foo.com"}
+
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DefaultDNSController,
+		},
+	}
+	a, err := desiredDNSConfigMap(dns, corefileInputs{Servers: servers, ClusterDomain: "cluster.local", MetricsAddress: "127.0.0.1:9153"})
+	if err != nil {
+		t.Fatalf("invalid dns configmap: %v", err)
+	}
+	b, err := desiredDNSConfigMap(dns, corefileInputs{Servers: reordered, ClusterDomain: "cluster.local", MetricsAddress: "127.0.0.1:9153"})
+	if err != nil {
+		t.Fatalf("invalid dns configmap: %v", err)
+	}
+	if a.Data["Corefile"] != b.Data["Corefile"] {
+		t.Errorf("expected the same Corefile; got:\n%s\nand:\n%s\n", a.Data["Corefile"], b.Data["Corefile"])
+	}
+	if a.Annotations[DesiredHashAnnotation] != b.Annotations[DesiredHashAnnotation] {
+		t.Errorf("expected the same hash, got %q and %q", a.Annotations[DesiredHashAnnotation], b.Annotations[DesiredHashAnnotation])
+	}
+	if servers[0].Zones[0] != "foo.com" || reordered[1].Zones[0] != "foo.org" {
+		t.Errorf("expected the given servers not to be modified")
+	}
+}
+
+// TestDesiredDNSConfigMapQueryTimeouts verifies that desiredDNSConfigMap adds
+// the cancel plugin to the server blocks for which the DNS sets valid query
+// timeouts.
+func TestDesiredDNSConfigMapQueryTimeouts(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DefaultDNSController,
+			Annotations: map[string]string{
+				QueryTimeoutsAnnotation: ".=5s, foo=1500ms,bar=-1s baz=2m,malformed,=3s",
+			},
+		},
+		Spec: operatorv1.DNSSpec{
+			Servers: []operatorv1.Server{
+				{
+					Name:          "foo",
+					Zones:         []string{"foo.com"},
+					ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"1.1.1.1"}},
+				},
+				{
+					Name:          "bar",
+					Zones:         []string{"bar.com"},
+					ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"3.3.3.3"}},
+				},
+			},
+		},
+	}
+	expectedCorefile := `# bar
+bar.com:5353 {
+    forward . 3.3.3.3
+    errors
+    bufsize 1232
+    prometheus 127.0.0.1:9153
+}
+# foo
+foo.com:5353 {
+    forward . 1.1.1.1
+    errors
+    bufsize 1232
+    prometheus 127.0.0.1:9153
+    cancel 1.5s
+}
+.:5353 {
+    bufsize 1232
+    errors
+    cancel 5s
+    health {
+        lameduck 20s
+    }
+    ready
+    kubernetes cluster.local in-addr.arpa ip6.arpa {
+        pods insecure
+        fallthrough in-addr.arpa ip6.arpa
+    }
+    prometheus 127.0.0.1:9153
+    forward . /etc/resolv.conf {
+        policy sequential
+    }
+    cache 900 {
+        denial 9984 30
+    }
+    reload
+}
+`
+	if cm, err := desiredDNSConfigMap(dns, corefileInputs{Servers: dns.Spec.Servers, ClusterDomain: "cluster.local", MetricsAddress: "127.0.0.1:9153"}); err != nil {
+		t.Errorf("invalid dns configmap: %v", err)
+	} else if cm.Data["Corefile"] != expectedCorefile {
+		t.Errorf("unexpected Corefile; got:\n%s\nexpected:\n%s\n", cm.Data["Corefile"], expectedCorefile)
+	}
+}
+
+// TestDesiredDNSConfigMapServerTimeouts verifies that the server timeouts
+// annotation adds the timeouts plugin with the valid timeouts to every server
+// block.
+func TestDesiredDNSConfigMapServerTimeouts(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DefaultDNSController,
+			Annotations: map[string]string{
+				ServerTimeoutsAnnotation: "read=10s, idle=2m,write=500ms bogus=5s,malformed",
+			},
+		},
+		Spec: operatorv1.DNSSpec{
+			Servers: []operatorv1.Server{
+				{
+					Name:          "foo",
+					Zones:         []string{"foo.com"},
+					ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"1.1.1.1"}},
+				},
+			},
+		},
+	}
+	expectedCorefile := `# foo
+foo.com:5353 {
+    forward . 1.1.1.1
+    errors
+    bufsize 1232
+    prometheus 127.0.0.1:9153
+    timeouts {
+        read 10s
+        idle 2m0s
+    }
+}
+.:5353 {
+    bufsize 1232
+    errors
+    timeouts {
+        read 10s
+        idle 2m0s
+    }
+    health {
+        lameduck 20s
+    }
+    ready
+    kubernetes cluster.local in-addr.arpa ip6.arpa {
+        pods insecure
+        fallthrough in-addr.arpa ip6.arpa
+    }
+    prometheus 127.0.0.1:9153
+    forward . /etc/resolv.conf {
+        policy sequential
+    }
+    cache 900 {
+        denial 9984 30
+    }
+    reload
+}
+`
+	if cm, err := desiredDNSConfigMap(dns, corefileInputs{Servers: dns.Spec.Servers, ClusterDomain: "cluster.local", MetricsAddress: "127.0.0.1:9153"}); err != nil {
+		t.Errorf("invalid dns configmap: %v", err)
+	} else if cm.Data["Corefile"] != expectedCorefile {
+		t.Errorf("unexpected Corefile; got:\n%s\nexpected:\n%s\n", cm.Data["Corefile"], expectedCorefile)
+	}
+}
+
+// TestDesiredDNSConfigMapForwarding verifies that the forwarding annotation
+// sets the valid forward plugin options in each server block, with the
+// settings for a server block overriding the settings for every server block.
+func TestDesiredDNSConfigMapForwarding(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DefaultDNSController,
+			Annotations: map[string]string{
+				ForwardingAnnotation:                 "max-concurrent=5000, ./max-concurrent=10000,foo/expire=30s health-check=1s,bar/health-check=5m,bogus=1,/expire=1s,malformed",
+				FallbackToDefaultUpstreamsAnnotation: "foo",
+			},
+		},
+		Spec: operatorv1.DNSSpec{
+			Servers: []operatorv1.Server{
+				{
+					Name:          "foo",
+					Zones:         []string{"foo.com"},
+					ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"1.1.1.1"}},
+				},
+				{
+					Name:          "bar",
+					Zones:         []string{"bar.com"},
+					ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"3.3.3.3"}},
+				},
+			},
+		},
+	}
+	expectedCorefile := `# bar
+bar.com:5353 {
+    forward . 3.3.3.3 {
+        max_concurrent 5000
+        health_check 1s
+    }
+    errors
+    bufsize 1232
+    prometheus 127.0.0.1:9153
+}
+# foo
+foo.com:5353 {
+    forward . 1.1.1.1 /etc/resolv.conf {
+        policy sequential
+        max_concurrent 5000
+        expire 30s
+        health_check 1s
+    }
+    errors
+    bufsize 1232
+    prometheus 127.0.0.1:9153
+}
+.:5353 {
+    bufsize 1232
+    errors
+    health {
+        lameduck 20s
+    }
+    ready
+    kubernetes cluster.local in-addr.arpa ip6.arpa {
+        pods insecure
+        fallthrough in-addr.arpa ip6.arpa
+    }
+    prometheus 127.0.0.1:9153
+    forward . /etc/resolv.conf {
+        policy sequential
+        max_concurrent 10000
+        health_check 1s
+    }
+    cache 900 {
+        denial 9984 30
+    }
+    reload
+}
+`
+	if cm, err := desiredDNSConfigMap(dns, corefileInputs{Servers: dns.Spec.Servers, ClusterDomain: "cluster.local", MetricsAddress: "127.0.0.1:9153"}); err != nil {
+		t.Errorf("invalid dns configmap: %v", err)
+	} else if cm.Data["Corefile"] != expectedCorefile {
+		t.Errorf("unexpected Corefile; got:\n%s\nexpected:\n%s\n", cm.Data["Corefile"], expectedCorefile)
+	}
+}
+
+// TestDesiredDNSConfigMapCache verifies that the cache annotation sets the
+// valid cache settings in the default server block and that invalid settings
+// leave the defaults.
+func TestDesiredDNSConfigMapCache(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DefaultDNSController,
+			Annotations: map[string]string{
+				CacheAnnotation: "success-ttl=5m, success-max-entries=4096,denial-ttl=1500ms denial-max-entries=10 bogus=1 malformed",
+			},
+		},
+	}
+	expectedCorefile := `.:5353 {
+    bufsize 1232
+    errors
+    health {
+        lameduck 20s
+    }
+    ready
+    kubernetes cluster.local in-addr.arpa ip6.arpa {
+        pods insecure
+        fallthrough in-addr.arpa ip6.arpa
+    }
+    prometheus 127.0.0.1:9153
+    forward . /etc/resolv.conf {
+        policy sequential
+    }
+    cache 300 {
+        success 4096
+        denial 9984 30
+    }
+    reload
+}
+`
+	if cm, err := desiredDNSConfigMap(dns, corefileInputs{ClusterDomain: "cluster.local", MetricsAddress: "127.0.0.1:9153"}); err != nil {
+		t.Errorf("invalid dns configmap: %v", err)
+	} else if cm.Data["Corefile"] != expectedCorefile {
+		t.Errorf("unexpected Corefile; got:\n%s\nexpected:\n%s\n", cm.Data["Corefile"], expectedCorefile)
+	}
+}
+
+// TestDesiredDNSConfigMapPrefetch verifies that the prefetch cache settings
+// enable prefetching in the default server block, with CoreDNS's defaults for
+// the settings that the annotation does not set.
+func TestDesiredDNSConfigMapPrefetch(t *testing.T) {
+	testCases := []struct {
+		description      string
+		annotation       string
+		expectedPrefetch string
+	}{
+		{
+			description: "no prefetch settings",
+			annotation:  "success-ttl=5m",
+		},
+		{
+			description:      "amount only",
+			annotation:       "prefetch-amount=5",
+			expectedPrefetch: "prefetch 5 1m0s 10%",
+		},
+		{
+			description:      "all settings",
+			annotation:       "prefetch-amount=3,prefetch-duration=30s,prefetch-percentage=20",
+			expectedPrefetch: "prefetch 3 30s 20%",
+		},
+		{
+			description:      "invalid settings are ignored",
+			annotation:       "prefetch-amount=0,prefetch-duration=10m,prefetch-percentage=101",
+			expectedPrefetch: "prefetch 2 10m0s 10%",
+		},
+		{
+			description: "only invalid settings",
+			annotation:  "prefetch-amount=-1 prefetch-duration=forever",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			dns := &operatorv1.DNS{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        DefaultDNSController,
+					Annotations: map[string]string{CacheAnnotation: tc.annotation},
+				},
+			}
+			cm, err := desiredDNSConfigMap(dns, corefileInputs{ClusterDomain: "cluster.local", MetricsAddress: "127.0.0.1:9153"})
+			if err != nil {
+				t.Fatalf("invalid dns configmap: %v", err)
+			}
+			corefile := cm.Data["Corefile"]
+			switch {
+			case len(tc.expectedPrefetch) == 0 && strings.Contains(corefile, "prefetch"):
+				t.Errorf("expected Corefile not to enable prefetch, got:\n%s", corefile)
+			case len(tc.expectedPrefetch) != 0 && !strings.Contains(corefile, "        "+tc.expectedPrefetch+"\n    }\n    reload"):
+				t.Errorf("expected the cache plugin to have %q, got:\n%s", tc.expectedPrefetch, corefile)
+			}
+		})
+	}
+}
+
+// TestDesiredDNSConfigMapServeStale verifies that the serve-stale cache
+// setting enables serve_stale in the default server block and adds the cache
+// plugin to the other server blocks.
+func TestDesiredDNSConfigMapServeStale(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DefaultDNSController,
+			Annotations: map[string]string{
+				CacheAnnotation: "serve-stale=30m,denial-ttl=5s",
+			},
+		},
+		Spec: operatorv1.DNSSpec{
+			Servers: []operatorv1.Server{
+				{
+					Name:          "foo",
+					Zones:         []string{"foo.com"},
+					ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"1.1.1.1"}},
+				},
+			},
+		},
+	}
+	expectedCorefile := `# foo
+foo.com:5353 {
+    forward . 1.1.1.1
+    errors
+    bufsize 1232
+    prometheus 127.0.0.1:9153
+    cache 900 {
+        denial 9984 5
+        serve_stale 30m0s
+    }
+}
+.:5353 {
+    bufsize 1232
+    errors
+    health {
+        lameduck 20s
+    }
+    ready
+    kubernetes cluster.local in-addr.arpa ip6.arpa {
+        pods insecure
+        fallthrough in-addr.arpa ip6.arpa
+    }
+    prometheus 127.0.0.1:9153
+    forward . /etc/resolv.conf {
+        policy sequential
+    }
+    cache 900 {
+        denial 9984 5
+        serve_stale 30m0s
+    }
+    reload
+}
+`
+	if cm, err := desiredDNSConfigMap(dns, corefileInputs{Servers: dns.Spec.Servers, ClusterDomain: "cluster.local", MetricsAddress: "127.0.0.1:9153"}); err != nil {
+		t.Errorf("invalid dns configmap: %v", err)
+	} else if cm.Data["Corefile"] != expectedCorefile {
+		t.Errorf("unexpected Corefile; got:\n%s\nexpected:\n%s\n", cm.Data["Corefile"], expectedCorefile)
+	}
+
+	dns.Annotations[CacheAnnotation] = "serve-stale=0s serve-stale=forever"
+	if cache := cacheSettings(dns); cache.ServeStale != 0 {
+		t.Errorf("expected invalid serve-stale settings to be ignored, got %v", cache.ServeStale)
+	}
+}
+
+// TestDesiredDNSConfigMapFallbackToDefaultUpstreams verifies that a server
+// block that the FallbackToDefaultUpstreamsAnnotation annotation names
+// forwards to its own upstreams and then to /etc/resolv.conf.
+func TestDesiredDNSConfigMapFallbackToDefaultUpstreams(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DefaultDNSController,
+			Annotations: map[string]string{
+				FallbackToDefaultUpstreamsAnnotation: "foo,missing",
+			},
+		},
+		Spec: operatorv1.DNSSpec{
+			Servers: []operatorv1.Server{
+				{
+					Name:          "foo",
+					Zones:         []string{"foo.com"},
+					ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"1.1.1.1", "2.2.2.2:5353"}},
+				},
+				{
+					Name:          "bar",
+					Zones:         []string{"bar.com"},
+					ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"3.3.3.3"}},
+				},
+			},
+		},
+	}
+	expectedCorefile := `# bar
+bar.com:5353 {
     forward . 3.3.3.3
     errors
     bufsize 1232
+    prometheus 127.0.0.1:9153
+}
+# foo
+foo.com:5353 {
+    forward . 1.1.1.1 2.2.2.2:5353 /etc/resolv.conf {
+        policy sequential
+    }
+    errors
+    bufsize 1232
+    prometheus 127.0.0.1:9153
 }
 .:5353 {
     bufsize 1232
@@ -66,9 +557,143 @@ bar.com:5353 example.com:5353 {
     reload
 }
 `
-	if cm, err := desiredDNSConfigMap(dns, clusterDomain); err != nil {
+	if cm, err := desiredDNSConfigMap(dns, corefileInputs{Servers: dns.Spec.Servers, ClusterDomain: "cluster.local", MetricsAddress: "127.0.0.1:9153"}); err != nil {
+		t.Errorf("invalid dns configmap: %v", err)
+	} else if cm.Data["Corefile"] != expectedCorefile {
+		t.Errorf("unexpected Corefile; got:\n%s\nexpected:\n%s\n", cm.Data["Corefile"], expectedCorefile)
+	}
+}
+
+// TestDesiredDNSConfigMapUDPTruncation verifies that the UDP truncation policy
+// applies to every server block and that invalid settings are ignored.
+func TestDesiredDNSConfigMapUDPTruncation(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DefaultDNSController,
+			Annotations: map[string]string{
+				UDPTruncationAnnotation: "max-size=512, minimal-responses=true max-size=65535 minimal-responses=maybe bogus=1",
+			},
+		},
+		Spec: operatorv1.DNSSpec{
+			Servers: []operatorv1.Server{{
+				Name:          "foo",
+				Zones:         []string{"foo.com"},
+				ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"1.1.1.1"}},
+			}},
+		},
+	}
+	expectedCorefile := `# foo
+foo.com:5353 {
+    forward . 1.1.1.1
+    errors
+    bufsize 512
+    minimal
+    prometheus 127.0.0.1:9153
+}
+.:5353 {
+    bufsize 512
+    minimal
+    errors
+    health {
+        lameduck 20s
+    }
+    ready
+    kubernetes cluster.local in-addr.arpa ip6.arpa {
+        pods insecure
+        fallthrough in-addr.arpa ip6.arpa
+    }
+    prometheus 127.0.0.1:9153
+    forward . /etc/resolv.conf {
+        policy sequential
+    }
+    cache 900 {
+        denial 9984 30
+    }
+    reload
+}
+`
+	if cm, err := desiredDNSConfigMap(dns, corefileInputs{Servers: dns.Spec.Servers, ClusterDomain: "cluster.local", MetricsAddress: "127.0.0.1:9153"}); err != nil {
 		t.Errorf("invalid dns configmap: %v", err)
 	} else if cm.Data["Corefile"] != expectedCorefile {
 		t.Errorf("unexpected Corefile; got:\n%s\nexpected:\n%s\n", cm.Data["Corefile"], expectedCorefile)
 	}
 }
+
+// TestDesiredDNSConfigMapPreferredAnswerPrefixes verifies that every server
+// block sorts answers by the valid prefixes in the dns's
+// PreferredAnswerPrefixesAnnotation annotation, in order and without
+// duplicates.
+func TestDesiredDNSConfigMapPreferredAnswerPrefixes(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DefaultDNSController,
+			Annotations: map[string]string{
+				PreferredAnswerPrefixesAnnotation: "192.168.10.7/24, fd00:10::/64 10.0.0.0/8,bogus,10.1.0.0/33 192.168.10.0/24",
+			},
+		},
+		Spec: operatorv1.DNSSpec{
+			Servers: []operatorv1.Server{{
+				Name:          "foo",
+				Zones:         []string{"foo.com"},
+				ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"1.1.1.1"}},
+			}},
+		},
+	}
+	expected := `
+    loadbalance round_robin {
+        prefer 192.168.10.0/24 fd00:10::/64 10.0.0.0/8
+    }`
+	if cm, err := desiredDNSConfigMap(dns, corefileInputs{Servers: dns.Spec.Servers, ClusterDomain: "cluster.local", MetricsAddress: "127.0.0.1:9153"}); err != nil {
+		t.Errorf("invalid dns configmap: %v", err)
+	} else if n := strings.Count(cm.Data["Corefile"], expected); n != 2 {
+		t.Errorf("expected 2 server blocks with:%s\ngot %d in:\n%s", expected, n, cm.Data["Corefile"])
+	}
+}
+
+// TestDesiredDNSConfigMapSearchSuffix verifies that the default server block
+// appends the suffix in the dns's SearchSuffixAnnotation annotation to
+// single-label names before it applies the rewrite rules, and that it ignores
+// invalid suffixes.
+func TestDesiredDNSConfigMapSearchSuffix(t *testing.T) {
+	servers := []operatorv1.Server{{
+		Name:          "foo",
+		Zones:         []string{"foo.com"},
+		ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"1.1.1.1"}},
+	}}
+	rules := []rewriteRule{{Match: "suffix", From: "corp.example.com.", To: "corp.example.net."}}
+	testCases := []struct {
+		annotation string
+		expected   string
+	}{
+		{"", ""},
+		{"Corp.Example.com.", "corp.example.com."},
+		{".", ""},
+		{"bad_suffix", ""},
+		{"local", ""},
+		{"bar.foo.com", ""},
+	}
+	for _, tc := range testCases {
+		dns := &operatorv1.DNS{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        DefaultDNSController,
+				Annotations: map[string]string{SearchSuffixAnnotation: tc.annotation},
+			},
+		}
+		cm, err := desiredDNSConfigMap(dns, corefileInputs{Servers: servers, RewriteRules: rules, ClusterDomain: "cluster.local", MetricsAddress: "127.0.0.1:9153"})
+		if err != nil {
+			t.Fatalf("%q: invalid dns configmap: %v", tc.annotation, err)
+		}
+		corefile := cm.Data["Corefile"]
+		rule := "    rewrite name suffix corp.example.com. corp.example.net. answer auto\n"
+		if len(tc.expected) == 0 {
+			if strings.Contains(corefile, "name regex") {
+				t.Errorf("%q: expected no search suffix, got:\n%s", tc.annotation, corefile)
+			}
+			continue
+		}
+		expected := "    ready\n    rewrite continue name regex ^([^.]+)\\.$ {1}." + tc.expected + " answer auto\n" + rule
+		if !strings.Contains(corefile, expected) {
+			t.Errorf("%q: expected Corefile to contain:\n%s\ngot:\n%s", tc.annotation, expected, corefile)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	// DNSCRDSchemaCurrentConditionType is the type of the DNS status
+	// condition that reports whether the installed DNS CRD has the schema
+	// that the operator expects.  During an upgrade, the operator may
+	// start before the CRD is updated.
+	DNSCRDSchemaCurrentConditionType = conditions.TypeCRDSchemaCurrent
+
+	// dnsCRDName is the name of the DNS CRD.
+	dnsCRDName = "dnses.operator.openshift.io"
+
+	// crdSchemaCheckPeriod is how often the operator checks the DNS CRD
+	// again while its schema is outdated.  The operator does not watch
+	// CRDs, so it does not otherwise notice when the CRD is updated.
+	crdSchemaCheckPeriod = time.Minute
+)
+
+// expectedDNSSchemaFields are the fields of the DNS resource that the operator
+// reads or writes.  The API server prunes fields that the CRD's schema does
+// not have, so the operator cannot read or write these fields if the schema
+// lacks them.
+var expectedDNSSchemaFields = []string{
+	"spec.nodePlacement",
+	"spec.servers",
+	"status.clusterDomain",
+	"status.clusterIP",
+	"status.conditions",
+}
+
+// dnsCRDSchema describes the differences between the schema of the installed
+// DNS CRD and the schema that the operator expects.
+type dnsCRDSchema struct {
+	// MissingFields are the fields in expectedDNSSchemaFields that the
+	// schema does not have.
+	MissingFields sets.String
+	// NoStatusSubresource indicates that the CRD does not enable the status
+	// subresource, without which the operator cannot update the status.
+	NoStatusSubresource bool
+	// NoServedVersion indicates that the CRD does not serve the version of
+	// the DNS API that the operator uses.
+	NoServedVersion bool
+}
+
+// outdated returns a Boolean value indicating whether the schema is older than
+// the operator expects.  A nil schema, which is unknown, is not outdated.
+func (s *dnsCRDSchema) outdated() bool {
+	return s != nil && (len(s.MissingFields) != 0 || s.NoStatusSubresource || s.NoServedVersion)
+}
+
+// canUpdateStatus returns a Boolean value indicating whether the operator can
+// update the status of a DNS.
+func (s *dnsCRDSchema) canUpdateStatus() bool {
+	return s == nil || (!s.NoStatusSubresource && !s.NoServedVersion)
+}
+
+// pruneStatus clears the fields of the given status that the schema does not
+// have, as the API server would, so that the operator does not update the
+// status again on every reconciliation to set fields that are never stored.
+func (s *dnsCRDSchema) pruneStatus(status *operatorv1.DNSStatus) {
+	if s == nil {
+		return
+	}
+	if s.MissingFields.Has("status.clusterDomain") {
+		status.ClusterDomain = ""
+	}
+	if s.MissingFields.Has("status.clusterIP") {
+		status.ClusterIP = ""
+	}
+	if s.MissingFields.Has("status.conditions") {
+		status.Conditions = nil
+	}
+}
+
+// currentDNSCRDSchema gets the DNS CRD and returns how its schema differs from
+// the schema that the operator expects and a status condition that reports the
+// differences.
+func (r *reconciler) currentDNSCRDSchema() (*dnsCRDSchema, operatorv1.OperatorCondition, error) {
+	condition := operatorv1.OperatorCondition{
+		Type: DNSCRDSchemaCurrentConditionType,
+	}
+	crd := &apiextensionsv1.CustomResourceDefinition{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: dnsCRDName}, crd); err != nil {
+		return nil, condition, fmt.Errorf("failed to get crd %s: %w", dnsCRDName, err)
+	}
+	schema := compareDNSCRDSchema(crd, operatorv1.GroupVersion.Version, expectedDNSSchemaFields)
+	return schema, computeDNSCRDSchemaCurrentCondition(schema), nil
+}
+
+// compareDNSCRDSchema returns how the schema of the given version of the given
+// CRD differs from a schema that has the given fields, each a dot-separated
+// path from the resource's root.
+func compareDNSCRDSchema(crd *apiextensionsv1.CustomResourceDefinition, version string, fields []string) *dnsCRDSchema {
+	schema := &dnsCRDSchema{MissingFields: sets.NewString()}
+	var served *apiextensionsv1.CustomResourceDefinitionVersion
+	for i := range crd.Spec.Versions {
+		if crd.Spec.Versions[i].Name == version && crd.Spec.Versions[i].Served {
+			served = &crd.Spec.Versions[i]
+		}
+	}
+	if served == nil {
+		schema.NoServedVersion = true
+		return schema
+	}
+	schema.NoStatusSubresource = served.Subresources == nil || served.Subresources.Status == nil
+	var root *apiextensionsv1.JSONSchemaProps
+	if served.Schema != nil {
+		root = served.Schema.OpenAPIV3Schema
+	}
+	for _, field := range fields {
+		if !schemaHasField(root, strings.Split(field, ".")) {
+			schema.MissingFields.Insert(field)
+		}
+	}
+	return schema
+}
+
+// schemaHasField returns a Boolean value indicating whether the given schema
+// has the field with the given path, either because the schema declares it or
+// because the schema preserves unknown fields where the field would be.
+func schemaHasField(schema *apiextensionsv1.JSONSchemaProps, path []string) bool {
+	for _, name := range path {
+		if schema == nil {
+			return false
+		}
+		if schema.XPreserveUnknownFields != nil && *schema.XPreserveUnknownFields {
+			return true
+		}
+		property, ok := schema.Properties[name]
+		if !ok {
+			return false
+		}
+		schema = &property
+	}
+	return true
+}
+
+// computeDNSCRDSchemaCurrentCondition returns a status condition that reports
+// how the given DNS CRD schema differs from the schema that the operator
+// expects.
+func computeDNSCRDSchemaCurrentCondition(schema *dnsCRDSchema) operatorv1.OperatorCondition {
+	condition := operatorv1.OperatorCondition{
+		Type: DNSCRDSchemaCurrentConditionType,
+	}
+	if !schema.outdated() {
+		condition.Status = operatorv1.ConditionTrue
+		condition.Reason = conditions.ReasonAsExpected
+		condition.Message = fmt.Sprintf("The CRD %s has the schema that the operator expects.", dnsCRDName)
+		return condition
+	}
+	var problems []string
+	if schema.NoServedVersion {
+		problems = append(problems, fmt.Sprintf("it does not serve version %s", operatorv1.GroupVersion.Version))
+	}
+	if schema.NoStatusSubresource {
+		problems = append(problems, "it does not enable the status subresource")
+	}
+	if len(schema.MissingFields) != 0 {
+		problems = append(problems, fmt.Sprintf("it does not have fields %s", strings.Join(schema.MissingFields.List(), ", ")))
+	}
+	condition.Status = operatorv1.ConditionFalse
+	condition.Reason = conditions.ReasonCRDSchemaOutdated
+	condition.Message = fmt.Sprintf("The CRD %s is older than the operator expects: %s.  The operator ignores the missing fields until the CRD is updated.", dnsCRDName, strings.Join(problems, "; "))
+	return condition
+}
@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// loadDNSCRD returns the DNS CRD from the operator's manifests.
+func loadDNSCRD(t *testing.T) *apiextensionsv1.CustomResourceDefinition {
+	f, err := os.Open("../../../manifests/0000_70_dns-operator_00-custom-resource-definition.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	crd := &apiextensionsv1.CustomResourceDefinition{}
+	if err := yaml.NewYAMLOrJSONDecoder(f, 4096).Decode(crd); err != nil {
+		t.Fatal(err)
+	}
+	return crd
+}
+
+// TestCompareDNSCRDSchema verifies that compareDNSCRDSchema finds no
+// differences in the DNS CRD that the operator installs and reports the
+// differences in older CRDs.
+func TestCompareDNSCRDSchema(t *testing.T) {
+	crd := loadDNSCRD(t)
+	if schema := compareDNSCRDSchema(crd, "v1", expectedDNSSchemaFields); schema.outdated() {
+		t.Errorf("expected the installed CRD to be current, got %+v", schema)
+	}
+
+	old := crd.DeepCopy()
+	delete(old.Spec.Versions[0].Schema.OpenAPIV3Schema.Properties["spec"].Properties, "nodePlacement")
+	status := old.Spec.Versions[0].Schema.OpenAPIV3Schema.Properties["status"]
+	delete(status.Properties, "conditions")
+	old.Spec.Versions[0].Schema.OpenAPIV3Schema.Properties["status"] = status
+	old.Spec.Versions[0].Subresources = nil
+	schema := compareDNSCRDSchema(old, "v1", expectedDNSSchemaFields)
+	if !schema.outdated() || schema.canUpdateStatus() {
+		t.Errorf("expected an outdated schema that does not allow status updates, got %+v", schema)
+	}
+	if missing := schema.MissingFields.List(); strings.Join(missing, ",") != "spec.nodePlacement,status.conditions" {
+		t.Errorf("expected missing fields spec.nodePlacement and status.conditions, got %v", missing)
+	}
+
+	preserved := true
+	old.Spec.Versions[0].Schema.OpenAPIV3Schema.Properties["status"] = apiextensionsv1.JSONSchemaProps{Type: "object", XPreserveUnknownFields: &preserved}
+	if schema := compareDNSCRDSchema(old, "v1", expectedDNSSchemaFields); schema.MissingFields.Has("status.conditions") {
+		t.Errorf("expected a status that preserves unknown fields to have status.conditions, got %+v", schema)
+	}
+
+	if schema := compareDNSCRDSchema(crd, "v2", expectedDNSSchemaFields); !schema.NoServedVersion {
+		t.Errorf("expected version v2 not to be served, got %+v", schema)
+	}
+}
+
+// TestDNSCRDSchemaPruneStatus verifies that the operator omits the status
+// fields that an outdated schema does not have and reports the outdated schema.
+func TestDNSCRDSchemaPruneStatus(t *testing.T) {
+	schema := &dnsCRDSchema{MissingFields: sets.NewString("status.conditions")}
+	status := operatorv1.DNSStatus{
+		ClusterIP:     "172.30.0.10",
+		ClusterDomain: "cluster.local",
+		Conditions:    []operatorv1.OperatorCondition{{Type: "Available"}},
+	}
+	schema.pruneStatus(&status)
+	if status.Conditions != nil || status.ClusterIP != "172.30.0.10" || status.ClusterDomain != "cluster.local" {
+		t.Errorf("expected only the conditions to be pruned, got %+v", status)
+	}
+
+	condition := computeDNSCRDSchemaCurrentCondition(schema)
+	expectedMessage := "The CRD dnses.operator.openshift.io is older than the operator expects: it does not have fields status.conditions.  The operator ignores the missing fields until the CRD is updated."
+	if condition.Status != operatorv1.ConditionFalse || condition.Reason != conditions.ReasonCRDSchemaOutdated || condition.Message != expectedMessage {
+		t.Errorf("expected condition with status False, reason %s, and message %q, got %+v", conditions.ReasonCRDSchemaOutdated, expectedMessage, condition)
+	}
+
+	var unknown *dnsCRDSchema
+	unknown.pruneStatus(&status)
+	if unknown.outdated() || !unknown.canUpdateStatus() {
+		t.Errorf("expected an unknown schema to be treated as current")
+	}
+}
@@ -0,0 +1,356 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	// DNSCustomCorefileAppliedConditionType is the type of the DNS status
+	// condition that reports the custom Corefile snippets that the dns
+	// serves and the snippets that it ignores.  The condition is reported
+	// only if the dns's custom Corefile configmap exists.
+	DNSCustomCorefileAppliedConditionType = conditions.TypeCustomCorefileApplied
+
+	// customServerKeySuffix is the suffix of the keys in the custom
+	// Corefile configmap whose values are server blocks.
+	customServerKeySuffix = ".server"
+	// customOverrideKeySuffix is the suffix of the keys in the custom
+	// Corefile configmap whose values are plugins for the default server
+	// block.
+	customOverrideKeySuffix = ".override"
+)
+
+var (
+	// defaultServerBlockPlugins are the plugins that the operator may
+	// configure in the default server block.  A custom override snippet
+	// must not configure them again, even if the dns does not currently
+	// use them, so that the snippet does not break when the dns's
+	// configuration changes.
+	defaultServerBlockPlugins = sets.NewString("bufsize", "cache", "cancel", "dns64", "errors", "forward", "health", "hosts", "kubernetes", "loadbalance", "minimal", "prometheus", "ready", "reload", "timeouts")
+	// repeatablePlugins are the plugins that CoreDNS allows more than once
+	// in a server block.
+	repeatablePlugins = sets.NewString("rewrite", "template")
+	// corefilePluginName matches the names of CoreDNS plugins.
+	corefilePluginName = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+)
+
+// corefileSnippet is a validated snippet from a dns's custom Corefile
+// configmap.  Lines are the snippet's non-empty lines without trailing
+// whitespace.
+type corefileSnippet struct {
+	Key   string
+	Lines []string
+}
+
+// corefileCustom is the custom configuration from a dns's custom Corefile
+// configmap.  Servers are server blocks that follow the generated server
+// blocks, and Overrides are plugins for the default server block, each
+// ordered by key.
+type corefileCustom struct {
+	Servers   []corefileSnippet
+	Overrides []corefileSnippet
+}
+
+// corefileWord is a word of a Corefile.  First indicates whether the word is
+// the first on its line.
+type corefileWord struct {
+	Text  string
+	First bool
+}
+
+// dnsCustomCorefile returns the valid snippets in the given dns's custom
+// Corefile configmap and a status condition that reports them, or nil and a nil
+// condition if the configmap does not exist.  Server blocks must not serve the
+// zones that the given servers, IdM DNS servers, zone files, and secondary
+// zones, the default server block, or the cluster domain serve.
+func (r *reconciler) dnsCustomCorefile(dns *operatorv1.DNS, servers []operatorv1.Server, idmResolvers []idmResolver, zoneFiles []zoneFile, secondaryZones []secondaryZone, clusterDomain string) (*corefileCustom, *operatorv1.OperatorCondition, error) {
+	cm := &corev1.ConfigMap{}
+	name := DNSCustomCorefileConfigMapName(dns)
+	if err := r.cache.Get(context.TODO(), name, cm); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil, nil
+		}
+		return nil, nil, fmt.Errorf("failed to get custom corefile configmap %s/%s: %w", name.Namespace, name.Name, err)
+	}
+
+	zones := otherServerZones(servers, idmResolvers)
+	for _, zone := range zoneFiles {
+		zones.Insert(normalizeZone(zone.Zone))
+	}
+	for _, zone := range secondaryZones {
+		zones.Insert(normalizeZone(zone.Zone))
+	}
+	custom, problems := parseCustomCorefile(cm.Data, clusterDomain, zones)
+	condition := computeCustomCorefileAppliedCondition(types.NamespacedName{Namespace: cm.Namespace, Name: cm.Name}, custom, problems)
+	return custom, &condition, nil
+}
+
+// parseCustomCorefile parses the snippets in the given custom Corefile
+// configmap data and returns the valid snippets and the problems with the
+// invalid snippets, which are ignored.  A server block is invalid if it does
+// not listen on CoreDNS's port or if it serves a zone that is in, or contains,
+// the given cluster domain or that is one of the given zones, which are
+// normalized with normalizeZone, or that another snippet serves.  An override
+// is invalid if it configures a plugin that the default server block already
+// has.
+func parseCustomCorefile(data map[string]string, clusterDomain string, zones sets.String) (*corefileCustom, []string) {
+	var (
+		custom   corefileCustom
+		problems []string
+		keys     []string
+	)
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	clusterDomain = normalizeZone(clusterDomain)
+	zones = sets.NewString(zones.UnsortedList()...)
+	for _, key := range keys {
+		var err error
+		switch {
+		case strings.HasSuffix(key, customServerKeySuffix):
+			var blockZones []string
+			if blockZones, err = parseCustomServerBlocks(data[key], clusterDomain, zones); err == nil {
+				zones.Insert(blockZones...)
+				custom.Servers = append(custom.Servers, corefileSnippet{Key: key, Lines: snippetLines(data[key])})
+			}
+		case strings.HasSuffix(key, customOverrideKeySuffix):
+			if err = parseCustomOverride(data[key]); err == nil {
+				custom.Overrides = append(custom.Overrides, corefileSnippet{Key: key, Lines: snippetLines(data[key])})
+			}
+		default:
+			err = fmt.Errorf("the key must end with %s or %s", customServerKeySuffix, customOverrideKeySuffix)
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", key, err))
+		}
+	}
+	return &custom, problems
+}
+
+// parseCustomServerBlocks validates the given server blocks and returns the
+// zones, normalized with normalizeZone, that they serve.
+func parseCustomServerBlocks(text, clusterDomain string, zones sets.String) ([]string, error) {
+	words, err := corefileWords(text)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		served []string
+		keys   []string
+		depth  int
+		blocks int
+	)
+	for _, word := range words {
+		switch {
+		case word.Text == "{":
+			if depth == 0 {
+				if len(keys) == 0 {
+					return nil, fmt.Errorf("a server block must have zones")
+				}
+				for _, key := range keys {
+					zone, err := parseCustomServerBlockKey(key, clusterDomain, zones)
+					if err != nil {
+						return nil, err
+					}
+					served = append(served, zone)
+				}
+				keys = nil
+				blocks++
+			}
+			depth++
+		case word.Text == "}":
+			if depth == 0 {
+				return nil, fmt.Errorf("unbalanced braces")
+			}
+			depth--
+		case depth == 0:
+			keys = append(keys, word.Text)
+		}
+	}
+	switch {
+	case depth != 0:
+		return nil, fmt.Errorf("unbalanced braces")
+	case len(keys) != 0:
+		return nil, fmt.Errorf("server block %s has no body", strings.Join(keys, " "))
+	case blocks == 0:
+		return nil, fmt.Errorf("no server blocks")
+	}
+	return served, nil
+}
+
+// parseCustomServerBlockKey validates the given server block key and returns
+// the zone, normalized with normalizeZone, that it serves.
+func parseCustomServerBlockKey(key, clusterDomain string, zones sets.String) (string, error) {
+	address := strings.TrimPrefix(key, "dns://")
+	i := strings.LastIndex(address, ":")
+	if i < 0 {
+		return "", fmt.Errorf("zone %q must specify port %d", key, CoreDNSPort)
+	}
+	if port, err := strconv.Atoi(address[i+1:]); err != nil || port != CoreDNSPort {
+		return "", fmt.Errorf("zone %q must specify port %d", key, CoreDNSPort)
+	}
+	zone := normalizeZone(address[:i])
+	switch {
+	case zone == ".":
+		return "", fmt.Errorf("zone %q conflicts with the default server block", key)
+	case !validDomainName(zone):
+		return "", fmt.Errorf("%q is not a valid domain name", address[:i])
+	case nameInZone(zone, clusterDomain) || nameInZone(clusterDomain, zone):
+		return "", fmt.Errorf("zone %q overlaps the cluster domain", key)
+	case zones.Has(zone):
+		return "", fmt.Errorf("zone %q conflicts with another server block", key)
+	}
+	return zone, nil
+}
+
+// parseCustomOverride validates the given plugins for the default server block.
+func parseCustomOverride(text string) error {
+	words, err := corefileWords(text)
+	if err != nil {
+		return err
+	}
+	depth := 0
+	plugins := sets.NewString()
+	for _, word := range words {
+		switch {
+		case word.Text == "{":
+			depth++
+		case word.Text == "}":
+			if depth == 0 {
+				return fmt.Errorf("unbalanced braces")
+			}
+			depth--
+		case depth == 0 && word.First:
+			switch plugin := word.Text; {
+			case !corefilePluginName.MatchString(plugin):
+				return fmt.Errorf("%q is not a plugin name", plugin)
+			case defaultServerBlockPlugins.Has(plugin):
+				return fmt.Errorf("plugin %q conflicts with the default server block's configuration", plugin)
+			case plugins.Has(plugin) && !repeatablePlugins.Has(plugin):
+				return fmt.Errorf("plugin %q is configured more than once", plugin)
+			default:
+				plugins.Insert(plugin)
+			}
+		}
+	}
+	if depth != 0 {
+		return fmt.Errorf("unbalanced braces")
+	}
+	if plugins.Len() == 0 {
+		return fmt.Errorf("no plugins")
+	}
+	return nil
+}
+
+// corefileWords splits the given Corefile text into words as CoreDNS does,
+// omitting comments.  A quoted word may contain whitespace.
+func corefileWords(text string) ([]corefileWord, error) {
+	var (
+		words   []corefileWord
+		word    strings.Builder
+		inWord  bool
+		quoted  bool
+		escaped bool
+		comment bool
+		first   = true
+	)
+	flush := func() {
+		if inWord {
+			words = append(words, corefileWord{Text: word.String(), First: first})
+			word.Reset()
+			inWord, first = false, false
+		}
+	}
+	for _, r := range text {
+		switch {
+		case comment:
+			if r == '\n' {
+				comment, first = false, true
+			}
+		case quoted:
+			switch {
+			case escaped:
+				word.WriteRune(r)
+				escaped = false
+			case r == '\\':
+				escaped = true
+			case r == '"':
+				quoted = false
+			default:
+				word.WriteRune(r)
+			}
+		case r == '"':
+			inWord, quoted = true, true
+		case r == '#' && !inWord:
+			comment = true
+		case r == '\n':
+			flush()
+			first = true
+		case unicode.IsSpace(r):
+			flush()
+		default:
+			inWord = true
+			word.WriteRune(r)
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	flush()
+	return words, nil
+}
+
+// snippetLines returns the non-empty lines of the given snippet without
+// trailing whitespace.
+func snippetLines(text string) []string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimRightFunc(line, unicode.IsSpace); len(line) != 0 {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// computeCustomCorefileAppliedCondition returns a status condition that reports
+// the given custom snippets from the configmap with the given name and the
+// problems with the snippets that were ignored.
+func computeCustomCorefileAppliedCondition(name types.NamespacedName, custom *corefileCustom, problems []string) operatorv1.OperatorCondition {
+	condition := operatorv1.OperatorCondition{
+		Type: DNSCustomCorefileAppliedConditionType,
+	}
+	var keys []string
+	for _, snippet := range append(append([]corefileSnippet(nil), custom.Servers...), custom.Overrides...) {
+		keys = append(keys, snippet.Key)
+	}
+	active := "No custom Corefile snippets are active."
+	if len(keys) != 0 {
+		active = fmt.Sprintf("Custom Corefile snippets are active: %s.", strings.Join(keys, ", "))
+	}
+	if len(problems) != 0 {
+		condition.Status = operatorv1.ConditionFalse
+		condition.Reason = conditions.ReasonCustomCorefileIgnored
+		condition.Message = fmt.Sprintf("Some custom Corefile snippets in configmap %s/%s were ignored: %s.  %s", name.Namespace, name.Name, strings.Join(problems, "; "), active)
+		return condition
+	}
+	condition.Status = operatorv1.ConditionTrue
+	condition.Reason = conditions.ReasonAsExpected
+	condition.Message = fmt.Sprintf("Using configmap %s/%s.  %s", name.Namespace, name.Name, active)
+	return condition
+}
@@ -0,0 +1,129 @@
+package controller
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// TestParseCustomCorefile verifies that parseCustomCorefile accepts server
+// blocks and overrides that do not conflict with the generated Corefile and
+// ignores those that do.
+func TestParseCustomCorefile(t *testing.T) {
+	data := map[string]string{
+		"lab.server": `lab.example.com:5353 {
+    # Serve the lab's names from a file.
+    file /etc/coredns/lab.db
+}
+`,
+		"log.override":      "log . \"{remote} {name}\"\nrewrite name exact old.example.com new.example.com\nrewrite name exact a.example.com b.example.com\n",
+		"conflict.server":   "foo.com:5353 {\n    whoami\n}",
+		"cluster.server":    "svc.cluster.local:5353 {\n    whoami\n}",
+		"root.server":       ".:5353 {\n    whoami\n}",
+		"port.server":       "other.example.com {\n    whoami\n}",
+		"second.server":     "lab.example.com:5353 {\n    whoami\n}",
+		"unbalanced.server": "broken.example.com:5353 {\n    whoami\n",
+		"cache.override":    "cache 30",
+		"block.override":    "example.org:5353 {\n    whoami\n}",
+		"twice.override":    "any\nany",
+		"quote.override":    "log \"unterminated",
+		"notes.txt":         "hello",
+	}
+	expected := &corefileCustom{
+		Servers: []corefileSnippet{{
+			Key: "lab.server",
+			Lines: []string{
+				"lab.example.com:5353 {",
+				"    # Serve the lab's names from a file.",
+				"    file /etc/coredns/lab.db",
+				"}",
+			},
+		}},
+		Overrides: []corefileSnippet{{
+			Key: "log.override",
+			Lines: []string{
+				`log . "{remote} {name}"`,
+				"rewrite name exact old.example.com new.example.com",
+				"rewrite name exact a.example.com b.example.com",
+			},
+		}},
+	}
+	custom, problems := parseCustomCorefile(data, "cluster.local", sets.NewString("foo.com."))
+	if !reflect.DeepEqual(custom, expected) {
+		t.Errorf("expected %+v, got %+v", expected, custom)
+	}
+	expectedProblems := []string{
+		`block.override: "example.org:5353" is not a plugin name`,
+		`cache.override: plugin "cache" conflicts with the default server block's configuration`,
+		`cluster.server: zone "svc.cluster.local:5353" overlaps the cluster domain`,
+		`conflict.server: zone "foo.com:5353" conflicts with another server block`,
+		`notes.txt: the key must end with .server or .override`,
+		`port.server: zone "other.example.com" must specify port 5353`,
+		`quote.override: unterminated quote`,
+		`root.server: zone ".:5353" conflicts with the default server block`,
+		`second.server: zone "lab.example.com:5353" conflicts with another server block`,
+		`twice.override: plugin "any" is configured more than once`,
+		`unbalanced.server: unbalanced braces`,
+	}
+	if !reflect.DeepEqual(problems, expectedProblems) {
+		t.Errorf("expected problems:\n%s\ngot:\n%s", strings.Join(expectedProblems, "\n"), strings.Join(problems, "\n"))
+	}
+
+	condition := computeCustomCorefileAppliedCondition(types.NamespacedName{Namespace: "openshift-dns", Name: "dns-default-custom-corefile"}, custom, problems)
+	if condition.Status != operatorv1.ConditionFalse || condition.Reason != conditions.ReasonCustomCorefileIgnored {
+		t.Errorf("expected condition with status False and reason %s, got %+v", conditions.ReasonCustomCorefileIgnored, condition)
+	}
+	if !strings.Contains(condition.Message, "Custom Corefile snippets are active: lab.server, log.override.") {
+		t.Errorf("expected condition message to name the active snippets, got %q", condition.Message)
+	}
+}
+
+// TestDesiredDNSConfigMapCustomCorefile verifies that custom server blocks
+// precede the default server block and that custom plugins follow the default
+// server block's generated plugins.
+func TestDesiredDNSConfigMapCustomCorefile(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DefaultDNSController,
+		},
+	}
+	custom := &corefileCustom{
+		Servers: []corefileSnippet{{
+			Key:   "lab.server",
+			Lines: []string{"lab.example.com:5353 {", "    whoami", "}"},
+		}},
+		Overrides: []corefileSnippet{{
+			Key:   "log.override",
+			Lines: []string{"log . {", "    class error", "}"},
+		}},
+	}
+	cm, err := desiredDNSConfigMap(dns, corefileInputs{Custom: custom, ClusterDomain: "cluster.local", MetricsAddress: "127.0.0.1:9153"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `# lab.server
+lab.example.com:5353 {
+    whoami
+}
+.:5353 {`
+	if !strings.HasPrefix(cm.Data["Corefile"], expected) {
+		t.Errorf("expected Corefile to start with:\n%s\ngot:\n%s", expected, cm.Data["Corefile"])
+	}
+	expected = `    reload
+    # log.override
+    log . {
+        class error
+    }
+}
+`
+	if !strings.HasSuffix(cm.Data["Corefile"], expected) {
+		t.Errorf("expected Corefile to end with:\n%s\ngot:\n%s", expected, cm.Data["Corefile"])
+	}
+}
@@ -0,0 +1,243 @@
+package controller
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	// DNSCustomCoreDNSImageCompatibleConditionType is the type of the DNS
+	// status condition that indicates whether the custom coredns image
+	// that the DNS's CustomCoreDNSImageAnnotation annotation sets has
+	// every plugin that the rendered Corefile uses.  The condition is
+	// reported only if the annotation is set.
+	DNSCustomCoreDNSImageCompatibleConditionType = conditions.TypeCustomCoreDNSImageCompatible
+
+	// coreDNSPluginPrefix is the prefix of the names of DNS plugins in
+	// the output of "coredns -plugins".
+	coreDNSPluginPrefix = "dns."
+)
+
+// customCoreDNSImage returns the image in the given dns's
+// CustomCoreDNSImageAnnotation annotation, or the empty string if the
+// annotation is not set.
+func customCoreDNSImage(dns *operatorv1.DNS) string {
+	return strings.TrimSpace(dns.Annotations[CustomCoreDNSImageAnnotation])
+}
+
+// corefilePlugins returns the names of the plugins that the given Corefile
+// uses, which are the first words of the lines directly within server blocks.
+func corefilePlugins(corefile string) sets.String {
+	plugins := sets.NewString()
+	depth := 0
+	scanner := bufio.NewScanner(strings.NewReader(corefile))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if fields := strings.Fields(line); depth == 1 && fields[0] != "}" {
+			plugins.Insert(fields[0])
+		}
+		depth += strings.Count(line, "{") - strings.Count(line, "}")
+	}
+	return plugins
+}
+
+// parseCoreDNSPluginList returns the names of the DNS plugins in the given
+// output of "coredns -plugins", which lists each plugin on its own line as
+// "dns.<name>".
+func parseCoreDNSPluginList(output string) sets.String {
+	plugins := sets.NewString()
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, coreDNSPluginPrefix) {
+			plugins.Insert(strings.TrimPrefix(line, coreDNSPluginPrefix))
+		}
+	}
+	return plugins
+}
+
+// desiredPluginCheckPod returns a pod that writes the plugins of the given
+// coredns image to its termination message.  The pod has the same service
+// account and node placement as the dns daemonset.
+func desiredPluginCheckPod(dns *operatorv1.DNS, image string) *corev1.Pod {
+	name := DNSPluginCheckPodName(dns)
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name.Name,
+			Namespace:       name.Namespace,
+			OwnerReferences: []metav1.OwnerReference{dnsOwnerRef(dns)},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name:                     "dns",
+				Image:                    image,
+				ImagePullPolicy:          corev1.PullIfNotPresent,
+				Command:                  []string{"/bin/sh", "-c", "coredns -plugins > /dev/termination-log"},
+				TerminationMessagePolicy: corev1.TerminationMessageReadFile,
+			}},
+			NodeSelector:       nodeSelectorForDNS(dns),
+			PriorityClassName:  "system-node-critical",
+			RestartPolicy:      corev1.RestartPolicyNever,
+			ServiceAccountName: "dns",
+			Tolerations:        tolerationsForDNS(dns),
+		},
+	}
+}
+
+// ensureCustomCoreDNSImage checks whether the given dns sets a custom coredns
+// image that has every plugin that the Corefile for the given servers, views,
+// and query ACLs uses.  The check uses a pod that lists the image's plugins;
+// the completed pod is kept so that the list is available when the Corefile
+// changes.  Returns the coredns image that the dns daemonset should use, which
+// is the custom image if it is compatible, the current image if the custom
+// image is incompatible or is still being checked, or the given release image
+// if the dns does not set a custom image, and a status condition that describes
+// the check, or nil if the dns does not set a custom image.
+func (r *reconciler) ensureCustomCoreDNSImage(dns *operatorv1.DNS, servers []operatorv1.Server, views []dnsView, queryACLs []queryACL, clusterDomain, releaseImage string) (string, *operatorv1.OperatorCondition, error) {
+	image := customCoreDNSImage(dns)
+	if len(image) == 0 {
+		if err := r.deletePluginCheckPod(dns); err != nil {
+			return releaseImage, nil, err
+		}
+		return releaseImage, nil, nil
+	}
+	condition := &operatorv1.OperatorCondition{
+		Type: DNSCustomCoreDNSImageCompatibleConditionType,
+	}
+
+	// Until the custom image is known to be compatible, keep the image
+	// that the daemonset uses, or use the release image for a new
+	// daemonset.
+	fallbackImage := releaseImage
+	haveDS, current, err := r.currentDNSDaemonSet(dns)
+	if err != nil {
+		return releaseImage, condition, err
+	}
+	if haveDS {
+		fallbackImage, _ = daemonsetImages(current)
+	}
+
+	cm, err := desiredDNSConfigMap(dns, corefileInputs{
+		Servers:        servers,
+		Views:          views,
+		QueryACLs:      queryACLs,
+		ClusterDomain:  clusterDomain,
+		MetricsAddress: r.coreDNSMetricsAddress(),
+	})
+	if err != nil {
+		return fallbackImage, condition, fmt.Errorf("failed to build configmap: %w", err)
+	}
+	required := corefilePlugins(cm.Data["Corefile"])
+
+	pod, err := r.ensurePluginCheckPod(dns, desiredPluginCheckPod(dns, image))
+	if err != nil {
+		return fallbackImage, condition, err
+	}
+	available, failure := pluginCheckPodResult(pod)
+	switch {
+	case len(failure) != 0:
+		condition.Status = operatorv1.ConditionFalse
+		condition.Reason = conditions.ReasonPluginCheckFailed
+		condition.Message = fmt.Sprintf("Failed to list the plugins of custom coredns image %q, so the DNS daemonset uses image %q: %s", image, fallbackImage, failure)
+		return fallbackImage, condition, nil
+	case available == nil:
+		condition.Status = operatorv1.ConditionUnknown
+		condition.Reason = conditions.ReasonCheckingPlugins
+		condition.Message = fmt.Sprintf("Listing the plugins of custom coredns image %q before rolling it out.", image)
+		return fallbackImage, condition, nil
+	}
+	if missing := required.Difference(available); missing.Len() != 0 {
+		condition.Status = operatorv1.ConditionFalse
+		condition.Reason = conditions.ReasonMissingPlugins
+		condition.Message = fmt.Sprintf("Custom coredns image %q does not have plugins that the Corefile uses, so the DNS daemonset uses image %q: %s.", image, fallbackImage, strings.Join(missing.List(), ", "))
+		logrus.Warningf("refusing custom coredns image %q for dns %s: missing plugins %s", image, dns.Name, strings.Join(missing.List(), ", "))
+		return fallbackImage, condition, nil
+	}
+	condition.Status = operatorv1.ConditionTrue
+	condition.Reason = conditions.ReasonAsExpected
+	condition.Message = fmt.Sprintf("Custom coredns image %q has every plugin that the Corefile uses.", image)
+	return image, condition, nil
+}
+
+// ensurePluginCheckPod ensures that the plugin check pod exists and uses the
+// desired image, recreating it if the desired image has changed.
+func (r *reconciler) ensurePluginCheckPod(dns *operatorv1.DNS, desired *corev1.Pod) (*corev1.Pod, error) {
+	current := &corev1.Pod{}
+	name := DNSPluginCheckPodName(dns)
+	if err := r.client.Get(context.TODO(), name, current); err != nil {
+		if !errors.IsNotFound(err) {
+			return nil, fmt.Errorf("failed to get plugin check pod %s: %w", name, err)
+		}
+		if err := r.client.Create(context.TODO(), desired); err != nil {
+			return nil, fmt.Errorf("failed to create plugin check pod %s: %w", name, err)
+		}
+		logrus.Infof("created plugin check pod %s", name)
+		return desired, nil
+	}
+	if podImagesMatch(current, desired) {
+		return current, nil
+	}
+	if err := r.client.Delete(context.TODO(), current); err != nil && !errors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to delete stale plugin check pod %s: %w", name, err)
+	}
+	logrus.Infof("deleted stale plugin check pod %s", name)
+	// Return the desired pod, which has an empty status; the pod is
+	// recreated on a subsequent reconciliation once the deletion is
+	// observed.
+	return desired, nil
+}
+
+// deletePluginCheckPod deletes the plugin check pod for the given dns if it
+// exists.
+func (r *reconciler) deletePluginCheckPod(dns *operatorv1.DNS) error {
+	name := DNSPluginCheckPodName(dns)
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name.Name,
+			Namespace: name.Namespace,
+		},
+	}
+	if err := r.client.Delete(context.TODO(), pod); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to delete plugin check pod %s: %w", name, err)
+	}
+	logrus.Infof("deleted plugin check pod %s", name)
+	return nil
+}
+
+// pluginCheckPodResult inspects the given plugin check pod and returns the
+// plugins that it listed, or nil if it has not finished, and a message
+// describing the failure if the pod failed or its image cannot be pulled.
+func pluginCheckPodResult(pod *corev1.Pod) (sets.String, string) {
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.State.Waiting != nil && imagePullFailureReasons[cs.State.Waiting.Reason] {
+			return nil, fmt.Sprintf("image %q cannot be pulled: %s: %s", cs.Image, cs.State.Waiting.Reason, cs.State.Waiting.Message)
+		}
+		terminated := cs.State.Terminated
+		if terminated == nil {
+			continue
+		}
+		if terminated.ExitCode != 0 {
+			return nil, fmt.Sprintf("the pod exited with code %d: %s", terminated.ExitCode, terminated.Message)
+		}
+		return parseCoreDNSPluginList(terminated.Message), ""
+	}
+	return nil, ""
+}
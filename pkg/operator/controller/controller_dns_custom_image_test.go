@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	corev1 "k8s.io/api/core/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestCorefilePlugins verifies that corefilePlugins lists the plugins of every
+// server block in a rendered Corefile but not the options of plugins.
+func TestCorefilePlugins(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DefaultDNSController,
+			Annotations: map[string]string{
+				QueryTimeoutsAnnotation:  "foo=2s",
+				ServerTimeoutsAnnotation: "idle=30s",
+			},
+		},
+		Spec: operatorv1.DNSSpec{
+			Servers: []operatorv1.Server{{
+				Name:          "foo",
+				Zones:         []string{"foo.com"},
+				ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"1.1.1.1"}},
+			}},
+		},
+	}
+	cm, err := desiredDNSConfigMap(dns, corefileInputs{Servers: dns.Spec.Servers, ClusterDomain: "cluster.local", MetricsAddress: "127.0.0.1:9153"})
+	if err != nil {
+		t.Fatalf("invalid dns configmap: %v", err)
+	}
+	expected := []string{"bufsize", "cache", "cancel", "errors", "forward", "health", "kubernetes", "prometheus", "ready", "reload", "timeouts"}
+	if actual := corefilePlugins(cm.Data["Corefile"]).List(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
+
+// TestPluginCheckPodResult verifies that pluginCheckPodResult parses the
+// plugin list of a completed plugin check pod and reports failures.
+func TestPluginCheckPodResult(t *testing.T) {
+	pod := func(state corev1.ContainerState) *corev1.Pod {
+		return &corev1.Pod{
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{{Name: "dns", State: state}},
+			},
+		}
+	}
+	output := "Server types:\n  dns\n\nCaddyfile loaders:\n  flag\n  default\n\nOther plugins:\n  dns.errors\n  dns.forward\n  dns.whoami\n  on\n"
+	testCases := []struct {
+		description     string
+		pod             *corev1.Pod
+		expectPlugins   []string
+		expectFailure   bool
+		expectUnchecked bool
+	}{
+		{
+			description:     "no status",
+			pod:             &corev1.Pod{},
+			expectUnchecked: true,
+		},
+		{
+			description:     "running",
+			pod:             pod(corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}),
+			expectUnchecked: true,
+		},
+		{
+			description:   "image pull failure",
+			pod:           pod(corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff"}}),
+			expectFailure: true,
+		},
+		{
+			description:   "nonzero exit",
+			pod:           pod(corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 127, Message: "sh: not found"}}),
+			expectFailure: true,
+		},
+		{
+			description:   "completed",
+			pod:           pod(corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Message: output}}),
+			expectPlugins: []string{"errors", "forward", "whoami"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			plugins, failure := pluginCheckPodResult(tc.pod)
+			if (len(failure) != 0) != tc.expectFailure {
+				t.Errorf("expected failure %t, got %q", tc.expectFailure, failure)
+			}
+			if (plugins == nil) != (tc.expectUnchecked || tc.expectFailure) {
+				t.Errorf("unexpected plugins %v", plugins)
+			}
+			if tc.expectPlugins != nil && !reflect.DeepEqual(plugins.List(), tc.expectPlugins) {
+				t.Errorf("expected plugins %v, got %v", tc.expectPlugins, plugins.List())
+			}
+		})
+	}
+}
@@ -3,6 +3,8 @@ package controller
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -22,30 +24,55 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-// ensureDNSDaemonSet ensures the dns daemonset exists for a given dns.
-func (r *reconciler) ensureDNSDaemonSet(dns *operatorv1.DNS) (bool, *appsv1.DaemonSet, error) {
+// dnsShutdownDelay is the time from the start of a dns pod's termination until
+// CoreDNS stops answering queries: the dns container's preStop hook in the
+// daemonset asset sleeps for 15 seconds, and then the Corefile's health plugin
+// keeps CoreDNS answering for its 20-second lameduck period.  Sidecars to which
+// CoreDNS forwards queries must keep serving for at least as long.
+const dnsShutdownDelay = 35 * time.Second
+
+// ensureDNSDaemonSet ensures the dns daemonset exists for a given dns and uses
+// the given images and metrics serving certificate secret.  The warm-cache
+// sidecar, if any, uses the release's openshift client image.  If
+// holdNodePlacement is true, an existing daemonset keeps its node selector and
+// tolerations.
+func (r *reconciler) ensureDNSDaemonSet(dns *operatorv1.DNS, coreDNSImage, kubeRBACProxyImage, metricsSecretName, metricsCertificateRevision string, encryptedListeners []encryptedListener, doh *dohForwarding, upstreamTLSConfigs []upstreamTLS, transfers []zoneTransfer, holdNodePlacement bool) (bool, *appsv1.DaemonSet, *operatorv1.OperatorCondition, error) {
 	haveDS, current, err := r.currentDNSDaemonSet(dns)
 	if err != nil {
-		return false, nil, err
+		return false, nil, nil, err
 	}
-	desired, err := desiredDNSDaemonSet(dns, r.CoreDNSImage, r.KubeRBACProxyImage)
+	desired, err := desiredDNSDaemonSet(dns, coreDNSImage, kubeRBACProxyImage, r.OpenshiftCLIImage, metricsSecretName, metricsCertificateRevision, encryptedListeners, doh, upstreamTLSConfigs, transfers, r.OperatorImage)
 	if err != nil {
-		return haveDS, current, fmt.Errorf("failed to build dns daemonset: %v", err)
+		return haveDS, current, nil, fmt.Errorf("failed to build dns daemonset: %v", err)
+	}
+	if haveDS && holdNodePlacement {
+		if err := holdDNSNodePlacement(desired, current); err != nil {
+			return haveDS, current, nil, fmt.Errorf("failed to build dns daemonset: %v", err)
+		}
 	}
+	r.desiredState.record(dns.Name, desired)
 	switch {
 	case !haveDS:
 		if err := r.createDNSDaemonSet(desired); err != nil {
-			return false, nil, err
+			return false, nil, nil, err
 		}
-		return r.currentDNSDaemonSet(dns)
+		haveDS, current, err := r.currentDNSDaemonSet(dns)
+		return haveDS, current, nil, err
 	case haveDS:
+		releaseImages := map[string]string{"dns": r.CoreDNSImage, "kube-rbac-proxy": r.KubeRBACProxyImage}
+		deferred, condition := dnsDaemonSetRolloutDeferral(dns, current, desired, releaseImages, time.Now())
+		if deferred {
+			return true, current, condition, nil
+		}
 		if updated, err := r.updateDNSDaemonSet(current, desired); err != nil {
-			return true, current, err
+			return true, current, condition, err
 		} else if updated {
-			return r.currentDNSDaemonSet(dns)
+			haveDS, current, err := r.currentDNSDaemonSet(dns)
+			return haveDS, current, condition, err
 		}
+		return true, current, condition, nil
 	}
-	return true, current, nil
+	return true, current, nil, nil
 }
 
 // ensureDNSDaemonSetDeleted ensures deletion of daemonset and related resources
@@ -65,8 +92,19 @@ func (r *reconciler) ensureDNSDaemonSetDeleted(dns *operatorv1.DNS) error {
 	return nil
 }
 
-// desiredDNSDaemonSet returns the desired dns daemonset.
-func desiredDNSDaemonSet(dns *operatorv1.DNS, coreDNSImage, kubeRBACProxyImage string) (*appsv1.DaemonSet, error) {
+// desiredDNSDaemonSet returns the desired dns daemonset.  The kube-rbac-proxy
+// sidecar serves metrics using the certificate in the secret with the given
+// name.  If metricsCertificateRevision is not empty, the pod template records
+// it so that the pods restart when cert-manager renews the certificate.  If
+// kubeRBACProxyImage is empty, the daemonset omits the kube-rbac-proxy sidecar
+// and instead exposes CoreDNS's metrics port to the pod network so that the
+// operator's metrics proxy can scrape it.  If the dns has the
+// WarmCacheNamesAnnotation annotation, the daemonset has a warm-cache sidecar
+// that uses openshiftCLIImage.  The pods mount the serving certificate of each
+// of the given encrypted listeners and expose CoreDNS's port for it.  If doh is
+// not nil, the daemonset has a sidecar that runs operatorImage's DNS-over-HTTPS
+// forwarder.  The pods mount the TSIG secrets of the given zone transfers.
+func desiredDNSDaemonSet(dns *operatorv1.DNS, coreDNSImage, kubeRBACProxyImage, openshiftCLIImage, metricsSecretName, metricsCertificateRevision string, encryptedListeners []encryptedListener, doh *dohForwarding, upstreamTLSConfigs []upstreamTLS, transfers []zoneTransfer, operatorImage string) (*appsv1.DaemonSet, error) {
 	daemonset := manifests.DNSDaemonSet()
 	name := DNSDaemonSetName(dns)
 	daemonset.Name = name.Name
@@ -81,9 +119,16 @@ func desiredDNSDaemonSet(dns *operatorv1.DNS, coreDNSImage, kubeRBACProxyImage s
 	// Ensure the daemonset adopts only its own pods.
 	daemonset.Spec.Selector = DNSDaemonSetPodSelector(dns)
 	daemonset.Spec.Template.Labels = daemonset.Spec.Selector.MatchLabels
+	if len(metricsCertificateRevision) != 0 {
+		if daemonset.Spec.Template.Annotations == nil {
+			daemonset.Spec.Template.Annotations = map[string]string{}
+		}
+		daemonset.Spec.Template.Annotations[metricsCertificateRevisionAnnotation] = metricsCertificateRevision
+	}
 
 	daemonset.Spec.Template.Spec.NodeSelector = nodeSelectorForDNS(dns)
 	daemonset.Spec.Template.Spec.Tolerations = tolerationsForDNS(dns)
+	daemonset.Spec.Template.Spec.Affinity = affinityForDNS(dns)
 
 	coreFileVolumeFound := false
 	for i := range daemonset.Spec.Template.Spec.Volumes {
@@ -91,11 +136,18 @@ func desiredDNSDaemonSet(dns *operatorv1.DNS, coreDNSImage, kubeRBACProxyImage s
 		switch daemonset.Spec.Template.Spec.Volumes[i].Name {
 		case "config-volume":
 			daemonset.Spec.Template.Spec.Volumes[i].ConfigMap.Name = DNSConfigMapName(dns).Name
+			// The configmap has the CA certificates of the IdM
+			// DNS servers, the zone files, the blocklist, and the
+			// CA bundles of the DNS-over-TLS upstreams in addition
+			// to the Corefile.
+			if idmDiscoveryEnabled(dns) || zoneFilesEnabled(dns) || blocklistsEnabled(dns) || upstreamTLSUsesCABundles(upstreamTLSConfigs) {
+				daemonset.Spec.Template.Spec.Volumes[i].ConfigMap.Items = nil
+			}
 			coreFileVolumeFound = true
 			break
 		case "metrics-tls":
 			daemonset.Spec.Template.Spec.Volumes[i].Secret = &corev1.SecretVolumeSource{
-				SecretName: DNSMetricsSecretName(dns),
+				SecretName: metricsSecretName,
 			}
 		}
 	}
@@ -111,6 +163,31 @@ func desiredDNSDaemonSet(dns *operatorv1.DNS, coreDNSImage, kubeRBACProxyImage s
 			daemonset.Spec.Template.Spec.Containers[i].Image = kubeRBACProxyImage
 		}
 	}
+	if len(kubeRBACProxyImage) == 0 {
+		removeKubeRBACProxy(daemonset)
+	}
+	if port := dnsHostPort(dns); port != 0 {
+		addDNSHostPort(daemonset, port)
+	}
+	if names := warmCacheNames(dns); len(names) != 0 {
+		addWarmCacheSidecar(daemonset, names, openshiftCLIImage)
+	}
+	for _, listener := range encryptedListeners {
+		addEncryptedListener(daemonset, listener)
+	}
+	if doh != nil && len(doh.Upstreams) != 0 {
+		addDoHForwarderSidecar(daemonset, doh, operatorImage)
+	}
+	if len(upstreamTLSConfigs) != 0 {
+		addUpstreamTLSCertificates(daemonset, upstreamTLSConfigs)
+	}
+	addZoneTransferTSIGSecrets(daemonset, transfers)
+	hash, err := computeHash(daemonset.Spec)
+	if err != nil {
+		return nil, err
+	}
+	setDesiredHash(daemonset, hash)
+
 	return daemonset, nil
 }
 
@@ -124,15 +201,72 @@ func nodeSelectorForDNS(dns *operatorv1.DNS) map[string]string {
 }
 
 // tolerationsForDNS takes a dns and returns the tolerations that it specifies,
-// or default tolerations if it doesn't specify tolerations.
+// or default tolerations if it doesn't specify tolerations.  The default
+// tolerations allow DNS pods on control-plane nodes, which may be the only
+// nodes in the cluster, whether the nodes use the legacy or the current
+// control-plane taint.
 func tolerationsForDNS(dns *operatorv1.DNS) []corev1.Toleration {
 	if len(dns.Spec.NodePlacement.Tolerations) != 0 {
 		return dns.Spec.NodePlacement.Tolerations
 	}
-	return []corev1.Toleration{{
-		Key:      "node-role.kubernetes.io/master",
+	tolerations := []corev1.Toleration{{
+		Key:      masterNodeRoleLabel,
+		Operator: corev1.TolerationOpExists,
+	}, {
+		Key:      controlPlaneNodeRoleLabel,
 		Operator: corev1.TolerationOpExists,
 	}}
+	if preferInfraNodes(dns) {
+		tolerations = append(tolerations, corev1.Toleration{
+			Key:      infraNodeRoleLabel,
+			Operator: corev1.TolerationOpExists,
+		})
+	}
+	return tolerations
+}
+
+// infraNodeAffinityWeight is the weight of the preferred node affinity term
+// for infra nodes.
+const infraNodeAffinityWeight = 100
+
+// affinityForDNS takes a dns and returns the affinity for its pods.  If the
+// dns prefers infra nodes, the affinity has a preferred term for infra nodes
+// so that the scheduler favors infra nodes when it places DNS pods, for
+// example when it must preempt other pods to make room for them; otherwise,
+// the affinity is nil.  The term is only a preference, so DNS pods still run
+// on every node that the node selector selects.
+func affinityForDNS(dns *operatorv1.DNS) *corev1.Affinity {
+	if !preferInfraNodes(dns) {
+		return nil
+	}
+	return &corev1.Affinity{
+		NodeAffinity: &corev1.NodeAffinity{
+			PreferredDuringSchedulingIgnoredDuringExecution: []corev1.PreferredSchedulingTerm{{
+				Weight: infraNodeAffinityWeight,
+				Preference: corev1.NodeSelectorTerm{
+					MatchExpressions: []corev1.NodeSelectorRequirement{{
+						Key:      infraNodeRoleLabel,
+						Operator: corev1.NodeSelectorOpExists,
+					}},
+				},
+			}},
+		},
+	}
+}
+
+// preferInfraNodes returns a Boolean value indicating whether the given dns's
+// PreferInfraNodesAnnotation annotation is set to true.
+func preferInfraNodes(dns *operatorv1.DNS) bool {
+	value, ok := dns.Annotations[PreferInfraNodesAnnotation]
+	if !ok {
+		return false
+	}
+	prefer, err := strconv.ParseBool(value)
+	if err != nil {
+		logrus.Warningf("ignoring invalid value %q for annotation %s on dns %s: %v", value, PreferInfraNodesAnnotation, dns.Name, err)
+		return false
+	}
+	return prefer
 }
 
 // currentDNSDaemonSet returns the current dns daemonset.
@@ -225,11 +359,11 @@ func (r *reconciler) daemonsetUpdateIsSafe(current, updated *appsv1.DaemonSet) (
 	listOpts = []client.ListOption{
 		client.MatchingLabelsSelector{Selector: nodeSelector},
 	}
-	nodeList := &corev1.NodeList{}
-	if err := r.cache.List(context.TODO(), nodeList, listOpts...); err != nil {
+	nodes, err := ListNodes(context.TODO(), r.cache, listOpts...)
+	if err != nil {
 		return false, "", fmt.Errorf("failed to list nodes: %w", err)
 	}
-	for _, node := range nodeList.Items {
+	for _, node := range nodes {
 		if node.Spec.Unschedulable {
 			continue
 		}
@@ -260,12 +394,53 @@ func tolerationsTolerateTaints(tolerations []corev1.Toleration, taints []corev1.
 	return false
 }
 
+// removeKubeRBACProxy removes the kube-rbac-proxy sidecar and the volume for
+// its serving certificate from the given dns daemonset, and adds a named port
+// for CoreDNS's metrics endpoint to the dns container so that the dns service
+// exposes it in place of the sidecar's port.
+func removeKubeRBACProxy(daemonset *appsv1.DaemonSet) {
+	spec := &daemonset.Spec.Template.Spec
+	containers := []corev1.Container{}
+	for _, c := range spec.Containers {
+		switch c.Name {
+		case "kube-rbac-proxy":
+			continue
+		case "dns":
+			c.Ports = append(c.Ports, corev1.ContainerPort{
+				Name:          "metrics",
+				ContainerPort: CoreDNSMetricsPort,
+				Protocol:      corev1.ProtocolTCP,
+			})
+		}
+		containers = append(containers, c)
+	}
+	spec.Containers = containers
+	volumes := []corev1.Volume{}
+	for _, v := range spec.Volumes {
+		if v.Name == "metrics-tls" {
+			continue
+		}
+		volumes = append(volumes, v)
+	}
+	spec.Volumes = volumes
+}
+
 // daemonsetConfigChanged checks if current config matches the expected config
 // for the dns daemonset and if not returns the updated config.
 func daemonsetConfigChanged(current, expected *appsv1.DaemonSet) (bool, *appsv1.DaemonSet) {
 	changed := false
 	updated := current.DeepCopy()
 
+	// The desired hash covers the entire rendered spec, so a mismatch
+	// means that something changed that the field-by-field comparisons
+	// below may not inspect.
+	if hashChanged(current.Annotations, expected.Annotations) {
+		setDesiredHash(updated, expected.Annotations[DesiredHashAnnotation])
+		updated.Spec.Template = expected.Spec.Template
+		updated.Spec.UpdateStrategy = expected.Spec.UpdateStrategy
+		return true, updated
+	}
+
 	if !cmp.Equal(current.Spec.UpdateStrategy, expected.Spec.UpdateStrategy, cmpopts.EquateEmpty()) {
 		updated.Spec.UpdateStrategy = expected.Spec.UpdateStrategy
 		changed = true
@@ -318,6 +493,10 @@ func daemonsetConfigChanged(current, expected *appsv1.DaemonSet) (bool, *appsv1.
 		updated.Spec.Template.Spec.NodeSelector = expected.Spec.Template.Spec.NodeSelector
 		changed = true
 	}
+	if !cmp.Equal(current.Spec.Template.Spec.Affinity, expected.Spec.Template.Spec.Affinity, cmpopts.EquateEmpty()) {
+		updated.Spec.Template.Spec.Affinity = expected.Spec.Template.Spec.Affinity
+		changed = true
+	}
 	if !cmp.Equal(current.Spec.Template.Spec.TerminationGracePeriodSeconds, expected.Spec.Template.Spec.TerminationGracePeriodSeconds, cmpopts.EquateEmpty(), cmp.Comparer(cmpTerminationGracePeriodSeconds)) {
 		updated.Spec.Template.Spec.TerminationGracePeriodSeconds = expected.Spec.Template.Spec.TerminationGracePeriodSeconds
 		changed = true
@@ -2,7 +2,10 @@ package controller
 
 import (
 	"reflect"
+	"regexp"
+	"strconv"
 	"testing"
+	"time"
 
 	operatorv1 "github.com/openshift/api/operator/v1"
 
@@ -23,7 +26,7 @@ func TestDesiredDNSDaemonset(t *testing.T) {
 		},
 	}
 
-	if ds, err := desiredDNSDaemonSet(dns, coreDNSImage, kubeRBACProxyImage); err != nil {
+	if ds, err := desiredDNSDaemonSet(dns, coreDNSImage, kubeRBACProxyImage, "", DNSMetricsSecretName(dns), "", nil, nil, nil, nil, ""); err != nil {
 		t.Errorf("invalid dns daemonset: %v", err)
 	} else {
 		// Validate the daemonset
@@ -36,6 +39,9 @@ func TestDesiredDNSDaemonset(t *testing.T) {
 		expectedTolerations := []corev1.Toleration{{
 			Key:      "node-role.kubernetes.io/master",
 			Operator: corev1.TolerationOpExists,
+		}, {
+			Key:      "node-role.kubernetes.io/control-plane",
+			Operator: corev1.TolerationOpExists,
 		}}
 		if !reflect.DeepEqual(actualTolerations, expectedTolerations) {
 			t.Errorf("unexpected tolerations: expected %#v, got %#v", expectedTolerations, actualTolerations)
@@ -60,6 +66,93 @@ func TestDesiredDNSDaemonset(t *testing.T) {
 	}
 }
 
+// TestDesiredDNSDaemonsetWithoutKubeRBACProxy verifies that desiredDNSDaemonSet
+// omits the kube-rbac-proxy sidecar and exposes the CoreDNS metrics port when
+// no kube-rbac-proxy image is given.
+// TestDesiredDNSDaemonsetGracefulTermination verifies that a terminating dns
+// pod keeps answering queries, first during its preStop hook and then during
+// CoreDNS's lameduck period, for dnsShutdownDelay and that the termination
+// grace period allows for it.
+func TestDesiredDNSDaemonsetGracefulTermination(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DefaultDNSController,
+		},
+	}
+	ds, err := desiredDNSDaemonSet(dns, "coredns", "kube-rbac-proxy", "", DNSMetricsSecretName(dns), "", nil, nil, nil, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var preStopDelay time.Duration
+	for _, c := range ds.Spec.Template.Spec.Containers {
+		if c.Name != "dns" {
+			continue
+		}
+		if c.Lifecycle == nil || c.Lifecycle.PreStop == nil || c.Lifecycle.PreStop.Exec == nil || len(c.Lifecycle.PreStop.Exec.Command) != 2 || c.Lifecycle.PreStop.Exec.Command[0] != "sleep" {
+			t.Fatalf("expected the dns container to have a preStop hook that sleeps, got %#v", c.Lifecycle)
+		}
+		seconds, err := strconv.Atoi(c.Lifecycle.PreStop.Exec.Command[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		preStopDelay = time.Duration(seconds) * time.Second
+	}
+
+	cm, err := desiredDNSConfigMap(dns, corefileInputs{ClusterDomain: "cluster.local", MetricsAddress: "127.0.0.1:9153"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	match := regexp.MustCompile(`lameduck (\S+)`).FindStringSubmatch(cm.Data["Corefile"])
+	if match == nil {
+		t.Fatalf("expected the Corefile to have a lameduck period, got:\n%s", cm.Data["Corefile"])
+	}
+	lameduck, err := time.ParseDuration(match[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if preStopDelay+lameduck != dnsShutdownDelay {
+		t.Errorf("expected the preStop delay %s and the lameduck period %s to add up to %s", preStopDelay, lameduck, dnsShutdownDelay)
+	}
+	grace := ds.Spec.Template.Spec.TerminationGracePeriodSeconds
+	if grace == nil || time.Duration(*grace)*time.Second <= dnsShutdownDelay {
+		t.Errorf("expected a termination grace period longer than %s, got %v", dnsShutdownDelay, grace)
+	}
+}
+
+func TestDesiredDNSDaemonsetWithoutKubeRBACProxy(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DefaultDNSController,
+		},
+	}
+	ds, err := desiredDNSDaemonSet(dns, "quay.io/openshift/coredns:test", "", "", "", "", nil, nil, nil, nil, "")
+	if err != nil {
+		t.Fatalf("invalid dns daemonset: %v", err)
+	}
+	if len(ds.Spec.Template.Spec.Containers) != 1 {
+		t.Fatalf("expected number of daemonset containers 1, got %d", len(ds.Spec.Template.Spec.Containers))
+	}
+	c := ds.Spec.Template.Spec.Containers[0]
+	if c.Name != "dns" {
+		t.Fatalf("unexpected daemonset container %q", c.Name)
+	}
+	foundPort := false
+	for _, port := range c.Ports {
+		if port.Name == "metrics" && port.ContainerPort == CoreDNSMetricsPort {
+			foundPort = true
+		}
+	}
+	if !foundPort {
+		t.Errorf("expected dns container to expose metrics port %d, got %#v", CoreDNSMetricsPort, c.Ports)
+	}
+	for _, v := range ds.Spec.Template.Spec.Volumes {
+		if v.Name == "metrics-tls" {
+			t.Errorf("expected daemonset not to have volume %q", v.Name)
+		}
+	}
+}
+
 // TestDesiredDNSDaemonsetNodePlacement verifies that desiredDNSDaemonSet
 // respects the DNS pod placement API.
 func TestDesiredDNSDaemonsetNodePlacement(t *testing.T) {
@@ -83,7 +176,7 @@ func TestDesiredDNSDaemonsetNodePlacement(t *testing.T) {
 			},
 		},
 	}
-	if ds, err := desiredDNSDaemonSet(dns, "", ""); err != nil {
+	if ds, err := desiredDNSDaemonSet(dns, "", "", "", "", "", nil, nil, nil, nil, ""); err != nil {
 		t.Errorf("invalid dns daemonset: %v", err)
 	} else {
 		actualNodeSelector := ds.Spec.Template.Spec.NodeSelector
@@ -97,6 +190,92 @@ func TestDesiredDNSDaemonsetNodePlacement(t *testing.T) {
 	}
 }
 
+// TestDesiredDNSDaemonsetPreferInfraNodes verifies that desiredDNSDaemonSet
+// adds a toleration and a preferred node affinity term for infra nodes if the
+// DNS prefers infra nodes, and leaves a node placement that specifies
+// tolerations unchanged.
+func TestDesiredDNSDaemonsetPreferInfraNodes(t *testing.T) {
+	infraToleration := corev1.Toleration{
+		Key:      "node-role.kubernetes.io/infra",
+		Operator: corev1.TolerationOpExists,
+	}
+	testCases := []struct {
+		description      string
+		annotation       string
+		tolerations      []corev1.Toleration
+		expectInfra      bool
+		expectToleration bool
+	}{
+		{
+			description: "no annotation",
+		},
+		{
+			description: "annotation set to false",
+			annotation:  "false",
+		},
+		{
+			description: "invalid annotation",
+			annotation:  "yes please",
+		},
+		{
+			description:      "annotation set to true",
+			annotation:       "true",
+			expectInfra:      true,
+			expectToleration: true,
+		},
+		{
+			description: "annotation set to true with custom tolerations",
+			annotation:  "true",
+			tolerations: []corev1.Toleration{toleration},
+			expectInfra: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			dns := &operatorv1.DNS{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: DefaultDNSController,
+				},
+				Spec: operatorv1.DNSSpec{
+					NodePlacement: operatorv1.DNSNodePlacement{
+						Tolerations: tc.tolerations,
+					},
+				},
+			}
+			if len(tc.annotation) != 0 {
+				dns.Annotations = map[string]string{
+					PreferInfraNodesAnnotation: tc.annotation,
+				}
+			}
+			ds, err := desiredDNSDaemonSet(dns, "", "", "", "", "", nil, nil, nil, nil, "")
+			if err != nil {
+				t.Fatalf("invalid dns daemonset: %v", err)
+			}
+			affinity := ds.Spec.Template.Spec.Affinity
+			switch {
+			case tc.expectInfra && affinity == nil:
+				t.Errorf("expected node affinity for infra nodes, got nil")
+			case tc.expectInfra:
+				terms := affinity.NodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution
+				if len(terms) != 1 || terms[0].Preference.MatchExpressions[0].Key != "node-role.kubernetes.io/infra" {
+					t.Errorf("unexpected preferred node affinity terms: %#v", terms)
+				}
+			case affinity != nil:
+				t.Errorf("expected nil affinity, got %#v", affinity)
+			}
+			hasToleration := false
+			for _, toleration := range ds.Spec.Template.Spec.Tolerations {
+				if reflect.DeepEqual(toleration, infraToleration) {
+					hasToleration = true
+				}
+			}
+			if hasToleration != tc.expectToleration {
+				t.Errorf("expected infra toleration to be %t, got tolerations %#v", tc.expectToleration, ds.Spec.Template.Spec.Tolerations)
+			}
+		})
+	}
+}
+
 var toleration = corev1.Toleration{
 	Key:      "foo",
 	Value:    "bar",
@@ -138,6 +317,15 @@ func TestDaemonsetConfigChanged(t *testing.T) {
 			},
 			expect: true,
 		},
+		{
+			description: "if .spec.template.spec.affinity changes",
+			mutate: func(daemonset *appsv1.DaemonSet) {
+				daemonset.Spec.Template.Spec.Affinity = &corev1.Affinity{
+					NodeAffinity: &corev1.NodeAffinity{},
+				}
+			},
+			expect: true,
+		},
 		{
 			description: "if .spec.template.spec.volumes changes",
 			mutate: func(daemonset *appsv1.DaemonSet) {
@@ -0,0 +1,272 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// DNSDefaultUpstreamsObservedConditionType is the type of the DNS
+	// status condition that reports the upstream resolvers in the
+	// /etc/resolv.conf file that the dns pods use for the default server
+	// block, for each group of nodes.  The condition is reported only if
+	// the DNS's ObserveDefaultUpstreamsAnnotation annotation is set to
+	// true.
+	DNSDefaultUpstreamsObservedConditionType = conditions.TypeDefaultUpstreamsObserved
+
+	// resolvConfProbeLabel is the label on the pods that read
+	// /etc/resolv.conf on a node of each node group.
+	resolvConfProbeLabel = "dns.operator.openshift.io/resolv-conf-probe"
+	// resolvConfProbeNodeGroupAnnotation is the annotation on a resolv.conf
+	// probe pod that names the node group whose resolv.conf it reads.
+	resolvConfProbeNodeGroupAnnotation = "dns.operator.openshift.io/node-group"
+
+	// defaultUpstreamsRefreshPeriod is the interval at which the operator
+	// reads the nodes' resolv.conf files again.
+	defaultUpstreamsRefreshPeriod = time.Hour
+)
+
+// observeDefaultUpstreams returns a Boolean value indicating whether the given
+// dns's ObserveDefaultUpstreamsAnnotation annotation is set to true.
+func observeDefaultUpstreams(dns *operatorv1.DNS) bool {
+	value, ok := dns.Annotations[ObserveDefaultUpstreamsAnnotation]
+	if !ok {
+		return false
+	}
+	observe, err := strconv.ParseBool(value)
+	if err != nil {
+		logrus.Warningf("ignoring invalid value %q for annotation %s on dns %s: %v", value, ObserveDefaultUpstreamsAnnotation, dns.Name, err)
+		return false
+	}
+	return observe
+}
+
+// nodeGroups groups the given nodes that match the given node selector by the
+// rendered machine config that they run, which determines how their
+// /etc/resolv.conf files are generated.  Nodes without a machine config form
+// one group with the empty name.  Returns the names of the nodes in each
+// group.
+func nodeGroups(nodes []corev1.Node, selector labels.Selector) map[string]sets.String {
+	groups := map[string]sets.String{}
+	for _, node := range nodes {
+		if !selector.Matches(labels.Set(node.Labels)) {
+			continue
+		}
+		group := node.Annotations[currentMachineConfigAnnotation]
+		if _, ok := groups[group]; !ok {
+			groups[group] = sets.NewString()
+		}
+		groups[group].Insert(node.Name)
+	}
+	return groups
+}
+
+// parseResolvConfNameservers returns the addresses of the nameserver lines in
+// the given resolv.conf contents.
+func parseResolvConfNameservers(resolvConf string) []string {
+	var nameservers []string
+	for _, line := range strings.Split(resolvConf, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			nameservers = append(nameservers, fields[1])
+		}
+	}
+	return nameservers
+}
+
+// desiredResolvConfProbePod returns a pod that runs on the given node of the
+// given node group, with the node's resolv.conf, and writes the resolv.conf to
+// its termination message.
+func desiredResolvConfProbePod(dns *operatorv1.DNS, group, nodeName, image string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "dns-" + dns.Name + "-resolv-conf-",
+			Namespace:    DefaultOperandNamespace,
+			Labels: map[string]string{
+				resolvConfProbeLabel: dns.Name,
+			},
+			Annotations: map[string]string{
+				resolvConfProbeNodeGroupAnnotation: group,
+			},
+			OwnerReferences: []metav1.OwnerReference{dnsOwnerRef(dns)},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name:                     "resolv-conf",
+				Image:                    image,
+				ImagePullPolicy:          corev1.PullIfNotPresent,
+				Command:                  []string{"/bin/sh", "-c", "cat /etc/resolv.conf > /dev/termination-log"},
+				TerminationMessagePolicy: corev1.TerminationMessageReadFile,
+			}},
+			// The Default policy gives the pod the node's
+			// resolv.conf, as it does the dns pods.
+			DNSPolicy:          corev1.DNSDefault,
+			NodeName:           nodeName,
+			PriorityClassName:  "system-node-critical",
+			RestartPolicy:      corev1.RestartPolicyNever,
+			ServiceAccountName: "dns",
+			Tolerations:        tolerationsForDNS(dns),
+		},
+	}
+}
+
+// resolvConfProbeResult inspects the given resolv.conf probe pod and returns
+// the nameservers that it read and a Boolean value indicating whether it has
+// finished, and a message describing the failure if it failed.
+func resolvConfProbeResult(pod *corev1.Pod) ([]string, bool, string) {
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.State.Waiting != nil && imagePullFailureReasons[cs.State.Waiting.Reason] {
+			return nil, true, fmt.Sprintf("image %q cannot be pulled: %s", cs.Image, cs.State.Waiting.Reason)
+		}
+		if terminated := cs.State.Terminated; terminated != nil {
+			if terminated.ExitCode != 0 {
+				return nil, true, fmt.Sprintf("the pod exited with code %d", terminated.ExitCode)
+			}
+			return parseResolvConfNameservers(terminated.Message), true, ""
+		}
+	}
+	return nil, false, ""
+}
+
+// ensureDefaultUpstreamsObserved ensures that a resolv.conf probe pod runs on
+// a node of each node group where the given dns's pods run if the dns's
+// ObserveDefaultUpstreamsAnnotation annotation is set to true, and that no
+// probe pods exist otherwise.  Probe pods are recreated after
+// defaultUpstreamsRefreshPeriod.  Returns a status condition that reports the
+// upstreams of each node group, or nil if the annotation is not set.
+func (r *reconciler) ensureDefaultUpstreamsObserved(dns *operatorv1.DNS) (*operatorv1.OperatorCondition, error) {
+	podList := &corev1.PodList{}
+	listOpts := []client.ListOption{
+		client.MatchingLabels{resolvConfProbeLabel: dns.Name},
+		client.InNamespace(DefaultOperandNamespace),
+	}
+	if err := r.cache.List(context.TODO(), podList, listOpts...); err != nil {
+		return nil, fmt.Errorf("failed to list resolv.conf probe pods for dns %s: %w", dns.Name, err)
+	}
+
+	if !observeDefaultUpstreams(dns) {
+		for i := range podList.Items {
+			if err := r.deleteResolvConfProbePod(&podList.Items[i]); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	}
+
+	allNodes, err := ListNodes(context.TODO(), r.cache)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+	groups := nodeGroups(allNodes, labels.SelectorFromSet(nodeSelectorForDNS(dns)))
+
+	// Keep one current pod for each group, and delete pods for groups
+	// that no longer exist, pods on nodes that left their group, stale
+	// pods, and duplicates.
+	pods := map[string]*corev1.Pod{}
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		group := pod.Annotations[resolvConfProbeNodeGroupAnnotation]
+		nodes, ok := groups[group]
+		stale := time.Since(pod.CreationTimestamp.Time) > defaultUpstreamsRefreshPeriod
+		if _, dup := pods[group]; !ok || !nodes.Has(pod.Spec.NodeName) || stale || dup {
+			if err := r.deleteResolvConfProbePod(pod); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		pods[group] = pod
+	}
+
+	groupNames := make([]string, 0, len(groups))
+	for group := range groups {
+		groupNames = append(groupNames, group)
+	}
+	sort.Strings(groupNames)
+	var observed, pending, failed []string
+	for _, group := range groupNames {
+		groupDesc := describeNodeGroup(group, groups[group])
+		pod, ok := pods[group]
+		if !ok {
+			desired := desiredResolvConfProbePod(dns, group, groups[group].List()[0], r.OpenshiftCLIImage)
+			if err := r.client.Create(context.TODO(), desired); err != nil {
+				return nil, fmt.Errorf("failed to create resolv.conf probe pod for %s: %w", groupDesc, err)
+			}
+			logrus.Infof("created resolv.conf probe pod %s/%s for %s", desired.Namespace, desired.Name, groupDesc)
+			pending = append(pending, groupDesc)
+			continue
+		}
+		nameservers, done, failure := resolvConfProbeResult(pod)
+		switch {
+		case len(failure) != 0:
+			failed = append(failed, fmt.Sprintf("%s: %s", groupDesc, failure))
+		case !done:
+			pending = append(pending, groupDesc)
+		case len(nameservers) == 0:
+			observed = append(observed, fmt.Sprintf("%s: no nameservers", groupDesc))
+		default:
+			observed = append(observed, fmt.Sprintf("%s: %s", groupDesc, strings.Join(nameservers, ", ")))
+		}
+	}
+
+	condition := &operatorv1.OperatorCondition{
+		Type: DNSDefaultUpstreamsObservedConditionType,
+	}
+	switch {
+	case len(groups) == 0:
+		condition.Status = operatorv1.ConditionUnknown
+		condition.Reason = conditions.ReasonNoNodes
+		condition.Message = "No nodes match the DNS's node selector."
+	case len(failed) != 0:
+		condition.Status = operatorv1.ConditionFalse
+		condition.Reason = conditions.ReasonObservationFailed
+		condition.Message = fmt.Sprintf("Failed to read /etc/resolv.conf for %s.", strings.Join(failed, "; "))
+	case len(pending) != 0:
+		condition.Status = operatorv1.ConditionUnknown
+		condition.Reason = conditions.ReasonObserving
+		condition.Message = fmt.Sprintf("Reading /etc/resolv.conf for %s.", strings.Join(pending, "; "))
+	default:
+		condition.Status = operatorv1.ConditionTrue
+		condition.Reason = conditions.ReasonAsExpected
+		condition.Message = fmt.Sprintf("The default server block forwards to the upstreams in /etc/resolv.conf, which are %s.", strings.Join(observed, "; "))
+	}
+	return condition, nil
+}
+
+// describeNodeGroup returns a description of the node group with the given
+// name and nodes for a status message.
+func describeNodeGroup(group string, nodes sets.String) string {
+	if len(group) == 0 {
+		return fmt.Sprintf("%d nodes without a machine config", nodes.Len())
+	}
+	return fmt.Sprintf("%d nodes with machine config %s", nodes.Len(), group)
+}
+
+// deleteResolvConfProbePod deletes the given resolv.conf probe pod.
+func (r *reconciler) deleteResolvConfProbePod(pod *corev1.Pod) error {
+	if err := r.client.Delete(context.TODO(), pod); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to delete resolv.conf probe pod %s/%s: %w", pod.Namespace, pod.Name, err)
+	}
+	logrus.Infof("deleted resolv.conf probe pod %s/%s", pod.Namespace, pod.Name)
+	return nil
+}
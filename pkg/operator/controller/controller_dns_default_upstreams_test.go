@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// TestNodeGroups verifies that nodeGroups groups the nodes that match the
+// selector by machine config and ignores duplicate nodes.
+func TestNodeGroups(t *testing.T) {
+	node := func(name, config string, linux bool) corev1.Node {
+		n := corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{}}}
+		if linux {
+			n.Labels["kubernetes.io/os"] = "linux"
+		}
+		if len(config) != 0 {
+			n.Annotations = map[string]string{currentMachineConfigAnnotation: config}
+		}
+		return n
+	}
+	nodes := []corev1.Node{
+		node("master-0", "rendered-master-1", true),
+		node("worker-0", "rendered-worker-1", true),
+		node("worker-1", "rendered-worker-1", true),
+		node("worker-1", "rendered-worker-1", true),
+		node("edge-0", "", true),
+		node("windows-0", "", false),
+	}
+	expected := map[string]sets.String{
+		"rendered-master-1": sets.NewString("master-0"),
+		"rendered-worker-1": sets.NewString("worker-0", "worker-1"),
+		"":                  sets.NewString("edge-0"),
+	}
+	actual := nodeGroups(nodes, labels.SelectorFromSet(map[string]string{"kubernetes.io/os": "linux"}))
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
+
+// TestResolvConfProbeResult verifies that resolvConfProbeResult parses the
+// nameservers from a completed probe pod's termination message.
+func TestResolvConfProbeResult(t *testing.T) {
+	pod := func(state corev1.ContainerState) *corev1.Pod {
+		return &corev1.Pod{
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{{Name: "resolv-conf", State: state}},
+			},
+		}
+	}
+	resolvConf := "# Generated by NetworkManager\nsearch ec2.internal\nnameserver 10.0.0.2\nnameserver  fd00::2\noptions ndots:1\n"
+	testCases := []struct {
+		description       string
+		pod               *corev1.Pod
+		expectNameservers []string
+		expectDone        bool
+		expectFailure     bool
+	}{
+		{
+			description: "pending",
+			pod:         &corev1.Pod{},
+		},
+		{
+			description:   "image pull failure",
+			pod:           pod(corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ErrImagePull"}}),
+			expectDone:    true,
+			expectFailure: true,
+		},
+		{
+			description:   "nonzero exit",
+			pod:           pod(corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 1}}),
+			expectDone:    true,
+			expectFailure: true,
+		},
+		{
+			description:       "completed",
+			pod:               pod(corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Message: resolvConf}}),
+			expectNameservers: []string{"10.0.0.2", "fd00::2"},
+			expectDone:        true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			nameservers, done, failure := resolvConfProbeResult(tc.pod)
+			if !reflect.DeepEqual(nameservers, tc.expectNameservers) {
+				t.Errorf("expected nameservers %v, got %v", tc.expectNameservers, nameservers)
+			}
+			if done != tc.expectDone {
+				t.Errorf("expected done %t, got %t", tc.expectDone, done)
+			}
+			if (len(failure) != 0) != tc.expectFailure {
+				t.Errorf("expected failure %t, got %q", tc.expectFailure, failure)
+			}
+		})
+	}
+}
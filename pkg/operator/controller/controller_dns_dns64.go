@@ -0,0 +1,137 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	configv1 "github.com/openshift/api/config/v1"
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// DNSDNS64ActiveConditionType is the type of the DNS status condition that
+// reports whether CoreDNS synthesizes AAAA records with DNS64.  The condition
+// is reported only if the dns has the DNS64Annotation annotation.
+const DNSDNS64ActiveConditionType = conditions.TypeDNS64Active
+
+// corefileDNS64 is the configuration of CoreDNS's dns64 plugin.
+type corefileDNS64 struct {
+	// Prefix is the IPv6 prefix, in CIDR notation, with which AAAA records
+	// are synthesized from A records.
+	Prefix string
+	// TranslateAll, if true, synthesizes AAAA records for names that have
+	// AAAA records too.
+	TranslateAll bool
+}
+
+// defaultDNS64 is the DNS64 configuration of a dns whose DNS64Annotation
+// annotation sets no prefix.  64:ff9b::/96 is the well-known prefix of RFC
+// 6052.
+var defaultDNS64 = corefileDNS64{
+	Prefix: "64:ff9b::/96",
+}
+
+// dns64PrefixLengths are the prefix lengths that RFC 6052 allows for DNS64.
+var dns64PrefixLengths = map[int]bool{32: true, 40: true, 48: true, 56: true, 64: true, 96: true}
+
+// dnsDNS64 returns the DNS64 configuration in the given dns's DNS64Annotation
+// annotation and a status condition that reports whether it is active, or nil
+// and a nil condition if the dns does not have the annotation.  The
+// configuration is nil unless the cluster network has an IPv6 network, because
+// the synthesized addresses are reachable only over IPv6.
+func (r *reconciler) dnsDNS64(dns *operatorv1.DNS) (*corefileDNS64, *operatorv1.OperatorCondition, error) {
+	if _, ok := dns.Annotations[DNS64Annotation]; !ok {
+		return nil, nil, nil
+	}
+	networkConfig := &configv1.Network{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: "cluster"}, networkConfig); err != nil {
+		return nil, nil, fmt.Errorf("failed to get network 'cluster': %v", err)
+	}
+	config := dns64Config(dns)
+	ipv6 := clusterNetworkHasIPv6(networkConfig)
+	condition := computeDNS64ActiveCondition(config, ipv6)
+	if !ipv6 {
+		return nil, &condition, nil
+	}
+	return &config, &condition, nil
+}
+
+// dns64Config returns the DNS64 configuration in the given dns's
+// DNS64Annotation annotation.  The value is a comma- or space-delimited list of
+// <setting>=<value> entries.  Invalid entries are logged and ignored.
+func dns64Config(dns *operatorv1.DNS) corefileDNS64 {
+	config := defaultDNS64
+	list := dns.Annotations[DNS64Annotation]
+	for _, entry := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' }) {
+		i := strings.Index(entry, "=")
+		if i < 1 {
+			logrus.Warningf("ignoring malformed DNS64 setting %q in annotation %s on dns %s", entry, DNS64Annotation, dns.Name)
+			continue
+		}
+		value := entry[i+1:]
+		switch setting := entry[:i]; setting {
+		case "prefix":
+			ip, prefix, err := net.ParseCIDR(value)
+			if err != nil || ip.To4() != nil {
+				logrus.Warningf("ignoring DNS64 setting %q in annotation %s on dns %s: the prefix must be an IPv6 CIDR", entry, DNS64Annotation, dns.Name)
+				continue
+			}
+			if ones, _ := prefix.Mask.Size(); !dns64PrefixLengths[ones] {
+				logrus.Warningf("ignoring DNS64 setting %q in annotation %s on dns %s: the prefix length must be 32, 40, 48, 56, 64, or 96", entry, DNS64Annotation, dns.Name)
+				continue
+			}
+			config.Prefix = prefix.String()
+		case "translate-all":
+			translateAll, err := strconv.ParseBool(value)
+			if err != nil {
+				logrus.Warningf("ignoring DNS64 setting %q in annotation %s on dns %s: the value must be a Boolean value", entry, DNS64Annotation, dns.Name)
+				continue
+			}
+			config.TranslateAll = translateAll
+		default:
+			logrus.Warningf("ignoring unknown DNS64 setting %q in annotation %s on dns %s: the setting must be prefix or translate-all", entry, DNS64Annotation, dns.Name)
+		}
+	}
+	return config
+}
+
+// clusterNetworkHasIPv6 returns a Boolean value indicating whether the given
+// network config has an IPv6 cluster network, that is, whether the cluster is
+// IPv6-only or dual-stack.
+func clusterNetworkHasIPv6(networkConfig *configv1.Network) bool {
+	for _, entry := range networkConfig.Status.ClusterNetwork {
+		if ip, _, err := net.ParseCIDR(entry.CIDR); err == nil && ip.To4() == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// computeDNS64ActiveCondition returns a status condition that reports whether
+// the given DNS64 configuration is active, which it is only if the cluster
+// network has an IPv6 network.
+func computeDNS64ActiveCondition(config corefileDNS64, ipv6 bool) operatorv1.OperatorCondition {
+	condition := operatorv1.OperatorCondition{
+		Type: DNSDNS64ActiveConditionType,
+	}
+	if !ipv6 {
+		condition.Status = operatorv1.ConditionFalse
+		condition.Reason = conditions.ReasonNoIPv6ClusterNetwork
+		condition.Message = "DNS64 is not active because the cluster network has no IPv6 network."
+		return condition
+	}
+	condition.Status = operatorv1.ConditionTrue
+	condition.Reason = conditions.ReasonAsExpected
+	condition.Message = fmt.Sprintf("DNS64 synthesizes AAAA records with prefix %s.", config.Prefix)
+	if config.TranslateAll {
+		condition.Message = fmt.Sprintf("DNS64 synthesizes AAAA records for all names with prefix %s.", config.Prefix)
+	}
+	return condition
+}
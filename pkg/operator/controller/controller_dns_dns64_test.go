@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	configv1 "github.com/openshift/api/config/v1"
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestDNS64Config verifies that dns64Config applies valid settings and ignores
+// invalid ones.
+func TestDNS64Config(t *testing.T) {
+	testCases := []struct {
+		annotation string
+		expected   corefileDNS64
+	}{
+		{"", defaultDNS64},
+		{"prefix=2001:db8:64::/96", corefileDNS64{Prefix: "2001:db8:64::/96"}},
+		{"prefix=2001:db8:64:0:1::/64, translate-all=true", corefileDNS64{Prefix: "2001:db8:64::/64", TranslateAll: true}},
+		{"prefix=10.0.0.0/8", defaultDNS64},
+		{"prefix=2001:db8::/120", defaultDNS64},
+		{"translate-all=maybe,allow-ipv4=true,prefix", defaultDNS64},
+	}
+	for _, tc := range testCases {
+		dns := &operatorv1.DNS{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        DefaultDNSController,
+				Annotations: map[string]string{DNS64Annotation: tc.annotation},
+			},
+		}
+		if actual := dns64Config(dns); actual != tc.expected {
+			t.Errorf("%q: expected %+v, got %+v", tc.annotation, tc.expected, actual)
+		}
+	}
+}
+
+// TestClusterNetworkHasIPv6 verifies that clusterNetworkHasIPv6 detects
+// IPv6-only and dual-stack cluster networks.
+func TestClusterNetworkHasIPv6(t *testing.T) {
+	testCases := []struct {
+		cidrs    []string
+		expected bool
+	}{
+		{[]string{"10.128.0.0/14"}, false},
+		{[]string{"fd01::/48"}, true},
+		{[]string{"10.128.0.0/14", "fd01::/48"}, true},
+		{nil, false},
+	}
+	for _, tc := range testCases {
+		networkConfig := &configv1.Network{}
+		for _, cidr := range tc.cidrs {
+			networkConfig.Status.ClusterNetwork = append(networkConfig.Status.ClusterNetwork, configv1.ClusterNetworkEntry{CIDR: cidr})
+		}
+		if actual := clusterNetworkHasIPv6(networkConfig); actual != tc.expected {
+			t.Errorf("%v: expected %t, got %t", tc.cidrs, tc.expected, actual)
+		}
+	}
+}
+
+// TestDesiredDNSConfigMapDNS64 verifies that every server block has the dns64
+// plugin when DNS64 is active.
+func TestDesiredDNSConfigMapDNS64(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DefaultDNSController,
+		},
+	}
+	servers := []operatorv1.Server{{
+		Name:          "corp",
+		Zones:         []string{"corp.example.com"},
+		ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"10.0.0.53"}},
+	}}
+	dns64 := &corefileDNS64{Prefix: "64:ff9b::/96", TranslateAll: true}
+	cm, err := desiredDNSConfigMap(dns, corefileInputs{Servers: servers, DNS64: dns64, ClusterDomain: "cluster.local", MetricsAddress: "127.0.0.1:9153"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `
+    dns64 {
+        prefix 64:ff9b::/96
+        translate_all
+    }`
+	if n := strings.Count(cm.Data["Corefile"], expected); n != 2 {
+		t.Errorf("expected 2 server blocks with:%s\ngot %d in:\n%s", expected, n, cm.Data["Corefile"])
+	}
+
+	cm, err = desiredDNSConfigMap(dns, corefileInputs{Servers: servers, ClusterDomain: "cluster.local", MetricsAddress: "127.0.0.1:9153"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(cm.Data["Corefile"], "dns64") {
+		t.Errorf("expected no dns64 plugin, got:\n%s", cm.Data["Corefile"])
+	}
+}
@@ -0,0 +1,340 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	configv1 "github.com/openshift/api/config/v1"
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	"github.com/sirupsen/logrus"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// DNSOverHTTPSUpstreamsReadyConditionType is the type of the DNS
+	// status condition that reports whether the dns forwards queries to
+	// its servers' DNS-over-HTTPS upstreams.  The condition is reported
+	// only if a server has an https upstream.
+	DNSOverHTTPSUpstreamsReadyConditionType = conditions.TypeDNSOverHTTPSUpstreamsReady
+
+	// dohForwarderContainerName is the name of the dns pod's sidecar that
+	// forwards queries to DNS-over-HTTPS upstreams.
+	dohForwarderContainerName = "doh-forwarder"
+	// dohForwarderBasePort is the port on which the forwarder listens for
+	// the queries for the first DNS-over-HTTPS upstream; it listens for
+	// the queries for each subsequent upstream on the next port.
+	dohForwarderBasePort = 5400
+	// maxDoHUpstreams is the largest number of distinct DNS-over-HTTPS
+	// upstreams that a dns forwards to, which bounds the forwarder's
+	// ports.
+	maxDoHUpstreams = 32
+	// dohDefaultPath is the path of a DNS-over-HTTPS upstream's URL if
+	// the upstream specifies none, which is the path that RFC 8484's
+	// examples and most public resolvers use.
+	dohDefaultPath = "/dns-query"
+
+	// injectTrustedCABundleLabel is the label on a configmap that makes
+	// the cluster network operator inject the cluster's trusted CA
+	// bundle, which includes the proxy's trusted CA, into it.
+	injectTrustedCABundleLabel = "config.openshift.io/inject-trusted-cabundle"
+	// trustedCABundleKey is the key of the trusted CA bundle in the
+	// configmap.
+	trustedCABundleKey = "ca-bundle.crt"
+	// dohCABundleVolumeName is the name of the dns pod's volume with the
+	// trusted CA bundle.
+	dohCABundleVolumeName = "doh-forwarder-ca-bundle"
+	// dohCABundleDir is the directory in which the forwarder mounts the
+	// trusted CA bundle.
+	dohCABundleDir = "/etc/doh-forwarder/ca-bundle"
+	// dohCABundleHashAnnotation is the annotation on the dns pod template
+	// with a hash of the trusted CA bundle, so that the pods restart and
+	// the forwarder loads the bundle when it changes.
+	dohCABundleHashAnnotation = "dns.operator.openshift.io/doh-ca-bundle-hash"
+)
+
+// dohUpstream is a DNS-over-HTTPS upstream and the local address on which the
+// dns pod's forwarder listens for the queries to forward to it.
+type dohUpstream struct {
+	// URL is the upstream's normalized https URL.
+	URL string
+	// Address is the forwarder's host:port address for the upstream.
+	Address string
+}
+
+// dohForwarding is the configuration of the dns pod's forwarder for the dns's
+// DNS-over-HTTPS upstreams.
+type dohForwarding struct {
+	// Upstreams are the upstreams, ordered by URL.
+	Upstreams []dohUpstream
+	// Proxy is the cluster-wide proxy through which the forwarder reaches
+	// the upstreams.
+	Proxy configv1.ProxyStatus
+	// CABundleConfigMapName is the name of the configmap with the trusted
+	// CA bundle, or the empty string if the bundle has not been injected
+	// yet, in which case the forwarder uses its image's CA bundle.
+	CABundleConfigMapName string
+	// CABundleHash is a hash of the trusted CA bundle.
+	CABundleHash string
+}
+
+// isDoHUpstream returns a Boolean value indicating whether the given upstream
+// is a DNS-over-HTTPS URL rather than an address.
+func isDoHUpstream(upstream string) bool {
+	return strings.HasPrefix(strings.ToLower(upstream), "https://")
+}
+
+// normalizeDoHUpstream returns the given DNS-over-HTTPS upstream URL with a
+// lowercase host, without the default port, and with the default path if it
+// has none, and a Boolean value indicating whether the URL is valid.  The URL
+// must not have credentials, a query, or a fragment.
+func normalizeDoHUpstream(upstream string) (string, bool) {
+	u, err := url.Parse(upstream)
+	if err != nil || !strings.EqualFold(u.Scheme, "https") || len(u.Hostname()) == 0 {
+		return "", false
+	}
+	if u.User != nil || len(u.RawQuery) != 0 || len(u.Fragment) != 0 || u.Opaque != "" {
+		return "", false
+	}
+	host := strings.ToLower(u.Hostname())
+	if port := u.Port(); len(port) != 0 {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return "", false
+		}
+		if port != "443" {
+			host = net.JoinHostPort(host, port)
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	path := u.EscapedPath()
+	if len(path) == 0 || path == "/" {
+		path = dohDefaultPath
+	}
+	return "https://" + host + path, true
+}
+
+// dohUpstreamURLs returns the distinct normalized DNS-over-HTTPS upstreams of
+// the given servers, in sorted order.
+func dohUpstreamURLs(servers []operatorv1.Server) []string {
+	seen := map[string]bool{}
+	var urls []string
+	for _, server := range servers {
+		for _, upstream := range server.ForwardPlugin.Upstreams {
+			if !isDoHUpstream(upstream) {
+				continue
+			}
+			if u, ok := normalizeDoHUpstream(upstream); ok && !seen[u] {
+				seen[u] = true
+				urls = append(urls, u)
+			}
+		}
+	}
+	sort.Strings(urls)
+	return urls
+}
+
+// dnsDoHForwarding returns the forwarder configuration for the given
+// DNS-over-HTTPS upstream URLs of the given dns and a status condition that
+// reports the upstreams that the dns forwards to.  It ensures that the
+// configmap for the trusted CA bundle exists.  If the operator does not know
+// its own image, which the forwarder runs, it returns nil.
+func (r *reconciler) dnsDoHForwarding(dns *operatorv1.DNS, urls []string) (*dohForwarding, operatorv1.OperatorCondition, error) {
+	condition := operatorv1.OperatorCondition{
+		Type: DNSOverHTTPSUpstreamsReadyConditionType,
+	}
+	if len(r.OperatorImage) == 0 {
+		condition.Status = operatorv1.ConditionFalse
+		condition.Reason = conditions.ReasonForwarderImageUnavailable
+		condition.Message = fmt.Sprintf("DNS-over-HTTPS upstreams %s were omitted because the operator's image, which forwards queries to them, is not configured.", strings.Join(urls, ", "))
+		return nil, condition, nil
+	}
+	var omitted []string
+	if len(urls) > maxDoHUpstreams {
+		urls, omitted = urls[:maxDoHUpstreams], urls[maxDoHUpstreams:]
+	}
+	doh := &dohForwarding{}
+	for i, u := range urls {
+		doh.Upstreams = append(doh.Upstreams, dohUpstream{
+			URL:     u,
+			Address: net.JoinHostPort("127.0.0.1", strconv.Itoa(dohForwarderBasePort+i)),
+		})
+	}
+
+	proxy := &configv1.Proxy{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: "cluster"}, proxy); err != nil {
+		if !errors.IsNotFound(err) {
+			return nil, condition, fmt.Errorf("failed to get cluster proxy: %w", err)
+		}
+	} else {
+		doh.Proxy = proxy.Status
+	}
+
+	cm, err := r.ensureDNSTrustedCABundleConfigMap(dns)
+	if err != nil {
+		return nil, condition, err
+	}
+	if bundle := cm.Data[trustedCABundleKey]; len(bundle) != 0 {
+		hash, err := computeHash(bundle)
+		if err != nil {
+			return nil, condition, err
+		}
+		doh.CABundleConfigMapName = cm.Name
+		doh.CABundleHash = hash
+	}
+
+	if len(omitted) != 0 {
+		condition.Status = operatorv1.ConditionFalse
+		condition.Reason = conditions.ReasonDNSOverHTTPSUpstreamsOmitted
+		condition.Message = fmt.Sprintf("DNS-over-HTTPS upstreams %s were omitted because they exceed the limit of %d upstreams.", strings.Join(omitted, ", "), maxDoHUpstreams)
+		return doh, condition, nil
+	}
+	var via []string
+	if len(doh.Proxy.HTTPSProxy) != 0 {
+		via = append(via, "through the cluster-wide proxy")
+	}
+	if len(doh.CABundleConfigMapName) != 0 {
+		via = append(via, fmt.Sprintf("trusting the CA bundle in configmap %s/%s", cm.Namespace, cm.Name))
+	}
+	condition.Status = operatorv1.ConditionTrue
+	condition.Reason = conditions.ReasonAsExpected
+	condition.Message = fmt.Sprintf("Forwarding queries to DNS-over-HTTPS upstreams %s", strings.Join(urls, ", "))
+	if len(via) != 0 {
+		condition.Message += " " + strings.Join(via, " and ")
+	}
+	condition.Message += "."
+	return doh, condition, nil
+}
+
+// ensureDNSTrustedCABundleConfigMap ensures that the configmap into which the
+// cluster network operator injects the trusted CA bundle exists for the given
+// dns and returns it.  The operator creates the configmap empty and does not
+// update its data.
+func (r *reconciler) ensureDNSTrustedCABundleConfigMap(dns *operatorv1.DNS) (*corev1.ConfigMap, error) {
+	name := DNSTrustedCABundleConfigMapName(dns)
+	cm := &corev1.ConfigMap{}
+	if err := r.client.Get(context.TODO(), name, cm); err != nil {
+		if !errors.IsNotFound(err) {
+			return nil, fmt.Errorf("failed to get trusted ca bundle configmap %s: %w", name, err)
+		}
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            name.Name,
+				Namespace:       name.Namespace,
+				Labels:          map[string]string{injectTrustedCABundleLabel: "true"},
+				OwnerReferences: []metav1.OwnerReference{dnsOwnerRef(dns)},
+			},
+		}
+		if err := r.client.Create(context.TODO(), cm); err != nil {
+			return nil, fmt.Errorf("failed to create trusted ca bundle configmap %s: %w", name, err)
+		}
+		logrus.Infof("created trusted ca bundle configmap %s", name)
+	}
+	return cm, nil
+}
+
+// applyDoHForwarding returns copies of the given servers with each
+// DNS-over-HTTPS upstream replaced by the address on which the forwarder
+// listens for the upstream's queries.  Upstreams that the forwarder does not
+// forward to are omitted, and so is a server whose upstreams are all omitted.
+func applyDoHForwarding(servers []operatorv1.Server, doh *dohForwarding) []operatorv1.Server {
+	addresses := map[string]string{}
+	if doh != nil {
+		for _, upstream := range doh.Upstreams {
+			addresses[upstream.URL] = upstream.Address
+		}
+	}
+	var result []operatorv1.Server
+	for _, server := range servers {
+		var upstreams []string
+		for _, upstream := range server.ForwardPlugin.Upstreams {
+			if !isDoHUpstream(upstream) {
+				upstreams = append(upstreams, upstream)
+				continue
+			}
+			if u, ok := normalizeDoHUpstream(upstream); ok && len(addresses[u]) != 0 {
+				upstreams = append(upstreams, addresses[u])
+			}
+		}
+		if len(upstreams) == 0 {
+			continue
+		}
+		s := *server.DeepCopy()
+		s.ForwardPlugin.Upstreams = upstreams
+		result = append(result, s)
+	}
+	return result
+}
+
+// addDoHForwarderSidecar adds a sidecar to the given dns daemonset that runs
+// the given operator image's DNS-over-HTTPS forwarder with the given
+// configuration.
+func addDoHForwarderSidecar(daemonset *appsv1.DaemonSet, doh *dohForwarding, operatorImage string) {
+	// The forwarder keeps serving until CoreDNS stops forwarding to it
+	// when the pod terminates.
+	command := []string{"dns-operator", "doh-forwarder", "--shutdown-delay=" + dnsShutdownDelay.String()}
+	for _, upstream := range doh.Upstreams {
+		command = append(command, "--route="+upstream.Address+"="+upstream.URL)
+	}
+	var env []corev1.EnvVar
+	for _, v := range []struct{ name, value string }{
+		{"HTTP_PROXY", doh.Proxy.HTTPProxy},
+		{"HTTPS_PROXY", doh.Proxy.HTTPSProxy},
+		{"NO_PROXY", doh.Proxy.NoProxy},
+	} {
+		if len(v.value) != 0 {
+			env = append(env, corev1.EnvVar{Name: v.name, Value: v.value})
+		}
+	}
+	container := corev1.Container{
+		Name:            dohForwarderContainerName,
+		Image:           operatorImage,
+		ImagePullPolicy: corev1.PullIfNotPresent,
+		Command:         command,
+		Env:             env,
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("10m"),
+				corev1.ResourceMemory: resource.MustParse("20Mi"),
+			},
+		},
+		TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+	}
+	spec := &daemonset.Spec.Template.Spec
+	if len(doh.CABundleConfigMapName) != 0 {
+		container.Env = append(container.Env, corev1.EnvVar{Name: "SSL_CERT_FILE", Value: dohCABundleDir + "/" + trustedCABundleKey})
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      dohCABundleVolumeName,
+			MountPath: dohCABundleDir,
+			ReadOnly:  true,
+		})
+		spec.Volumes = append(spec.Volumes, corev1.Volume{
+			Name: dohCABundleVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: doh.CABundleConfigMapName},
+				},
+			},
+		})
+		if daemonset.Spec.Template.Annotations == nil {
+			daemonset.Spec.Template.Annotations = map[string]string{}
+		}
+		daemonset.Spec.Template.Annotations[dohCABundleHashAnnotation] = doh.CABundleHash
+	}
+	spec.Containers = append(spec.Containers, container)
+}
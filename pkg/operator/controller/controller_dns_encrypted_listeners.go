@@ -0,0 +1,237 @@
+package controller
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"strings"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	// DNSOverTLSAvailableConditionType is the type of the DNS status
+	// condition that reports whether the dns serves DNS-over-TLS.  The
+	// condition is reported only if the dns has the DNSOverTLSAnnotation
+	// annotation.
+	DNSOverTLSAvailableConditionType = conditions.TypeDNSOverTLSAvailable
+	// DNSOverHTTPSAvailableConditionType is the type of the DNS status
+	// condition that reports whether the dns serves DNS-over-HTTPS.  The
+	// condition is reported only if the dns has the DNSOverHTTPSAnnotation
+	// annotation.
+	DNSOverHTTPSAvailableConditionType = conditions.TypeDNSOverHTTPSAvailable
+
+	// encryptedListenerServiceCA is the value of an encrypted transport's
+	// annotation that selects the serving certificate that the service CA
+	// operator generates for the dns's service.
+	encryptedListenerServiceCA = "service-ca"
+)
+
+// encryptedTransport is a transport that encrypts DNS and on which CoreDNS can
+// serve DNS using a serving certificate.
+type encryptedTransport struct {
+	// Name is the name of the dns pod's port and volume for the
+	// transport.
+	Name string
+	// Description is the name of the transport in status conditions.
+	Description string
+	// Scheme is the scheme of the transport's server block in the
+	// Corefile.
+	Scheme string
+	// Annotation is the annotation on a DNS that enables the transport.
+	Annotation string
+	// ConditionType is the type of the DNS status condition that reports
+	// whether the dns serves the transport.
+	ConditionType string
+	// CertDir is the directory in which the dns pods mount the transport's
+	// serving certificate.
+	CertDir string
+	// CertificateHashAnnotation is the annotation on the dns pod template
+	// with a hash of the transport's serving certificate, so that the pods
+	// restart and load the certificate when it is rotated; CoreDNS reads
+	// the certificate only when it loads its configuration.
+	CertificateHashAnnotation string
+	// ContainerPort is the port on which CoreDNS serves the transport.
+	ContainerPort int32
+	// ServicePort is the port of the dns service on which it serves the
+	// transport.
+	ServicePort int32
+}
+
+var (
+	// dnsOverTLS is DNS-over-TLS, as specified in RFC 7858.
+	dnsOverTLS = encryptedTransport{
+		Name:                      "dns-over-tls",
+		Description:               "DNS-over-TLS",
+		Scheme:                    "tls",
+		Annotation:                DNSOverTLSAnnotation,
+		ConditionType:             DNSOverTLSAvailableConditionType,
+		CertDir:                   "/etc/coredns-tls",
+		CertificateHashAnnotation: "dns.operator.openshift.io/dns-over-tls-certificate-hash",
+		ContainerPort:             CoreDNSTLSPort,
+		ServicePort:               DNSOverTLSServicePort,
+	}
+	// dnsOverHTTPS is DNS-over-HTTPS, as specified in RFC 8484.  CoreDNS
+	// serves it at the "/dns-query" path.
+	dnsOverHTTPS = encryptedTransport{
+		Name:                      "dns-over-https",
+		Description:               "DNS-over-HTTPS",
+		Scheme:                    "https",
+		Annotation:                DNSOverHTTPSAnnotation,
+		ConditionType:             DNSOverHTTPSAvailableConditionType,
+		CertDir:                   "/etc/coredns-https",
+		CertificateHashAnnotation: "dns.operator.openshift.io/dns-over-https-certificate-hash",
+		ContainerPort:             CoreDNSHTTPSPort,
+		ServicePort:               DNSOverHTTPSServicePort,
+	}
+
+	// encryptedTransports are the encrypted transports that a dns can
+	// serve, in the order in which the Corefile has their server blocks.
+	encryptedTransports = []encryptedTransport{dnsOverTLS, dnsOverHTTPS}
+)
+
+// encryptedListener is an encrypted transport that a dns serves.
+type encryptedListener struct {
+	encryptedTransport
+	// SecretName is the name of the secret in the operand namespace with
+	// the serving certificate, or a SecretProviderReferencePrefix
+	// reference to the SecretProviderClass that provides it.
+	SecretName string
+	// CertificateHash is a hash of the serving certificate and key, or of
+	// the SecretProviderClass's generation.
+	CertificateHash string
+}
+
+// encryptedListenerSecretName returns the name of the secret with the serving
+// certificate that the given dns's annotation for the given transport selects,
+// or the empty string if the dns does not have the annotation.
+func encryptedListenerSecretName(dns *operatorv1.DNS, transport encryptedTransport) string {
+	value := strings.TrimSpace(dns.Annotations[transport.Annotation])
+	if value == encryptedListenerServiceCA {
+		return DNSMetricsSecretName(dns)
+	}
+	return value
+}
+
+// encryptedListener returns the listener for the given transport of the given
+// dns and a status condition that reports whether the serving certificate is
+// usable, or nil and a nil condition if the dns does not have the transport's
+// annotation.  If the certificate is not usable, the dns does not serve the
+// transport, because CoreDNS would fail to load its configuration.
+func (r *reconciler) encryptedListener(dns *operatorv1.DNS, transport encryptedTransport) (*encryptedListener, *operatorv1.OperatorCondition, error) {
+	name := encryptedListenerSecretName(dns, transport)
+	if len(name) == 0 {
+		return nil, nil, nil
+	}
+	condition := &operatorv1.OperatorCondition{
+		Type: transport.ConditionType,
+	}
+	if spc, ok := secretProviderClassName(name); ok {
+		hash, problem, err := r.secretProviderClassHash(spc)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to check %s serving certificate: %w", transport.Name, err)
+		}
+		if len(problem) != 0 {
+			condition.Status = operatorv1.ConditionFalse
+			condition.Reason = conditions.ReasonSecretProviderUnavailable
+			condition.Message = fmt.Sprintf("The %[1]s serving certificate cannot be mounted: %[2]s; not serving %[1]s.", transport.Description, problem)
+			return nil, condition, nil
+		}
+		condition.Status = operatorv1.ConditionTrue
+		condition.Reason = conditions.ReasonAsExpected
+		condition.Message = fmt.Sprintf("Serving %s on port %d using the certificate that SecretProviderClass %s provides.", transport.Description, transport.ServicePort, types.NamespacedName{Namespace: DefaultOperandNamespace, Name: spc})
+		return &encryptedListener{encryptedTransport: transport, SecretName: name, CertificateHash: hash}, condition, nil
+	}
+	secret := &corev1.Secret{}
+	secretName := types.NamespacedName{Namespace: DefaultOperandNamespace, Name: name}
+	if err := r.client.Get(context.TODO(), secretName, secret); err != nil {
+		if !errors.IsNotFound(err) {
+			return nil, nil, fmt.Errorf("failed to get %s serving certificate secret %s: %w", transport.Name, secretName, err)
+		}
+		condition.Status = operatorv1.ConditionFalse
+		condition.Reason = conditions.ReasonSecretNotFound
+		condition.Message = fmt.Sprintf("The %[1]s serving certificate secret %[2]s does not exist; not serving %[1]s.", transport.Description, secretName)
+		return nil, condition, nil
+	}
+	if err := validateServingCertificateSecret(secret); err != nil {
+		condition.Status = operatorv1.ConditionFalse
+		condition.Reason = conditions.ReasonInvalidSecret
+		condition.Message = fmt.Sprintf("The %[1]s serving certificate secret %[2]s is invalid: %[3]v; not serving %[1]s.", transport.Description, secretName, err)
+		return nil, condition, nil
+	}
+	hash, err := computeHash([][]byte{secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey]})
+	if err != nil {
+		return nil, nil, err
+	}
+	condition.Status = operatorv1.ConditionTrue
+	condition.Reason = conditions.ReasonAsExpected
+	condition.Message = fmt.Sprintf("Serving %s on port %d using the certificate in secret %s.", transport.Description, transport.ServicePort, secretName)
+	return &encryptedListener{encryptedTransport: transport, SecretName: name, CertificateHash: hash}, condition, nil
+}
+
+// validateServingCertificateSecret returns an error if the given secret does
+// not have a serving certificate and a matching key.
+func validateServingCertificateSecret(secret *corev1.Secret) error {
+	var missing []string
+	for _, key := range []string{corev1.TLSCertKey, corev1.TLSPrivateKeyKey} {
+		if len(secret.Data[key]) == 0 {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) != 0 {
+		return fmt.Errorf("missing or empty keys: %s", strings.Join(missing, ", "))
+	}
+	if _, err := tls.X509KeyPair(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey]); err != nil {
+		return err
+	}
+	return nil
+}
+
+// addEncryptedListener mounts the given listener's serving certificate in the
+// given dns daemonset's pods, exposes CoreDNS's port for the listener, and
+// records the certificate's hash in the pod template.
+func addEncryptedListener(daemonset *appsv1.DaemonSet, listener encryptedListener) {
+	spec := &daemonset.Spec.Template.Spec
+	spec.Volumes = append(spec.Volumes, corev1.Volume{
+		Name:         listener.Name,
+		VolumeSource: credentialVolumeSource(listener.SecretName),
+	})
+	for i := range spec.Containers {
+		if spec.Containers[i].Name != "dns" {
+			continue
+		}
+		spec.Containers[i].VolumeMounts = append(spec.Containers[i].VolumeMounts, corev1.VolumeMount{
+			Name:      listener.Name,
+			MountPath: listener.CertDir,
+			ReadOnly:  true,
+		})
+		spec.Containers[i].Ports = append(spec.Containers[i].Ports, corev1.ContainerPort{
+			Name:          listener.Name,
+			ContainerPort: listener.ContainerPort,
+			Protocol:      corev1.ProtocolTCP,
+		})
+	}
+	if daemonset.Spec.Template.Annotations == nil {
+		daemonset.Spec.Template.Annotations = map[string]string{}
+	}
+	daemonset.Spec.Template.Annotations[listener.CertificateHashAnnotation] = listener.CertificateHash
+}
+
+// encryptedListenerServicePort returns the port of the dns service on which it
+// serves the given listener.
+func encryptedListenerServicePort(listener encryptedListener) corev1.ServicePort {
+	return corev1.ServicePort{
+		Name:       listener.Name,
+		Port:       listener.ServicePort,
+		TargetPort: intstr.FromString(listener.Name),
+		Protocol:   corev1.ProtocolTCP,
+	}
+}
@@ -0,0 +1,215 @@
+package controller
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	corev1 "k8s.io/api/core/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// newTestServingCertificate returns a PEM-encoded self-signed certificate and
+// key for the default dns's service.
+func newTestServingCertificate(t *testing.T) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "dns-default.openshift-dns.svc"},
+		DNSNames:     []string{"dns-default.openshift-dns.svc"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// TestValidateServingCertificateSecret verifies that
+// validateServingCertificateSecret accepts only a secret with a serving
+// certificate and its key.
+func TestValidateServingCertificateSecret(t *testing.T) {
+	cert, key := newTestServingCertificate(t)
+	_, otherKey := newTestServingCertificate(t)
+	testCases := []struct {
+		name          string
+		data          map[string][]byte
+		expectedError string
+	}{
+		{
+			name: "valid",
+			data: map[string][]byte{"tls.crt": cert, "tls.key": key},
+		},
+		{
+			name:          "missing key",
+			data:          map[string][]byte{"tls.crt": cert},
+			expectedError: "missing or empty keys: tls.key",
+		},
+		{
+			name:          "mismatched key",
+			data:          map[string][]byte{"tls.crt": cert, "tls.key": otherKey},
+			expectedError: "private key does not match public key",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateServingCertificateSecret(&corev1.Secret{Data: tc.data})
+			switch {
+			case len(tc.expectedError) == 0 && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case len(tc.expectedError) != 0 && (err == nil || !strings.Contains(err.Error(), tc.expectedError)):
+				t.Errorf("expected error containing %q, got %v", tc.expectedError, err)
+			}
+		})
+	}
+}
+
+// TestEncryptedListenerSecretName verifies that encryptedListenerSecretName
+// selects the service CA generated secret or the secret that the dns names in
+// the transport's annotation.
+func TestEncryptedListenerSecretName(t *testing.T) {
+	testCases := []struct {
+		transport  encryptedTransport
+		annotation string
+		expected   string
+	}{
+		{dnsOverTLS, "", ""},
+		{dnsOverTLS, "service-ca", "dns-default-metrics-tls"},
+		{dnsOverTLS, " dot-cert ", "dot-cert"},
+		{dnsOverHTTPS, "doh-cert", "doh-cert"},
+	}
+	for _, tc := range testCases {
+		dns := &operatorv1.DNS{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        DefaultDNSController,
+				Annotations: map[string]string{tc.transport.Annotation: tc.annotation},
+			},
+		}
+		if actual := encryptedListenerSecretName(dns, tc.transport); actual != tc.expected {
+			t.Errorf("%s %q: expected %q, got %q", tc.transport.Name, tc.annotation, tc.expected, actual)
+		}
+	}
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        DefaultDNSController,
+			Annotations: map[string]string{DNSOverTLSAnnotation: "dot-cert"},
+		},
+	}
+	if actual := encryptedListenerSecretName(dns, dnsOverHTTPS); len(actual) != 0 {
+		t.Errorf("expected no DNS-over-HTTPS secret, got %q", actual)
+	}
+}
+
+// TestDesiredEncryptedListeners verifies that the Corefile, daemonset, and
+// service serve DNS-over-TLS and DNS-over-HTTPS only if the dns has a usable
+// serving certificate for them.
+func TestDesiredEncryptedListeners(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DefaultDNSController,
+		},
+	}
+	listeners := []encryptedListener{
+		{encryptedTransport: dnsOverTLS, SecretName: "dot-cert", CertificateHash: "abc"},
+		{encryptedTransport: dnsOverHTTPS, SecretName: "doh-cert", CertificateHash: "def"},
+	}
+
+	cm, err := desiredDNSConfigMap(dns, corefileInputs{EncryptedListeners: listeners, ClusterDomain: "cluster.local", MetricsAddress: "127.0.0.1:9153"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `# dns-over-tls
+tls://.:8853 {
+    tls /etc/coredns-tls/tls.crt /etc/coredns-tls/tls.key
+    forward . 127.0.0.1:5353
+    errors
+    prometheus 127.0.0.1:9153
+}
+# dns-over-https
+https://.:8443 {
+    tls /etc/coredns-https/tls.crt /etc/coredns-https/tls.key
+    forward . 127.0.0.1:5353
+    errors
+    prometheus 127.0.0.1:9153
+}
+.:5353 {`
+	if !strings.HasPrefix(cm.Data["Corefile"], expected) {
+		t.Errorf("expected Corefile to start with:\n%s\ngot:\n%s", expected, cm.Data["Corefile"])
+	}
+	if cm, err := desiredDNSConfigMap(dns, corefileInputs{ClusterDomain: "cluster.local", MetricsAddress: "127.0.0.1:9153"}); err != nil {
+		t.Fatal(err)
+	} else if strings.Contains(cm.Data["Corefile"], "tls://") || strings.Contains(cm.Data["Corefile"], "https://") {
+		t.Errorf("expected no encrypted server blocks, got:\n%s", cm.Data["Corefile"])
+	}
+
+	ds, err := desiredDNSDaemonSet(dns, "coredns", "kube-rbac-proxy", "", "dns-default-metrics-tls", "", listeners, nil, nil, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, listener := range listeners {
+		if actual := ds.Spec.Template.Annotations[listener.CertificateHashAnnotation]; actual != listener.CertificateHash {
+			t.Errorf("%s: expected certificate hash %q, got %q", listener.Name, listener.CertificateHash, actual)
+		}
+		var volumeFound, mountFound, portFound bool
+		for _, v := range ds.Spec.Template.Spec.Volumes {
+			if v.Name == listener.Name && v.Secret != nil && v.Secret.SecretName == listener.SecretName {
+				volumeFound = true
+			}
+		}
+		for _, c := range ds.Spec.Template.Spec.Containers {
+			if c.Name != "dns" {
+				continue
+			}
+			for _, m := range c.VolumeMounts {
+				if m.Name == listener.Name && m.MountPath == listener.CertDir {
+					mountFound = true
+				}
+			}
+			for _, p := range c.Ports {
+				if p.Name == listener.Name && p.ContainerPort == listener.ContainerPort {
+					portFound = true
+				}
+			}
+		}
+		if !volumeFound || !mountFound || !portFound {
+			t.Errorf("expected the dns container to mount secret %s and expose port %d, got volume %t, mount %t, port %t", listener.SecretName, listener.ContainerPort, volumeFound, mountFound, portFound)
+		}
+	}
+
+	svc, err := desiredDNSService(dns, "", listeners)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(svc.Spec.Ports) != 5 {
+		t.Fatalf("expected 5 service ports, got %v", svc.Spec.Ports)
+	}
+	for i, listener := range listeners {
+		if port := svc.Spec.Ports[3+i]; port.Port != listener.ServicePort || port.TargetPort.StrVal != listener.Name {
+			t.Errorf("expected the service to have port %d for %s, got %v", listener.ServicePort, listener.Name, port)
+		}
+	}
+	if svc, err := desiredDNSService(dns, "", nil); err != nil {
+		t.Fatal(err)
+	} else if len(svc.Spec.Ports) != 3 {
+		t.Errorf("expected 3 service ports, got %v", svc.Spec.Ports)
+	}
+}
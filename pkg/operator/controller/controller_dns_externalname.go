@@ -0,0 +1,196 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// DNSExternalNamePolicyCompliantConditionType is the type of the DNS
+	// status condition that reports the ExternalName services that violate
+	// the dns's ExternalName policy.  The condition is reported only if
+	// the dns has the ExternalNameAllowedDomainsAnnotation annotation.
+	DNSExternalNamePolicyCompliantConditionType = conditions.TypeExternalNamePolicyCompliant
+
+	// externalNameListPageSize is the number of services that the
+	// operator requests at a time when it checks ExternalName services.
+	externalNameListPageSize = 500
+	// maxReportedExternalNameViolations is the number of violations that
+	// the condition's message names.
+	maxReportedExternalNameViolations = 5
+)
+
+// externalNameViolation is an ExternalName service whose external name the
+// dns's ExternalName policy does not allow.
+type externalNameViolation struct {
+	Service      types.NamespacedName
+	ExternalName string
+}
+
+// externalNamePolicyEnabled returns a Boolean value indicating whether the
+// given dns restricts the external names of ExternalName services.
+func externalNamePolicyEnabled(dns *operatorv1.DNS) bool {
+	_, ok := dns.Annotations[ExternalNameAllowedDomainsAnnotation]
+	return ok
+}
+
+// externalNamePolicyEnforced returns a Boolean value indicating whether CoreDNS
+// refuses to resolve ExternalName services that violate the given dns's
+// ExternalName policy, rather than only reporting them.
+func externalNamePolicyEnforced(dns *operatorv1.DNS) bool {
+	switch value := strings.TrimSpace(dns.Annotations[ExternalNameEnforcementAnnotation]); strings.ToLower(value) {
+	case "enforce":
+		return true
+	case "", "audit":
+		return false
+	default:
+		logrus.Warningf("ignoring invalid value %q for annotation %s on dns %s: the value must be audit or enforce", value, ExternalNameEnforcementAnnotation, dns.Name)
+		return false
+	}
+}
+
+// externalNameAllowedDomains returns the domains, normalized with
+// normalizeZone, in the given dns's ExternalNameAllowedDomainsAnnotation
+// annotation.  Invalid domains are logged and ignored.
+func externalNameAllowedDomains(dns *operatorv1.DNS) sets.String {
+	domains := sets.NewString()
+	list := dns.Annotations[ExternalNameAllowedDomainsAnnotation]
+	for _, entry := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' }) {
+		domain := normalizeZone(entry)
+		if !validDomainName(domain) {
+			logrus.Warningf("ignoring invalid domain %q in annotation %s on dns %s", entry, ExternalNameAllowedDomainsAnnotation, dns.Name)
+			continue
+		}
+		domains.Insert(domain)
+	}
+	return domains
+}
+
+// dnsExternalNamePolicy returns the synthesized records that make CoreDNS
+// answer NXDOMAIN for the ExternalName services that violate the given dns's
+// ExternalName policy, if the dns enforces the policy, and a status condition
+// that reports the violations, or nil and a nil condition if the dns has no
+// ExternalName policy.
+func (r *reconciler) dnsExternalNamePolicy(dns *operatorv1.DNS, clusterDomain string) ([]synthesizedRecord, *operatorv1.OperatorCondition, error) {
+	if !externalNamePolicyEnabled(dns) {
+		return nil, nil, nil
+	}
+	// The operator's cache holds services only in the operator's
+	// namespaces, so list them with the client.
+	var services []corev1.Service
+	serviceList := &corev1.ServiceList{}
+	opts := []client.ListOption{client.Limit(externalNameListPageSize)}
+	for {
+		if err := r.client.List(context.TODO(), serviceList, opts...); err != nil {
+			return nil, nil, fmt.Errorf("failed to list services: %w", err)
+		}
+		services = append(services, serviceList.Items...)
+		if len(serviceList.Continue) == 0 {
+			break
+		}
+		opts = []client.ListOption{client.Limit(externalNameListPageSize), client.Continue(serviceList.Continue)}
+	}
+
+	violations := externalNameViolations(services, clusterDomain, externalNameAllowedDomains(dns))
+	enforced := externalNamePolicyEnforced(dns)
+	condition := computeExternalNamePolicyCompliantCondition(violations, enforced)
+	if !enforced {
+		return nil, &condition, nil
+	}
+	return externalNameBlockingRecords(violations, clusterDomain), &condition, nil
+}
+
+// externalNameViolations returns the ExternalName services among the given
+// services whose external names are not allowed, ordered by namespace and name.
+// An external name is allowed if it is in one of the given allowed domains,
+// which are normalized with normalizeZone, or if it is a name in the given
+// cluster domain in the service's own namespace, so that tenants cannot use
+// ExternalName services to reach each other's services.
+func externalNameViolations(services []corev1.Service, clusterDomain string, allowed sets.String) []externalNameViolation {
+	clusterDomain = normalizeZone(clusterDomain)
+	var violations []externalNameViolation
+	for _, svc := range services {
+		if svc.Spec.Type != corev1.ServiceTypeExternalName {
+			continue
+		}
+		name := normalizeZone(svc.Spec.ExternalName)
+		if nameInZone(name, clusterDomain) {
+			if nameInZone(name, svc.Namespace+".svc."+clusterDomain) {
+				continue
+			}
+		} else if nameInAnyZone(name, allowed) {
+			continue
+		}
+		violations = append(violations, externalNameViolation{
+			Service:      types.NamespacedName{Namespace: svc.Namespace, Name: svc.Name},
+			ExternalName: svc.Spec.ExternalName,
+		})
+	}
+	sort.Slice(violations, func(i, j int) bool {
+		return violations[i].Service.String() < violations[j].Service.String()
+	})
+	return violations
+}
+
+// externalNameBlockingRecords returns synthesized records that make CoreDNS
+// answer NXDOMAIN for the names of the given services in the given cluster
+// domain.  CoreDNS's template plugin precedes its kubernetes plugin, so the
+// records take precedence over the services' CNAME records.
+func externalNameBlockingRecords(violations []externalNameViolation, clusterDomain string) []synthesizedRecord {
+	var records []synthesizedRecord
+	for _, v := range violations {
+		records = append(records, synthesizedRecord{
+			Zone:  normalizeZone(v.Service.Name + "." + v.Service.Namespace + ".svc." + clusterDomain),
+			Class: "IN",
+			Type:  "ANY",
+			Rcode: "NXDOMAIN",
+		})
+	}
+	return records
+}
+
+// computeExternalNamePolicyCompliantCondition returns a status condition that
+// reports the given violations of a dns's ExternalName policy and whether the
+// policy is enforced.
+func computeExternalNamePolicyCompliantCondition(violations []externalNameViolation, enforced bool) operatorv1.OperatorCondition {
+	condition := operatorv1.OperatorCondition{
+		Type: DNSExternalNamePolicyCompliantConditionType,
+	}
+	if len(violations) == 0 {
+		condition.Status = operatorv1.ConditionTrue
+		condition.Reason = conditions.ReasonAsExpected
+		condition.Message = "Every ExternalName service points to an allowed name."
+		return condition
+	}
+	var names []string
+	for i, v := range violations {
+		if i == maxReportedExternalNameViolations {
+			names = append(names, fmt.Sprintf("and %d more", len(violations)-i))
+			break
+		}
+		names = append(names, fmt.Sprintf("%s (%s)", v.Service, v.ExternalName))
+	}
+	condition.Status = operatorv1.ConditionFalse
+	if enforced {
+		condition.Reason = conditions.ReasonExternalNameServicesBlocked
+		condition.Message = fmt.Sprintf("%d ExternalName services point to names that are not allowed and do not resolve: %s.", len(violations), strings.Join(names, ", "))
+		return condition
+	}
+	condition.Reason = conditions.ReasonExternalNameServicesNotAllowed
+	condition.Message = fmt.Sprintf("%d ExternalName services point to names that are not allowed: %s.", len(violations), strings.Join(names, ", "))
+	return condition
+}
@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	corev1 "k8s.io/api/core/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// TestExternalNameViolations verifies that externalNameViolations reports the
+// ExternalName services that point outside the allowed domains or to names in
+// other namespaces in the cluster domain.
+func TestExternalNameViolations(t *testing.T) {
+	service := func(namespace, name string, svcType corev1.ServiceType, externalName string) corev1.Service {
+		return corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Spec:       corev1.ServiceSpec{Type: svcType, ExternalName: externalName},
+		}
+	}
+	services := []corev1.Service{
+		service("team-b", "steal", corev1.ServiceTypeExternalName, "db.team-a.svc.cluster.local"),
+		service("team-a", "db-alias", corev1.ServiceTypeExternalName, "db.team-a.svc.cluster.local."),
+		service("team-a", "api", corev1.ServiceTypeExternalName, "API.Example.com"),
+		service("team-a", "partner", corev1.ServiceTypeExternalName, "api.partner.net"),
+		service("team-a", "db", corev1.ServiceTypeClusterIP, ""),
+		service("default", "openshift", corev1.ServiceTypeExternalName, "kubernetes.default.svc.cluster.local"),
+	}
+	expected := []externalNameViolation{
+		{Service: types.NamespacedName{Namespace: "team-a", Name: "partner"}, ExternalName: "api.partner.net"},
+		{Service: types.NamespacedName{Namespace: "team-b", Name: "steal"}, ExternalName: "db.team-a.svc.cluster.local"},
+	}
+	actual := externalNameViolations(services, "cluster.local", sets.NewString("example.com."))
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected violations %+v, got %+v", expected, actual)
+	}
+}
+
+// TestDNSExternalNamePolicyConfiguration verifies that the ExternalName policy
+// annotations enable the policy, parse the allowed domains, and select
+// enforcement.
+func TestDNSExternalNamePolicyConfiguration(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DefaultDNSController,
+		},
+	}
+	if externalNamePolicyEnabled(dns) {
+		t.Errorf("expected the policy to be disabled without the annotation")
+	}
+	dns.Annotations = map[string]string{
+		ExternalNameAllowedDomainsAnnotation: "Example.com, partner.net. bad_domain",
+		ExternalNameEnforcementAnnotation:    "Enforce",
+	}
+	if !externalNamePolicyEnabled(dns) {
+		t.Errorf("expected the policy to be enabled")
+	}
+	if expected, actual := sets.NewString("example.com.", "partner.net."), externalNameAllowedDomains(dns); !expected.Equal(actual) {
+		t.Errorf("expected allowed domains %v, got %v", expected.List(), actual.List())
+	}
+	if !externalNamePolicyEnforced(dns) {
+		t.Errorf("expected the policy to be enforced")
+	}
+	dns.Annotations[ExternalNameEnforcementAnnotation] = "block"
+	if externalNamePolicyEnforced(dns) {
+		t.Errorf("expected an invalid enforcement value to audit")
+	}
+}
+
+// TestExternalNameBlockingRecords verifies that enforced violations make the
+// default server block answer NXDOMAIN for the services' names and that the
+// condition reports them.
+func TestExternalNameBlockingRecords(t *testing.T) {
+	violations := []externalNameViolation{
+		{Service: types.NamespacedName{Namespace: "team-b", Name: "steal"}, ExternalName: "db.team-a.svc.cluster.local"},
+	}
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DefaultDNSController,
+		},
+	}
+	cm, err := desiredDNSConfigMap(dns, corefileInputs{SynthesizedRecords: externalNameBlockingRecords(violations, "cluster.local"), ClusterDomain: "cluster.local", MetricsAddress: "127.0.0.1:9153"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `
+    template IN ANY steal.team-b.svc.cluster.local. {
+        rcode NXDOMAIN
+    }`
+	if !strings.Contains(cm.Data["Corefile"], expected) {
+		t.Errorf("expected Corefile to contain:%s\ngot:\n%s", expected, cm.Data["Corefile"])
+	}
+
+	condition := computeExternalNamePolicyCompliantCondition(violations, true)
+	if condition.Status != operatorv1.ConditionFalse || condition.Reason != conditions.ReasonExternalNameServicesBlocked {
+		t.Errorf("expected condition with status False and reason %s, got %+v", conditions.ReasonExternalNameServicesBlocked, condition)
+	}
+	if !strings.Contains(condition.Message, "team-b/steal (db.team-a.svc.cluster.local)") {
+		t.Errorf("expected condition message to name the service, got %q", condition.Message)
+	}
+}
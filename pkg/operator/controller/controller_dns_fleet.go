@@ -0,0 +1,287 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/manifests"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// DNSFleetConfigurationEnforcedConditionType is the type of the DNS
+	// status condition that reports whether the operator applies the
+	// configuration that the dns's fleet manager set and the fingerprint
+	// of the configuration that the operator applies.  The condition is
+	// reported only if the dns has the FleetManagerAnnotation annotation.
+	DNSFleetConfigurationEnforcedConditionType = conditions.TypeFleetConfigurationEnforced
+
+	// fleetConfigurationKey is the key of the fleet configuration in the
+	// fleet configuration configmap.
+	fleetConfigurationKey = "configuration.json"
+	// configurationAnnotationPrefix is the prefix of the annotations that
+	// configure a dns.
+	configurationAnnotationPrefix = "dns.operator.openshift.io/"
+)
+
+// fleetConfiguration is the configuration of a dns that a fleet manager sets.
+type fleetConfiguration struct {
+	// Spec is the dns's spec.
+	Spec operatorv1.DNSSpec `json:"spec"`
+	// Annotations are the dns's annotations that configure it.
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// fingerprint returns a hash of the configuration.  Equal configurations have
+// equal fingerprints on every cluster.
+func (c fleetConfiguration) fingerprint() (string, error) {
+	// Maps are encoded with sorted keys, so the encoding is
+	// deterministic.
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// fleetManager returns the value of the given dns's FleetManagerAnnotation
+// annotation, or the empty string if the dns is not fleet-managed.
+func fleetManager(dns *operatorv1.DNS) string {
+	return dns.Annotations[FleetManagerAnnotation]
+}
+
+// isConfigurationAnnotation returns a Boolean value indicating whether the
+// annotation with the given key configures a dns.  The annotations that the
+// operator itself sets, and FleetManagerAnnotation, do not.
+func isConfigurationAnnotation(key string) bool {
+	switch key {
+	case ObservedGenerationAnnotation, ChaosKillPodsHandledAnnotation, MigratedFieldsAnnotation, FleetManagerAnnotation:
+		return false
+	}
+	return strings.HasPrefix(key, configurationAnnotationPrefix)
+}
+
+// dnsFleetConfiguration returns the configuration of the given dns.
+func dnsFleetConfiguration(dns *operatorv1.DNS) fleetConfiguration {
+	config := fleetConfiguration{Spec: *dns.Spec.DeepCopy()}
+	for key, value := range dns.Annotations {
+		if isConfigurationAnnotation(key) {
+			if config.Annotations == nil {
+				config.Annotations = map[string]string{}
+			}
+			config.Annotations[key] = value
+		}
+	}
+	return config
+}
+
+// withFleetConfiguration returns a copy of the given dns with its spec and
+// configuration annotations replaced by the given configuration.
+func withFleetConfiguration(dns *operatorv1.DNS, config fleetConfiguration) *operatorv1.DNS {
+	updated := dns.DeepCopy()
+	updated.Spec = *config.Spec.DeepCopy()
+	for key := range updated.Annotations {
+		if isConfigurationAnnotation(key) {
+			delete(updated.Annotations, key)
+		}
+	}
+	for key, value := range config.Annotations {
+		if updated.Annotations == nil {
+			updated.Annotations = map[string]string{}
+		}
+		updated.Annotations[key] = value
+	}
+	return updated
+}
+
+// operatorFieldManager returns the field manager that the API server records
+// for the operator's own writes.  The operator does not set a field manager,
+// so the API server uses the prefix of the operator's user agent.
+func operatorFieldManager() string {
+	return strings.Split(rest.DefaultKubernetesUserAgent(), "/")[0]
+}
+
+// localConfigurationEditors returns the names of the field managers of the
+// given dns, other than the given fleet manager and the given operator field
+// manager, that own any field of the dns's spec or any of its configuration
+// annotations.
+func localConfigurationEditors(dns *operatorv1.DNS, fleetManager, operatorManager string) []string {
+	editors := sets.NewString()
+	for _, entry := range dns.ManagedFields {
+		if entry.Manager == fleetManager || entry.Manager == operatorManager || entry.FieldsV1 == nil {
+			continue
+		}
+		var fields map[string]interface{}
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+			logrus.Warningf("ignoring malformed managed fields of manager %s on dns %s: %v", entry.Manager, dns.Name, err)
+			continue
+		}
+		if _, ok := fields["f:spec"]; ok {
+			editors.Insert(entry.Manager)
+			continue
+		}
+		metadata, _ := fields["f:metadata"].(map[string]interface{})
+		annotations, _ := metadata["f:annotations"].(map[string]interface{})
+		for key := range annotations {
+			if isConfigurationAnnotation(strings.TrimPrefix(key, "f:")) {
+				editors.Insert(entry.Manager)
+				break
+			}
+		}
+	}
+	return editors.List()
+}
+
+// ensureDNSFleetConfiguration returns the dns whose configuration the operator
+// applies in place of the given dns, and the status conditions that report
+// it.  If the given dns is not fleet-managed, it is returned unchanged and
+// without conditions.  If the fleet manager alone owns the dns's
+// configuration, the configuration is recorded in the fleet configuration
+// configmap and the dns is returned unchanged.  Otherwise, the dns is returned
+// with the configuration from the configmap, if any, so that local edits are
+// ignored.
+func (r *reconciler) ensureDNSFleetConfiguration(dns *operatorv1.DNS) (*operatorv1.DNS, []operatorv1.OperatorCondition, error) {
+	manager := fleetManager(dns)
+	if len(manager) == 0 {
+		if err := r.ensureDNSFleetConfigurationDeleted(dns); err != nil {
+			return nil, nil, err
+		}
+		return dns, nil, nil
+	}
+
+	condition := operatorv1.OperatorCondition{
+		Type: DNSFleetConfigurationEnforcedConditionType,
+	}
+	editors := localConfigurationEditors(dns, manager, operatorFieldManager())
+	if len(editors) == 0 {
+		config := dnsFleetConfiguration(dns)
+		fingerprint, err := config.fingerprint()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to compute configuration fingerprint for dns %s: %w", dns.Name, err)
+		}
+		if err := r.ensureDNSFleetConfigurationConfigMap(dns, config); err != nil {
+			return nil, nil, err
+		}
+		condition.Status = operatorv1.ConditionTrue
+		condition.Reason = conditions.ReasonAsExpected
+		condition.Message = fmt.Sprintf("The configuration is managed by %s.  Configuration fingerprint: %s.", manager, fingerprint)
+		return dns, []operatorv1.OperatorCondition{condition}, nil
+	}
+
+	config, ok, err := r.currentDNSFleetConfiguration(dns)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		fingerprint, err := dnsFleetConfiguration(dns).fingerprint()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to compute configuration fingerprint for dns %s: %w", dns.Name, err)
+		}
+		condition.Status = operatorv1.ConditionFalse
+		condition.Reason = conditions.ReasonNoFleetConfiguration
+		condition.Message = fmt.Sprintf("The configuration is managed by %s, but %s also own parts of it, and %s has not yet set the whole configuration, so the local configuration is applied.  Configuration fingerprint: %s.", manager, strings.Join(editors, ", "), manager, fingerprint)
+		return dns, []operatorv1.OperatorCondition{condition}, nil
+	}
+	fingerprint, err := config.fingerprint()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to compute configuration fingerprint for dns %s: %w", dns.Name, err)
+	}
+	condition.Status = operatorv1.ConditionFalse
+	condition.Reason = conditions.ReasonLocalEditsRejected
+	condition.Message = fmt.Sprintf("The configuration is managed by %s, so local edits by %s are ignored and the configuration that %s last set is applied.  Configuration fingerprint: %s.", manager, strings.Join(editors, ", "), manager, fingerprint)
+	return withFleetConfiguration(dns, config), []operatorv1.OperatorCondition{condition}, nil
+}
+
+// currentDNSFleetConfiguration returns the configuration in the given dns's
+// fleet configuration configmap and a Boolean value indicating whether the
+// configmap exists.
+func (r *reconciler) currentDNSFleetConfiguration(dns *operatorv1.DNS) (fleetConfiguration, bool, error) {
+	var config fleetConfiguration
+	cm := &corev1.ConfigMap{}
+	if err := r.client.Get(context.TODO(), DNSFleetConfigurationConfigMapName(dns), cm); err != nil {
+		if errors.IsNotFound(err) {
+			return config, false, nil
+		}
+		return config, false, fmt.Errorf("failed to get fleet configuration configmap: %w", err)
+	}
+	if err := json.Unmarshal([]byte(cm.Data[fleetConfigurationKey]), &config); err != nil {
+		return config, false, fmt.Errorf("failed to decode fleet configuration configmap %s/%s: %w", cm.Namespace, cm.Name, err)
+	}
+	return config, true, nil
+}
+
+// ensureDNSFleetConfigurationConfigMap ensures that the fleet configuration
+// configmap for the given dns has the given configuration.
+func (r *reconciler) ensureDNSFleetConfigurationConfigMap(dns *operatorv1.DNS, config fleetConfiguration) error {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to encode fleet configuration for dns %s: %w", dns.Name, err)
+	}
+	name := DNSFleetConfigurationConfigMapName(dns)
+	desired := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name.Name,
+			Namespace: name.Namespace,
+			Labels: map[string]string{
+				manifests.OwningDNSLabel: DNSDaemonSetLabel(dns),
+			},
+		},
+		Data: map[string]string{fleetConfigurationKey: string(data)},
+	}
+	desired.SetOwnerReferences([]metav1.OwnerReference{dnsOwnerRef(dns)})
+
+	current := &corev1.ConfigMap{}
+	if err := r.client.Get(context.TODO(), name, current); err != nil {
+		if !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to get fleet configuration configmap: %w", err)
+		}
+		if err := r.client.Create(context.TODO(), desired); err != nil {
+			return fmt.Errorf("failed to create fleet configuration configmap: %w", err)
+		}
+		logrus.Infof("created fleet configuration configmap %s/%s", desired.Namespace, desired.Name)
+		return nil
+	}
+	if current.Data[fleetConfigurationKey] == desired.Data[fleetConfigurationKey] {
+		return nil
+	}
+	updated := current.DeepCopy()
+	updated.Data = desired.Data
+	if err := r.client.Update(context.TODO(), updated); err != nil {
+		return fmt.Errorf("failed to update fleet configuration configmap: %w", err)
+	}
+	logrus.Infof("updated fleet configuration configmap %s/%s", updated.Namespace, updated.Name)
+	return nil
+}
+
+// ensureDNSFleetConfigurationDeleted deletes the fleet configuration configmap
+// of the given dns, which is no longer fleet-managed, if it exists.
+func (r *reconciler) ensureDNSFleetConfigurationDeleted(dns *operatorv1.DNS) error {
+	cm := &corev1.ConfigMap{}
+	if err := r.cache.Get(context.TODO(), DNSFleetConfigurationConfigMapName(dns), cm); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to get fleet configuration configmap: %w", err)
+	}
+	if err := r.client.Delete(context.TODO(), cm); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete fleet configuration configmap: %w", err)
+	}
+	logrus.Infof("deleted fleet configuration configmap %s/%s", cm.Namespace, cm.Name)
+	return nil
+}
@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func managedFieldsEntry(manager, fields string) metav1.ManagedFieldsEntry {
+	return metav1.ManagedFieldsEntry{
+		Manager:  manager,
+		FieldsV1: &metav1.FieldsV1{Raw: []byte(fields)},
+	}
+}
+
+// TestLocalConfigurationEditors verifies that localConfigurationEditors
+// reports the field managers other than the fleet manager and the operator
+// that own the spec or configuration annotations of a dns.
+func TestLocalConfigurationEditors(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DefaultDNSController,
+			ManagedFields: []metav1.ManagedFieldsEntry{
+				managedFieldsEntry("argocd", `{"f:metadata":{"f:annotations":{"f:dns.operator.openshift.io/fleet-manager":{}}},"f:spec":{"f:servers":{}}}`),
+				managedFieldsEntry("dns-operator", `{"f:metadata":{"f:annotations":{"f:dns.operator.openshift.io/observed-generation":{}},"f:finalizers":{}}}`),
+				managedFieldsEntry("kubectl-edit", `{"f:spec":{"f:nodePlacement":{}}}`),
+				managedFieldsEntry("kubectl-annotate", `{"f:metadata":{"f:annotations":{"f:dns.operator.openshift.io/cache":{}}}}`),
+				managedFieldsEntry("labeler", `{"f:metadata":{"f:labels":{"f:team":{}},"f:annotations":{"f:example.com/owner":{}}}}`),
+				managedFieldsEntry("status-writer", `{"f:status":{"f:clusterIP":{}}}`),
+			},
+		},
+	}
+	expected := []string{"kubectl-annotate", "kubectl-edit"}
+	if actual := localConfigurationEditors(dns, "argocd", "dns-operator"); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected editors %v, got %v", expected, actual)
+	}
+}
+
+// TestWithFleetConfiguration verifies that withFleetConfiguration replaces the
+// spec and configuration annotations of a dns and that the fingerprint of a
+// configuration depends only on the configuration.
+func TestWithFleetConfiguration(t *testing.T) {
+	fleet := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DefaultDNSController,
+			Annotations: map[string]string{
+				FleetManagerAnnotation:       "argocd",
+				QueryTimeoutsAnnotation:      ".=5s",
+				ServerTimeoutsAnnotation:     "idle=30s",
+				ObservedGenerationAnnotation: "3",
+				"example.com/owner":          "team-a",
+			},
+		},
+		Spec: operatorv1.DNSSpec{
+			Servers: []operatorv1.Server{{
+				Name:          "corp",
+				Zones:         []string{"corp.example.com"},
+				ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"10.0.0.53"}},
+			}},
+		},
+	}
+	config := dnsFleetConfiguration(fleet)
+
+	local := fleet.DeepCopy()
+	local.Spec.Servers = nil
+	local.Annotations[CacheAnnotation] = "serve-stale=1h"
+	delete(local.Annotations, QueryTimeoutsAnnotation)
+	local.Annotations[ObservedGenerationAnnotation] = "4"
+
+	effective := withFleetConfiguration(local, config)
+	if !reflect.DeepEqual(effective.Spec, fleet.Spec) {
+		t.Errorf("expected spec %+v, got %+v", fleet.Spec, effective.Spec)
+	}
+	expectedAnnotations := map[string]string{
+		FleetManagerAnnotation:       "argocd",
+		QueryTimeoutsAnnotation:      ".=5s",
+		ServerTimeoutsAnnotation:     "idle=30s",
+		ObservedGenerationAnnotation: "4",
+		"example.com/owner":          "team-a",
+	}
+	if !reflect.DeepEqual(effective.Annotations, expectedAnnotations) {
+		t.Errorf("expected annotations %v, got %v", expectedAnnotations, effective.Annotations)
+	}
+
+	a, err := config.fingerprint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := dnsFleetConfiguration(effective).fingerprint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a != b {
+		t.Errorf("expected equal configurations to have equal fingerprints, got %s and %s", a, b)
+	}
+	c, err := dnsFleetConfiguration(local).fingerprint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == c {
+		t.Errorf("expected different configurations to have different fingerprints, got %s", a)
+	}
+}
@@ -0,0 +1,185 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// DNSForwardingConfigMapAppliedConditionType is the type of the DNS
+	// status condition that reports the forwarding zones that the dns
+	// applies from the configmap that its ForwardingConfigMapAnnotation
+	// annotation names and the zones that it ignores.  The condition is
+	// reported only if the dns has the annotation.
+	DNSForwardingConfigMapAppliedConditionType = conditions.TypeForwardingConfigMapApplied
+
+	// forwardingZonesKey is the key of the zones in a forwarding
+	// configmap.
+	forwardingZonesKey = "zones"
+)
+
+// forwardingConfigMapName returns the name of the configmap in the given dns's
+// ForwardingConfigMapAnnotation annotation, or the empty string if the dns does
+// not have the annotation.
+func forwardingConfigMapName(dns *operatorv1.DNS) string {
+	return strings.TrimSpace(dns.Annotations[ForwardingConfigMapAnnotation])
+}
+
+// forwardingZonesHash returns a hash of the given zones of a forwarding
+// configmap.
+func forwardingZonesHash(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:8])
+}
+
+// dnsForwardingConfigMapServers returns the servers for the valid zones in the
+// configmap that the given dns's ForwardingConfigMapAnnotation annotation names
+// and a status condition that reports them, or nil and a nil condition if the
+// dns does not have the annotation.  The servers are named
+// "<configmap>/<zone>", so their names do not collide with those of the dns's
+// servers.
+func (r *reconciler) dnsForwardingConfigMapServers(dns *operatorv1.DNS, clusterDomain string) ([]operatorv1.Server, *operatorv1.OperatorCondition, error) {
+	cmName := forwardingConfigMapName(dns)
+	if len(cmName) == 0 {
+		return nil, nil, nil
+	}
+	name := types.NamespacedName{Namespace: DefaultOperandNamespace, Name: cmName}
+	if errs := validation.IsDNS1123Subdomain(cmName); len(errs) != 0 {
+		condition := computeForwardingConfigMapAppliedCondition(name, "", nil, []string{fmt.Sprintf("%q is not a valid configmap name", cmName)})
+		return nil, &condition, nil
+	}
+	cm := &corev1.ConfigMap{}
+	if err := r.cache.Get(context.TODO(), name, cm); err != nil {
+		if errors.IsNotFound(err) {
+			condition := computeForwardingConfigMapAppliedCondition(name, "", nil, []string{"the configmap does not exist"})
+			return nil, &condition, nil
+		}
+		return nil, nil, fmt.Errorf("failed to get forwarding configmap %s/%s: %w", name.Namespace, name.Name, err)
+	}
+	data, ok := cm.Data[forwardingZonesKey]
+	if !ok {
+		condition := computeForwardingConfigMapAppliedCondition(name, "", nil, []string{fmt.Sprintf("the configmap has no %q key", forwardingZonesKey)})
+		return nil, &condition, nil
+	}
+	servers, problems := parseForwardingZones(cmName, data, clusterDomain, otherServerZones(dns.Spec.Servers, nil))
+	condition := computeForwardingConfigMapAppliedCondition(name, forwardingZonesHash(data), servers, problems)
+	return servers, &condition, nil
+}
+
+// parseForwardingZones parses the given zones of the forwarding configmap with
+// the given name and returns a server for each valid zone and the problems with
+// the invalid zones, which are ignored.  Each line has the form
+// "<zone> <upstream> [<upstream>...]".  Text from "#" to the end of a line is a
+// comment.  A zone is invalid if it is the root zone, if it is in or contains
+// the given cluster domain, if it is one of the given zones, which are
+// normalized with normalizeZone, or if an earlier line has it.  The upstreams
+// are not validated; validUpstreamServers validates them with those of the
+// dns's servers.
+func parseForwardingZones(cmName, data, clusterDomain string, zones sets.String) ([]operatorv1.Server, []string) {
+	var (
+		servers  []operatorv1.Server
+		problems []string
+		seen     = sets.NewString()
+	)
+	clusterDomain = normalizeZone(clusterDomain)
+	for i, line := range strings.Split(data, "\n") {
+		if j := strings.Index(line, "#"); j >= 0 {
+			line = line[:j]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 2 {
+			problems = append(problems, fmt.Sprintf("line %d: expected \"<zone> <upstream> [<upstream>...]\"", i+1))
+			continue
+		}
+		zone := normalizeZone(fields[0])
+		switch {
+		case zone == ".":
+			problems = append(problems, fmt.Sprintf("line %d: the root zone must be forwarded with the default upstreams", i+1))
+			continue
+		case !validDomainName(zone):
+			problems = append(problems, fmt.Sprintf("line %d: %q is not a valid domain name", i+1, fields[0]))
+			continue
+		case nameInZone(zone, clusterDomain) || nameInZone(clusterDomain, zone):
+			problems = append(problems, fmt.Sprintf("line %d: zone %s overlaps the cluster domain", i+1, fields[0]))
+			continue
+		case zones.Has(zone):
+			problems = append(problems, fmt.Sprintf("line %d: zone %s is forwarded by a server in spec.servers", i+1, fields[0]))
+			continue
+		case seen.Has(zone):
+			problems = append(problems, fmt.Sprintf("line %d: zone %s is on an earlier line", i+1, fields[0]))
+			continue
+		}
+		seen.Insert(zone)
+		zone = strings.TrimSuffix(zone, ".")
+		servers = append(servers, operatorv1.Server{
+			Name:  cmName + "/" + zone,
+			Zones: []string{zone},
+			ForwardPlugin: operatorv1.ForwardPlugin{
+				Upstreams: fields[1:],
+			},
+		})
+	}
+	return servers, problems
+}
+
+// dnsesForForwardingConfigMap returns reconcile requests for the dnses whose
+// ForwardingConfigMapAnnotation annotation names the given configmap.
+func (r *reconciler) dnsesForForwardingConfigMap(o client.Object) []reconcile.Request {
+	dnses := &operatorv1.DNSList{}
+	if err := r.cache.List(context.TODO(), dnses); err != nil {
+		logrus.Errorf("failed to list dnses for configmap %s/%s: %v", o.GetNamespace(), o.GetName(), err)
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range dnses.Items {
+		if forwardingConfigMapName(&dnses.Items[i]) == o.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: dnses.Items[i].Name}})
+		}
+	}
+	return requests
+}
+
+// computeForwardingConfigMapAppliedCondition returns a status condition that
+// reports the given servers from the forwarding configmap with the given name,
+// whose zones have the given hash, and the problems with the zones that were
+// ignored.
+func computeForwardingConfigMapAppliedCondition(name types.NamespacedName, hash string, servers []operatorv1.Server, problems []string) operatorv1.OperatorCondition {
+	condition := operatorv1.OperatorCondition{
+		Type: DNSForwardingConfigMapAppliedConditionType,
+	}
+	applied := fmt.Sprintf("Forwarding %d zones from configmap %s/%s", len(servers), name.Namespace, name.Name)
+	if len(hash) != 0 {
+		applied += fmt.Sprintf(" (hash %s)", hash)
+	}
+	if len(problems) != 0 {
+		condition.Status = operatorv1.ConditionFalse
+		condition.Reason = conditions.ReasonForwardingZonesIgnored
+		condition.Message = fmt.Sprintf("Some forwarding zones in configmap %s/%s were ignored: %s.  %s.", name.Namespace, name.Name, strings.Join(problems, "; "), applied)
+		return condition
+	}
+	condition.Status = operatorv1.ConditionTrue
+	condition.Reason = conditions.ReasonAsExpected
+	condition.Message = applied + "."
+	return condition
+}
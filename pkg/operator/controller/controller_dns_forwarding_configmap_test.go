@@ -0,0 +1,67 @@
+package controller
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// TestParseForwardingZones verifies that parseForwardingZones returns a server
+// for each valid zone, in order, and ignores invalid and conflicting zones.
+func TestParseForwardingZones(t *testing.T) {
+	data := `# Corporate zones
+Corp.Example.com. 10.0.0.53 10.0.0.54:5353
+lab.example.com tls://10.0.1.53 # lab
+lab.example.com 10.0.2.53
+hr.example.com
+. 10.0.0.53
+bad_zone! 10.0.0.53
+svc.cluster.local 10.0.0.53
+payroll.example.com 10.0.3.53
+`
+	servers, problems := parseForwardingZones("corp-zones", data, "cluster.local", sets.NewString("payroll.example.com."))
+	expected := []operatorv1.Server{
+		{Name: "corp-zones/corp.example.com", Zones: []string{"corp.example.com"}, ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"10.0.0.53", "10.0.0.54:5353"}}},
+		{Name: "corp-zones/lab.example.com", Zones: []string{"lab.example.com"}, ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"tls://10.0.1.53"}}},
+	}
+	if !reflect.DeepEqual(servers, expected) {
+		t.Errorf("expected %#v, got %#v", expected, servers)
+	}
+	expectedProblems := []string{
+		"line 4: zone lab.example.com is on an earlier line",
+		`line 5: expected "<zone> <upstream> [<upstream>...]"`,
+		"line 6: the root zone must be forwarded with the default upstreams",
+		`line 7: "bad_zone!" is not a valid domain name`,
+		"line 8: zone svc.cluster.local overlaps the cluster domain",
+		"line 9: zone payroll.example.com is forwarded by a server in spec.servers",
+	}
+	if !reflect.DeepEqual(problems, expectedProblems) {
+		t.Errorf("expected problems %q, got %q", expectedProblems, problems)
+	}
+}
+
+// TestComputeForwardingConfigMapAppliedCondition verifies that the condition
+// reports the hash of the configmap's zones and the ignored zones.
+func TestComputeForwardingConfigMapAppliedCondition(t *testing.T) {
+	name := types.NamespacedName{Namespace: DefaultOperandNamespace, Name: "corp-zones"}
+	servers, _ := parseForwardingZones(name.Name, "corp.example.com 10.0.0.53\n", "cluster.local", sets.NewString())
+	hash := forwardingZonesHash("corp.example.com 10.0.0.53\n")
+
+	condition := computeForwardingConfigMapAppliedCondition(name, hash, servers, nil)
+	if condition.Status != operatorv1.ConditionTrue || condition.Reason != conditions.ReasonAsExpected || !strings.Contains(condition.Message, "(hash "+hash+")") {
+		t.Errorf("expected a true condition with hash %s, got %#v", hash, condition)
+	}
+	condition = computeForwardingConfigMapAppliedCondition(name, "", nil, []string{"the configmap does not exist"})
+	if condition.Status != operatorv1.ConditionFalse || condition.Reason != conditions.ReasonForwardingZonesIgnored {
+		t.Errorf("expected a false condition, got %#v", condition)
+	}
+	if forwardingZonesHash("corp.example.com 10.0.0.54\n") == hash {
+		t.Errorf("expected the hash to change when the zones change")
+	}
+}
@@ -0,0 +1,170 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	// DNSForwardingLoopFreeConditionType is the type of the DNS status
+	// condition that indicates whether the dns's servers are free of
+	// forwarding loops.  Servers that would cause loops are omitted from
+	// the Corefile.
+	DNSForwardingLoopFreeConditionType = conditions.TypeForwardingLoopFree
+)
+
+// dnsServiceAddresses returns the cluster IP addresses of the services of all
+// dnses, including the given cluster IP address, which is the address of the
+// service of the dns that is being reconciled and may not be assigned yet.
+// Forwarding to any of these addresses sends queries back to cluster DNS.
+func (r *reconciler) dnsServiceAddresses(clusterIP string) (sets.String, error) {
+	addresses := sets.NewString()
+	if len(clusterIP) != 0 {
+		addresses.Insert(clusterIP)
+	}
+	dnsList := &operatorv1.DNSList{}
+	if err := r.cache.List(context.TODO(), dnsList); err != nil {
+		return addresses, fmt.Errorf("failed to list dnses: %w", err)
+	}
+	for i := range dnsList.Items {
+		svc := &corev1.Service{}
+		if err := r.cache.Get(context.TODO(), DNSServiceName(&dnsList.Items[i]), svc); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return addresses, fmt.Errorf("failed to get service for dns %s: %w", dnsList.Items[i].Name, err)
+		}
+		for _, ip := range append([]string{svc.Spec.ClusterIP}, svc.Spec.ClusterIPs...) {
+			if len(ip) != 0 && ip != corev1.ClusterIPNone {
+				addresses.Insert(ip)
+			}
+		}
+	}
+	return addresses, nil
+}
+
+// loopFreeServers returns the subset of the given servers that can be rendered
+// in the Corefile without causing forwarding loops, along with a status
+// condition that describes any servers or upstreams that were omitted.
+//
+// Each zone is owned by the first server that claims it; the root zone and
+// the cluster domain are owned by the default server block.  A server is
+// omitted if it claims a zone that another server owns.  A server may claim a
+// subdomain of the cluster domain, such as legacy.cluster.local, to forward
+// that subdomain elsewhere; CoreDNS routes each query to the server block
+// with the most specific matching zone, so the kubernetes plugin in the
+// default server block does not shadow the subdomain.  However, a server is
+// omitted if it claims the service or pod subdomain of the cluster domain or
+// a zone within either, as it would shadow names that the kubernetes plugin
+// serves.  An upstream is omitted if it is the address of a dns service, and a
+// server whose upstreams are all omitted is itself omitted.
+func loopFreeServers(dnsServers []operatorv1.Server, clusterDomain string, dnsAddresses sets.String) ([]operatorv1.Server, operatorv1.OperatorCondition) {
+	owners := map[string]string{
+		".":                           "the default server",
+		normalizeZone(clusterDomain):  "the default server",
+		normalizeZone("in-addr.arpa"): "the default server",
+		normalizeZone("ip6.arpa"):     "the default server",
+	}
+	var (
+		servers  []operatorv1.Server
+		problems []string
+	)
+	for _, server := range dnsServers {
+		var conflicts []string
+		for _, zone := range server.Zones {
+			if owner, ok := owners[normalizeZone(zone)]; ok {
+				conflicts = append(conflicts, fmt.Sprintf("zone %q is already served by %s", zone, owner))
+			} else if subdomain, ok := kubernetesSubdomain(zone, clusterDomain); ok {
+				conflicts = append(conflicts, fmt.Sprintf("zone %q would shadow %s names, which the default server serves", zone, subdomain))
+			}
+		}
+		if len(conflicts) != 0 {
+			problems = append(problems, fmt.Sprintf("server %q was omitted: %s", server.Name, strings.Join(conflicts, ", ")))
+			continue
+		}
+
+		var upstreams, loops []string
+		for _, upstream := range server.ForwardPlugin.Upstreams {
+			if dnsAddresses.Has(upstreamHost(upstream)) {
+				loops = append(loops, upstream)
+				continue
+			}
+			upstreams = append(upstreams, upstream)
+		}
+		if len(loops) != 0 {
+			if len(upstreams) == 0 {
+				problems = append(problems, fmt.Sprintf("server %q was omitted: all of its upstreams forward to cluster DNS", server.Name))
+				continue
+			}
+			problems = append(problems, fmt.Sprintf("server %q forwards to cluster DNS; omitted upstreams %s", server.Name, strings.Join(loops, ", ")))
+		}
+
+		for _, zone := range server.Zones {
+			owners[normalizeZone(zone)] = fmt.Sprintf("server %q", server.Name)
+		}
+		safe := *server.DeepCopy()
+		safe.ForwardPlugin.Upstreams = upstreams
+		servers = append(servers, safe)
+	}
+
+	condition := operatorv1.OperatorCondition{
+		Type: DNSForwardingLoopFreeConditionType,
+	}
+	if len(problems) != 0 {
+		sort.Strings(problems)
+		condition.Status = operatorv1.ConditionFalse
+		condition.Reason = conditions.ReasonForwardingLoopPrevented
+		condition.Message = fmt.Sprintf("Some servers would cause forwarding loops: %s.", strings.Join(problems, "; "))
+	} else {
+		condition.Status = operatorv1.ConditionTrue
+		condition.Reason = conditions.ReasonAsExpected
+		condition.Message = "No servers forward to cluster DNS or claim zones that other servers serve."
+	}
+	return servers, condition
+}
+
+// kubernetesSubdomain returns the name of the subdomain of the given cluster
+// domain in which the kubernetes plugin serves records, either "svc" or "pod",
+// if the given zone is that subdomain or a zone within it.  The Boolean value
+// indicates whether the zone is in either subdomain.
+func kubernetesSubdomain(zone, clusterDomain string) (string, bool) {
+	zone = normalizeZone(zone)
+	for _, subdomain := range []string{"svc", "pod"} {
+		name := normalizeZone(subdomain + "." + clusterDomain)
+		if zone == name || strings.HasSuffix(zone, "."+name) {
+			return subdomain, true
+		}
+	}
+	return "", false
+}
+
+// normalizeZone returns the given zone in lower case with a trailing dot.
+func normalizeZone(zone string) string {
+	return strings.ToLower(strings.TrimSuffix(zone, ".")) + "."
+}
+
+// upstreamHost returns the address of the given upstream, which may be an
+// address or an address and port, with or without the quic or tls scheme.
+func upstreamHost(upstream string) string {
+	switch {
+	case isDoQUpstream(upstream):
+		upstream = upstream[len(dnsOverQUICScheme):]
+	case isDoTUpstream(upstream):
+		upstream = upstream[len(dnsOverTLSScheme):]
+	}
+	if host, _, err := net.SplitHostPort(upstream); err == nil {
+		return host
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(upstream, "["), "]")
+}
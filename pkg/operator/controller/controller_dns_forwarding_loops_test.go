@@ -0,0 +1,119 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// TestLoopFreeServers verifies that loopFreeServers omits servers and
+// upstreams that would cause forwarding loops.
+func TestLoopFreeServers(t *testing.T) {
+	server := func(name string, zones []string, upstreams ...string) operatorv1.Server {
+		return operatorv1.Server{
+			Name:          name,
+			Zones:         zones,
+			ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: upstreams},
+		}
+	}
+	dnsAddresses := sets.NewString("172.30.0.10", "fd02::a")
+	testCases := []struct {
+		name            string
+		servers         []operatorv1.Server
+		expectedServers []operatorv1.Server
+		expectedStatus  operatorv1.ConditionStatus
+	}{
+		{
+			name:           "no servers",
+			expectedStatus: operatorv1.ConditionTrue,
+		},
+		{
+			name: "no loops",
+			servers: []operatorv1.Server{
+				server("foo", []string{"foo.com"}, "1.1.1.1", "2.2.2.2:5353"),
+				server("bar", []string{"bar.com"}, "[fd00::1]:53"),
+			},
+			expectedServers: []operatorv1.Server{
+				server("foo", []string{"foo.com"}, "1.1.1.1", "2.2.2.2:5353"),
+				server("bar", []string{"bar.com"}, "[fd00::1]:53"),
+			},
+			expectedStatus: operatorv1.ConditionTrue,
+		},
+		{
+			name: "some upstreams forward to cluster dns",
+			servers: []operatorv1.Server{
+				server("foo", []string{"foo.com"}, "1.1.1.1", "172.30.0.10:53", "[fd02::a]:53"),
+			},
+			expectedServers: []operatorv1.Server{
+				server("foo", []string{"foo.com"}, "1.1.1.1"),
+			},
+			expectedStatus: operatorv1.ConditionFalse,
+		},
+		{
+			name: "all upstreams forward to cluster dns",
+			servers: []operatorv1.Server{
+				server("foo", []string{"foo.com"}, "172.30.0.10"),
+				server("bar", []string{"bar.com"}, "1.1.1.1"),
+			},
+			expectedServers: []operatorv1.Server{
+				server("bar", []string{"bar.com"}, "1.1.1.1"),
+			},
+			expectedStatus: operatorv1.ConditionFalse,
+		},
+		{
+			name: "zone claimed by two servers",
+			servers: []operatorv1.Server{
+				server("foo", []string{"foo.com"}, "1.1.1.1"),
+				server("bar", []string{"bar.com", "FOO.com."}, "2.2.2.2"),
+			},
+			expectedServers: []operatorv1.Server{
+				server("foo", []string{"foo.com"}, "1.1.1.1"),
+			},
+			expectedStatus: operatorv1.ConditionFalse,
+		},
+		{
+			name: "cluster domain",
+			servers: []operatorv1.Server{
+				server("foo", []string{"cluster.local"}, "1.1.1.1"),
+			},
+			expectedStatus: operatorv1.ConditionFalse,
+		},
+		{
+			name: "subdomain of the cluster domain",
+			servers: []operatorv1.Server{
+				server("legacy", []string{"legacy.cluster.local"}, "1.1.1.1"),
+			},
+			expectedServers: []operatorv1.Server{
+				server("legacy", []string{"legacy.cluster.local"}, "1.1.1.1"),
+			},
+			expectedStatus: operatorv1.ConditionTrue,
+		},
+		{
+			name: "service and pod subdomains of the cluster domain",
+			servers: []operatorv1.Server{
+				server("svc", []string{"svc.cluster.local"}, "1.1.1.1"),
+				server("ns", []string{"ns.SVC.cluster.local."}, "1.1.1.1"),
+				server("pod", []string{"pod.cluster.local"}, "1.1.1.1"),
+				server("notsvc", []string{"notsvc.cluster.local"}, "1.1.1.1"),
+			},
+			expectedServers: []operatorv1.Server{
+				server("notsvc", []string{"notsvc.cluster.local"}, "1.1.1.1"),
+			},
+			expectedStatus: operatorv1.ConditionFalse,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			servers, condition := loopFreeServers(tc.servers, "cluster.local", dnsAddresses)
+			if !reflect.DeepEqual(servers, tc.expectedServers) {
+				t.Errorf("expected servers %#v, got %#v", tc.expectedServers, servers)
+			}
+			if condition.Status != tc.expectedStatus {
+				t.Errorf("expected status %s, got %s: %s", tc.expectedStatus, condition.Status, condition.Message)
+			}
+		})
+	}
+}
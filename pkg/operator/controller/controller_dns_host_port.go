@@ -0,0 +1,50 @@
+package controller
+
+import (
+	"strconv"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	"github.com/sirupsen/logrus"
+
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+// minDNSHostPort is the lowest port that the HostPortAnnotation annotation may
+// set.  Privileged ports are reserved for the node's own services.
+const minDNSHostPort = 1024
+
+// dnsHostPort returns the port that the given dns's HostPortAnnotation
+// annotation sets, or 0 if the annotation is unset or invalid.
+func dnsHostPort(dns *operatorv1.DNS) int32 {
+	value, ok := dns.Annotations[HostPortAnnotation]
+	if !ok {
+		return 0
+	}
+	port, err := strconv.ParseInt(value, 10, 32)
+	if err != nil || port < minDNSHostPort || port > 65535 {
+		logrus.Warningf("ignoring invalid value %q for annotation %s on dns %s: must be a port from %d to 65535", value, HostPortAnnotation, dns.Name, minDNSHostPort)
+		return 0
+	}
+	return int32(port)
+}
+
+// addDNSHostPort maps the given port on the node to the dns container's UDP
+// and TCP DNS ports in the given dns daemonset.  The container runtime forwards
+// queries to the node's IP addresses on the port to the pod, so the pod does not
+// need the host network, and the scheduler keeps other pods that use the port
+// off the pod's node.  The daemonset's rolling update deletes a node's old pod
+// before it creates the new one, so the old and new pods never conflict on the
+// port.
+func addDNSHostPort(daemonset *appsv1.DaemonSet, port int32) {
+	for i, c := range daemonset.Spec.Template.Spec.Containers {
+		if c.Name != "dns" {
+			continue
+		}
+		for j, p := range c.Ports {
+			if p.ContainerPort == CoreDNSPort {
+				daemonset.Spec.Template.Spec.Containers[i].Ports[j].HostPort = port
+			}
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"testing"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestDNSHostPort verifies that dnsHostPort accepts only unprivileged ports.
+func TestDNSHostPort(t *testing.T) {
+	testCases := []struct {
+		value    string
+		expected int32
+	}{
+		{"", 0},
+		{"5354", 5354},
+		{"1024", 1024},
+		{"65535", 65535},
+		{"53", 0},
+		{"1023", 0},
+		{"65536", 0},
+		{"-5354", 0},
+		{"dns", 0},
+	}
+	for _, tc := range testCases {
+		dns := &operatorv1.DNS{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+		if len(tc.value) != 0 {
+			dns.Annotations = map[string]string{HostPortAnnotation: tc.value}
+		}
+		if actual := dnsHostPort(dns); actual != tc.expected {
+			t.Errorf("%q: expected %d, got %d", tc.value, tc.expected, actual)
+		}
+	}
+}
+
+// TestDesiredDNSDaemonSetHostPort verifies that the dns container's UDP and TCP
+// DNS ports, and no other ports, have the host port that the HostPortAnnotation
+// annotation sets.
+func TestDesiredDNSDaemonSetHostPort(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        DefaultDNSController,
+			Annotations: map[string]string{HostPortAnnotation: "5354"},
+		},
+	}
+	ds, err := desiredDNSDaemonSet(dns, "coredns", "kube-rbac-proxy", "", "dns-default-metrics-tls", "", nil, nil, nil, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostPorts := map[string]int32{}
+	for _, c := range ds.Spec.Template.Spec.Containers {
+		for _, p := range c.Ports {
+			if p.HostPort != 0 {
+				hostPorts[c.Name+"/"+p.Name] = p.HostPort
+			}
+		}
+	}
+	if len(hostPorts) != 2 || hostPorts["dns/dns"] != 5354 || hostPorts["dns/dns-tcp"] != 5354 {
+		t.Errorf("expected host port 5354 on ports dns and dns-tcp of the dns container, got %v", hostPorts)
+	}
+
+	delete(dns.Annotations, HostPortAnnotation)
+	ds, err = desiredDNSDaemonSet(dns, "coredns", "kube-rbac-proxy", "", "dns-default-metrics-tls", "", nil, nil, nil, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range ds.Spec.Template.Spec.Containers {
+		for _, p := range c.Ports {
+			if p.HostPort != 0 {
+				t.Errorf("expected no host ports without the annotation, got %d on %s/%s", p.HostPort, c.Name, p.Name)
+			}
+		}
+	}
+}
@@ -0,0 +1,294 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// DNSIdMForwardingConfiguredConditionType is the type of the DNS
+	// status condition that reports the IdM DNS servers to which the dns
+	// forwards the zones of their realms.  The condition is reported only
+	// if the dns has the IdMDiscoveryAnnotation annotation.
+	DNSIdMForwardingConfiguredConditionType = conditions.TypeIdMForwardingConfigured
+
+	// idmCASecretKey is the key of the CA certificate in an IdM DNS
+	// server's secret.
+	idmCASecretKey = "ca.crt"
+	// idmTLSPortName is the name of the port on which an IdM DNS server's
+	// service serves DNS-over-TLS.
+	idmTLSPortName = "dns-tls"
+	// idmTLSDefaultPort is the port on which an IdM DNS server serves
+	// DNS-over-TLS if its service has no port named idmTLSPortName.
+	idmTLSDefaultPort = 853
+	// coreDNSConfigDir is the directory in which the dns pods mount the
+	// dns's configmap.
+	coreDNSConfigDir = "/etc/coredns"
+)
+
+// idmResolver is an on-cluster IdM DNS server to which a dns forwards the
+// zones of the server's realm over DNS-over-TLS.
+type idmResolver struct {
+	// Name is the name of the resolver's server block in the Corefile,
+	// "idm-<namespace>-<name>" after the resolver's service.
+	Name string
+	// Zones are the realm's zones, in sorted order.
+	Zones []string
+	// Upstream is the resolver's address, "tls://<ip>:<port>".
+	Upstream string
+	// TLSServerName is the name that the resolver's certificate must have.
+	TLSServerName string
+	// CABundle is the PEM-encoded CA certificate that signed the
+	// resolver's certificate.
+	CABundle string
+}
+
+// CAKey returns the key of the resolver's CA certificate in the dns's
+// configmap.
+func (r idmResolver) CAKey() string {
+	return r.Name + ".crt"
+}
+
+// CAHash returns an abbreviated hash of the resolver's CA certificate.  The
+// Corefile includes the hash so that CoreDNS reloads its configuration when
+// the certificate changes.
+func (r idmResolver) CAHash() string {
+	sum := sha256.Sum256([]byte(r.CABundle))
+	return hex.EncodeToString(sum[:8])
+}
+
+// idmDiscoveryEnabled returns a Boolean value indicating whether the given
+// dns has the IdMDiscoveryAnnotation annotation set to "true".
+func idmDiscoveryEnabled(dns *operatorv1.DNS) bool {
+	value, ok := dns.Annotations[IdMDiscoveryAnnotation]
+	if !ok {
+		return false
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		logrus.Warningf("ignoring invalid value %q for annotation %s on dns %s: %v", value, IdMDiscoveryAnnotation, dns.Name, err)
+		return false
+	}
+	return enabled
+}
+
+// idmNamespaces returns the namespaces, in sorted order, that the given dns's
+// IdMNamespacesAnnotation annotation lists.  Invalid namespaces are ignored.
+func idmNamespaces(dns *operatorv1.DNS) []string {
+	namespaces := sets.NewString()
+	list := dns.Annotations[IdMNamespacesAnnotation]
+	for _, namespace := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' }) {
+		if errs := validation.IsDNS1123Label(namespace); len(errs) != 0 {
+			logrus.Warningf("ignoring invalid namespace %q in annotation %s on dns %s", namespace, IdMNamespacesAnnotation, dns.Name)
+			continue
+		}
+		namespaces.Insert(namespace)
+	}
+	return namespaces.List()
+}
+
+// idmAllowedZones returns the zones, normalized with normalizeZone, that the
+// given dns's IdMAllowedZonesAnnotation annotation lists.  Invalid zones are
+// ignored.
+func idmAllowedZones(dns *operatorv1.DNS) sets.String {
+	zones := sets.NewString()
+	list := dns.Annotations[IdMAllowedZonesAnnotation]
+	for _, zone := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' }) {
+		zone = normalizeZone(zone)
+		if zone == "." || !validDomainName(zone) {
+			logrus.Warningf("ignoring invalid zone %q in annotation %s on dns %s", zone, IdMAllowedZonesAnnotation, dns.Name)
+			continue
+		}
+		zones.Insert(zone)
+	}
+	return zones
+}
+
+// idmCASecretName returns the name of the secret with the CA certificate of
+// the IdM DNS server that the given service describes.
+func idmCASecretName(svc *corev1.Service) types.NamespacedName {
+	return types.NamespacedName{
+		Namespace: DefaultOperandNamespace,
+		Name:      fmt.Sprintf("idm-%s-%s", svc.Namespace, svc.Name),
+	}
+}
+
+// discoverIdMResolvers returns the IdM DNS servers in the namespaces that the
+// given dns's IdMNamespacesAnnotation annotation lists and a status condition
+// that reports them.  Only zones that the dns's IdMAllowedZonesAnnotation
+// annotation allows are forwarded to the IdM DNS servers.  Zones that the
+// given servers or the cluster domain already cover are not forwarded, and
+// nor are zones that an IdM DNS server that sorts earlier by namespace and
+// name already serves.
+func (r *reconciler) discoverIdMResolvers(dns *operatorv1.DNS, servers []operatorv1.Server, clusterDomain string) ([]idmResolver, operatorv1.OperatorCondition, error) {
+	namespaces, allowed := idmNamespaces(dns), idmAllowedZones(dns)
+	if len(namespaces) == 0 || allowed.Len() == 0 {
+		return nil, computeIdMForwardingCondition(nil, nil, nil), nil
+	}
+	// The operator's cache does not cover the namespaces, which the
+	// cluster administrator can change at any time, so list the services
+	// in each namespace through the client.
+	var services []corev1.Service
+	for _, namespace := range namespaces {
+		list := &corev1.ServiceList{}
+		if err := r.client.List(context.TODO(), list, client.InNamespace(namespace), client.MatchingLabels{IdMResolverLabel: "true"}); err != nil {
+			return nil, operatorv1.OperatorCondition{}, fmt.Errorf("failed to list IdM DNS services in namespace %s: %w", namespace, err)
+		}
+		services = append(services, list.Items...)
+	}
+	sort.Slice(services, func(i, j int) bool {
+		a, b := services[i], services[j]
+		return a.Namespace < b.Namespace || (a.Namespace == b.Namespace && a.Name < b.Name)
+	})
+
+	taken := sets.NewString()
+	for _, server := range servers {
+		for _, zone := range server.Zones {
+			taken.Insert(normalizeZone(zone))
+		}
+	}
+	var (
+		resolvers []idmResolver
+		problems  []string
+	)
+	for i := range services {
+		svc := &services[i]
+		secret := &corev1.Secret{}
+		if err := r.secretCache.Get(context.TODO(), idmCASecretName(svc), secret); err != nil {
+			if !errors.IsNotFound(err) {
+				return nil, operatorv1.OperatorCondition{}, fmt.Errorf("failed to get secret %s: %w", idmCASecretName(svc), err)
+			}
+			secret = nil
+		}
+		resolver, resolverProblems := idmResolverForService(svc, secret, clusterDomain, allowed, taken)
+		for _, problem := range resolverProblems {
+			problems = append(problems, fmt.Sprintf("service %s/%s: %s", svc.Namespace, svc.Name, problem))
+		}
+		if resolver != nil {
+			resolvers = append(resolvers, *resolver)
+		}
+	}
+	return resolvers, computeIdMForwardingCondition(namespaces, resolvers, problems), nil
+}
+
+// idmResolverForService returns the IdM DNS server that the given service and
+// secret describe, or nil if they do not describe a usable server, and the
+// problems with them.  The server's zones are limited to the given allowed
+// zones, which are normalized with normalizeZone, and exclude the cluster
+// domain and its subdomains and the zones in the given set of taken zones,
+// which are normalized with normalizeZone and to which the server's zones are
+// added.  The secret is nil if it does not exist.
+func idmResolverForService(svc *corev1.Service, secret *corev1.Secret, clusterDomain string, allowed, taken sets.String) (*idmResolver, []string) {
+	var problems []string
+	ip := svc.Spec.ClusterIP
+	if len(ip) == 0 || ip == corev1.ClusterIPNone {
+		return nil, []string{"the service has no cluster IP"}
+	}
+	port := int32(idmTLSDefaultPort)
+	for _, p := range svc.Spec.Ports {
+		if p.Name == idmTLSPortName {
+			port = p.Port
+			break
+		}
+	}
+	secretName := idmCASecretName(svc)
+	if secret == nil {
+		return nil, []string{fmt.Sprintf("secret %s with the CA certificate does not exist", secretName)}
+	}
+	caBundle := secret.Data[idmCASecretKey]
+	if block, _ := pem.Decode(caBundle); block == nil || block.Type != "CERTIFICATE" {
+		return nil, []string{fmt.Sprintf("the %q key of secret %s does not have a PEM-encoded certificate", idmCASecretKey, secretName)}
+	}
+
+	zones := sets.NewString()
+	list := svc.Annotations[IdMZonesAnnotation]
+	for _, zone := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' }) {
+		zone = strings.TrimSuffix(normalizeZone(zone), ".")
+		switch {
+		case len(validation.IsDNS1123Subdomain(zone)) != 0:
+			problems = append(problems, fmt.Sprintf("zone %q is not a valid domain name", zone))
+		case !nameInAnyZone(normalizeZone(zone), allowed):
+			problems = append(problems, fmt.Sprintf("zone %q is not in the zones that annotation %s allows", zone, IdMAllowedZonesAnnotation))
+		case zone == clusterDomain || strings.HasSuffix(zone, "."+clusterDomain):
+			problems = append(problems, fmt.Sprintf("zone %q is in the cluster domain", zone))
+		case taken.Has(normalizeZone(zone)):
+			problems = append(problems, fmt.Sprintf("zone %q is already forwarded", zone))
+		default:
+			zones.Insert(zone)
+		}
+	}
+	if zones.Len() == 0 {
+		return nil, append(problems, fmt.Sprintf("the service has no usable zones in annotation %s", IdMZonesAnnotation))
+	}
+	for zone := range zones {
+		taken.Insert(normalizeZone(zone))
+	}
+
+	serverName := svc.Annotations[IdMTLSServerNameAnnotation]
+	if len(serverName) == 0 {
+		serverName = fmt.Sprintf("%s.%s.svc", svc.Name, svc.Namespace)
+	}
+	return &idmResolver{
+		Name:          fmt.Sprintf("idm-%s-%s", svc.Namespace, svc.Name),
+		Zones:         zones.List(),
+		Upstream:      "tls://" + net.JoinHostPort(ip, strconv.Itoa(int(port))),
+		TLSServerName: serverName,
+		CABundle:      string(caBundle),
+	}, problems
+}
+
+// computeIdMForwardingCondition returns a status condition that reports the
+// given IdM DNS servers, which were discovered in the given namespaces, and
+// the problems with the IdM DNS servers that were ignored in whole or in part.
+func computeIdMForwardingCondition(namespaces []string, resolvers []idmResolver, problems []string) operatorv1.OperatorCondition {
+	condition := operatorv1.OperatorCondition{
+		Type: DNSIdMForwardingConfiguredConditionType,
+	}
+	var forwarded []string
+	for _, resolver := range resolvers {
+		forwarded = append(forwarded, fmt.Sprintf("%s to %s", strings.Join(resolver.Zones, ", "), resolver.Upstream))
+	}
+	switch {
+	case len(problems) != 0:
+		condition.Status = operatorv1.ConditionFalse
+		condition.Reason = conditions.ReasonIdMResolversIgnored
+		condition.Message = fmt.Sprintf("Some IdM DNS servers or zones were ignored: %s.", strings.Join(problems, "; "))
+		if len(forwarded) != 0 {
+			condition.Message += fmt.Sprintf("  Forwarding %s.", strings.Join(forwarded, "; "))
+		}
+	case len(namespaces) == 0:
+		condition.Status = operatorv1.ConditionFalse
+		condition.Reason = conditions.ReasonNoIdMResolversFound
+		condition.Message = fmt.Sprintf("The annotations %s and %s must list the namespaces in which to discover IdM DNS servers and the zones that they may serve.", IdMNamespacesAnnotation, IdMAllowedZonesAnnotation)
+	case len(resolvers) == 0:
+		condition.Status = operatorv1.ConditionFalse
+		condition.Reason = conditions.ReasonNoIdMResolversFound
+		condition.Message = fmt.Sprintf("No services in namespaces %s have the label %s=true.", strings.Join(namespaces, ", "), IdMResolverLabel)
+	default:
+		condition.Status = operatorv1.ConditionTrue
+		condition.Reason = conditions.ReasonAsExpected
+		condition.Message = fmt.Sprintf("Forwarding %s.", strings.Join(forwarded, "; "))
+	}
+	return condition
+}
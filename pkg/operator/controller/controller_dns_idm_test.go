@@ -0,0 +1,205 @@
+package controller
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	corev1 "k8s.io/api/core/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const testIdMCA = "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n"
+
+func idmService(zones string, ports ...corev1.ServicePort) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "ipa",
+			Namespace:   "idm",
+			Labels:      map[string]string{IdMResolverLabel: "true"},
+			Annotations: map[string]string{IdMZonesAnnotation: zones},
+		},
+		Spec: corev1.ServiceSpec{ClusterIP: "172.30.5.5", Ports: ports},
+	}
+}
+
+func idmSecret(ca string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "idm-idm-ipa", Namespace: DefaultOperandNamespace},
+		Data:       map[string][]byte{idmCASecretKey: []byte(ca)},
+	}
+}
+
+// TestIdMResolverForService verifies that idmResolverForService builds the
+// IdM DNS server that a service and secret describe and ignores the zones
+// that it must not forward or that are not allowed.
+func TestIdMResolverForService(t *testing.T) {
+	testCases := []struct {
+		description      string
+		svc              *corev1.Service
+		secret           *corev1.Secret
+		taken            []string
+		expected         *idmResolver
+		expectedProblems int
+	}{
+		{
+			description: "default port and server name",
+			svc:         idmService("IPA.Example.com., 5.168.192.in-addr.arpa"),
+			secret:      idmSecret(testIdMCA),
+			expected: &idmResolver{
+				Name:          "idm-idm-ipa",
+				Zones:         []string{"5.168.192.in-addr.arpa", "ipa.example.com"},
+				Upstream:      "tls://172.30.5.5:853",
+				TLSServerName: "ipa.idm.svc",
+				CABundle:      testIdMCA,
+			},
+		},
+		{
+			description: "named port",
+			svc:         idmService("ipa.example.com", corev1.ServicePort{Name: "dns", Port: 53}, corev1.ServicePort{Name: idmTLSPortName, Port: 8853}),
+			secret:      idmSecret(testIdMCA),
+			expected: &idmResolver{
+				Name:          "idm-idm-ipa",
+				Zones:         []string{"ipa.example.com"},
+				Upstream:      "tls://172.30.5.5:8853",
+				TLSServerName: "ipa.idm.svc",
+				CABundle:      testIdMCA,
+			},
+		},
+		{
+			description: "zones in the cluster domain, taken, or invalid are ignored",
+			svc:         idmService("svc.cluster.local foo.com ipa.example.com bad_zone"),
+			secret:      idmSecret(testIdMCA),
+			taken:       []string{"foo.com."},
+			expected: &idmResolver{
+				Name:          "idm-idm-ipa",
+				Zones:         []string{"ipa.example.com"},
+				Upstream:      "tls://172.30.5.5:853",
+				TLSServerName: "ipa.idm.svc",
+				CABundle:      testIdMCA,
+			},
+			expectedProblems: 3,
+		},
+		{
+			description: "zones that are not allowed are ignored",
+			svc:         idmService("ipa.example.com example.com corp.example.org"),
+			secret:      idmSecret(testIdMCA),
+			expected: &idmResolver{
+				Name:          "idm-idm-ipa",
+				Zones:         []string{"ipa.example.com"},
+				Upstream:      "tls://172.30.5.5:853",
+				TLSServerName: "ipa.idm.svc",
+				CABundle:      testIdMCA,
+			},
+			expectedProblems: 2,
+		},
+		{
+			description:      "no usable zones",
+			svc:              idmService("foo.com"),
+			secret:           idmSecret(testIdMCA),
+			taken:            []string{"foo.com."},
+			expectedProblems: 2,
+		},
+		{
+			description:      "missing secret",
+			svc:              idmService("ipa.example.com"),
+			expectedProblems: 1,
+		},
+		{
+			description:      "invalid CA certificate",
+			svc:              idmService("ipa.example.com"),
+			secret:           idmSecret("not a certificate"),
+			expectedProblems: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			allowed := sets.NewString("ipa.example.com.", "5.168.192.in-addr.arpa.", "foo.com.", "cluster.local.")
+			resolver, problems := idmResolverForService(tc.svc, tc.secret, "cluster.local", allowed, sets.NewString(tc.taken...))
+			if !reflect.DeepEqual(resolver, tc.expected) {
+				t.Errorf("expected resolver %+v, got %+v", tc.expected, resolver)
+			}
+			if len(problems) != tc.expectedProblems {
+				t.Errorf("expected %d problems, got %v", tc.expectedProblems, problems)
+			}
+		})
+	}
+}
+
+// TestIdMDiscoveryAnnotations verifies that idmNamespaces and idmAllowedZones
+// parse the dns's annotations and ignore invalid entries.
+func TestIdMDiscoveryAnnotations(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DefaultDNSController,
+			Annotations: map[string]string{
+				IdMNamespacesAnnotation:   "idm, Bad_Namespace idm-2,idm",
+				IdMAllowedZonesAnnotation: "IPA.Example.com. bad_zone, .",
+			},
+		},
+	}
+	if expected, actual := []string{"idm", "idm-2"}, idmNamespaces(dns); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected namespaces %q, got %q", expected, actual)
+	}
+	if expected, actual := []string{"ipa.example.com."}, idmAllowedZones(dns).List(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected allowed zones %q, got %q", expected, actual)
+	}
+	condition := computeIdMForwardingCondition(nil, nil, nil)
+	if condition.Status != operatorv1.ConditionFalse || !strings.Contains(condition.Message, IdMNamespacesAnnotation) {
+		t.Errorf("expected a false condition that names annotation %s, got %#v", IdMNamespacesAnnotation, condition)
+	}
+}
+
+// TestDesiredDNSConfigMapIdMResolvers verifies that the Corefile forwards the
+// zones of IdM DNS servers over DNS-over-TLS and that the configmap has their
+// CA certificates.
+func TestDesiredDNSConfigMapIdMResolvers(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        DefaultDNSController,
+			Annotations: map[string]string{IdMDiscoveryAnnotation: "true"},
+		},
+	}
+	resolver := idmResolver{
+		Name:          "idm-idm-ipa",
+		Zones:         []string{"ipa.example.com"},
+		Upstream:      "tls://172.30.5.5:853",
+		TLSServerName: "ipa.idm.svc",
+		CABundle:      testIdMCA,
+	}
+	cm, err := desiredDNSConfigMap(dns, corefileInputs{IdMResolvers: []idmResolver{resolver}, ClusterDomain: "cluster.local", MetricsAddress: "127.0.0.1:9153"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `# idm-idm-ipa (CA ` + resolver.CAHash() + `)
+ipa.example.com:5353 {
+    forward . tls://172.30.5.5:853 {
+        tls /etc/coredns/idm-idm-ipa.crt
+        tls_servername ipa.idm.svc
+    }
+    errors
+    bufsize 1232
+    prometheus 127.0.0.1:9153
+}
+.:5353 {`
+	if !strings.HasPrefix(cm.Data["Corefile"], expected) {
+		t.Errorf("expected Corefile to start with:\n%s\ngot:\n%s", expected, cm.Data["Corefile"])
+	}
+	if cm.Data[resolver.CAKey()] != testIdMCA {
+		t.Errorf("expected configmap to have the CA certificate, got %q", cm.Data[resolver.CAKey()])
+	}
+
+	ds, err := desiredDNSDaemonSet(dns, "coredns", "kube-rbac-proxy", "", "dns-default-metrics-tls", "", nil, nil, nil, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range ds.Spec.Template.Spec.Volumes {
+		if v.Name == "config-volume" && len(v.ConfigMap.Items) != 0 {
+			t.Errorf("expected the daemonset to mount every key of the configmap, got items %v", v.ConfigMap.Items)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	configv1 "github.com/openshift/api/config/v1"
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// DNSOperandImagesPinnedConditionType is the type of the DNS status
+	// condition that indicates whether the images of the dns daemonset are
+	// pinned by digest.  Pinning is required when the cluster's release
+	// image is pinned by digest and the ClusterVersion manages the
+	// operator's deployment.
+	DNSOperandImagesPinnedConditionType = conditions.TypeOperandImagesPinned
+
+	// operatorDeploymentName is the name of the operator's deployment, as
+	// a ClusterVersion component override names it.
+	operatorDeploymentName = "dns-operator"
+)
+
+// imagePinnedByDigest returns a Boolean value indicating whether the given
+// image reference is pinned by digest.
+func imagePinnedByDigest(image string) bool {
+	i := strings.LastIndex(image, "@")
+	return i >= 0 && strings.Contains(image[i+1:], ":")
+}
+
+// unpinnedImagesAllowed returns a Boolean value indicating whether the given
+// dns's UnsafeAllowUnpinnedImagesAnnotation annotation is set to true.
+func unpinnedImagesAllowed(dns *operatorv1.DNS) bool {
+	value, ok := dns.Annotations[UnsafeAllowUnpinnedImagesAnnotation]
+	if !ok {
+		return false
+	}
+	allowed, err := strconv.ParseBool(value)
+	if err != nil {
+		logrus.Warningf("ignoring invalid value %q for annotation %s on dns %s: %v", value, UnsafeAllowUnpinnedImagesAnnotation, dns.Name, err)
+		return false
+	}
+	return allowed
+}
+
+// imagePinningRequired returns a Boolean value indicating whether the
+// cluster's ClusterVersion requires operand images to be pinned by digest,
+// which is the case if the ClusterVersion's desired release image is pinned
+// by digest and the ClusterVersion does not mark the operator's deployment as
+// unmanaged.  Clusters without a ClusterVersion do not require pinning.
+func (r *reconciler) imagePinningRequired() (bool, error) {
+	cv := &configv1.ClusterVersion{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: "version"}, cv); err != nil {
+		if errors.IsNotFound(err) || meta.IsNoMatchError(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get clusterversion: %w", err)
+	}
+	return clusterVersionRequiresPinning(cv, r.OperatorNamespace), nil
+}
+
+// clusterVersionRequiresPinning returns a Boolean value indicating whether the
+// given ClusterVersion requires the operator, which runs in the given
+// namespace, to use operand images that are pinned by digest.
+func clusterVersionRequiresPinning(cv *configv1.ClusterVersion, operatorNamespace string) bool {
+	if !imagePinnedByDigest(cv.Status.Desired.Image) {
+		return false
+	}
+	for _, override := range cv.Spec.Overrides {
+		if override.Unmanaged && override.Kind == "Deployment" && override.Group == "apps" && override.Namespace == operatorNamespace && override.Name == operatorDeploymentName {
+			return false
+		}
+	}
+	return true
+}
+
+// ensureOperandImagesPinned checks whether the given coredns and
+// kube-rbac-proxy images are pinned by digest.  An empty kube-rbac-proxy image
+// indicates that the dns daemonset does not use a kube-rbac-proxy sidecar.  If
+// pinning is required and an image is not pinned, the dns daemonset keeps its
+// current image for that container unless the dns allows unpinned images.
+// Returns the images that the dns daemonset should use and a status condition
+// that describes the check.
+func (r *reconciler) ensureOperandImagesPinned(dns *operatorv1.DNS, wantCoreDNS, wantKubeRBACProxy string) (string, string, operatorv1.OperatorCondition, error) {
+	condition := operatorv1.OperatorCondition{
+		Type: DNSOperandImagesPinnedConditionType,
+	}
+	var unpinned []string
+	for _, image := range []string{wantCoreDNS, wantKubeRBACProxy} {
+		if len(image) != 0 && !imagePinnedByDigest(image) {
+			unpinned = append(unpinned, strconv.Quote(image))
+		}
+	}
+	if len(unpinned) == 0 {
+		condition.Status = operatorv1.ConditionTrue
+		condition.Reason = conditions.ReasonAsExpected
+		condition.Message = "The operand images are pinned by digest."
+		return wantCoreDNS, wantKubeRBACProxy, condition, nil
+	}
+
+	required, err := r.imagePinningRequired()
+	if err != nil {
+		return wantCoreDNS, wantKubeRBACProxy, condition, err
+	}
+	switch {
+	case !required:
+		condition.Status = operatorv1.ConditionTrue
+		condition.Reason = conditions.ReasonPinningNotRequired
+		condition.Message = fmt.Sprintf("Images %s are not pinned by digest, which the cluster version does not require.", strings.Join(unpinned, " and "))
+		return wantCoreDNS, wantKubeRBACProxy, condition, nil
+	case unpinnedImagesAllowed(dns):
+		condition.Status = operatorv1.ConditionFalse
+		condition.Reason = conditions.ReasonUnpinnedImagesAllowed
+		condition.Message = fmt.Sprintf("Images %s are not pinned by digest, but the %s annotation allows them to be used.", strings.Join(unpinned, " and "), UnsafeAllowUnpinnedImagesAnnotation)
+		return wantCoreDNS, wantKubeRBACProxy, condition, nil
+	}
+
+	// Keep the current image for each container whose new image is not
+	// pinned.  If the daemonset does not exist yet, there is no image to
+	// keep, so use the new images rather than leave the cluster without
+	// DNS.
+	haveDS, current, err := r.currentDNSDaemonSet(dns)
+	if err != nil {
+		return wantCoreDNS, wantKubeRBACProxy, condition, err
+	}
+	condition.Status = operatorv1.ConditionFalse
+	if !haveDS {
+		condition.Reason = conditions.ReasonUnpinnedImagesInUse
+		condition.Message = fmt.Sprintf("Images %s are not pinned by digest, which the cluster version requires.  The DNS daemonset does not exist yet, so it uses them.  Set the %s annotation to \"true\" to allow unpinned images.", strings.Join(unpinned, " and "), UnsafeAllowUnpinnedImagesAnnotation)
+		return wantCoreDNS, wantKubeRBACProxy, condition, nil
+	}
+	haveCoreDNS, haveKubeRBACProxy := daemonsetImages(current)
+	coreDNSImage, kubeRBACProxyImage := wantCoreDNS, wantKubeRBACProxy
+	if !imagePinnedByDigest(wantCoreDNS) {
+		coreDNSImage = haveCoreDNS
+	}
+	if len(wantKubeRBACProxy) != 0 && !imagePinnedByDigest(wantKubeRBACProxy) {
+		// The sidecar cannot be added without an image.
+		if len(haveKubeRBACProxy) == 0 {
+			kubeRBACProxyImage = wantKubeRBACProxy
+		} else {
+			kubeRBACProxyImage = haveKubeRBACProxy
+		}
+	}
+	condition.Reason = conditions.ReasonUnpinnedImagesRefused
+	condition.Message = fmt.Sprintf("Images %s are not pinned by digest, which the cluster version requires, so the DNS daemonset keeps its current images.  Set the %s annotation to \"true\" to allow unpinned images.", strings.Join(unpinned, " and "), UnsafeAllowUnpinnedImagesAnnotation)
+	logrus.Warningf("refusing unpinned operand images %s for dns %s", strings.Join(unpinned, " and "), dns.Name)
+	return coreDNSImage, kubeRBACProxyImage, condition, nil
+}
@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"testing"
+
+	configv1 "github.com/openshift/api/config/v1"
+)
+
+// TestImagePinnedByDigest verifies that imagePinnedByDigest accepts digest
+// references and rejects tag references.
+func TestImagePinnedByDigest(t *testing.T) {
+	for image, expected := range map[string]bool{
+		"quay.io/openshift/coredns@sha256:0123456789abcdef": true,
+		"quay.io/openshift/coredns:latest@sha256:0123":      true,
+		"quay.io/openshift/coredns:latest":                  false,
+		"registry.local:5000/coredns":                       false,
+		"":                                                  false,
+	} {
+		if actual := imagePinnedByDigest(image); actual != expected {
+			t.Errorf("expected %t for %q, got %t", expected, image, actual)
+		}
+	}
+}
+
+// TestClusterVersionRequiresPinning verifies that pinning is required when
+// the release image is pinned by digest, unless the ClusterVersion marks the
+// operator's deployment as unmanaged.
+func TestClusterVersionRequiresPinning(t *testing.T) {
+	const namespace = "openshift-dns-operator"
+	override := func(unmanaged bool, name string) configv1.ComponentOverride {
+		return configv1.ComponentOverride{Kind: "Deployment", Group: "apps", Namespace: namespace, Name: name, Unmanaged: unmanaged}
+	}
+	testCases := []struct {
+		description string
+		image       string
+		overrides   []configv1.ComponentOverride
+		expected    bool
+	}{
+		{
+			description: "pinned release image",
+			image:       "quay.io/openshift-release-dev/ocp-release@sha256:abcd",
+			expected:    true,
+		},
+		{
+			description: "tagged release image",
+			image:       "quay.io/openshift-release-dev/ocp-release:4.10.0",
+			expected:    false,
+		},
+		{
+			description: "unmanaged operator deployment",
+			image:       "quay.io/openshift-release-dev/ocp-release@sha256:abcd",
+			overrides:   []configv1.ComponentOverride{override(true, "dns-operator")},
+			expected:    false,
+		},
+		{
+			description: "managed operator deployment override",
+			image:       "quay.io/openshift-release-dev/ocp-release@sha256:abcd",
+			overrides:   []configv1.ComponentOverride{override(false, "dns-operator"), override(true, "other")},
+			expected:    true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			cv := &configv1.ClusterVersion{
+				Spec:   configv1.ClusterVersionSpec{Overrides: tc.overrides},
+				Status: configv1.ClusterVersionStatus{Desired: configv1.Release{Image: tc.image}},
+			}
+			if actual := clusterVersionRequiresPinning(cv, namespace); actual != tc.expected {
+				t.Errorf("expected %t, got %t", tc.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	"github.com/sirupsen/logrus"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// DNSOperandImagesVerifiedConditionType is the type of the DNS status
+	// condition that indicates whether the operand images that the
+	// operator wants to roll out have been verified to be pullable.
+	DNSOperandImagesVerifiedConditionType = conditions.TypeOperandImagesVerified
+)
+
+// imagePullFailureReasons are the container waiting reasons that indicate
+// that an image cannot be pulled.
+var imagePullFailureReasons = map[string]bool{
+	"ErrImagePull":      true,
+	"ImagePullBackOff":  true,
+	"InvalidImageName":  true,
+	"ErrImageNeverPull": true,
+}
+
+// ensureOperandImagesVerified verifies that the given coredns and
+// kube-rbac-proxy images can be pulled before they are rolled out to the dns
+// daemonset.  An empty kube-rbac-proxy image indicates that the dns daemonset
+// does not use a kube-rbac-proxy sidecar.  Verification uses a short-lived pod
+// that runs the new images with the same service account and node placement as
+// the dns daemonset.  Returns the images that the dns daemonset should use,
+// which are the current images if verification has failed or is still in
+// progress, and a status condition describing the verification.
+func (r *reconciler) ensureOperandImagesVerified(dns *operatorv1.DNS, wantCoreDNS, wantKubeRBACProxy string) (string, string, operatorv1.OperatorCondition, error) {
+	condition := operatorv1.OperatorCondition{
+		Type: DNSOperandImagesVerifiedConditionType,
+	}
+
+	haveDS, current, err := r.currentDNSDaemonSet(dns)
+	if err != nil {
+		return wantCoreDNS, wantKubeRBACProxy, condition, err
+	}
+	// With no current daemonset, there is no rollout to protect.
+	if !haveDS {
+		condition.Status = operatorv1.ConditionTrue
+		condition.Reason = conditions.ReasonAsExpected
+		condition.Message = "The DNS daemonset does not exist yet, so no image rollout is pending."
+		return wantCoreDNS, wantKubeRBACProxy, condition, nil
+	}
+	haveCoreDNS, haveKubeRBACProxy := daemonsetImages(current)
+	if len(wantKubeRBACProxy) == 0 {
+		// Dropping the sidecar does not require pulling anything.
+		haveKubeRBACProxy = ""
+	}
+	if haveCoreDNS == wantCoreDNS && haveKubeRBACProxy == wantKubeRBACProxy {
+		if err := r.deleteImageVerificationPod(dns); err != nil {
+			return wantCoreDNS, wantKubeRBACProxy, condition, err
+		}
+		condition.Status = operatorv1.ConditionTrue
+		condition.Reason = conditions.ReasonAsExpected
+		condition.Message = "The DNS daemonset is using the desired images."
+		return wantCoreDNS, wantKubeRBACProxy, condition, nil
+	}
+
+	desired := desiredImageVerificationPod(dns, wantCoreDNS, wantKubeRBACProxy)
+	pod, err := r.ensureImageVerificationPod(dns, desired)
+	if err != nil {
+		return haveCoreDNS, haveKubeRBACProxy, condition, err
+	}
+	pulled, failure := imageVerificationPodResult(pod)
+	switch {
+	case len(failure) != 0:
+		condition.Status = operatorv1.ConditionFalse
+		condition.Reason = conditions.ReasonImagePullFailed
+		condition.Message = fmt.Sprintf("Holding the rollout of the DNS daemonset: %s", failure)
+		logrus.Warningf("holding rollout of dns daemonset for dns %s: %s", dns.Name, failure)
+		return haveCoreDNS, haveKubeRBACProxy, condition, nil
+	case !pulled:
+		condition.Status = operatorv1.ConditionUnknown
+		condition.Reason = conditions.ReasonVerifying
+		condition.Message = fmt.Sprintf("Verifying that images %q and %q can be pulled before rolling them out.", wantCoreDNS, wantKubeRBACProxy)
+		return haveCoreDNS, haveKubeRBACProxy, condition, nil
+	}
+
+	if err := r.deleteImageVerificationPod(dns); err != nil {
+		return wantCoreDNS, wantKubeRBACProxy, condition, err
+	}
+	condition.Status = operatorv1.ConditionTrue
+	condition.Reason = conditions.ReasonAsExpected
+	condition.Message = fmt.Sprintf("Verified that images %q and %q can be pulled.", wantCoreDNS, wantKubeRBACProxy)
+	return wantCoreDNS, wantKubeRBACProxy, condition, nil
+}
+
+// daemonsetImages returns the images of the dns and kube-rbac-proxy
+// containers in the given dns daemonset.
+func daemonsetImages(daemonset *appsv1.DaemonSet) (string, string) {
+	var coreDNSImage, kubeRBACProxyImage string
+	for _, c := range daemonset.Spec.Template.Spec.Containers {
+		switch c.Name {
+		case "dns":
+			coreDNSImage = c.Image
+		case "kube-rbac-proxy":
+			kubeRBACProxyImage = c.Image
+		}
+	}
+	return coreDNSImage, kubeRBACProxyImage
+}
+
+// desiredImageVerificationPod returns a pod that pulls the given images.  The
+// containers only print version or usage information and exit; what matters
+// is whether the kubelet is able to pull the images.  If kubeRBACProxyImage is
+// empty, the pod only pulls the coredns image.
+func desiredImageVerificationPod(dns *operatorv1.DNS, coreDNSImage, kubeRBACProxyImage string) *corev1.Pod {
+	name := DNSImageVerificationPodName(dns)
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name.Name,
+			Namespace:       name.Namespace,
+			OwnerReferences: []metav1.OwnerReference{dnsOwnerRef(dns)},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name:                     "dns",
+					Image:                    coreDNSImage,
+					ImagePullPolicy:          corev1.PullIfNotPresent,
+					Command:                  []string{"coredns", "-version"},
+					TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+				},
+				{
+					Name:                     "kube-rbac-proxy",
+					Image:                    kubeRBACProxyImage,
+					ImagePullPolicy:          corev1.PullIfNotPresent,
+					Args:                     []string{"--help"},
+					TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+				},
+			},
+			NodeSelector:       nodeSelectorForDNS(dns),
+			PriorityClassName:  "system-node-critical",
+			RestartPolicy:      corev1.RestartPolicyNever,
+			ServiceAccountName: "dns",
+			Tolerations:        tolerationsForDNS(dns),
+		},
+	}
+	if len(kubeRBACProxyImage) == 0 {
+		pod.Spec.Containers = pod.Spec.Containers[:1]
+	}
+	return pod
+}
+
+// ensureImageVerificationPod ensures that the image verification pod exists
+// and uses the desired images, recreating it if the desired images have
+// changed.
+func (r *reconciler) ensureImageVerificationPod(dns *operatorv1.DNS, desired *corev1.Pod) (*corev1.Pod, error) {
+	current := &corev1.Pod{}
+	name := DNSImageVerificationPodName(dns)
+	if err := r.client.Get(context.TODO(), name, current); err != nil {
+		if !errors.IsNotFound(err) {
+			return nil, fmt.Errorf("failed to get image verification pod %s: %w", name, err)
+		}
+		if err := r.client.Create(context.TODO(), desired); err != nil {
+			return nil, fmt.Errorf("failed to create image verification pod %s: %w", name, err)
+		}
+		logrus.Infof("created image verification pod %s", name)
+		return desired, nil
+	}
+	if podImagesMatch(current, desired) {
+		return current, nil
+	}
+	if err := r.client.Delete(context.TODO(), current); err != nil && !errors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to delete stale image verification pod %s: %w", name, err)
+	}
+	logrus.Infof("deleted stale image verification pod %s", name)
+	// Return the desired pod, which has an empty status; the pod is
+	// recreated on a subsequent reconciliation once the deletion is
+	// observed.
+	return desired, nil
+}
+
+// deleteImageVerificationPod deletes the image verification pod for the given
+// dns if it exists.
+func (r *reconciler) deleteImageVerificationPod(dns *operatorv1.DNS) error {
+	name := DNSImageVerificationPodName(dns)
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name.Name,
+			Namespace: name.Namespace,
+		},
+	}
+	if err := r.client.Delete(context.TODO(), pod); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to delete image verification pod %s: %w", name, err)
+	}
+	logrus.Infof("deleted image verification pod %s", name)
+	return nil
+}
+
+// podImagesMatch returns a Boolean value indicating whether the given pods
+// have containers with the same names and images.
+func podImagesMatch(a, b *corev1.Pod) bool {
+	if len(a.Spec.Containers) != len(b.Spec.Containers) {
+		return false
+	}
+	for i := range a.Spec.Containers {
+		if a.Spec.Containers[i].Name != b.Spec.Containers[i].Name {
+			return false
+		}
+		if a.Spec.Containers[i].Image != b.Spec.Containers[i].Image {
+			return false
+		}
+	}
+	return true
+}
+
+// imageVerificationPodResult inspects the given image verification pod and
+// returns a Boolean value indicating whether all of its images have been
+// pulled and a message describing the failure if any image cannot be pulled.
+func imageVerificationPodResult(pod *corev1.Pod) (bool, string) {
+	statuses := pod.Status.ContainerStatuses
+	if len(statuses) != len(pod.Spec.Containers) {
+		return false, ""
+	}
+	pulled := true
+	for _, cs := range statuses {
+		if cs.State.Waiting != nil && imagePullFailureReasons[cs.State.Waiting.Reason] {
+			return false, fmt.Sprintf("image %q for container %q cannot be pulled: %s: %s", cs.Image, cs.Name, cs.State.Waiting.Reason, cs.State.Waiting.Message)
+		}
+		if len(cs.ImageID) == 0 {
+			pulled = false
+		}
+	}
+	return pulled, ""
+}
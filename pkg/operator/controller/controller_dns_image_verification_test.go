@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"testing"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	corev1 "k8s.io/api/core/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestImageVerificationPodResult verifies that imageVerificationPodResult
+// correctly distinguishes pulled images, pending pulls, and pull failures.
+func TestImageVerificationPodResult(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DefaultDNSController,
+		},
+	}
+	pulled := func(name string) corev1.ContainerStatus {
+		return corev1.ContainerStatus{
+			Name:    name,
+			ImageID: "sha256:abc",
+			State: corev1.ContainerState{
+				Terminated: &corev1.ContainerStateTerminated{},
+			},
+		}
+	}
+	waiting := func(name, reason string) corev1.ContainerStatus {
+		return corev1.ContainerStatus{
+			Name: name,
+			State: corev1.ContainerState{
+				Waiting: &corev1.ContainerStateWaiting{Reason: reason},
+			},
+		}
+	}
+	testCases := []struct {
+		description   string
+		statuses      []corev1.ContainerStatus
+		expectPulled  bool
+		expectFailure bool
+	}{
+		{
+			description: "no container statuses",
+		},
+		{
+			description:  "all images pulled",
+			statuses:     []corev1.ContainerStatus{pulled("dns"), pulled("kube-rbac-proxy")},
+			expectPulled: true,
+		},
+		{
+			description: "one image still being pulled",
+			statuses:    []corev1.ContainerStatus{pulled("dns"), waiting("kube-rbac-proxy", "ContainerCreating")},
+		},
+		{
+			description:   "one image cannot be pulled",
+			statuses:      []corev1.ContainerStatus{waiting("dns", "ImagePullBackOff"), pulled("kube-rbac-proxy")},
+			expectFailure: true,
+		},
+	}
+	for _, tc := range testCases {
+		pod := desiredImageVerificationPod(dns, "coredns", "kube-rbac-proxy")
+		pod.Status.ContainerStatuses = tc.statuses
+		pulled, failure := imageVerificationPodResult(pod)
+		if pulled != tc.expectPulled {
+			t.Errorf("%s: expected pulled to be %t, got %t", tc.description, tc.expectPulled, pulled)
+		}
+		if (len(failure) != 0) != tc.expectFailure {
+			t.Errorf("%s: expected failure to be %t, got %q", tc.description, tc.expectFailure, failure)
+		}
+	}
+}
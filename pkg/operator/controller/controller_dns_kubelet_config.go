@@ -0,0 +1,201 @@
+package controller
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+const (
+	// DNSKubeletClusterDNSConsistentConditionType is the type of the DNS
+	// status condition that indicates whether the kubelets on the
+	// cluster's nodes send pods' DNS queries to the DNS service's cluster
+	// IP.  The condition is reported only if the cluster has the machine
+	// config API.
+	DNSKubeletClusterDNSConsistentConditionType = conditions.TypeKubeletClusterDNSConsistent
+
+	// currentMachineConfigAnnotation is the annotation on a node that
+	// names the rendered machine config that the node runs.
+	currentMachineConfigAnnotation = "machineconfiguration.openshift.io/currentConfig"
+	// kubeletConfigPath is the path of the kubelet configuration file in
+	// a machine config.
+	kubeletConfigPath = "/etc/kubernetes/kubelet.conf"
+)
+
+// machineConfigGVK is the group, version, and kind of the machine config
+// operator's MachineConfig resource.  The operator uses unstructured objects
+// for MachineConfig resources so that it does not depend on the machine config
+// operator's API.
+var machineConfigGVK = schema.GroupVersionKind{
+	Group:   "machineconfiguration.openshift.io",
+	Version: "v1",
+	Kind:    "MachineConfig",
+}
+
+// computeDNSKubeletClusterDNSConsistentCondition compares the clusterDNS
+// setting in the kubelet configuration of each node's rendered machine config
+// with the given cluster IP of the dns service and returns a status condition
+// that reports nodes whose kubelets use other addresses.  If the cluster does
+// not have the machine config API, the function returns nil.
+func (r *reconciler) computeDNSKubeletClusterDNSConsistentCondition(clusterIP string) (*operatorv1.OperatorCondition, error) {
+	nodes, err := ListNodes(context.TODO(), r.cache)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+	// Group the node names by machine config.
+	nodesByConfig := map[string]sets.String{}
+	for _, node := range nodes {
+		name := node.Annotations[currentMachineConfigAnnotation]
+		if len(name) == 0 {
+			continue
+		}
+		if _, ok := nodesByConfig[name]; !ok {
+			nodesByConfig[name] = sets.NewString()
+		}
+		nodesByConfig[name].Insert(node.Name)
+	}
+	configNames := make([]string, 0, len(nodesByConfig))
+	for name := range nodesByConfig {
+		configNames = append(configNames, name)
+	}
+	sort.Strings(configNames)
+
+	checked, mismatched := sets.NewString(), sets.NewString()
+	mismatchedAddresses := sets.NewString()
+	for _, name := range configNames {
+		mc := &unstructured.Unstructured{}
+		mc.SetGroupVersionKind(machineConfigGVK)
+		if err := r.client.Get(context.TODO(), types.NamespacedName{Name: name}, mc); err != nil {
+			switch {
+			case meta.IsNoMatchError(err):
+				return nil, nil
+			case errors.IsNotFound(err):
+				continue
+			}
+			return nil, fmt.Errorf("failed to get machineconfig %s: %w", name, err)
+		}
+		clusterDNS, found, err := machineConfigClusterDNS(mc)
+		if err != nil {
+			logrus.Warningf("failed to read kubelet configuration from machineconfig %s: %v", name, err)
+			continue
+		}
+		if !found {
+			continue
+		}
+		checked = checked.Union(nodesByConfig[name])
+		if !sets.NewString(clusterDNS...).Has(clusterIP) {
+			mismatched = mismatched.Union(nodesByConfig[name])
+			mismatchedAddresses.Insert(clusterDNS...)
+		}
+	}
+	if checked.Len() == 0 {
+		return nil, nil
+	}
+
+	if mismatched.Len() != 0 {
+		return &operatorv1.OperatorCondition{
+			Type:    DNSKubeletClusterDNSConsistentConditionType,
+			Status:  operatorv1.ConditionFalse,
+			Reason:  conditions.ReasonClusterDNSMismatch,
+			Message: fmt.Sprintf("The kubelets on %d nodes send pods' DNS queries to %s rather than to the DNS service's cluster IP %s, so pods on those nodes may fail to resolve names: %s.", mismatched.Len(), strings.Join(mismatchedAddresses.List(), ", "), clusterIP, summarizeNodeNames(mismatched)),
+		}, nil
+	}
+	return &operatorv1.OperatorCondition{
+		Type:    DNSKubeletClusterDNSConsistentConditionType,
+		Status:  operatorv1.ConditionTrue,
+		Reason:  conditions.ReasonAsExpected,
+		Message: fmt.Sprintf("The kubelets on all %d nodes with known machine configs send pods' DNS queries to the DNS service's cluster IP %s.", checked.Len(), clusterIP),
+	}, nil
+}
+
+// kubeletClusterDNSConfig has the kubelet configuration field that
+// machineConfigClusterDNS reads.
+type kubeletClusterDNSConfig struct {
+	ClusterDNS []string `json:"clusterDNS"`
+}
+
+// machineConfigClusterDNS returns the clusterDNS addresses in the kubelet
+// configuration file of the given machine config, and a Boolean value
+// indicating whether the machine config has the kubelet configuration file.
+func machineConfigClusterDNS(mc *unstructured.Unstructured) ([]string, bool, error) {
+	files, _, err := unstructured.NestedSlice(mc.Object, "spec", "config", "storage", "files")
+	if err != nil {
+		return nil, false, err
+	}
+	for _, f := range files {
+		file, ok := f.(map[string]interface{})
+		if !ok || file["path"] != kubeletConfigPath {
+			continue
+		}
+		source, _, _ := unstructured.NestedString(file, "contents", "source")
+		compression, _, _ := unstructured.NestedString(file, "contents", "compression")
+		data, err := decodeIgnitionContents(source, compression)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to decode %s: %w", kubeletConfigPath, err)
+		}
+		config := kubeletClusterDNSConfig{}
+		if err := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096).Decode(&config); err != nil {
+			return nil, false, fmt.Errorf("failed to parse %s: %w", kubeletConfigPath, err)
+		}
+		return config.ClusterDNS, true, nil
+	}
+	return nil, false, nil
+}
+
+// decodeIgnitionContents decodes the given data URL from the contents of an
+// Ignition file, which may be compressed with gzip.
+func decodeIgnitionContents(source, compression string) ([]byte, error) {
+	if !strings.HasPrefix(source, "data:") {
+		return nil, fmt.Errorf("unsupported source %q", source)
+	}
+	i := strings.Index(source, ",")
+	if i < 0 {
+		return nil, fmt.Errorf("invalid data URL")
+	}
+	mediaType, payload := source[len("data:"):i], source[i+1:]
+	var data []byte
+	if strings.HasSuffix(mediaType, ";base64") {
+		decoded, err := base64.StdEncoding.DecodeString(payload)
+		if err != nil {
+			return nil, err
+		}
+		data = decoded
+	} else {
+		unescaped, err := url.PathUnescape(payload)
+		if err != nil {
+			return nil, err
+		}
+		data = []byte(unescaped)
+	}
+	switch compression {
+	case "":
+		return data, nil
+	case "gzip":
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	}
+	return nil, fmt.Errorf("unsupported compression %q", compression)
+}
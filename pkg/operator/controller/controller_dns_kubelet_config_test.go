@@ -0,0 +1,96 @@
+package controller
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// TestMachineConfigClusterDNS verifies that machineConfigClusterDNS reads the
+// clusterDNS setting from plain, base64-encoded, and gzip-compressed kubelet
+// configuration files.
+func TestMachineConfigClusterDNS(t *testing.T) {
+	kubeletConfig := "kind: KubeletConfiguration\napiVersion: kubelet.config.k8s.io/v1beta1\nclusterDNS:\n  - 172.30.0.10\nclusterDomain: cluster.local\n"
+	var compressed bytes.Buffer
+	w := gzip.NewWriter(&compressed)
+	if _, err := w.Write([]byte(kubeletConfig)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	machineConfig := func(path, source, compression string) *unstructured.Unstructured {
+		contents := map[string]interface{}{"source": source}
+		if len(compression) != 0 {
+			contents["compression"] = compression
+		}
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"spec": map[string]interface{}{
+				"config": map[string]interface{}{
+					"storage": map[string]interface{}{
+						"files": []interface{}{
+							map[string]interface{}{
+								"path":     "/etc/other",
+								"contents": map[string]interface{}{"source": "data:,other"},
+							},
+							map[string]interface{}{
+								"path":     path,
+								"contents": contents,
+							},
+						},
+					},
+				},
+			},
+		}}
+	}
+	testCases := []struct {
+		description string
+		mc          *unstructured.Unstructured
+		expected    []string
+		expectFound bool
+	}{
+		{
+			description: "percent-encoded",
+			mc:          machineConfig(kubeletConfigPath, "data:,"+url.PathEscape(kubeletConfig), ""),
+			expected:    []string{"172.30.0.10"},
+			expectFound: true,
+		},
+		{
+			description: "base64-encoded",
+			mc:          machineConfig(kubeletConfigPath, "data:text/plain;charset=utf-8;base64,"+base64.StdEncoding.EncodeToString([]byte(kubeletConfig)), ""),
+			expected:    []string{"172.30.0.10"},
+			expectFound: true,
+		},
+		{
+			description: "gzip-compressed",
+			mc:          machineConfig(kubeletConfigPath, "data:;base64,"+base64.StdEncoding.EncodeToString(compressed.Bytes()), "gzip"),
+			expected:    []string{"172.30.0.10"},
+			expectFound: true,
+		},
+		{
+			description: "no kubelet configuration",
+			mc:          machineConfig("/etc/kubernetes/other.conf", "data:,"+url.PathEscape(kubeletConfig), ""),
+			expectFound: false,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			clusterDNS, found, err := machineConfigClusterDNS(tc.mc)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if found != tc.expectFound || !reflect.DeepEqual(clusterDNS, tc.expected) {
+				t.Errorf("expected (%v, %t), got (%v, %t)", tc.expected, tc.expectFound, clusterDNS, found)
+			}
+		})
+	}
+
+	if _, _, err := machineConfigClusterDNS(machineConfig(kubeletConfigPath, "https://example.com/kubelet.conf", "")); err == nil {
+		t.Errorf("expected an error for a remote source")
+	}
+}
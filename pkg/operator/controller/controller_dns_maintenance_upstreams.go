@@ -0,0 +1,209 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maintenanceWindowSpec is an entry in the value of a dns's
+// MaintenanceUpstreamsAnnotation annotation.
+type maintenanceWindowSpec struct {
+	// Server is the name of the server in the dns's spec.servers whose
+	// upstreams are replaced during the window.
+	Server string `json:"server"`
+	// Upstreams are the upstreams that the server uses during the window.
+	Upstreams []string `json:"upstreams"`
+	// Days are the days of the week, such as "Saturday" or "Sat", on
+	// which the window starts.  If empty, the window starts every day.
+	Days []string `json:"days,omitempty"`
+	// Start is the time of day in UTC, in the format "15:04", at which
+	// the window starts.
+	Start string `json:"start"`
+	// Duration is the length of the window, in the format that
+	// time.ParseDuration accepts, such as "2h30m".
+	Duration string `json:"duration"`
+}
+
+// maintenanceWindow is a validated maintenanceWindowSpec.
+type maintenanceWindow struct {
+	server    string
+	upstreams []string
+	// days is the set of days of the week on which the window starts, or
+	// nil if the window starts every day.
+	days map[time.Weekday]bool
+	// start is the offset from midnight UTC at which the window starts.
+	start    time.Duration
+	duration time.Duration
+}
+
+// weekdays maps the full and abbreviated names of the days of the week, in
+// lower case, to the days.
+var weekdays = func() map[string]time.Weekday {
+	m := map[string]time.Weekday{}
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		m[strings.ToLower(d.String())] = d
+		m[strings.ToLower(d.String()[:3])] = d
+	}
+	return m
+}()
+
+// maintenanceWindows returns the valid maintenance windows in the given dns's
+// MaintenanceUpstreamsAnnotation annotation.  Invalid windows are logged and
+// ignored.
+func maintenanceWindows(dns *operatorv1.DNS) []maintenanceWindow {
+	value, ok := dns.Annotations[MaintenanceUpstreamsAnnotation]
+	if !ok || len(strings.TrimSpace(value)) == 0 {
+		return nil
+	}
+	var specs []maintenanceWindowSpec
+	if err := json.Unmarshal([]byte(value), &specs); err != nil {
+		logrus.Warningf("ignoring invalid value for annotation %s on dns %s: %v", MaintenanceUpstreamsAnnotation, dns.Name, err)
+		return nil
+	}
+	var windows []maintenanceWindow
+	for i, spec := range specs {
+		window, err := parseMaintenanceWindow(spec)
+		if err != nil {
+			logrus.Warningf("ignoring invalid maintenance window %d in annotation %s on dns %s: %v", i, MaintenanceUpstreamsAnnotation, dns.Name, err)
+			continue
+		}
+		windows = append(windows, window)
+	}
+	return windows
+}
+
+// parseMaintenanceWindow validates the given maintenance window spec and
+// returns the corresponding maintenance window.
+func parseMaintenanceWindow(spec maintenanceWindowSpec) (maintenanceWindow, error) {
+	window := maintenanceWindow{server: spec.Server}
+	if len(spec.Server) == 0 {
+		return window, fmt.Errorf("server must be specified")
+	}
+	if len(spec.Upstreams) == 0 {
+		return window, fmt.Errorf("upstreams must be specified for server %q", spec.Server)
+	}
+	window.upstreams = spec.Upstreams
+	if err := window.parseSchedule(spec.Days, spec.Start, spec.Duration); err != nil {
+		return window, fmt.Errorf("%w for server %q", err, spec.Server)
+	}
+	return window, nil
+}
+
+// parseSchedule validates the given days, start, and duration of a window and
+// sets them on the window.
+func (w *maintenanceWindow) parseSchedule(days []string, start, duration string) error {
+	for _, day := range days {
+		d, ok := weekdays[strings.ToLower(day)]
+		if !ok {
+			return fmt.Errorf("invalid day %q", day)
+		}
+		if w.days == nil {
+			w.days = map[time.Weekday]bool{}
+		}
+		w.days[d] = true
+	}
+	t, err := time.Parse("15:04", start)
+	if err != nil {
+		return fmt.Errorf("invalid start %q (%v)", start, err)
+	}
+	w.start = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	d, err := time.ParseDuration(duration)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q (%v)", duration, err)
+	}
+	// A window must end before the next window on the same day of the
+	// following week starts.
+	if d <= 0 || d >= 7*24*time.Hour {
+		return fmt.Errorf("duration %q must be positive and less than one week", duration)
+	}
+	w.duration = d
+	return nil
+}
+
+// starts returns the start times of the given window's occurrences that
+// overlap the week before or the week after the given time.
+func (w maintenanceWindow) starts(now time.Time) []time.Time {
+	now = now.UTC()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	var starts []time.Time
+	for i := -7; i <= 7; i++ {
+		day := midnight.AddDate(0, 0, i)
+		if w.days != nil && !w.days[day.Weekday()] {
+			continue
+		}
+		starts = append(starts, day.Add(w.start))
+	}
+	return starts
+}
+
+// active returns a Boolean value indicating whether the given window is in
+// effect at the given time.
+func (w maintenanceWindow) active(now time.Time) bool {
+	for _, start := range w.starts(now) {
+		if !now.Before(start) && now.Before(start.Add(w.duration)) {
+			return true
+		}
+	}
+	return false
+}
+
+// activeMaintenanceUpstreams returns a map of server names to the upstreams of
+// the first of the given windows for each server that is in effect at the
+// given time.
+func activeMaintenanceUpstreams(windows []maintenanceWindow, now time.Time) map[string][]string {
+	active := map[string][]string{}
+	for _, w := range windows {
+		if _, ok := active[w.server]; ok {
+			continue
+		}
+		if w.active(now) {
+			active[w.server] = w.upstreams
+		}
+	}
+	return active
+}
+
+// nextMaintenanceTransition returns the time until the next start or end of
+// any of the given windows after the given time, and a Boolean value that is
+// false if there are no windows.
+func nextMaintenanceTransition(windows []maintenanceWindow, now time.Time) (time.Duration, bool) {
+	var next time.Duration
+	found := false
+	for _, w := range windows {
+		for _, start := range w.starts(now) {
+			for _, t := range []time.Time{start, start.Add(w.duration)} {
+				if !t.After(now) {
+					continue
+				}
+				if d := t.Sub(now); !found || d < next {
+					next, found = d, true
+				}
+			}
+		}
+	}
+	return next, found
+}
+
+// applyMaintenanceUpstreams returns a copy of the given servers in which the
+// upstreams of each server that has an entry in the given map are replaced
+// with the upstreams in the entry.
+func applyMaintenanceUpstreams(servers []operatorv1.Server, active map[string][]string) []operatorv1.Server {
+	if len(active) == 0 {
+		return servers
+	}
+	result := make([]operatorv1.Server, 0, len(servers))
+	for _, server := range servers {
+		if upstreams, ok := active[server.Name]; ok {
+			server = *server.DeepCopy()
+			server.ForwardPlugin.Upstreams = append([]string(nil), upstreams...)
+		}
+		result = append(result, server)
+	}
+	return result
+}
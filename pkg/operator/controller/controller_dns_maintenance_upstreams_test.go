@@ -0,0 +1,169 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestMaintenanceWindows verifies that maintenanceWindows parses the
+// maintenance upstreams annotation and ignores invalid windows.
+func TestMaintenanceWindows(t *testing.T) {
+	testCases := []struct {
+		description string
+		annotation  string
+		expected    []maintenanceWindow
+	}{
+		{
+			description: "no annotation",
+		},
+		{
+			description: "invalid JSON",
+			annotation:  "not json",
+		},
+		{
+			description: "valid and invalid windows",
+			annotation: `[
+				{"server": "foo", "upstreams": ["10.0.0.53"], "days": ["Sat", "sunday"], "start": "02:30", "duration": "4h"},
+				{"server": "bar", "upstreams": ["10.0.0.54"], "start": "23:00", "duration": "2h"},
+				{"server": "", "upstreams": ["10.0.0.55"], "start": "01:00", "duration": "1h"},
+				{"server": "baz", "start": "01:00", "duration": "1h"},
+				{"server": "baz", "upstreams": ["10.0.0.55"], "days": ["Caturday"], "start": "01:00", "duration": "1h"},
+				{"server": "baz", "upstreams": ["10.0.0.55"], "start": "25:00", "duration": "1h"},
+				{"server": "baz", "upstreams": ["10.0.0.55"], "start": "01:00", "duration": "168h"}
+			]`,
+			expected: []maintenanceWindow{{
+				server:    "foo",
+				upstreams: []string{"10.0.0.53"},
+				days:      map[time.Weekday]bool{time.Saturday: true, time.Sunday: true},
+				start:     2*time.Hour + 30*time.Minute,
+				duration:  4 * time.Hour,
+			}, {
+				server:    "bar",
+				upstreams: []string{"10.0.0.54"},
+				start:     23 * time.Hour,
+				duration:  2 * time.Hour,
+			}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			dns := &operatorv1.DNS{
+				ObjectMeta: metav1.ObjectMeta{Name: DefaultDNSController},
+			}
+			if len(tc.annotation) != 0 {
+				dns.Annotations = map[string]string{MaintenanceUpstreamsAnnotation: tc.annotation}
+			}
+			if actual := maintenanceWindows(dns); !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("expected %#v, got %#v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+// TestMaintenanceUpstreamsSchedule verifies that activeMaintenanceUpstreams
+// and nextMaintenanceTransition follow the windows' schedules, including
+// windows that span midnight.
+func TestMaintenanceUpstreamsSchedule(t *testing.T) {
+	windows := []maintenanceWindow{{
+		server:    "foo",
+		upstreams: []string{"10.0.0.53"},
+		days:      map[time.Weekday]bool{time.Saturday: true},
+		start:     2 * time.Hour,
+		duration:  4 * time.Hour,
+	}, {
+		server:    "bar",
+		upstreams: []string{"10.0.0.54"},
+		start:     23 * time.Hour,
+		duration:  2 * time.Hour,
+	}, {
+		// A later window for the same server is superseded.
+		server:    "bar",
+		upstreams: []string{"10.0.0.99"},
+		start:     0,
+		duration:  time.Hour,
+	}}
+	// 2021-03-06 is a Saturday.
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2021, time.March, day, hour, minute, 0, 0, time.UTC)
+	}
+	testCases := []struct {
+		description    string
+		now            time.Time
+		expectedActive map[string][]string
+		expectedNext   time.Duration
+	}{
+		{
+			description:    "before any window on Friday",
+			now:            at(5, 12, 0),
+			expectedActive: map[string][]string{},
+			expectedNext:   11 * time.Hour,
+		},
+		{
+			description:    "bar's window spans midnight into Saturday",
+			now:            at(6, 0, 30),
+			expectedActive: map[string][]string{"bar": {"10.0.0.54"}},
+			expectedNext:   30 * time.Minute,
+		},
+		{
+			description:    "foo's Saturday window",
+			now:            at(6, 2, 0),
+			expectedActive: map[string][]string{"foo": {"10.0.0.53"}},
+			expectedNext:   4 * time.Hour,
+		},
+		{
+			description:    "foo's window does not recur on Sunday",
+			now:            at(7, 3, 0),
+			expectedActive: map[string][]string{},
+			expectedNext:   20 * time.Hour,
+		},
+		{
+			description:    "non-UTC time",
+			now:            at(6, 3, 0).In(time.FixedZone("EST", -5*60*60)),
+			expectedActive: map[string][]string{"foo": {"10.0.0.53"}},
+			expectedNext:   3 * time.Hour,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			if actual := activeMaintenanceUpstreams(windows, tc.now); !reflect.DeepEqual(actual, tc.expectedActive) {
+				t.Errorf("expected active upstreams %v, got %v", tc.expectedActive, actual)
+			}
+			if next, ok := nextMaintenanceTransition(windows, tc.now); !ok || next != tc.expectedNext {
+				t.Errorf("expected next transition in %v, got %v (%t)", tc.expectedNext, next, ok)
+			}
+		})
+	}
+	if _, ok := nextMaintenanceTransition(nil, at(6, 0, 0)); ok {
+		t.Errorf("expected no transition without windows")
+	}
+}
+
+// TestApplyMaintenanceUpstreams verifies that applyMaintenanceUpstreams
+// replaces the upstreams of servers with active windows without modifying the
+// given servers.
+func TestApplyMaintenanceUpstreams(t *testing.T) {
+	servers := []operatorv1.Server{{
+		Name:          "foo",
+		Zones:         []string{"foo.com"},
+		ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"1.1.1.1"}},
+	}, {
+		Name:          "bar",
+		Zones:         []string{"bar.com"},
+		ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"2.2.2.2"}},
+	}}
+	actual := applyMaintenanceUpstreams(servers, map[string][]string{"bar": {"10.0.0.54"}})
+	if e, a := []string{"1.1.1.1"}, actual[0].ForwardPlugin.Upstreams; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected upstreams %v for foo, got %v", e, a)
+	}
+	if e, a := []string{"10.0.0.54"}, actual[1].ForwardPlugin.Upstreams; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected upstreams %v for bar, got %v", e, a)
+	}
+	if e, a := []string{"2.2.2.2"}, servers[1].ForwardPlugin.Upstreams; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected original upstreams %v for bar, got %v", e, a)
+	}
+}
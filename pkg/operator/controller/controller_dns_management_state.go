@@ -0,0 +1,154 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	"github.com/sirupsen/logrus"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DNSOperandsRemovedConditionType is the type of the DNS status condition that
+// indicates that the DNS's management state is Removed and that the operator
+// has removed the DNS's operands, so the cluster does not serve DNS.  The
+// condition is reported only if the management state is Removed.
+const DNSOperandsRemovedConditionType = conditions.TypeOperandsRemoved
+
+// dnsManagementState returns the management state in the given dns's
+// ManagementStateAnnotation annotation.  The operator supports the Managed and
+// Removed states; any other value is ignored, and the default is Managed.
+func dnsManagementState(dns *operatorv1.DNS) operatorv1.ManagementState {
+	value, ok := dns.Annotations[ManagementStateAnnotation]
+	if !ok {
+		return operatorv1.Managed
+	}
+	switch state := operatorv1.ManagementState(value); state {
+	case operatorv1.Managed, operatorv1.Removed:
+		return state
+	}
+	logrus.Warningf("ignoring unsupported value %q for annotation %s on dns %s; supported values are %q and %q", value, ManagementStateAnnotation, dns.Name, operatorv1.Managed, operatorv1.Removed)
+	return operatorv1.Managed
+}
+
+// ensureDNSRemoved deletes the operands of the given dns, whose management
+// state is Removed, and updates its status to report that the cluster does not
+// serve DNS.  Setting the management state back to Managed recreates the
+// operands.
+func (r *reconciler) ensureDNSRemoved(dns *operatorv1.DNS) error {
+	// The dns owns its operands, but the garbage collector deletes them
+	// only when the dns itself is deleted, so delete each one.
+	for _, obj := range dnsOperands(dns) {
+		if err := r.client.Delete(context.TODO(), obj); err != nil {
+			if errors.IsNotFound(err) || meta.IsNoMatchError(err) {
+				continue
+			}
+			return fmt.Errorf("failed to delete %T %s/%s for dns %s: %w", obj, obj.GetNamespace(), obj.GetName(), dns.Name, err)
+		}
+		logrus.Infof("deleted %T %s/%s for dns %s", obj, obj.GetNamespace(), obj.GetName(), dns.Name)
+	}
+	probePods := &corev1.PodList{}
+	if err := r.client.List(context.TODO(), probePods, client.MatchingLabels{resolvConfProbeLabel: dns.Name}, client.InNamespace(DefaultOperandNamespace)); err != nil {
+		return fmt.Errorf("failed to list resolv.conf probe pods for dns %s: %w", dns.Name, err)
+	}
+	for i := range probePods.Items {
+		if err := r.deleteResolvConfProbePod(&probePods.Items[i]); err != nil {
+			return err
+		}
+	}
+	// The copies of secrets and configmaps in other namespaces are
+	// deleted like the operands.
+	if err := r.deleteUpstreamTLSCopies(dns, nil); err != nil {
+		return err
+	}
+	if err := r.ensureOpenshiftExternalNameServiceDeleted(); err != nil {
+		return err
+	}
+
+	updated := dns.DeepCopy()
+	updated.Status.Conditions = computeDNSAdditionalConditions(dns, computeDNSRemovedConditions())
+	if !dnsStatusesEqual(updated.Status, dns.Status) {
+		if err := r.client.Status().Update(context.TODO(), updated); err != nil {
+			return fmt.Errorf("failed to update dns status: %w", err)
+		}
+		logrus.Infof("updated DNS %s status: old: %#v, new: %#v", dns.Name, dns.Status, updated.Status)
+	}
+	return r.syncDNSObservedGeneration(dns)
+}
+
+// dnsOperands returns the objects, with only their names set, that the operator
+// creates for the given dns.  The node resolver's daemonset and configmap,
+// which the default dns owns, are included.
+func dnsOperands(dns *operatorv1.DNS) []client.Object {
+	var objects []client.Object
+	add := func(obj client.Object, name types.NamespacedName) {
+		obj.SetNamespace(name.Namespace)
+		obj.SetName(name.Name)
+		objects = append(objects, obj)
+	}
+	addUnstructured := func(gvk schema.GroupVersionKind, name types.NamespacedName) {
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(gvk)
+		add(obj, name)
+	}
+	add(&appsv1.DaemonSet{}, DNSDaemonSetName(dns))
+	add(&corev1.Service{}, DNSServiceName(dns))
+	add(&corev1.ConfigMap{}, DNSConfigMapName(dns))
+	add(&networkingv1.NetworkPolicy{}, DNSNetworkPolicyName(dns))
+	addUnstructured(serviceMonitorGVK, DNSServiceMonitorName(dns))
+	add(&corev1.ConfigMap{}, DNSTopologySnapshotConfigMapName(dns))
+	add(&corev1.ConfigMap{}, DNSFleetConfigurationConfigMapName(dns))
+	add(&corev1.ConfigMap{}, DNSBlocklistFeedsConfigMapName(dns))
+	add(&corev1.ConfigMap{}, DNSTrustedCABundleConfigMapName(dns))
+	add(&corev1.Pod{}, DNSImageVerificationPodName(dns))
+	add(&corev1.Pod{}, DNSPluginCheckPodName(dns))
+	add(&corev1.Pod{}, DNSQUICCheckPodName(dns))
+	addUnstructured(certificateGVK, DNSMetricsCertificateName(dns))
+	addUnstructured(tunedGVK, DNSTunedName(dns))
+	add(&appsv1.DaemonSet{}, NodeResolverDaemonSetName())
+	add(&corev1.ConfigMap{}, NodeResolverConfigMapName())
+	return objects
+}
+
+// computeDNSRemovedConditions returns the status conditions for a dns whose
+// management state is Removed.  As is usual for operators in the Removed
+// state, the dns reports that it is available and neither progressing nor
+// degraded so that it does not block cluster upgrades; the OperandsRemoved
+// condition warns that the cluster does not serve DNS.
+func computeDNSRemovedConditions() []operatorv1.OperatorCondition {
+	message := fmt.Sprintf("The %s annotation is %q, so the operator has removed the DNS's operands.", ManagementStateAnnotation, operatorv1.Removed)
+	return []operatorv1.OperatorCondition{{
+		Type:    operatorv1.OperatorStatusTypeDegraded,
+		Status:  operatorv1.ConditionFalse,
+		Reason:  conditions.ReasonRemoved,
+		Message: message,
+	}, {
+		Type:    operatorv1.OperatorStatusTypeProgressing,
+		Status:  operatorv1.ConditionFalse,
+		Reason:  conditions.ReasonRemoved,
+		Message: message,
+	}, {
+		Type:    operatorv1.OperatorStatusTypeAvailable,
+		Status:  operatorv1.ConditionTrue,
+		Reason:  conditions.ReasonRemoved,
+		Message: message,
+	}, {
+		Type:    DNSOperandsRemovedConditionType,
+		Status:  operatorv1.ConditionTrue,
+		Reason:  conditions.ReasonManagementStateRemoved,
+		Message: fmt.Sprintf("WARNING: the cluster DNS service and its pods are removed, so pods cannot resolve service names unless another resolver serves the DNS service's cluster IP.  Set the %s annotation to %q to restore them.", ManagementStateAnnotation, operatorv1.Managed),
+	}}
+}
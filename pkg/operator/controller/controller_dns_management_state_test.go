@@ -0,0 +1,269 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// TestDNSManagementState verifies that dnsManagementState honors the Managed
+// and Removed values of the management state annotation and otherwise returns
+// Managed.
+func TestDNSManagementState(t *testing.T) {
+	testCases := []struct {
+		description string
+		annotations map[string]string
+		expected    operatorv1.ManagementState
+	}{
+		{
+			description: "no annotation",
+			expected:    operatorv1.Managed,
+		},
+		{
+			description: "Managed",
+			annotations: map[string]string{ManagementStateAnnotation: "Managed"},
+			expected:    operatorv1.Managed,
+		},
+		{
+			description: "Removed",
+			annotations: map[string]string{ManagementStateAnnotation: "Removed"},
+			expected:    operatorv1.Removed,
+		},
+		{
+			description: "unsupported Unmanaged",
+			annotations: map[string]string{ManagementStateAnnotation: "Unmanaged"},
+			expected:    operatorv1.Managed,
+		},
+		{
+			description: "lowercase removed",
+			annotations: map[string]string{ManagementStateAnnotation: "removed"},
+			expected:    operatorv1.Managed,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			dns := &operatorv1.DNS{ObjectMeta: metav1.ObjectMeta{Annotations: tc.annotations}}
+			if actual := dnsManagementState(dns); actual != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, actual)
+			}
+		})
+	}
+}
+
+// fakeObjectClient is a client.Client that gets, creates, updates, and deletes
+// objects in, and lists pods, secrets, and configmaps in, a fixed set of
+// objects.  Status updates and patches have no effect.  Gets of the objects
+// with the keys in forbidden fail as an API server without RBAC access would.
+type fakeObjectClient struct {
+	client.Client
+	objects   map[string]client.Object
+	forbidden map[string]bool
+}
+
+// fakeObjectKey returns the key of the given object in a fakeObjectClient.
+func fakeObjectKey(obj client.Object) string {
+	return fakeKey(obj, client.ObjectKeyFromObject(obj))
+}
+
+// fakeKey returns the key in a fakeObjectClient of the object of the type of
+// the given object with the given namespace and name.
+func fakeKey(obj client.Object, key client.ObjectKey) string {
+	kind := fmt.Sprintf("%T", obj)
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		kind = u.GetKind()
+	}
+	return fmt.Sprintf("%s %s/%s", kind, key.Namespace, key.Name)
+}
+
+func (c *fakeObjectClient) Get(_ context.Context, key client.ObjectKey, obj client.Object) error {
+	if c.forbidden[fakeKey(obj, key)] {
+		return errors.NewForbidden(schema.GroupResource{}, key.Name, fmt.Errorf("access denied"))
+	}
+	stored, ok := c.objects[fakeKey(obj, key)]
+	if !ok {
+		return errors.NewNotFound(schema.GroupResource{}, key.Name)
+	}
+	reflect.ValueOf(obj).Elem().Set(reflect.ValueOf(stored.DeepCopyObject()).Elem())
+	return nil
+}
+
+func (c *fakeObjectClient) Create(_ context.Context, obj client.Object, _ ...client.CreateOption) error {
+	key := fakeObjectKey(obj)
+	if _, ok := c.objects[key]; ok {
+		return errors.NewAlreadyExists(schema.GroupResource{}, obj.GetName())
+	}
+	c.objects[key] = obj.DeepCopyObject().(client.Object)
+	return nil
+}
+
+func (c *fakeObjectClient) Update(_ context.Context, obj client.Object, _ ...client.UpdateOption) error {
+	key := fakeObjectKey(obj)
+	if _, ok := c.objects[key]; !ok {
+		return errors.NewNotFound(schema.GroupResource{}, obj.GetName())
+	}
+	c.objects[key] = obj.DeepCopyObject().(client.Object)
+	return nil
+}
+
+func (c *fakeObjectClient) Delete(_ context.Context, obj client.Object, _ ...client.DeleteOption) error {
+	key := fakeObjectKey(obj)
+	if _, ok := c.objects[key]; !ok {
+		return errors.NewNotFound(schema.GroupResource{}, obj.GetName())
+	}
+	delete(c.objects, key)
+	return nil
+}
+
+func (c *fakeObjectClient) List(_ context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	listOpts := &client.ListOptions{}
+	listOpts.ApplyOptions(opts)
+	for _, obj := range c.objects {
+		if len(listOpts.Namespace) != 0 && obj.GetNamespace() != listOpts.Namespace {
+			continue
+		}
+		if listOpts.LabelSelector != nil && !listOpts.LabelSelector.Matches(labels.Set(obj.GetLabels())) {
+			continue
+		}
+		switch l := list.(type) {
+		case *corev1.PodList:
+			if pod, ok := obj.(*corev1.Pod); ok {
+				l.Items = append(l.Items, *pod)
+			}
+		case *corev1.SecretList:
+			if secret, ok := obj.(*corev1.Secret); ok {
+				l.Items = append(l.Items, *secret)
+			}
+		case *corev1.ConfigMapList:
+			if cm, ok := obj.(*corev1.ConfigMap); ok {
+				l.Items = append(l.Items, *cm)
+			}
+		}
+	}
+	return nil
+}
+
+func (c *fakeObjectClient) Patch(context.Context, client.Object, client.Patch, ...client.PatchOption) error {
+	return nil
+}
+
+func (c *fakeObjectClient) Status() client.StatusWriter {
+	return fakeStatusWriter{}
+}
+
+// fakeStatusWriter is a client.StatusWriter whose updates have no effect.
+type fakeStatusWriter struct {
+	client.StatusWriter
+}
+
+func (fakeStatusWriter) Update(context.Context, client.Object, ...client.UpdateOption) error {
+	return nil
+}
+
+// TestEnsureDNSRemoved verifies that ensureDNSRemoved deletes every object that
+// the operator creates for a dns, even though the garbage collector does not
+// delete the objects while the dns exists, and leaves other objects alone.
+func TestEnsureDNSRemoved(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DefaultDNSController,
+			UID:  "1",
+			Annotations: map[string]string{
+				ManagementStateAnnotation: string(operatorv1.Removed),
+			},
+		},
+	}
+	daemonset, err := desiredDNSDaemonSet(dns, "coredns", "kube-rbac-proxy", "", DNSMetricsSecretName(dns), "", nil, nil, nil, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	service, err := desiredDNSService(dns, "172.30.0.10", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	configMap, err := desiredDNSConfigMap(dns, corefileInputs{ClusterDomain: "cluster.local", MetricsAddress: "127.0.0.1:9153"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := desiredDNSTopologySnapshotConfigMap(dns, DNSTopologySnapshot{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, nodeResolverDaemonSet, err := desiredNodeResolverDaemonSet(dns, "172.30.0.10", "cluster.local", "cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	probePod := desiredResolvConfProbePod(dns, "", "node-1", "cli")
+	probePod.Name = probePod.GenerateName + "abcde"
+	ownedConfigMap := func(name func(*operatorv1.DNS) types.NamespacedName) *corev1.ConfigMap {
+		return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+			Namespace:       name(dns).Namespace,
+			Name:            name(dns).Name,
+			OwnerReferences: []metav1.OwnerReference{dnsOwnerRef(dns)},
+		}}
+	}
+	daemonsetRef := metav1.OwnerReference{APIVersion: "apps/v1", Kind: "DaemonSet", Name: daemonset.Name}
+	owned := []client.Object{
+		daemonset,
+		service,
+		configMap,
+		desiredDNSNetworkPolicy(dns, daemonset, "openshift-dns-operator"),
+		desiredServiceMonitor(dns, service, daemonsetRef, "", ""),
+		snapshot,
+		ownedConfigMap(DNSFleetConfigurationConfigMapName),
+		ownedConfigMap(DNSBlocklistFeedsConfigMapName),
+		ownedConfigMap(DNSTrustedCABundleConfigMapName),
+		desiredImageVerificationPod(dns, "coredns", "kube-rbac-proxy"),
+		desiredPluginCheckPod(dns, "coredns"),
+		desiredQUICCheckPod(dns, "coredns"),
+		desiredDNSMetricsCertificate(dns, "ClusterIssuer", "corp-ca"),
+		desiredDNSTuned(dns),
+		nodeResolverDaemonSet,
+		desiredNodeResolverConfigMap(dns, "cluster.local", nil),
+		probePod,
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+			Namespace: DefaultOperandNamespace,
+			Name:      "upstream-tls.team-a.corp-client",
+			Labels:    map[string]string{upstreamTLSCopyLabel: dns.Name},
+		}},
+	}
+	// The cluster administrator's configmaps and other pods must survive.
+	hosts := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: DefaultOperandNamespace, Name: DNSStaticHostsConfigMapName(dns).Name}}
+	otherPod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: DefaultOperandNamespace, Name: "other"}}
+	otherDaemonSet := &appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Namespace: DefaultOperandNamespace, Name: "dns-other"}}
+
+	c := &fakeObjectClient{objects: map[string]client.Object{}}
+	for _, obj := range append(owned, hosts, otherPod, otherDaemonSet) {
+		c.objects[fakeObjectKey(obj)] = obj
+	}
+	r := &reconciler{client: c}
+	if err := r.ensureDNSRemoved(dns); err != nil {
+		t.Fatal(err)
+	}
+
+	var remaining []string
+	for key := range c.objects {
+		remaining = append(remaining, key)
+	}
+	sort.Strings(remaining)
+	expected := []string{fakeObjectKey(otherDaemonSet), fakeObjectKey(hosts), fakeObjectKey(otherPod)}
+	sort.Strings(expected)
+	if !reflect.DeepEqual(remaining, expected) {
+		t.Errorf("expected only %q to remain, got %q", expected, remaining)
+	}
+}
@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// DNSMetricsServingCertificateConditionType is the type of the DNS
+	// status condition that indicates whether the custom metrics serving
+	// certificate that the DNS references is usable.  The condition is
+	// only reported when the DNS references a custom certificate.
+	DNSMetricsServingCertificateConditionType = conditions.TypeMetricsServingCertificate
+
+	// metricsCASecretKey is the key in a custom metrics serving certificate
+	// secret that holds the CA certificate that prometheus uses to verify
+	// the metrics endpoint.
+	metricsCASecretKey = "ca.crt"
+)
+
+// customMetricsSecretName returns the name of the custom metrics serving
+// certificate secret that the given dns references, or that cert-manager
+// stores the certificate that the dns requests in, or the empty string if the
+// dns uses the certificate that the service CA operator generates.
+func customMetricsSecretName(dns *operatorv1.DNS) string {
+	if name := strings.TrimSpace(dns.Annotations[MetricsServingCertSecretAnnotation]); len(name) != 0 {
+		return name
+	}
+	if _, _, ok := metricsCertificateIssuer(dns); ok {
+		return DNSMetricsCertificateName(dns).Name
+	}
+	return ""
+}
+
+// metricsServingCertSecret returns the name of the secret that the dns pods
+// should use for the metrics serving certificate, a Boolean value indicating
+// whether that secret is a custom secret, and, if the dns references a custom
+// secret, a status condition describing whether the secret is usable.  If the
+// custom secret is not usable, the secret that the service CA operator
+// generates is used instead so that metrics remain available.
+func (r *reconciler) metricsServingCertSecret(dns *operatorv1.DNS) (string, bool, *operatorv1.OperatorCondition, error) {
+	name := customMetricsSecretName(dns)
+	if len(name) == 0 {
+		return DNSMetricsSecretName(dns), false, nil, nil
+	}
+	condition := &operatorv1.OperatorCondition{
+		Type: DNSMetricsServingCertificateConditionType,
+	}
+	secret := &corev1.Secret{}
+	secretName := types.NamespacedName{Namespace: DefaultOperandNamespace, Name: name}
+	if err := r.client.Get(context.TODO(), secretName, secret); err != nil {
+		if !errors.IsNotFound(err) {
+			return DNSMetricsSecretName(dns), false, nil, fmt.Errorf("failed to get metrics serving certificate secret %s: %w", secretName, err)
+		}
+		condition.Status = operatorv1.ConditionFalse
+		condition.Reason = conditions.ReasonSecretNotFound
+		condition.Message = fmt.Sprintf("The metrics serving certificate secret %s does not exist; using the service CA generated certificate.", secretName)
+		return DNSMetricsSecretName(dns), false, condition, nil
+	}
+	if err := validateMetricsServingCertSecret(secret); err != nil {
+		condition.Status = operatorv1.ConditionFalse
+		condition.Reason = conditions.ReasonInvalidSecret
+		condition.Message = fmt.Sprintf("The metrics serving certificate secret %s is invalid: %v; using the service CA generated certificate.", secretName, err)
+		return DNSMetricsSecretName(dns), false, condition, nil
+	}
+	condition.Status = operatorv1.ConditionTrue
+	condition.Reason = conditions.ReasonAsExpected
+	condition.Message = fmt.Sprintf("Using the metrics serving certificate from secret %s.", secretName)
+	return name, true, condition, nil
+}
+
+// validateMetricsServingCertSecret returns an error if the given secret does
+// not have the serving certificate and key that kube-rbac-proxy needs and the
+// CA certificate that prometheus needs.
+func validateMetricsServingCertSecret(secret *corev1.Secret) error {
+	var missing []string
+	for _, key := range []string{corev1.TLSCertKey, corev1.TLSPrivateKeyKey, metricsCASecretKey} {
+		if len(secret.Data[key]) == 0 {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) != 0 {
+		return fmt.Errorf("missing or empty keys: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
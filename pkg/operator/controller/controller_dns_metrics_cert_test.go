@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// TestValidateMetricsServingCertSecret verifies that
+// validateMetricsServingCertSecret requires the certificate, key, and CA.
+func TestValidateMetricsServingCertSecret(t *testing.T) {
+	testCases := []struct {
+		description string
+		data        map[string][]byte
+		expectError bool
+	}{
+		{
+			description: "empty secret",
+			expectError: true,
+		},
+		{
+			description: "missing CA certificate",
+			data: map[string][]byte{
+				"tls.crt": []byte("cert"),
+				"tls.key": []byte("key"),
+			},
+			expectError: true,
+		},
+		{
+			description: "empty key",
+			data: map[string][]byte{
+				"tls.crt": []byte("cert"),
+				"tls.key": {},
+				"ca.crt":  []byte("ca"),
+			},
+			expectError: true,
+		},
+		{
+			description: "complete secret",
+			data: map[string][]byte{
+				"tls.crt": []byte("cert"),
+				"tls.key": []byte("key"),
+				"ca.crt":  []byte("ca"),
+			},
+		},
+	}
+	for _, tc := range testCases {
+		secret := &corev1.Secret{Data: tc.data}
+		err := validateMetricsServingCertSecret(secret)
+		if tc.expectError && err == nil {
+			t.Errorf("%s: expected an error", tc.description)
+		} else if !tc.expectError && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.description, err)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"strings"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// metricsExclusion is the set of server blocks whose queries CoreDNS does not
+// count in its metrics.
+type metricsExclusion struct {
+	// servers is the names of the excluded servers in the dns's
+	// spec.servers.
+	servers sets.String
+	// zones is the excluded zones of the dns's zone files and secondary
+	// zones, without trailing dots.
+	zones sets.String
+}
+
+// metricsExcluded returns the server blocks in the given dns's
+// MetricsExcludedZonesAnnotation annotation.  Each entry is the name of a
+// server in the dns's spec.servers or a zone, with or without a trailing dot,
+// of the dns's zone files or secondary zones; an entry that names neither has
+// no effect.
+func metricsExcluded(dns *operatorv1.DNS) metricsExclusion {
+	exclusion := metricsExclusion{servers: sets.NewString(), zones: sets.NewString()}
+	list := dns.Annotations[MetricsExcludedZonesAnnotation]
+	for _, entry := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' }) {
+		exclusion.servers.Insert(entry)
+		exclusion.zones.Insert(strings.TrimSuffix(normalizeZone(entry), "."))
+	}
+	return exclusion
+}
+
+// Server returns a Boolean value indicating whether the server block of the
+// server with the given name is excluded from metrics.
+func (e metricsExclusion) Server(name string) bool {
+	return e.servers.Has(name)
+}
+
+// Zone returns a Boolean value indicating whether the server block of the zone
+// file or secondary zone with the given zone is excluded from metrics.
+func (e metricsExclusion) Zone(zone string) bool {
+	return e.zones.Has(zone)
+}
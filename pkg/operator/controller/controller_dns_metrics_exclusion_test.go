@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestDesiredDNSConfigMapMetricsExclusion verifies that the server blocks of
+// the servers and zones in the MetricsExcludedZonesAnnotation annotation, and
+// only those, omit the prometheus plugin.
+func TestDesiredDNSConfigMapMetricsExclusion(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        DefaultDNSController,
+			Annotations: map[string]string{MetricsExcludedZonesAnnotation: "hr, Payroll.Example.com., missing"},
+		},
+	}
+	servers := []operatorv1.Server{
+		{Name: "corp", Zones: []string{"corp.example.com"}, ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"10.0.0.53"}}},
+		{Name: "hr", Zones: []string{"hr.example.com"}, ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"10.0.1.53"}}},
+	}
+	zoneFiles := []zoneFile{{zoneFileReference: zoneFileReference{Zone: "payroll.example.com", ConfigMap: "payroll"}, Data: "@ 3600 IN SOA ns hostmaster 1 7200 3600 1209600 3600\n"}}
+	secondaryZones := []secondaryZone{{Zone: "lab.example.com", Primaries: []string{"192.0.2.53:53"}}}
+
+	cm, err := desiredDNSConfigMap(dns, corefileInputs{Servers: servers, ZoneFiles: zoneFiles, SecondaryZones: secondaryZones, ClusterDomain: "cluster.local", MetricsAddress: "127.0.0.1:9153"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	corefile := cm.Data["Corefile"]
+	blocks := map[string]string{}
+	for _, block := range strings.Split(corefile, "\n}\n") {
+		lines := strings.SplitN(strings.TrimSpace(block), "\n", 3)
+		if len(lines) < 2 {
+			continue
+		}
+		blocks[lines[1]] = block
+	}
+	testCases := []struct {
+		header   string
+		excluded bool
+	}{
+		{"corp.example.com:5353 {", false},
+		{"hr.example.com:5353 {", true},
+		{"payroll.example.com:5353 {", true},
+		{"lab.example.com:5353 {", false},
+	}
+	for _, tc := range testCases {
+		block, ok := blocks[tc.header]
+		if !ok {
+			t.Errorf("expected a server block %q, got Corefile:\n%s", tc.header, corefile)
+			continue
+		}
+		if hasMetrics := strings.Contains(block, "prometheus 127.0.0.1:9153"); hasMetrics == tc.excluded {
+			t.Errorf("%q: expected prometheus plugin %t, got server block:\n%s", tc.header, !tc.excluded, block)
+		}
+	}
+	if !strings.Contains(corefile, "    kubernetes cluster.local in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n    }\n    prometheus 127.0.0.1:9153\n") {
+		t.Errorf("expected the default server block to keep the prometheus plugin, got Corefile:\n%s", corefile)
+	}
+}
@@ -0,0 +1,202 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DNSDeprecatedFieldsMigratedConditionType is the type of the DNS
+	// status condition that reports the deprecated configuration shapes
+	// that the operator has migrated to their current forms and those that
+	// it could not migrate.  The condition is reported only if the dns has
+	// used deprecated shapes.  The dns ClusterOperator is not Upgradeable
+	// while the condition is False.
+	DNSDeprecatedFieldsMigratedConditionType = conditions.TypeDeprecatedFieldsMigrated
+
+	// deprecatedUpstreamScheme is the scheme of plain DNS upstreams in a
+	// Corefile, which the forward plugin's upstreams do not take.
+	deprecatedUpstreamScheme = "dns://"
+
+	// maxMigrationRecords is the number of migrations that the
+	// MigratedFieldsAnnotation annotation records.  Older migrations are
+	// dropped.
+	maxMigrationRecords = 20
+)
+
+// fieldMigration migrates a deprecated shape of a dns's configuration in place
+// and returns descriptions of the migrated fields and of the deprecated fields
+// that it cannot migrate.
+type fieldMigration func(dns *operatorv1.DNS) (migrated, remaining []string)
+
+// fieldMigrations are the migrations that the operator applies to every dns,
+// in order.
+var fieldMigrations = []fieldMigration{
+	migrateCorefileStyleUpstreams,
+}
+
+// migrateDeprecatedFields returns a copy of the given dns with its deprecated
+// configuration shapes migrated to their current forms, along with
+// descriptions of the migrated fields and of the deprecated fields that could
+// not be migrated.
+func migrateDeprecatedFields(dns *operatorv1.DNS) (*operatorv1.DNS, []string, []string) {
+	updated := dns.DeepCopy()
+	var migrated, remaining []string
+	for _, migrate := range fieldMigrations {
+		m, r := migrate(updated)
+		migrated = append(migrated, m...)
+		remaining = append(remaining, r...)
+	}
+	return updated, migrated, remaining
+}
+
+// migrateCorefileStyleUpstreams rewrites the upstreams of the given dns's
+// servers that are written as in a Corefile's forward plugin, with the dns
+// scheme or with several addresses in one entry, as one entry per address
+// without the scheme.  An address that duplicates another upstream of the same
+// server is pruned.  An entry with an address that is not valid is left as is,
+// so validUpstreamServers omits it.
+func migrateCorefileStyleUpstreams(dns *operatorv1.DNS) ([]string, []string) {
+	var migrated, remaining []string
+	for i := range dns.Spec.Servers {
+		server := &dns.Spec.Servers[i]
+		var upstreams []string
+		seen := map[string]bool{}
+		for _, upstream := range server.ForwardPlugin.Upstreams {
+			if key, ok := normalizeUpstream(upstream); ok {
+				seen[key] = true
+			}
+		}
+		for _, upstream := range server.ForwardPlugin.Upstreams {
+			fields := strings.FieldsFunc(upstream, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' })
+			if len(fields) == 0 || len(fields) == 1 && !hasDeprecatedUpstreamScheme(fields[0]) {
+				upstreams = append(upstreams, upstream)
+				continue
+			}
+			var addresses []string
+			invalid := ""
+			for _, field := range fields {
+				if hasDeprecatedUpstreamScheme(field) {
+					field = field[len(deprecatedUpstreamScheme):]
+				}
+				if _, ok := normalizeUpstream(field); !ok || isDoHUpstream(field) || isDoQUpstream(field) || isDoTUpstream(field) {
+					invalid = field
+					break
+				}
+				addresses = append(addresses, field)
+			}
+			if len(invalid) != 0 {
+				remaining = append(remaining, fmt.Sprintf("server %q upstream %q cannot be migrated because %q is not an IP address or IP address and port", server.Name, upstream, invalid))
+				upstreams = append(upstreams, upstream)
+				continue
+			}
+			var rewritten, pruned []string
+			for _, address := range addresses {
+				key, _ := normalizeUpstream(address)
+				if seen[key] {
+					pruned = append(pruned, address)
+					continue
+				}
+				seen[key] = true
+				rewritten = append(rewritten, address)
+			}
+			description := fmt.Sprintf("server %q upstream %q was rewritten as %q", server.Name, upstream, rewritten)
+			if len(pruned) != 0 {
+				description += fmt.Sprintf(" without the duplicate upstreams %q", pruned)
+			}
+			migrated = append(migrated, description)
+			upstreams = append(upstreams, rewritten...)
+		}
+		server.ForwardPlugin.Upstreams = upstreams
+	}
+	return migrated, remaining
+}
+
+// hasDeprecatedUpstreamScheme returns a Boolean value indicating whether the
+// given upstream has the dns scheme, in any case.
+func hasDeprecatedUpstreamScheme(upstream string) bool {
+	return len(upstream) > len(deprecatedUpstreamScheme) && strings.EqualFold(upstream[:len(deprecatedUpstreamScheme)], deprecatedUpstreamScheme)
+}
+
+// migrationRecords returns the migrations in the given dns's
+// MigratedFieldsAnnotation annotation.  An invalid annotation is ignored.
+func migrationRecords(dns *operatorv1.DNS) []string {
+	value, ok := dns.Annotations[MigratedFieldsAnnotation]
+	if !ok {
+		return nil
+	}
+	var records []string
+	if err := json.Unmarshal([]byte(value), &records); err != nil {
+		logrus.Warningf("ignoring invalid %s annotation on dns %s: %v", MigratedFieldsAnnotation, dns.Name, err)
+		return nil
+	}
+	return records
+}
+
+// ensureDeprecatedFieldsMigrated migrates the deprecated configuration shapes
+// of the given dns and returns the dns with its configuration migrated and a
+// status condition that reports the migrations, or nil if the dns has never
+// used deprecated shapes.  The operator updates a dns that is not
+// fleet-managed with the migrated configuration and records the migrations in
+// its MigratedFieldsAnnotation annotation; the migrated configuration of a
+// fleet-managed dns is applied but not written, so the fleet manager's
+// configuration still uses deprecated shapes.
+func (r *reconciler) ensureDeprecatedFieldsMigrated(dns *operatorv1.DNS) (*operatorv1.DNS, *operatorv1.OperatorCondition, error) {
+	updated, migrated, remaining := migrateDeprecatedFields(dns)
+	if len(migrated) != 0 {
+		if manager := fleetManager(dns); len(manager) != 0 {
+			for _, m := range migrated {
+				remaining = append(remaining, fmt.Sprintf("%s, but %s must update its configuration", m, manager))
+			}
+		} else {
+			records := append(migrationRecords(dns), migrated...)
+			if len(records) > maxMigrationRecords {
+				records = records[len(records)-maxMigrationRecords:]
+			}
+			value, err := json.Marshal(records)
+			if err != nil {
+				return dns, nil, fmt.Errorf("failed to record migrations for dns %s: %w", dns.Name, err)
+			}
+			if updated.Annotations == nil {
+				updated.Annotations = map[string]string{}
+			}
+			updated.Annotations[MigratedFieldsAnnotation] = string(value)
+			if err := r.client.Update(context.TODO(), updated); err != nil {
+				return dns, nil, fmt.Errorf("failed to update dns %s with migrated fields: %w", dns.Name, err)
+			}
+			for _, m := range migrated {
+				logrus.Infof("migrated deprecated field of dns %s: %s", dns.Name, m)
+			}
+		}
+	}
+	return updated, computeDeprecatedFieldsMigratedCondition(migrationRecords(updated), remaining), nil
+}
+
+// computeDeprecatedFieldsMigratedCondition returns a status condition that
+// reports the given recorded migrations and the given deprecated fields that
+// remain, or nil if there are neither.
+func computeDeprecatedFieldsMigratedCondition(records, remaining []string) *operatorv1.OperatorCondition {
+	condition := &operatorv1.OperatorCondition{
+		Type: DNSDeprecatedFieldsMigratedConditionType,
+	}
+	switch {
+	case len(remaining) != 0:
+		condition.Status = operatorv1.ConditionFalse
+		condition.Reason = conditions.ReasonDeprecatedFieldsInUse
+		condition.Message = fmt.Sprintf("Some deprecated fields must be updated manually before the operator is upgraded: %s.", strings.Join(remaining, "; "))
+	case len(records) != 0:
+		condition.Status = operatorv1.ConditionTrue
+		condition.Reason = conditions.ReasonDeprecatedFieldsMigrated
+		condition.Message = fmt.Sprintf("All deprecated fields were migrated: %s.", strings.Join(records, "; "))
+	default:
+		return nil
+	}
+	return condition
+}
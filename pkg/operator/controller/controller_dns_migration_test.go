@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestMigrateDeprecatedFields verifies that upstreams written as in a Corefile
+// are rewritten as one address per entry, that duplicates are pruned, and that
+// entries that cannot be migrated are kept and reported.
+func TestMigrateDeprecatedFields(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{Name: DefaultDNSController},
+		Spec: operatorv1.DNSSpec{
+			Servers: []operatorv1.Server{
+				{Name: "corp", ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"DNS://10.0.0.53", "10.0.0.54:53"}}},
+				{Name: "lab", ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"10.1.0.53 dns://10.1.0.54:5353, 10.1.0.53:53", "10.1.0.55"}}},
+				{Name: "bad", ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"dns://dns.example.com", "tls://10.2.0.53"}}},
+			},
+		},
+	}
+	migrated, migrations, remaining := migrateDeprecatedFields(dns)
+
+	expected := [][]string{
+		{"10.0.0.53", "10.0.0.54:53"},
+		{"10.1.0.53", "10.1.0.54:5353", "10.1.0.55"},
+		{"dns://dns.example.com", "tls://10.2.0.53"},
+	}
+	for i, server := range migrated.Spec.Servers {
+		if !reflect.DeepEqual(server.ForwardPlugin.Upstreams, expected[i]) {
+			t.Errorf("server %q: expected upstreams %q, got %q", server.Name, expected[i], server.ForwardPlugin.Upstreams)
+		}
+	}
+	if dns.Spec.Servers[0].ForwardPlugin.Upstreams[0] != "DNS://10.0.0.53" {
+		t.Errorf("expected the dns not to be modified, got %q", dns.Spec.Servers[0].ForwardPlugin.Upstreams)
+	}
+	expectedMigrations := []string{
+		`server "corp" upstream "DNS://10.0.0.53" was rewritten as ["10.0.0.53"]`,
+		`server "lab" upstream "10.1.0.53 dns://10.1.0.54:5353, 10.1.0.53:53" was rewritten as ["10.1.0.53" "10.1.0.54:5353"] without the duplicate upstreams ["10.1.0.53:53"]`,
+	}
+	if !reflect.DeepEqual(migrations, expectedMigrations) {
+		t.Errorf("expected migrations %q, got %q", expectedMigrations, migrations)
+	}
+	if len(remaining) != 1 || !strings.Contains(remaining[0], `"dns.example.com" is not an IP address`) {
+		t.Errorf("expected the bad server's dns upstream to remain, got %q", remaining)
+	}
+
+	if _, migrations, remaining := migrateDeprecatedFields(migrated); len(migrations) != 0 || len(remaining) != 1 {
+		t.Errorf("expected migration to be idempotent apart from the remaining field, got migrations %q and remaining %q", migrations, remaining)
+	}
+}
+
+// TestComputeDeprecatedFieldsMigratedCondition verifies that the condition is
+// False while deprecated fields remain and is omitted if the dns has never
+// used deprecated fields.
+func TestComputeDeprecatedFieldsMigratedCondition(t *testing.T) {
+	if condition := computeDeprecatedFieldsMigratedCondition(nil, nil); condition != nil {
+		t.Errorf("expected no condition, got %#v", condition)
+	}
+	if condition := computeDeprecatedFieldsMigratedCondition([]string{"migrated"}, nil); condition == nil || condition.Status != operatorv1.ConditionTrue {
+		t.Errorf("expected a True condition, got %#v", condition)
+	}
+	if condition := computeDeprecatedFieldsMigratedCondition([]string{"migrated"}, []string{"remaining"}); condition == nil || condition.Status != operatorv1.ConditionFalse {
+		t.Errorf("expected a False condition, got %#v", condition)
+	}
+}
@@ -0,0 +1,176 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/manifests"
+
+	"github.com/sirupsen/logrus"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	// namespaceNameLabel is the label that the API server sets on every
+	// namespace to the namespace's name.
+	namespaceNameLabel = "kubernetes.io/metadata.name"
+	// operatorPodLabel is the label that selects the operator's pods.
+	operatorPodLabel = "name"
+	// operatorPodLabelValue is the value of operatorPodLabel on the
+	// operator's pods.
+	operatorPodLabelValue = "dns-operator"
+)
+
+// ensureDNSNetworkPolicy ensures that the network policy that allows only the
+// operator to reach CoreDNS's metrics port on the given dns's pods exists and
+// allows traffic from anywhere to the other ports of the given daemonset's
+// pods.  CoreDNS serves metrics without authentication, so when the operator's
+// metrics proxy scrapes the pods, the pods must serve metrics on the pod
+// network, and the network policy stops other clients from bypassing the
+// proxy's authentication.
+func (r *reconciler) ensureDNSNetworkPolicy(dns *operatorv1.DNS, daemonset *appsv1.DaemonSet) error {
+	desired := desiredDNSNetworkPolicy(dns, daemonset, r.OperatorNamespace)
+	name := DNSNetworkPolicyName(dns)
+	current := &networkingv1.NetworkPolicy{}
+	if err := r.client.Get(context.TODO(), name, current); err != nil {
+		if !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to get network policy %s: %w", name, err)
+		}
+		if err := r.client.Create(context.TODO(), desired); err != nil {
+			return fmt.Errorf("failed to create network policy %s: %w", name, err)
+		}
+		logrus.Infof("created network policy %s", name)
+		return nil
+	}
+	if reflect.DeepEqual(current.Spec, desired.Spec) {
+		return nil
+	}
+	updated := current.DeepCopy()
+	updated.Spec = desired.Spec
+	if err := r.client.Update(context.TODO(), updated); err != nil {
+		return fmt.Errorf("failed to update network policy %s: %w", name, err)
+	}
+	logrus.Infof("updated network policy %s", name)
+	return nil
+}
+
+// ensureDNSNetworkPolicyDeleted deletes the network policy of the given dns,
+// whose pods serve metrics only on the loopback interface, if it exists.
+func (r *reconciler) ensureDNSNetworkPolicyDeleted(dns *operatorv1.DNS) error {
+	name := DNSNetworkPolicyName(dns)
+	policy := &networkingv1.NetworkPolicy{}
+	policy.Name = name.Name
+	policy.Namespace = name.Namespace
+	if err := r.client.Delete(context.TODO(), policy); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to delete network policy %s: %w", name, err)
+	}
+	logrus.Infof("deleted network policy %s", name)
+	return nil
+}
+
+// desiredDNSNetworkPolicy returns the desired network policy for the given
+// dns.  The policy selects the dns's pods and allows ingress from anywhere to
+// the ports that the given daemonset's containers expose or probe, except for
+// CoreDNS's metrics port, and ingress to the metrics port only from the
+// operator's pods in the given namespace.
+func desiredDNSNetworkPolicy(dns *operatorv1.DNS, daemonset *appsv1.DaemonSet, operatorNamespace string) *networkingv1.NetworkPolicy {
+	name := DNSNetworkPolicyName(dns)
+	policy := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name.Name,
+			Namespace: name.Namespace,
+			Labels: map[string]string{
+				manifests.OwningDNSLabel: DNSDaemonSetLabel(dns),
+			},
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: *DNSDaemonSetPodSelector(dns),
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{
+				{Ports: dnsNetworkPolicyPorts(daemonset)},
+				{
+					Ports: []networkingv1.NetworkPolicyPort{networkPolicyPort(corev1.ProtocolTCP, CoreDNSMetricsPort)},
+					From: []networkingv1.NetworkPolicyPeer{{
+						NamespaceSelector: &metav1.LabelSelector{
+							MatchLabels: map[string]string{namespaceNameLabel: operatorNamespace},
+						},
+						PodSelector: &metav1.LabelSelector{
+							MatchLabels: map[string]string{operatorPodLabel: operatorPodLabelValue},
+						},
+					}},
+				},
+			},
+		},
+	}
+	policy.SetOwnerReferences([]metav1.OwnerReference{dnsOwnerRef(dns)})
+	return policy
+}
+
+// dnsNetworkPolicyPorts returns the ports, in order, that the given
+// daemonset's containers expose or that their probes use, other than
+// CoreDNS's metrics port.  Probes with named ports use exposed ports, so only
+// probes with numbered ports are considered.
+func dnsNetworkPolicyPorts(daemonset *appsv1.DaemonSet) []networkingv1.NetworkPolicyPort {
+	type key struct {
+		protocol corev1.Protocol
+		port     int32
+	}
+	seen := map[key]bool{}
+	add := func(protocol corev1.Protocol, port int32) {
+		if len(protocol) == 0 {
+			protocol = corev1.ProtocolTCP
+		}
+		if port != 0 && !(protocol == corev1.ProtocolTCP && port == CoreDNSMetricsPort) {
+			seen[key{protocol, port}] = true
+		}
+	}
+	probePort := func(probe *corev1.Probe) {
+		switch {
+		case probe == nil:
+		case probe.HTTPGet != nil && probe.HTTPGet.Port.Type == intstr.Int:
+			add(corev1.ProtocolTCP, probe.HTTPGet.Port.IntVal)
+		case probe.TCPSocket != nil && probe.TCPSocket.Port.Type == intstr.Int:
+			add(corev1.ProtocolTCP, probe.TCPSocket.Port.IntVal)
+		}
+	}
+	for _, c := range daemonset.Spec.Template.Spec.Containers {
+		for _, p := range c.Ports {
+			add(p.Protocol, p.ContainerPort)
+		}
+		probePort(c.LivenessProbe)
+		probePort(c.ReadinessProbe)
+		probePort(c.StartupProbe)
+	}
+	keys := make([]key, 0, len(seen))
+	for k := range seen {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].port < keys[j].port || (keys[i].port == keys[j].port && keys[i].protocol < keys[j].protocol)
+	})
+	ports := make([]networkingv1.NetworkPolicyPort, 0, len(keys))
+	for _, k := range keys {
+		ports = append(ports, networkPolicyPort(k.protocol, k.port))
+	}
+	return ports
+}
+
+// networkPolicyPort returns a network policy port for the given protocol and
+// port number.
+func networkPolicyPort(protocol corev1.Protocol, port int32) networkingv1.NetworkPolicyPort {
+	p := intstr.FromInt(int(port))
+	return networkingv1.NetworkPolicyPort{Protocol: &protocol, Port: &p}
+}
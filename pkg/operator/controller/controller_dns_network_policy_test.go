@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestDesiredDNSNetworkPolicy verifies that the network policy for a dns whose
+// metrics the operator's metrics proxy scrapes allows ingress from anywhere to
+// the dns pods' DNS and health ports and only from the operator to the
+// CoreDNS metrics port.
+func TestDesiredDNSNetworkPolicy(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DefaultDNSController,
+		},
+	}
+	// Without a kube-rbac-proxy image, the daemonset exposes CoreDNS's
+	// metrics port.
+	ds, err := desiredDNSDaemonSet(dns, "coredns", "", "", DNSMetricsSecretName(dns), "", nil, nil, nil, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := desiredDNSNetworkPolicy(dns, ds, "openshift-dns-operator")
+	if len(policy.Spec.Ingress) != 2 {
+		t.Fatalf("expected 2 ingress rules, got %#v", policy.Spec.Ingress)
+	}
+
+	var ports []string
+	for _, p := range policy.Spec.Ingress[0].Ports {
+		ports = append(ports, string(*p.Protocol)+"/"+p.Port.String())
+	}
+	if expected := []string{"TCP/5353", "UDP/5353", "TCP/8080", "TCP/8181"}; !reflect.DeepEqual(ports, expected) {
+		t.Errorf("expected ports %q open to everyone, got %q", expected, ports)
+	}
+	if len(policy.Spec.Ingress[0].From) != 0 {
+		t.Errorf("expected the DNS ports to be open to everyone, got %#v", policy.Spec.Ingress[0].From)
+	}
+
+	metrics := policy.Spec.Ingress[1]
+	if len(metrics.Ports) != 1 || *metrics.Ports[0].Protocol != corev1.ProtocolTCP || metrics.Ports[0].Port.IntValue() != CoreDNSMetricsPort {
+		t.Errorf("expected the second rule to allow the metrics port, got %#v", metrics.Ports)
+	}
+	expectedFrom := []networkingv1.NetworkPolicyPeer{{
+		NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"kubernetes.io/metadata.name": "openshift-dns-operator"}},
+		PodSelector:       &metav1.LabelSelector{MatchLabels: map[string]string{"name": "dns-operator"}},
+	}}
+	if !reflect.DeepEqual(metrics.From, expectedFrom) {
+		t.Errorf("expected the metrics port to be open only to the operator, got %#v", metrics.From)
+	}
+}
@@ -0,0 +1,218 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	"github.com/sirupsen/logrus"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// DNSNodeCoveragePreservedConditionType is the type of the DNS status
+	// condition that reports how many nodes the dns's node placement
+	// matches and whether any nodes that currently have dns pods would
+	// lose them under that node placement.
+	DNSNodeCoveragePreservedConditionType = conditions.TypeNodeCoveragePreserved
+
+	// maxReportedNodes is the maximum number of node names that the
+	// NodeCoveragePreserved condition lists in its message.
+	maxReportedNodes = 10
+)
+
+// daemonSetTolerations is the set of taint keys that the daemonset controller
+// tolerates automatically for all daemonset pods.  Nodes with these taints
+// run daemonset pods regardless of the pods' tolerations.
+var daemonSetTolerations = sets.NewString(
+	"node.kubernetes.io/not-ready",
+	"node.kubernetes.io/unreachable",
+	"node.kubernetes.io/disk-pressure",
+	"node.kubernetes.io/memory-pressure",
+	"node.kubernetes.io/pid-pressure",
+	"node.kubernetes.io/unschedulable",
+)
+
+// nodePlacementPreview describes the nodes on which a dns's pods would run
+// under the dns's node placement.
+type nodePlacementPreview struct {
+	// totalNodes is the number of nodes in the cluster.
+	totalNodes int
+	// matchingNodes is the set of names of nodes that the node placement
+	// matches.
+	matchingNodes sets.String
+	// losingNodes is the set of names of nodes that currently have dns
+	// pods but that the node placement does not match.
+	losingNodes sets.String
+}
+
+// computeDNSNodeCoveragePreservedCondition lists the cluster's nodes and the
+// given dns's pods and returns a status condition that reports how many nodes
+// the dns's node placement matches and which nodes that currently have dns
+// pods do not match it, and a Boolean value indicating whether the daemonset
+// must keep its current node placement.  A change to the node placement that
+// would remove dns pods from nodes is held unless the dns's
+// AllowNodeCoverageLossAnnotation annotation is set to true, so that an
+// accidental loss of DNS coverage is reported before it happens.  Nodes can
+// also lose dns pods because their labels or taints change, which the
+// operator does not prevent; the condition reports those nodes after the fact.
+func (r *reconciler) computeDNSNodeCoveragePreservedCondition(dns *operatorv1.DNS) (operatorv1.OperatorCondition, bool, error) {
+	condition := operatorv1.OperatorCondition{
+		Type: DNSNodeCoveragePreservedConditionType,
+	}
+	nodes, err := ListNodes(context.TODO(), r.cache)
+	if err != nil {
+		return condition, false, fmt.Errorf("failed to list nodes: %w", err)
+	}
+	selector, err := metav1.LabelSelectorAsSelector(DNSDaemonSetPodSelector(dns))
+	if err != nil {
+		return condition, false, fmt.Errorf("failed to build pod selector for dns %s: %w", dns.Name, err)
+	}
+	podList := &corev1.PodList{}
+	listOpts := []client.ListOption{
+		client.MatchingLabelsSelector{Selector: selector},
+		client.InNamespace(DefaultOperandNamespace),
+	}
+	if err := r.cache.List(context.TODO(), podList, listOpts...); err != nil {
+		return condition, false, fmt.Errorf("failed to list pods for dns %s: %w", dns.Name, err)
+	}
+	haveDS, current, err := r.currentDNSDaemonSet(dns)
+	if err != nil {
+		return condition, false, fmt.Errorf("failed to get daemonset for dns %s: %w", dns.Name, err)
+	}
+
+	preview := previewNodePlacement(nodes, podList.Items, nodeSelectorForDNS(dns), tolerationsForDNS(dns))
+	if preview.losingNodes.Len() == 0 {
+		condition.Status = operatorv1.ConditionTrue
+		condition.Reason = conditions.ReasonAsExpected
+		condition.Message = fmt.Sprintf("The node placement matches %d of %d nodes, including every node that has a DNS pod.", preview.matchingNodes.Len(), preview.totalNodes)
+		return condition, false, nil
+	}
+	condition.Status = operatorv1.ConditionFalse
+	if haveDS && nodePlacementChanged(dns, current) && !allowNodeCoverageLoss(dns) {
+		logrus.Warningf("holding node placement change for dns %s, which matches %d of %d nodes; nodes that would lose dns pods: %s", dns.Name, preview.matchingNodes.Len(), preview.totalNodes, strings.Join(preview.losingNodes.List(), ", "))
+		condition.Reason = conditions.ReasonNodePlacementChangeHeld
+		condition.Message = fmt.Sprintf("The new node placement matches %d of %d nodes; %d nodes that have DNS pods would lose them: %s.  The DNS pods keep their current node placement until the %s annotation is set to \"true\".", preview.matchingNodes.Len(), preview.totalNodes, preview.losingNodes.Len(), summarizeNodeNames(preview.losingNodes), AllowNodeCoverageLossAnnotation)
+		return condition, true, nil
+	}
+	logrus.Warningf("node placement for dns %s matches %d of %d nodes; nodes that are losing dns pods: %s", dns.Name, preview.matchingNodes.Len(), preview.totalNodes, strings.Join(preview.losingNodes.List(), ", "))
+	condition.Reason = conditions.ReasonNodesLosingDNSPods
+	condition.Message = fmt.Sprintf("The node placement matches %d of %d nodes; %d nodes that have DNS pods are losing them: %s.", preview.matchingNodes.Len(), preview.totalNodes, preview.losingNodes.Len(), summarizeNodeNames(preview.losingNodes))
+	return condition, false, nil
+}
+
+// nodePlacementChanged returns a Boolean value indicating whether the given
+// dns's node selector or tolerations differ from those of the given current
+// daemonset.
+func nodePlacementChanged(dns *operatorv1.DNS, current *appsv1.DaemonSet) bool {
+	spec := current.Spec.Template.Spec
+	return !cmp.Equal(spec.NodeSelector, nodeSelectorForDNS(dns), cmpopts.EquateEmpty()) || !cmp.Equal(spec.Tolerations, tolerationsForDNS(dns), cmpopts.EquateEmpty(), cmpopts.SortSlices(cmpTolerations))
+}
+
+// allowNodeCoverageLoss returns a Boolean value indicating whether the given
+// dns's AllowNodeCoverageLossAnnotation annotation is set to true.
+func allowNodeCoverageLoss(dns *operatorv1.DNS) bool {
+	value, ok := dns.Annotations[AllowNodeCoverageLossAnnotation]
+	if !ok {
+		return false
+	}
+	allow, err := strconv.ParseBool(value)
+	if err != nil {
+		logrus.Warningf("ignoring invalid value %q for annotation %s on dns %s: %v", value, AllowNodeCoverageLossAnnotation, dns.Name, err)
+		return false
+	}
+	return allow
+}
+
+// holdDNSNodePlacement sets the node selector and tolerations of the given
+// desired daemonset to those of the given current daemonset and updates the
+// desired daemonset's hash.
+func holdDNSNodePlacement(desired, current *appsv1.DaemonSet) error {
+	desired.Spec.Template.Spec.NodeSelector = current.Spec.Template.Spec.NodeSelector
+	desired.Spec.Template.Spec.Tolerations = current.Spec.Template.Spec.Tolerations
+	hash, err := computeHash(desired.Spec)
+	if err != nil {
+		return err
+	}
+	setDesiredHash(desired, hash)
+	return nil
+}
+
+// previewNodePlacement returns a preview of the nodes among the given nodes
+// that the given node selector and tolerations match and of the nodes that
+// have any of the given pods but that the node placement does not match.
+func previewNodePlacement(nodes []corev1.Node, pods []corev1.Pod, nodeSelector map[string]string, tolerations []corev1.Toleration) nodePlacementPreview {
+	selector := labels.SelectorFromSet(nodeSelector)
+	all, matching := sets.NewString(), sets.NewString()
+	for i := range nodes {
+		node := &nodes[i]
+		all.Insert(node.Name)
+		if selector.Matches(labels.Set(node.Labels)) && daemonSetPodToleratesTaints(tolerations, node.Spec.Taints) {
+			matching.Insert(node.Name)
+		}
+	}
+	losing := sets.NewString()
+	for _, pod := range pods {
+		name := pod.Spec.NodeName
+		// Ignore pods on nodes that no longer exist; the nodes have
+		// already lost their dns pods.
+		if len(name) == 0 || !all.Has(name) || matching.Has(name) {
+			continue
+		}
+		losing.Insert(name)
+	}
+	return nodePlacementPreview{
+		totalNodes:    all.Len(),
+		matchingNodes: matching,
+		losingNodes:   losing,
+	}
+}
+
+// daemonSetPodToleratesTaints returns a Boolean value indicating whether a
+// daemonset pod with the given tolerations tolerates all of the given taints
+// that prevent scheduling or cause eviction.  Taints that the daemonset
+// controller tolerates automatically are ignored.
+func daemonSetPodToleratesTaints(tolerations []corev1.Toleration, taints []corev1.Taint) bool {
+	for i := range taints {
+		taint := &taints[i]
+		if taint.Effect == corev1.TaintEffectPreferNoSchedule || daemonSetTolerations.Has(taint.Key) {
+			continue
+		}
+		tolerated := false
+		for _, toleration := range tolerations {
+			if toleration.ToleratesTaint(taint) {
+				tolerated = true
+				break
+			}
+		}
+		if !tolerated {
+			return false
+		}
+	}
+	return true
+}
+
+// summarizeNodeNames returns the given node names as a sorted,
+// comma-delimited list, truncated to maxReportedNodes names.
+func summarizeNodeNames(names sets.String) string {
+	list := names.List()
+	if len(list) <= maxReportedNodes {
+		return strings.Join(list, ", ")
+	}
+	return fmt.Sprintf("%s, and %d more", strings.Join(list[:maxReportedNodes], ", "), len(list)-maxReportedNodes)
+}
@@ -0,0 +1,149 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	corev1 "k8s.io/api/core/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// TestPreviewNodePlacement verifies that previewNodePlacement reports the
+// nodes that a node placement matches and the nodes that would lose dns pods.
+func TestPreviewNodePlacement(t *testing.T) {
+	node := func(name string, labels map[string]string, taints ...corev1.Taint) corev1.Node {
+		return corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+			Spec:       corev1.NodeSpec{Taints: taints},
+		}
+	}
+	pod := func(nodeName string) corev1.Pod {
+		return corev1.Pod{Spec: corev1.PodSpec{NodeName: nodeName}}
+	}
+	linux := map[string]string{"kubernetes.io/os": "linux"}
+	infra := map[string]string{"kubernetes.io/os": "linux", "node-role.kubernetes.io/infra": ""}
+	infraTaint := corev1.Taint{Key: "node-role.kubernetes.io/infra", Effect: corev1.TaintEffectNoSchedule}
+	nodes := []corev1.Node{
+		node("worker-1", linux),
+		node("worker-2", linux),
+		node("infra-1", infra, infraTaint),
+		node("not-ready", linux, corev1.Taint{Key: "node.kubernetes.io/not-ready", Effect: corev1.TaintEffectNoExecute}),
+		node("soft-taint", linux, corev1.Taint{Key: "foo", Effect: corev1.TaintEffectPreferNoSchedule}),
+	}
+	pods := []corev1.Pod{pod("worker-1"), pod("worker-2"), pod("infra-1"), pod("gone"), pod("")}
+	infraToleration := corev1.Toleration{Key: "node-role.kubernetes.io/infra", Operator: corev1.TolerationOpExists}
+
+	testCases := []struct {
+		description    string
+		nodeSelector   map[string]string
+		tolerations    []corev1.Toleration
+		expectMatching []string
+		expectLosing   []string
+	}{
+		{
+			description:    "default placement without infra toleration",
+			nodeSelector:   map[string]string{"kubernetes.io/os": "linux"},
+			expectMatching: []string{"not-ready", "soft-taint", "worker-1", "worker-2"},
+			expectLosing:   []string{"infra-1"},
+		},
+		{
+			description:    "default placement with infra toleration",
+			nodeSelector:   map[string]string{"kubernetes.io/os": "linux"},
+			tolerations:    []corev1.Toleration{infraToleration},
+			expectMatching: []string{"infra-1", "not-ready", "soft-taint", "worker-1", "worker-2"},
+		},
+		{
+			description:    "infra nodes only",
+			nodeSelector:   map[string]string{"node-role.kubernetes.io/infra": ""},
+			tolerations:    []corev1.Toleration{infraToleration},
+			expectMatching: []string{"infra-1"},
+			expectLosing:   []string{"worker-1", "worker-2"},
+		},
+		{
+			description:  "selector that matches no nodes",
+			nodeSelector: map[string]string{"xyzzy": "quux"},
+			expectLosing: []string{"infra-1", "worker-1", "worker-2"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			preview := previewNodePlacement(nodes, pods, tc.nodeSelector, tc.tolerations)
+			if preview.totalNodes != 5 {
+				t.Errorf("expected 5 nodes, got %d", preview.totalNodes)
+			}
+			if e, a := sets.NewString(tc.expectMatching...), preview.matchingNodes; !e.Equal(a) {
+				t.Errorf("expected matching nodes %v, got %v", e.List(), a.List())
+			}
+			if e, a := sets.NewString(tc.expectLosing...), preview.losingNodes; !e.Equal(a) {
+				t.Errorf("expected losing nodes %v, got %v", e.List(), a.List())
+			}
+		})
+	}
+}
+
+// TestHoldDNSNodePlacement verifies that nodePlacementChanged detects a change
+// to the dns's node placement and that holdDNSNodePlacement makes the desired
+// daemonset keep the current node placement while other changes still apply.
+func TestHoldDNSNodePlacement(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DefaultDNSController,
+		},
+	}
+	current, err := desiredDNSDaemonSet(dns, "coredns:1", "kube-rbac-proxy", "", "dns-default-metrics-tls", "", nil, nil, nil, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nodePlacementChanged(dns, current) {
+		t.Errorf("expected the node placement to be unchanged")
+	}
+	if allowNodeCoverageLoss(dns) {
+		t.Errorf("expected node coverage loss not to be allowed without the annotation")
+	}
+
+	dns.Spec.NodePlacement.NodeSelector = map[string]string{"node-role.kubernetes.io/infra": ""}
+	dns.Annotations = map[string]string{AllowNodeCoverageLossAnnotation: "true"}
+	if !nodePlacementChanged(dns, current) {
+		t.Errorf("expected the node placement to be changed")
+	}
+	if !allowNodeCoverageLoss(dns) {
+		t.Errorf("expected node coverage loss to be allowed with the annotation")
+	}
+
+	desired, err := desiredDNSDaemonSet(dns, "coredns:2", "kube-rbac-proxy", "", "dns-default-metrics-tls", "", nil, nil, nil, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := holdDNSNodePlacement(desired, current); err != nil {
+		t.Fatal(err)
+	}
+	changed, updated := daemonsetConfigChanged(current, desired)
+	if !changed {
+		t.Fatal("expected the image change to apply")
+	}
+	if e, a := current.Spec.Template.Spec.NodeSelector, updated.Spec.Template.Spec.NodeSelector; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected node selector %v, got %v", e, a)
+	}
+	if a := updated.Spec.Template.Spec.Containers[0].Image; a != "coredns:2" {
+		t.Errorf("expected image coredns:2, got %s", a)
+	}
+}
+
+// TestSummarizeNodeNames verifies that summarizeNodeNames truncates long lists
+// of node names.
+func TestSummarizeNodeNames(t *testing.T) {
+	names := sets.NewString()
+	for _, name := range []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l"} {
+		names.Insert(name)
+	}
+	if e, a := "a, b, c, d, e, f, g, h, i, j, and 2 more", summarizeNodeNames(names); e != a {
+		t.Errorf("expected %q, got %q", e, a)
+	}
+	if e, a := "a, b", summarizeNodeNames(sets.NewString("b", "a")); e != a {
+		t.Errorf("expected %q, got %q", e, a)
+	}
+}
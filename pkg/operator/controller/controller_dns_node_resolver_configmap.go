@@ -0,0 +1,233 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// nodeResolverHostsKey is the key in the node resolver configmap that
+	// holds the hosts file entries for the node resolver services.
+	nodeResolverHostsKey = "hosts"
+
+	// nodeResolverHostsMountPath is the path at which the node resolver
+	// configmap is mounted in the node resolver pods.
+	nodeResolverHostsMountPath = "/etc/node-resolver"
+
+	// nodeResolverResyncPeriod is the interval at which the operator
+	// reconciles the node resolver configmap when the dns has additional
+	// node resolver services.  The operator does not watch services outside
+	// of the namespaces of the default node resolver services, so it must
+	// poll for changes to the additional services' cluster IPs.
+	nodeResolverResyncPeriod = 5 * time.Minute
+)
+
+// nodeResolverService is a service for which the node resolver adds entries
+// to /etc/hosts.
+type nodeResolverService struct {
+	// name is the namespace and name of the service.
+	name types.NamespacedName
+	// hostname is the hostname to use for the service's cluster IPs.  If
+	// hostname is empty, the service's cluster domain names are used.
+	hostname string
+}
+
+// nodeResolverServiceNames parses the given comma- or space-delimited list of
+// relative service names of the form <name>.<namespace>.svc and returns the
+// namespaced name of each service.
+func nodeResolverServiceNames(list string) []types.NamespacedName {
+	var names []types.NamespacedName
+	for _, svc := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == ' ' }) {
+		parts := strings.Split(svc, ".")
+		if len(parts) != 3 || parts[2] != "svc" {
+			logrus.Warningf("ignoring malformed node resolver service name %q", svc)
+			continue
+		}
+		names = append(names, types.NamespacedName{Namespace: parts[1], Name: parts[0]})
+	}
+	return names
+}
+
+// NodeResolverServiceNamespaces returns the namespaces of the services for
+// which the node resolver adds entries to /etc/hosts.
+func NodeResolverServiceNamespaces() []string {
+	seen := map[string]bool{}
+	var namespaces []string
+	for _, name := range nodeResolverServiceNames(services) {
+		if !seen[name.Namespace] {
+			seen[name.Namespace] = true
+			namespaces = append(namespaces, name.Namespace)
+		}
+	}
+	return namespaces
+}
+
+// nodeResolverAdditionalServices returns the additional node resolver services
+// that the given dns's NodeResolverAdditionalServicesAnnotation annotation
+// lists.  Malformed entries are ignored.
+func nodeResolverAdditionalServices(dns *operatorv1.DNS) []nodeResolverService {
+	var svcs []nodeResolverService
+	list := dns.Annotations[NodeResolverAdditionalServicesAnnotation]
+	for _, entry := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' }) {
+		ref, hostname := entry, ""
+		if i := strings.Index(entry, "="); i != -1 {
+			ref, hostname = entry[:i], strings.ToLower(entry[i+1:])
+			if errs := validation.IsDNS1123Subdomain(hostname); len(errs) != 0 {
+				logrus.Warningf("ignoring node resolver additional service %q with invalid hostname: %s", entry, strings.Join(errs, ", "))
+				continue
+			}
+		}
+		parts := strings.Split(ref, "/")
+		if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+			logrus.Warningf("ignoring malformed node resolver additional service %q", entry)
+			continue
+		}
+		svcs = append(svcs, nodeResolverService{
+			name:     types.NamespacedName{Namespace: parts[0], Name: parts[1]},
+			hostname: hostname,
+		})
+	}
+	return svcs
+}
+
+// isNodeResolverService returns a Boolean value indicating whether the given
+// service is one for which the node resolver adds entries to /etc/hosts.
+func isNodeResolverService(namespace, name string) bool {
+	for _, svc := range nodeResolverServiceNames(services) {
+		if svc.Namespace == namespace && svc.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ensureNodeResolverConfigMap ensures that the node resolver configmap exists
+// and has hosts file entries for the current cluster IPs of the node resolver
+// services and of the dns's additional node resolver services.  Publishing the
+// IPs from the operator, which watches the services, spares each node from
+// querying cluster DNS for them.
+func (r *reconciler) ensureNodeResolverConfigMap(dns *operatorv1.DNS, clusterDomain string) error {
+	var svcs []nodeResolverService
+	for _, name := range nodeResolverServiceNames(services) {
+		svcs = append(svcs, nodeResolverService{name: name})
+	}
+	var entries []nodeResolverServiceEntry
+	for _, svc := range svcs {
+		current := corev1.Service{}
+		if err := r.cache.Get(context.TODO(), svc.name, &current); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return fmt.Errorf("failed to get service %s: %w", svc.name, err)
+		}
+		entries = append(entries, nodeResolverServiceEntry{nodeResolverService: svc, service: current})
+	}
+	// The additional services may be in any namespace, which the cache
+	// does not cover, so get them using the client.
+	for _, svc := range nodeResolverAdditionalServices(dns) {
+		current := corev1.Service{}
+		if err := r.client.Get(context.TODO(), svc.name, &current); err != nil {
+			if errors.IsNotFound(err) {
+				logrus.Warningf("node resolver additional service %s not found", svc.name)
+				continue
+			}
+			return fmt.Errorf("failed to get service %s: %w", svc.name, err)
+		}
+		entries = append(entries, nodeResolverServiceEntry{nodeResolverService: svc, service: current})
+	}
+	desired := desiredNodeResolverConfigMap(dns, clusterDomain, entries)
+	r.desiredState.record(dns.Name, desired)
+
+	current := &corev1.ConfigMap{}
+	if err := r.client.Get(context.TODO(), NodeResolverConfigMapName(), current); err != nil {
+		if !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to get node resolver configmap: %w", err)
+		}
+		if err := r.client.Create(context.TODO(), desired); err != nil {
+			return fmt.Errorf("failed to create node resolver configmap: %w", err)
+		}
+		logrus.Infof("created node resolver configmap %s/%s", desired.Namespace, desired.Name)
+		return nil
+	}
+	if cmp.Equal(current.Data, desired.Data, cmpopts.EquateEmpty()) {
+		return nil
+	}
+	updated := current.DeepCopy()
+	updated.Data = desired.Data
+	// Diff before updating because the client may mutate the object.
+	diff := cmp.Diff(current.Data, updated.Data, cmpopts.EquateEmpty())
+	if err := r.client.Update(context.TODO(), updated); err != nil {
+		return fmt.Errorf("failed to update node resolver configmap: %w", err)
+	}
+	logrus.Infof("updated node resolver configmap %s/%s: %v", updated.Namespace, updated.Name, diff)
+	return nil
+}
+
+// nodeResolverServiceEntry is a node resolver service and its current state.
+type nodeResolverServiceEntry struct {
+	nodeResolverService
+	service corev1.Service
+}
+
+// desiredNodeResolverConfigMap returns the desired node resolver configmap
+// with hosts file entries for the given services.
+func desiredNodeResolverConfigMap(dns *operatorv1.DNS, clusterDomain string, entries []nodeResolverServiceEntry) *corev1.ConfigMap {
+	var lines []string
+	seen := map[string]bool{}
+	for _, entry := range entries {
+		svc := entry.service
+		ips := svc.Spec.ClusterIPs
+		if len(ips) == 0 && len(svc.Spec.ClusterIP) != 0 {
+			ips = []string{svc.Spec.ClusterIP}
+		}
+		hostnames := entry.hostname
+		if len(hostnames) == 0 {
+			hostname := fmt.Sprintf("%s.%s.svc", svc.Name, svc.Namespace)
+			hostnames = fmt.Sprintf("%s %s.%s", hostname, hostname, clusterDomain)
+		}
+		for _, ip := range ips {
+			if ip == corev1.ClusterIPNone {
+				continue
+			}
+			line := fmt.Sprintf("%s %s", ip, hostnames)
+			if !seen[line] {
+				seen[line] = true
+				lines = append(lines, line)
+			}
+		}
+	}
+	sort.Strings(lines)
+	hosts := ""
+	if len(lines) != 0 {
+		hosts = strings.Join(lines, "\n") + "\n"
+	}
+
+	name := NodeResolverConfigMapName()
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name.Name,
+			Namespace:       name.Namespace,
+			OwnerReferences: []metav1.OwnerReference{dnsOwnerRef(dns)},
+		},
+		Data: map[string]string{
+			nodeResolverHostsKey: hosts,
+		},
+	}
+}
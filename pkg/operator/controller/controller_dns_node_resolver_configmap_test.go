@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	corev1 "k8s.io/api/core/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// TestNodeResolverServiceNames verifies that nodeResolverServiceNames parses
+// relative service names and ignores malformed ones.
+func TestNodeResolverServiceNames(t *testing.T) {
+	actual := nodeResolverServiceNames("image-registry.openshift-image-registry.svc, foo.bar.svc bogus")
+	expected := []types.NamespacedName{
+		{Namespace: "openshift-image-registry", Name: "image-registry"},
+		{Namespace: "bar", Name: "foo"},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
+
+// TestDesiredNodeResolverConfigMap verifies that desiredNodeResolverConfigMap
+// publishes a hosts file entry for each cluster IP of each service.
+func TestDesiredNodeResolverConfigMap(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DefaultDNSController,
+		},
+	}
+	entries := []nodeResolverServiceEntry{
+		{
+			nodeResolverService: nodeResolverService{
+				name: types.NamespacedName{Namespace: "openshift-image-registry", Name: "image-registry"},
+			},
+			service: corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "openshift-image-registry", Name: "image-registry"},
+				Spec: corev1.ServiceSpec{
+					ClusterIP:  "172.30.0.5",
+					ClusterIPs: []string{"172.30.0.5", "fd02::5"},
+				},
+			},
+		},
+		{
+			nodeResolverService: nodeResolverService{
+				name: types.NamespacedName{Namespace: "bar", Name: "headless"},
+			},
+			service: corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "bar", Name: "headless"},
+				Spec: corev1.ServiceSpec{
+					ClusterIP: corev1.ClusterIPNone,
+				},
+			},
+		},
+		{
+			nodeResolverService: nodeResolverService{
+				name:     types.NamespacedName{Namespace: "monitoring", Name: "agent"},
+				hostname: "agent.example.com",
+			},
+			service: corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "monitoring", Name: "agent"},
+				Spec: corev1.ServiceSpec{
+					ClusterIP: "172.30.0.9",
+				},
+			},
+		},
+	}
+	cm := desiredNodeResolverConfigMap(dns, "cluster.local", entries)
+	expected := "172.30.0.5 image-registry.openshift-image-registry.svc image-registry.openshift-image-registry.svc.cluster.local\n" +
+		"172.30.0.9 agent.example.com\n" +
+		"fd02::5 image-registry.openshift-image-registry.svc image-registry.openshift-image-registry.svc.cluster.local\n"
+	if actual := cm.Data[nodeResolverHostsKey]; actual != expected {
+		t.Errorf("expected hosts %q, got %q", expected, actual)
+	}
+
+	cm = desiredNodeResolverConfigMap(dns, "cluster.local", nil)
+	if actual := cm.Data[nodeResolverHostsKey]; actual != "" {
+		t.Errorf("expected empty hosts without services, got %q", actual)
+	}
+}
+
+// TestNodeResolverAdditionalServices verifies that
+// nodeResolverAdditionalServices parses the additional services annotation
+// and ignores malformed entries.
+func TestNodeResolverAdditionalServices(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DefaultDNSController,
+			Annotations: map[string]string{
+				NodeResolverAdditionalServicesAnnotation: "monitoring/agent=Agent.example.com, foo/bar\nbogus baz/qux=not_a_hostname /missing",
+			},
+		},
+	}
+	expected := []nodeResolverService{
+		{name: types.NamespacedName{Namespace: "monitoring", Name: "agent"}, hostname: "agent.example.com"},
+		{name: types.NamespacedName{Namespace: "foo", Name: "bar"}},
+	}
+	if actual := nodeResolverAdditionalServices(dns); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
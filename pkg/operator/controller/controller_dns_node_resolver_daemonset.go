@@ -51,6 +51,9 @@ func (r *reconciler) ensureNodeResolverDaemonSet(dns *operatorv1.DNS, clusterIP,
 	if err != nil {
 		return haveDS, current, fmt.Errorf("failed to build node resolver daemonset: %v", err)
 	}
+	if wantDS {
+		r.desiredState.record(dns.Name, desired)
+	}
 	switch {
 	case !wantDS && !haveDS:
 		return false, nil, nil
@@ -96,6 +99,10 @@ func desiredNodeResolverDaemonSet(dns *operatorv1.DNS, clusterIP, clusterDomain,
 			Value: clusterDomain,
 		})
 	}
+	envs = append(envs, corev1.EnvVar{
+		Name:  "HOSTS_ENTRIES_FILE",
+		Value: nodeResolverHostsMountPath + "/" + nodeResolverHostsKey,
+	})
 	trueVal := true
 	name := NodeResolverDaemonSetName()
 	daemonset := appsv1.DaemonSet{
@@ -138,6 +145,10 @@ func desiredNodeResolverDaemonSet(dns *operatorv1.DNS, clusterIP, clusterDomain,
 						VolumeMounts: []corev1.VolumeMount{{
 							Name:      "hosts-file",
 							MountPath: "/etc/hosts",
+						}, {
+							Name:      "hosts-entries",
+							MountPath: nodeResolverHostsMountPath,
+							ReadOnly:  true,
 						}},
 					}},
 					// The node-resolver pods need to run on
@@ -164,6 +175,21 @@ func desiredNodeResolverDaemonSet(dns *operatorv1.DNS, clusterIP, clusterDomain,
 								Type: &hostPathFile,
 							},
 						},
+					}, {
+						// The operator publishes the
+						// service IPs in this configmap.
+						// It is optional so that the
+						// pods can start before the
+						// operator creates it.
+						Name: "hosts-entries",
+						VolumeSource: corev1.VolumeSource{
+							ConfigMap: &corev1.ConfigMapVolumeSource{
+								LocalObjectReference: corev1.LocalObjectReference{
+									Name: NodeResolverConfigMapName().Name,
+								},
+								Optional: &trueVal,
+							},
+						},
 					}},
 				},
 			},
@@ -176,6 +202,12 @@ func desiredNodeResolverDaemonSet(dns *operatorv1.DNS, clusterIP, clusterDomain,
 			},
 		},
 	}
+	hash, err := computeHash(daemonset.Spec)
+	if err != nil {
+		return false, nil, err
+	}
+	setDesiredHash(&daemonset, hash)
+
 	return true, &daemonset, nil
 }
 
@@ -233,6 +265,13 @@ func nodeResolverDaemonSetConfigChanged(current, expected *appsv1.DaemonSet) (bo
 	changed := false
 	updated := current.DeepCopy()
 
+	if hashChanged(current.Annotations, expected.Annotations) {
+		setDesiredHash(updated, expected.Annotations[DesiredHashAnnotation])
+		updated.Spec.Template = expected.Spec.Template
+		updated.Spec.UpdateStrategy = expected.Spec.UpdateStrategy
+		return true, updated
+	}
+
 	if !cmp.Equal(current.Spec.UpdateStrategy, expected.Spec.UpdateStrategy, cmpopts.EquateEmpty()) {
 		updated.Spec.UpdateStrategy = expected.Spec.UpdateStrategy
 		changed = true
@@ -0,0 +1,155 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// controlPlaneNodeRoleLabel is the label that identifies a
+	// control-plane node.
+	controlPlaneNodeRoleLabel = "node-role.kubernetes.io/control-plane"
+	// masterNodeRoleLabel is the legacy label that identifies a
+	// control-plane node.
+	masterNodeRoleLabel = "node-role.kubernetes.io/master"
+	// workerNodeRoleLabel is the label that identifies a worker node.
+	workerNodeRoleLabel = "node-role.kubernetes.io/worker"
+	// infraNodeRoleLabel is the label that identifies an infra node.
+	infraNodeRoleLabel = "node-role.kubernetes.io/infra"
+)
+
+// nodeTopology describes the roles of the cluster's nodes insofar as they
+// affect the expected state of the DNS and node-resolver daemonsets.  The zero
+// value describes a cluster with both control-plane and worker nodes.
+type nodeTopology struct {
+	// noNodes indicates that the cluster has no nodes, as is the case for
+	// a cluster with an external control plane and no workers.  No DNS or
+	// node-resolver pods are desired on such a cluster.
+	noNodes bool
+	// controlPlaneOnly indicates that the cluster has nodes but that all
+	// of them are control-plane nodes without the worker role.  DNS pods
+	// can run on such a cluster only if the DNS's node placement allows
+	// control-plane nodes.
+	controlPlaneOnly bool
+	// workersUnschedulable indicates that the cluster has worker nodes but
+	// that all of them are cordoned, as may happen temporarily while the
+	// cluster's workers are being replaced or scaled.
+	workersUnschedulable bool
+	// controlPlaneWindow indicates that workersUnschedulable is true, that
+	// the DNS tolerates unschedulable workers, and that DNS pods on
+	// control-plane nodes are available.  During such a window, the DNS
+	// is reported as progressing rather than degraded.
+	controlPlaneWindow bool
+	// controlPlaneNodes is the set of names of control-plane nodes that
+	// are not also worker nodes.
+	controlPlaneNodes sets.String
+}
+
+// currentNodeTopology lists the cluster's nodes and returns their topology.
+func (r *reconciler) currentNodeTopology() (nodeTopology, error) {
+	nodes, err := ListNodes(context.TODO(), r.cache)
+	if err != nil {
+		return nodeTopology{}, fmt.Errorf("failed to list nodes: %w", err)
+	}
+	return computeNodeTopology(nodes), nil
+}
+
+// computeNodeTopology returns the topology of the given nodes.  A node is
+// considered a worker node if it has the worker role or does not have a
+// control-plane role.
+func computeNodeTopology(nodes []corev1.Node) nodeTopology {
+	all, workers, schedulableWorkers := sets.NewString(), sets.NewString(), sets.NewString()
+	for _, node := range nodes {
+		all.Insert(node.Name)
+		if isWorkerNode(&node) {
+			workers.Insert(node.Name)
+			if !node.Spec.Unschedulable {
+				schedulableWorkers.Insert(node.Name)
+			}
+		}
+	}
+	return nodeTopology{
+		noNodes:              all.Len() == 0,
+		controlPlaneOnly:     all.Len() != 0 && workers.Len() == 0,
+		workersUnschedulable: workers.Len() != 0 && schedulableWorkers.Len() == 0,
+		controlPlaneNodes:    all.Difference(workers),
+	}
+}
+
+// isWorkerNode returns a Boolean value indicating whether the given node can
+// run ordinary workloads.
+func isWorkerNode(node *corev1.Node) bool {
+	if _, ok := node.Labels[workerNodeRoleLabel]; ok {
+		return true
+	}
+	_, isControlPlane := node.Labels[controlPlaneNodeRoleLabel]
+	_, isMaster := node.Labels[masterNodeRoleLabel]
+	return !isControlPlane && !isMaster
+}
+
+// tolerateUnschedulableWorkers returns a Boolean value indicating whether the
+// given dns's TolerateUnschedulableWorkersAnnotation annotation is set to
+// true.
+func tolerateUnschedulableWorkers(dns *operatorv1.DNS) bool {
+	value, ok := dns.Annotations[TolerateUnschedulableWorkersAnnotation]
+	if !ok {
+		return false
+	}
+	tolerate, err := strconv.ParseBool(value)
+	if err != nil {
+		logrus.Warningf("ignoring invalid value %q for annotation %s on dns %s: %v", value, TolerateUnschedulableWorkersAnnotation, dns.Name, err)
+		return false
+	}
+	return tolerate
+}
+
+// computeControlPlaneWindow sets topology.controlPlaneWindow if all worker
+// nodes are unschedulable, the given dns tolerates unschedulable workers, and
+// at least one of the dns's pods on a control-plane node is ready.
+func (r *reconciler) computeControlPlaneWindow(dns *operatorv1.DNS, topology *nodeTopology) error {
+	if !topology.workersUnschedulable || !tolerateUnschedulableWorkers(dns) {
+		return nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(DNSDaemonSetPodSelector(dns))
+	if err != nil {
+		return fmt.Errorf("failed to build pod selector for dns %s: %w", dns.Name, err)
+	}
+	podList := &corev1.PodList{}
+	listOpts := []client.ListOption{
+		client.MatchingLabelsSelector{Selector: selector},
+		client.InNamespace(DefaultOperandNamespace),
+	}
+	if err := r.cache.List(context.TODO(), podList, listOpts...); err != nil {
+		return fmt.Errorf("failed to list pods for dns %s: %w", dns.Name, err)
+	}
+	topology.controlPlaneWindow = controlPlaneDNSPodReady(podList.Items, topology.controlPlaneNodes)
+	return nil
+}
+
+// controlPlaneDNSPodReady returns a Boolean value indicating whether any of
+// the given pods is ready and runs on one of the given control-plane nodes.
+func controlPlaneDNSPodReady(pods []corev1.Pod, controlPlaneNodes sets.String) bool {
+	for _, pod := range pods {
+		if !controlPlaneNodes.Has(pod.Spec.NodeName) {
+			continue
+		}
+		for _, cond := range pod.Status.Conditions {
+			if cond.Type == corev1.PodReady && cond.Status == corev1.ConditionTrue {
+				return true
+			}
+		}
+	}
+	return false
+}
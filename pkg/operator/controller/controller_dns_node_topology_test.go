@@ -0,0 +1,136 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// TestComputeNodeTopology verifies that computeNodeTopology recognizes
+// clusters with no nodes, clusters with only control-plane nodes, and
+// clusters whose worker nodes are all unschedulable.
+func TestComputeNodeTopology(t *testing.T) {
+	node := func(name string, roles ...string) corev1.Node {
+		labels := map[string]string{}
+		for _, role := range roles {
+			labels[role] = ""
+		}
+		return corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+	}
+	cordoned := func(node corev1.Node) corev1.Node {
+		node.Spec.Unschedulable = true
+		return node
+	}
+	testCases := []struct {
+		name     string
+		nodes    []corev1.Node
+		expected nodeTopology
+	}{
+		{
+			name:     "no nodes",
+			expected: nodeTopology{noNodes: true, controlPlaneNodes: sets.NewString()},
+		},
+		{
+			name: "control-plane and worker nodes",
+			nodes: []corev1.Node{
+				node("master-0", masterNodeRoleLabel),
+				node("worker-0", workerNodeRoleLabel),
+			},
+			expected: nodeTopology{controlPlaneNodes: sets.NewString("master-0")},
+		},
+		{
+			name: "unlabeled node",
+			nodes: []corev1.Node{
+				node("master-0", controlPlaneNodeRoleLabel),
+				node("node-0"),
+			},
+			expected: nodeTopology{controlPlaneNodes: sets.NewString("master-0")},
+		},
+		{
+			name: "control-plane nodes only",
+			nodes: []corev1.Node{
+				node("master-0", masterNodeRoleLabel, controlPlaneNodeRoleLabel),
+				node("master-1", controlPlaneNodeRoleLabel),
+				node("master-0", masterNodeRoleLabel, controlPlaneNodeRoleLabel),
+			},
+			expected: nodeTopology{controlPlaneOnly: true, controlPlaneNodes: sets.NewString("master-0", "master-1")},
+		},
+		{
+			name: "schedulable control-plane nodes",
+			nodes: []corev1.Node{
+				node("master-0", masterNodeRoleLabel, workerNodeRoleLabel),
+			},
+			expected: nodeTopology{controlPlaneNodes: sets.NewString()},
+		},
+		{
+			name: "some workers unschedulable",
+			nodes: []corev1.Node{
+				node("master-0", masterNodeRoleLabel),
+				cordoned(node("worker-0", workerNodeRoleLabel)),
+				node("worker-1", workerNodeRoleLabel),
+			},
+			expected: nodeTopology{controlPlaneNodes: sets.NewString("master-0")},
+		},
+		{
+			name: "all workers unschedulable",
+			nodes: []corev1.Node{
+				node("master-0", masterNodeRoleLabel),
+				cordoned(node("worker-0", workerNodeRoleLabel)),
+				cordoned(node("worker-1", workerNodeRoleLabel)),
+			},
+			expected: nodeTopology{workersUnschedulable: true, controlPlaneNodes: sets.NewString("master-0")},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := computeNodeTopology(tc.nodes); !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("expected %+v, got %+v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+// TestControlPlaneDNSPodReady verifies that controlPlaneDNSPodReady only
+// considers ready pods on control-plane nodes.
+func TestControlPlaneDNSPodReady(t *testing.T) {
+	pod := func(nodeName string, ready corev1.ConditionStatus) corev1.Pod {
+		return corev1.Pod{
+			Spec: corev1.PodSpec{NodeName: nodeName},
+			Status: corev1.PodStatus{
+				Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: ready}},
+			},
+		}
+	}
+	controlPlaneNodes := sets.NewString("master-0", "master-1")
+	testCases := []struct {
+		name     string
+		pods     []corev1.Pod
+		expected bool
+	}{
+		{
+			name:     "no pods",
+			expected: false,
+		},
+		{
+			name:     "ready pod on worker node",
+			pods:     []corev1.Pod{pod("worker-0", corev1.ConditionTrue), pod("master-0", corev1.ConditionFalse)},
+			expected: false,
+		},
+		{
+			name:     "ready pod on control-plane node",
+			pods:     []corev1.Pod{pod("master-0", corev1.ConditionFalse), pod("master-1", corev1.ConditionTrue)},
+			expected: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := controlPlaneDNSPodReady(tc.pods, controlPlaneNodes); actual != tc.expected {
+				t.Errorf("expected %t, got %t", tc.expected, actual)
+			}
+		})
+	}
+}
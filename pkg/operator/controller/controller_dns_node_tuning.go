@@ -0,0 +1,226 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// DNSNodeTuningConfiguredConditionType is the type of the DNS status
+	// condition that indicates whether the operator has configured the
+	// node tuning profile that the NodeTuningAnnotation annotation
+	// requests.  The condition is reported only if the annotation is set.
+	DNSNodeTuningConfiguredConditionType = conditions.TypeNodeTuningConfigured
+
+	// tunedNamespace is the namespace of the node tuning operator, which
+	// watches Tuned resources in that namespace.
+	tunedNamespace = "openshift-cluster-node-tuning-operator"
+
+	// tunedControlPlaneProfile and tunedNodeProfile are the names of the
+	// tuned profiles for control-plane nodes and for other nodes.  Each
+	// includes the corresponding default OpenShift profile so that the
+	// defaults still apply.
+	tunedControlPlaneProfile = "openshift-dns-control-plane"
+	tunedNodeProfile         = "openshift-dns-node"
+
+	// tunedControlPlanePriority and tunedNodePriority are the priorities
+	// of the profiles' recommendations.  Tuned selects the matching
+	// recommendation with the lowest priority number; the default
+	// OpenShift recommendations have priorities 30 and 40.
+	tunedControlPlanePriority = 20
+	tunedNodePriority         = 25
+)
+
+// tunedGVK is the group, version, and kind of the node tuning operator's
+// Tuned resource.  The operator uses unstructured objects for Tuned
+// resources so that it does not depend on the node tuning operator's API.
+var tunedGVK = schema.GroupVersionKind{
+	Group:   "tuned.openshift.io",
+	Version: "v1",
+	Kind:    "Tuned",
+}
+
+// dnsNodeSysctl is a sysctl that the node tuning profile sets.
+type dnsNodeSysctl struct {
+	name  string
+	value string
+}
+
+// dnsNodeSysctls are the sysctls that the node tuning profile sets for nodes
+// that run dns pods.  Shorter UDP conntrack timeouts release the entries for
+// completed DNS queries sooner, and larger socket buffers absorb bursts of
+// queries.
+var dnsNodeSysctls = []dnsNodeSysctl{
+	{name: "net.netfilter.nf_conntrack_udp_timeout", value: "10"},
+	{name: "net.netfilter.nf_conntrack_udp_timeout_stream", value: "60"},
+	{name: "net.core.rmem_default", value: "1048576"},
+	{name: "net.core.rmem_max", value: "8388608"},
+	{name: "net.core.wmem_max", value: "8388608"},
+}
+
+// nodeTuningEnabled returns a Boolean value indicating whether the given dns's
+// NodeTuningAnnotation annotation is set to true.
+func nodeTuningEnabled(dns *operatorv1.DNS) bool {
+	value, ok := dns.Annotations[NodeTuningAnnotation]
+	if !ok {
+		return false
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		logrus.Warningf("ignoring invalid value %q for annotation %s on dns %s: %v", value, NodeTuningAnnotation, dns.Name, err)
+		return false
+	}
+	return enabled
+}
+
+// ensureDNSNodeTuning ensures that the Tuned resource for the given dns exists
+// if the dns enables node tuning and does not exist otherwise.  If node
+// tuning is enabled, ensureDNSNodeTuning returns a status condition that
+// describes the tuning that was requested; otherwise, it returns nil.
+func (r *reconciler) ensureDNSNodeTuning(dns *operatorv1.DNS) (*operatorv1.OperatorCondition, error) {
+	name := DNSTunedName(dns)
+	current := &unstructured.Unstructured{}
+	current.SetGroupVersionKind(tunedGVK)
+	haveTuned := true
+	if err := r.client.Get(context.TODO(), name, current); err != nil {
+		switch {
+		case meta.IsNoMatchError(err):
+			// The node tuning operator is not installed.
+			if !nodeTuningEnabled(dns) {
+				return nil, nil
+			}
+			return &operatorv1.OperatorCondition{
+				Type:    DNSNodeTuningConfiguredConditionType,
+				Status:  operatorv1.ConditionFalse,
+				Reason:  conditions.ReasonNodeTuningUnavailable,
+				Message: "The cluster does not have the Tuned API; the node tuning operator may not be installed.",
+			}, nil
+		case errors.IsNotFound(err):
+			haveTuned = false
+		default:
+			return nil, fmt.Errorf("failed to get tuned %s: %w", name, err)
+		}
+	}
+
+	if !nodeTuningEnabled(dns) {
+		if !haveTuned {
+			return nil, nil
+		}
+		if err := r.client.Delete(context.TODO(), current); err != nil && !errors.IsNotFound(err) {
+			return nil, fmt.Errorf("failed to delete tuned %s: %w", name, err)
+		}
+		logrus.Infof("deleted tuned %s", name)
+		return nil, nil
+	}
+
+	desired := desiredDNSTuned(dns)
+	switch {
+	case !haveTuned:
+		if err := r.client.Create(context.TODO(), desired); err != nil {
+			return nil, fmt.Errorf("failed to create tuned %s: %w", name, err)
+		}
+		logrus.Infof("created tuned %s", name)
+	case !reflect.DeepEqual(current.Object["spec"], desired.Object["spec"]):
+		updated := current.DeepCopy()
+		updated.Object["spec"] = desired.Object["spec"]
+		if err := r.client.Update(context.TODO(), updated); err != nil {
+			return nil, fmt.Errorf("failed to update tuned %s: %w", name, err)
+		}
+		logrus.Infof("updated tuned %s", name)
+	}
+
+	var sysctls []string
+	for _, sysctl := range dnsNodeSysctls {
+		sysctls = append(sysctls, sysctl.name+"="+sysctl.value)
+	}
+	return &operatorv1.OperatorCondition{
+		Type:    DNSNodeTuningConfiguredConditionType,
+		Status:  operatorv1.ConditionTrue,
+		Reason:  conditions.ReasonAsExpected,
+		Message: fmt.Sprintf("Tuned %s requests the %s and %s profiles, which set %s, on nodes that match the DNS's node selector.", name, tunedControlPlaneProfile, tunedNodeProfile, strings.Join(sysctls, ", ")),
+	}, nil
+}
+
+// desiredDNSTuned returns the desired Tuned resource for the given dns.  The
+// Tuned resource recommends its profiles for the nodes that the dns's node
+// selector matches.
+func desiredDNSTuned(dns *operatorv1.DNS) *unstructured.Unstructured {
+	name := DNSTunedName(dns)
+	tuned := &unstructured.Unstructured{}
+	tuned.SetGroupVersionKind(tunedGVK)
+	tuned.SetNamespace(name.Namespace)
+	tuned.SetName(name.Name)
+	tuned.SetOwnerReferences([]metav1.OwnerReference{dnsOwnerRef(dns)})
+
+	var sysctls strings.Builder
+	for _, sysctl := range dnsNodeSysctls {
+		fmt.Fprintf(&sysctls, "%s=%s\n", sysctl.name, sysctl.value)
+	}
+	profile := func(name, include string) interface{} {
+		return map[string]interface{}{
+			"name": name,
+			"data": fmt.Sprintf("[main]\nsummary=Tuning for nodes that run cluster DNS pods\ninclude=%s\n\n[sysctl]\n%s", include, sysctls.String()),
+		}
+	}
+
+	// Nested matches are ANDed, so chain the node selector's labels.
+	nodeSelector := nodeSelectorForDNS(dns)
+	keys := make([]string, 0, len(nodeSelector))
+	for k := range nodeSelector {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var selectorMatch []interface{}
+	for i := len(keys) - 1; i >= 0; i-- {
+		match := map[string]interface{}{
+			"label": keys[i],
+			"value": nodeSelector[keys[i]],
+		}
+		if selectorMatch != nil {
+			match["match"] = selectorMatch
+		}
+		selectorMatch = []interface{}{match}
+	}
+	controlPlaneMatch := func(label string) interface{} {
+		match := map[string]interface{}{"label": label}
+		if selectorMatch != nil {
+			match["match"] = selectorMatch
+		}
+		return match
+	}
+
+	tuned.Object["spec"] = map[string]interface{}{
+		"profile": []interface{}{
+			profile(tunedControlPlaneProfile, "openshift-control-plane"),
+			profile(tunedNodeProfile, "openshift-node"),
+		},
+		"recommend": []interface{}{
+			map[string]interface{}{
+				"match":    []interface{}{controlPlaneMatch(controlPlaneNodeRoleLabel), controlPlaneMatch(masterNodeRoleLabel)},
+				"priority": int64(tunedControlPlanePriority),
+				"profile":  tunedControlPlaneProfile,
+			},
+			map[string]interface{}{
+				"match":    selectorMatch,
+				"priority": int64(tunedNodePriority),
+				"profile":  tunedNodeProfile,
+			},
+		},
+	}
+	return tuned
+}
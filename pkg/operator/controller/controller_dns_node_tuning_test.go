@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// TestDesiredDNSTuned verifies that desiredDNSTuned recommends the tuning
+// profiles for the nodes that the DNS's node selector matches, with a
+// separate profile for control-plane nodes.
+func TestDesiredDNSTuned(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        DefaultDNSController,
+			UID:         "1",
+			Annotations: map[string]string{NodeTuningAnnotation: "true"},
+		},
+		Spec: operatorv1.DNSSpec{
+			NodePlacement: operatorv1.DNSNodePlacement{
+				NodeSelector: map[string]string{
+					"kubernetes.io/os": "linux",
+					"dns":              "heavy",
+				},
+			},
+		},
+	}
+	tuned := desiredDNSTuned(dns)
+	// The object must contain only JSON-compatible values, or DeepCopy
+	// panics.
+	tuned = tuned.DeepCopy()
+
+	if e, a := "openshift-cluster-node-tuning-operator", tuned.GetNamespace(); e != a {
+		t.Errorf("expected namespace %q, got %q", e, a)
+	}
+	if e, a := "openshift-dns-default", tuned.GetName(); e != a {
+		t.Errorf("expected name %q, got %q", e, a)
+	}
+
+	profiles, _, err := unstructured.NestedSlice(tuned.Object, "spec", "profile")
+	if err != nil || len(profiles) != 2 {
+		t.Fatalf("expected 2 profiles, got %v (%v)", profiles, err)
+	}
+	for _, p := range profiles {
+		data := p.(map[string]interface{})["data"].(string)
+		if !strings.Contains(data, "net.netfilter.nf_conntrack_udp_timeout=10\n") {
+			t.Errorf("expected profile to set the UDP conntrack timeout, got:\n%s", data)
+		}
+	}
+
+	recommend, _, err := unstructured.NestedSlice(tuned.Object, "spec", "recommend")
+	if err != nil || len(recommend) != 2 {
+		t.Fatalf("expected 2 recommendations, got %v (%v)", recommend, err)
+	}
+	selectorMatch := []interface{}{map[string]interface{}{
+		"label": "dns",
+		"value": "heavy",
+		"match": []interface{}{map[string]interface{}{
+			"label": "kubernetes.io/os",
+			"value": "linux",
+		}},
+	}}
+	expectedControlPlane := map[string]interface{}{
+		"match": []interface{}{
+			map[string]interface{}{"label": "node-role.kubernetes.io/control-plane", "match": selectorMatch},
+			map[string]interface{}{"label": "node-role.kubernetes.io/master", "match": selectorMatch},
+		},
+		"priority": int64(20),
+		"profile":  "openshift-dns-control-plane",
+	}
+	if !reflect.DeepEqual(recommend[0], expectedControlPlane) {
+		t.Errorf("unexpected control-plane recommendation:\nexpected %#v\ngot %#v", expectedControlPlane, recommend[0])
+	}
+	expectedNode := map[string]interface{}{
+		"match":    selectorMatch,
+		"priority": int64(25),
+		"profile":  "openshift-dns-node",
+	}
+	if !reflect.DeepEqual(recommend[1], expectedNode) {
+		t.Errorf("unexpected node recommendation:\nexpected %#v\ngot %#v", expectedNode, recommend[1])
+	}
+}
+
+// TestNodeTuningEnabled verifies that nodeTuningEnabled parses the node tuning
+// annotation and treats invalid values as false.
+func TestNodeTuningEnabled(t *testing.T) {
+	for value, expected := range map[string]bool{
+		"":      false,
+		"true":  true,
+		"false": false,
+		"bogus": false,
+	} {
+		dns := &operatorv1.DNS{}
+		if len(value) != 0 {
+			dns.Annotations = map[string]string{NodeTuningAnnotation: value}
+		}
+		if actual := nodeTuningEnabled(dns); actual != expected {
+			t.Errorf("expected %t for %q, got %t", expected, value, actual)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	corev1 "k8s.io/api/core/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// addressInUseError is the part of the error of a failed bind that
+	// reports that another process listens on the address.  CoreDNS, the
+	// kubelet's host port manager, and the container runtime all report
+	// a conflicting listener with it.
+	addressInUseError = "address already in use"
+	// freePortsUnschedulableMessage is the part of the message of an
+	// unschedulable pod's PodScheduled condition that reports that the
+	// pod's host ports are used by another pod on the node.
+	freePortsUnschedulableMessage = "didn't have free ports"
+	// failedCreatePodSandBoxReason is the reason of the event that the
+	// kubelet reports when it cannot create a pod's sandbox, for example
+	// because a process on the node listens on one of the pod's host
+	// ports.
+	failedCreatePodSandBoxReason = "FailedCreatePodSandBox"
+
+	// portConflictCheckPeriod is how often the operator checks for port
+	// conflicts while some of a dns's pods are not ready.  Neither the
+	// kubelet's events nor the restarts of a crash-looping container
+	// change the dns daemonset's status, so the operator would not
+	// otherwise notice a conflict that appears after a pod is created.
+	portConflictCheckPeriod = time.Minute
+)
+
+// dnsPortConflicts returns the conflicts that keep the given dns's pods from
+// listening on their ports, ordered by pod.  A pod's own network namespace is
+// created fresh and never has a conflicting listener, so the conflicts are
+// found where they show: in the scheduler's verdict on the pods' host ports, in
+// the kubelet's events for sandboxes whose host ports are taken on the node,
+// and in the termination messages of containers that exit because another
+// container in the pod or, for a pod in the host network, another process on
+// the node listens on their ports.
+func (r *reconciler) dnsPortConflicts(dns *operatorv1.DNS) ([]string, error) {
+	selector, err := metav1.LabelSelectorAsSelector(DNSDaemonSetPodSelector(dns))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build pod selector for dns %s: %w", dns.Name, err)
+	}
+	podList := &corev1.PodList{}
+	listOpts := []client.ListOption{
+		client.MatchingLabelsSelector{Selector: selector},
+		client.InNamespace(DefaultOperandNamespace),
+	}
+	if err := r.cache.List(context.TODO(), podList, listOpts...); err != nil {
+		return nil, fmt.Errorf("failed to list pods for dns %s: %w", dns.Name, err)
+	}
+	// Events are not cached, so list them only if a pod is waiting for
+	// its sandbox.
+	var events []corev1.Event
+	if len(podsWithoutSandbox(podList.Items)) != 0 {
+		eventList := &corev1.EventList{}
+		listOpts := []client.ListOption{
+			client.InNamespace(DefaultOperandNamespace),
+			client.MatchingFields{"reason": failedCreatePodSandBoxReason},
+		}
+		if err := r.client.List(context.TODO(), eventList, listOpts...); err != nil {
+			return nil, fmt.Errorf("failed to list events for dns %s: %w", dns.Name, err)
+		}
+		events = eventList.Items
+	}
+	return podPortConflicts(podList.Items, events), nil
+}
+
+// dnsPodsUnready returns a Boolean value indicating whether any of the given
+// dns's pods is not ready.
+func (r *reconciler) dnsPodsUnready(dns *operatorv1.DNS) (bool, error) {
+	selector, err := metav1.LabelSelectorAsSelector(DNSDaemonSetPodSelector(dns))
+	if err != nil {
+		return false, fmt.Errorf("failed to build pod selector for dns %s: %w", dns.Name, err)
+	}
+	podList := &corev1.PodList{}
+	listOpts := []client.ListOption{
+		client.MatchingLabelsSelector{Selector: selector},
+		client.InNamespace(DefaultOperandNamespace),
+	}
+	if err := r.cache.List(context.TODO(), podList, listOpts...); err != nil {
+		return false, fmt.Errorf("failed to list pods for dns %s: %w", dns.Name, err)
+	}
+	for i := range podList.Items {
+		if !podReady(&podList.Items[i]) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// podsWithoutSandbox returns the names of the given pods that are scheduled to
+// a node but whose containers have not been created.
+func podsWithoutSandbox(pods []corev1.Pod) sets.String {
+	names := sets.NewString()
+	for i := range pods {
+		pod := &pods[i]
+		if pod.Status.Phase != corev1.PodPending || len(pod.Spec.NodeName) == 0 {
+			continue
+		}
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.State.Waiting != nil && status.State.Waiting.Reason == "ContainerCreating" {
+				names.Insert(pod.Name)
+				break
+			}
+		}
+		if len(pod.Status.ContainerStatuses) == 0 {
+			names.Insert(pod.Name)
+		}
+	}
+	return names
+}
+
+// podPortConflicts returns the port conflicts of the given pods that the pods'
+// statuses and the given FailedCreatePodSandBox events report.  Events for
+// pods that have since got their sandboxes are ignored.
+func podPortConflicts(pods []corev1.Pod, events []corev1.Event) []string {
+	waiting := podsWithoutSandbox(pods)
+	sandboxErrors := map[string]string{}
+	for _, event := range events {
+		if event.Reason != failedCreatePodSandBoxReason || event.InvolvedObject.Kind != "Pod" || !waiting.Has(event.InvolvedObject.Name) {
+			continue
+		}
+		if line := addressInUseLine(event.Message); len(line) != 0 {
+			sandboxErrors[event.InvolvedObject.Name] = line
+		}
+	}
+
+	var conflicts []string
+	for i := range pods {
+		pod := &pods[i]
+		where := "pod " + pod.Name
+		if len(pod.Spec.NodeName) != 0 {
+			where += " on node " + pod.Spec.NodeName
+		}
+		for _, cond := range pod.Status.Conditions {
+			if cond.Type == corev1.PodScheduled && cond.Status == corev1.ConditionFalse && strings.Contains(cond.Message, freePortsUnschedulableMessage) {
+				conflicts = append(conflicts, fmt.Sprintf("%s cannot be scheduled because another pod uses its host ports: %s", where, cond.Message))
+			}
+		}
+		if line, ok := sandboxErrors[pod.Name]; ok {
+			conflicts = append(conflicts, fmt.Sprintf("%s cannot start because a process on the node uses its host ports: %s", where, line))
+		}
+		for _, status := range pod.Status.ContainerStatuses {
+			// A container that is ready again has got its ports.
+			if status.Ready {
+				continue
+			}
+			if line := containerAddressInUseLine(status); len(line) != 0 {
+				conflicts = append(conflicts, fmt.Sprintf("%s: container %s cannot listen on its ports: %s", where, status.Name, line))
+			}
+		}
+	}
+	sort.Strings(conflicts)
+	return conflicts
+}
+
+// containerAddressInUseLine returns the line of the termination message of the
+// given container's current or last termination that reports that an address
+// is in use, or the empty string if the container did not exit for that
+// reason.  The dns container falls back to its logs for its termination
+// message, so the message has the error with which CoreDNS exited.
+func containerAddressInUseLine(status corev1.ContainerStatus) string {
+	for _, terminated := range []*corev1.ContainerStateTerminated{status.State.Terminated, status.LastTerminationState.Terminated} {
+		if terminated == nil {
+			continue
+		}
+		if line := addressInUseLine(terminated.Message); len(line) != 0 {
+			return line
+		}
+	}
+	return ""
+}
+
+// addressInUseLine returns the last line of the given message that reports
+// that an address is in use, or the empty string if no line does.
+func addressInUseLine(message string) string {
+	lines := strings.Split(message, "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		if strings.Contains(lines[i], addressInUseError) {
+			return strings.TrimSpace(lines[i])
+		}
+	}
+	return ""
+}
@@ -0,0 +1,96 @@
+package controller
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestPodPortConflicts verifies that podPortConflicts reports the pods whose
+// host ports are taken by another pod or by a process on the node and the
+// containers that exit because their ports are in use, and ignores pods that
+// have recovered.
+func TestPodPortConflicts(t *testing.T) {
+	const bindError = "listen tcp :5353: bind: address already in use"
+	pods := []corev1.Pod{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "dns-default-unschedulable"},
+			Status: corev1.PodStatus{
+				Phase: corev1.PodPending,
+				Conditions: []corev1.PodCondition{{
+					Type:    corev1.PodScheduled,
+					Status:  corev1.ConditionFalse,
+					Reason:  corev1.PodReasonUnschedulable,
+					Message: "0/3 nodes are available: 1 node(s) didn't have free ports for the requested pod ports.",
+				}},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "dns-default-sandbox"},
+			Spec:       corev1.PodSpec{NodeName: "worker-0"},
+			Status: corev1.PodStatus{
+				Phase: corev1.PodPending,
+				ContainerStatuses: []corev1.ContainerStatus{{
+					Name:  "dns",
+					State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ContainerCreating"}},
+				}},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "dns-default-crashing"},
+			Spec:       corev1.PodSpec{NodeName: "worker-1"},
+			Status: corev1.PodStatus{
+				Phase: corev1.PodRunning,
+				ContainerStatuses: []corev1.ContainerStatus{{
+					Name:                 "dns",
+					State:                corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+					LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 1, Message: ".:5353\nCoreDNS-1.8.1\nListen: " + bindError + "\n"}},
+				}},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "dns-default-recovered"},
+			Spec:       corev1.PodSpec{NodeName: "worker-2"},
+			Status: corev1.PodStatus{
+				Phase: corev1.PodRunning,
+				ContainerStatuses: []corev1.ContainerStatus{{
+					Name:                 "dns",
+					Ready:                true,
+					State:                corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+					LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 1, Message: "Listen: " + bindError}},
+				}},
+			},
+		},
+	}
+	sandboxError := "Failed to create pod sandbox: rpc error: code = Unknown desc = cannot open hostport 5354 for pod dns-default-sandbox: listen tcp4 :5354: bind: address already in use"
+	events := []corev1.Event{
+		{
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "dns-default-sandbox"},
+			Reason:         failedCreatePodSandBoxReason,
+			Message:        sandboxError,
+		},
+		{
+			// The pod has since got its sandbox.
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "dns-default-recovered"},
+			Reason:         failedCreatePodSandBoxReason,
+			Message:        sandboxError,
+		},
+	}
+
+	expected := []string{
+		"pod dns-default-crashing on node worker-1: container dns cannot listen on its ports: Listen: " + bindError,
+		"pod dns-default-sandbox on node worker-0 cannot start because a process on the node uses its host ports: " + sandboxError,
+		"pod dns-default-unschedulable cannot be scheduled because another pod uses its host ports: 0/3 nodes are available: 1 node(s) didn't have free ports for the requested pod ports.",
+	}
+	if actual := podPortConflicts(pods, events); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected conflicts:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(actual, "\n"))
+	}
+
+	if actual := podPortConflicts(pods[3:], events); len(actual) != 0 {
+		t.Errorf("expected no conflicts for a recovered pod, got %v", actual)
+	}
+}
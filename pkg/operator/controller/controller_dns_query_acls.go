@@ -0,0 +1,200 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+
+	configv1 "github.com/openshift/api/config/v1"
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// DNSQueryACLsAppliedConditionType is the type of the DNS status condition
+// that reports whether the ACLs in the dns's QueryACLsAnnotation annotation are
+// applied.  The condition is reported only if the dns has the annotation.
+const DNSQueryACLsAppliedConditionType = conditions.TypeQueryACLsApplied
+
+// queryACLActions maps the actions of a query ACL to the actions of CoreDNS's
+// acl plugin.
+var queryACLActions = map[string]string{
+	"refuse": "block",
+	"drop":   "drop",
+}
+
+// loopbackNetworks are the networks from which the dns pods forward the queries
+// of their encrypted listeners to their plain DNS listener.
+var loopbackNetworks = []string{"127.0.0.0/8", "::1/128"}
+
+// queryACLSpec is an entry in the value of a dns's QueryACLsAnnotation
+// annotation.
+type queryACLSpec struct {
+	// Name is the name of the ACL.
+	Name string `json:"name"`
+	// SourceCIDRs are the prefixes of the addresses of the clients to
+	// which the ACL applies.
+	SourceCIDRs []string `json:"sourceCIDRs"`
+	// Action is what CoreDNS does with the clients' queries, "Refuse" or
+	// "Drop".
+	Action string `json:"action"`
+}
+
+// queryACL is a validated queryACLSpec.
+type queryACL struct {
+	// Name is the name of the ACL.
+	Name string
+	// SourceCIDRs are the prefixes of the ACL's clients, in canonical form.
+	SourceCIDRs []string
+	// Action is the action of CoreDNS's acl plugin, "block" or "drop".
+	Action string
+}
+
+// queryACLsEnabled returns a Boolean value indicating whether the given dns has
+// a non-empty QueryACLsAnnotation annotation.
+func queryACLsEnabled(dns *operatorv1.DNS) bool {
+	return len(strings.TrimSpace(dns.Annotations[QueryACLsAnnotation])) != 0
+}
+
+// dnsQueryACLs returns the valid ACLs in the given dns's QueryACLsAnnotation
+// annotation, in the annotation's order, and a status condition that reports
+// the invalid ACLs, or nil and a nil condition if the dns does not have the
+// annotation.  The ACLs are validated against the cluster's network config.
+func (r *reconciler) dnsQueryACLs(dns *operatorv1.DNS) ([]queryACL, *operatorv1.OperatorCondition, error) {
+	if !queryACLsEnabled(dns) {
+		return nil, nil, nil
+	}
+	networkConfig := &configv1.Network{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: "cluster"}, networkConfig); err != nil {
+		return nil, nil, fmt.Errorf("failed to get network 'cluster': %v", err)
+	}
+	var clusterNetworks []string
+	for _, entry := range networkConfig.Status.ClusterNetwork {
+		clusterNetworks = append(clusterNetworks, entry.CIDR)
+	}
+	acls, problems := parseQueryACLs(dns.Annotations[QueryACLsAnnotation], clusterNetworks, networkConfig.Status.ServiceNetwork)
+	return acls, computeQueryACLsAppliedCondition(acls, problems), nil
+}
+
+// computeQueryACLsAppliedCondition returns a status condition that reports the
+// given problems with the ACLs that were ignored.
+func computeQueryACLsAppliedCondition(acls []queryACL, problems []string) *operatorv1.OperatorCondition {
+	condition := &operatorv1.OperatorCondition{
+		Type: DNSQueryACLsAppliedConditionType,
+	}
+	if len(problems) != 0 {
+		condition.Status = operatorv1.ConditionFalse
+		condition.Reason = conditions.ReasonQueryACLsIgnored
+		condition.Message = fmt.Sprintf("Some query ACLs were ignored: %s.", strings.Join(problems, "; "))
+	} else {
+		condition.Status = operatorv1.ConditionTrue
+		condition.Reason = conditions.ReasonAsExpected
+		condition.Message = fmt.Sprintf("All %d query ACLs are applied.", len(acls))
+	}
+	return condition
+}
+
+// parseQueryACLs parses the given value of a QueryACLsAnnotation annotation and
+// returns the valid ACLs, in order, and the problems with the invalid ACLs,
+// which are ignored.  An ACL is invalid if its name is not a valid label or is
+// another ACL's name, if its action is not valid, or if it has no source CIDRs
+// or a source CIDR that is invalid, that contains one of the given cluster
+// networks, or that overlaps one of the given service networks or the loopback
+// networks.  Invalid cluster and service networks are ignored.
+func parseQueryACLs(value string, clusterNetworks, serviceNetworks []string) ([]queryACL, []string) {
+	var specs []queryACLSpec
+	if err := json.Unmarshal([]byte(value), &specs); err != nil {
+		return nil, []string{fmt.Sprintf("the annotation is not a JSON list of query ACLs: %v", err)}
+	}
+	var (
+		acls            []queryACL
+		problems        []string
+		seen            = sets.NewString()
+		clusterPrefixes = parseCIDRs(clusterNetworks)
+		servicePrefixes = parseCIDRs(serviceNetworks)
+	)
+	for i, spec := range specs {
+		acl, err := parseQueryACL(spec, clusterPrefixes, servicePrefixes)
+		switch {
+		case len(spec.Name) == 0:
+			problems = append(problems, fmt.Sprintf("entry %d: name must be specified", i))
+		case len(validation.IsDNS1123Label(spec.Name)) != 0:
+			problems = append(problems, fmt.Sprintf("query ACL %s: the name is not a valid label", spec.Name))
+		case seen.Has(spec.Name):
+			problems = append(problems, fmt.Sprintf("query ACL %s: another entry has the name", spec.Name))
+		case err != nil:
+			problems = append(problems, fmt.Sprintf("query ACL %s: %v", spec.Name, err))
+		default:
+			seen.Insert(spec.Name)
+			acls = append(acls, acl)
+		}
+	}
+	return acls, problems
+}
+
+// parseQueryACL validates the given ACL, whose name is not validated, against
+// the given cluster and service networks and returns it in canonical form.
+func parseQueryACL(spec queryACLSpec, clusterNetworks, serviceNetworks []*net.IPNet) (queryACL, error) {
+	acl := queryACL{Name: spec.Name}
+	action, ok := queryACLActions[strings.ToLower(spec.Action)]
+	if !ok {
+		return acl, fmt.Errorf("action %q is not one of Drop, Refuse", spec.Action)
+	}
+	acl.Action = action
+	if len(spec.SourceCIDRs) == 0 {
+		return acl, fmt.Errorf("sourceCIDRs must be specified")
+	}
+	for _, cidr := range spec.SourceCIDRs {
+		_, prefix, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return acl, fmt.Errorf("source CIDR %q is not valid", cidr)
+		}
+		for _, network := range clusterNetworks {
+			if cidrContains(prefix, network) {
+				return acl, fmt.Errorf("source CIDR %s contains the cluster network %s, so every pod's queries would be refused", cidr, network)
+			}
+		}
+		for _, network := range serviceNetworks {
+			if cidrsOverlap(prefix, network) {
+				return acl, fmt.Errorf("source CIDR %s overlaps the service network %s", cidr, network)
+			}
+		}
+		for _, network := range parseCIDRs(loopbackNetworks) {
+			if cidrsOverlap(prefix, network) {
+				return acl, fmt.Errorf("source CIDR %s overlaps the loopback network %s", cidr, network)
+			}
+		}
+		acl.SourceCIDRs = append(acl.SourceCIDRs, prefix.String())
+	}
+	return acl, nil
+}
+
+// parseCIDRs returns the valid CIDRs among the given CIDRs.
+func parseCIDRs(cidrs []string) []*net.IPNet {
+	var prefixes []*net.IPNet
+	for _, cidr := range cidrs {
+		if _, prefix, err := net.ParseCIDR(cidr); err == nil {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	return prefixes
+}
+
+// cidrContains returns a Boolean value indicating whether the first of the
+// given prefixes contains every address of the second.
+func cidrContains(outer, inner *net.IPNet) bool {
+	outerOnes, outerBits := outer.Mask.Size()
+	innerOnes, innerBits := inner.Mask.Size()
+	return outerBits == innerBits && outerOnes <= innerOnes && outer.Contains(inner.IP)
+}
+
+// cidrsOverlap returns a Boolean value indicating whether the given prefixes
+// have an address in common.
+func cidrsOverlap(a, b *net.IPNet) bool {
+	return cidrContains(a, b) || cidrContains(b, a)
+}
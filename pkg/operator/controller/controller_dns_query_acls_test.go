@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestParseQueryACLs verifies that parseQueryACLs keeps the annotation's order
+// and ignores ACLs with invalid names, actions, or CIDRs and ACLs that cover a
+// cluster network or overlap the service or loopback networks.
+func TestParseQueryACLs(t *testing.T) {
+	value := `[
+		{"name": "legacy-hosts", "sourceCIDRs": ["10.0.32.1/20", "fd00:1::/64"], "action": "Drop"},
+		{"name": "lab-pods", "sourceCIDRs": ["10.128.4.0/23"], "action": "refuse"},
+		{"name": "legacy-hosts", "sourceCIDRs": ["10.0.48.0/20"], "action": "Drop"},
+		{"sourceCIDRs": ["10.0.48.0/20"], "action": "Drop"},
+		{"name": "Bad_Name", "sourceCIDRs": ["10.0.48.0/20"], "action": "Drop"},
+		{"name": "allow", "sourceCIDRs": ["10.0.48.0/20"], "action": "Allow"},
+		{"name": "no-cidrs", "action": "Drop"},
+		{"name": "bad-cidr", "sourceCIDRs": ["10.0.48.0"], "action": "Drop"},
+		{"name": "all-pods", "sourceCIDRs": ["10.0.0.0/8"], "action": "Refuse"},
+		{"name": "services", "sourceCIDRs": ["172.30.1.0/24"], "action": "Refuse"},
+		{"name": "loopback", "sourceCIDRs": ["127.0.0.0/16"], "action": "Drop"}
+	]`
+	acls, problems := parseQueryACLs(value, []string{"10.128.0.0/14", "fd01::/48"}, []string{"172.30.0.0/16", "invalid"})
+	expected := []queryACL{
+		{Name: "legacy-hosts", SourceCIDRs: []string{"10.0.32.0/20", "fd00:1::/64"}, Action: "drop"},
+		{Name: "lab-pods", SourceCIDRs: []string{"10.128.4.0/23"}, Action: "block"},
+	}
+	if !reflect.DeepEqual(acls, expected) {
+		t.Errorf("expected %#v, got %#v", expected, acls)
+	}
+	expectedProblems := []string{
+		"query ACL legacy-hosts: another entry has the name",
+		"entry 3: name must be specified",
+		"query ACL Bad_Name: the name is not a valid label",
+		`query ACL allow: action "Allow" is not one of Drop, Refuse`,
+		"query ACL no-cidrs: sourceCIDRs must be specified",
+		`query ACL bad-cidr: source CIDR "10.0.48.0" is not valid`,
+		"query ACL all-pods: source CIDR 10.0.0.0/8 contains the cluster network 10.128.0.0/14, so every pod's queries would be refused",
+		"query ACL services: source CIDR 172.30.1.0/24 overlaps the service network 172.30.0.0/16",
+		"query ACL loopback: source CIDR 127.0.0.0/16 overlaps the loopback network 127.0.0.0/8",
+	}
+	if !reflect.DeepEqual(problems, expectedProblems) {
+		t.Errorf("expected problems %q, got %q", expectedProblems, problems)
+	}
+}
+
+// TestDesiredDNSConfigMapQueryACLs verifies that every server block has the
+// acl plugin with the ACLs in order.
+func TestDesiredDNSConfigMapQueryACLs(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DefaultDNSController,
+		},
+	}
+	servers := []operatorv1.Server{
+		{Name: "corp", Zones: []string{"corp.example.com"}, ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"10.1.0.53"}}},
+	}
+	acls := []queryACL{
+		{Name: "legacy-hosts", SourceCIDRs: []string{"10.0.32.0/20", "fd00:1::/64"}, Action: "drop"},
+		{Name: "lab-pods", SourceCIDRs: []string{"10.128.4.0/23"}, Action: "block"},
+	}
+	cm, err := desiredDNSConfigMap(dns, corefileInputs{Servers: servers, QueryACLs: acls, ClusterDomain: "cluster.local", MetricsAddress: "127.0.0.1:9153"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	acl := `
+    acl {
+        drop net 10.0.32.0/20 fd00:1::/64
+        block net 10.128.4.0/23
+    }
+`
+	corefile := cm.Data["Corefile"]
+	for _, header := range []string{"corp.example.com:5353 {", ".:5353 {"} {
+		if !strings.Contains(corefile, header+acl) {
+			t.Errorf("expected server block %q to start with the acl plugin, got Corefile:\n%s", header, corefile)
+		}
+	}
+
+	cm, err = desiredDNSConfigMap(dns, corefileInputs{Servers: servers, ClusterDomain: "cluster.local", MetricsAddress: "127.0.0.1:9153"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(cm.Data["Corefile"], "acl") {
+		t.Errorf("expected no acl plugin without query ACLs, got Corefile:\n%s", cm.Data["Corefile"])
+	}
+}
@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"strings"
+	"time"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Query privacy modes, which are the values of the QueryPrivacyAnnotation
+// annotation.
+const (
+	// queryPrivacyHash replaces query names in metric labels with hashes,
+	// which keeps distinct names distinct without revealing them.
+	queryPrivacyHash = "hash"
+	// queryPrivacyTruncate replaces query names in metric labels with
+	// their last two labels, which keeps the domain for troubleshooting
+	// and bounds the labels' cardinality.
+	queryPrivacyTruncate = "truncate"
+)
+
+const (
+	// errorConsolidationPeriod is the period over which CoreDNS counts the
+	// errors of each kind when the dns enables query privacy.
+	errorConsolidationPeriod = time.Minute
+	// queryNameHashModulus is the modulus of the hashes that replace query
+	// names in metric labels.  It is large enough that distinct names
+	// seldom collide and small enough that the hashes cannot be reversed
+	// by hashing candidate names.
+	queryNameHashModulus = 1 << 20
+	// queryNameHashLabel is the temporary label in which prometheus stores
+	// a query name's hash before it replaces the name.
+	queryNameHashLabel = "__tmp_query_name_hash"
+)
+
+// queryNameMetricLabels are the labels of CoreDNS metrics whose values are
+// query names.  The metrics that the operator configures do not have these
+// labels, but debug plugins in a custom Corefile, which count queries by
+// name, do.
+var queryNameMetricLabels = []string{"name", "qname"}
+
+// errorConsolidation is a kind of error that CoreDNS's errors plugin counts,
+// and logs once per period, rather than logging each error.
+type errorConsolidation struct {
+	// Period is the period over which the errors are counted.
+	Period time.Duration
+	// Pattern is a regular expression that matches the errors' messages.
+	Pattern string
+}
+
+// queryPrivacyMode returns the query privacy mode in the given dns's
+// QueryPrivacyAnnotation annotation, or the empty string if the dns does not
+// enable query privacy.  An invalid mode is logged and ignored.
+func queryPrivacyMode(dns *operatorv1.DNS) string {
+	value, ok := dns.Annotations[QueryPrivacyAnnotation]
+	if !ok {
+		return ""
+	}
+	switch mode := strings.TrimSpace(value); mode {
+	case queryPrivacyHash, queryPrivacyTruncate:
+		return mode
+	default:
+		logrus.Warningf("ignoring query privacy mode %q in annotation %s on dns %s: the mode must be %s or %s", value, QueryPrivacyAnnotation, dns.Name, queryPrivacyHash, queryPrivacyTruncate)
+		return ""
+	}
+}
+
+// errorConsolidations returns the kinds of errors that CoreDNS should count
+// rather than log for the given query privacy mode.  CoreDNS logs each error
+// with its query name and cannot rewrite the name, so with query privacy every
+// error is counted: the common kinds of upstream failures separately, so that
+// their counts remain useful for troubleshooting, and the rest together.
+// CoreDNS uses the first pattern that matches an error.
+func errorConsolidations(mode string) []errorConsolidation {
+	if len(mode) == 0 {
+		return nil
+	}
+	var consolidations []errorConsolidation
+	for _, pattern := range []string{
+		"^.* i/o timeout$",
+		"^.* connection refused$",
+		"^.* no route to host$",
+		"^.*$",
+	} {
+		consolidations = append(consolidations, errorConsolidation{Period: errorConsolidationPeriod, Pattern: pattern})
+	}
+	return consolidations
+}
+
+// queryPrivacyMetricRelabelings returns the metric relabelings with which
+// prometheus replaces the query names in the labels of the metrics that it
+// scrapes from the dns pods for the given query privacy mode.  Prometheus
+// anchors the regular expressions, and it leaves a label alone if its
+// expression does not match, so series without the label do not gain it.
+func queryPrivacyMetricRelabelings(mode string) []interface{} {
+	var relabelings []interface{}
+	for _, label := range queryNameMetricLabels {
+		switch mode {
+		case queryPrivacyHash:
+			relabelings = append(relabelings,
+				map[string]interface{}{
+					"sourceLabels": []interface{}{label},
+					"targetLabel":  queryNameHashLabel,
+					"modulus":      int64(queryNameHashModulus),
+					"action":       "hashmod",
+				},
+				map[string]interface{}{
+					"sourceLabels": []interface{}{label, queryNameHashLabel},
+					"separator":    ";",
+					"regex":        "(.+);(.+)",
+					"targetLabel":  label,
+					"replacement":  "hash-$2",
+					"action":       "replace",
+				},
+				map[string]interface{}{
+					"regex":  queryNameHashLabel,
+					"action": "labeldrop",
+				},
+			)
+		case queryPrivacyTruncate:
+			relabelings = append(relabelings, map[string]interface{}{
+				"sourceLabels": []interface{}{label},
+				"regex":        `.+\.([^.]+\.[^.]+\.?)`,
+				"targetLabel":  label,
+				"replacement":  "*.$1",
+				"action":       "replace",
+			})
+		}
+	}
+	return relabelings
+}
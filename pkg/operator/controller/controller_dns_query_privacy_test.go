@@ -0,0 +1,135 @@
+package controller
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	corev1 "k8s.io/api/core/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// TestQueryPrivacyMode verifies that queryPrivacyMode accepts only the known
+// query privacy modes.
+func TestQueryPrivacyMode(t *testing.T) {
+	testCases := []struct {
+		annotations map[string]string
+		expected    string
+	}{
+		{nil, ""},
+		{map[string]string{QueryPrivacyAnnotation: "hash"}, "hash"},
+		{map[string]string{QueryPrivacyAnnotation: " truncate "}, "truncate"},
+		{map[string]string{QueryPrivacyAnnotation: "redact"}, ""},
+	}
+	for _, tc := range testCases {
+		dns := &operatorv1.DNS{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        DefaultDNSController,
+				Annotations: tc.annotations,
+			},
+		}
+		if actual := queryPrivacyMode(dns); actual != tc.expected {
+			t.Errorf("%v: expected %q, got %q", tc.annotations, tc.expected, actual)
+		}
+	}
+}
+
+// TestDesiredDNSConfigMapQueryPrivacy verifies that every server block
+// consolidates errors if the dns enables query privacy.
+func TestDesiredDNSConfigMapQueryPrivacy(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        DefaultDNSController,
+			Annotations: map[string]string{QueryPrivacyAnnotation: "hash"},
+		},
+	}
+	servers := []operatorv1.Server{{
+		Name:          "foo",
+		Zones:         []string{"foo.com"},
+		ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"1.1.1.1"}},
+	}}
+	cm, err := desiredDNSConfigMap(dns, corefileInputs{Servers: servers, ClusterDomain: "cluster.local", MetricsAddress: "127.0.0.1:9153"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `
+    errors {
+        consolidate 1m0s "^.* i/o timeout$"
+        consolidate 1m0s "^.* connection refused$"
+        consolidate 1m0s "^.* no route to host$"
+        consolidate 1m0s "^.*$"
+    }
+`
+	if n := strings.Count(cm.Data["Corefile"], expected); n != 2 {
+		t.Errorf("expected 2 server blocks to consolidate errors, got %d:\n%s", n, cm.Data["Corefile"])
+	}
+
+	delete(dns.Annotations, QueryPrivacyAnnotation)
+	if cm, err := desiredDNSConfigMap(dns, corefileInputs{Servers: servers, ClusterDomain: "cluster.local", MetricsAddress: "127.0.0.1:9153"}); err != nil {
+		t.Fatal(err)
+	} else if strings.Contains(cm.Data["Corefile"], "consolidate") {
+		t.Errorf("expected no error consolidation without query privacy, got:\n%s", cm.Data["Corefile"])
+	}
+}
+
+// TestDesiredServiceMonitorQueryPrivacy verifies that prometheus replaces query
+// names in metric labels if the dns enables query privacy.
+func TestDesiredServiceMonitorQueryPrivacy(t *testing.T) {
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "openshift-dns",
+			Name:      "dns-default",
+		},
+	}
+	relabelings := func(mode string) []interface{} {
+		dns := &operatorv1.DNS{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        DefaultDNSController,
+				Annotations: map[string]string{QueryPrivacyAnnotation: mode},
+			},
+		}
+		sm := desiredServiceMonitor(dns, svc, metav1.OwnerReference{}, "", "")
+		endpoint := sm.Object["spec"].(map[string]interface{})["endpoints"].([]interface{})[0].(map[string]interface{})
+		if _, ok := endpoint["metricRelabelings"]; !ok {
+			return nil
+		}
+		// The servicemonitor must be convertible to JSON.
+		if _, err := (&unstructured.Unstructured{Object: sm.Object}).MarshalJSON(); err != nil {
+			t.Fatal(err)
+		}
+		return endpoint["metricRelabelings"].([]interface{})
+	}
+
+	if r := relabelings(""); r != nil {
+		t.Errorf("expected no metric relabelings without query privacy, got %#v", r)
+	}
+	if r := relabelings("hash"); len(r) != 3*len(queryNameMetricLabels) {
+		t.Errorf("expected 3 metric relabelings per query name label, got %#v", r)
+	}
+
+	r := relabelings("truncate")
+	if len(r) != len(queryNameMetricLabels) {
+		t.Fatalf("expected 1 metric relabeling per query name label, got %#v", r)
+	}
+	relabeling := r[0].(map[string]interface{})
+	// Prometheus anchors relabeling regular expressions at both ends.
+	re := regexp.MustCompile("^(?:" + relabeling["regex"].(string) + ")$")
+	replacement := strings.ReplaceAll(relabeling["replacement"].(string), "$1", "${1}")
+	for name, expected := range map[string]string{
+		"www.alice.example.com.": "*.example.com.",
+		"example.com.":           "example.com.",
+		"com.":                   "com.",
+	} {
+		actual := name
+		if re.MatchString(name) {
+			actual = re.ReplaceAllString(name, replacement)
+		}
+		if actual != expected {
+			t.Errorf("expected %q to be truncated to %q, got %q", name, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// DNSOverQUICUpstreamsSupportedConditionType is the type of the DNS
+	// status condition that indicates whether the dns's coredns image can
+	// forward queries to DNS-over-QUIC upstreams.  The condition is
+	// reported only if a server has a quic upstream.
+	DNSOverQUICUpstreamsSupportedConditionType = conditions.TypeDNSOverQUICUpstreamsSupported
+
+	// dnsOverQUICScheme is the prefix of a DNS-over-QUIC upstream, which
+	// is how CoreDNS's forward plugin denotes the transport.
+	dnsOverQUICScheme = "quic://"
+	// dnsOverQUICDefaultPort is the port of a DNS-over-QUIC upstream that
+	// specifies none, which RFC 9250 assigns.
+	dnsOverQUICDefaultPort = "853"
+	// quicSupportedMessage is the termination message of the QUIC check
+	// pod if CoreDNS accepts a quic upstream.
+	quicSupportedMessage = "supported"
+)
+
+// quicCheckScript starts CoreDNS with a Corefile that forwards to a quic
+// upstream.  CoreDNS exits immediately if its forward plugin does not support
+// the transport, so if it is still running after a few seconds, it supports
+// the transport.  The script writes quicSupportedMessage or CoreDNS's error
+// to the termination message.
+const quicCheckScript = `cat > /tmp/Corefile <<'EOF'
+.:5353 {
+    forward . quic://127.0.0.1:853
+}
+EOF
+timeout 5 coredns -conf /tmp/Corefile > /tmp/coredns.log 2>&1
+if [ $? -eq 124 ]; then
+  echo ` + quicSupportedMessage + ` > /dev/termination-log
+else
+  tail -c 1024 /tmp/coredns.log > /dev/termination-log
+fi
+`
+
+// isDoQUpstream returns a Boolean value indicating whether the given upstream
+// is a DNS-over-QUIC upstream.
+func isDoQUpstream(upstream string) bool {
+	return strings.HasPrefix(strings.ToLower(upstream), dnsOverQUICScheme)
+}
+
+// normalizeDoQUpstream returns the given DNS-over-QUIC upstream as
+// "quic://<address>:<port>", with the default port if the upstream has none,
+// and a Boolean value indicating whether the upstream is a quic scheme
+// followed by a valid address or address and port.
+func normalizeDoQUpstream(upstream string) (string, bool) {
+	return normalizeSchemeUpstream(upstream, dnsOverQUICScheme, dnsOverQUICDefaultPort)
+}
+
+// serversUseDoQUpstreams returns a Boolean value indicating whether any of the
+// given servers has a DNS-over-QUIC upstream.
+func serversUseDoQUpstreams(servers []operatorv1.Server) bool {
+	for _, server := range servers {
+		for _, upstream := range server.ForwardPlugin.Upstreams {
+			if isDoQUpstream(upstream) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// withoutDoQUpstreams returns copies of the given servers without their
+// DNS-over-QUIC upstreams.  A server whose upstreams are all omitted is itself
+// omitted.
+func withoutDoQUpstreams(servers []operatorv1.Server) []operatorv1.Server {
+	var result []operatorv1.Server
+	for _, server := range servers {
+		var upstreams []string
+		for _, upstream := range server.ForwardPlugin.Upstreams {
+			if !isDoQUpstream(upstream) {
+				upstreams = append(upstreams, upstream)
+			}
+		}
+		if len(upstreams) == 0 {
+			continue
+		}
+		s := *server.DeepCopy()
+		s.ForwardPlugin.Upstreams = upstreams
+		result = append(result, s)
+	}
+	return result
+}
+
+// desiredQUICCheckPod returns a pod that checks whether the given coredns image
+// can forward queries over QUIC.  The pod has the same service account and node
+// placement as the dns daemonset.
+func desiredQUICCheckPod(dns *operatorv1.DNS, image string) *corev1.Pod {
+	name := DNSQUICCheckPodName(dns)
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name.Name,
+			Namespace:       name.Namespace,
+			OwnerReferences: []metav1.OwnerReference{dnsOwnerRef(dns)},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name:                     "dns",
+				Image:                    image,
+				ImagePullPolicy:          corev1.PullIfNotPresent,
+				Command:                  []string{"/bin/sh", "-c", quicCheckScript},
+				TerminationMessagePolicy: corev1.TerminationMessageReadFile,
+			}},
+			NodeSelector:       nodeSelectorForDNS(dns),
+			PriorityClassName:  "system-node-critical",
+			RestartPolicy:      corev1.RestartPolicyNever,
+			ServiceAccountName: "dns",
+			Tolerations:        tolerationsForDNS(dns),
+		},
+	}
+}
+
+// ensureDoQUpstreams checks whether the given coredns image can forward
+// queries to the DNS-over-QUIC upstreams of the given servers.  The check uses
+// a pod that starts CoreDNS with a quic upstream; the completed pod is kept
+// so that the result is available until the image changes.  Returns the
+// servers, without their quic upstreams unless the image is known to support
+// them, and a status condition that describes the check, or nil if no server
+// has a quic upstream.
+func (r *reconciler) ensureDoQUpstreams(dns *operatorv1.DNS, servers []operatorv1.Server, image string) ([]operatorv1.Server, *operatorv1.OperatorCondition, error) {
+	if !serversUseDoQUpstreams(servers) {
+		if err := r.deleteQUICCheckPod(dns); err != nil {
+			return servers, nil, err
+		}
+		return servers, nil, nil
+	}
+	condition := &operatorv1.OperatorCondition{
+		Type: DNSOverQUICUpstreamsSupportedConditionType,
+	}
+	pod, err := r.ensureQUICCheckPod(dns, desiredQUICCheckPod(dns, image))
+	if err != nil {
+		return withoutDoQUpstreams(servers), condition, err
+	}
+	unsupported, finished, failure := quicCheckPodResult(pod)
+	switch {
+	case len(failure) != 0:
+		condition.Status = operatorv1.ConditionFalse
+		condition.Reason = conditions.ReasonQUICCheckFailed
+		condition.Message = fmt.Sprintf("Failed to check whether coredns image %q supports DNS-over-QUIC upstreams, so they are omitted: %s", image, failure)
+		return withoutDoQUpstreams(servers), condition, nil
+	case !finished:
+		condition.Status = operatorv1.ConditionUnknown
+		condition.Reason = conditions.ReasonCheckingQUICSupport
+		condition.Message = fmt.Sprintf("Checking whether coredns image %q supports DNS-over-QUIC upstreams; they are omitted until the check completes.", image)
+		return withoutDoQUpstreams(servers), condition, nil
+	case len(unsupported) != 0:
+		condition.Status = operatorv1.ConditionFalse
+		condition.Reason = conditions.ReasonQUICUnsupported
+		condition.Message = fmt.Sprintf("Coredns image %q cannot forward queries over QUIC, so DNS-over-QUIC upstreams are omitted: %s", image, unsupported)
+		logrus.Warningf("omitting DNS-over-QUIC upstreams for dns %s: coredns image %q does not support them", dns.Name, image)
+		return withoutDoQUpstreams(servers), condition, nil
+	}
+	condition.Status = operatorv1.ConditionTrue
+	condition.Reason = conditions.ReasonAsExpected
+	condition.Message = fmt.Sprintf("Coredns image %q supports DNS-over-QUIC upstreams.", image)
+	return servers, condition, nil
+}
+
+// ensureQUICCheckPod ensures that the QUIC check pod exists and uses the
+// desired image, recreating it if the desired image has changed.
+func (r *reconciler) ensureQUICCheckPod(dns *operatorv1.DNS, desired *corev1.Pod) (*corev1.Pod, error) {
+	current := &corev1.Pod{}
+	name := DNSQUICCheckPodName(dns)
+	if err := r.client.Get(context.TODO(), name, current); err != nil {
+		if !errors.IsNotFound(err) {
+			return nil, fmt.Errorf("failed to get quic check pod %s: %w", name, err)
+		}
+		if err := r.client.Create(context.TODO(), desired); err != nil {
+			return nil, fmt.Errorf("failed to create quic check pod %s: %w", name, err)
+		}
+		logrus.Infof("created quic check pod %s", name)
+		return desired, nil
+	}
+	if podImagesMatch(current, desired) {
+		return current, nil
+	}
+	if err := r.client.Delete(context.TODO(), current); err != nil && !errors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to delete stale quic check pod %s: %w", name, err)
+	}
+	logrus.Infof("deleted stale quic check pod %s", name)
+	// Return the desired pod, which has an empty status; the pod is
+	// recreated on a subsequent reconciliation once the deletion is
+	// observed.
+	return desired, nil
+}
+
+// deleteQUICCheckPod deletes the QUIC check pod for the given dns if it exists.
+func (r *reconciler) deleteQUICCheckPod(dns *operatorv1.DNS) error {
+	name := DNSQUICCheckPodName(dns)
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name.Name,
+			Namespace: name.Namespace,
+		},
+	}
+	if err := r.client.Delete(context.TODO(), pod); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to delete quic check pod %s: %w", name, err)
+	}
+	logrus.Infof("deleted quic check pod %s", name)
+	return nil
+}
+
+// quicCheckPodResult inspects the given QUIC check pod and returns CoreDNS's
+// error if the image does not support quic upstreams, or the empty string if
+// it does; a Boolean value indicating whether the check has finished; and a
+// message describing the failure if the pod failed or its image cannot be
+// pulled.
+func quicCheckPodResult(pod *corev1.Pod) (string, bool, string) {
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.State.Waiting != nil && imagePullFailureReasons[cs.State.Waiting.Reason] {
+			return "", false, fmt.Sprintf("image %q cannot be pulled: %s: %s", cs.Image, cs.State.Waiting.Reason, cs.State.Waiting.Message)
+		}
+		terminated := cs.State.Terminated
+		if terminated == nil {
+			continue
+		}
+		if terminated.ExitCode != 0 {
+			return "", false, fmt.Sprintf("the pod exited with code %d: %s", terminated.ExitCode, terminated.Message)
+		}
+		message := strings.TrimSpace(terminated.Message)
+		if message == quicSupportedMessage {
+			return "", true, ""
+		}
+		if len(message) == 0 {
+			message = "coredns exited without an error message"
+		}
+		return message, true, ""
+	}
+	return "", false, ""
+}
@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// TestNormalizeDoQUpstream verifies that normalizeDoQUpstream accepts only a
+// quic scheme followed by an address or address and port.
+func TestNormalizeDoQUpstream(t *testing.T) {
+	testCases := []struct {
+		upstream string
+		expected string
+		valid    bool
+	}{
+		{"quic://1.1.1.1", "quic://1.1.1.1:853", true},
+		{"QUIC://1.1.1.1:8853", "quic://1.1.1.1:8853", true},
+		{"quic://[2001:db8::1]:853", "quic://[2001:db8::1]:853", true},
+		{"quic://2001:db8::1", "quic://[2001:db8::1]:853", true},
+		{"quic://dns.example.com", "", false},
+		{"quic://1.1.1.1:0", "", false},
+		{"quic://", "", false},
+	}
+	for _, tc := range testCases {
+		actual, valid := normalizeDoQUpstream(tc.upstream)
+		if valid != tc.valid || actual != tc.expected {
+			t.Errorf("%q: expected (%q, %t), got (%q, %t)", tc.upstream, tc.expected, tc.valid, actual, valid)
+		}
+	}
+}
+
+// TestDoQUpstreams verifies that validUpstreamServers keeps quic upstreams,
+// that loopFreeServers detects quic upstreams that forward to cluster DNS, and
+// that withoutDoQUpstreams omits quic upstreams and servers that have only
+// quic upstreams.
+func TestDoQUpstreams(t *testing.T) {
+	servers := []operatorv1.Server{
+		{
+			Name:          "foo",
+			Zones:         []string{"foo.com"},
+			ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"quic://1.1.1.1", "quic://1.1.1.1:853", "8.8.8.8"}},
+		},
+		{
+			Name:          "bar",
+			Zones:         []string{"bar.com"},
+			ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"quic://9.9.9.9:853", "quic://172.30.0.10"}},
+		},
+	}
+	valid, _ := validUpstreamServers(servers, sets.NewString())
+	if len(valid) != 2 || !reflect.DeepEqual(valid[0].ForwardPlugin.Upstreams, []string{"quic://1.1.1.1", "8.8.8.8"}) {
+		t.Fatalf("expected server foo to keep its first quic upstream, got %#v", valid)
+	}
+	valid, condition := loopFreeServers(valid, "cluster.local", sets.NewString("172.30.0.10"))
+	if condition.Status != operatorv1.ConditionFalse || !reflect.DeepEqual(valid[1].ForwardPlugin.Upstreams, []string{"quic://9.9.9.9:853"}) {
+		t.Errorf("expected the quic upstream that forwards to cluster DNS to be omitted, got %#v and %q", valid, condition.Message)
+	}
+	if !serversUseDoQUpstreams(valid) {
+		t.Errorf("expected the servers to use quic upstreams")
+	}
+	without := withoutDoQUpstreams(valid)
+	if len(without) != 1 || without[0].Name != "foo" || !reflect.DeepEqual(without[0].ForwardPlugin.Upstreams, []string{"8.8.8.8"}) {
+		t.Errorf("expected only server foo with upstream 8.8.8.8, got %#v", without)
+	}
+	if serversUseDoQUpstreams(without) {
+		t.Errorf("expected no quic upstreams, got %#v", without)
+	}
+}
+
+// TestQUICCheckPodResult verifies that quicCheckPodResult reports whether the
+// coredns image supports quic upstreams and reports failures.
+func TestQUICCheckPodResult(t *testing.T) {
+	pod := func(state corev1.ContainerState) *corev1.Pod {
+		return &corev1.Pod{
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{{Name: "dns", State: state}},
+			},
+		}
+	}
+	terminated := func(exitCode int32, message string) *corev1.Pod {
+		return pod(corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: exitCode, Message: message}})
+	}
+	testCases := []struct {
+		description       string
+		pod               *corev1.Pod
+		expectUnsupported string
+		expectFinished    bool
+		expectFailure     bool
+	}{
+		{
+			description: "no status",
+			pod:         &corev1.Pod{},
+		},
+		{
+			description: "running",
+			pod:         pod(corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}),
+		},
+		{
+			description:   "image pull failure",
+			pod:           pod(corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ErrImagePull"}}),
+			expectFailure: true,
+		},
+		{
+			description:   "script failure",
+			pod:           terminated(1, ""),
+			expectFailure: true,
+		},
+		{
+			description:    "supported",
+			pod:            terminated(0, "supported\n"),
+			expectFinished: true,
+		},
+		{
+			description:       "unsupported",
+			pod:               terminated(0, "plugin/forward: unsupported transport\n"),
+			expectUnsupported: "plugin/forward: unsupported transport",
+			expectFinished:    true,
+		},
+	}
+	for _, tc := range testCases {
+		unsupported, finished, failure := quicCheckPodResult(tc.pod)
+		if unsupported != tc.expectUnsupported || finished != tc.expectFinished || (len(failure) != 0) != tc.expectFailure {
+			t.Errorf("%s: expected (%q, %t, failure %t), got (%q, %t, %q)", tc.description, tc.expectUnsupported, tc.expectFinished, tc.expectFailure, unsupported, finished, failure)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// DNSRewriteRulesAppliedConditionType is the type of the DNS status
+	// condition that reports the rewrite rules that the dns applies and
+	// the rules that it ignores.  The condition is reported only if the
+	// dns's rewrite rules configmap exists.
+	DNSRewriteRulesAppliedConditionType = conditions.TypeRewriteRulesApplied
+
+	// rewriteRulesKey is the key of the rules in the rewrite rules
+	// configmap.
+	rewriteRulesKey = "rules"
+)
+
+// rewriteRule is a rule for CoreDNS's rewrite plugin that rewrites the names in
+// queries.  Names are lowercase and fully qualified.
+type rewriteRule struct {
+	// Match is "exact" if the rule rewrites the name From to the name To,
+	// or "suffix" if it rewrites names that end in From to end in To.
+	Match string
+	From  string
+	To    string
+}
+
+// dnsRewriteRules returns the valid rewrite rules in the given dns's rewrite
+// rules configmap and a status condition that reports them, or nil and a nil
+// condition if the configmap does not exist.  Rules must not rewrite names in
+// the cluster domain or in the zones that the given servers and IdM DNS
+// servers serve, because the default server block, which applies the rules,
+// does not serve those names.
+func (r *reconciler) dnsRewriteRules(dns *operatorv1.DNS, servers []operatorv1.Server, idmResolvers []idmResolver, clusterDomain string) ([]rewriteRule, *operatorv1.OperatorCondition, error) {
+	cm := &corev1.ConfigMap{}
+	name := DNSRewriteRulesConfigMapName(dns)
+	if err := r.cache.Get(context.TODO(), name, cm); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil, nil
+		}
+		return nil, nil, fmt.Errorf("failed to get rewrite rules configmap %s/%s: %w", name.Namespace, name.Name, err)
+	}
+
+	rules, problems := parseRewriteRules(cm.Data[rewriteRulesKey], clusterDomain, otherServerZones(servers, idmResolvers))
+	condition := computeRewriteRulesAppliedCondition(types.NamespacedName{Namespace: cm.Namespace, Name: cm.Name}, rules, problems)
+	return rules, &condition, nil
+}
+
+// parseRewriteRules parses the given rewrite rules, one per line, and returns
+// the valid rules and the problems with the invalid rules, which are ignored.
+// Each rule has the form "<match> <from> <to>", where <match> is "exact" or
+// "suffix".  Blank lines and lines that start with "#" are ignored.  A rule is
+// invalid if <from> is in the given cluster domain or in one of the given
+// zones, which are normalized with normalizeZone, or, for a suffix rule, if
+// <from> is a suffix of the cluster domain.
+func parseRewriteRules(data, clusterDomain string, zones sets.String) ([]rewriteRule, []string) {
+	var (
+		rules    []rewriteRule
+		problems []string
+	)
+	clusterDomain = normalizeZone(clusterDomain)
+	for i, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			problems = append(problems, fmt.Sprintf("line %d: expected \"<match> <from> <to>\"", i+1))
+			continue
+		}
+		rule := rewriteRule{Match: fields[0], From: normalizeZone(fields[1]), To: normalizeZone(fields[2])}
+		switch {
+		case rule.Match != "exact" && rule.Match != "suffix":
+			problems = append(problems, fmt.Sprintf("line %d: match %q must be exact or suffix", i+1, rule.Match))
+		case !validDomainName(rule.From):
+			problems = append(problems, fmt.Sprintf("line %d: %q is not a valid domain name", i+1, fields[1]))
+		case !validDomainName(rule.To):
+			problems = append(problems, fmt.Sprintf("line %d: %q is not a valid domain name", i+1, fields[2]))
+		case nameInZone(rule.From, clusterDomain):
+			problems = append(problems, fmt.Sprintf("line %d: %q is in the cluster domain", i+1, fields[1]))
+		case rule.Match == "suffix" && nameInZone(clusterDomain, rule.From):
+			problems = append(problems, fmt.Sprintf("line %d: suffix %q would rewrite the cluster domain", i+1, fields[1]))
+		case nameInAnyZone(rule.From, zones):
+			problems = append(problems, fmt.Sprintf("line %d: %q is in a zone that another server serves", i+1, fields[1]))
+		default:
+			rules = append(rules, rule)
+		}
+	}
+	return rules, problems
+}
+
+// validDomainName returns a Boolean value indicating whether the given fully
+// qualified name is a valid domain name.
+func validDomainName(name string) bool {
+	return len(validation.IsDNS1123Subdomain(strings.TrimSuffix(name, "."))) == 0
+}
+
+// otherServerZones returns the zones, normalized with normalizeZone, that the
+// given servers and IdM DNS servers serve instead of the default server block.
+func otherServerZones(servers []operatorv1.Server, idmResolvers []idmResolver) sets.String {
+	zones := sets.NewString()
+	for _, server := range servers {
+		for _, zone := range server.Zones {
+			zones.Insert(normalizeZone(zone))
+		}
+	}
+	for _, resolver := range idmResolvers {
+		for _, zone := range resolver.Zones {
+			zones.Insert(normalizeZone(zone))
+		}
+	}
+	return zones
+}
+
+// nameInZone returns a Boolean value indicating whether the given fully
+// qualified name is the given fully qualified zone or a name in it.
+func nameInZone(name, zone string) bool {
+	return name == zone || strings.HasSuffix(name, "."+zone)
+}
+
+// nameInAnyZone returns a Boolean value indicating whether the given fully
+// qualified name is in any of the given zones.
+func nameInAnyZone(name string, zones sets.String) bool {
+	for zone := range zones {
+		if nameInZone(name, zone) {
+			return true
+		}
+	}
+	return false
+}
+
+// computeRewriteRulesAppliedCondition returns a status condition that reports
+// the given rewrite rules from the configmap with the given name and the
+// problems with the rules that were ignored.
+func computeRewriteRulesAppliedCondition(name types.NamespacedName, rules []rewriteRule, problems []string) operatorv1.OperatorCondition {
+	condition := operatorv1.OperatorCondition{
+		Type: DNSRewriteRulesAppliedConditionType,
+	}
+	if len(problems) != 0 {
+		condition.Status = operatorv1.ConditionFalse
+		condition.Reason = conditions.ReasonRewriteRulesIgnored
+		condition.Message = fmt.Sprintf("Some rewrite rules in configmap %s/%s were ignored: %s.  Applying %d rewrite rules.", name.Namespace, name.Name, strings.Join(problems, "; "), len(rules))
+		return condition
+	}
+	condition.Status = operatorv1.ConditionTrue
+	condition.Reason = conditions.ReasonAsExpected
+	condition.Message = fmt.Sprintf("Applying %d rewrite rules from configmap %s/%s.", len(rules), name.Namespace, name.Name)
+	return condition
+}
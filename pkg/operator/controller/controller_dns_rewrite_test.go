@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// TestParseRewriteRules verifies that parseRewriteRules parses valid rules and
+// ignores rules that would rewrite names that the default server block does
+// not serve.
+func TestParseRewriteRules(t *testing.T) {
+	data := `# Legacy hostnames.
+exact db.corp.example.com postgres.databases.svc.cluster.local
+
+suffix Legacy.Example.com. svc.cluster.local.
+exact foo.svc.cluster.local bar.svc.cluster.local
+suffix local svc.cluster.local
+exact www.foo.com bar.svc.cluster.local
+regex (.*)\.example\.org {1}.svc.cluster.local
+exact bad_name.example.com bar.svc.cluster.local
+exact missing.example.com
+`
+	expectedRules := []rewriteRule{
+		{Match: "exact", From: "db.corp.example.com.", To: "postgres.databases.svc.cluster.local."},
+		{Match: "suffix", From: "legacy.example.com.", To: "svc.cluster.local."},
+	}
+	rules, problems := parseRewriteRules(data, "cluster.local", sets.NewString("foo.com."))
+	if !reflect.DeepEqual(rules, expectedRules) {
+		t.Errorf("expected rules %+v, got %+v", expectedRules, rules)
+	}
+	expectedProblems := []string{
+		`line 5: "foo.svc.cluster.local" is in the cluster domain`,
+		`line 6: suffix "local" would rewrite the cluster domain`,
+		`line 7: "www.foo.com" is in a zone that another server serves`,
+		`line 8: match "regex" must be exact or suffix`,
+		`line 9: "bad_name.example.com" is not a valid domain name`,
+		`line 10: expected "<match> <from> <to>"`,
+	}
+	if !reflect.DeepEqual(problems, expectedProblems) {
+		t.Errorf("expected problems:\n%s\ngot:\n%s", strings.Join(expectedProblems, "\n"), strings.Join(problems, "\n"))
+	}
+}
+
+// TestDesiredDNSConfigMapRewriteRules verifies that the default server block
+// has a rewrite plugin stanza for each rewrite rule, in order.
+func TestDesiredDNSConfigMapRewriteRules(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DefaultDNSController,
+		},
+	}
+	rules := []rewriteRule{
+		{Match: "suffix", From: "legacy.example.com.", To: "svc.cluster.local."},
+		{Match: "exact", From: "db.corp.example.com.", To: "postgres.databases.svc.cluster.local."},
+	}
+	cm, err := desiredDNSConfigMap(dns, corefileInputs{RewriteRules: rules, ClusterDomain: "cluster.local", MetricsAddress: "127.0.0.1:9153"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `    ready
+    rewrite name suffix legacy.example.com. svc.cluster.local. answer auto
+    rewrite name exact db.corp.example.com. postgres.databases.svc.cluster.local. answer auto
+    kubernetes cluster.local in-addr.arpa ip6.arpa {`
+	if !strings.Contains(cm.Data["Corefile"], expected) {
+		t.Errorf("expected Corefile to contain:\n%s\ngot:\n%s", expected, cm.Data["Corefile"])
+	}
+}
@@ -0,0 +1,149 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	"github.com/sirupsen/logrus"
+
+	appsv1 "k8s.io/api/apps/v1"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+)
+
+// DNSRolloutDeferredConditionType is the type of the DNS status condition that
+// reports whether a rollout of the dns's daemonset is deferred until the next
+// rollout window.  The condition is reported only if the dns has the
+// RolloutWindowsAnnotation annotation.
+const DNSRolloutDeferredConditionType = conditions.TypeRolloutDeferred
+
+// rolloutWindowSpec is an entry in the value of a dns's
+// RolloutWindowsAnnotation annotation.
+type rolloutWindowSpec struct {
+	// Days are the days of the week, such as "Saturday" or "Sat", on
+	// which the window starts.  If empty, the window starts every day.
+	Days []string `json:"days,omitempty"`
+	// Start is the time of day in UTC, in the format "15:04", at which
+	// the window starts.
+	Start string `json:"start"`
+	// Duration is the length of the window, in the format that
+	// time.ParseDuration accepts, such as "2h30m".
+	Duration string `json:"duration"`
+}
+
+// rolloutWindows returns the valid rollout windows in the given dns's
+// RolloutWindowsAnnotation annotation.  Invalid windows are logged and
+// ignored.  The windows have no server or upstreams.
+func rolloutWindows(dns *operatorv1.DNS) []maintenanceWindow {
+	value, ok := dns.Annotations[RolloutWindowsAnnotation]
+	if !ok || len(strings.TrimSpace(value)) == 0 {
+		return nil
+	}
+	var specs []rolloutWindowSpec
+	if err := json.Unmarshal([]byte(value), &specs); err != nil {
+		logrus.Warningf("ignoring invalid value for annotation %s on dns %s: %v", RolloutWindowsAnnotation, dns.Name, err)
+		return nil
+	}
+	var windows []maintenanceWindow
+	for i, spec := range specs {
+		var window maintenanceWindow
+		if err := window.parseSchedule(spec.Days, spec.Start, spec.Duration); err != nil {
+			logrus.Warningf("ignoring invalid rollout window %d in annotation %s on dns %s: %v", i, RolloutWindowsAnnotation, dns.Name, err)
+			continue
+		}
+		windows = append(windows, window)
+	}
+	return windows
+}
+
+// nextWindowStart returns the next start of any of the given windows after the
+// given time, and a Boolean value that is false if there are no windows.
+func nextWindowStart(windows []maintenanceWindow, now time.Time) (time.Time, bool) {
+	var next time.Time
+	found := false
+	for _, w := range windows {
+		for _, start := range w.starts(now) {
+			if start.After(now) && (!found || start.Before(next)) {
+				next, found = start, true
+			}
+		}
+	}
+	return next, found
+}
+
+// urgentRolloutReason returns the reason why the rollout from the given current
+// daemonset to the given desired daemonset must not be deferred, or the empty
+// string if it may be deferred.  A rollout is urgent if the current daemonset
+// has no available pods, so that deferring it cannot preserve service, or if
+// it updates a container to the given release image for that container, so
+// that cluster upgrades are not blocked.  The release images map container
+// names to images.
+func urgentRolloutReason(current, desired *appsv1.DaemonSet, releaseImages map[string]string) string {
+	if current.Status.NumberAvailable == 0 {
+		return "the daemonset has no available pods"
+	}
+	currentImages := map[string]string{}
+	for _, c := range current.Spec.Template.Spec.Containers {
+		currentImages[c.Name] = c.Image
+	}
+	for _, c := range desired.Spec.Template.Spec.Containers {
+		if image, ok := releaseImages[c.Name]; ok && len(image) != 0 && c.Image == image && currentImages[c.Name] != image {
+			return fmt.Sprintf("the %s container is updated to the release image", c.Name)
+		}
+	}
+	return ""
+}
+
+// rolloutDeferred returns a Boolean value indicating whether the given
+// RolloutDeferred condition, which may be nil, reports a deferred rollout.
+func rolloutDeferred(condition *operatorv1.OperatorCondition) bool {
+	return condition != nil && condition.Type == DNSRolloutDeferredConditionType && condition.Status == operatorv1.ConditionTrue
+}
+
+// dnsDaemonSetRolloutDeferral returns a Boolean value indicating whether the
+// update from the given current daemonset to the given desired daemonset of
+// the given dns must be deferred until the dns's next rollout window, and a
+// status condition that reports it, or nil if the dns has no rollout windows.
+// Updates that do not change the daemonset's pod template do not roll out pods
+// and are never deferred.  While the rollout is deferred, so is any update to
+// the dns's configmap.
+func dnsDaemonSetRolloutDeferral(dns *operatorv1.DNS, current, desired *appsv1.DaemonSet, releaseImages map[string]string, now time.Time) (bool, *operatorv1.OperatorCondition) {
+	windows := rolloutWindows(dns)
+	if len(windows) == 0 {
+		return false, nil
+	}
+	condition := &operatorv1.OperatorCondition{
+		Type:   DNSRolloutDeferredConditionType,
+		Status: operatorv1.ConditionFalse,
+		Reason: conditions.ReasonAsExpected,
+	}
+	changed, updated := daemonsetConfigChanged(current, desired)
+	if !changed || equality.Semantic.DeepEqual(current.Spec.Template, updated.Spec.Template) {
+		condition.Message = fmt.Sprintf("No rollout of daemonset %s/%s is pending.", current.Namespace, current.Name)
+		return false, condition
+	}
+	if reason := urgentRolloutReason(current, desired, releaseImages); len(reason) != 0 {
+		condition.Reason = conditions.ReasonUrgentRollout
+		condition.Message = fmt.Sprintf("Rolling out daemonset %s/%s immediately because %s.", current.Namespace, current.Name, reason)
+		return false, condition
+	}
+	for _, w := range windows {
+		if w.active(now) {
+			condition.Message = fmt.Sprintf("Rolling out daemonset %s/%s during the current rollout window.", current.Namespace, current.Name)
+			return false, condition
+		}
+	}
+	condition.Status = operatorv1.ConditionTrue
+	condition.Reason = conditions.ReasonOutsideRolloutWindow
+	condition.Message = fmt.Sprintf("A rollout of daemonset %s/%s and any update to its configmap are deferred until the next rollout window", current.Namespace, current.Name)
+	if next, ok := nextWindowStart(windows, now); ok {
+		condition.Message += fmt.Sprintf(", which starts at %s", next.Format(time.RFC3339))
+	}
+	condition.Message += "."
+	return true, condition
+}
@@ -0,0 +1,216 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	corev1 "k8s.io/api/core/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// TestDNSDaemonSetRolloutDeferral verifies that pod template changes are
+// deferred outside the dns's rollout windows unless they are urgent.
+func TestDNSDaemonSetRolloutDeferral(t *testing.T) {
+	const (
+		releaseImage = "quay.io/openshift/coredns:release"
+		customImage  = "quay.io/example/coredns:custom"
+	)
+	releaseImages := map[string]string{"dns": releaseImage, "kube-rbac-proxy": "quay.io/openshift/kube-rbac-proxy:release"}
+	// Saturday, 2021-06-05.
+	saturdayNoon := time.Date(2021, time.June, 5, 12, 0, 0, 0, time.UTC)
+	sundayOneAM := time.Date(2021, time.June, 6, 1, 30, 0, 0, time.UTC)
+	windows := `[{"days": ["Sun"], "start": "01:00", "duration": "2h"}]`
+
+	testCases := []struct {
+		description       string
+		windows           string
+		currentImage      string
+		desiredImage      string
+		available         int32
+		now               time.Time
+		expectDeferred    bool
+		expectCondition   bool
+		expectedReason    string
+		expectedCondition operatorv1.ConditionStatus
+	}{
+		{
+			description:  "no rollout windows",
+			currentImage: releaseImage,
+			desiredImage: customImage,
+			available:    3,
+			now:          saturdayNoon,
+		},
+		{
+			description:       "no pending rollout",
+			windows:           windows,
+			currentImage:      customImage,
+			desiredImage:      customImage,
+			available:         3,
+			now:               saturdayNoon,
+			expectCondition:   true,
+			expectedReason:    conditions.ReasonAsExpected,
+			expectedCondition: operatorv1.ConditionFalse,
+		},
+		{
+			description:       "custom image outside the window",
+			windows:           windows,
+			currentImage:      releaseImage,
+			desiredImage:      customImage,
+			available:         3,
+			now:               saturdayNoon,
+			expectDeferred:    true,
+			expectCondition:   true,
+			expectedReason:    conditions.ReasonOutsideRolloutWindow,
+			expectedCondition: operatorv1.ConditionTrue,
+		},
+		{
+			description:       "custom image inside the window",
+			windows:           windows,
+			currentImage:      releaseImage,
+			desiredImage:      customImage,
+			available:         3,
+			now:               sundayOneAM,
+			expectCondition:   true,
+			expectedReason:    conditions.ReasonAsExpected,
+			expectedCondition: operatorv1.ConditionFalse,
+		},
+		{
+			description:       "release image outside the window",
+			windows:           windows,
+			currentImage:      customImage,
+			desiredImage:      releaseImage,
+			available:         3,
+			now:               saturdayNoon,
+			expectCondition:   true,
+			expectedReason:    conditions.ReasonUrgentRollout,
+			expectedCondition: operatorv1.ConditionFalse,
+		},
+		{
+			description:       "no available pods outside the window",
+			windows:           windows,
+			currentImage:      releaseImage,
+			desiredImage:      customImage,
+			now:               saturdayNoon,
+			expectCondition:   true,
+			expectedReason:    conditions.ReasonUrgentRollout,
+			expectedCondition: operatorv1.ConditionFalse,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			dns := &operatorv1.DNS{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: DefaultDNSController,
+				},
+			}
+			if len(tc.windows) != 0 {
+				dns.Annotations = map[string]string{RolloutWindowsAnnotation: tc.windows}
+			}
+			current, err := desiredDNSDaemonSet(dns, tc.currentImage, "", "", "dns-default-metrics-tls", "", nil, nil, nil, nil, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			current.Status.NumberAvailable = tc.available
+			desired, err := desiredDNSDaemonSet(dns, tc.desiredImage, "", "", "dns-default-metrics-tls", "", nil, nil, nil, nil, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			deferred, condition := dnsDaemonSetRolloutDeferral(dns, current, desired, releaseImages, tc.now)
+			if deferred != tc.expectDeferred {
+				t.Errorf("expected deferred to be %t, got %t", tc.expectDeferred, deferred)
+			}
+			if rolloutDeferred(condition) != deferred {
+				t.Errorf("expected the condition to report deferred %t, got %+v", deferred, condition)
+			}
+			switch {
+			case !tc.expectCondition && condition != nil:
+				t.Errorf("expected no condition, got %+v", *condition)
+			case tc.expectCondition && condition == nil:
+				t.Errorf("expected a condition, got none")
+			case tc.expectCondition && (condition.Status != tc.expectedCondition || condition.Reason != tc.expectedReason):
+				t.Errorf("expected condition with status %s and reason %s, got %+v", tc.expectedCondition, tc.expectedReason, *condition)
+			}
+		})
+	}
+}
+
+// fakeConfigMapUpdateClient is a fakeConfigMapClient that records the
+// configmaps that it updates.
+type fakeConfigMapUpdateClient struct {
+	fakeConfigMapClient
+	updated []*corev1.ConfigMap
+}
+
+func (c *fakeConfigMapUpdateClient) Update(_ context.Context, obj client.Object, _ ...client.UpdateOption) error {
+	cm := obj.(*corev1.ConfigMap)
+	c.updated = append(c.updated, cm.DeepCopy())
+	c.configMaps[cm.Name] = cm.DeepCopy()
+	return nil
+}
+
+// TestEnsureDNSConfigMapDeferUpdate verifies that ensureDNSConfigMap does not
+// update the configmap while the daemonset's rollout is deferred and updates it
+// once the rollout proceeds.
+func TestEnsureDNSConfigMapDeferUpdate(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DefaultDNSController,
+		},
+	}
+	current, err := desiredDNSConfigMap(dns, corefileInputs{ClusterDomain: "cluster.local", MetricsAddress: "127.0.0.1:9153"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &fakeConfigMapUpdateClient{fakeConfigMapClient: fakeConfigMapClient{configMaps: map[string]*corev1.ConfigMap{current.Name: current}}}
+	r := &reconciler{client: c}
+	servers := []operatorv1.Server{{
+		Name:  "corp",
+		Zones: []string{"corp.example.com"},
+		ForwardPlugin: operatorv1.ForwardPlugin{
+			Upstreams: []string{"10.0.0.53"},
+		},
+	}}
+
+	if _, _, err := r.ensureDNSConfigMap(dns, corefileInputs{Servers: servers, ClusterDomain: "cluster.local", MetricsAddress: "127.0.0.1:9153"}, true); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.updated) != 0 {
+		t.Fatalf("expected no update while the rollout is deferred, got %d", len(c.updated))
+	}
+	if _, _, err := r.ensureDNSConfigMap(dns, corefileInputs{Servers: servers, ClusterDomain: "cluster.local", MetricsAddress: "127.0.0.1:9153"}, false); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.updated) != 1 || !strings.Contains(c.updated[0].Data["Corefile"], "corp.example.com:5353") {
+		t.Errorf("expected the configmap to be updated with the corp server, got %d updates", len(c.updated))
+	}
+}
+
+// TestNextWindowStart verifies that nextWindowStart returns the earliest start
+// of any window after the given time.
+func TestNextWindowStart(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DefaultDNSController,
+			Annotations: map[string]string{
+				RolloutWindowsAnnotation: `[{"days": ["Sun"], "start": "01:00", "duration": "2h"}, {"days": ["Mon"], "start": "25:00", "duration": "1h"}, {"start": "22:00", "duration": "1h"}]`,
+			},
+		},
+	}
+	windows := rolloutWindows(dns)
+	if len(windows) != 2 {
+		t.Fatalf("expected 2 valid windows, got %d", len(windows))
+	}
+	now := time.Date(2021, time.June, 5, 12, 0, 0, 0, time.UTC)
+	expected := time.Date(2021, time.June, 5, 22, 0, 0, 0, time.UTC)
+	if next, ok := nextWindowStart(windows, now); !ok || !next.Equal(expected) {
+		t.Errorf("expected next window start %v, got %v (%t)", expected, next, ok)
+	}
+}
@@ -0,0 +1,183 @@
+package controller
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DNSResponseRateLimitingConfiguredConditionType is the type of the DNS
+	// status condition that reports whether the response rate limiting that
+	// the dns's ResponseRateLimitingAnnotation annotation enables applies.
+	// The condition is reported only if the dns has the annotation.
+	DNSResponseRateLimitingConfiguredConditionType = conditions.TypeResponseRateLimitingConfigured
+
+	minRRLWindow    = time.Second
+	maxRRLWindow    = time.Hour
+	maxRRLAllowance = 100000
+	maxRRLSlipRatio = 10
+)
+
+// defaultRRL is the response rate limiting configuration of a dns whose
+// ResponseRateLimitingAnnotation annotation sets no valid settings.
+var defaultRRL = corefileRRL{
+	Window:             15,
+	ResponsesPerSecond: 100,
+	IPv4PrefixLength:   24,
+	IPv6PrefixLength:   56,
+	SlipRatio:          2,
+}
+
+// corefileRRL is the configuration of the rrl plugin.
+type corefileRRL struct {
+	// Window is the number of seconds over which CoreDNS averages the
+	// responses to each client network.
+	Window int
+	// ResponsesPerSecond, NodataPerSecond, NXDomainsPerSecond,
+	// ErrorsPerSecond, and RequestsPerSecond are the allowances of each
+	// client network, or zero if the kind of response is not limited.
+	ResponsesPerSecond int
+	NodataPerSecond    int
+	NXDomainsPerSecond int
+	ErrorsPerSecond    int
+	RequestsPerSecond  int
+	// IPv4PrefixLength and IPv6PrefixLength are the lengths of the
+	// client networks.
+	IPv4PrefixLength int
+	IPv6PrefixLength int
+	// SlipRatio is the ratio of limited queries that CoreDNS answers
+	// truncated rather than drops, or zero to drop them all.
+	SlipRatio int
+	// ReportOnly makes CoreDNS only count the queries that exceed the
+	// allowances.
+	ReportOnly bool
+}
+
+// responseRateLimitingEnabled returns a Boolean value indicating whether the
+// given dns has a ResponseRateLimitingAnnotation annotation.
+func responseRateLimitingEnabled(dns *operatorv1.DNS) bool {
+	_, ok := dns.Annotations[ResponseRateLimitingAnnotation]
+	return ok
+}
+
+// responseRateLimiting returns the response rate limiting configuration in the
+// given dns's ResponseRateLimitingAnnotation annotation, or nil if the dns does
+// not have the annotation or does not set a custom coredns image, which is
+// required for the rrl plugin.
+func responseRateLimiting(dns *operatorv1.DNS) *corefileRRL {
+	if !responseRateLimitingEnabled(dns) || len(customCoreDNSImage(dns)) == 0 {
+		return nil
+	}
+	config := rrlConfig(dns)
+	return &config
+}
+
+// rrlConfig returns the response rate limiting configuration in the given dns's
+// ResponseRateLimitingAnnotation annotation.  The value is a comma- or
+// space-delimited list of <setting>=<value> entries.  Invalid entries are
+// logged and ignored.
+func rrlConfig(dns *operatorv1.DNS) corefileRRL {
+	config := defaultRRL
+	list := dns.Annotations[ResponseRateLimitingAnnotation]
+	for _, entry := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' }) {
+		i := strings.Index(entry, "=")
+		if i < 1 {
+			logrus.Warningf("ignoring malformed response rate limiting setting %q in annotation %s on dns %s", entry, ResponseRateLimitingAnnotation, dns.Name)
+			continue
+		}
+		value := entry[i+1:]
+		switch setting := entry[:i]; setting {
+		case "window":
+			d, err := time.ParseDuration(value)
+			if err != nil || d < minRRLWindow || d > maxRRLWindow || d%time.Second != 0 {
+				logrus.Warningf("ignoring response rate limiting setting %q in annotation %s on dns %s: the window must be a duration of whole seconds between %v and %v", entry, ResponseRateLimitingAnnotation, dns.Name, minRRLWindow, maxRRLWindow)
+				continue
+			}
+			config.Window = int(d.Seconds())
+		case "responses-per-second", "nodata-per-second", "nxdomains-per-second", "errors-per-second", "requests-per-second":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 || n > maxRRLAllowance {
+				logrus.Warningf("ignoring response rate limiting setting %q in annotation %s on dns %s: the allowance must be between 0 and %d", entry, ResponseRateLimitingAnnotation, dns.Name, maxRRLAllowance)
+				continue
+			}
+			switch setting {
+			case "responses-per-second":
+				config.ResponsesPerSecond = n
+			case "nodata-per-second":
+				config.NodataPerSecond = n
+			case "nxdomains-per-second":
+				config.NXDomainsPerSecond = n
+			case "errors-per-second":
+				config.ErrorsPerSecond = n
+			case "requests-per-second":
+				config.RequestsPerSecond = n
+			}
+		case "ipv4-prefix-length", "ipv6-prefix-length":
+			max := 32
+			if setting == "ipv6-prefix-length" {
+				max = 128
+			}
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 || n > max {
+				logrus.Warningf("ignoring response rate limiting setting %q in annotation %s on dns %s: the prefix length must be between 1 and %d", entry, ResponseRateLimitingAnnotation, dns.Name, max)
+				continue
+			}
+			if setting == "ipv4-prefix-length" {
+				config.IPv4PrefixLength = n
+			} else {
+				config.IPv6PrefixLength = n
+			}
+		case "slip-ratio":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 || n > maxRRLSlipRatio {
+				logrus.Warningf("ignoring response rate limiting setting %q in annotation %s on dns %s: the ratio must be between 0 and %d", entry, ResponseRateLimitingAnnotation, dns.Name, maxRRLSlipRatio)
+				continue
+			}
+			config.SlipRatio = n
+		case "report-only":
+			reportOnly, err := strconv.ParseBool(value)
+			if err != nil {
+				logrus.Warningf("ignoring response rate limiting setting %q in annotation %s on dns %s: the value must be a Boolean value", entry, ResponseRateLimitingAnnotation, dns.Name)
+				continue
+			}
+			config.ReportOnly = reportOnly
+		default:
+			logrus.Warningf("ignoring unknown response rate limiting setting %q in annotation %s on dns %s", entry, ResponseRateLimitingAnnotation, dns.Name)
+		}
+	}
+	return config
+}
+
+// computeResponseRateLimitingConfiguredCondition returns a status condition
+// that reports whether the response rate limiting of the given dns applies, or
+// nil if the dns does not enable it.
+func computeResponseRateLimitingConfiguredCondition(dns *operatorv1.DNS) *operatorv1.OperatorCondition {
+	if !responseRateLimitingEnabled(dns) {
+		return nil
+	}
+	condition := &operatorv1.OperatorCondition{
+		Type: DNSResponseRateLimitingConfiguredConditionType,
+	}
+	config := responseRateLimiting(dns)
+	if config == nil {
+		condition.Status = operatorv1.ConditionFalse
+		condition.Reason = conditions.ReasonRequiresCustomCoreDNSImage
+		condition.Message = fmt.Sprintf("Response rate limiting requires the rrl plugin, which the release's coredns image does not have; set annotation %s to an image that has it.", CustomCoreDNSImageAnnotation)
+		return condition
+	}
+	condition.Status = operatorv1.ConditionTrue
+	condition.Reason = conditions.ReasonAsExpected
+	mode := "limits"
+	if config.ReportOnly {
+		mode = "reports"
+	}
+	condition.Message = fmt.Sprintf("Response rate limiting %s each /%d and /%d client network to %d responses per second over a %d-second window.", mode, config.IPv4PrefixLength, config.IPv6PrefixLength, config.ResponsesPerSecond, config.Window)
+	return condition
+}
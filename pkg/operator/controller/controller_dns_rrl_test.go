@@ -0,0 +1,115 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestRRLConfig verifies that rrlConfig applies valid settings over the
+// defaults and ignores invalid ones.
+func TestRRLConfig(t *testing.T) {
+	testCases := []struct {
+		name     string
+		value    string
+		expected corefileRRL
+	}{
+		{"empty", "", defaultRRL},
+		{
+			name:  "all settings",
+			value: "window=30s, responses-per-second=50,nodata-per-second=10 nxdomains-per-second=20,errors-per-second=5,requests-per-second=200,ipv4-prefix-length=32,ipv6-prefix-length=64,slip-ratio=0,report-only=true",
+			expected: corefileRRL{
+				Window:             30,
+				ResponsesPerSecond: 50,
+				NodataPerSecond:    10,
+				NXDomainsPerSecond: 20,
+				ErrorsPerSecond:    5,
+				RequestsPerSecond:  200,
+				IPv4PrefixLength:   32,
+				IPv6PrefixLength:   64,
+				ReportOnly:         true,
+			},
+		},
+		{
+			name:  "disable responses limit",
+			value: "responses-per-second=0,errors-per-second=5",
+			expected: corefileRRL{
+				Window:           15,
+				ErrorsPerSecond:  5,
+				IPv4PrefixLength: 24,
+				IPv6PrefixLength: 56,
+				SlipRatio:        2,
+			},
+		},
+		{"invalid settings", "window=1500ms,window=2h,responses-per-second=-1,requests-per-second=100001,ipv4-prefix-length=33,ipv6-prefix-length=0,slip-ratio=11,report-only=maybe,burst=5,window", defaultRRL},
+	}
+	for _, tc := range testCases {
+		dns := &operatorv1.DNS{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        DefaultDNSController,
+				Annotations: map[string]string{ResponseRateLimitingAnnotation: tc.value},
+			},
+		}
+		if actual := rrlConfig(dns); actual != tc.expected {
+			t.Errorf("%q: expected %+v, got %+v", tc.name, tc.expected, actual)
+		}
+	}
+}
+
+// TestDesiredDNSConfigMapRRL verifies that the server blocks have the rrl
+// plugin only if the dns enables response rate limiting and sets a custom
+// coredns image, and that the condition reports why otherwise.
+func TestDesiredDNSConfigMapRRL(t *testing.T) {
+	servers := []operatorv1.Server{
+		{Name: "corp", Zones: []string{"corp.example.com"}, ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"10.1.0.53"}}},
+	}
+	rrl := `
+    rrl {
+        window 15
+        ipv4-prefix-length 24
+        ipv6-prefix-length 56
+        responses-per-second 50
+        slip-ratio 2
+    }
+`
+	testCases := []struct {
+		name           string
+		annotations    map[string]string
+		expectRRL      bool
+		expectedStatus operatorv1.ConditionStatus
+		expectedReason string
+	}{
+		{"disabled", nil, false, "", ""},
+		{"release image", map[string]string{ResponseRateLimitingAnnotation: "responses-per-second=50"}, false, operatorv1.ConditionFalse, conditions.ReasonRequiresCustomCoreDNSImage},
+		{"custom image", map[string]string{ResponseRateLimitingAnnotation: "responses-per-second=50", CustomCoreDNSImageAnnotation: "quay.io/example/coredns-rrl:v1"}, true, operatorv1.ConditionTrue, conditions.ReasonAsExpected},
+	}
+	for _, tc := range testCases {
+		dns := &operatorv1.DNS{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        DefaultDNSController,
+				Annotations: tc.annotations,
+			},
+		}
+		cm, err := desiredDNSConfigMap(dns, corefileInputs{Servers: servers, ClusterDomain: "cluster.local", MetricsAddress: "127.0.0.1:9153"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		corefile := cm.Data["Corefile"]
+		for _, header := range []string{"corp.example.com:5353 {", ".:5353 {"} {
+			if hasRRL := strings.Contains(corefile, header+rrl); hasRRL != tc.expectRRL {
+				t.Errorf("%q: expected rrl plugin in server block %q %t, got Corefile:\n%s", tc.name, header, tc.expectRRL, corefile)
+			}
+		}
+		condition := computeResponseRateLimitingConfiguredCondition(dns)
+		switch {
+		case len(tc.expectedStatus) == 0 && condition != nil:
+			t.Errorf("%q: expected no condition, got %#v", tc.name, condition)
+		case len(tc.expectedStatus) != 0 && (condition == nil || condition.Status != tc.expectedStatus || condition.Reason != tc.expectedReason):
+			t.Errorf("%q: expected status %s and reason %s, got %#v", tc.name, tc.expectedStatus, tc.expectedReason, condition)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	corev1 "k8s.io/api/core/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// DNSSecondaryZonesTransferredConditionType is the type of the DNS
+	// status condition that reports whether the dns's pods serve the
+	// secondary zones that they transfer from the zones' primaries.  The
+	// condition is reported only if the dns has the
+	// SecondaryZonesAnnotation annotation.
+	DNSSecondaryZonesTransferredConditionType = conditions.TypeSecondaryZonesTransferred
+
+	// secondaryZoneCheckPeriod is how often the operator checks that the
+	// dns's pods serve the secondary zones.  The operator does not
+	// otherwise notice that a transfer fails, or succeeds after failing.
+	secondaryZoneCheckPeriod = time.Minute
+	// secondaryZoneLookupTimeout is how long the operator waits for a dns
+	// pod to answer a query for a secondary zone.
+	secondaryZoneLookupTimeout = 2 * time.Second
+)
+
+// secondaryZoneSpec is an entry in the value of a dns's
+// SecondaryZonesAnnotation annotation.
+type secondaryZoneSpec struct {
+	// Zone is the zone to transfer.
+	Zone string `json:"zone"`
+	// Primaries are the addresses, "<ip>" or "<ip>:<port>", of the
+	// zone's primary servers.
+	Primaries []string `json:"primaries"`
+}
+
+// secondaryZone is a validated secondaryZoneSpec.
+type secondaryZone struct {
+	// Zone is the zone, lowercase and without a trailing dot.
+	Zone string
+	// Primaries are the addresses of the zone's primary servers, each
+	// with a port.
+	Primaries []string
+}
+
+// secondaryZonesEnabled returns a Boolean value indicating whether the given
+// dns has a non-empty SecondaryZonesAnnotation annotation.
+func secondaryZonesEnabled(dns *operatorv1.DNS) bool {
+	return len(strings.TrimSpace(dns.Annotations[SecondaryZonesAnnotation])) != 0
+}
+
+// dnsSecondaryZones returns the valid secondary zones in the given dns's
+// SecondaryZonesAnnotation annotation, ordered by zone, and a status condition
+// that reports the invalid zones and the zones that the dns's pods do not
+// serve, or nil and a nil condition if the dns does not have the annotation.  A
+// zone must not be in, or contain, the cluster domain, and it must not be a
+// zone that the given servers, IdM DNS servers, or zone files serve.
+func (r *reconciler) dnsSecondaryZones(dns *operatorv1.DNS, servers []operatorv1.Server, idmResolvers []idmResolver, zoneFiles []zoneFile, clusterDomain string) ([]secondaryZone, *operatorv1.OperatorCondition, error) {
+	if !secondaryZonesEnabled(dns) {
+		return nil, nil, nil
+	}
+	zones := otherServerZones(servers, idmResolvers)
+	for _, zone := range zoneFiles {
+		zones.Insert(normalizeZone(zone.Zone))
+	}
+	secondaryZones, problems := parseSecondaryZones(dns.Annotations[SecondaryZonesAnnotation], clusterDomain, zones)
+
+	selector, err := metav1.LabelSelectorAsSelector(DNSDaemonSetPodSelector(dns))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to build pod selector for dns %s: %w", dns.Name, err)
+	}
+	podList := &corev1.PodList{}
+	listOpts := []client.ListOption{
+		client.MatchingLabelsSelector{Selector: selector},
+		client.InNamespace(DefaultOperandNamespace),
+	}
+	if err := r.cache.List(context.TODO(), podList, listOpts...); err != nil {
+		return nil, nil, fmt.Errorf("failed to list pods for dns %s: %w", dns.Name, err)
+	}
+	failures := secondaryZoneTransferFailures(podList.Items, secondaryZones, lookupZoneNS)
+
+	condition := computeSecondaryZonesTransferredCondition(secondaryZones, problems, failures)
+	return secondaryZones, &condition, nil
+}
+
+// parseSecondaryZones parses the given value of a SecondaryZonesAnnotation
+// annotation and returns the valid zones, ordered by zone, and the problems
+// with the invalid zones, which are ignored.  A zone is invalid if it is in, or
+// contains, the given cluster domain, or if it is one of the given zones, which
+// are normalized with normalizeZone, or another entry's zone.
+func parseSecondaryZones(value, clusterDomain string, zones sets.String) ([]secondaryZone, []string) {
+	var specs []secondaryZoneSpec
+	if err := json.Unmarshal([]byte(value), &specs); err != nil {
+		return nil, []string{fmt.Sprintf("the annotation is not a JSON list of zones: %v", err)}
+	}
+	var (
+		secondaryZones []secondaryZone
+		problems       []string
+		seen           = sets.NewString()
+	)
+	clusterDomain = normalizeZone(clusterDomain)
+	for i, spec := range specs {
+		zone := normalizeZone(spec.Zone)
+		switch {
+		case len(spec.Zone) == 0:
+			problems = append(problems, fmt.Sprintf("entry %d: zone must be specified", i))
+			continue
+		case zone == ".":
+			problems = append(problems, fmt.Sprintf("zone %s: the root zone conflicts with the default server block", spec.Zone))
+			continue
+		case !validDomainName(zone):
+			problems = append(problems, fmt.Sprintf("zone %s: the zone is not a valid domain name", spec.Zone))
+			continue
+		case nameInZone(zone, clusterDomain) || nameInZone(clusterDomain, zone):
+			problems = append(problems, fmt.Sprintf("zone %s: the zone overlaps the cluster domain", spec.Zone))
+			continue
+		case zones.Has(zone) || seen.Has(zone):
+			problems = append(problems, fmt.Sprintf("zone %s: another server serves the zone", spec.Zone))
+			continue
+		case len(spec.Primaries) == 0:
+			problems = append(problems, fmt.Sprintf("zone %s: primaries must be specified", spec.Zone))
+			continue
+		}
+		primaries, err := dnsServerAddresses(spec.Primaries, "primary")
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("zone %s: %v", spec.Zone, err))
+			continue
+		}
+		seen.Insert(zone)
+		secondaryZones = append(secondaryZones, secondaryZone{Zone: strings.TrimSuffix(zone, "."), Primaries: primaries})
+	}
+	sort.Slice(secondaryZones, func(i, j int) bool {
+		return secondaryZones[i].Zone < secondaryZones[j].Zone
+	})
+	return secondaryZones, problems
+}
+
+// dnsServerAddresses validates the given DNS server addresses, "<ip>" or
+// "<ip>:<port>", and returns them with the default DNS port added to those that
+// have no port.  The given role names the servers in errors.
+func dnsServerAddresses(servers []string, role string) ([]string, error) {
+	var addresses []string
+	for _, server := range servers {
+		host, port := server, "53"
+		if h, p, err := net.SplitHostPort(server); err == nil {
+			host, port = h, p
+		}
+		ip := net.ParseIP(host)
+		if ip == nil {
+			return nil, fmt.Errorf("%s %q is not an IP address", role, server)
+		}
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return nil, fmt.Errorf("%s %q has an invalid port", role, server)
+		}
+		addresses = append(addresses, net.JoinHostPort(ip.String(), port))
+	}
+	return addresses, nil
+}
+
+// zoneLookupFunc checks whether the DNS server at the given address serves the
+// given fully qualified zone.
+type zoneLookupFunc func(ctx context.Context, address, zone string) error
+
+// lookupZoneNS queries the DNS server at the given address for the NS records
+// of the given fully qualified zone.  CoreDNS's secondary plugin answers
+// SERVFAIL for a zone that it has not transferred, so the query fails until the
+// transfer succeeds.
+func lookupZoneNS(ctx context.Context, address, zone string) error {
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, address)
+		},
+	}
+	ctx, cancel := context.WithTimeout(ctx, secondaryZoneLookupTimeout)
+	defer cancel()
+	_, err := resolver.LookupNS(ctx, zone)
+	return err
+}
+
+// secondaryZoneTransferFailures queries each of the given dns pods that is
+// running for each of the given secondary zones using the given lookup function
+// and returns, for each zone that some pods do not serve, the names of those
+// pods in sorted order.
+func secondaryZoneTransferFailures(pods []corev1.Pod, secondaryZones []secondaryZone, lookup zoneLookupFunc) map[string][]string {
+	var (
+		lock     sync.Mutex
+		wg       sync.WaitGroup
+		failures = map[string][]string{}
+	)
+	for i := range pods {
+		pod := &pods[i]
+		if pod.Status.Phase != corev1.PodRunning || len(pod.Status.PodIP) == 0 {
+			continue
+		}
+		address := net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(CoreDNSPort))
+		for _, zone := range secondaryZones {
+			wg.Add(1)
+			go func(name, zone string) {
+				defer wg.Done()
+				if err := lookup(context.TODO(), address, zone+"."); err != nil {
+					lock.Lock()
+					failures[zone] = append(failures[zone], name)
+					lock.Unlock()
+				}
+			}(pod.Name, zone.Zone)
+		}
+	}
+	wg.Wait()
+	for _, names := range failures {
+		sort.Strings(names)
+	}
+	return failures
+}
+
+// computeSecondaryZonesTransferredCondition returns a status condition that
+// reports the given secondary zones, the problems with the zones that were
+// ignored, and the pods that do not serve each zone.
+func computeSecondaryZonesTransferredCondition(secondaryZones []secondaryZone, problems []string, failures map[string][]string) operatorv1.OperatorCondition {
+	condition := operatorv1.OperatorCondition{
+		Type: DNSSecondaryZonesTransferredConditionType,
+	}
+	var served []string
+	for _, zone := range secondaryZones {
+		served = append(served, zone.Zone)
+	}
+	serving := "Serving no secondary zones."
+	if len(served) != 0 {
+		serving = fmt.Sprintf("Serving secondary zones: %s.", strings.Join(served, ", "))
+	}
+	var failed []string
+	for _, zone := range secondaryZones {
+		if pods, ok := failures[zone.Zone]; ok {
+			failed = append(failed, fmt.Sprintf("zone %s has not been transferred from %s on pods %s", zone.Zone, strings.Join(zone.Primaries, ", "), strings.Join(pods, ", ")))
+		}
+	}
+	switch {
+	case len(failed) != 0:
+		condition.Status = operatorv1.ConditionFalse
+		condition.Reason = conditions.ReasonZoneTransferFailed
+		condition.Message = fmt.Sprintf("Zone transfers failed: %s.  %s", strings.Join(failed, "; "), serving)
+		if len(problems) != 0 {
+			condition.Message += fmt.Sprintf("  Some secondary zones were ignored: %s.", strings.Join(problems, "; "))
+		}
+	case len(problems) != 0:
+		condition.Status = operatorv1.ConditionFalse
+		condition.Reason = conditions.ReasonSecondaryZonesIgnored
+		condition.Message = fmt.Sprintf("Some secondary zones were ignored: %s.  %s", strings.Join(problems, "; "), serving)
+	default:
+		condition.Status = operatorv1.ConditionTrue
+		condition.Reason = conditions.ReasonAsExpected
+		condition.Message = serving
+	}
+	return condition
+}
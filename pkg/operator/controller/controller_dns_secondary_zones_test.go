@@ -0,0 +1,142 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	corev1 "k8s.io/api/core/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// TestParseSecondaryZones verifies that parseSecondaryZones accepts valid
+// secondary zones and reports invalid ones.
+func TestParseSecondaryZones(t *testing.T) {
+	value := `[
+		{"zone": "Corp.Example.com.", "primaries": ["192.0.2.53", "192.0.2.54:5353", "2001:db8::53"]},
+		{"zone": "a.example.org", "primaries": ["[2001:db8::54]:53"]},
+		{"zone": "", "primaries": ["192.0.2.53"]},
+		{"zone": ".", "primaries": ["192.0.2.53"]},
+		{"zone": "svc.cluster.local", "primaries": ["192.0.2.53"]},
+		{"zone": "foo.com", "primaries": ["192.0.2.53"]},
+		{"zone": "corp.example.com", "primaries": ["192.0.2.53"]},
+		{"zone": "b.example.org", "primaries": []},
+		{"zone": "c.example.org", "primaries": ["ns1.example.org"]},
+		{"zone": "d.example.org", "primaries": ["192.0.2.53:0"]}
+	]`
+	zones, problems := parseSecondaryZones(value, "cluster.local", sets.NewString("foo.com."))
+	expected := []secondaryZone{
+		{Zone: "a.example.org", Primaries: []string{"[2001:db8::54]:53"}},
+		{Zone: "corp.example.com", Primaries: []string{"192.0.2.53:53", "192.0.2.54:5353", "[2001:db8::53]:53"}},
+	}
+	if !reflect.DeepEqual(zones, expected) {
+		t.Errorf("expected %v, got %v", expected, zones)
+	}
+	expectedProblems := []string{
+		"entry 2: zone must be specified",
+		"zone .: the root zone conflicts with the default server block",
+		"zone svc.cluster.local: the zone overlaps the cluster domain",
+		"zone foo.com: another server serves the zone",
+		"zone corp.example.com: another server serves the zone",
+		"zone b.example.org: primaries must be specified",
+		`zone c.example.org: primary "ns1.example.org" is not an IP address`,
+		`zone d.example.org: primary "192.0.2.53:0" has an invalid port`,
+	}
+	if !reflect.DeepEqual(problems, expectedProblems) {
+		t.Errorf("expected problems:\n%s\ngot:\n%s", strings.Join(expectedProblems, "\n"), strings.Join(problems, "\n"))
+	}
+
+	if _, problems := parseSecondaryZones("corp.example.com", "cluster.local", sets.NewString()); len(problems) != 1 {
+		t.Errorf("expected a problem for a value that is not JSON, got %v", problems)
+	}
+}
+
+// TestSecondaryZoneTransferFailures verifies that the operator reports the
+// running pods that do not serve a secondary zone.
+func TestSecondaryZoneTransferFailures(t *testing.T) {
+	pod := func(name, ip string, phase corev1.PodPhase) corev1.Pod {
+		return corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status:     corev1.PodStatus{Phase: phase, PodIP: ip},
+		}
+	}
+	pods := []corev1.Pod{
+		pod("dns-default-a", "10.128.0.10", corev1.PodRunning),
+		pod("dns-default-b", "10.128.0.11", corev1.PodRunning),
+		pod("dns-default-c", "10.128.0.12", corev1.PodPending),
+		pod("dns-default-d", "", corev1.PodRunning),
+	}
+	zones := []secondaryZone{
+		{Zone: "corp.example.com", Primaries: []string{"192.0.2.53:53"}},
+		{Zone: "lab.example.com", Primaries: []string{"192.0.2.54:53"}},
+	}
+	var (
+		lock    sync.Mutex
+		lookups []string
+	)
+	lookup := func(_ context.Context, address, zone string) error {
+		lock.Lock()
+		defer lock.Unlock()
+		lookups = append(lookups, address+" "+zone)
+		if address == "10.128.0.11:5353" && zone == "corp.example.com." {
+			return errors.New("server misbehaving")
+		}
+		return nil
+	}
+	failures := secondaryZoneTransferFailures(pods, zones, lookup)
+	expected := map[string][]string{"corp.example.com": {"dns-default-b"}}
+	if !reflect.DeepEqual(failures, expected) {
+		t.Errorf("expected %v, got %v", expected, failures)
+	}
+	if len(lookups) != 4 {
+		t.Errorf("expected 4 lookups, got %v", lookups)
+	}
+
+	condition := computeSecondaryZonesTransferredCondition(zones, nil, failures)
+	if condition.Status != operatorv1.ConditionFalse || condition.Reason != conditions.ReasonZoneTransferFailed {
+		t.Errorf("expected condition with status False and reason %s, got %+v", conditions.ReasonZoneTransferFailed, condition)
+	}
+	expectedMessage := "Zone transfers failed: zone corp.example.com has not been transferred from 192.0.2.53:53 on pods dns-default-b.  Serving secondary zones: corp.example.com, lab.example.com."
+	if condition.Message != expectedMessage {
+		t.Errorf("expected message %q, got %q", expectedMessage, condition.Message)
+	}
+	if condition := computeSecondaryZonesTransferredCondition(zones, nil, nil); condition.Status != operatorv1.ConditionTrue {
+		t.Errorf("expected condition with status True, got %+v", condition)
+	}
+}
+
+// TestDesiredDNSConfigMapSecondaryZones verifies that the Corefile has a server
+// block that transfers each secondary zone from its primaries.
+func TestDesiredDNSConfigMapSecondaryZones(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DefaultDNSController,
+		},
+	}
+	zones := []secondaryZone{{Zone: "corp.example.com", Primaries: []string{"192.0.2.53:53", "192.0.2.54:5353"}}}
+	cm, err := desiredDNSConfigMap(dns, corefileInputs{SecondaryZones: zones, ClusterDomain: "cluster.local", MetricsAddress: "127.0.0.1:9153"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `# secondary corp.example.com
+corp.example.com:5353 {
+    secondary {
+        transfer from 192.0.2.53:53 192.0.2.54:5353
+    }
+    errors
+    bufsize 1232
+    prometheus 127.0.0.1:9153
+}
+.:5353 {`
+	if !strings.HasPrefix(cm.Data["Corefile"], expected) {
+		t.Errorf("expected Corefile to start with:\n%s\ngot:\n%s", expected, cm.Data["Corefile"])
+	}
+}
@@ -0,0 +1,145 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// secretsStoreCSIDriver is the name of the Secrets Store CSI driver,
+	// which mounts the credentials that a SecretProviderClass selects
+	// from an external secret store.
+	secretsStoreCSIDriver = "secrets-store.csi.k8s.io"
+
+	// secretProviderClassCheckPeriod is how often the operator checks the
+	// SecretProviderClasses that a dns refers to.  The operator does not
+	// watch them, so it notices a change, after which the dns pods must
+	// restart to load the credentials, only when it checks them.
+	secretProviderClassCheckPeriod = 5 * time.Minute
+)
+
+// secretProviderClassGVK is the group, version, and kind of the Secrets Store
+// CSI driver's SecretProviderClass resource.  The operator uses unstructured
+// objects for SecretProviderClass resources so that it does not depend on the
+// driver's API.
+var secretProviderClassGVK = schema.GroupVersionKind{
+	Group:   "secrets-store.csi.x-k8s.io",
+	Version: "v1",
+	Kind:    "SecretProviderClass",
+}
+
+// secretProviderClassName returns the name of the SecretProviderClass that the
+// given credential reference names and a Boolean value indicating whether the
+// reference has SecretProviderReferencePrefix, rather than naming a secret.
+func secretProviderClassName(ref string) (string, bool) {
+	if !strings.HasPrefix(ref, SecretProviderReferencePrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(ref, SecretProviderReferencePrefix), true
+}
+
+// validCredentialReference returns a Boolean value indicating whether the given
+// credential reference is a valid secret name or a SecretProviderReferencePrefix
+// reference to a valid SecretProviderClass name.
+func validCredentialReference(ref string) bool {
+	if name, ok := secretProviderClassName(ref); ok {
+		ref = name
+	}
+	return len(validation.IsDNS1123Subdomain(ref)) == 0
+}
+
+// credentialDir returns the directory under the given directory in which the
+// dns pods mount the credentials of the given reference.  A
+// SecretProviderClass's credentials are in a sibling directory so that they
+// cannot collide with a secret of the same name.
+func credentialDir(dir, ref string) string {
+	if name, ok := secretProviderClassName(ref); ok {
+		return path.Join(dir+"-csi", name)
+	}
+	return path.Join(dir, ref)
+}
+
+// credentialVolumeSource returns the source of the volume that mounts the
+// credentials of the given reference: a secret volume for a secret, or a
+// Secrets Store CSI volume for a SecretProviderClass.
+func credentialVolumeSource(ref string) corev1.VolumeSource {
+	if name, ok := secretProviderClassName(ref); ok {
+		readOnly := true
+		return corev1.VolumeSource{
+			CSI: &corev1.CSIVolumeSource{
+				Driver:           secretsStoreCSIDriver,
+				ReadOnly:         &readOnly,
+				VolumeAttributes: map[string]string{"secretProviderClass": name},
+			},
+		}
+	}
+	return corev1.VolumeSource{
+		Secret: &corev1.SecretVolumeSource{SecretName: ref},
+	}
+}
+
+// secretProviderClassHash returns a value that changes when the
+// SecretProviderClass with the given name in the operand namespace changes, or
+// the problem with the SecretProviderClass if it is missing or the cluster does
+// not have the SecretProviderClass API.  The operator cannot read the
+// credentials in the external store, so it cannot validate them, and the dns
+// pods restart only when the SecretProviderClass itself changes.
+func (r *reconciler) secretProviderClassHash(name string) (string, string, error) {
+	spc := &unstructured.Unstructured{}
+	spc.SetGroupVersionKind(secretProviderClassGVK)
+	key := types.NamespacedName{Namespace: DefaultOperandNamespace, Name: name}
+	if err := r.client.Get(context.TODO(), key, spc); err != nil {
+		switch {
+		case meta.IsNoMatchError(err):
+			return "", "the cluster does not have the SecretProviderClass API; the Secrets Store CSI driver may not be installed", nil
+		case errors.IsNotFound(err):
+			return "", fmt.Sprintf("SecretProviderClass %s does not exist", key), nil
+		}
+		return "", "", fmt.Errorf("failed to get SecretProviderClass %s: %w", key, err)
+	}
+	return "generation-" + strconv.FormatInt(spc.GetGeneration(), 10), "", nil
+}
+
+// usesSecretProviders returns a Boolean value indicating whether any of the
+// given dns's credential references is a SecretProviderReferencePrefix
+// reference.
+func usesSecretProviders(dns *operatorv1.DNS) bool {
+	for _, transport := range encryptedTransports {
+		if _, ok := secretProviderClassName(strings.TrimSpace(dns.Annotations[transport.Annotation])); ok {
+			return true
+		}
+	}
+	var upstreamTLSSpecs []upstreamTLSSpec
+	if err := json.Unmarshal([]byte(dns.Annotations[UpstreamTLSAnnotation]), &upstreamTLSSpecs); err == nil {
+		for _, spec := range upstreamTLSSpecs {
+			if _, ok := secretProviderClassName(spec.ClientCertificateSecret); ok {
+				return true
+			}
+		}
+	}
+	var zoneTransferSpecs []zoneTransferSpec
+	if err := json.Unmarshal([]byte(dns.Annotations[ZoneTransfersAnnotation]), &zoneTransferSpecs); err == nil {
+		for _, spec := range zoneTransferSpecs {
+			if _, ok := secretProviderClassName(spec.TSIGSecret); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	corev1 "k8s.io/api/core/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// TestCredentialVolumeSource verifies that credentialVolumeSource and
+// credentialDir mount a secret and a SecretProviderClass in different volumes
+// and directories.
+func TestCredentialVolumeSource(t *testing.T) {
+	readOnly := true
+	testCases := []struct {
+		ref            string
+		expectedSource corev1.VolumeSource
+		expectedDir    string
+	}{
+		{
+			ref:            "lab-transfer-key",
+			expectedSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "lab-transfer-key"}},
+			expectedDir:    zoneTransferTSIGDir + "/lab-transfer-key",
+		},
+		{
+			ref: "csi:lab-transfer-key",
+			expectedSource: corev1.VolumeSource{CSI: &corev1.CSIVolumeSource{
+				Driver:           secretsStoreCSIDriver,
+				ReadOnly:         &readOnly,
+				VolumeAttributes: map[string]string{"secretProviderClass": "lab-transfer-key"},
+			}},
+			expectedDir: zoneTransferTSIGDir + "-csi/lab-transfer-key",
+		},
+	}
+	for _, tc := range testCases {
+		if actual := credentialVolumeSource(tc.ref); !reflect.DeepEqual(actual, tc.expectedSource) {
+			t.Errorf("%s: expected volume source %#v, got %#v", tc.ref, tc.expectedSource, actual)
+		}
+		if actual := credentialDir(zoneTransferTSIGDir, tc.ref); actual != tc.expectedDir {
+			t.Errorf("%s: expected directory %q, got %q", tc.ref, tc.expectedDir, actual)
+		}
+	}
+}
+
+// TestSecretProviderClassHash verifies that secretProviderClassHash reports a
+// missing SecretProviderClass and that its hash changes with the class's
+// generation.
+func TestSecretProviderClassHash(t *testing.T) {
+	c := &fakeObjectClient{objects: map[string]client.Object{}}
+	r := &reconciler{client: c}
+
+	hash, problem, err := r.secretProviderClassHash("corp-dns-client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hash) != 0 || problem != "SecretProviderClass openshift-dns/corp-dns-client does not exist" {
+		t.Errorf("expected a problem for a missing SecretProviderClass, got hash %q and problem %q", hash, problem)
+	}
+
+	spc := &unstructured.Unstructured{}
+	spc.SetGroupVersionKind(secretProviderClassGVK)
+	spc.SetNamespace(DefaultOperandNamespace)
+	spc.SetName("corp-dns-client")
+	spc.SetGeneration(1)
+	c.objects[fakeObjectKey(spc)] = spc
+	first, problem, err := r.secretProviderClassHash("corp-dns-client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first) == 0 || len(problem) != 0 {
+		t.Fatalf("expected a hash for an existing SecretProviderClass, got hash %q and problem %q", first, problem)
+	}
+
+	spc.SetGeneration(2)
+	second, _, err := r.secretProviderClassHash("corp-dns-client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second == first {
+		t.Errorf("expected the hash to change with the generation, got %q twice", first)
+	}
+}
+
+// TestUsesSecretProviders verifies that usesSecretProviders finds
+// SecretProviderReferencePrefix references in each of the annotations that
+// accept them.
+func TestUsesSecretProviders(t *testing.T) {
+	testCases := []struct {
+		annotations map[string]string
+		expected    bool
+	}{
+		{annotations: nil, expected: false},
+		{annotations: map[string]string{DNSOverTLSAnnotation: "dns-default-tls"}, expected: false},
+		{annotations: map[string]string{DNSOverTLSAnnotation: "csi:dns-default-tls"}, expected: true},
+		{annotations: map[string]string{DNSOverHTTPSAnnotation: " csi:dns-default-tls "}, expected: true},
+		{annotations: map[string]string{UpstreamTLSAnnotation: `[{"server": "corp", "clientCertificateSecret": "corp/corp-client"}]`}, expected: false},
+		{annotations: map[string]string{UpstreamTLSAnnotation: `[{"server": "corp", "clientCertificateSecret": "csi:corp-client"}]`}, expected: true},
+		{annotations: map[string]string{ZoneTransfersAnnotation: `[{"zone": "lab.example.com", "to": ["192.0.2.10"], "tsigSecret": "csi:lab-transfer-key"}]`}, expected: true},
+		{annotations: map[string]string{ZoneTransfersAnnotation: "not json"}, expected: false},
+	}
+	for _, tc := range testCases {
+		dns := &operatorv1.DNS{ObjectMeta: metav1.ObjectMeta{Name: "default", Annotations: tc.annotations}}
+		if actual := usesSecretProviders(dns); actual != tc.expected {
+			t.Errorf("%v: expected %t, got %t", tc.annotations, tc.expected, actual)
+		}
+	}
+}
@@ -3,11 +3,15 @@ package controller
 import (
 	"context"
 	"fmt"
+	"net"
+	"strconv"
+	"strings"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	operatorv1 "github.com/openshift/api/operator/v1"
 	"github.com/openshift/cluster-dns-operator/pkg/manifests"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
 
 	corev1 "k8s.io/api/core/v1"
 
@@ -15,15 +19,28 @@ import (
 
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// DNSServiceUpToDateConditionType is the type of the DNS status
+	// condition that indicates whether the DNS's service has the desired
+	// cluster IP or must be recreated to change it.
+	DNSServiceUpToDateConditionType = conditions.TypeServiceUpToDate
 )
 
 // ensureDNSService ensures that a service exists for a given DNS.
-func (r *reconciler) ensureDNSService(dns *operatorv1.DNS, clusterIP string, daemonsetRef metav1.OwnerReference) (bool, *corev1.Service, error) {
+func (r *reconciler) ensureDNSService(dns *operatorv1.DNS, clusterIP string, encryptedListeners []encryptedListener) (bool, *corev1.Service, error) {
 	haveService, current, err := r.currentDNSService(dns)
 	if err != nil {
 		return false, nil, err
 	}
-	desired := desiredDNSService(dns, clusterIP, daemonsetRef)
+	desired, err := desiredDNSService(dns, clusterIP, encryptedListeners)
+	if err != nil {
+		return haveService, current, fmt.Errorf("failed to build dns service: %v", err)
+	}
+	r.desiredState.record(dns.Name, desired)
 
 	switch {
 	case !haveService:
@@ -54,7 +71,9 @@ func (r *reconciler) currentDNSService(dns *operatorv1.DNS) (bool, *corev1.Servi
 	return true, current, nil
 }
 
-func desiredDNSService(dns *operatorv1.DNS, clusterIP string, daemonsetRef metav1.OwnerReference) *corev1.Service {
+// desiredDNSService returns the desired dns service, which has a port for each
+// of the given encrypted listeners.
+func desiredDNSService(dns *operatorv1.DNS, clusterIP string, encryptedListeners []encryptedListener) (*corev1.Service, error) {
 	s := manifests.DNSService()
 
 	name := DNSServiceName(dns)
@@ -71,11 +90,22 @@ func desiredDNSService(dns *operatorv1.DNS, clusterIP string, daemonsetRef metav
 	}
 
 	s.Spec.Selector = DNSDaemonSetPodSelector(dns).MatchLabels
+	s.Spec.PublishNotReadyAddresses = publishNotReadyAddresses(dns)
+	for _, listener := range encryptedListeners {
+		s.Spec.Ports = append(s.Spec.Ports, encryptedListenerServicePort(listener))
+	}
 
 	if len(clusterIP) > 0 {
 		s.Spec.ClusterIP = clusterIP
 	}
-	return s
+
+	hash, err := computeHash(s.Spec)
+	if err != nil {
+		return nil, err
+	}
+	setDesiredHash(s, hash)
+
+	return s, nil
 }
 
 func (r *reconciler) updateDNSService(current, desired *corev1.Service) (bool, error) {
@@ -115,7 +145,7 @@ func serviceChanged(current, expected *corev1.Service) (bool, *corev1.Service) {
 	expectedServingCertAnnotation := expected.ObjectMeta.Annotations[servingCertAnnotationKey]
 	annotationMatches := currentServingCertAnnotation == expectedServingCertAnnotation
 
-	if cmp.Equal(current.Spec, expected.Spec, serviceCmpOpts...) && annotationMatches {
+	if cmp.Equal(current.Spec, expected.Spec, serviceCmpOpts...) && annotationMatches && !hashChanged(current.Annotations, expected.Annotations) {
 		return false, nil
 	}
 
@@ -149,3 +179,97 @@ func cmpServiceType(a, b corev1.ServiceType) bool {
 	}
 	return a == b
 }
+
+// publishNotReadyAddresses returns a Boolean value indicating whether the
+// given dns's PublishNotReadyAddressesAnnotation annotation is set to true.
+func publishNotReadyAddresses(dns *operatorv1.DNS) bool {
+	value, ok := dns.Annotations[PublishNotReadyAddressesAnnotation]
+	if !ok {
+		return false
+	}
+	publish, err := strconv.ParseBool(value)
+	if err != nil {
+		logrus.Warningf("ignoring invalid value %q for annotation %s on dns %s: %v", value, PublishNotReadyAddressesAnnotation, dns.Name, err)
+		return false
+	}
+	return publish
+}
+
+// serviceRecreationAllowed returns a Boolean value indicating whether the
+// given dns's AllowServiceRecreationAnnotation annotation is set to true.
+func serviceRecreationAllowed(dns *operatorv1.DNS) bool {
+	value, ok := dns.Annotations[AllowServiceRecreationAnnotation]
+	if !ok {
+		return false
+	}
+	allowed, err := strconv.ParseBool(value)
+	if err != nil {
+		logrus.Warningf("ignoring invalid value %q for annotation %s on dns %s: %v", value, AllowServiceRecreationAnnotation, dns.Name, err)
+		return false
+	}
+	return allowed
+}
+
+// ensureDNSServiceRecreated deletes the given dns's service if the service's
+// cluster IP differs from the given cluster IP, which can only be changed by
+// recreating the service, and the dns allows the service to be recreated.
+// The caller must then ensure that the service exists.
+// ensureDNSServiceRecreated returns a status condition that describes whether
+// the service must be or is being recreated.
+func (r *reconciler) ensureDNSServiceRecreated(dns *operatorv1.DNS, clusterIP string) (operatorv1.OperatorCondition, error) {
+	condition := operatorv1.OperatorCondition{
+		Type:    DNSServiceUpToDateConditionType,
+		Status:  operatorv1.ConditionTrue,
+		Reason:  conditions.ReasonAsExpected,
+		Message: "The DNS service has the desired cluster IP.",
+	}
+	haveService, current, err := r.currentDNSService(dns)
+	if err != nil {
+		return condition, fmt.Errorf("failed to get dns service: %w", err)
+	}
+	if !haveService {
+		return condition, nil
+	}
+	reasons := serviceRecreationReasons(current, clusterIP)
+	if len(reasons) == 0 {
+		return condition, nil
+	}
+
+	if !serviceRecreationAllowed(dns) {
+		condition.Status = operatorv1.ConditionFalse
+		condition.Reason = conditions.ReasonRecreationRequired
+		condition.Message = fmt.Sprintf("The DNS service must be recreated because %s.  Recreating the service briefly interrupts DNS resolution through its cluster IP.  Set the %s annotation to \"true\" to allow the operator to recreate it.", strings.Join(reasons, " and "), AllowServiceRecreationAnnotation)
+		return condition, nil
+	}
+
+	// Use a precondition so that a service that was already recreated is
+	// not deleted.
+	uid := current.UID
+	if err := r.client.Delete(context.TODO(), current, &client.DeleteOptions{Preconditions: &metav1.Preconditions{UID: &uid}}); err != nil && !errors.IsNotFound(err) {
+		return condition, fmt.Errorf("failed to delete dns service %s/%s: %w", current.Namespace, current.Name, err)
+	}
+	logrus.Infof("deleted dns service %s/%s for recreation because %s", current.Namespace, current.Name, strings.Join(reasons, " and "))
+	condition.Status = operatorv1.ConditionFalse
+	condition.Reason = conditions.ReasonRecreating
+	condition.Message = fmt.Sprintf("The DNS service was deleted to be recreated because %s.", strings.Join(reasons, " and "))
+	return condition, nil
+}
+
+// serviceRecreationReasons returns the reasons for which the given service
+// must be recreated to have the given cluster IP, or nil if it need not be.
+func serviceRecreationReasons(current *corev1.Service, clusterIP string) []string {
+	if len(clusterIP) == 0 || len(current.Spec.ClusterIP) == 0 || current.Spec.ClusterIP == corev1.ClusterIPNone || current.Spec.ClusterIP == clusterIP {
+		return nil
+	}
+	reasons := []string{fmt.Sprintf("its cluster IP must change from %s to %s", current.Spec.ClusterIP, clusterIP)}
+	if len(current.Spec.IPFamilies) != 0 {
+		family := corev1.IPv4Protocol
+		if ip := net.ParseIP(clusterIP); ip != nil && ip.To4() == nil {
+			family = corev1.IPv6Protocol
+		}
+		if current.Spec.IPFamilies[0] != family {
+			reasons = append(reasons, fmt.Sprintf("its primary IP family must change from %s to %s", current.Spec.IPFamilies[0], family))
+		}
+	}
+	return reasons
+}
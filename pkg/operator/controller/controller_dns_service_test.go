@@ -41,6 +41,13 @@ func TestDNSServiceChanged(t *testing.T) {
 			},
 			expect: true,
 		},
+		{
+			description: "if .spec.publishNotReadyAddresses changes",
+			mutate: func(service *corev1.Service) {
+				service.Spec.PublishNotReadyAddresses = true
+			},
+			expect: true,
+		},
 		{
 			description: "if .spec.type is defaulted",
 			mutate: func(service *corev1.Service) {
@@ -135,3 +142,56 @@ func TestDNSServiceChanged(t *testing.T) {
 		}
 	}
 }
+
+// TestServiceRecreationReasons verifies that serviceRecreationReasons
+// requires recreation only when the service's cluster IP must change and
+// reports a change of the primary IP family.
+func TestServiceRecreationReasons(t *testing.T) {
+	service := func(clusterIP string, families ...corev1.IPFamily) *corev1.Service {
+		return &corev1.Service{Spec: corev1.ServiceSpec{ClusterIP: clusterIP, IPFamilies: families}}
+	}
+	testCases := []struct {
+		description string
+		current     *corev1.Service
+		clusterIP   string
+		expected    int
+	}{
+		{
+			description: "same cluster IP",
+			current:     service("172.30.0.10", corev1.IPv4Protocol),
+			clusterIP:   "172.30.0.10",
+			expected:    0,
+		},
+		{
+			description: "no desired cluster IP",
+			current:     service("172.30.0.10", corev1.IPv4Protocol),
+			expected:    0,
+		},
+		{
+			description: "headless service",
+			current:     service(corev1.ClusterIPNone),
+			clusterIP:   "172.30.0.10",
+			expected:    0,
+		},
+		{
+			description: "different cluster IP",
+			current:     service("172.30.0.10", corev1.IPv4Protocol),
+			clusterIP:   "172.31.0.10",
+			expected:    1,
+		},
+		{
+			description: "different primary IP family",
+			current:     service("172.30.0.10", corev1.IPv4Protocol, corev1.IPv6Protocol),
+			clusterIP:   "fd02::a",
+			expected:    2,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			reasons := serviceRecreationReasons(tc.current, tc.clusterIP)
+			if len(reasons) != tc.expected {
+				t.Errorf("expected %d reasons, got %v", tc.expected, reasons)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	// DNSStaticHostsAppliedConditionType is the type of the DNS status
+	// condition that reports the static host entries that the dns serves
+	// and the entries that it ignores.  The condition is reported only if
+	// the dns's static hosts configmap exists.
+	DNSStaticHostsAppliedConditionType = conditions.TypeStaticHostsApplied
+
+	// staticHostsKey is the key of the entries in the static hosts
+	// configmap.
+	staticHostsKey = "hosts"
+)
+
+// staticHost is an entry for CoreDNS's hosts plugin.  CoreDNS answers A or AAAA
+// queries for the names with the address and PTR queries for the address with
+// the first name.  Names are lowercase and have no trailing dot.
+type staticHost struct {
+	IP    string
+	Names []string
+}
+
+// dnsStaticHosts returns the valid static host entries in the given dns's
+// static hosts configmap and a status condition that reports them, or nil and
+// a nil condition if the configmap does not exist.  Entries must not have
+// names in the cluster domain or in the zones that the given servers and IdM
+// DNS servers serve, because the default server block, which serves the
+// entries, does not serve those names.
+func (r *reconciler) dnsStaticHosts(dns *operatorv1.DNS, servers []operatorv1.Server, idmResolvers []idmResolver, clusterDomain string) ([]staticHost, *operatorv1.OperatorCondition, error) {
+	cm := &corev1.ConfigMap{}
+	name := DNSStaticHostsConfigMapName(dns)
+	if err := r.cache.Get(context.TODO(), name, cm); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil, nil
+		}
+		return nil, nil, fmt.Errorf("failed to get static hosts configmap %s/%s: %w", name.Namespace, name.Name, err)
+	}
+
+	hosts, problems := parseStaticHosts(cm.Data[staticHostsKey], clusterDomain, otherServerZones(servers, idmResolvers))
+	condition := computeStaticHostsAppliedCondition(types.NamespacedName{Namespace: cm.Namespace, Name: cm.Name}, hosts, problems)
+	return hosts, &condition, nil
+}
+
+// parseStaticHosts parses the given static host entries, which are in the
+// format of /etc/hosts, and returns the valid entries and the problems with the
+// invalid entries and names, which are ignored.  Each entry has the form
+// "<address> <name> [<name>...]", where <address> is an IPv4 or IPv6 address.
+// Text from "#" to the end of a line is a comment.  A name is invalid if it is
+// in the given cluster domain or in one of the given zones, which are
+// normalized with normalizeZone.
+func parseStaticHosts(data, clusterDomain string, zones sets.String) ([]staticHost, []string) {
+	var (
+		hosts    []staticHost
+		problems []string
+	)
+	clusterDomain = normalizeZone(clusterDomain)
+	for i, line := range strings.Split(data, "\n") {
+		if j := strings.Index(line, "#"); j >= 0 {
+			line = line[:j]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 2 {
+			problems = append(problems, fmt.Sprintf("line %d: expected \"<address> <name> [<name>...]\"", i+1))
+			continue
+		}
+		ip := net.ParseIP(fields[0])
+		if ip == nil {
+			problems = append(problems, fmt.Sprintf("line %d: %q is not a valid IP address", i+1, fields[0]))
+			continue
+		}
+		host := staticHost{IP: ip.String()}
+		for _, name := range fields[1:] {
+			fqdn := normalizeZone(name)
+			switch {
+			case !validDomainName(fqdn):
+				problems = append(problems, fmt.Sprintf("line %d: %q is not a valid domain name", i+1, name))
+			case nameInZone(fqdn, clusterDomain):
+				problems = append(problems, fmt.Sprintf("line %d: %q is in the cluster domain", i+1, name))
+			case nameInAnyZone(fqdn, zones):
+				problems = append(problems, fmt.Sprintf("line %d: %q is in a zone that another server serves", i+1, name))
+			default:
+				host.Names = append(host.Names, strings.TrimSuffix(fqdn, "."))
+			}
+		}
+		if len(host.Names) != 0 {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts, problems
+}
+
+// computeStaticHostsAppliedCondition returns a status condition that reports
+// the given static host entries from the configmap with the given name and the
+// problems with the entries and names that were ignored.
+func computeStaticHostsAppliedCondition(name types.NamespacedName, hosts []staticHost, problems []string) operatorv1.OperatorCondition {
+	condition := operatorv1.OperatorCondition{
+		Type: DNSStaticHostsAppliedConditionType,
+	}
+	if len(problems) != 0 {
+		condition.Status = operatorv1.ConditionFalse
+		condition.Reason = conditions.ReasonStaticHostsIgnored
+		condition.Message = fmt.Sprintf("Some static host entries in configmap %s/%s were ignored: %s.  Serving %d static host entries.", name.Namespace, name.Name, strings.Join(problems, "; "), len(hosts))
+		return condition
+	}
+	condition.Status = operatorv1.ConditionTrue
+	condition.Reason = conditions.ReasonAsExpected
+	condition.Message = fmt.Sprintf("Serving %d static host entries from configmap %s/%s.", len(hosts), name.Namespace, name.Name)
+	return condition
+}
@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// TestParseStaticHosts verifies that parseStaticHosts parses entries in the
+// format of /etc/hosts and ignores names that the default server block does
+// not serve.
+func TestParseStaticHosts(t *testing.T) {
+	data := `# Air-gapped registry.
+10.0.0.10 Registry.Example.com mirror.example.com.  # primary
+fd00::10 registry.example.com
+
+10.0.0.11 api.svc.cluster.local appliance.example.com
+10.0.0.12 www.foo.com
+10.0.0.300 bad.example.com
+10.0.0.13 bad_name.example.com
+10.0.0.14
+`
+	expectedHosts := []staticHost{
+		{IP: "10.0.0.10", Names: []string{"registry.example.com", "mirror.example.com"}},
+		{IP: "fd00::10", Names: []string{"registry.example.com"}},
+		{IP: "10.0.0.11", Names: []string{"appliance.example.com"}},
+	}
+	hosts, problems := parseStaticHosts(data, "cluster.local", sets.NewString("foo.com."))
+	if !reflect.DeepEqual(hosts, expectedHosts) {
+		t.Errorf("expected hosts %+v, got %+v", expectedHosts, hosts)
+	}
+	expectedProblems := []string{
+		`line 5: "api.svc.cluster.local" is in the cluster domain`,
+		`line 6: "www.foo.com" is in a zone that another server serves`,
+		`line 7: "10.0.0.300" is not a valid IP address`,
+		`line 8: "bad_name.example.com" is not a valid domain name`,
+		`line 9: expected "<address> <name> [<name>...]"`,
+	}
+	if !reflect.DeepEqual(problems, expectedProblems) {
+		t.Errorf("expected problems:\n%s\ngot:\n%s", strings.Join(expectedProblems, "\n"), strings.Join(problems, "\n"))
+	}
+}
+
+// TestDesiredDNSConfigMapStaticHosts verifies that the default server block
+// has a hosts plugin stanza with the static host entries that falls through to
+// the other plugins.
+func TestDesiredDNSConfigMapStaticHosts(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DefaultDNSController,
+		},
+	}
+	hosts := []staticHost{
+		{IP: "10.0.0.10", Names: []string{"registry.example.com", "mirror.example.com"}},
+		{IP: "fd00::10", Names: []string{"registry.example.com"}},
+	}
+	cm, err := desiredDNSConfigMap(dns, corefileInputs{StaticHosts: hosts, ClusterDomain: "cluster.local", MetricsAddress: "127.0.0.1:9153"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `    ready
+    hosts {
+        10.0.0.10 registry.example.com mirror.example.com
+        fd00::10 registry.example.com
+        fallthrough
+    }
+    kubernetes cluster.local in-addr.arpa ip6.arpa {`
+	if !strings.Contains(cm.Data["Corefile"], expected) {
+		t.Errorf("expected Corefile to contain:\n%s\ngot:\n%s", expected, cm.Data["Corefile"])
+	}
+}
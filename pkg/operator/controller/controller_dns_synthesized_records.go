@@ -0,0 +1,290 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	// DNSSynthesizedRecordsAppliedConditionType is the type of the DNS
+	// status condition that reports the synthesized records that the dns
+	// serves and the records that it ignores.  The condition is reported
+	// only if the dns's synthesized records configmap exists.
+	DNSSynthesizedRecordsAppliedConditionType = conditions.TypeSynthesizedRecordsApplied
+
+	// synthesizedRecordsKey is the key of the records in the synthesized
+	// records configmap.
+	synthesizedRecordsKey = "records"
+)
+
+var (
+	// synthesizedRecordClasses and synthesizedRecordTypes are the query
+	// classes and types that a synthesized record may answer.
+	synthesizedRecordClasses = sets.NewString("IN", "CH", "ANY")
+	synthesizedRecordTypes   = sets.NewString("A", "AAAA", "CNAME", "MX", "NS", "PTR", "SRV", "TXT", "ANY")
+	// synthesizedRecordRcodes are the response codes that a synthesized
+	// record may return.
+	synthesizedRecordRcodes = sets.NewString("NOERROR", "FORMERR", "SERVFAIL", "NXDOMAIN", "NOTIMP", "REFUSED")
+	// synthesizedRecordFuncs stands in for the functions that CoreDNS's
+	// template plugin provides to answer templates.
+	synthesizedRecordFuncs = template.FuncMap{
+		"parseInt": func(s string, base, bits int) (int64, error) { return 0, nil },
+		"meta":     func(name string) string { return "" },
+	}
+)
+
+// synthesizedRecordSpec is an entry in the value of the records key of a dns's
+// synthesized records configmap.
+type synthesizedRecordSpec struct {
+	// Zone is the zone whose names the record answers for.
+	Zone string `json:"zone"`
+	// Class is the query class that the record answers.  The default is
+	// "IN".
+	Class string `json:"class,omitempty"`
+	// Type is the query type that the record answers, such as "A".
+	Type string `json:"type"`
+	// Match are regular expressions for the query names that the record
+	// answers.  If empty, the record answers every name in the zone.
+	Match []string `json:"match,omitempty"`
+	// Answer, Additional, and Authority are Go templates for the resource
+	// records in the corresponding sections of the response.
+	Answer     []string `json:"answer,omitempty"`
+	Additional []string `json:"additional,omitempty"`
+	Authority  []string `json:"authority,omitempty"`
+	// Rcode is the response code.  The default is "NOERROR".
+	Rcode string `json:"rcode,omitempty"`
+	// Fallthrough, if true, passes queries whose names match none of the
+	// regular expressions to the plugins after the template plugin.
+	Fallthrough bool `json:"fallthrough,omitempty"`
+}
+
+// synthesizedRecord is a validated synthesizedRecordSpec for CoreDNS's template
+// plugin.  Zone is lowercase and fully qualified.
+type synthesizedRecord struct {
+	Zone        string
+	Class       string
+	Type        string
+	Match       []string
+	Answer      []string
+	Additional  []string
+	Authority   []string
+	Rcode       string
+	Fallthrough bool
+}
+
+// synthesizedRecordData stands in for the data that CoreDNS's template plugin
+// passes to answer templates.
+type synthesizedRecordData struct {
+	Zone     string
+	Name     string
+	Regex    string
+	Match    []string
+	Group    map[string]string
+	Class    string
+	Type     string
+	Message  map[string]interface{}
+	Question map[string]interface{}
+	Remote   string
+}
+
+// dnsSynthesizedRecords returns the valid synthesized records in the given
+// dns's synthesized records configmap and a status condition that reports
+// them, or nil and a nil condition if the configmap does not exist.  Records
+// must not answer for names in the cluster domain or in the zones that the
+// given servers and IdM DNS servers serve, because the default server block,
+// which serves the records, does not serve those names.
+func (r *reconciler) dnsSynthesizedRecords(dns *operatorv1.DNS, servers []operatorv1.Server, idmResolvers []idmResolver, clusterDomain string) ([]synthesizedRecord, *operatorv1.OperatorCondition, error) {
+	cm := &corev1.ConfigMap{}
+	name := DNSSynthesizedRecordsConfigMapName(dns)
+	if err := r.cache.Get(context.TODO(), name, cm); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil, nil
+		}
+		return nil, nil, fmt.Errorf("failed to get synthesized records configmap %s/%s: %w", name.Namespace, name.Name, err)
+	}
+
+	records, problems := parseSynthesizedRecords(cm.Data[synthesizedRecordsKey], clusterDomain, otherServerZones(servers, idmResolvers))
+	condition := computeSynthesizedRecordsAppliedCondition(types.NamespacedName{Namespace: cm.Namespace, Name: cm.Name}, records, problems)
+	return records, &condition, nil
+}
+
+// parseSynthesizedRecords parses the given JSON list of synthesized record
+// specs and returns the valid records and the problems with the invalid
+// records, which are ignored.  A record is invalid if its zone is in, or
+// contains, the given cluster domain or is in one of the given zones, which are
+// normalized with normalizeZone, or if its templates do not render resource
+// records.
+func parseSynthesizedRecords(data, clusterDomain string, zones sets.String) ([]synthesizedRecord, []string) {
+	if len(strings.TrimSpace(data)) == 0 {
+		return nil, nil
+	}
+	var specs []synthesizedRecordSpec
+	if err := json.Unmarshal([]byte(data), &specs); err != nil {
+		return nil, []string{fmt.Sprintf("invalid JSON: %v", err)}
+	}
+	var (
+		records  []synthesizedRecord
+		problems []string
+	)
+	clusterDomain = normalizeZone(clusterDomain)
+	for i, spec := range specs {
+		record, err := parseSynthesizedRecord(spec, clusterDomain, zones)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("record %d: %v", i, err))
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, problems
+}
+
+// parseSynthesizedRecord validates the given synthesized record spec and
+// returns the corresponding synthesized record.
+func parseSynthesizedRecord(spec synthesizedRecordSpec, clusterDomain string, zones sets.String) (synthesizedRecord, error) {
+	record := synthesizedRecord{
+		Zone:        normalizeZone(spec.Zone),
+		Class:       strings.ToUpper(spec.Class),
+		Type:        strings.ToUpper(spec.Type),
+		Match:       spec.Match,
+		Answer:      spec.Answer,
+		Additional:  spec.Additional,
+		Authority:   spec.Authority,
+		Rcode:       strings.ToUpper(spec.Rcode),
+		Fallthrough: spec.Fallthrough,
+	}
+	if len(record.Class) == 0 {
+		record.Class = "IN"
+	}
+	switch {
+	case len(spec.Zone) == 0:
+		return record, fmt.Errorf("zone must be specified")
+	case !validDomainName(record.Zone):
+		return record, fmt.Errorf("%q is not a valid domain name", spec.Zone)
+	case nameInZone(record.Zone, clusterDomain) || nameInZone(clusterDomain, record.Zone):
+		return record, fmt.Errorf("zone %q overlaps the cluster domain", spec.Zone)
+	case nameInAnyZone(record.Zone, zones):
+		return record, fmt.Errorf("zone %q is in a zone that another server serves", spec.Zone)
+	case !synthesizedRecordClasses.Has(record.Class):
+		return record, fmt.Errorf("class %q must be one of %s", spec.Class, strings.Join(synthesizedRecordClasses.List(), ", "))
+	case !synthesizedRecordTypes.Has(record.Type):
+		return record, fmt.Errorf("type %q must be one of %s", spec.Type, strings.Join(synthesizedRecordTypes.List(), ", "))
+	case len(record.Rcode) != 0 && !synthesizedRecordRcodes.Has(record.Rcode):
+		return record, fmt.Errorf("rcode %q must be one of %s", spec.Rcode, strings.Join(synthesizedRecordRcodes.List(), ", "))
+	case len(record.Answer) == 0 && len(record.Additional) == 0 && len(record.Authority) == 0 && len(record.Rcode) == 0:
+		return record, fmt.Errorf("answer, additional, authority, or rcode must be specified")
+	}
+
+	data := synthesizedRecordData{
+		Zone:     record.Zone,
+		Name:     record.Zone,
+		Group:    map[string]string{},
+		Class:    record.Class,
+		Type:     record.Type,
+		Message:  map[string]interface{}{},
+		Question: map[string]interface{}{"Name": record.Zone},
+		Remote:   "127.0.0.1",
+	}
+	for _, expr := range record.Match {
+		if err := validCorefileArgument(expr); err != nil {
+			return record, fmt.Errorf("match %q %v", expr, err)
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return record, fmt.Errorf("invalid match %q: %v", expr, err)
+		}
+		// Give every capture group of every expression a value so
+		// that templates can index the groups that they use.
+		if n := re.NumSubexp() + 1; n > len(data.Match) {
+			data.Match = make([]string, n)
+		}
+		for _, group := range re.SubexpNames() {
+			if len(group) != 0 {
+				data.Group[group] = ""
+			}
+		}
+	}
+	if len(data.Match) == 0 {
+		data.Match = []string{""}
+	}
+	for _, section := range [][]string{record.Answer, record.Additional, record.Authority} {
+		for _, text := range section {
+			if err := validateSynthesizedRecordTemplate(text, data); err != nil {
+				return record, err
+			}
+		}
+	}
+	return record, nil
+}
+
+// validateSynthesizedRecordTemplate returns an error if the given template does
+// not parse or, when it is executed with the given data, does not render a
+// resource record of the form "<name> [<ttl>] [<class>] <type> <data>".
+func validateSynthesizedRecordTemplate(text string, data synthesizedRecordData) error {
+	if err := validCorefileArgument(text); err != nil {
+		return fmt.Errorf("template %q %v", text, err)
+	}
+	tmpl, err := template.New("record").Funcs(synthesizedRecordFuncs).Parse(text)
+	if err != nil {
+		return fmt.Errorf("invalid template %q: %v", text, err)
+	}
+	rr := new(bytes.Buffer)
+	if err := tmpl.Execute(rr, data); err != nil {
+		return fmt.Errorf("failed to execute template %q: %v", text, err)
+	}
+	if len(strings.Fields(rr.String())) < 3 {
+		return fmt.Errorf("template %q renders %q, which is not a resource record", text, rr.String())
+	}
+	return nil
+}
+
+// validCorefileArgument returns an error if the given value cannot be quoted as
+// an argument in a Corefile with corefileQuote.
+func validCorefileArgument(value string) error {
+	switch {
+	case strings.ContainsAny(value, "\n\r"):
+		return fmt.Errorf("must not contain line breaks")
+	case strings.Contains(value, `\"`) || strings.HasSuffix(value, `\`):
+		return fmt.Errorf(`must not contain \" or end with \`)
+	}
+	return nil
+}
+
+// corefileQuote returns the given value as a quoted Corefile argument.  CoreDNS
+// unescapes only quotes in quoted arguments, so values must be valid according
+// to validCorefileArgument.
+func corefileQuote(value string) string {
+	return `"` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+}
+
+// computeSynthesizedRecordsAppliedCondition returns a status condition that
+// reports the given synthesized records from the configmap with the given name
+// and the problems with the records that were ignored.
+func computeSynthesizedRecordsAppliedCondition(name types.NamespacedName, records []synthesizedRecord, problems []string) operatorv1.OperatorCondition {
+	condition := operatorv1.OperatorCondition{
+		Type: DNSSynthesizedRecordsAppliedConditionType,
+	}
+	if len(problems) != 0 {
+		condition.Status = operatorv1.ConditionFalse
+		condition.Reason = conditions.ReasonSynthesizedRecordsIgnored
+		condition.Message = fmt.Sprintf("Some synthesized records in configmap %s/%s were ignored: %s.  Serving %d synthesized records.", name.Namespace, name.Name, strings.Join(problems, "; "), len(records))
+		return condition
+	}
+	condition.Status = operatorv1.ConditionTrue
+	condition.Reason = conditions.ReasonAsExpected
+	condition.Message = fmt.Sprintf("Serving %d synthesized records from configmap %s/%s.", len(records), name.Namespace, name.Name)
+	return condition
+}
@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// TestParseSynthesizedRecords verifies that parseSynthesizedRecords parses
+// valid records and ignores records whose zones the default server block does
+// not serve or whose templates do not render resource records.
+func TestParseSynthesizedRecords(t *testing.T) {
+	data := `[
+	{"zone": "Lab.Example.com", "type": "a", "match": ["^(?P<host>[a-z0-9-]+)\\.lab\\.example\\.com\\.$"], "answer": ["{{ .Name }} 60 IN A 10.0.0.10"], "fallthrough": true},
+	{"zone": "blocked.example.com", "type": "ANY", "rcode": "nxdomain"},
+	{"zone": "svc.cluster.local", "type": "A", "answer": ["{{ .Name }} 60 IN A 10.0.0.1"]},
+	{"zone": "local", "type": "A", "answer": ["{{ .Name }} 60 IN A 10.0.0.1"]},
+	{"zone": "www.foo.com", "type": "A", "answer": ["{{ .Name }} 60 IN A 10.0.0.1"]},
+	{"zone": "example.org", "type": "HINFO", "answer": ["{{ .Name }} 60 IN HINFO a b"]},
+	{"zone": "example.org", "type": "A"},
+	{"zone": "example.org", "type": "A", "match": ["(unclosed"], "answer": ["{{ .Name }} 60 IN A 10.0.0.1"]},
+	{"zone": "example.org", "type": "A", "answer": ["{{ .Name 60 IN A 10.0.0.1"]},
+	{"zone": "example.org", "type": "A", "answer": ["{{ index .Match 1 }} 60 IN A 10.0.0.1"]},
+	{"zone": "example.org", "type": "A", "answer": ["{{ .Name }}"]}
+]`
+	expectedRecords := []synthesizedRecord{
+		{
+			Zone:        "lab.example.com.",
+			Class:       "IN",
+			Type:        "A",
+			Match:       []string{`^(?P<host>[a-z0-9-]+)\.lab\.example\.com\.$`},
+			Answer:      []string{"{{ .Name }} 60 IN A 10.0.0.10"},
+			Fallthrough: true,
+		},
+		{
+			Zone:  "blocked.example.com.",
+			Class: "IN",
+			Type:  "ANY",
+			Rcode: "NXDOMAIN",
+		},
+	}
+	records, problems := parseSynthesizedRecords(data, "cluster.local", sets.NewString("foo.com."))
+	if !reflect.DeepEqual(records, expectedRecords) {
+		t.Errorf("expected records %+v, got %+v", expectedRecords, records)
+	}
+	expectedPrefixes := []string{
+		`record 2: zone "svc.cluster.local" overlaps the cluster domain`,
+		`record 3: zone "local" overlaps the cluster domain`,
+		`record 4: zone "www.foo.com" is in a zone that another server serves`,
+		`record 5: type "HINFO" must be one of`,
+		`record 6: answer, additional, authority, or rcode must be specified`,
+		`record 7: invalid match "(unclosed"`,
+		`record 8: invalid template "{{ .Name 60 IN A 10.0.0.1"`,
+		`record 9: failed to execute template "{{ index .Match 1 }} 60 IN A 10.0.0.1"`,
+		`record 10: template "{{ .Name }}" renders "example.org.", which is not a resource record`,
+	}
+	if len(problems) != len(expectedPrefixes) {
+		t.Fatalf("expected %d problems, got %d:\n%s", len(expectedPrefixes), len(problems), strings.Join(problems, "\n"))
+	}
+	for i, prefix := range expectedPrefixes {
+		if !strings.HasPrefix(problems[i], prefix) {
+			t.Errorf("expected problem %d to start with %q, got %q", i, prefix, problems[i])
+		}
+	}
+}
+
+// TestDesiredDNSConfigMapSynthesizedRecords verifies that the default server
+// block has a template plugin stanza for each synthesized record, with quoted
+// regular expressions and templates.
+func TestDesiredDNSConfigMapSynthesizedRecords(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DefaultDNSController,
+		},
+	}
+	records := []synthesizedRecord{
+		{
+			Zone:        "lab.example.com.",
+			Class:       "IN",
+			Type:        "TXT",
+			Match:       []string{`^(?P<host>[a-z0-9-]+)\.lab\.example\.com\.$`},
+			Answer:      []string{`{{ .Name }} 60 IN TXT "{{ .Group.host }}"`},
+			Fallthrough: true,
+		},
+		{
+			Zone:  "blocked.example.com.",
+			Class: "IN",
+			Type:  "ANY",
+			Rcode: "NXDOMAIN",
+		},
+	}
+	cm, err := desiredDNSConfigMap(dns, corefileInputs{SynthesizedRecords: records, ClusterDomain: "cluster.local", MetricsAddress: "127.0.0.1:9153"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `    ready
+    template IN TXT lab.example.com. {
+        match "^(?P<host>[a-z0-9-]+)\.lab\.example\.com\.$"
+        answer "{{ .Name }} 60 IN TXT \"{{ .Group.host }}\""
+        fallthrough
+    }
+    template IN ANY blocked.example.com. {
+        rcode NXDOMAIN
+    }
+    kubernetes cluster.local in-addr.arpa ip6.arpa {`
+	if !strings.Contains(cm.Data["Corefile"], expected) {
+		t.Errorf("expected Corefile to contain:\n%s\ngot:\n%s", expected, cm.Data["Corefile"])
+	}
+}
@@ -0,0 +1,388 @@
+package controller
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// DNSTLSCertificatesExpiringConditionType is the type of the DNS
+	// status condition that reports whether the certificates that the
+	// dns's DNS-over-TLS and DNS-over-HTTPS upstreams present, or that its
+	// encrypted listeners serve, expire soon.  The condition is reported
+	// only if the dns has such upstreams or listeners.
+	DNSTLSCertificatesExpiringConditionType = conditions.TypeTLSCertificatesExpiring
+
+	// tlsCertificateExpiryThreshold is how long before a certificate
+	// expires that the TLSCertificatesExpiring condition reports that it
+	// must be rotated.
+	tlsCertificateExpiryThreshold = 30 * 24 * time.Hour
+	// tlsCertificateCheckPeriod is how often the operator probes the
+	// upstreams for their certificates, which the upstreams rotate without
+	// the operator's knowledge.
+	tlsCertificateCheckPeriod = time.Hour
+	// tlsCertificateProbeTimeout is how long the operator waits for an
+	// upstream to complete a TLS handshake.
+	tlsCertificateProbeTimeout = 5 * time.Second
+
+	// tlsCertificateKindUpstream and tlsCertificateKindListener are the
+	// values of the kind label of the certificate expiry metric.
+	tlsCertificateKindUpstream = "upstream"
+	tlsCertificateKindListener = "listener"
+)
+
+var tlsCertificateExpiryDesc = prometheus.NewDesc(
+	"dns_tls_certificate_expiry_timestamp_seconds",
+	"When the certificate that a DNS-over-TLS or DNS-over-HTTPS upstream of a DNS presents, or that an encrypted listener of a DNS serves, expires, in seconds since the Unix epoch.",
+	[]string{"dns", "kind", "name"}, nil,
+)
+
+// tlsCertificateTarget is an upstream or an encrypted listener whose
+// certificate's expiry the operator tracks.
+type tlsCertificateTarget struct {
+	// Kind is tlsCertificateKindUpstream or tlsCertificateKindListener.
+	Kind string
+	// Name is the upstream, or the name of the listener's transport.
+	Name string
+	// Address is the host:port address that the operator probes for an
+	// upstream's certificate.
+	Address string
+	// ServerName is the name that the operator sends when it probes an
+	// upstream, or empty.
+	ServerName string
+	// SecretName is the name of the secret with a listener's serving
+	// certificate.
+	SecretName string
+}
+
+// String returns a description of the target for status conditions.
+func (t tlsCertificateTarget) String() string {
+	if t.Kind == tlsCertificateKindListener {
+		return fmt.Sprintf("the %s listener's certificate in secret %s/%s", t.Name, DefaultOperandNamespace, t.SecretName)
+	}
+	return fmt.Sprintf("upstream %s", t.Name)
+}
+
+// tlsCertificateExpiry is when a target's certificate expires, or why the
+// operator could not tell.
+type tlsCertificateExpiry struct {
+	Target tlsCertificateTarget
+	Expiry time.Time
+	Err    error
+}
+
+// tlsCertificateProbe is the cached result of probing an upstream.
+type tlsCertificateProbe struct {
+	expiry  time.Time
+	err     error
+	checked time.Time
+}
+
+// tlsCertificateExpiries tracks when the certificates of the dnses' TLS
+// upstreams and encrypted listeners expire.  It caches the results of probing
+// the upstreams, and it is a prometheus.Collector that reports the expiries
+// that were most recently computed for each dns.  A nil tlsCertificateExpiries
+// probes the upstreams on every call and records nothing.
+type tlsCertificateExpiries struct {
+	lock sync.Mutex
+	// probe returns when the certificate that the upstream at the given
+	// address presents for the given server name expires.
+	probe func(address, serverName string) (time.Time, error)
+	// probes has the results of probing the upstreams, by address and
+	// server name.
+	probes map[string]tlsCertificateProbe
+	// expiries has the expiries that were most recently computed for each
+	// dns, by dns name.
+	expiries map[string][]tlsCertificateExpiry
+}
+
+// newTLSCertificateExpiries returns an empty tlsCertificateExpiries.
+func newTLSCertificateExpiries() *tlsCertificateExpiries {
+	return &tlsCertificateExpiries{
+		probe:    probeTLSCertificateExpiry,
+		probes:   map[string]tlsCertificateProbe{},
+		expiries: map[string][]tlsCertificateExpiry{},
+	}
+}
+
+// upstreamExpiry returns when the certificate of the given upstream target
+// expires, probing the upstream unless it was probed less than
+// tlsCertificateCheckPeriod before the given time.
+func (e *tlsCertificateExpiries) upstreamExpiry(target tlsCertificateTarget, now time.Time) (time.Time, error) {
+	if e == nil {
+		return probeTLSCertificateExpiry(target.Address, target.ServerName)
+	}
+	key := target.Address + "/" + target.ServerName
+	e.lock.Lock()
+	probe, ok := e.probes[key]
+	e.lock.Unlock()
+	if ok && now.Sub(probe.checked) < tlsCertificateCheckPeriod {
+		return probe.expiry, probe.err
+	}
+	expiry, err := e.probe(target.Address, target.ServerName)
+	e.lock.Lock()
+	e.probes[key] = tlsCertificateProbe{expiry: expiry, err: err, checked: now}
+	e.lock.Unlock()
+	return expiry, err
+}
+
+// record records the given expiries for the dns with the given name and
+// forgets the probes of upstreams that no dns uses any more.
+func (e *tlsCertificateExpiries) record(dnsName string, expiries []tlsCertificateExpiry) {
+	if e == nil {
+		return
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if len(expiries) == 0 {
+		delete(e.expiries, dnsName)
+	} else {
+		e.expiries[dnsName] = expiries
+	}
+	used := map[string]bool{}
+	for _, expiries := range e.expiries {
+		for _, expiry := range expiries {
+			used[expiry.Target.Address+"/"+expiry.Target.ServerName] = true
+		}
+	}
+	for key := range e.probes {
+		if !used[key] {
+			delete(e.probes, key)
+		}
+	}
+}
+
+// forget forgets the expiries of the dns with the given name.
+func (e *tlsCertificateExpiries) forget(dnsName string) {
+	e.record(dnsName, nil)
+}
+
+// tracking returns a Boolean value indicating whether the dns with the given
+// name has certificates whose expiry is tracked.
+func (e *tlsCertificateExpiries) tracking(dnsName string) bool {
+	if e == nil {
+		return false
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return len(e.expiries[dnsName]) != 0
+}
+
+// Describe implements prometheus.Collector.
+func (e *tlsCertificateExpiries) Describe(ch chan<- *prometheus.Desc) {
+	ch <- tlsCertificateExpiryDesc
+}
+
+// Collect implements prometheus.Collector.
+func (e *tlsCertificateExpiries) Collect(ch chan<- prometheus.Metric) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	for dnsName, expiries := range e.expiries {
+		for _, expiry := range expiries {
+			if expiry.Err != nil {
+				continue
+			}
+			ch <- prometheus.MustNewConstMetric(tlsCertificateExpiryDesc, prometheus.GaugeValue, float64(expiry.Expiry.Unix()), dnsName, expiry.Target.Kind, expiry.Target.Name)
+		}
+	}
+}
+
+// probeTLSCertificateExpiry connects to the given address, sending the given
+// server name if it is not empty, and returns when the certificate that the
+// peer presents expires.  The operator does not verify the certificate, which
+// CoreDNS does; it only reports the expiry.  The handshake fails if the peer
+// requires a client certificate, which the operator does not have, but the
+// peer presents its own certificate first.
+func probeTLSCertificateExpiry(address, serverName string) (time.Time, error) {
+	var leaf *x509.Certificate
+	config := &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return fmt.Errorf("no certificate was presented")
+			}
+			cert, err := x509.ParseCertificate(rawCerts[0])
+			if err != nil {
+				return err
+			}
+			leaf = cert
+			return nil
+		},
+	}
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: tlsCertificateProbeTimeout}, "tcp", address, config)
+	if conn != nil {
+		conn.Close()
+	}
+	if leaf != nil {
+		return leaf.NotAfter, nil
+	}
+	if err == nil {
+		err = fmt.Errorf("no certificate was presented")
+	}
+	return time.Time{}, err
+}
+
+// tlsCertificateTargets returns the DNS-over-TLS upstreams of the given
+// servers, with the server names that the given client TLS configurations
+// specify, the DNS-over-HTTPS upstreams of the given forwarding
+// configuration, which may be nil, and the given encrypted listeners whose
+// certificates are in secrets.
+func tlsCertificateTargets(servers []operatorv1.Server, upstreamTLSConfigs []upstreamTLS, doh *dohForwarding, listeners []encryptedListener) []tlsCertificateTarget {
+	var targets []tlsCertificateTarget
+	seen := map[string]bool{}
+	for _, server := range servers {
+		serverName := ""
+		if config := upstreamTLSForServer(upstreamTLSConfigs, server.Name); config != nil {
+			serverName = config.ServerName
+		}
+		for _, upstream := range server.ForwardPlugin.Upstreams {
+			if !isDoTUpstream(upstream) {
+				continue
+			}
+			normalized, ok := normalizeDoTUpstream(upstream)
+			if !ok || seen[normalized+"/"+serverName] {
+				continue
+			}
+			seen[normalized+"/"+serverName] = true
+			targets = append(targets, tlsCertificateTarget{
+				Kind:       tlsCertificateKindUpstream,
+				Name:       normalized,
+				Address:    strings.TrimPrefix(normalized, dnsOverTLSScheme),
+				ServerName: serverName,
+			})
+		}
+	}
+	if doh != nil {
+		for _, upstream := range doh.Upstreams {
+			u, err := url.Parse(upstream.URL)
+			if err != nil {
+				continue
+			}
+			port := u.Port()
+			if len(port) == 0 {
+				port = "443"
+			}
+			serverName := u.Hostname()
+			if net.ParseIP(serverName) != nil {
+				serverName = ""
+			}
+			targets = append(targets, tlsCertificateTarget{
+				Kind:       tlsCertificateKindUpstream,
+				Name:       upstream.URL,
+				Address:    net.JoinHostPort(u.Hostname(), port),
+				ServerName: serverName,
+			})
+		}
+	}
+	for _, listener := range listeners {
+		// The operator cannot read a certificate that a
+		// SecretProviderClass provides.
+		if _, ok := secretProviderClassName(listener.SecretName); ok {
+			continue
+		}
+		targets = append(targets, tlsCertificateTarget{
+			Kind:       tlsCertificateKindListener,
+			Name:       listener.Name,
+			SecretName: listener.SecretName,
+		})
+	}
+	return targets
+}
+
+// computeDNSTLSCertificatesExpiringCondition returns a status condition that
+// reports the certificates of the given targets of the given dns that expire
+// within tlsCertificateExpiryThreshold of the given time, or nil if there are
+// no targets, and records the expiries for the certificate expiry metric.
+func (r *reconciler) computeDNSTLSCertificatesExpiringCondition(dns *operatorv1.DNS, targets []tlsCertificateTarget, now time.Time) (*operatorv1.OperatorCondition, error) {
+	var expiries []tlsCertificateExpiry
+	for _, target := range targets {
+		expiry := tlsCertificateExpiry{Target: target}
+		if target.Kind == tlsCertificateKindListener {
+			secret := &corev1.Secret{}
+			name := types.NamespacedName{Namespace: DefaultOperandNamespace, Name: target.SecretName}
+			if err := r.client.Get(context.TODO(), name, secret); err != nil {
+				return nil, fmt.Errorf("failed to get %s serving certificate secret %s: %w", target.Name, name, err)
+			}
+			expiry.Expiry, expiry.Err = servingCertificateExpiry(secret.Data[corev1.TLSCertKey])
+		} else {
+			expiry.Expiry, expiry.Err = r.tlsCertificates.upstreamExpiry(target, now)
+		}
+		expiries = append(expiries, expiry)
+	}
+	r.tlsCertificates.record(dns.Name, expiries)
+	if len(expiries) == 0 {
+		return nil, nil
+	}
+
+	condition := &operatorv1.OperatorCondition{
+		Type: DNSTLSCertificatesExpiringConditionType,
+	}
+	var expiring, failed []string
+	for _, expiry := range expiries {
+		switch {
+		case expiry.Err != nil:
+			failed = append(failed, fmt.Sprintf("%s: %v", expiry.Target, expiry.Err))
+		case !expiry.Expiry.After(now):
+			expiring = append(expiring, fmt.Sprintf("%s expired at %s", expiry.Target, expiry.Expiry.UTC().Format(time.RFC3339)))
+		case expiry.Expiry.Sub(now) < tlsCertificateExpiryThreshold:
+			expiring = append(expiring, fmt.Sprintf("%s expires at %s", expiry.Target, expiry.Expiry.UTC().Format(time.RFC3339)))
+		}
+	}
+	sort.Strings(expiring)
+	sort.Strings(failed)
+	switch {
+	case len(expiring) != 0:
+		condition.Status = operatorv1.ConditionTrue
+		condition.Reason = conditions.ReasonCertificatesExpiringSoon
+		condition.Message = fmt.Sprintf("Some certificates must be rotated because they expire within %d days: %s.", tlsCertificateExpiryThreshold/(24*time.Hour), strings.Join(expiring, "; "))
+	case len(failed) != 0:
+		condition.Status = operatorv1.ConditionUnknown
+		condition.Reason = conditions.ReasonCertificateCheckFailed
+		condition.Message = fmt.Sprintf("The expiry of some certificates could not be checked: %s.", strings.Join(failed, "; "))
+	default:
+		condition.Status = operatorv1.ConditionFalse
+		condition.Reason = conditions.ReasonAsExpected
+		condition.Message = fmt.Sprintf("None of the %d certificates expires within %d days.", len(expiries), tlsCertificateExpiryThreshold/(24*time.Hour))
+	}
+	return condition, nil
+}
+
+// servingCertificateExpiry returns when the first certificate in the given
+// PEM-encoded certificate chain expires.
+func servingCertificateExpiry(chain []byte) (time.Time, error) {
+	for {
+		var block *pem.Block
+		block, chain = pem.Decode(chain)
+		if block == nil {
+			return time.Time{}, fmt.Errorf("key %s has no PEM-encoded certificates", corev1.TLSCertKey)
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("key %s has an invalid certificate: %w", corev1.TLSCertKey, err)
+		}
+		return cert.NotAfter, nil
+	}
+}
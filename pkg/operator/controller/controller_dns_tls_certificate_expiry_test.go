@@ -0,0 +1,211 @@
+package controller
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// fakeSecretClient is a client.Client that gets secrets from a fixed set.
+type fakeSecretClient struct {
+	client.Client
+	secrets map[string]*corev1.Secret
+}
+
+func (c *fakeSecretClient) Get(_ context.Context, key client.ObjectKey, obj client.Object) error {
+	secret, ok := c.secrets[key.Name]
+	if !ok {
+		return errors.NewNotFound(schema.GroupResource{Resource: "secrets"}, key.Name)
+	}
+	secret.DeepCopyInto(obj.(*corev1.Secret))
+	return nil
+}
+
+// TestTLSCertificateTargets verifies that tlsCertificateTargets returns each
+// DNS-over-TLS upstream once, with its server's server name, the
+// DNS-over-HTTPS upstreams, and the encrypted listeners.
+func TestTLSCertificateTargets(t *testing.T) {
+	servers := []operatorv1.Server{{
+		Name: "foo",
+		ForwardPlugin: operatorv1.ForwardPlugin{
+			Upstreams: []string{"tls://1.1.1.1", "1.0.0.1", "TLS://1.1.1.1:853"},
+		},
+	}, {
+		Name: "bar",
+		ForwardPlugin: operatorv1.ForwardPlugin{
+			Upstreams: []string{"tls://[2606:4700::1111]:8853"},
+		},
+	}}
+	configs := []upstreamTLS{{Server: "foo", ServerName: "cloudflare-dns.com"}}
+	doh := &dohForwarding{Upstreams: []dohUpstream{
+		{URL: "https://dns.google/dns-query"},
+		{URL: "https://9.9.9.9:5053/dns-query"},
+	}}
+	listeners := []encryptedListener{{encryptedTransport: dnsOverTLS, SecretName: "dot-cert"}}
+
+	expected := []tlsCertificateTarget{
+		{Kind: tlsCertificateKindUpstream, Name: "tls://1.1.1.1:853", Address: "1.1.1.1:853", ServerName: "cloudflare-dns.com"},
+		{Kind: tlsCertificateKindUpstream, Name: "tls://[2606:4700::1111]:8853", Address: "[2606:4700::1111]:8853"},
+		{Kind: tlsCertificateKindUpstream, Name: "https://dns.google/dns-query", Address: "dns.google:443", ServerName: "dns.google"},
+		{Kind: tlsCertificateKindUpstream, Name: "https://9.9.9.9:5053/dns-query", Address: "9.9.9.9:5053"},
+		{Kind: tlsCertificateKindListener, Name: "dns-over-tls", SecretName: "dot-cert"},
+	}
+	if actual := tlsCertificateTargets(servers, configs, doh, listeners); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %+v, got %+v", expected, actual)
+	}
+	if actual := tlsCertificateTargets(nil, nil, nil, nil); len(actual) != 0 {
+		t.Errorf("expected no targets, got %+v", actual)
+	}
+}
+
+// TestProbeTLSCertificateExpiry verifies that probeTLSCertificateExpiry
+// returns the expiry of the certificate that a server presents, even if the
+// server requires a client certificate.
+func TestProbeTLSCertificateExpiry(t *testing.T) {
+	for _, clientAuth := range []tls.ClientAuthType{tls.NoClientCert, tls.RequireAnyClientCert} {
+		t.Run(clientAuth.String(), func(t *testing.T) {
+			server := httptest.NewUnstartedServer(http.NotFoundHandler())
+			server.TLS = &tls.Config{ClientAuth: clientAuth}
+			server.StartTLS()
+			defer server.Close()
+
+			expiry, err := probeTLSCertificateExpiry(server.Listener.Addr().String(), "example.com")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if expected := server.Certificate().NotAfter; !expiry.Equal(expected) {
+				t.Errorf("expected expiry %s, got %s", expected, expiry)
+			}
+		})
+	}
+}
+
+// TestComputeDNSTLSCertificatesExpiringCondition verifies that the condition
+// reports certificates that expire soon before certificates whose expiry could
+// not be checked, and that the upstreams are probed at most once per
+// tlsCertificateCheckPeriod.
+func TestComputeDNSTLSCertificatesExpiringCondition(t *testing.T) {
+	now := time.Date(2021, time.June, 1, 0, 0, 0, 0, time.UTC)
+	dns := &operatorv1.DNS{ObjectMeta: metav1.ObjectMeta{Name: DefaultDNSController}}
+	upstream := tlsCertificateTarget{Kind: tlsCertificateKindUpstream, Name: "tls://1.1.1.1:853", Address: "1.1.1.1:853"}
+	listener := tlsCertificateTarget{Kind: tlsCertificateKindListener, Name: "dns-over-tls", SecretName: "dot-cert"}
+	secret := func(notAfter time.Time) *corev1.Secret {
+		return &corev1.Secret{Data: map[string][]byte{
+			corev1.TLSCertKey: []byte(newTestCACertificate(t, notAfter)),
+		}}
+	}
+	testCases := []struct {
+		name            string
+		upstreamExpiry  time.Time
+		upstreamError   error
+		secret          *corev1.Secret
+		expectedStatus  operatorv1.ConditionStatus
+		expectedReason  string
+		expectedMessage string
+	}{
+		{
+			name:            "all certificates valid",
+			upstreamExpiry:  now.Add(90 * 24 * time.Hour),
+			secret:          secret(now.Add(60 * 24 * time.Hour)),
+			expectedStatus:  operatorv1.ConditionFalse,
+			expectedReason:  conditions.ReasonAsExpected,
+			expectedMessage: "None of the 2 certificates",
+		},
+		{
+			name:            "upstream certificate expiring",
+			upstreamExpiry:  now.Add(24 * time.Hour),
+			secret:          secret(now.Add(60 * 24 * time.Hour)),
+			expectedStatus:  operatorv1.ConditionTrue,
+			expectedReason:  conditions.ReasonCertificatesExpiringSoon,
+			expectedMessage: "upstream tls://1.1.1.1:853 expires at 2021-06-02T00:00:00Z",
+		},
+		{
+			name:            "listener certificate expired and upstream unreachable",
+			upstreamError:   fmt.Errorf("connection refused"),
+			secret:          secret(now.Add(-time.Hour)),
+			expectedStatus:  operatorv1.ConditionTrue,
+			expectedReason:  conditions.ReasonCertificatesExpiringSoon,
+			expectedMessage: "the dns-over-tls listener's certificate in secret openshift-dns/dot-cert expired at",
+		},
+		{
+			name:            "upstream unreachable",
+			upstreamError:   fmt.Errorf("connection refused"),
+			secret:          secret(now.Add(60 * 24 * time.Hour)),
+			expectedStatus:  operatorv1.ConditionUnknown,
+			expectedReason:  conditions.ReasonCertificateCheckFailed,
+			expectedMessage: "upstream tls://1.1.1.1:853: connection refused",
+		},
+		{
+			name:            "listener certificate invalid",
+			upstreamExpiry:  now.Add(90 * 24 * time.Hour),
+			secret:          &corev1.Secret{},
+			expectedStatus:  operatorv1.ConditionUnknown,
+			expectedReason:  conditions.ReasonCertificateCheckFailed,
+			expectedMessage: "no PEM-encoded certificates",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			probes := 0
+			expiries := newTLSCertificateExpiries()
+			expiries.probe = func(address, serverName string) (time.Time, error) {
+				probes++
+				return tc.upstreamExpiry, tc.upstreamError
+			}
+			r := &reconciler{
+				client:          &fakeSecretClient{secrets: map[string]*corev1.Secret{"dot-cert": tc.secret}},
+				tlsCertificates: expiries,
+			}
+			targets := []tlsCertificateTarget{upstream, listener}
+			condition, err := r.computeDNSTLSCertificatesExpiringCondition(dns, targets, now)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if condition.Status != tc.expectedStatus || condition.Reason != tc.expectedReason || !strings.Contains(condition.Message, tc.expectedMessage) {
+				t.Errorf("expected status %s, reason %s, and message containing %q, got %+v", tc.expectedStatus, tc.expectedReason, tc.expectedMessage, condition)
+			}
+			if !expiries.tracking(dns.Name) {
+				t.Error("expected the dns's certificates to be tracked")
+			}
+
+			if _, err := r.computeDNSTLSCertificatesExpiringCondition(dns, targets, now.Add(tlsCertificateCheckPeriod/2)); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if probes != 1 {
+				t.Errorf("expected 1 probe within the check period, got %d", probes)
+			}
+			if _, err := r.computeDNSTLSCertificatesExpiringCondition(dns, targets, now.Add(tlsCertificateCheckPeriod)); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if probes != 2 {
+				t.Errorf("expected 2 probes after the check period, got %d", probes)
+			}
+
+			if condition, err := r.computeDNSTLSCertificatesExpiringCondition(dns, nil, now); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			} else if condition != nil {
+				t.Errorf("expected no condition without targets, got %+v", condition)
+			}
+			if expiries.tracking(dns.Name) {
+				t.Error("expected the dns's certificates to be forgotten")
+			}
+		})
+	}
+}
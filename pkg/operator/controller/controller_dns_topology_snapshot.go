@@ -0,0 +1,302 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// The types in this file define the schema of the topology snapshot that the
+// operator publishes in a configmap for consumption by external auditing and
+// network-validation tools.  Consumers should check apiVersion and kind before
+// interpreting the data.  Fields may be added to a schema version, but
+// existing fields are not removed or changed in an incompatible way without
+// incrementing the version.
+
+const (
+	// DNSTopologySnapshotAPIVersion is the version of the
+	// DNSTopologySnapshot schema.
+	DNSTopologySnapshotAPIVersion = "dns.operator.openshift.io/v1alpha1"
+	// DNSTopologySnapshotKind is the kind of the DNSTopologySnapshot
+	// schema.
+	DNSTopologySnapshotKind = "DNSTopologySnapshot"
+	// DNSTopologySnapshotKey is the key in the topology snapshot configmap
+	// whose value is the JSON encoding of the snapshot.
+	DNSTopologySnapshotKey = "topology.json"
+
+	// ForwardedZoneHealthActive indicates that a forwarded zone's server
+	// is in the Corefile with the upstreams that the DNS specifies.
+	ForwardedZoneHealthActive = "Active"
+	// ForwardedZoneHealthModified indicates that a forwarded zone's
+	// server is in the Corefile with upstreams that differ from the ones
+	// that the DNS specifies, for example because some upstreams are
+	// invalid or a maintenance window replaces them.
+	ForwardedZoneHealthModified = "Modified"
+	// ForwardedZoneHealthOmitted indicates that a forwarded zone's server
+	// is not in the Corefile, for example because all of its upstreams
+	// are invalid or would cause a forwarding loop.
+	ForwardedZoneHealthOmitted = "Omitted"
+)
+
+// DNSTopologySnapshot describes the topology of a DNS: its service, the nodes
+// and zones on which its pods run, and the zones that it forwards.  The DNS's
+// status conditions explain why a forwarded zone is modified or omitted.
+type DNSTopologySnapshot struct {
+	// APIVersion is the version of the schema,
+	// DNSTopologySnapshotAPIVersion.
+	APIVersion string `json:"apiVersion"`
+	// Kind is the kind of the schema, DNSTopologySnapshotKind.
+	Kind string `json:"kind"`
+
+	// Service describes the DNS's service, or is nil if the service does
+	// not exist.
+	Service *SnapshotService `json:"service,omitempty"`
+	// Zones describes the DNS pods in each topology zone.  Nodes without
+	// a zone are counted in a zone with the empty name.
+	Zones []SnapshotZone `json:"zones,omitempty"`
+	// Nodes describes each node on which the DNS's pods can run and the
+	// DNS pod on it.
+	Nodes []SnapshotNode `json:"nodes,omitempty"`
+	// ForwardedZones describes each server in the DNS's spec.
+	ForwardedZones []SnapshotForwardedZone `json:"forwardedZones,omitempty"`
+}
+
+// SnapshotService describes the DNS's service.
+type SnapshotService struct {
+	// Name is the name of the service.
+	Name string `json:"name"`
+	// Namespace is the namespace of the service.
+	Namespace string `json:"namespace"`
+	// ClusterIPs are the service's cluster IP addresses.
+	ClusterIPs []string `json:"clusterIPs"`
+}
+
+// SnapshotZone describes the DNS pods in a topology zone.
+type SnapshotZone struct {
+	// Name is the name of the zone.
+	Name string `json:"name"`
+	// Nodes is the number of nodes in the zone on which DNS pods can run.
+	Nodes int `json:"nodes"`
+	// Pods is the number of DNS pods in the zone.
+	Pods int `json:"pods"`
+	// ReadyPods is the number of ready DNS pods in the zone.
+	ReadyPods int `json:"readyPods"`
+}
+
+// SnapshotNode describes a node on which DNS pods can run.
+type SnapshotNode struct {
+	// Name is the name of the node.
+	Name string `json:"name"`
+	// Zone is the node's topology zone, or empty if it has none.
+	Zone string `json:"zone,omitempty"`
+	// Pod is the name of the DNS pod on the node, or empty if the node
+	// has no DNS pod.
+	Pod string `json:"pod,omitempty"`
+	// Ready indicates whether the DNS pod on the node is ready.
+	Ready bool `json:"ready"`
+}
+
+// SnapshotForwardedZone describes a server in the DNS's spec.
+type SnapshotForwardedZone struct {
+	// Server is the name of the server in the DNS spec.
+	Server string `json:"server"`
+	// Zones are the zones that the server forwards.
+	Zones []string `json:"zones"`
+	// Upstreams are the upstreams that the DNS specifies for the server.
+	Upstreams []string `json:"upstreams"`
+	// ActiveUpstreams are the upstreams to which the Corefile forwards
+	// queries for the server, or empty if the server is omitted.
+	ActiveUpstreams []string `json:"activeUpstreams,omitempty"`
+	// Health is one of ForwardedZoneHealthActive,
+	// ForwardedZoneHealthModified, or ForwardedZoneHealthOmitted.
+	Health string `json:"health"`
+}
+
+// ensureDNSTopologySnapshot ensures that the topology snapshot configmap for
+// the given dns exists and describes the given service, which may be nil, and
+// the given servers, which are the servers that the Corefile has.
+func (r *reconciler) ensureDNSTopologySnapshot(dns *operatorv1.DNS, svc *corev1.Service, servers []operatorv1.Server) error {
+	selector, err := metav1.LabelSelectorAsSelector(DNSDaemonSetPodSelector(dns))
+	if err != nil {
+		return fmt.Errorf("failed to build pod selector for dns %s: %w", dns.Name, err)
+	}
+	podList := &corev1.PodList{}
+	listOpts := []client.ListOption{
+		client.MatchingLabelsSelector{Selector: selector},
+		client.InNamespace(DefaultOperandNamespace),
+	}
+	if err := r.cache.List(context.TODO(), podList, listOpts...); err != nil {
+		return fmt.Errorf("failed to list pods for dns %s: %w", dns.Name, err)
+	}
+	nodes, err := ListNodes(context.TODO(), r.cache)
+	if err != nil {
+		return fmt.Errorf("failed to list nodes: %w", err)
+	}
+	desired, err := desiredDNSTopologySnapshotConfigMap(dns, computeDNSTopologySnapshot(dns, svc, servers, podList.Items, nodes))
+	if err != nil {
+		return err
+	}
+
+	current := &corev1.ConfigMap{}
+	if err := r.client.Get(context.TODO(), DNSTopologySnapshotConfigMapName(dns), current); err != nil {
+		if !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to get topology snapshot configmap: %w", err)
+		}
+		if err := r.client.Create(context.TODO(), desired); err != nil {
+			return fmt.Errorf("failed to create topology snapshot configmap: %w", err)
+		}
+		logrus.Infof("created topology snapshot configmap %s/%s", desired.Namespace, desired.Name)
+		return nil
+	}
+	if cmp.Equal(current.Data, desired.Data, cmpopts.EquateEmpty()) {
+		return nil
+	}
+	updated := current.DeepCopy()
+	updated.Data = desired.Data
+	if err := r.client.Update(context.TODO(), updated); err != nil {
+		return fmt.Errorf("failed to update topology snapshot configmap: %w", err)
+	}
+	logrus.Infof("updated topology snapshot configmap %s/%s", updated.Namespace, updated.Name)
+	return nil
+}
+
+// desiredDNSTopologySnapshotConfigMap returns the desired topology snapshot
+// configmap for the given dns with the given snapshot.
+func desiredDNSTopologySnapshotConfigMap(dns *operatorv1.DNS, snapshot DNSTopologySnapshot) (*corev1.ConfigMap, error) {
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode topology snapshot for dns %s: %w", dns.Name, err)
+	}
+	name := DNSTopologySnapshotConfigMapName(dns)
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name.Name,
+			Namespace:       name.Namespace,
+			OwnerReferences: []metav1.OwnerReference{dnsOwnerRef(dns)},
+		},
+		Data: map[string]string{
+			DNSTopologySnapshotKey: string(data),
+		},
+	}, nil
+}
+
+// computeDNSTopologySnapshot returns the topology snapshot for the given dns,
+// its service, which may be nil, the servers that its Corefile has, its pods,
+// and the cluster's nodes.  The snapshot has no timestamps so that it changes
+// only when the topology does.
+func computeDNSTopologySnapshot(dns *operatorv1.DNS, svc *corev1.Service, servers []operatorv1.Server, pods []corev1.Pod, nodes []corev1.Node) DNSTopologySnapshot {
+	snapshot := DNSTopologySnapshot{
+		APIVersion: DNSTopologySnapshotAPIVersion,
+		Kind:       DNSTopologySnapshotKind,
+	}
+
+	if svc != nil {
+		ips := svc.Spec.ClusterIPs
+		if len(ips) == 0 && len(svc.Spec.ClusterIP) != 0 {
+			ips = []string{svc.Spec.ClusterIP}
+		}
+		snapshot.Service = &SnapshotService{
+			Name:       svc.Name,
+			Namespace:  svc.Namespace,
+			ClusterIPs: append([]string{}, ips...),
+		}
+	}
+
+	podsByNode := map[string]corev1.Pod{}
+	for _, pod := range pods {
+		if len(pod.Spec.NodeName) != 0 {
+			podsByNode[pod.Spec.NodeName] = pod
+		}
+	}
+	nodeSelector := labels.SelectorFromSet(nodeSelectorForDNS(dns))
+	zones := map[string]*SnapshotZone{}
+	for _, node := range nodes {
+		pod, hasPod := podsByNode[node.Name]
+		if !hasPod && !nodeSelector.Matches(labels.Set(node.Labels)) {
+			continue
+		}
+		zoneName := node.Labels[corev1.LabelTopologyZone]
+		zone, ok := zones[zoneName]
+		if !ok {
+			zone = &SnapshotZone{Name: zoneName}
+			zones[zoneName] = zone
+		}
+		zone.Nodes++
+		entry := SnapshotNode{Name: node.Name, Zone: zoneName}
+		if hasPod {
+			entry.Pod = pod.Name
+			entry.Ready = podReady(&pod)
+			zone.Pods++
+			if entry.Ready {
+				zone.ReadyPods++
+			}
+		}
+		snapshot.Nodes = append(snapshot.Nodes, entry)
+	}
+	sort.Slice(snapshot.Nodes, func(i, j int) bool {
+		return snapshot.Nodes[i].Name < snapshot.Nodes[j].Name
+	})
+	for _, zone := range zones {
+		snapshot.Zones = append(snapshot.Zones, *zone)
+	}
+	sort.Slice(snapshot.Zones, func(i, j int) bool {
+		return snapshot.Zones[i].Name < snapshot.Zones[j].Name
+	})
+
+	active := map[string][]string{}
+	for _, server := range servers {
+		active[server.Name] = server.ForwardPlugin.Upstreams
+	}
+	for _, server := range dns.Spec.Servers {
+		zone := SnapshotForwardedZone{
+			Server:    server.Name,
+			Zones:     append([]string{}, server.Zones...),
+			Upstreams: append([]string{}, server.ForwardPlugin.Upstreams...),
+		}
+		upstreams, ok := active[server.Name]
+		switch {
+		case !ok:
+			zone.Health = ForwardedZoneHealthOmitted
+		case !reflect.DeepEqual(upstreams, server.ForwardPlugin.Upstreams):
+			zone.ActiveUpstreams = append([]string{}, upstreams...)
+			zone.Health = ForwardedZoneHealthModified
+		default:
+			zone.ActiveUpstreams = append([]string{}, upstreams...)
+			zone.Health = ForwardedZoneHealthActive
+		}
+		snapshot.ForwardedZones = append(snapshot.ForwardedZones, zone)
+	}
+	sort.Slice(snapshot.ForwardedZones, func(i, j int) bool {
+		return snapshot.ForwardedZones[i].Server < snapshot.ForwardedZones[j].Server
+	})
+
+	return snapshot
+}
+
+// podReady returns a Boolean value indicating whether the given pod is
+// ready.
+func podReady(pod *corev1.Pod) bool {
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
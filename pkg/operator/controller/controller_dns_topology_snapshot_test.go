@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	corev1 "k8s.io/api/core/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestComputeDNSTopologySnapshot verifies that computeDNSTopologySnapshot
+// describes the service, the pods per node and zone, and the health of each
+// forwarded zone.
+func TestComputeDNSTopologySnapshot(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{Name: DefaultDNSController},
+		Spec: operatorv1.DNSSpec{
+			Servers: []operatorv1.Server{
+				{Name: "foo", Zones: []string{"foo.com"}, ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"1.1.1.1"}}},
+				{Name: "bar", Zones: []string{"bar.com"}, ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"2.2.2.2", "bogus"}}},
+				{Name: "baz", Zones: []string{"baz.com"}, ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"bogus"}}},
+			},
+		},
+	}
+	servers := []operatorv1.Server{
+		dns.Spec.Servers[0],
+		{Name: "bar", Zones: []string{"bar.com"}, ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"2.2.2.2"}}},
+	}
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "dns-default", Namespace: "openshift-dns"},
+		Spec:       corev1.ServiceSpec{ClusterIP: "172.30.0.10"},
+	}
+	node := func(name, zone, os string) corev1.Node {
+		return corev1.Node{ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{"kubernetes.io/os": os, corev1.LabelTopologyZone: zone},
+		}}
+	}
+	pod := func(name, node string, ready bool) corev1.Pod {
+		status := corev1.ConditionFalse
+		if ready {
+			status = corev1.ConditionTrue
+		}
+		return corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       corev1.PodSpec{NodeName: node},
+			Status:     corev1.PodStatus{Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: status}}},
+		}
+	}
+	nodes := []corev1.Node{node("a1", "a", "linux"), node("a2", "a", "linux"), node("b1", "b", "linux"), node("w1", "a", "windows")}
+	pods := []corev1.Pod{pod("dns-a1", "a1", true), pod("dns-a2", "a2", false), pod("dns-b1", "b1", true)}
+
+	snapshot := computeDNSTopologySnapshot(dns, svc, servers, pods, nodes)
+	expected := DNSTopologySnapshot{
+		APIVersion: DNSTopologySnapshotAPIVersion,
+		Kind:       DNSTopologySnapshotKind,
+		Service: &SnapshotService{
+			Name:       "dns-default",
+			Namespace:  "openshift-dns",
+			ClusterIPs: []string{"172.30.0.10"},
+		},
+		Zones: []SnapshotZone{
+			{Name: "a", Nodes: 2, Pods: 2, ReadyPods: 1},
+			{Name: "b", Nodes: 1, Pods: 1, ReadyPods: 1},
+		},
+		Nodes: []SnapshotNode{
+			{Name: "a1", Zone: "a", Pod: "dns-a1", Ready: true},
+			{Name: "a2", Zone: "a", Pod: "dns-a2"},
+			{Name: "b1", Zone: "b", Pod: "dns-b1", Ready: true},
+		},
+		ForwardedZones: []SnapshotForwardedZone{
+			{Server: "bar", Zones: []string{"bar.com"}, Upstreams: []string{"2.2.2.2", "bogus"}, ActiveUpstreams: []string{"2.2.2.2"}, Health: ForwardedZoneHealthModified},
+			{Server: "baz", Zones: []string{"baz.com"}, Upstreams: []string{"bogus"}, Health: ForwardedZoneHealthOmitted},
+			{Server: "foo", Zones: []string{"foo.com"}, Upstreams: []string{"1.1.1.1"}, ActiveUpstreams: []string{"1.1.1.1"}, Health: ForwardedZoneHealthActive},
+		},
+	}
+	if !reflect.DeepEqual(snapshot, expected) {
+		t.Errorf("expected snapshot:\n%+v\ngot:\n%+v", expected, snapshot)
+	}
+}
@@ -0,0 +1,218 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// DNSUpstreamCABundlesValidConditionType is the type of the DNS status
+	// condition that reports whether the CA bundles that verify the
+	// certificates of the dns's DNS-over-TLS upstreams are valid and not
+	// about to expire.  The condition is reported only if the dns's
+	// UpstreamTLSAnnotation annotation refers to a CA bundle configmap.
+	DNSUpstreamCABundlesValidConditionType = conditions.TypeUpstreamCABundlesValid
+
+	// upstreamCABundleExpiryThreshold is how long before every certificate
+	// in a CA bundle expires that the UpstreamCABundlesValid condition
+	// reports that the bundle must be rotated.
+	upstreamCABundleExpiryThreshold = 30 * 24 * time.Hour
+	// upstreamCABundleCheckPeriod is how often the operator checks the
+	// expiry of the CA bundles, which changes with time rather than with
+	// the configmaps.
+	upstreamCABundleCheckPeriod = time.Hour
+)
+
+// upstreamCABundle is a valid CA bundle from a configmap that an entry of a
+// dns's UpstreamTLSAnnotation annotation refers to.
+type upstreamCABundle struct {
+	// ConfigMap is the name of the configmap.
+	ConfigMap string
+	// Bundle is the PEM-encoded CA certificates.
+	Bundle string
+	// Expiry is when the last of the bundle's certificates expires.
+	Expiry time.Time
+}
+
+// Key returns the key of the bundle in the dns's configmap.
+func (b upstreamCABundle) Key() string {
+	return "upstream-ca-" + b.ConfigMap + ".crt"
+}
+
+// Hash returns an abbreviated hash of the bundle.  The Corefile includes the
+// hash so that CoreDNS reloads its configuration when the bundle changes.
+func (b upstreamCABundle) Hash() string {
+	sum := sha256.Sum256([]byte(b.Bundle))
+	return hex.EncodeToString(sum[:8])
+}
+
+// upstreamCABundleConfigMapNames returns the names in the operand namespace of
+// the CA bundle configmaps that the entries of the given dns's
+// UpstreamTLSAnnotation annotation refer to, in sorted order and without
+// duplicates.  The names of copies are returned for configmaps in other
+// namespaces.  The names are not validated; parseUpstreamTLS validates the
+// entries.
+func upstreamCABundleConfigMapNames(dns *operatorv1.DNS) []string {
+	value := strings.TrimSpace(dns.Annotations[UpstreamTLSAnnotation])
+	if len(value) == 0 {
+		return nil
+	}
+	var specs []upstreamTLSSpec
+	if err := json.Unmarshal([]byte(value), &specs); err != nil {
+		return nil
+	}
+	names := sets.NewString()
+	for _, spec := range specs {
+		if len(spec.CABundleConfigMap) != 0 {
+			names.Insert(upstreamTLSLocalName(spec.CABundleConfigMap))
+		}
+	}
+	return names.List()
+}
+
+// dnsUpstreamCABundles returns the valid CA bundles, by configmap name, that
+// the given dns's UpstreamTLSAnnotation annotation refers to and a status
+// condition that reports the bundles that are missing, invalid, or about to
+// expire at the given time, or nil and a nil condition if the annotation
+// refers to no CA bundles.  A bundle is invalid if it has no certificates or
+// if all of them have expired.
+func (r *reconciler) dnsUpstreamCABundles(dns *operatorv1.DNS, now time.Time) (map[string]upstreamCABundle, *operatorv1.OperatorCondition, error) {
+	names := upstreamCABundleConfigMapNames(dns)
+	if len(names) == 0 {
+		return nil, nil, nil
+	}
+	bundles := map[string]upstreamCABundle{}
+	var problems []string
+	for _, name := range names {
+		cm := &corev1.ConfigMap{}
+		cmName := types.NamespacedName{Namespace: DefaultOperandNamespace, Name: name}
+		if err := r.cache.Get(context.TODO(), cmName, cm); err != nil {
+			if !errors.IsNotFound(err) {
+				return nil, nil, fmt.Errorf("failed to get CA bundle configmap %s: %w", cmName, err)
+			}
+			problems = append(problems, fmt.Sprintf("configmap %s does not exist", cmName))
+			continue
+		}
+		bundle := cm.Data[trustedCABundleKey]
+		expiry, err := upstreamCABundleExpiry(bundle, now)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("configmap %s: %v", cmName, err))
+			continue
+		}
+		bundles[name] = upstreamCABundle{ConfigMap: name, Bundle: bundle, Expiry: expiry}
+	}
+	condition := computeUpstreamCABundlesValidCondition(bundles, problems, now)
+	return bundles, &condition, nil
+}
+
+// upstreamCABundleExpiry returns when the last of the certificates in the
+// given PEM-encoded CA bundle expires, or an error if the bundle has no
+// certificates or if all of them have expired at the given time.
+func upstreamCABundleExpiry(bundle string, now time.Time) (time.Time, error) {
+	var (
+		expiry time.Time
+		count  int
+		rest   = []byte(bundle)
+	)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("key %s has an invalid certificate: %w", trustedCABundleKey, err)
+		}
+		count++
+		if cert.NotAfter.After(expiry) {
+			expiry = cert.NotAfter
+		}
+	}
+	switch {
+	case count == 0:
+		return time.Time{}, fmt.Errorf("key %s has no PEM-encoded certificates", trustedCABundleKey)
+	case !expiry.After(now):
+		return time.Time{}, fmt.Errorf("every certificate in key %s expired by %s", trustedCABundleKey, expiry.UTC().Format(time.RFC3339))
+	}
+	return expiry, nil
+}
+
+// computeUpstreamCABundlesValidCondition returns a status condition that
+// reports the problems with the CA bundles that were ignored and the given
+// valid bundles whose certificates all expire within
+// upstreamCABundleExpiryThreshold of the given time.
+func computeUpstreamCABundlesValidCondition(bundles map[string]upstreamCABundle, problems []string, now time.Time) operatorv1.OperatorCondition {
+	condition := operatorv1.OperatorCondition{
+		Type: DNSUpstreamCABundlesValidConditionType,
+	}
+	if len(problems) != 0 {
+		condition.Status = operatorv1.ConditionFalse
+		condition.Reason = conditions.ReasonInvalidCABundle
+		condition.Message = fmt.Sprintf("Some CA bundles are invalid, so the DNS-over-TLS upstreams that they verify are omitted: %s.", strings.Join(problems, "; "))
+		return condition
+	}
+	var expiring []string
+	for name, bundle := range bundles {
+		if bundle.Expiry.Sub(now) < upstreamCABundleExpiryThreshold {
+			expiring = append(expiring, fmt.Sprintf("configmap %s expires at %s", name, bundle.Expiry.UTC().Format(time.RFC3339)))
+		}
+	}
+	sort.Strings(expiring)
+	if len(expiring) != 0 {
+		condition.Status = operatorv1.ConditionFalse
+		condition.Reason = conditions.ReasonCABundleExpiringSoon
+		condition.Message = fmt.Sprintf("Some CA bundles must be rotated because all of their certificates expire soon: %s.", strings.Join(expiring, "; "))
+		return condition
+	}
+	condition.Status = operatorv1.ConditionTrue
+	condition.Reason = conditions.ReasonAsExpected
+	condition.Message = fmt.Sprintf("All %d CA bundles are valid.", len(bundles))
+	return condition
+}
+
+// dnsesForUpstreamCABundleConfigMap returns reconcile requests for the dnses
+// whose UpstreamTLSAnnotation annotation refers to the given configmap, so that
+// a rotated CA bundle is copied into the dnses' configmaps.
+func (r *reconciler) dnsesForUpstreamCABundleConfigMap(o client.Object) []reconcile.Request {
+	dnses := &operatorv1.DNSList{}
+	if err := r.cache.List(context.TODO(), dnses); err != nil {
+		logrus.Errorf("failed to list dnses for configmap %s/%s: %v", o.GetNamespace(), o.GetName(), err)
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range dnses.Items {
+		for _, name := range upstreamCABundleConfigMapNames(&dnses.Items[i]) {
+			if name == o.GetName() {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: dnses.Items[i].Name}})
+				break
+			}
+		}
+	}
+	return requests
+}
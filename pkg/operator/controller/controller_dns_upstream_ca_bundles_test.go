@@ -0,0 +1,156 @@
+package controller
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// newTestCACertificate returns a PEM-encoded self-signed CA certificate that
+// expires at the given time.
+func newTestCACertificate(t *testing.T, notAfter time.Time) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "upstream-ca"},
+		NotBefore:             notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:              notAfter,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+// TestUpstreamCABundleExpiry verifies that upstreamCABundleExpiry returns when
+// the last of a bundle's certificates expires and rejects bundles without
+// unexpired certificates.
+func TestUpstreamCABundleExpiry(t *testing.T) {
+	now := time.Date(2021, time.June, 1, 0, 0, 0, 0, time.UTC)
+	expired := newTestCACertificate(t, now.Add(-time.Hour))
+	soon := newTestCACertificate(t, now.Add(24*time.Hour))
+	later := newTestCACertificate(t, now.Add(90*24*time.Hour))
+	testCases := []struct {
+		name           string
+		bundle         string
+		expectedExpiry time.Time
+		expectedError  string
+	}{
+		{"one certificate", soon, now.Add(24 * time.Hour), ""},
+		{"latest certificate", later + expired + soon, now.Add(90 * 24 * time.Hour), ""},
+		{"all certificates expired", expired, time.Time{}, "expired by"},
+		{"empty", "", time.Time{}, "no PEM-encoded certificates"},
+		{"not PEM", "certificate", time.Time{}, "no PEM-encoded certificates"},
+		{"invalid certificate", "-----BEGIN CERTIFICATE-----\nYWJj\n-----END CERTIFICATE-----\n", time.Time{}, "invalid certificate"},
+	}
+	for _, tc := range testCases {
+		expiry, err := upstreamCABundleExpiry(tc.bundle, now)
+		switch {
+		case len(tc.expectedError) == 0 && err != nil:
+			t.Errorf("%q: unexpected error: %v", tc.name, err)
+		case len(tc.expectedError) != 0 && (err == nil || !strings.Contains(err.Error(), tc.expectedError)):
+			t.Errorf("%q: expected error containing %q, got %v", tc.name, tc.expectedError, err)
+		case !expiry.Equal(tc.expectedExpiry):
+			t.Errorf("%q: expected expiry %s, got %s", tc.name, tc.expectedExpiry, expiry)
+		}
+	}
+}
+
+// TestComputeUpstreamCABundlesValidCondition verifies that the condition
+// reports invalid bundles before bundles that expire soon.
+func TestComputeUpstreamCABundlesValidCondition(t *testing.T) {
+	now := time.Date(2021, time.June, 1, 0, 0, 0, 0, time.UTC)
+	valid := upstreamCABundle{ConfigMap: "corp-ca", Expiry: now.Add(90 * 24 * time.Hour)}
+	expiring := upstreamCABundle{ConfigMap: "lab-ca", Expiry: now.Add(24 * time.Hour)}
+	testCases := []struct {
+		name           string
+		bundles        map[string]upstreamCABundle
+		problems       []string
+		expectedStatus operatorv1.ConditionStatus
+		expectedReason string
+	}{
+		{"valid", map[string]upstreamCABundle{"corp-ca": valid}, nil, operatorv1.ConditionTrue, conditions.ReasonAsExpected},
+		{"expiring", map[string]upstreamCABundle{"corp-ca": valid, "lab-ca": expiring}, nil, operatorv1.ConditionFalse, conditions.ReasonCABundleExpiringSoon},
+		{"invalid", map[string]upstreamCABundle{"lab-ca": expiring}, []string{"configmap openshift-dns/corp-ca does not exist"}, operatorv1.ConditionFalse, conditions.ReasonInvalidCABundle},
+	}
+	for _, tc := range testCases {
+		condition := computeUpstreamCABundlesValidCondition(tc.bundles, tc.problems, now)
+		if condition.Type != DNSUpstreamCABundlesValidConditionType || condition.Status != tc.expectedStatus || condition.Reason != tc.expectedReason {
+			t.Errorf("%q: expected status %s and reason %s, got %#v", tc.name, tc.expectedStatus, tc.expectedReason, condition)
+		}
+	}
+}
+
+// TestDesiredUpstreamCABundle verifies that the dns's configmap has the CA
+// bundle of a server's DNS-over-TLS upstreams and that the Corefile verifies the
+// upstreams with the bundle and changes when the bundle does.
+func TestDesiredUpstreamCABundle(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DefaultDNSController,
+		},
+	}
+	servers := []operatorv1.Server{
+		{Name: "corp", Zones: []string{"corp.example.com"}, ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"tls://10.0.0.53:853"}}},
+	}
+	bundle := upstreamCABundle{ConfigMap: "corp-ca", Bundle: "bundle-1"}
+	configs := []upstreamTLS{
+		{Server: "corp", SecretName: "corp-client", HasCA: true, CABundle: &bundle, CertificateHash: "abc"},
+	}
+
+	cm, err := desiredDNSConfigMap(dns, corefileInputs{Servers: servers, UpstreamTLS: configs, ClusterDomain: "cluster.local", MetricsAddress: "127.0.0.1:9153"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `# corp (CA ` + bundle.Hash() + `)
+corp.example.com:5353 {
+    forward . tls://10.0.0.53:853 {
+        tls /etc/coredns-upstream-tls/corp-client/tls.crt /etc/coredns-upstream-tls/corp-client/tls.key /etc/coredns/upstream-ca-corp-ca.crt
+    }
+`
+	if !strings.HasPrefix(cm.Data["Corefile"], expected) {
+		t.Errorf("expected Corefile to start with:\n%s\ngot:\n%s", expected, cm.Data["Corefile"])
+	}
+	if actual := cm.Data["upstream-ca-corp-ca.crt"]; actual != "bundle-1" {
+		t.Errorf("expected the configmap to have the CA bundle, got %q", actual)
+	}
+
+	rotated := upstreamCABundle{ConfigMap: "corp-ca", Bundle: "bundle-2"}
+	configs[0].CABundle = &rotated
+	rotatedCM, err := desiredDNSConfigMap(dns, corefileInputs{Servers: servers, UpstreamTLS: configs, ClusterDomain: "cluster.local", MetricsAddress: "127.0.0.1:9153"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotatedCM.Data["Corefile"] == cm.Data["Corefile"] {
+		t.Errorf("expected the Corefile to change when the CA bundle is rotated")
+	}
+
+	ds, err := desiredDNSDaemonSet(dns, "coredns", "kube-rbac-proxy", "", "dns-default-metrics-tls", "", nil, nil, configs, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range ds.Spec.Template.Spec.Volumes {
+		if v.Name == "config-volume" && v.ConfigMap.Items != nil {
+			t.Errorf("expected the config volume to project every key of the configmap, got %v", v.ConfigMap.Items)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	configv1 "github.com/openshift/api/config/v1"
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// DNSUpstreamTemplatesResolvedConditionType is the type of the DNS status
+// condition that indicates whether the operator resolved every template in
+// the upstreams of the dns's servers.  The condition is reported only if some
+// upstream uses a template.
+const DNSUpstreamTemplatesResolvedConditionType = conditions.TypeUpstreamTemplatesResolved
+
+// platformDNSServers maps platform types to the addresses of the DNS servers
+// that the platforms provide to instances.
+var platformDNSServers = map[configv1.PlatformType]string{
+	configv1.AWSPlatformType:   "169.254.169.253",
+	configv1.AzurePlatformType: "168.63.129.16",
+	configv1.GCPPlatformType:   "169.254.169.254",
+}
+
+// upstreamTemplateFacts are the cluster facts to which upstream templates
+// refer.  Only these fields may appear in a template, and only as a plain
+// field reference such as "{{.ClusterDNSIP}}".  An empty fact is unknown, and
+// a template that refers to it cannot be resolved.
+type upstreamTemplateFacts struct {
+	// ClusterDomain is the cluster's domain, such as "cluster.local".
+	ClusterDomain string
+	// ClusterDNSIP is the cluster IP address of the DNS service.
+	ClusterDNSIP string
+	// PlatformDNSServer is the address of the DNS server that the
+	// cluster's platform provides, or empty if the platform provides none
+	// that the operator knows.
+	PlatformDNSServer string
+}
+
+// isUpstreamTemplate returns a Boolean value indicating whether the given
+// upstream is a template.
+func isUpstreamTemplate(upstream string) bool {
+	return strings.Contains(upstream, "{{")
+}
+
+// serversUseUpstreamTemplates returns a Boolean value indicating whether any
+// of the given servers has an upstream that is a template.
+func serversUseUpstreamTemplates(servers []operatorv1.Server) bool {
+	for _, server := range servers {
+		for _, upstream := range server.ForwardPlugin.Upstreams {
+			if isUpstreamTemplate(upstream) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// resolveUpstreamTemplate returns the given upstream template with its field
+// references replaced by the given facts.  Templates may contain only text and
+// plain references to the fields of upstreamTemplateFacts.
+func resolveUpstreamTemplate(upstream string, facts upstreamTemplateFacts) (string, error) {
+	tmpl, err := template.New("upstream").Option("missingkey=error").Parse(upstream)
+	if err != nil {
+		return "", err
+	}
+	known := map[string]string{
+		"ClusterDomain":     facts.ClusterDomain,
+		"ClusterDNSIP":      facts.ClusterDNSIP,
+		"PlatformDNSServer": facts.PlatformDNSServer,
+	}
+	for _, node := range tmpl.Tree.Root.Nodes {
+		switch node := node.(type) {
+		case *parse.TextNode:
+		case *parse.ActionNode:
+			cmds := node.Pipe.Cmds
+			if len(node.Pipe.Decl) != 0 || len(cmds) != 1 || len(cmds[0].Args) != 1 {
+				return "", fmt.Errorf("unsupported expression %s", node)
+			}
+			field, ok := cmds[0].Args[0].(*parse.FieldNode)
+			if !ok || len(field.Ident) != 1 {
+				return "", fmt.Errorf("unsupported expression %s", node)
+			}
+			value, ok := known[field.Ident[0]]
+			if !ok {
+				return "", fmt.Errorf("unknown fact %s", field)
+			}
+			if len(value) == 0 {
+				return "", fmt.Errorf("fact %s is unknown on this cluster", field)
+			}
+		default:
+			return "", fmt.Errorf("unsupported expression %s", node)
+		}
+	}
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, facts); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// resolveUpstreamTemplates returns copies of the given servers with their
+// upstream templates resolved using the given facts, and a status condition
+// that describes each template that could not be resolved.  An upstream whose
+// template cannot be resolved is omitted.
+func resolveUpstreamTemplates(dnsServers []operatorv1.Server, facts upstreamTemplateFacts) ([]operatorv1.Server, operatorv1.OperatorCondition) {
+	var (
+		servers  []operatorv1.Server
+		problems []string
+	)
+	for _, server := range dnsServers {
+		resolved := *server.DeepCopy()
+		resolved.ForwardPlugin.Upstreams = nil
+		for i, upstream := range server.ForwardPlugin.Upstreams {
+			if !isUpstreamTemplate(upstream) {
+				resolved.ForwardPlugin.Upstreams = append(resolved.ForwardPlugin.Upstreams, upstream)
+				continue
+			}
+			value, err := resolveUpstreamTemplate(upstream, facts)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("server %q upstream %d (%q): %v", server.Name, i+1, upstream, err))
+				continue
+			}
+			resolved.ForwardPlugin.Upstreams = append(resolved.ForwardPlugin.Upstreams, value)
+		}
+		servers = append(servers, resolved)
+	}
+
+	condition := operatorv1.OperatorCondition{
+		Type: DNSUpstreamTemplatesResolvedConditionType,
+	}
+	if len(problems) != 0 {
+		condition.Status = operatorv1.ConditionFalse
+		condition.Reason = conditions.ReasonTemplateResolutionFailed
+		condition.Message = fmt.Sprintf("Some upstream templates could not be resolved and were omitted: %s.", strings.Join(problems, "; "))
+	} else {
+		condition.Status = operatorv1.ConditionTrue
+		condition.Reason = conditions.ReasonAsExpected
+		condition.Message = "All upstream templates were resolved."
+	}
+	return servers, condition
+}
+
+// platformDNSServer returns the address of the DNS server that the cluster's
+// platform provides, or the empty string if the platform is unknown or
+// provides no DNS server that the operator knows.
+func (r *reconciler) platformDNSServer() (string, error) {
+	infra := &configv1.Infrastructure{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: "cluster"}, infra); err != nil {
+		if errors.IsNotFound(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get infrastructure 'cluster': %w", err)
+	}
+	if infra.Status.PlatformStatus == nil {
+		return platformDNSServers[infra.Status.Platform], nil
+	}
+	return platformDNSServers[infra.Status.PlatformStatus.Type], nil
+}
@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+)
+
+// TestResolveUpstreamTemplates verifies that resolveUpstreamTemplates
+// resolves templates that refer to known facts and omits the upstreams whose
+// templates are invalid or refer to unknown facts.
+func TestResolveUpstreamTemplates(t *testing.T) {
+	facts := upstreamTemplateFacts{
+		ClusterDomain: "cluster.local",
+		ClusterDNSIP:  "172.30.0.10",
+	}
+	server := func(upstreams ...string) []operatorv1.Server {
+		return []operatorv1.Server{{
+			Name:          "foo",
+			Zones:         []string{"foo.com"},
+			ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: upstreams},
+		}}
+	}
+	testCases := []struct {
+		description    string
+		upstreams      []string
+		expected       []string
+		expectedStatus operatorv1.ConditionStatus
+	}{
+		{
+			description:    "plain upstreams are unchanged",
+			upstreams:      []string{"1.1.1.1", "2.2.2.2:5353"},
+			expected:       []string{"1.1.1.1", "2.2.2.2:5353"},
+			expectedStatus: operatorv1.ConditionTrue,
+		},
+		{
+			description:    "known facts are resolved",
+			upstreams:      []string{"{{.ClusterDNSIP}}:5353", "1.1.1.1"},
+			expected:       []string{"172.30.0.10:5353", "1.1.1.1"},
+			expectedStatus: operatorv1.ConditionTrue,
+		},
+		{
+			description:    "a fact that is unknown on the cluster is omitted",
+			upstreams:      []string{"{{.PlatformDNSServer}}", "1.1.1.1"},
+			expected:       []string{"1.1.1.1"},
+			expectedStatus: operatorv1.ConditionFalse,
+		},
+		{
+			description:    "an unknown fact is omitted",
+			upstreams:      []string{"{{.NodeIP}}", "1.1.1.1"},
+			expected:       []string{"1.1.1.1"},
+			expectedStatus: operatorv1.ConditionFalse,
+		},
+		{
+			description:    "functions and pipelines are omitted",
+			upstreams:      []string{`{{printf "%s" .ClusterDNSIP}}`, "{{.ClusterDNSIP | len}}", "{{if .ClusterDNSIP}}1.1.1.1{{end}}", "{{.ClusterDNSIP"},
+			expectedStatus: operatorv1.ConditionFalse,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			servers, condition := resolveUpstreamTemplates(server(tc.upstreams...), facts)
+			if !reflect.DeepEqual(servers[0].ForwardPlugin.Upstreams, tc.expected) {
+				t.Errorf("expected upstreams %v, got %v", tc.expected, servers[0].ForwardPlugin.Upstreams)
+			}
+			if condition.Status != tc.expectedStatus {
+				t.Errorf("expected condition status %s, got %s: %s", tc.expectedStatus, condition.Status, condition.Message)
+			}
+		})
+	}
+
+	facts.PlatformDNSServer = "169.254.169.253"
+	servers, _ := resolveUpstreamTemplates(server("{{ .PlatformDNSServer }}"), facts)
+	if expected := []string{"169.254.169.253"}; !reflect.DeepEqual(servers[0].ForwardPlugin.Upstreams, expected) {
+		t.Errorf("expected upstreams %v, got %v", expected, servers[0].ForwardPlugin.Upstreams)
+	}
+}
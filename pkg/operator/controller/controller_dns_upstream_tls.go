@@ -0,0 +1,367 @@
+package controller
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	// DNSUpstreamTLSConfiguredConditionType is the type of the DNS status
+	// condition that reports whether CoreDNS authenticates to the
+	// DNS-over-TLS upstreams of the dns's servers with the client
+	// certificates that the UpstreamTLSAnnotation annotation configures.
+	// The condition is reported only if the dns has the annotation.
+	DNSUpstreamTLSConfiguredConditionType = conditions.TypeUpstreamTLSConfigured
+
+	// dnsOverTLSScheme is the prefix of a DNS-over-TLS upstream, which is
+	// how CoreDNS's forward plugin denotes the transport.
+	dnsOverTLSScheme = "tls://"
+	// dnsOverTLSDefaultPort is the port of a DNS-over-TLS upstream that
+	// specifies none, which RFC 7858 assigns.
+	dnsOverTLSDefaultPort = "853"
+
+	// upstreamTLSCertDir is the directory in which the dns pods mount the
+	// client certificate secrets, each in a subdirectory named after the
+	// secret.
+	upstreamTLSCertDir = "/etc/coredns-upstream-tls"
+	// upstreamTLSCAKey is the key of a client certificate secret whose
+	// value, if any, has the CA certificates that verify the upstreams'
+	// certificates.
+	upstreamTLSCAKey = "ca.crt"
+	// upstreamTLSCertificatesHashAnnotation is the annotation on the dns
+	// pod template with a hash of the client certificate secrets, so that
+	// the pods restart and load the certificates when they are rotated;
+	// CoreDNS reads the certificates only when it loads its configuration.
+	upstreamTLSCertificatesHashAnnotation = "dns.operator.openshift.io/upstream-tls-certificates-hash"
+)
+
+// upstreamTLSSpec is an entry in the value of a dns's UpstreamTLSAnnotation
+// annotation.
+type upstreamTLSSpec struct {
+	// Server is the name of the server whose DNS-over-TLS upstreams the
+	// entry configures.
+	Server string `json:"server"`
+	// ClientCertificateSecret is the name of the secret in the operand
+	// namespace with the client certificate and key, the
+	// "<namespace>/<name>" of a secret in another namespace, or a
+	// SecretProviderReferencePrefix reference to a SecretProviderClass in
+	// the operand namespace that provides them.
+	ClientCertificateSecret string `json:"clientCertificateSecret"`
+	// ServerName is the name that the upstreams' certificates must have,
+	// or empty to verify the upstreams' addresses.
+	ServerName string `json:"serverName,omitempty"`
+	// CABundleConfigMap is the name of the configmap in the operand
+	// namespace with the CA bundle that verifies the upstreams'
+	// certificates, or the "<namespace>/<name>" of a configmap in another
+	// namespace, or empty to use the secret's CA certificates, if any.
+	CABundleConfigMap string `json:"caBundleConfigMap,omitempty"`
+}
+
+// upstreamTLS is a validated upstreamTLSSpec whose secret is usable.
+type upstreamTLS struct {
+	// Server is the name of the server.
+	Server string
+	// SecretName is the name of the client certificate secret, or a
+	// SecretProviderReferencePrefix reference to the SecretProviderClass
+	// that provides the certificate.
+	SecretName string
+	// ServerName is the name that the upstreams' certificates must have,
+	// or empty.
+	ServerName string
+	// HasCA indicates whether the secret has CA certificates.  The
+	// operator cannot see whether a SecretProviderClass provides CA
+	// certificates, so it is false for one.
+	HasCA bool
+	// CABundle is the CA bundle that verifies the upstreams' certificates
+	// instead of the secret's CA certificates, or nil.  The bundle is not
+	// part of CertificateHash because the dns pods need not restart when
+	// it changes.
+	CABundle *upstreamCABundle
+	// CertificateHash is a hash of the secret's certificates and key.
+	CertificateHash string
+}
+
+// CertDir returns the directory in which the dns pods mount the secret.
+func (u upstreamTLS) CertDir() string {
+	return credentialDir(upstreamTLSCertDir, u.SecretName)
+}
+
+// isDoTUpstream returns a Boolean value indicating whether the given upstream
+// is a DNS-over-TLS upstream.
+func isDoTUpstream(upstream string) bool {
+	return strings.HasPrefix(strings.ToLower(upstream), dnsOverTLSScheme)
+}
+
+// normalizeDoTUpstream returns the given DNS-over-TLS upstream as
+// "tls://<address>:<port>", with the default port if the upstream has none,
+// and a Boolean value indicating whether the upstream is a tls scheme followed
+// by a valid address or address and port.
+func normalizeDoTUpstream(upstream string) (string, bool) {
+	return normalizeSchemeUpstream(upstream, dnsOverTLSScheme, dnsOverTLSDefaultPort)
+}
+
+// dnsUpstreamTLS returns the client TLS configurations in the given dns's
+// UpstreamTLSAnnotation annotation whose secrets and CA bundles are usable,
+// using the given valid CA bundles by configmap name, ordered by server, and a
+// status condition that reports the entries that are ignored, or nil and a nil
+// condition if the dns does not have the annotation.  It also returns copies of
+// the given servers without the DNS-over-TLS upstreams of the servers whose
+// entries are ignored because their secrets or CA bundles are missing or
+// invalid, or of every server that the annotation configures if a secret cannot
+// be read; CoreDNS would fail to load a Corefile that refers to a missing
+// certificate, and the upstreams are expected to reject connections without
+// one.
+func (r *reconciler) dnsUpstreamTLS(dns *operatorv1.DNS, servers []operatorv1.Server, caBundles map[string]upstreamCABundle) ([]operatorv1.Server, []upstreamTLS, *operatorv1.OperatorCondition, error) {
+	value := strings.TrimSpace(dns.Annotations[UpstreamTLSAnnotation])
+	if len(value) == 0 {
+		return servers, nil, nil, nil
+	}
+	specs, problems := parseUpstreamTLS(value, servers)
+	var (
+		configs  []upstreamTLS
+		disabled = sets.NewString()
+	)
+	for _, spec := range specs {
+		config, problem, err := r.upstreamTLSConfig(spec, caBundles)
+		if err != nil {
+			for _, spec := range specs {
+				disabled.Insert(spec.Server)
+			}
+			return withoutServerDoTUpstreams(servers, disabled), nil, nil, err
+		}
+		if len(problem) != 0 {
+			problems = append(problems, fmt.Sprintf("server %s: %s", spec.Server, problem))
+			disabled.Insert(spec.Server)
+			continue
+		}
+		configs = append(configs, *config)
+	}
+
+	condition := &operatorv1.OperatorCondition{
+		Type: DNSUpstreamTLSConfiguredConditionType,
+	}
+	if len(problems) != 0 {
+		condition.Status = operatorv1.ConditionFalse
+		condition.Reason = conditions.ReasonUpstreamTLSIgnored
+		condition.Message = fmt.Sprintf("Some entries of annotation %s were ignored: %s.", UpstreamTLSAnnotation, strings.Join(problems, "; "))
+	} else {
+		condition.Status = operatorv1.ConditionTrue
+		condition.Reason = conditions.ReasonAsExpected
+		condition.Message = fmt.Sprintf("CoreDNS authenticates to the DNS-over-TLS upstreams of %d servers with client certificates.", len(configs))
+	}
+	return withoutServerDoTUpstreams(servers, disabled), configs, condition, nil
+}
+
+// parseUpstreamTLS parses the given value of an UpstreamTLSAnnotation
+// annotation and returns the valid entries, ordered by server, and the
+// problems with the invalid entries, which are ignored.  An entry is invalid if
+// its server is not one of the given servers, has no DNS-over-TLS upstreams,
+// or is another entry's server, if its server name is not a valid name, or if
+// its secret or configmap is not a valid name or "<namespace>/<name>"
+// reference.
+func parseUpstreamTLS(value string, servers []operatorv1.Server) ([]upstreamTLSSpec, []string) {
+	var specs []upstreamTLSSpec
+	if err := json.Unmarshal([]byte(value), &specs); err != nil {
+		return nil, []string{fmt.Sprintf("the annotation is not a JSON list of client TLS configurations: %v", err)}
+	}
+	dotServers := sets.NewString()
+	for _, server := range servers {
+		for _, upstream := range server.ForwardPlugin.Upstreams {
+			if isDoTUpstream(upstream) {
+				dotServers.Insert(server.Name)
+			}
+		}
+	}
+	var (
+		valid    []upstreamTLSSpec
+		problems []string
+		seen     = sets.NewString()
+	)
+	for i, spec := range specs {
+		switch {
+		case len(spec.Server) == 0:
+			problems = append(problems, fmt.Sprintf("entry %d: server must be specified", i))
+		case !dotServers.Has(spec.Server):
+			problems = append(problems, fmt.Sprintf("server %s: the server does not exist or has no valid DNS-over-TLS upstreams", spec.Server))
+		case seen.Has(spec.Server):
+			problems = append(problems, fmt.Sprintf("server %s: another entry configures the server", spec.Server))
+		case !validUpstreamTLSReference(spec.ClientCertificateSecret) && !validCredentialReference(spec.ClientCertificateSecret):
+			problems = append(problems, fmt.Sprintf("server %s: clientCertificateSecret %q is not a valid secret name, \"<namespace>/<name>\" reference, or %q reference", spec.Server, spec.ClientCertificateSecret, SecretProviderReferencePrefix+"<name>"))
+		case len(spec.ServerName) != 0 && !validDomainName(normalizeZone(spec.ServerName)):
+			problems = append(problems, fmt.Sprintf("server %s: serverName %q is not a valid domain name", spec.Server, spec.ServerName))
+		case len(spec.CABundleConfigMap) != 0 && !validUpstreamTLSReference(spec.CABundleConfigMap):
+			problems = append(problems, fmt.Sprintf("server %s: caBundleConfigMap %q is not a valid configmap name or \"<namespace>/<name>\" reference", spec.Server, spec.CABundleConfigMap))
+		default:
+			seen.Insert(spec.Server)
+			spec.ServerName = strings.TrimSuffix(strings.ToLower(spec.ServerName), ".")
+			valid = append(valid, spec)
+		}
+	}
+	sort.Slice(valid, func(i, j int) bool {
+		return valid[i].Server < valid[j].Server
+	})
+	return valid, problems
+}
+
+// upstreamTLSConfig returns the client TLS configuration for the given entry
+// with its CA bundle from the given valid CA bundles, or a nil configuration and
+// the problem with the entry if its secret or CA bundle is missing or invalid.
+func (r *reconciler) upstreamTLSConfig(spec upstreamTLSSpec, caBundles map[string]upstreamCABundle) (*upstreamTLS, string, error) {
+	var caBundle *upstreamCABundle
+	if len(spec.CABundleConfigMap) != 0 {
+		bundle, ok := caBundles[upstreamTLSLocalName(spec.CABundleConfigMap)]
+		if !ok {
+			return nil, fmt.Sprintf("CA bundle configmap %s/%s is missing or invalid", DefaultOperandNamespace, upstreamTLSLocalName(spec.CABundleConfigMap)), nil
+		}
+		caBundle = &bundle
+	}
+	if spc, ok := secretProviderClassName(spec.ClientCertificateSecret); ok {
+		hash, problem, err := r.secretProviderClassHash(spc)
+		if err != nil || len(problem) != 0 {
+			return nil, problem, err
+		}
+		return &upstreamTLS{
+			Server:          spec.Server,
+			SecretName:      spec.ClientCertificateSecret,
+			ServerName:      spec.ServerName,
+			CABundle:        caBundle,
+			CertificateHash: hash,
+		}, "", nil
+	}
+	secret := &corev1.Secret{}
+	name := types.NamespacedName{Namespace: DefaultOperandNamespace, Name: upstreamTLSLocalName(spec.ClientCertificateSecret)}
+	if err := r.client.Get(context.TODO(), name, secret); err != nil {
+		if !errors.IsNotFound(err) {
+			return nil, "", fmt.Errorf("failed to get client certificate secret %s: %w", name, err)
+		}
+		return nil, fmt.Sprintf("client certificate secret %s does not exist", name), nil
+	}
+	// A client certificate and key are validated the same way as a
+	// serving certificate and key.
+	if err := validateServingCertificateSecret(secret); err != nil {
+		return nil, fmt.Sprintf("client certificate secret %s is invalid: %v", name, err), nil
+	}
+	ca, hasCA := secret.Data[upstreamTLSCAKey]
+	if hasCA && !x509.NewCertPool().AppendCertsFromPEM(ca) {
+		return nil, fmt.Sprintf("client certificate secret %s is invalid: key %s has no PEM-encoded certificates", name, upstreamTLSCAKey), nil
+	}
+	hash, err := computeHash([][]byte{secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey], ca})
+	if err != nil {
+		return nil, "", err
+	}
+	return &upstreamTLS{
+		Server:          spec.Server,
+		SecretName:      name.Name,
+		ServerName:      spec.ServerName,
+		HasCA:           hasCA,
+		CABundle:        caBundle,
+		CertificateHash: hash,
+	}, "", nil
+}
+
+// withoutServerDoTUpstreams returns copies of the given servers without the
+// DNS-over-TLS upstreams of the servers named in the given set.  A server whose
+// upstreams are all omitted is itself omitted.
+func withoutServerDoTUpstreams(servers []operatorv1.Server, names sets.String) []operatorv1.Server {
+	if names.Len() == 0 {
+		return servers
+	}
+	var result []operatorv1.Server
+	for _, server := range servers {
+		if !names.Has(server.Name) {
+			result = append(result, server)
+			continue
+		}
+		var upstreams []string
+		for _, upstream := range server.ForwardPlugin.Upstreams {
+			if !isDoTUpstream(upstream) {
+				upstreams = append(upstreams, upstream)
+			}
+		}
+		if len(upstreams) == 0 {
+			continue
+		}
+		s := *server.DeepCopy()
+		s.ForwardPlugin.Upstreams = upstreams
+		result = append(result, s)
+	}
+	return result
+}
+
+// upstreamTLSForServer returns the client TLS configuration for the server with
+// the given name, or nil if the server has none.
+func upstreamTLSForServer(configs []upstreamTLS, name string) *upstreamTLS {
+	for i := range configs {
+		if configs[i].Server == name {
+			return &configs[i]
+		}
+	}
+	return nil
+}
+
+// upstreamTLSUsesCABundles returns a Boolean value indicating whether any of
+// the given client TLS configurations has a CA bundle from a configmap.
+func upstreamTLSUsesCABundles(configs []upstreamTLS) bool {
+	for _, config := range configs {
+		if config.CABundle != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// addUpstreamTLSCertificates mounts the secrets of the given client TLS
+// configurations in the given dns daemonset's pods and records a hash of the
+// secrets in the pod template.  Configurations that share a secret share its
+// volume.
+func addUpstreamTLSCertificates(daemonset *appsv1.DaemonSet, configs []upstreamTLS) {
+	hashes := map[string]string{}
+	for _, config := range configs {
+		hashes[config.SecretName] = config.CertificateHash
+	}
+	var secretNames []string
+	for name := range hashes {
+		secretNames = append(secretNames, name)
+	}
+	sort.Strings(secretNames)
+
+	spec := &daemonset.Spec.Template.Spec
+	var combined []string
+	for i, secretName := range secretNames {
+		volumeName := "upstream-tls-" + strconv.Itoa(i)
+		spec.Volumes = append(spec.Volumes, corev1.Volume{
+			Name:         volumeName,
+			VolumeSource: credentialVolumeSource(secretName),
+		})
+		for j := range spec.Containers {
+			if spec.Containers[j].Name != "dns" {
+				continue
+			}
+			spec.Containers[j].VolumeMounts = append(spec.Containers[j].VolumeMounts, corev1.VolumeMount{
+				Name:      volumeName,
+				MountPath: upstreamTLS{SecretName: secretName}.CertDir(),
+				ReadOnly:  true,
+			})
+		}
+		combined = append(combined, secretName+"="+hashes[secretName])
+	}
+	if daemonset.Spec.Template.Annotations == nil {
+		daemonset.Spec.Template.Annotations = map[string]string{}
+	}
+	daemonset.Spec.Template.Annotations[upstreamTLSCertificatesHashAnnotation] = strings.Join(combined, ",")
+}
@@ -0,0 +1,372 @@
+package controller
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// DNSUpstreamTLSReferencesGrantedConditionType is the type of the DNS
+	// status condition that reports whether the operator could copy the
+	// secrets and configmaps in other namespaces that the dns's
+	// UpstreamTLSAnnotation annotation refers to.  The condition is
+	// reported only if the annotation refers to another namespace.
+	DNSUpstreamTLSReferencesGrantedConditionType = conditions.TypeUpstreamTLSReferencesGranted
+
+	// upstreamTLSReferenceSyncPeriod is how often the operator copies the
+	// secrets and configmaps in other namespaces again.  The operator
+	// does not watch other namespaces, so it notices a rotated
+	// certificate or bundle, or a revoked grant, only when it copies
+	// them.
+	upstreamTLSReferenceSyncPeriod = 5 * time.Minute
+
+	// upstreamTLSCopyPrefix is the prefix of the name of a copy.
+	upstreamTLSCopyPrefix = "upstream-tls."
+	// upstreamTLSCopyLabel is the label on a copy whose value is the name
+	// of the dns for which the operator made the copy.
+	upstreamTLSCopyLabel = "dns.operator.openshift.io/upstream-tls-copy-for"
+	// upstreamTLSCopiedFromAnnotation is the annotation on a copy whose
+	// value is the "<namespace>/<name>" of the original.
+	upstreamTLSCopiedFromAnnotation = "dns.operator.openshift.io/upstream-tls-copied-from"
+
+	// operatorServiceAccountName is the name of the operator's service
+	// account, which the owner of a namespace must grant get access to a
+	// secret there before a DNS can use the secret.
+	operatorServiceAccountName = "dns-operator"
+)
+
+// upstreamTLSReference is a reference in an entry of a dns's
+// UpstreamTLSAnnotation annotation to a secret or configmap in another
+// namespace.
+type upstreamTLSReference struct {
+	// ConfigMap indicates whether the reference is to a CA bundle
+	// configmap rather than to a client certificate secret.
+	ConfigMap bool
+	// Namespace and Name are the namespace and name of the original.
+	Namespace string
+	Name      string
+}
+
+// String returns a description of the reference for messages.
+func (ref upstreamTLSReference) String() string {
+	kind := "secret"
+	if ref.ConfigMap {
+		kind = "configmap"
+	}
+	return kind + " " + ref.Namespace + "/" + ref.Name
+}
+
+// splitUpstreamTLSReference returns the namespace and name of the given secret
+// or configmap reference, "<name>" or "<namespace>/<name>".  A reference
+// without a namespace is in the operand namespace.
+func splitUpstreamTLSReference(ref string) (string, string) {
+	if i := strings.Index(ref, "/"); i >= 0 {
+		return ref[:i], ref[i+1:]
+	}
+	return DefaultOperandNamespace, ref
+}
+
+// upstreamTLSLocalName returns the name in the operand namespace of the secret
+// or configmap that the given reference refers to, which is the name of the
+// copy if the reference is to another namespace.
+func upstreamTLSLocalName(ref string) string {
+	namespace, name := splitUpstreamTLSReference(ref)
+	if namespace == DefaultOperandNamespace {
+		return name
+	}
+	return upstreamTLSCopyPrefix + namespace + "." + name
+}
+
+// validUpstreamTLSReference returns a Boolean value indicating whether the
+// given reference has a valid namespace, if any, and name, and whether the name
+// of its copy is valid.
+func validUpstreamTLSReference(ref string) bool {
+	namespace, name := splitUpstreamTLSReference(ref)
+	if len(validation.IsDNS1123Label(namespace)) != 0 || len(validation.IsDNS1123Subdomain(name)) != 0 {
+		return false
+	}
+	return len(validation.IsDNS1123Subdomain(upstreamTLSLocalName(ref))) == 0
+}
+
+// upstreamTLSReferences returns the valid references to other namespaces in
+// the given dns's UpstreamTLSAnnotation annotation, ordered and without
+// duplicates.
+func upstreamTLSReferences(dns *operatorv1.DNS) []upstreamTLSReference {
+	value := strings.TrimSpace(dns.Annotations[UpstreamTLSAnnotation])
+	if len(value) == 0 {
+		return nil
+	}
+	var specs []upstreamTLSSpec
+	if err := json.Unmarshal([]byte(value), &specs); err != nil {
+		return nil
+	}
+	var (
+		refs []upstreamTLSReference
+		seen = map[upstreamTLSReference]bool{}
+	)
+	add := func(value string, configMap bool) {
+		namespace, name := splitUpstreamTLSReference(value)
+		ref := upstreamTLSReference{ConfigMap: configMap, Namespace: namespace, Name: name}
+		if namespace == DefaultOperandNamespace || !validUpstreamTLSReference(value) || seen[ref] {
+			return
+		}
+		seen[ref] = true
+		refs = append(refs, ref)
+	}
+	for _, spec := range specs {
+		add(spec.ClientCertificateSecret, false)
+		if len(spec.CABundleConfigMap) != 0 {
+			add(spec.CABundleConfigMap, true)
+		}
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		return refs[i].String() < refs[j].String()
+	})
+	return refs
+}
+
+// upstreamTLSGranted returns a Boolean value indicating whether the given
+// object's UpstreamTLSGrantAnnotation annotation grants the dns with the given
+// name access to it.
+func upstreamTLSGranted(obj client.Object, dnsName string) bool {
+	grants := obj.GetAnnotations()[UpstreamTLSGrantAnnotation]
+	for _, grant := range strings.FieldsFunc(grants, func(r rune) bool { return r == ',' || r == ' ' }) {
+		if grant == dnsName {
+			return true
+		}
+	}
+	return false
+}
+
+// ensureUpstreamTLSCopies copies the secrets and configmaps in other
+// namespaces that the given dns's UpstreamTLSAnnotation annotation refers to
+// into the operand namespace, deletes the copies that are no longer referred
+// to or granted, and returns a status condition that reports the references
+// that could not be copied at the given time, or nil if the annotation refers
+// to no other namespaces.
+func (r *reconciler) ensureUpstreamTLSCopies(dns *operatorv1.DNS, now time.Time) (*operatorv1.OperatorCondition, error) {
+	refs := upstreamTLSReferences(dns)
+	var (
+		problems []string
+		keep     = sets.NewString()
+	)
+	for _, ref := range refs {
+		kept, problem, err := r.ensureUpstreamTLSCopy(dns, ref, now)
+		if err != nil {
+			return nil, err
+		}
+		if kept {
+			keep.Insert(ref.String())
+		}
+		if len(problem) != 0 {
+			problems = append(problems, problem)
+		}
+	}
+	if err := r.deleteUpstreamTLSCopies(dns, keep); err != nil {
+		return nil, err
+	}
+	if len(refs) == 0 {
+		return nil, nil
+	}
+
+	condition := &operatorv1.OperatorCondition{
+		Type: DNSUpstreamTLSReferencesGrantedConditionType,
+	}
+	if len(problems) != 0 {
+		condition.Status = operatorv1.ConditionFalse
+		condition.Reason = conditions.ReasonUpstreamTLSReferencesIgnored
+		condition.Message = fmt.Sprintf("Some secrets and configmaps in other namespaces could not be copied: %s.", strings.Join(problems, "; "))
+		return condition, nil
+	}
+	condition.Status = operatorv1.ConditionTrue
+	condition.Reason = conditions.ReasonAsExpected
+	condition.Message = fmt.Sprintf("All %d secrets and configmaps in other namespaces were copied.", len(refs))
+	return condition, nil
+}
+
+// ensureUpstreamTLSCopy copies the original of the given reference into the
+// operand namespace if the operator can read the original, the original grants
+// the given dns access, and the original is valid at the given time.  The
+// operator has no access to secrets outside the operand namespace, so a
+// secret is readable only if its namespace's owner grants the operator's
+// service account access to it with a role and role binding.  It returns a
+// Boolean value indicating whether the copy, if any, should be kept, and the
+// problem with the original, if any.  The copy is kept if the original is
+// invalid, so that a botched rotation does not break the upstreams, but not if
+// the original is missing, unreadable, or no longer grants access.
+func (r *reconciler) ensureUpstreamTLSCopy(dns *operatorv1.DNS, ref upstreamTLSReference, now time.Time) (bool, string, error) {
+	original := newUpstreamTLSObject(ref)
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, original); err != nil {
+		switch {
+		case errors.IsNotFound(err):
+			return false, fmt.Sprintf("%s does not exist", ref), nil
+		case errors.IsForbidden(err):
+			return false, fmt.Sprintf("%s is not readable by the operator; grant service account %s/%s get access to it with a role and role binding in namespace %s", ref, r.OperatorNamespace, operatorServiceAccountName, ref.Namespace), nil
+		}
+		return false, "", fmt.Errorf("failed to get %s: %w", ref, err)
+	}
+	if !upstreamTLSGranted(original, dns.Name) {
+		return false, fmt.Sprintf("%s does not grant dns %s access with annotation %s", ref, dns.Name, UpstreamTLSGrantAnnotation), nil
+	}
+	if err := validateUpstreamTLSObject(original, now); err != nil {
+		return true, fmt.Sprintf("%s is invalid, so its last valid copy, if any, is used: %v", ref, err), nil
+	}
+
+	name := types.NamespacedName{Namespace: DefaultOperandNamespace, Name: upstreamTLSLocalName(ref.Namespace + "/" + ref.Name)}
+	desired := newUpstreamTLSObject(ref)
+	desired.SetNamespace(name.Namespace)
+	desired.SetName(name.Name)
+	desired.SetLabels(map[string]string{upstreamTLSCopyLabel: dns.Name})
+	desired.SetAnnotations(map[string]string{upstreamTLSCopiedFromAnnotation: ref.Namespace + "/" + ref.Name})
+	desired.SetOwnerReferences([]metav1.OwnerReference{dnsOwnerRef(dns)})
+	copyUpstreamTLSData(desired, original)
+
+	current := newUpstreamTLSObject(ref)
+	if err := r.client.Get(context.TODO(), name, current); err != nil {
+		if !errors.IsNotFound(err) {
+			return false, "", fmt.Errorf("failed to get copy of %s: %w", ref, err)
+		}
+		if err := r.client.Create(context.TODO(), desired); err != nil {
+			return false, "", fmt.Errorf("failed to copy %s: %w", ref, err)
+		}
+		logrus.Infof("copied %s to %s for dns %s", ref, name, dns.Name)
+		return true, "", nil
+	}
+	if current.GetLabels()[upstreamTLSCopyLabel] != dns.Name {
+		// Never overwrite an object that the operator did not create.
+		return false, fmt.Sprintf("%s cannot be copied because %s already exists", ref, name), nil
+	}
+	if upstreamTLSDataEqual(current, desired) && current.GetAnnotations()[upstreamTLSCopiedFromAnnotation] == desired.GetAnnotations()[upstreamTLSCopiedFromAnnotation] {
+		return true, "", nil
+	}
+	updated := current.DeepCopyObject().(client.Object)
+	updated.SetAnnotations(desired.GetAnnotations())
+	copyUpstreamTLSData(updated, desired)
+	if err := r.client.Update(context.TODO(), updated); err != nil {
+		return false, "", fmt.Errorf("failed to update copy of %s: %w", ref, err)
+	}
+	logrus.Infof("updated copy %s of %s for dns %s", name, ref, dns.Name)
+	return true, "", nil
+}
+
+// deleteUpstreamTLSCopies deletes the copies that the operator made for the
+// given dns whose originals, "secret <namespace>/<name>" or "configmap
+// <namespace>/<name>", are not in the given set.
+func (r *reconciler) deleteUpstreamTLSCopies(dns *operatorv1.DNS, keep sets.String) error {
+	opts := []client.ListOption{
+		client.InNamespace(DefaultOperandNamespace),
+		client.MatchingLabels{upstreamTLSCopyLabel: dns.Name},
+	}
+	secrets := &corev1.SecretList{}
+	if err := r.client.List(context.TODO(), secrets, opts...); err != nil {
+		return fmt.Errorf("failed to list copied secrets for dns %s: %w", dns.Name, err)
+	}
+	configMaps := &corev1.ConfigMapList{}
+	if err := r.client.List(context.TODO(), configMaps, opts...); err != nil {
+		return fmt.Errorf("failed to list copied configmaps for dns %s: %w", dns.Name, err)
+	}
+	var copies []client.Object
+	for i := range secrets.Items {
+		copies = append(copies, &secrets.Items[i])
+	}
+	for i := range configMaps.Items {
+		copies = append(copies, &configMaps.Items[i])
+	}
+	for _, obj := range copies {
+		_, isConfigMap := obj.(*corev1.ConfigMap)
+		namespace, name := splitUpstreamTLSReference(obj.GetAnnotations()[upstreamTLSCopiedFromAnnotation])
+		if keep.Has(upstreamTLSReference{ConfigMap: isConfigMap, Namespace: namespace, Name: name}.String()) {
+			continue
+		}
+		if err := r.client.Delete(context.TODO(), obj); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete copy %s/%s for dns %s: %w", obj.GetNamespace(), obj.GetName(), dns.Name, err)
+		}
+		logrus.Infof("deleted copy %s/%s for dns %s", obj.GetNamespace(), obj.GetName(), dns.Name)
+	}
+	return nil
+}
+
+// newUpstreamTLSObject returns an empty secret or configmap for the given
+// reference.
+func newUpstreamTLSObject(ref upstreamTLSReference) client.Object {
+	if ref.ConfigMap {
+		return &corev1.ConfigMap{}
+	}
+	return &corev1.Secret{}
+}
+
+// validateUpstreamTLSObject validates the given client certificate secret or
+// CA bundle configmap at the given time the way that dnsUpstreamTLS and
+// dnsUpstreamCABundles do.
+func validateUpstreamTLSObject(obj client.Object, now time.Time) error {
+	switch o := obj.(type) {
+	case *corev1.Secret:
+		if err := validateServingCertificateSecret(o); err != nil {
+			return err
+		}
+		if ca, ok := o.Data[upstreamTLSCAKey]; ok && !x509.NewCertPool().AppendCertsFromPEM(ca) {
+			return fmt.Errorf("key %s has no PEM-encoded certificates", upstreamTLSCAKey)
+		}
+	case *corev1.ConfigMap:
+		if _, err := upstreamCABundleExpiry(o.Data[trustedCABundleKey], now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyUpstreamTLSData copies the data from the given original to the given
+// copy, which have the same type.  A new secret copy also gets the original's
+// secret type, which cannot be changed later.
+func copyUpstreamTLSData(dst, src client.Object) {
+	switch s := src.(type) {
+	case *corev1.Secret:
+		d := dst.(*corev1.Secret)
+		if len(d.Type) == 0 {
+			d.Type = s.Type
+		}
+		d.Data = map[string][]byte{}
+		for k, v := range s.Data {
+			d.Data[k] = append([]byte(nil), v...)
+		}
+	case *corev1.ConfigMap:
+		d := dst.(*corev1.ConfigMap)
+		d.Data = map[string]string{}
+		for k, v := range s.Data {
+			d.Data[k] = v
+		}
+	}
+}
+
+// upstreamTLSDataEqual returns a Boolean value indicating whether the given
+// secrets or configmaps have the same data.
+func upstreamTLSDataEqual(a, b client.Object) bool {
+	switch x := a.(type) {
+	case *corev1.Secret:
+		return reflect.DeepEqual(x.Data, b.(*corev1.Secret).Data)
+	case *corev1.ConfigMap:
+		return reflect.DeepEqual(x.Data, b.(*corev1.ConfigMap).Data)
+	}
+	return false
+}
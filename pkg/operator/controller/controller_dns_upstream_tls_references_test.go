@@ -0,0 +1,218 @@
+package controller
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+	operatorconfig "github.com/openshift/cluster-dns-operator/pkg/operator/config"
+
+	corev1 "k8s.io/api/core/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// TestUpstreamTLSReferences verifies that references to secrets and configmaps
+// in other namespaces are validated and named in the operand namespace, and
+// that upstreamTLSReferences returns each valid reference to another namespace
+// once.
+func TestUpstreamTLSReferences(t *testing.T) {
+	testCases := []struct {
+		ref       string
+		valid     bool
+		localName string
+	}{
+		{"corp-client", true, "corp-client"},
+		{"team-a/corp-client", true, "upstream-tls.team-a.corp-client"},
+		{"openshift-dns/corp-client", true, "corp-client"},
+		{"Team_A/corp-client", false, ""},
+		{"/corp-client", false, ""},
+		{"team-a/corp/client", false, ""},
+		{"team-a/" + strings.Repeat("a", 250), false, ""},
+	}
+	for _, tc := range testCases {
+		if valid := validUpstreamTLSReference(tc.ref); valid != tc.valid {
+			t.Errorf("%q: expected valid to be %t, got %t", tc.ref, tc.valid, valid)
+		}
+		if tc.valid && upstreamTLSLocalName(tc.ref) != tc.localName {
+			t.Errorf("%q: expected local name %q, got %q", tc.ref, tc.localName, upstreamTLSLocalName(tc.ref))
+		}
+	}
+
+	dns := &operatorv1.DNS{ObjectMeta: metav1.ObjectMeta{
+		Name: DefaultDNSController,
+		Annotations: map[string]string{UpstreamTLSAnnotation: `[
+			{"server": "corp", "clientCertificateSecret": "team-a/corp-client", "caBundleConfigMap": "team-b/corp-ca"},
+			{"server": "lab", "clientCertificateSecret": "team-a/corp-client", "caBundleConfigMap": "lab-ca"},
+			{"server": "test", "clientCertificateSecret": "Team_A/invalid"}
+		]`},
+	}}
+	expected := []upstreamTLSReference{
+		{ConfigMap: true, Namespace: "team-b", Name: "corp-ca"},
+		{Namespace: "team-a", Name: "corp-client"},
+	}
+	if actual := upstreamTLSReferences(dns); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+	if names := upstreamCABundleConfigMapNames(dns); !reflect.DeepEqual(names, []string{"lab-ca", "upstream-tls.team-b.corp-ca"}) {
+		t.Errorf("expected the CA bundle configmap names to be the local names, got %v", names)
+	}
+}
+
+// TestEnsureUpstreamTLSCopies verifies that the operator copies only readable,
+// granted, and valid secrets and configmaps from other namespaces, keeps the
+// last valid copy when an original becomes invalid, never overwrites an object
+// that it did not create, and deletes copies whose grants are revoked or that
+// are no longer referred to.
+func TestEnsureUpstreamTLSCopies(t *testing.T) {
+	now := time.Date(2021, time.June, 1, 0, 0, 0, 0, time.UTC)
+	dns := &operatorv1.DNS{ObjectMeta: metav1.ObjectMeta{
+		Name: DefaultDNSController,
+		Annotations: map[string]string{UpstreamTLSAnnotation: `[
+			{"server": "corp", "clientCertificateSecret": "team-a/corp-client", "caBundleConfigMap": "team-a/corp-ca"},
+			{"server": "lab", "clientCertificateSecret": "team-b/lab-client"},
+			{"server": "test", "clientCertificateSecret": "team-c/test-client"}
+		]`},
+	}}
+	granted := map[string]string{UpstreamTLSGrantAnnotation: "other, default"}
+	cert, key := newTestServingCertificate(t)
+	clientSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "corp-client", Annotations: granted},
+		Type:       corev1.SecretTypeTLS,
+		Data:       map[string][]byte{corev1.TLSCertKey: cert, corev1.TLSPrivateKeyKey: key},
+	}
+	caBundle := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "corp-ca", Annotations: granted},
+		Data:       map[string]string{trustedCABundleKey: newTestCACertificate(t, now.Add(90*24*time.Hour))},
+	}
+	ungranted := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-b", Name: "lab-client", Annotations: map[string]string{UpstreamTLSGrantAnnotation: "other"}},
+		Data:       clientSecret.Data,
+	}
+	// An object that the operator did not create has the name of the
+	// copy of team-c/test-client.
+	foreign := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: DefaultOperandNamespace, Name: "upstream-tls.team-c.test-client"}}
+	original := clientSecret.DeepCopy()
+	original.Namespace, original.Name = "team-c", "test-client"
+	stale := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+		Namespace:   DefaultOperandNamespace,
+		Name:        "upstream-tls.team-d.old-client",
+		Labels:      map[string]string{upstreamTLSCopyLabel: dns.Name},
+		Annotations: map[string]string{upstreamTLSCopiedFromAnnotation: "team-d/old-client"},
+	}}
+
+	c := &fakeObjectClient{objects: map[string]client.Object{}}
+	for _, obj := range []client.Object{clientSecret, caBundle, ungranted, foreign, original, stale} {
+		c.objects[fakeObjectKey(obj)] = obj
+	}
+	r := &reconciler{client: c, Config: operatorconfig.Config{OperatorNamespace: "openshift-dns-operator"}}
+	getCopy := func(obj client.Object, name string) client.Object {
+		obj.SetNamespace(DefaultOperandNamespace)
+		obj.SetName(name)
+		return c.objects[fakeObjectKey(obj)]
+	}
+
+	condition, err := r.ensureUpstreamTLSCopies(dns, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if condition == nil || condition.Status != operatorv1.ConditionFalse || condition.Reason != conditions.ReasonUpstreamTLSReferencesIgnored {
+		t.Fatalf("expected a false condition with reason %s, got %+v", conditions.ReasonUpstreamTLSReferencesIgnored, condition)
+	}
+	for _, problem := range []string{
+		"secret team-b/lab-client does not grant dns default access",
+		"secret team-c/test-client cannot be copied because openshift-dns/upstream-tls.team-c.test-client already exists",
+	} {
+		if !strings.Contains(condition.Message, problem) {
+			t.Errorf("expected the condition's message to contain %q, got %q", problem, condition.Message)
+		}
+	}
+	secretCopy, ok := getCopy(&corev1.Secret{}, "upstream-tls.team-a.corp-client").(*corev1.Secret)
+	if !ok {
+		t.Fatalf("expected secret team-a/corp-client to be copied, got %v", c.objects)
+	}
+	if !reflect.DeepEqual(secretCopy.Data, clientSecret.Data) || secretCopy.Type != corev1.SecretTypeTLS || secretCopy.Labels[upstreamTLSCopyLabel] != dns.Name || len(secretCopy.OwnerReferences) != 1 {
+		t.Errorf("expected a labeled and owned copy of secret team-a/corp-client, got %+v", secretCopy)
+	}
+	if cmCopy, ok := getCopy(&corev1.ConfigMap{}, "upstream-tls.team-a.corp-ca").(*corev1.ConfigMap); !ok || !reflect.DeepEqual(cmCopy.Data, caBundle.Data) {
+		t.Errorf("expected configmap team-a/corp-ca to be copied, got %v", cmCopy)
+	}
+	if getCopy(&corev1.Secret{}, "upstream-tls.team-b.lab-client") != nil {
+		t.Error("expected secret team-b/lab-client not to be copied")
+	}
+	if actual := c.objects[fakeObjectKey(foreign)].(*corev1.Secret); len(actual.Data) != 0 {
+		t.Errorf("expected secret %s/%s not to be overwritten, got %+v", foreign.Namespace, foreign.Name, actual)
+	}
+	if _, ok := c.objects[fakeObjectKey(stale)]; ok {
+		t.Error("expected the copy that is no longer referred to to be deleted")
+	}
+
+	// A rotated bundle is copied, but an invalid bundle is not.
+	rotated := newTestCACertificate(t, now.Add(180*24*time.Hour))
+	c.objects[fakeObjectKey(caBundle)].(*corev1.ConfigMap).Data[trustedCABundleKey] = rotated
+	if _, err := r.ensureUpstreamTLSCopies(dns, now); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cmCopy := getCopy(&corev1.ConfigMap{}, "upstream-tls.team-a.corp-ca").(*corev1.ConfigMap); cmCopy.Data[trustedCABundleKey] != rotated {
+		t.Errorf("expected the rotated bundle to be copied, got %q", cmCopy.Data[trustedCABundleKey])
+	}
+	c.objects[fakeObjectKey(caBundle)].(*corev1.ConfigMap).Data[trustedCABundleKey] = "invalid"
+	condition, err = r.ensureUpstreamTLSCopies(dns, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(condition.Message, "configmap team-a/corp-ca is invalid, so its last valid copy, if any, is used") {
+		t.Errorf("expected the condition to report the invalid bundle, got %q", condition.Message)
+	}
+	if cmCopy := getCopy(&corev1.ConfigMap{}, "upstream-tls.team-a.corp-ca").(*corev1.ConfigMap); cmCopy.Data[trustedCABundleKey] != rotated {
+		t.Errorf("expected the last valid bundle to be kept, got %q", cmCopy.Data[trustedCABundleKey])
+	}
+
+	// Revoking the operator's access to a secret deletes the copy.
+	c.forbidden = map[string]bool{fakeObjectKey(clientSecret): true}
+	condition, err = r.ensureUpstreamTLSCopies(dns, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if problem := "secret team-a/corp-client is not readable by the operator; grant service account openshift-dns-operator/dns-operator get access to it with a role and role binding in namespace team-a"; !strings.Contains(condition.Message, problem) {
+		t.Errorf("expected the condition's message to contain %q, got %q", problem, condition.Message)
+	}
+	if getCopy(&corev1.Secret{}, "upstream-tls.team-a.corp-client") != nil {
+		t.Error("expected the copy of secret team-a/corp-client to be deleted when the operator's access is revoked")
+	}
+
+	// Revoking a grant deletes the copy.
+	c.forbidden = nil
+	if _, err := r.ensureUpstreamTLSCopies(dns, now); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if getCopy(&corev1.Secret{}, "upstream-tls.team-a.corp-client") == nil {
+		t.Fatal("expected secret team-a/corp-client to be copied again when the operator's access is restored")
+	}
+	c.objects[fakeObjectKey(clientSecret)].(*corev1.Secret).Annotations = nil
+	if _, err := r.ensureUpstreamTLSCopies(dns, now); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if getCopy(&corev1.Secret{}, "upstream-tls.team-a.corp-client") != nil {
+		t.Error("expected the copy of secret team-a/corp-client to be deleted when its grant is revoked")
+	}
+
+	// Without references, every copy is deleted and no condition is
+	// reported.
+	delete(dns.Annotations, UpstreamTLSAnnotation)
+	condition, err = r.ensureUpstreamTLSCopies(dns, now)
+	if err != nil || condition != nil {
+		t.Errorf("expected no condition and no error without references, got %v, %v", condition, err)
+	}
+	if getCopy(&corev1.ConfigMap{}, "upstream-tls.team-a.corp-ca") != nil {
+		t.Error("expected the copy of configmap team-a/corp-ca to be deleted")
+	}
+	if _, ok := c.objects[fakeObjectKey(foreign)]; !ok {
+		t.Error("expected the object that the operator did not create to remain")
+	}
+}
@@ -0,0 +1,169 @@
+package controller
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// TestNormalizeDoTUpstream verifies that normalizeDoTUpstream accepts only a
+// tls scheme followed by an address or address and port.
+func TestNormalizeDoTUpstream(t *testing.T) {
+	testCases := []struct {
+		upstream string
+		expected string
+		valid    bool
+	}{
+		{"tls://1.1.1.1", "tls://1.1.1.1:853", true},
+		{"TLS://1.1.1.1:8853", "tls://1.1.1.1:8853", true},
+		{"tls://[2001:db8::1]:853", "tls://[2001:db8::1]:853", true},
+		{"tls://dns.example.com", "", false},
+		{"tls://1.1.1.1:65536", "", false},
+		{"tls://", "", false},
+	}
+	for _, tc := range testCases {
+		actual, valid := normalizeDoTUpstream(tc.upstream)
+		if valid != tc.valid || actual != tc.expected {
+			t.Errorf("%q: expected (%q, %t), got (%q, %t)", tc.upstream, tc.expected, tc.valid, actual, valid)
+		}
+	}
+}
+
+// TestParseUpstreamTLS verifies that parseUpstreamTLS accepts only entries for
+// servers with DNS-over-TLS upstreams and with valid secret and server names.
+func TestParseUpstreamTLS(t *testing.T) {
+	servers := []operatorv1.Server{
+		{Name: "corp", ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"tls://10.0.0.53", "10.0.0.54"}}},
+		{Name: "lab", ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"tls://10.1.0.53:8853"}}},
+		{Name: "plain", ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"10.2.0.53"}}},
+	}
+	value := `[
+		{"server": "lab", "clientCertificateSecret": "csi:lab-client-store"},
+		{"server": "corp", "clientCertificateSecret": "corp-client", "serverName": "DNS.Corp.Example.com."},
+		{"server": "corp", "clientCertificateSecret": "other-client"},
+		{"server": "plain", "clientCertificateSecret": "plain-client"},
+		{"server": "missing", "clientCertificateSecret": "missing-client"},
+		{"clientCertificateSecret": "no-server"},
+		{"server": "lab", "clientCertificateSecret": "Invalid_Name"}
+	]`
+	specs, problems := parseUpstreamTLS(value, servers)
+	expected := []upstreamTLSSpec{
+		{Server: "corp", ClientCertificateSecret: "corp-client", ServerName: "dns.corp.example.com"},
+		{Server: "lab", ClientCertificateSecret: "csi:lab-client-store"},
+	}
+	if !reflect.DeepEqual(specs, expected) {
+		t.Errorf("expected %#v, got %#v", expected, specs)
+	}
+	expectedProblems := []string{
+		"server corp: another entry configures the server",
+		"server plain: the server does not exist or has no valid DNS-over-TLS upstreams",
+		"server missing: the server does not exist or has no valid DNS-over-TLS upstreams",
+		"entry 5: server must be specified",
+		"server lab: another entry configures the server",
+	}
+	if !reflect.DeepEqual(problems, expectedProblems) {
+		t.Errorf("expected problems %q, got %q", expectedProblems, problems)
+	}
+
+	if _, problems := parseUpstreamTLS("corp=corp-client", servers); len(problems) != 1 || !strings.Contains(problems[0], "not a JSON list") {
+		t.Errorf("expected a problem with the annotation's format, got %q", problems)
+	}
+}
+
+// TestWithoutServerDoTUpstreams verifies that withoutServerDoTUpstreams omits
+// only the DNS-over-TLS upstreams of the given servers.
+func TestWithoutServerDoTUpstreams(t *testing.T) {
+	servers := []operatorv1.Server{
+		{Name: "corp", ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"tls://10.0.0.53", "10.0.0.54"}}},
+		{Name: "lab", ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"tls://10.1.0.53:8853"}}},
+		{Name: "other", ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"tls://10.2.0.53"}}},
+	}
+	actual := withoutServerDoTUpstreams(servers, sets.NewString("corp", "lab"))
+	if len(actual) != 2 || !reflect.DeepEqual(actual[0].ForwardPlugin.Upstreams, []string{"10.0.0.54"}) || actual[1].Name != "other" {
+		t.Errorf("expected server corp with upstream 10.0.0.54 and server other, got %#v", actual)
+	}
+	if servers[0].ForwardPlugin.Upstreams[0] != "tls://10.0.0.53" {
+		t.Errorf("expected the servers not to be modified, got %#v", servers)
+	}
+}
+
+// TestDesiredUpstreamTLS verifies that the Corefile authenticates to a server's
+// DNS-over-TLS upstreams with its client certificate and that the daemonset
+// mounts each client certificate secret once and records the secrets' hashes.
+func TestDesiredUpstreamTLS(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DefaultDNSController,
+		},
+	}
+	servers := []operatorv1.Server{
+		{Name: "corp", Zones: []string{"corp.example.com"}, ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"tls://10.0.0.53:853"}}},
+		{Name: "lab", Zones: []string{"lab.example.com"}, ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"tls://10.1.0.53:853"}}},
+		{Name: "plain", Zones: []string{"plain.example.com"}, ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"10.2.0.53"}}},
+	}
+	configs := []upstreamTLS{
+		{Server: "corp", SecretName: "corp-client", ServerName: "dns.corp.example.com", HasCA: true, CertificateHash: "abc"},
+		{Server: "lab", SecretName: "corp-client", CertificateHash: "abc"},
+	}
+
+	cm, err := desiredDNSConfigMap(dns, corefileInputs{Servers: servers, UpstreamTLS: configs, ClusterDomain: "cluster.local", MetricsAddress: "127.0.0.1:9153"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `# corp
+corp.example.com:5353 {
+    forward . tls://10.0.0.53:853 {
+        tls /etc/coredns-upstream-tls/corp-client/tls.crt /etc/coredns-upstream-tls/corp-client/tls.key /etc/coredns-upstream-tls/corp-client/ca.crt
+        tls_servername dns.corp.example.com
+    }
+    errors
+    bufsize 1232
+    prometheus 127.0.0.1:9153
+}
+# lab
+lab.example.com:5353 {
+    forward . tls://10.1.0.53:853 {
+        tls /etc/coredns-upstream-tls/corp-client/tls.crt /etc/coredns-upstream-tls/corp-client/tls.key
+    }
+    errors
+    bufsize 1232
+    prometheus 127.0.0.1:9153
+}
+# plain
+plain.example.com:5353 {
+    forward . 10.2.0.53
+    errors
+`
+	if !strings.HasPrefix(cm.Data["Corefile"], expected) {
+		t.Errorf("expected Corefile to start with:\n%s\ngot:\n%s", expected, cm.Data["Corefile"])
+	}
+
+	ds, err := desiredDNSDaemonSet(dns, "coredns", "kube-rbac-proxy", "", "dns-default-metrics-tls", "", nil, nil, configs, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := ds.Spec.Template.Annotations[upstreamTLSCertificatesHashAnnotation]; actual != "corp-client=abc" {
+		t.Errorf("expected certificates hash %q, got %q", "corp-client=abc", actual)
+	}
+	var volumes, mounts []string
+	for _, v := range ds.Spec.Template.Spec.Volumes {
+		if v.Secret != nil && v.Secret.SecretName == "corp-client" {
+			volumes = append(volumes, v.Name)
+		}
+	}
+	for _, c := range ds.Spec.Template.Spec.Containers {
+		for _, m := range c.VolumeMounts {
+			if strings.HasPrefix(m.MountPath, upstreamTLSCertDir) {
+				mounts = append(mounts, c.Name+":"+m.Name+":"+m.MountPath)
+			}
+		}
+	}
+	if !reflect.DeepEqual(volumes, []string{"upstream-tls-0"}) || !reflect.DeepEqual(mounts, []string{"dns:upstream-tls-0:/etc/coredns-upstream-tls/corp-client"}) {
+		t.Errorf("expected the dns container to mount secret corp-client once, got volumes %v and mounts %v", volumes, mounts)
+	}
+}
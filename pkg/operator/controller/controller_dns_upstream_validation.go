@@ -0,0 +1,140 @@
+package controller
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	// DNSUpstreamsValidConditionType is the type of the DNS status
+	// condition that indicates whether the upstreams of the dns's servers
+	// are valid.  Invalid upstreams are omitted from the Corefile.
+	DNSUpstreamsValidConditionType = conditions.TypeUpstreamsValid
+
+	// maxForwardUpstreams is the largest number of upstreams that
+	// CoreDNS's forward plugin accepts.  CoreDNS refuses to load a
+	// Corefile with more.
+	maxForwardUpstreams = 15
+	// maxResolvConfNameservers is the number of upstreams that are
+	// reserved for the nameservers in /etc/resolv.conf when a server falls
+	// back to the default upstreams, which is the number of nameservers
+	// that the resolver uses.
+	maxResolvConfNameservers = 3
+)
+
+// validUpstreamServers returns copies of the given servers without upstreams
+// that are not addresses, https URLs, quic addresses, or tls addresses, that
+// duplicate an earlier upstream of the same server, or that exceed the forward
+// plugin's limit, along with a status condition that describes each omitted
+// upstream.  The servers named in the given set fall back to the default
+// upstreams, so they have fewer upstreams available.  A server whose upstreams
+// are all omitted is itself omitted.
+func validUpstreamServers(dnsServers []operatorv1.Server, fallback sets.String) ([]operatorv1.Server, operatorv1.OperatorCondition) {
+	var (
+		servers  []operatorv1.Server
+		problems []string
+	)
+	for _, server := range dnsServers {
+		limit := maxForwardUpstreams
+		if fallback.Has(server.Name) {
+			limit -= maxResolvConfNameservers
+		}
+		var upstreams []string
+		seen := map[string]int{}
+		for i, upstream := range server.ForwardPlugin.Upstreams {
+			entry := fmt.Sprintf("server %q upstream %d (%q)", server.Name, i+1, upstream)
+			key, ok := normalizeUpstream(upstream)
+			switch {
+			case !ok:
+				problems = append(problems, fmt.Sprintf("%s is not an IP address, IP address and port, https URL, quic address, or tls address", entry))
+				continue
+			case seen[key] != 0:
+				problems = append(problems, fmt.Sprintf("%s duplicates upstream %d", entry, seen[key]))
+				continue
+			case len(upstreams) == limit:
+				problems = append(problems, fmt.Sprintf("%s exceeds the limit of %d upstreams", entry, limit))
+				continue
+			}
+			seen[key] = i + 1
+			upstreams = append(upstreams, upstream)
+		}
+		if len(upstreams) == 0 {
+			problems = append(problems, fmt.Sprintf("server %q was omitted because it has no valid upstreams", server.Name))
+			continue
+		}
+		valid := *server.DeepCopy()
+		valid.ForwardPlugin.Upstreams = upstreams
+		servers = append(servers, valid)
+	}
+
+	condition := operatorv1.OperatorCondition{
+		Type: DNSUpstreamsValidConditionType,
+	}
+	if len(problems) != 0 {
+		condition.Status = operatorv1.ConditionFalse
+		condition.Reason = conditions.ReasonInvalidUpstreamsOmitted
+		condition.Message = fmt.Sprintf("Some upstreams were omitted: %s.", strings.Join(problems, "; "))
+	} else {
+		condition.Status = operatorv1.ConditionTrue
+		condition.Reason = conditions.ReasonAsExpected
+		condition.Message = "All upstreams are valid."
+	}
+	return servers, condition
+}
+
+// normalizeUpstream returns the given upstream as an address and port, with
+// the default port if the upstream has none, or as a normalized https URL,
+// quic address, or tls address if the upstream is a DNS-over-HTTPS,
+// DNS-over-QUIC, or DNS-over-TLS upstream, and a Boolean value indicating
+// whether the upstream is a valid address, address and port, https URL, quic
+// address, or tls address.
+func normalizeUpstream(upstream string) (string, bool) {
+	if isDoHUpstream(upstream) {
+		return normalizeDoHUpstream(upstream)
+	}
+	if isDoQUpstream(upstream) {
+		return normalizeDoQUpstream(upstream)
+	}
+	if isDoTUpstream(upstream) {
+		return normalizeDoTUpstream(upstream)
+	}
+	host, port := upstream, "53"
+	if h, p, err := net.SplitHostPort(upstream); err == nil {
+		host, port = h, p
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return "", false
+		}
+	}
+	ip := net.ParseIP(upstreamHost(host))
+	if ip == nil {
+		return "", false
+	}
+	return net.JoinHostPort(ip.String(), port), true
+}
+
+// normalizeSchemeUpstream returns the given upstream, which has the given
+// scheme in any case, as "<scheme><address>:<port>", with the given default
+// port if the upstream has none, and a Boolean value indicating whether the
+// scheme is followed by a valid address or address and port.
+func normalizeSchemeUpstream(upstream, scheme, defaultPort string) (string, bool) {
+	address := upstream[len(scheme):]
+	host, port := address, defaultPort
+	if h, p, err := net.SplitHostPort(address); err == nil {
+		host, port = h, p
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return "", false
+		}
+	}
+	ip := net.ParseIP(upstreamHost(host))
+	if ip == nil {
+		return "", false
+	}
+	return scheme + net.JoinHostPort(ip.String(), port), true
+}
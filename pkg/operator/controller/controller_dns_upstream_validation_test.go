@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// TestValidUpstreamServers verifies that validUpstreamServers omits invalid,
+// duplicate, and excess upstreams and reports each omitted upstream.
+func TestValidUpstreamServers(t *testing.T) {
+	many := func(n int) []string {
+		var upstreams []string
+		for i := 1; i <= n; i++ {
+			upstreams = append(upstreams, fmt.Sprintf("10.0.0.%d", i))
+		}
+		return upstreams
+	}
+	server := func(name string, upstreams ...string) operatorv1.Server {
+		return operatorv1.Server{
+			Name:          name,
+			Zones:         []string{name + ".com"},
+			ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: upstreams},
+		}
+	}
+	testCases := []struct {
+		description     string
+		servers         []operatorv1.Server
+		fallback        sets.String
+		expected        map[string][]string
+		expectProblems  []string
+		expectCondition operatorv1.ConditionStatus
+	}{
+		{
+			description:     "valid upstreams",
+			servers:         []operatorv1.Server{server("foo", "1.1.1.1", "1.1.1.1:5353", "[fd00::1]:53")},
+			expected:        map[string][]string{"foo": {"1.1.1.1", "1.1.1.1:5353", "[fd00::1]:53"}},
+			expectCondition: operatorv1.ConditionTrue,
+		},
+		{
+			description: "duplicate upstreams",
+			servers:     []operatorv1.Server{server("foo", "1.1.1.1", "2.2.2.2", "1.1.1.1:53", "fd00:0::1", "fd00::1")},
+			expected:    map[string][]string{"foo": {"1.1.1.1", "2.2.2.2", "fd00:0::1"}},
+			expectProblems: []string{
+				`server "foo" upstream 3 ("1.1.1.1:53") duplicates upstream 1`,
+				`server "foo" upstream 5 ("fd00::1") duplicates upstream 4`,
+			},
+			expectCondition: operatorv1.ConditionFalse,
+		},
+		{
+			description: "invalid upstreams",
+			servers:     []operatorv1.Server{server("foo", "dns.example.com", "1.1.1.1:0"), server("bar", "2.2.2.2")},
+			expected:    map[string][]string{"bar": {"2.2.2.2"}},
+			expectProblems: []string{
+				`server "foo" upstream 1 ("dns.example.com") is not an IP address`,
+				`server "foo" upstream 2 ("1.1.1.1:0") is not an IP address`,
+				`server "foo" was omitted because it has no valid upstreams`,
+			},
+			expectCondition: operatorv1.ConditionFalse,
+		},
+		{
+			description:     "too many upstreams",
+			servers:         []operatorv1.Server{server("foo", many(16)...)},
+			expected:        map[string][]string{"foo": many(15)},
+			expectProblems:  []string{`server "foo" upstream 16 ("10.0.0.16") exceeds the limit of 15 upstreams`},
+			expectCondition: operatorv1.ConditionFalse,
+		},
+		{
+			description:     "too many upstreams with fallback",
+			servers:         []operatorv1.Server{server("foo", many(13)...)},
+			fallback:        sets.NewString("foo"),
+			expected:        map[string][]string{"foo": many(12)},
+			expectProblems:  []string{`server "foo" upstream 13 ("10.0.0.13") exceeds the limit of 12 upstreams`},
+			expectCondition: operatorv1.ConditionFalse,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			servers, condition := validUpstreamServers(tc.servers, tc.fallback)
+			actual := map[string][]string{}
+			for _, s := range servers {
+				actual[s.Name] = s.ForwardPlugin.Upstreams
+			}
+			if !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("expected upstreams %v, got %v", tc.expected, actual)
+			}
+			if condition.Status != tc.expectCondition {
+				t.Errorf("expected condition status %s, got %s: %s", tc.expectCondition, condition.Status, condition.Message)
+			}
+			for _, problem := range tc.expectProblems {
+				if !strings.Contains(condition.Message, problem) {
+					t.Errorf("expected condition message to contain %q, got %q", problem, condition.Message)
+				}
+			}
+		})
+	}
+}
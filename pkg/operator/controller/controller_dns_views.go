@@ -0,0 +1,186 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// DNSViewsAppliedConditionType is the type of the DNS status condition that
+// reports whether the views in the dns's ViewsAnnotation annotation are
+// applied.  The condition is reported only if the dns has the annotation.
+const DNSViewsAppliedConditionType = conditions.TypeViewsApplied
+
+// viewResponses are the response codes with which a view may answer every
+// query for its zones.
+var viewResponses = sets.NewString("NXDOMAIN", "REFUSED")
+
+// viewSpec is an entry in the value of a dns's ViewsAnnotation annotation.
+type viewSpec struct {
+	// Name is the name of the view.
+	Name string `json:"name"`
+	// SourceCIDRs are the prefixes of the addresses of the clients to
+	// which the view applies.
+	SourceCIDRs []string `json:"sourceCIDRs"`
+	// Zones are the zones that the view answers differently.
+	Zones []string `json:"zones"`
+	// Upstreams are the upstreams to which the view forwards queries.
+	Upstreams []string `json:"upstreams,omitempty"`
+	// Response is the response code with which the view answers queries
+	// instead of forwarding them.
+	Response string `json:"response,omitempty"`
+}
+
+// dnsView is a validated viewSpec.
+type dnsView struct {
+	// Name is the name of the view.
+	Name string
+	// SourceCIDRs are the prefixes of the view's clients, in canonical
+	// form.
+	SourceCIDRs []string
+	// Zones are the view's zones, lowercase and without trailing dots.
+	Zones []string
+	// Upstreams are the view's upstreams, or nil if the view answers
+	// with Response.
+	Upstreams []string
+	// Response is the uppercase response code of the view, or empty if
+	// the view forwards queries to Upstreams.
+	Response string
+}
+
+// Expression returns the expression with which CoreDNS's view plugin selects
+// the queries from the view's clients.
+func (v dnsView) Expression() string {
+	var terms []string
+	for _, cidr := range v.SourceCIDRs {
+		terms = append(terms, fmt.Sprintf("incidr(client_ip(), '%s')", cidr))
+	}
+	return strings.Join(terms, " || ")
+}
+
+// viewsEnabled returns a Boolean value indicating whether the given dns has a
+// non-empty ViewsAnnotation annotation.
+func viewsEnabled(dns *operatorv1.DNS) bool {
+	return len(strings.TrimSpace(dns.Annotations[ViewsAnnotation])) != 0
+}
+
+// dnsViews returns the valid views in the given dns's ViewsAnnotation
+// annotation, in the annotation's order, and a status condition that reports
+// the invalid views, or nil and a nil condition if the dns does not have the
+// annotation.
+func dnsViews(dns *operatorv1.DNS, clusterDomain string) ([]dnsView, *operatorv1.OperatorCondition) {
+	if !viewsEnabled(dns) {
+		return nil, nil
+	}
+	views, problems := parseViews(dns.Annotations[ViewsAnnotation], clusterDomain)
+	condition := &operatorv1.OperatorCondition{
+		Type: DNSViewsAppliedConditionType,
+	}
+	if len(problems) != 0 {
+		condition.Status = operatorv1.ConditionFalse
+		condition.Reason = conditions.ReasonViewsIgnored
+		condition.Message = fmt.Sprintf("Some views were ignored: %s.", strings.Join(problems, "; "))
+	} else {
+		condition.Status = operatorv1.ConditionTrue
+		condition.Reason = conditions.ReasonAsExpected
+		condition.Message = fmt.Sprintf("All %d views are applied.", len(views))
+	}
+	return views, condition
+}
+
+// parseViews parses the given value of a ViewsAnnotation annotation and returns
+// the valid views, in order, and the problems with the invalid views, which are
+// ignored.  A view is invalid if its name is not a valid label or is another
+// view's name, if it has no source CIDRs or an invalid one, if it has no zones
+// or a zone that is the root zone or is in, or contains, the given cluster
+// domain, or if it does not have either plain DNS upstreams or a valid
+// response.
+func parseViews(value, clusterDomain string) ([]dnsView, []string) {
+	var specs []viewSpec
+	if err := json.Unmarshal([]byte(value), &specs); err != nil {
+		return nil, []string{fmt.Sprintf("the annotation is not a JSON list of views: %v", err)}
+	}
+	var (
+		views    []dnsView
+		problems []string
+		seen     = sets.NewString()
+	)
+	clusterDomain = normalizeZone(clusterDomain)
+	for i, spec := range specs {
+		view, err := parseView(spec, clusterDomain)
+		switch {
+		case len(spec.Name) == 0:
+			problems = append(problems, fmt.Sprintf("entry %d: name must be specified", i))
+		case len(validation.IsDNS1123Label(spec.Name)) != 0:
+			problems = append(problems, fmt.Sprintf("view %s: the name is not a valid label", spec.Name))
+		case seen.Has(spec.Name):
+			problems = append(problems, fmt.Sprintf("view %s: another entry has the name", spec.Name))
+		case err != nil:
+			problems = append(problems, fmt.Sprintf("view %s: %v", spec.Name, err))
+		default:
+			seen.Insert(spec.Name)
+			views = append(views, view)
+		}
+	}
+	return views, problems
+}
+
+// parseView validates the given view, whose name is not validated, and returns
+// it in canonical form.
+func parseView(spec viewSpec, clusterDomain string) (dnsView, error) {
+	view := dnsView{Name: spec.Name}
+	if len(spec.SourceCIDRs) == 0 {
+		return view, fmt.Errorf("sourceCIDRs must be specified")
+	}
+	for _, cidr := range spec.SourceCIDRs {
+		_, prefix, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return view, fmt.Errorf("source CIDR %q is not valid", cidr)
+		}
+		view.SourceCIDRs = append(view.SourceCIDRs, prefix.String())
+	}
+	if len(spec.Zones) == 0 {
+		return view, fmt.Errorf("zones must be specified")
+	}
+	for _, z := range spec.Zones {
+		zone := normalizeZone(z)
+		switch {
+		case zone == ".":
+			return view, fmt.Errorf("the root zone would send every query from the view's clients away from the cluster's services")
+		case !validDomainName(zone):
+			return view, fmt.Errorf("zone %q is not a valid domain name", z)
+		case nameInZone(zone, clusterDomain) || nameInZone(clusterDomain, zone):
+			return view, fmt.Errorf("zone %s overlaps the cluster domain", z)
+		}
+		view.Zones = append(view.Zones, strings.TrimSuffix(zone, "."))
+	}
+	switch {
+	case len(spec.Upstreams) != 0 && len(spec.Response) != 0:
+		return view, fmt.Errorf("only one of upstreams and response may be specified")
+	case len(spec.Response) != 0:
+		view.Response = strings.ToUpper(spec.Response)
+		if !viewResponses.Has(view.Response) {
+			return view, fmt.Errorf("response %q is not one of %s", spec.Response, strings.Join(viewResponses.List(), ", "))
+		}
+	case len(spec.Upstreams) > maxForwardUpstreams:
+		return view, fmt.Errorf("the view has more than %d upstreams", maxForwardUpstreams)
+	case len(spec.Upstreams) != 0:
+		for _, upstream := range spec.Upstreams {
+			address, ok := normalizeUpstream(upstream)
+			if !ok || isDoHUpstream(upstream) || isDoQUpstream(upstream) || isDoTUpstream(upstream) {
+				return view, fmt.Errorf("upstream %q is not an IP address or IP address and port", upstream)
+			}
+			view.Upstreams = append(view.Upstreams, address)
+		}
+	default:
+		return view, fmt.Errorf("either upstreams or response must be specified")
+	}
+	return view, nil
+}
@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestParseViews verifies that parseViews keeps the annotation's order and
+// ignores views with invalid names, CIDRs, zones, upstreams, or responses.
+func TestParseViews(t *testing.T) {
+	value := `[
+		{"name": "infra", "sourceCIDRs": ["10.0.1.2/16", "fd00::/64"], "zones": ["Corp.Example.com."], "upstreams": ["10.0.0.53", "10.0.0.54:5353"]},
+		{"name": "workloads", "sourceCIDRs": ["10.128.0.0/14"], "zones": ["corp.example.com"], "response": "refused"},
+		{"name": "infra", "sourceCIDRs": ["10.0.0.0/16"], "zones": ["lab.example.com"], "response": "NXDOMAIN"},
+		{"sourceCIDRs": ["10.0.0.0/16"], "zones": ["lab.example.com"], "response": "NXDOMAIN"},
+		{"name": "Bad_Name", "sourceCIDRs": ["10.0.0.0/16"], "zones": ["lab.example.com"], "response": "NXDOMAIN"},
+		{"name": "no-cidrs", "zones": ["lab.example.com"], "response": "NXDOMAIN"},
+		{"name": "bad-cidr", "sourceCIDRs": ["10.0.0.0"], "zones": ["lab.example.com"], "response": "NXDOMAIN"},
+		{"name": "root", "sourceCIDRs": ["10.0.0.0/16"], "zones": ["."], "response": "NXDOMAIN"},
+		{"name": "cluster", "sourceCIDRs": ["10.0.0.0/16"], "zones": ["svc.cluster.local"], "response": "NXDOMAIN"},
+		{"name": "both", "sourceCIDRs": ["10.0.0.0/16"], "zones": ["lab.example.com"], "upstreams": ["10.0.0.53"], "response": "NXDOMAIN"},
+		{"name": "neither", "sourceCIDRs": ["10.0.0.0/16"], "zones": ["lab.example.com"]},
+		{"name": "tls", "sourceCIDRs": ["10.0.0.0/16"], "zones": ["lab.example.com"], "upstreams": ["tls://10.0.0.53"]},
+		{"name": "servfail", "sourceCIDRs": ["10.0.0.0/16"], "zones": ["lab.example.com"], "response": "SERVFAIL"}
+	]`
+	views, problems := parseViews(value, "cluster.local")
+	expected := []dnsView{
+		{Name: "infra", SourceCIDRs: []string{"10.0.0.0/16", "fd00::/64"}, Zones: []string{"corp.example.com"}, Upstreams: []string{"10.0.0.53:53", "10.0.0.54:5353"}},
+		{Name: "workloads", SourceCIDRs: []string{"10.128.0.0/14"}, Zones: []string{"corp.example.com"}, Response: "REFUSED"},
+	}
+	if !reflect.DeepEqual(views, expected) {
+		t.Errorf("expected %#v, got %#v", expected, views)
+	}
+	expectedProblems := []string{
+		"view infra: another entry has the name",
+		"entry 3: name must be specified",
+		"view Bad_Name: the name is not a valid label",
+		"view no-cidrs: sourceCIDRs must be specified",
+		`view bad-cidr: source CIDR "10.0.0.0" is not valid`,
+		"view root: the root zone would send every query from the view's clients away from the cluster's services",
+		"view cluster: zone svc.cluster.local overlaps the cluster domain",
+		"view both: only one of upstreams and response may be specified",
+		"view neither: either upstreams or response must be specified",
+		`view tls: upstream "tls://10.0.0.53" is not an IP address or IP address and port`,
+		`view servfail: response "SERVFAIL" is not one of NXDOMAIN, REFUSED`,
+	}
+	if !reflect.DeepEqual(problems, expectedProblems) {
+		t.Errorf("expected problems %q, got %q", expectedProblems, problems)
+	}
+}
+
+// TestDesiredDNSConfigMapViews verifies that the view blocks precede the
+// server block that answers the other clients for the same zone.
+func TestDesiredDNSConfigMapViews(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DefaultDNSController,
+		},
+	}
+	servers := []operatorv1.Server{
+		{Name: "corp", Zones: []string{"corp.example.com"}, ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"10.1.0.53"}}},
+	}
+	views := []dnsView{
+		{Name: "infra", SourceCIDRs: []string{"10.0.0.0/16", "fd00::/64"}, Zones: []string{"corp.example.com"}, Upstreams: []string{"10.0.0.53:53"}},
+		{Name: "workloads", SourceCIDRs: []string{"10.128.0.0/14"}, Zones: []string{"corp.example.com"}, Response: "REFUSED"},
+	}
+	cm, err := desiredDNSConfigMap(dns, corefileInputs{Servers: servers, Views: views, ClusterDomain: "cluster.local", MetricsAddress: "127.0.0.1:9153"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `# view infra
+corp.example.com:5353 {
+    view infra {
+        expr incidr(client_ip(), '10.0.0.0/16') || incidr(client_ip(), 'fd00::/64')
+    }
+    forward . 10.0.0.53:53
+    errors
+    bufsize 1232
+    prometheus 127.0.0.1:9153
+}
+# view workloads
+corp.example.com:5353 {
+    view workloads {
+        expr incidr(client_ip(), '10.128.0.0/14')
+    }
+    template ANY ANY {
+        rcode REFUSED
+    }
+    errors
+    bufsize 1232
+    prometheus 127.0.0.1:9153
+}
+# corp
+corp.example.com:5353 {
+    forward . 10.1.0.53
+`
+	if !strings.HasPrefix(cm.Data["Corefile"], expected) {
+		t.Errorf("expected Corefile to start with:\n%s\ngot:\n%s", expected, cm.Data["Corefile"])
+	}
+}
@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"strconv"
+	"strings"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/manifests"
+
+	"github.com/sirupsen/logrus"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	// maxWarmCacheNames is the most names that a dns pod resolves to warm
+	// its cache, so that the warm-up cannot delay the pod's readiness
+	// indefinitely or flood the upstream resolvers when many pods start
+	// at once.
+	maxWarmCacheNames = 100
+	// warmCacheReadyFile is the file that the warm-cache sidecar creates
+	// once the cache is warm.
+	warmCacheReadyFile = "/tmp/warm-cache-ready"
+)
+
+var (
+	// dnsWarmCacheScript is a shell script that warms CoreDNS's cache
+	// before the dns pod becomes ready.
+	dnsWarmCacheScript = manifests.DNSWarmCacheScript()
+)
+
+// warmCacheNames returns the valid names in the given dns's
+// WarmCacheNamesAnnotation annotation, fully qualified, lowercase, and without
+// duplicates, in the annotation's order.
+func warmCacheNames(dns *operatorv1.DNS) []string {
+	var names []string
+	seen := sets.NewString()
+	list := dns.Annotations[WarmCacheNamesAnnotation]
+	for _, name := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' }) {
+		fqdn := normalizeZone(name)
+		switch {
+		case fqdn == "." || !validDomainName(fqdn):
+			logrus.Warningf("ignoring invalid name %q in annotation %s on dns %s", name, WarmCacheNamesAnnotation, dns.Name)
+		case seen.Has(fqdn):
+		case len(names) == maxWarmCacheNames:
+			logrus.Warningf("ignoring name %q in annotation %s on dns %s: at most %d names are resolved", name, WarmCacheNamesAnnotation, dns.Name, maxWarmCacheNames)
+		default:
+			seen.Insert(fqdn)
+			names = append(names, fqdn)
+		}
+	}
+	return names
+}
+
+// addWarmCacheSidecar adds a sidecar to the given dns daemonset that resolves
+// the given names through the pod's CoreDNS when the pod starts and reports
+// ready only after it has done so, which keeps the pod from receiving queries
+// through the dns service until its cache is warm.
+func addWarmCacheSidecar(daemonset *appsv1.DaemonSet, names []string, openshiftCLIImage string) {
+	spec := &daemonset.Spec.Template.Spec
+	spec.Containers = append(spec.Containers, corev1.Container{
+		Name:            "warm-cache",
+		Image:           openshiftCLIImage,
+		ImagePullPolicy: corev1.PullIfNotPresent,
+		Command: []string{
+			"/bin/bash", "-c",
+			dnsWarmCacheScript,
+		},
+		Env: []corev1.EnvVar{
+			{Name: "NAMES", Value: strings.Join(names, " ")},
+			{Name: "PORT", Value: strconv.Itoa(CoreDNSPort)},
+			{Name: "CLUSTER_DOMAIN", Value: "cluster.local"},
+			{Name: "READY_FILE", Value: warmCacheReadyFile},
+		},
+		ReadinessProbe: &corev1.Probe{
+			Handler: corev1.Handler{
+				Exec: &corev1.ExecAction{
+					Command: []string{"test", "-f", warmCacheReadyFile},
+				},
+			},
+			PeriodSeconds:    3,
+			SuccessThreshold: 1,
+			FailureThreshold: 3,
+			TimeoutSeconds:   3,
+		},
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("10m"),
+				corev1.ResourceMemory: resource.MustParse("10Mi"),
+			},
+		},
+		TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+	})
+}
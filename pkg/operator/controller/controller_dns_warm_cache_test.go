@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	corev1 "k8s.io/api/core/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestWarmCacheNames verifies that warmCacheNames normalizes the names in the
+// WarmCacheNamesAnnotation annotation and ignores invalid and duplicate names
+// and names beyond maxWarmCacheNames.
+func TestWarmCacheNames(t *testing.T) {
+	testCases := []struct {
+		annotation string
+		expected   []string
+	}{
+		{"", nil},
+		{"registry.example.com", []string{"registry.example.com."}},
+		{"Registry.Example.com., quay.io\nregistry.example.com bad_name .", []string{"registry.example.com.", "quay.io."}},
+	}
+	for _, tc := range testCases {
+		dns := &operatorv1.DNS{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        DefaultDNSController,
+				Annotations: map[string]string{WarmCacheNamesAnnotation: tc.annotation},
+			},
+		}
+		if actual := warmCacheNames(dns); !reflect.DeepEqual(actual, tc.expected) {
+			t.Errorf("%q: expected %v, got %v", tc.annotation, tc.expected, actual)
+		}
+	}
+
+	var many []string
+	for i := 0; i <= maxWarmCacheNames; i++ {
+		many = append(many, fmt.Sprintf("name%d.example.com", i))
+	}
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        DefaultDNSController,
+			Annotations: map[string]string{WarmCacheNamesAnnotation: strings.Join(many, ",")},
+		},
+	}
+	if actual := warmCacheNames(dns); len(actual) != maxWarmCacheNames {
+		t.Errorf("expected %d names, got %d", maxWarmCacheNames, len(actual))
+	}
+}
+
+// TestDesiredDNSDaemonSetWarmCache verifies that the dns daemonset has the
+// warm-cache sidecar only if the dns lists names to resolve, and that the
+// sidecar's readiness depends on the warm-up.
+func TestDesiredDNSDaemonSetWarmCache(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DefaultDNSController,
+		},
+	}
+	ds, err := desiredDNSDaemonSet(dns, "coredns", "kube-rbac-proxy", "openshift-cli", "dns-default-metrics-tls", "", nil, nil, nil, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range ds.Spec.Template.Spec.Containers {
+		if c.Name == "warm-cache" {
+			t.Errorf("expected no warm-cache container")
+		}
+	}
+
+	dns.Annotations = map[string]string{WarmCacheNamesAnnotation: "registry.example.com, quay.io"}
+	ds, err = desiredDNSDaemonSet(dns, "coredns", "kube-rbac-proxy", "openshift-cli", "dns-default-metrics-tls", "", nil, nil, nil, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sidecar *corev1.Container
+	for i, c := range ds.Spec.Template.Spec.Containers {
+		if c.Name == "warm-cache" {
+			sidecar = &ds.Spec.Template.Spec.Containers[i]
+		}
+	}
+	if sidecar == nil {
+		t.Fatalf("expected a warm-cache container, got %v", ds.Spec.Template.Spec.Containers)
+	}
+	if sidecar.Image != "openshift-cli" {
+		t.Errorf("expected image %q, got %q", "openshift-cli", sidecar.Image)
+	}
+	expectedEnv := corev1.EnvVar{Name: "NAMES", Value: "registry.example.com. quay.io."}
+	if len(sidecar.Env) == 0 || sidecar.Env[0] != expectedEnv {
+		t.Errorf("expected env %v, got %v", expectedEnv, sidecar.Env)
+	}
+	if probe := sidecar.ReadinessProbe; probe == nil || probe.Exec == nil || !reflect.DeepEqual(probe.Exec.Command, []string{"test", "-f", warmCacheReadyFile}) {
+		t.Errorf("expected a readiness probe that checks %s, got %v", warmCacheReadyFile, sidecar.ReadinessProbe)
+	}
+}
@@ -0,0 +1,454 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// DNSZoneFilesServedConditionType is the type of the DNS status
+	// condition that reports the zones that the dns serves from zone files
+	// and the zone files that it ignores.  The condition is reported only
+	// if the dns has the ZoneFilesAnnotation annotation.
+	DNSZoneFilesServedConditionType = conditions.TypeZoneFilesServed
+
+	// zoneFileKey is the key of the zone file in a zone file configmap.
+	zoneFileKey = "db"
+)
+
+var (
+	// zoneClasses are the classes that a resource record in a zone file
+	// may have.
+	zoneClasses = sets.NewString("IN", "CH", "HS", "CS", "ANY")
+	// zoneTTLUnits are the numbers of seconds in the units of a TTL in a
+	// zone file.
+	zoneTTLUnits = map[rune]uint64{'s': 1, 'm': 60, 'h': 3600, 'd': 86400, 'w': 604800}
+)
+
+// zoneFileReference is an entry of a dns's ZoneFilesAnnotation annotation.
+type zoneFileReference struct {
+	// Zone is the zone, lowercase and without a trailing dot.
+	Zone string
+	// ConfigMap is the name of the configmap that has the zone file.
+	ConfigMap string
+}
+
+// zoneFile is a zone that a dns serves authoritatively from a zone file.
+type zoneFile struct {
+	zoneFileReference
+	// Data is the zone file.
+	Data string
+	// Serial is the serial number in the zone file's SOA record.
+	Serial uint32
+}
+
+// Key returns the key of the zone file in the dns's configmap.
+func (z zoneFile) Key() string {
+	return "zone-" + z.Zone + ".db"
+}
+
+// Hash returns an abbreviated hash of the zone file.  The Corefile includes the
+// hash so that CoreDNS reloads its configuration, and thus the zone, when the
+// zone file changes, even if its serial number does not.
+func (z zoneFile) Hash() string {
+	sum := sha256.Sum256([]byte(z.Data))
+	return hex.EncodeToString(sum[:8])
+}
+
+// zoneFilesEnabled returns a Boolean value indicating whether the given dns has
+// a non-empty ZoneFilesAnnotation annotation.
+func zoneFilesEnabled(dns *operatorv1.DNS) bool {
+	return len(strings.TrimSpace(dns.Annotations[ZoneFilesAnnotation])) != 0
+}
+
+// zoneFileReferences returns the entries of the given dns's ZoneFilesAnnotation
+// annotation, in the annotation's order, and the problems with the invalid
+// entries, which are ignored.
+func zoneFileReferences(dns *operatorv1.DNS) ([]zoneFileReference, []string) {
+	var (
+		refs     []zoneFileReference
+		problems []string
+	)
+	list := dns.Annotations[ZoneFilesAnnotation]
+	for _, entry := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' }) {
+		i := strings.Index(entry, "=")
+		if i < 0 {
+			problems = append(problems, fmt.Sprintf("%q: expected \"<zone>=<configmap>\"", entry))
+			continue
+		}
+		zone, name := normalizeZone(entry[:i]), entry[i+1:]
+		switch {
+		case zone == ".":
+			problems = append(problems, fmt.Sprintf("%q: the root zone conflicts with the default server block", entry))
+		case !validDomainName(zone):
+			problems = append(problems, fmt.Sprintf("%q: %q is not a valid domain name", entry, entry[:i]))
+		case len(validation.IsDNS1123Subdomain(name)) != 0:
+			problems = append(problems, fmt.Sprintf("%q: %q is not a valid configmap name", entry, name))
+		default:
+			refs = append(refs, zoneFileReference{Zone: strings.TrimSuffix(zone, "."), ConfigMap: name})
+		}
+	}
+	return refs, problems
+}
+
+// dnsZoneFiles returns the valid zone files that the given dns's
+// ZoneFilesAnnotation annotation names and a status condition that reports
+// them, ordered by zone, or nil and a nil condition if the dns does not have
+// the annotation.  A zone must not be in, or contain, the cluster domain, and
+// it must not be a zone that the given servers or IdM DNS servers serve, or the
+// zone of another zone file.
+func (r *reconciler) dnsZoneFiles(dns *operatorv1.DNS, servers []operatorv1.Server, idmResolvers []idmResolver, clusterDomain string) ([]zoneFile, *operatorv1.OperatorCondition, error) {
+	if !zoneFilesEnabled(dns) {
+		return nil, nil, nil
+	}
+	refs, problems := zoneFileReferences(dns)
+	clusterDomain = normalizeZone(clusterDomain)
+	zones := otherServerZones(servers, idmResolvers)
+	var zoneFiles []zoneFile
+	for _, ref := range refs {
+		zone := normalizeZone(ref.Zone)
+		switch {
+		case nameInZone(zone, clusterDomain) || nameInZone(clusterDomain, zone):
+			problems = append(problems, fmt.Sprintf("zone %s: the zone overlaps the cluster domain", ref.Zone))
+			continue
+		case zones.Has(zone):
+			problems = append(problems, fmt.Sprintf("zone %s: another server serves the zone", ref.Zone))
+			continue
+		}
+		cm := &corev1.ConfigMap{}
+		name := types.NamespacedName{Namespace: DefaultOperandNamespace, Name: ref.ConfigMap}
+		if err := r.cache.Get(context.TODO(), name, cm); err != nil {
+			if errors.IsNotFound(err) {
+				problems = append(problems, fmt.Sprintf("zone %s: configmap %s/%s does not exist", ref.Zone, name.Namespace, name.Name))
+				continue
+			}
+			return nil, nil, fmt.Errorf("failed to get zone file configmap %s/%s: %w", name.Namespace, name.Name, err)
+		}
+		data, ok := cm.Data[zoneFileKey]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("zone %s: configmap %s/%s has no %q key", ref.Zone, name.Namespace, name.Name, zoneFileKey))
+			continue
+		}
+		serial, err := parseZoneFile(data, zone)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("zone %s: configmap %s/%s: %v", ref.Zone, name.Namespace, name.Name, err))
+			continue
+		}
+		zones.Insert(zone)
+		zoneFiles = append(zoneFiles, zoneFile{zoneFileReference: ref, Data: data, Serial: serial})
+	}
+	sort.Slice(zoneFiles, func(i, j int) bool {
+		return zoneFiles[i].Zone < zoneFiles[j].Zone
+	})
+	condition := computeZoneFilesServedCondition(zoneFiles, problems)
+	return zoneFiles, &condition, nil
+}
+
+// parseZoneFile validates the given zone file, which is in RFC 1035 master
+// file format, for the given zone, which is normalized with normalizeZone, and
+// returns the serial number in its SOA record.  Every record must be in the
+// zone, and the zone file must have exactly one SOA record, at the zone's apex.
+// Only the SOA record's data is validated; CoreDNS reports other malformed
+// records when it loads the zone.  The $INCLUDE directive is not supported
+// because the zone file's configmap has only the zone file.
+func parseZoneFile(data, zone string) (uint32, error) {
+	var (
+		origin  = zone
+		owner   string
+		serial  uint32
+		haveSOA bool
+	)
+	lines, err := zoneFileLines(data)
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range lines {
+		fields := line.Fields
+		if strings.HasPrefix(fields[0], "$") && !line.Blank {
+			switch strings.ToUpper(fields[0]) {
+			case "$ORIGIN":
+				if len(fields) != 2 {
+					return 0, fmt.Errorf("line %d: expected \"$ORIGIN <domain>\"", line.Number)
+				}
+				origin = zoneFileName(fields[1], origin)
+			case "$TTL":
+				if len(fields) != 2 {
+					return 0, fmt.Errorf("line %d: expected \"$TTL <ttl>\"", line.Number)
+				}
+				if _, err := parseZoneTTL(fields[1]); err != nil {
+					return 0, fmt.Errorf("line %d: %v", line.Number, err)
+				}
+			case "$INCLUDE":
+				return 0, fmt.Errorf("line %d: $INCLUDE is not supported", line.Number)
+			case "$GENERATE":
+			default:
+				return 0, fmt.Errorf("line %d: unknown directive %s", line.Number, fields[0])
+			}
+			continue
+		}
+		if !line.Blank {
+			owner, fields = zoneFileName(fields[0], origin), fields[1:]
+		} else if len(owner) == 0 {
+			return 0, fmt.Errorf("line %d: the first record has no owner", line.Number)
+		}
+		if !nameInZone(strings.ToLower(owner), zone) {
+			return 0, fmt.Errorf("line %d: %s is not in the zone", line.Number, owner)
+		}
+		// The owner may be followed by a TTL and a class, in either
+		// order, and then the type.
+		for len(fields) != 0 {
+			if _, err := parseZoneTTL(fields[0]); err == nil || zoneClasses.Has(strings.ToUpper(fields[0])) {
+				fields = fields[1:]
+				continue
+			}
+			break
+		}
+		if len(fields) < 2 {
+			return 0, fmt.Errorf("line %d: expected \"<owner> [<ttl>] [<class>] <type> <data>\"", line.Number)
+		}
+		if strings.ToUpper(fields[0]) != "SOA" {
+			continue
+		}
+		switch {
+		case haveSOA:
+			return 0, fmt.Errorf("line %d: the zone has more than one SOA record", line.Number)
+		case strings.ToLower(owner) != zone:
+			return 0, fmt.Errorf("line %d: the SOA record's owner %s is not the zone's apex", line.Number, owner)
+		}
+		if serial, err = parseSOA(fields[1:]); err != nil {
+			return 0, fmt.Errorf("line %d: %v", line.Number, err)
+		}
+		haveSOA = true
+	}
+	if !haveSOA {
+		return 0, fmt.Errorf("the zone has no SOA record")
+	}
+	return serial, nil
+}
+
+// parseSOA validates the given data of an SOA record and returns the serial
+// number.
+func parseSOA(data []string) (uint32, error) {
+	if len(data) != 7 {
+		return 0, fmt.Errorf("expected \"SOA <mname> <rname> <serial> <refresh> <retry> <expire> <minimum>\"")
+	}
+	serial, err := strconv.ParseUint(data[2], 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("the SOA record's serial %q is not a 32-bit unsigned integer", data[2])
+	}
+	for _, timer := range data[3:] {
+		if _, err := parseZoneTTL(timer); err != nil {
+			return 0, fmt.Errorf("the SOA record has an invalid timer: %v", err)
+		}
+	}
+	return uint32(serial), nil
+}
+
+// parseZoneTTL parses the given TTL, which is a number of seconds or, as BIND
+// allows, a sequence of numbers with the units s, m, h, d, or w, such as
+// "1h30m".
+func parseZoneTTL(ttl string) (uint32, error) {
+	if len(ttl) == 0 {
+		return 0, fmt.Errorf("%q is not a valid TTL", ttl)
+	}
+	var total, n uint64
+	haveDigits := false
+	for _, r := range strings.ToLower(ttl) {
+		unit, isUnit := zoneTTLUnits[r]
+		switch {
+		case r >= '0' && r <= '9':
+			n, haveDigits = n*10+uint64(r-'0'), true
+		case isUnit && haveDigits:
+			total, n, haveDigits = total+n*unit, 0, false
+		default:
+			return 0, fmt.Errorf("%q is not a valid TTL", ttl)
+		}
+		if total+n > math.MaxUint32 {
+			return 0, fmt.Errorf("%q is not a valid TTL", ttl)
+		}
+	}
+	return uint32(total + n), nil
+}
+
+// zoneFileName returns the fully qualified form of the given name from a zone
+// file, relative to the given origin.
+func zoneFileName(name, origin string) string {
+	switch {
+	case name == "@":
+		return origin
+	case strings.HasSuffix(name, "."):
+		return name
+	case origin == ".":
+		return name + "."
+	default:
+		return name + "." + origin
+	}
+}
+
+// zoneFileLine is a logical line of a zone file.  Blank indicates whether the
+// line starts with whitespace, in which case it has no owner and its record
+// has the previous record's owner.
+type zoneFileLine struct {
+	Number int
+	Blank  bool
+	Fields []string
+}
+
+// zoneFileLines splits the given zone file into logical lines, omitting
+// comments and empty lines.  Parentheses continue a line across line breaks,
+// and a quoted string may contain whitespace.
+func zoneFileLines(data string) ([]zoneFileLine, error) {
+	var (
+		lines   []zoneFileLine
+		line    zoneFileLine
+		field   strings.Builder
+		inField bool
+		quoted  bool
+		escaped bool
+		comment bool
+		parens  int
+		number  = 1
+		start   = true
+	)
+	flush := func() {
+		if inField {
+			line.Fields = append(line.Fields, field.String())
+			field.Reset()
+			inField = false
+		}
+	}
+	for _, r := range data {
+		switch {
+		case r == '\n' && !quoted:
+			flush()
+			comment = false
+			if parens == 0 {
+				if len(line.Fields) != 0 {
+					lines = append(lines, line)
+				}
+				line, start = zoneFileLine{}, true
+			}
+			number++
+			continue
+		case comment:
+			continue
+		case escaped:
+			field.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			field.WriteRune(r)
+			inField, escaped = true, true
+		case quoted:
+			field.WriteRune(r)
+			if r == '"' {
+				quoted = false
+			}
+		case r == '"':
+			field.WriteRune(r)
+			inField, quoted = true, true
+		case r == ';':
+			flush()
+			comment = true
+		case r == '(':
+			flush()
+			parens++
+		case r == ')':
+			flush()
+			if parens == 0 {
+				return nil, fmt.Errorf("line %d: unbalanced parentheses", number)
+			}
+			parens--
+		case unicode.IsSpace(r):
+			if start {
+				line.Blank = true
+			}
+			flush()
+		default:
+			field.WriteRune(r)
+			inField = true
+		}
+		if start {
+			line.Number, start = number, false
+		}
+	}
+	switch {
+	case quoted:
+		return nil, fmt.Errorf("line %d: unterminated quote", number)
+	case parens != 0:
+		return nil, fmt.Errorf("line %d: unbalanced parentheses", number)
+	}
+	flush()
+	if len(line.Fields) != 0 {
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+// dnsesForZoneFileConfigMap returns reconcile requests for the dnses whose
+// ZoneFilesAnnotation annotation names the given configmap.
+func (r *reconciler) dnsesForZoneFileConfigMap(o client.Object) []reconcile.Request {
+	dnses := &operatorv1.DNSList{}
+	if err := r.cache.List(context.TODO(), dnses); err != nil {
+		logrus.Errorf("failed to list dnses for configmap %s/%s: %v", o.GetNamespace(), o.GetName(), err)
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range dnses.Items {
+		refs, _ := zoneFileReferences(&dnses.Items[i])
+		for _, ref := range refs {
+			if ref.ConfigMap == o.GetName() {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: dnses.Items[i].Name}})
+				break
+			}
+		}
+	}
+	return requests
+}
+
+// computeZoneFilesServedCondition returns a status condition that reports the
+// given zone files and the problems with the zone files that were ignored.
+func computeZoneFilesServedCondition(zoneFiles []zoneFile, problems []string) operatorv1.OperatorCondition {
+	condition := operatorv1.OperatorCondition{
+		Type: DNSZoneFilesServedConditionType,
+	}
+	var served []string
+	for _, zone := range zoneFiles {
+		served = append(served, fmt.Sprintf("%s (serial %d)", zone.Zone, zone.Serial))
+	}
+	serving := "Serving no zones from zone files."
+	if len(served) != 0 {
+		serving = fmt.Sprintf("Serving zones from zone files: %s.", strings.Join(served, ", "))
+	}
+	if len(problems) != 0 {
+		condition.Status = operatorv1.ConditionFalse
+		condition.Reason = conditions.ReasonZoneFilesIgnored
+		condition.Message = fmt.Sprintf("Some zone files were ignored: %s.  %s", strings.Join(problems, "; "), serving)
+		return condition
+	}
+	condition.Status = operatorv1.ConditionTrue
+	condition.Reason = conditions.ReasonAsExpected
+	condition.Message = serving
+	return condition
+}
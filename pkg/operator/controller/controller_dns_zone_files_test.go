@@ -0,0 +1,188 @@
+package controller
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestZoneFileReferences verifies that zoneFileReferences parses the
+// ZoneFilesAnnotation annotation and reports invalid entries.
+func TestZoneFileReferences(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DefaultDNSController,
+			Annotations: map[string]string{
+				ZoneFilesAnnotation: "Lab.Example.com.=lab-zone, .=root-zone\nexample.org, bad_zone=x example.net=Not_A_Name",
+			},
+		},
+	}
+	refs, problems := zoneFileReferences(dns)
+	expected := []zoneFileReference{{Zone: "lab.example.com", ConfigMap: "lab-zone"}}
+	if !reflect.DeepEqual(refs, expected) {
+		t.Errorf("expected %v, got %v", expected, refs)
+	}
+	expectedProblems := []string{
+		`".=root-zone": the root zone conflicts with the default server block`,
+		`"example.org": expected "<zone>=<configmap>"`,
+		`"bad_zone=x": "bad_zone" is not a valid domain name`,
+		`"example.net=Not_A_Name": "Not_A_Name" is not a valid configmap name`,
+	}
+	if !reflect.DeepEqual(problems, expectedProblems) {
+		t.Errorf("expected problems:\n%s\ngot:\n%s", strings.Join(expectedProblems, "\n"), strings.Join(problems, "\n"))
+	}
+}
+
+// TestParseZoneFile verifies that parseZoneFile returns the serial number of a
+// valid zone file and rejects zone files without exactly one SOA record at the
+// zone's apex or with records outside the zone.
+func TestParseZoneFile(t *testing.T) {
+	testCases := []struct {
+		name           string
+		data           string
+		expectedSerial uint32
+		expectedError  string
+	}{
+		{
+			name: "valid",
+			data: `$ORIGIN lab.example.com.
+$TTL 1h
+@	IN	SOA	ns1 hostmaster (
+		2021100401 ; serial
+		1h         ; refresh
+		15m        ; retry
+		1w         ; expire
+		300 )      ; minimum
+	IN	NS	ns1
+ns1	300	IN	A	192.0.2.1
+www		A	192.0.2.2
+		AAAA	2001:db8::2
+txt		TXT	"v=spf1 ; not a comment"
+$ORIGIN sub.lab.example.com.
+host		CNAME	www.lab.example.com.
+`,
+			expectedSerial: 2021100401,
+		},
+		{
+			name:          "no SOA",
+			data:          "www.lab.example.com. 300 IN A 192.0.2.1\n",
+			expectedError: "the zone has no SOA record",
+		},
+		{
+			name:          "two SOA records",
+			data:          "@ SOA ns1 hostmaster 1 1h 15m 1w 300\n@ SOA ns1 hostmaster 2 1h 15m 1w 300\n",
+			expectedError: "line 2: the zone has more than one SOA record",
+		},
+		{
+			name:          "SOA below the apex",
+			data:          "www SOA ns1 hostmaster 1 1h 15m 1w 300\n",
+			expectedError: "line 1: the SOA record's owner www.lab.example.com. is not the zone's apex",
+		},
+		{
+			name:          "invalid serial",
+			data:          "@ SOA ns1 hostmaster 4294967296 1h 15m 1w 300\n",
+			expectedError: `line 1: the SOA record's serial "4294967296" is not a 32-bit unsigned integer`,
+		},
+		{
+			name:          "invalid timer",
+			data:          "@ SOA ns1 hostmaster 1 1x 15m 1w 300\n",
+			expectedError: `line 1: the SOA record has an invalid timer: "1x" is not a valid TTL`,
+		},
+		{
+			name:          "short SOA",
+			data:          "@ SOA ns1 hostmaster 1\n",
+			expectedError: `line 1: expected "SOA <mname> <rname> <serial> <refresh> <retry> <expire> <minimum>"`,
+		},
+		{
+			name:          "record outside the zone",
+			data:          "@ SOA ns1 hostmaster 1 1h 15m 1w 300\nwww.example.org. A 192.0.2.1\n",
+			expectedError: "line 2: www.example.org. is not in the zone",
+		},
+		{
+			name:          "include",
+			data:          "$INCLUDE other.db\n",
+			expectedError: "line 1: $INCLUDE is not supported",
+		},
+		{
+			name:          "unbalanced parentheses",
+			data:          "@ SOA ns1 hostmaster (1 1h 15m 1w 300\n",
+			expectedError: "line 2: unbalanced parentheses",
+		},
+		{
+			name:          "first record without owner",
+			data:          "  A 192.0.2.1\n",
+			expectedError: "line 1: the first record has no owner",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			serial, err := parseZoneFile(tc.data, "lab.example.com.")
+			switch {
+			case len(tc.expectedError) != 0 && (err == nil || err.Error() != tc.expectedError):
+				t.Errorf("expected error %q, got %v", tc.expectedError, err)
+			case len(tc.expectedError) == 0 && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case serial != tc.expectedSerial:
+				t.Errorf("expected serial %d, got %d", tc.expectedSerial, serial)
+			}
+		})
+	}
+}
+
+// TestDesiredDNSConfigMapZoneFiles verifies that the Corefile has a server
+// block that serves each zone from its zone file and that the dns's configmap
+// has the zone files.
+func TestDesiredDNSConfigMapZoneFiles(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DefaultDNSController,
+		},
+	}
+	zone := zoneFile{
+		zoneFileReference: zoneFileReference{Zone: "lab.example.com", ConfigMap: "lab-zone"},
+		Data:              "@ SOA ns1 hostmaster 7 1h 15m 1w 300\n",
+		Serial:            7,
+	}
+	cm, err := desiredDNSConfigMap(dns, corefileInputs{ZoneFiles: []zoneFile{zone}, ClusterDomain: "cluster.local", MetricsAddress: "127.0.0.1:9153"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `# zone lab.example.com (configmap lab-zone, serial 7, hash ` + zone.Hash() + `)
+lab.example.com:5353 {
+    file /etc/coredns/zone-lab.example.com.db lab.example.com
+    errors
+    bufsize 1232
+    prometheus 127.0.0.1:9153
+}
+.:5353 {`
+	if !strings.HasPrefix(cm.Data["Corefile"], expected) {
+		t.Errorf("expected Corefile to start with:\n%s\ngot:\n%s", expected, cm.Data["Corefile"])
+	}
+	if actual := cm.Data["zone-lab.example.com.db"]; actual != zone.Data {
+		t.Errorf("expected the configmap to have the zone file %q, got %q", zone.Data, actual)
+	}
+}
+
+// TestDesiredDNSDaemonSetZoneFiles verifies that the dns pods mount every key
+// of the dns's configmap if the dns has zone files.
+func TestDesiredDNSDaemonSetZoneFiles(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        DefaultDNSController,
+			Annotations: map[string]string{ZoneFilesAnnotation: "lab.example.com=lab-zone"},
+		},
+	}
+	ds, err := desiredDNSDaemonSet(dns, "coredns", "kube-rbac-proxy", "", "dns-default-metrics-tls", "", nil, nil, nil, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range ds.Spec.Template.Spec.Volumes {
+		if v.Name == "config-volume" && len(v.ConfigMap.Items) != 0 {
+			t.Errorf("expected config-volume to mount every key, got items %v", v.ConfigMap.Items)
+		}
+	}
+}
@@ -0,0 +1,321 @@
+package controller
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	// DNSZoneTransfersAllowedConditionType is the type of the DNS status
+	// condition that reports the zones that external secondaries may
+	// transfer and the entries of the dns's ZoneTransfersAnnotation
+	// annotation that are ignored.  The condition is reported only if the
+	// dns has the annotation.
+	DNSZoneTransfersAllowedConditionType = conditions.TypeZoneTransfersAllowed
+
+	// zoneTransferTSIGKey is the key of the TSIG keys in a zone transfer
+	// TSIG secret.
+	zoneTransferTSIGKey = "tsig.key"
+	// zoneTransferTSIGDir is the directory in which the dns pods mount the
+	// zone transfer TSIG secrets, each in a subdirectory named after the
+	// secret.
+	zoneTransferTSIGDir = "/etc/coredns-zone-transfer-tsig"
+	// zoneTransferTSIGHashAnnotation is the annotation on the dns
+	// daemonset's pod template with a hash of the zone transfer TSIG
+	// secrets, so that the pods restart and load the keys when they are
+	// rotated; CoreDNS reads the keys only when it loads its
+	// configuration.
+	zoneTransferTSIGHashAnnotation = "dns.operator.openshift.io/zone-transfer-tsig-hash"
+)
+
+var (
+	// tsigKeyPattern matches a key statement in a BIND key file and
+	// captures the key's name and body.
+	tsigKeyPattern = regexp.MustCompile(`(?s)key\s+"?([^"\s{]+)"?\s*\{(.*?)\}\s*;`)
+	// tsigSecretPattern matches the secret in the body of a key statement
+	// and captures the base64-encoded secret.
+	tsigSecretPattern = regexp.MustCompile(`secret\s+"([^"]*)"\s*;`)
+)
+
+// zoneTransferSpec is an entry in the value of a dns's ZoneTransfersAnnotation
+// annotation.
+type zoneTransferSpec struct {
+	// Zone is the zone that may be transferred.
+	Zone string `json:"zone"`
+	// To are the addresses, "<ip>" or "<ip>:<port>", of the secondaries
+	// that may transfer the zone.
+	To []string `json:"to"`
+	// TSIGSecret is the name of the secret with the TSIG keys that
+	// transfers must be signed with, a SecretProviderReferencePrefix
+	// reference to the SecretProviderClass that provides them, or empty.
+	TSIGSecret string `json:"tsigSecret,omitempty"`
+}
+
+// zoneTransfer is a validated zoneTransferSpec.
+type zoneTransfer struct {
+	// Zone is the zone, lowercase and without a trailing dot.
+	Zone string
+	// To are the addresses of the secondaries, each with a port.
+	To []string
+	// TSIGSecret is the name of the secret with the TSIG keys, a
+	// SecretProviderReferencePrefix reference, or empty.
+	TSIGSecret string
+	// TSIGHash is a hash of the secret's TSIG keys or of the
+	// SecretProviderClass's generation, or empty.
+	TSIGHash string
+}
+
+// TSIGKeyFile returns the path of the TSIG keys in the dns pods.
+func (z zoneTransfer) TSIGKeyFile() string {
+	return path.Join(credentialDir(zoneTransferTSIGDir, z.TSIGSecret), zoneTransferTSIGKey)
+}
+
+// zoneTransfers are the zone transfers of a dns.
+type zoneTransfers []zoneTransfer
+
+// For returns the transfer of the given zone, or nil if the zone may not be
+// transferred.
+func (z zoneTransfers) For(zone string) *zoneTransfer {
+	for i := range z {
+		if z[i].Zone == zone {
+			return &z[i]
+		}
+	}
+	return nil
+}
+
+// zoneTransfersEnabled returns a Boolean value indicating whether the given
+// dns has a non-empty ZoneTransfersAnnotation annotation.
+func zoneTransfersEnabled(dns *operatorv1.DNS) bool {
+	return len(strings.TrimSpace(dns.Annotations[ZoneTransfersAnnotation])) != 0
+}
+
+// dnsZoneTransfers returns the valid zone transfers in the given dns's
+// ZoneTransfersAnnotation annotation, ordered by zone, and a status condition
+// that reports them and the entries that are ignored, or nil and a nil
+// condition if the dns does not have the annotation.  A zone must be one of the
+// given zone files, and a transfer's TSIG secret must exist and have valid
+// keys.
+func (r *reconciler) dnsZoneTransfers(dns *operatorv1.DNS, zoneFiles []zoneFile) ([]zoneTransfer, *operatorv1.OperatorCondition, error) {
+	if !zoneTransfersEnabled(dns) {
+		return nil, nil, nil
+	}
+	zones := sets.NewString()
+	for _, zone := range zoneFiles {
+		zones.Insert(zone.Zone)
+	}
+	specs, problems := parseZoneTransfers(dns.Annotations[ZoneTransfersAnnotation], zones, len(customCoreDNSImage(dns)) != 0)
+	var transfers []zoneTransfer
+	for _, transfer := range specs {
+		if len(transfer.TSIGSecret) != 0 {
+			hash, problem, err := r.zoneTransferTSIGHash(transfer.TSIGSecret)
+			if err != nil {
+				return nil, nil, err
+			}
+			if len(problem) != 0 {
+				problems = append(problems, fmt.Sprintf("zone %s: %s", transfer.Zone, problem))
+				continue
+			}
+			transfer.TSIGHash = hash
+		}
+		transfers = append(transfers, transfer)
+	}
+	condition := computeZoneTransfersAllowedCondition(transfers, problems)
+	return transfers, &condition, nil
+}
+
+// parseZoneTransfers parses the given value of a ZoneTransfersAnnotation
+// annotation and returns the valid transfers, ordered by zone, without their
+// TSIG hashes, and the problems with the invalid entries, which are ignored.
+// An entry is invalid if its zone is not one of the given zones, which are
+// lowercase and without a trailing dot, or is another entry's zone, if it has
+// no valid secondaries, or if its TSIG secret is not a valid name or cannot be
+// used because the given Boolean value indicates that the dns does not use a
+// custom coredns image.
+func parseZoneTransfers(value string, zones sets.String, customImage bool) ([]zoneTransfer, []string) {
+	var specs []zoneTransferSpec
+	if err := json.Unmarshal([]byte(value), &specs); err != nil {
+		return nil, []string{fmt.Sprintf("the annotation is not a JSON list of zone transfers: %v", err)}
+	}
+	var (
+		transfers []zoneTransfer
+		problems  []string
+		seen      = sets.NewString()
+	)
+	for i, spec := range specs {
+		zone := strings.TrimSuffix(normalizeZone(spec.Zone), ".")
+		switch {
+		case len(spec.Zone) == 0:
+			problems = append(problems, fmt.Sprintf("entry %d: zone must be specified", i))
+			continue
+		case !zones.Has(zone):
+			problems = append(problems, fmt.Sprintf("zone %s: the zone is not served from a zone file", spec.Zone))
+			continue
+		case seen.Has(zone):
+			problems = append(problems, fmt.Sprintf("zone %s: another entry configures the zone", spec.Zone))
+			continue
+		case len(spec.To) == 0:
+			problems = append(problems, fmt.Sprintf("zone %s: to must be specified", spec.Zone))
+			continue
+		case len(spec.TSIGSecret) != 0 && !validCredentialReference(spec.TSIGSecret):
+			problems = append(problems, fmt.Sprintf("zone %s: tsigSecret %q is not a valid secret name or %q reference", spec.Zone, spec.TSIGSecret, SecretProviderReferencePrefix+"<name>"))
+			continue
+		case len(spec.TSIGSecret) != 0 && !customImage:
+			problems = append(problems, fmt.Sprintf("zone %s: TSIG requires the tsig plugin, which the release's coredns image does not have; set annotation %s to an image that has it", spec.Zone, CustomCoreDNSImageAnnotation))
+			continue
+		}
+		to, err := dnsServerAddresses(spec.To, "secondary")
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("zone %s: %v", spec.Zone, err))
+			continue
+		}
+		seen.Insert(zone)
+		transfers = append(transfers, zoneTransfer{Zone: zone, To: to, TSIGSecret: spec.TSIGSecret})
+	}
+	sort.Slice(transfers, func(i, j int) bool {
+		return transfers[i].Zone < transfers[j].Zone
+	})
+	return transfers, problems
+}
+
+// zoneTransferTSIGHash returns a hash of the TSIG keys in the zone transfer
+// TSIG secret with the given name, or the problem with the secret if it is
+// missing or invalid.  For a SecretProviderReferencePrefix reference, it
+// returns the SecretProviderClass's hash, because the keys are not readable.
+func (r *reconciler) zoneTransferTSIGHash(secretName string) (string, string, error) {
+	if spc, ok := secretProviderClassName(secretName); ok {
+		return r.secretProviderClassHash(spc)
+	}
+	secret := &corev1.Secret{}
+	name := types.NamespacedName{Namespace: DefaultOperandNamespace, Name: secretName}
+	if err := r.client.Get(context.TODO(), name, secret); err != nil {
+		if !errors.IsNotFound(err) {
+			return "", "", fmt.Errorf("failed to get zone transfer TSIG secret %s: %w", name, err)
+		}
+		return "", fmt.Sprintf("TSIG secret %s does not exist", name), nil
+	}
+	keys, ok := secret.Data[zoneTransferTSIGKey]
+	if !ok {
+		return "", fmt.Sprintf("TSIG secret %s has no %q key", name, zoneTransferTSIGKey), nil
+	}
+	if err := validateTSIGKeys(string(keys)); err != nil {
+		return "", fmt.Sprintf("TSIG secret %s is invalid: %v", name, err), nil
+	}
+	hash, err := computeHash(keys)
+	if err != nil {
+		return "", "", err
+	}
+	return hash, "", nil
+}
+
+// validateTSIGKeys validates the given TSIG keys, which are key statements in
+// the format of a BIND key file.  There must be at least one key, and each key
+// must have a base64-encoded secret.
+func validateTSIGKeys(keys string) error {
+	matches := tsigKeyPattern.FindAllStringSubmatch(keys, -1)
+	if len(matches) == 0 {
+		return fmt.Errorf("no key statements")
+	}
+	for _, match := range matches {
+		secret := tsigSecretPattern.FindStringSubmatch(match[2])
+		if secret == nil {
+			return fmt.Errorf("key %s has no secret", match[1])
+		}
+		if _, err := base64.StdEncoding.DecodeString(secret[1]); err != nil || len(secret[1]) == 0 {
+			return fmt.Errorf("key %s has a secret that is not base64-encoded", match[1])
+		}
+	}
+	return nil
+}
+
+// computeZoneTransfersAllowedCondition returns a status condition that reports
+// the given zone transfers and the given problems with the ignored entries.
+func computeZoneTransfersAllowedCondition(transfers []zoneTransfer, problems []string) operatorv1.OperatorCondition {
+	condition := operatorv1.OperatorCondition{
+		Type: DNSZoneTransfersAllowedConditionType,
+	}
+	var allowed []string
+	for _, transfer := range transfers {
+		auth := ""
+		if len(transfer.TSIGSecret) != 0 {
+			auth = " with TSIG"
+		}
+		allowed = append(allowed, fmt.Sprintf("%s to %s%s", transfer.Zone, strings.Join(transfer.To, " "), auth))
+	}
+	allowing := "Allowing no zone transfers."
+	if len(allowed) != 0 {
+		allowing = fmt.Sprintf("Allowing zone transfers: %s.", strings.Join(allowed, ", "))
+	}
+	if len(problems) != 0 {
+		condition.Status = operatorv1.ConditionFalse
+		condition.Reason = conditions.ReasonZoneTransfersIgnored
+		condition.Message = fmt.Sprintf("Some zone transfers were ignored: %s.  %s", strings.Join(problems, "; "), allowing)
+		return condition
+	}
+	condition.Status = operatorv1.ConditionTrue
+	condition.Reason = conditions.ReasonAsExpected
+	condition.Message = allowing
+	return condition
+}
+
+// addZoneTransferTSIGSecrets mounts the TSIG secrets of the given zone
+// transfers in the given daemonset's dns container and annotates its pod
+// template with their hashes.
+func addZoneTransferTSIGSecrets(daemonset *appsv1.DaemonSet, transfers []zoneTransfer) {
+	hashes := map[string]string{}
+	for _, transfer := range transfers {
+		if len(transfer.TSIGSecret) != 0 {
+			hashes[transfer.TSIGSecret] = transfer.TSIGHash
+		}
+	}
+	if len(hashes) == 0 {
+		return
+	}
+	var secretNames []string
+	for name := range hashes {
+		secretNames = append(secretNames, name)
+	}
+	sort.Strings(secretNames)
+
+	spec := &daemonset.Spec.Template.Spec
+	var combined []string
+	for i, secretName := range secretNames {
+		volumeName := "zone-transfer-tsig-" + strconv.Itoa(i)
+		spec.Volumes = append(spec.Volumes, corev1.Volume{
+			Name:         volumeName,
+			VolumeSource: credentialVolumeSource(secretName),
+		})
+		for j := range spec.Containers {
+			if spec.Containers[j].Name != "dns" {
+				continue
+			}
+			spec.Containers[j].VolumeMounts = append(spec.Containers[j].VolumeMounts, corev1.VolumeMount{
+				Name:      volumeName,
+				MountPath: credentialDir(zoneTransferTSIGDir, secretName),
+				ReadOnly:  true,
+			})
+		}
+		combined = append(combined, secretName+"="+hashes[secretName])
+	}
+	if daemonset.Spec.Template.Annotations == nil {
+		daemonset.Spec.Template.Annotations = map[string]string{}
+	}
+	daemonset.Spec.Template.Annotations[zoneTransferTSIGHashAnnotation] = strings.Join(combined, ",")
+}
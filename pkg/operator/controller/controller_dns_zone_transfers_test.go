@@ -0,0 +1,248 @@
+package controller
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
+
+	corev1 "k8s.io/api/core/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// testTSIGKeys are TSIG keys in the format that tsig-keygen writes.
+const testTSIGKeys = `key "lab-transfer" {
+	algorithm hmac-sha256;
+	secret "8Ky9ZR9Bkq0lBcnQ+rsvN6mU5o2p3ZtLdkd2Iu6Zf0U=";
+};
+`
+
+// TestParseZoneTransfers verifies that parseZoneTransfers accepts valid zone
+// transfers and reports invalid ones.
+func TestParseZoneTransfers(t *testing.T) {
+	value := `[
+		{"zone": "Lab.Example.com.", "to": ["192.0.2.10", "[2001:db8::10]:5353"], "tsigSecret": "lab-transfer-key"},
+		{"zone": "corp.example.com", "to": ["198.51.100.10"]},
+		{"zone": "", "to": ["192.0.2.10"]},
+		{"zone": "other.example.com", "to": ["192.0.2.10"]},
+		{"zone": "lab.example.com", "to": ["192.0.2.11"]},
+		{"zone": "a.example.com", "to": []},
+		{"zone": "b.example.com", "to": ["*"]},
+		{"zone": "c.example.com", "to": ["192.0.2.0/24"]},
+		{"zone": "d.example.com", "to": ["192.0.2.10"], "tsigSecret": "Not_A_Secret"},
+		{"zone": "e.example.com", "to": ["192.0.2.10"], "tsigSecret": "csi:lab-transfer-store"},
+		{"zone": "f.example.com", "to": ["192.0.2.10"], "tsigSecret": "csi:"}
+	]`
+	zones := sets.NewString("lab.example.com", "corp.example.com", "a.example.com", "b.example.com", "c.example.com", "d.example.com", "e.example.com", "f.example.com")
+	transfers, problems := parseZoneTransfers(value, zones, true)
+	expected := []zoneTransfer{
+		{Zone: "corp.example.com", To: []string{"198.51.100.10:53"}},
+		{Zone: "e.example.com", To: []string{"192.0.2.10:53"}, TSIGSecret: "csi:lab-transfer-store"},
+		{Zone: "lab.example.com", To: []string{"192.0.2.10:53", "[2001:db8::10]:5353"}, TSIGSecret: "lab-transfer-key"},
+	}
+	if !reflect.DeepEqual(transfers, expected) {
+		t.Errorf("expected %v, got %v", expected, transfers)
+	}
+	expectedProblems := []string{
+		"entry 2: zone must be specified",
+		"zone other.example.com: the zone is not served from a zone file",
+		"zone lab.example.com: another entry configures the zone",
+		"zone a.example.com: to must be specified",
+		`zone b.example.com: secondary "*" is not an IP address`,
+		`zone c.example.com: secondary "192.0.2.0/24" is not an IP address`,
+		`zone d.example.com: tsigSecret "Not_A_Secret" is not a valid secret name or "csi:<name>" reference`,
+		`zone f.example.com: tsigSecret "csi:" is not a valid secret name or "csi:<name>" reference`,
+	}
+	if !reflect.DeepEqual(problems, expectedProblems) {
+		t.Errorf("expected problems:\n%s\ngot:\n%s", strings.Join(expectedProblems, "\n"), strings.Join(problems, "\n"))
+	}
+
+	transfers, problems = parseZoneTransfers(value, zones, false)
+	expected = []zoneTransfer{
+		{Zone: "corp.example.com", To: []string{"198.51.100.10:53"}},
+		{Zone: "lab.example.com", To: []string{"192.0.2.11:53"}},
+	}
+	if !reflect.DeepEqual(transfers, expected) {
+		t.Errorf("expected only the transfers without TSIG without a custom coredns image, %v, got %v", expected, transfers)
+	}
+	if len(problems) == 0 || !strings.Contains(problems[0], "requires the tsig plugin") {
+		t.Errorf("expected a problem for the transfer with TSIG, got %v", problems)
+	}
+
+	if _, problems := parseZoneTransfers("lab.example.com", zones, true); len(problems) != 1 {
+		t.Errorf("expected a problem for a value that is not JSON, got %v", problems)
+	}
+}
+
+// TestValidateTSIGKeys verifies that validateTSIGKeys accepts the keys that
+// tsig-keygen writes and rejects keys without base64-encoded secrets.
+func TestValidateTSIGKeys(t *testing.T) {
+	testCases := []struct {
+		name  string
+		keys  string
+		valid bool
+	}{
+		{"tsig-keygen output", testTSIGKeys, true},
+		{"unquoted name", `key lab { algorithm hmac-sha512; secret "c2VjcmV0"; };`, true},
+		{"empty", "", false},
+		{"no secret", `key "lab" { algorithm hmac-sha256; };`, false},
+		{"empty secret", `key "lab" { secret ""; };`, false},
+		{"invalid secret", `key "lab" { secret "not base64!"; };`, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := validateTSIGKeys(tc.keys); (err == nil) != tc.valid {
+				t.Errorf("expected valid to be %t, got error %v", tc.valid, err)
+			}
+		})
+	}
+}
+
+// TestDNSZoneTransfers verifies that dnsZoneTransfers ignores transfers whose
+// TSIG secrets are missing or invalid and reports them in the condition.
+func TestDNSZoneTransfers(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DefaultDNSController,
+			Annotations: map[string]string{
+				CustomCoreDNSImageAnnotation: "quay.io/example/coredns:tsig",
+				ZoneTransfersAnnotation: `[
+					{"zone": "a.example.com", "to": ["192.0.2.10"], "tsigSecret": "valid"},
+					{"zone": "b.example.com", "to": ["192.0.2.10"], "tsigSecret": "missing"},
+					{"zone": "c.example.com", "to": ["192.0.2.10"], "tsigSecret": "no-key"},
+					{"zone": "d.example.com", "to": ["192.0.2.10"], "tsigSecret": "invalid"}
+				]`,
+			},
+		},
+	}
+	var zoneFiles []zoneFile
+	for _, zone := range []string{"a.example.com", "b.example.com", "c.example.com", "d.example.com"} {
+		zoneFiles = append(zoneFiles, zoneFile{zoneFileReference: zoneFileReference{Zone: zone}})
+	}
+	r := &reconciler{client: &fakeSecretClient{secrets: map[string]*corev1.Secret{
+		"valid":   {Data: map[string][]byte{zoneTransferTSIGKey: []byte(testTSIGKeys)}},
+		"no-key":  {Data: map[string][]byte{"key": []byte(testTSIGKeys)}},
+		"invalid": {Data: map[string][]byte{zoneTransferTSIGKey: []byte("secret")}},
+	}}}
+	transfers, condition, err := r.dnsZoneTransfers(dns, zoneFiles)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(transfers) != 1 || transfers[0].Zone != "a.example.com" || len(transfers[0].TSIGHash) == 0 {
+		t.Errorf("expected only the transfer of a.example.com with a TSIG hash, got %+v", transfers)
+	}
+	if condition == nil || condition.Status != operatorv1.ConditionFalse || condition.Reason != conditions.ReasonZoneTransfersIgnored {
+		t.Fatalf("expected a false condition with reason %s, got %+v", conditions.ReasonZoneTransfersIgnored, condition)
+	}
+	for _, problem := range []string{
+		"zone b.example.com: TSIG secret openshift-dns/missing does not exist",
+		`zone c.example.com: TSIG secret openshift-dns/no-key has no "tsig.key" key`,
+		"zone d.example.com: TSIG secret openshift-dns/invalid is invalid: no key statements",
+		"Allowing zone transfers: a.example.com to 192.0.2.10:53 with TSIG.",
+	} {
+		if !strings.Contains(condition.Message, problem) {
+			t.Errorf("expected the condition's message to contain %q, got %q", problem, condition.Message)
+		}
+	}
+
+	delete(dns.Annotations, ZoneTransfersAnnotation)
+	if transfers, condition, err := r.dnsZoneTransfers(dns, zoneFiles); err != nil || transfers != nil || condition != nil {
+		t.Errorf("expected no transfers and no condition without the annotation, got %v, %v, %v", transfers, condition, err)
+	}
+}
+
+// TestDesiredDNSConfigMapZoneTransfers verifies that the server block of a zone
+// file allows the zone's transfers and requires TSIG for them if the transfer
+// has a TSIG secret.
+func TestDesiredDNSConfigMapZoneTransfers(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DefaultDNSController,
+		},
+	}
+	zone := zoneFile{
+		zoneFileReference: zoneFileReference{Zone: "lab.example.com", ConfigMap: "lab-zone"},
+		Data:              "@ SOA ns1 hostmaster 7 1h 15m 1w 300\n",
+		Serial:            7,
+	}
+	other := zoneFile{
+		zoneFileReference: zoneFileReference{Zone: "other.example.com", ConfigMap: "other-zone"},
+		Data:              "@ SOA ns1 hostmaster 1 1h 15m 1w 300\n",
+		Serial:            1,
+	}
+	transfers := []zoneTransfer{{Zone: "lab.example.com", To: []string{"192.0.2.10:53", "[2001:db8::10]:5353"}, TSIGSecret: "lab-transfer-key"}}
+	cm, err := desiredDNSConfigMap(dns, corefileInputs{ZoneFiles: []zoneFile{zone, other}, ZoneTransfers: transfers, ClusterDomain: "cluster.local", MetricsAddress: "127.0.0.1:9153"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `# zone lab.example.com (configmap lab-zone, serial 7, hash ` + zone.Hash() + `)
+lab.example.com:5353 {
+    file /etc/coredns/zone-lab.example.com.db lab.example.com
+    tsig lab.example.com {
+        secrets /etc/coredns-zone-transfer-tsig/lab-transfer-key/tsig.key
+        require AXFR IXFR
+    }
+    transfer lab.example.com {
+        to 192.0.2.10:53 [2001:db8::10]:5353
+    }
+    errors
+    bufsize 1232
+    prometheus 127.0.0.1:9153
+}
+# zone other.example.com (configmap other-zone, serial 1, hash ` + other.Hash() + `)
+other.example.com:5353 {
+    file /etc/coredns/zone-other.example.com.db other.example.com
+    errors
+`
+	if !strings.HasPrefix(cm.Data["Corefile"], expected) {
+		t.Errorf("expected Corefile to start with:\n%s\ngot:\n%s", expected, cm.Data["Corefile"])
+	}
+}
+
+// TestDesiredDNSDaemonSetZoneTransfers verifies that the dns pods mount the
+// TSIG secrets of the zone transfers and restart when the keys change.
+func TestDesiredDNSDaemonSetZoneTransfers(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DefaultDNSController,
+		},
+	}
+	transfers := []zoneTransfer{
+		{Zone: "lab.example.com", To: []string{"192.0.2.10:53"}, TSIGSecret: "lab-transfer-key", TSIGHash: "1"},
+		{Zone: "corp.example.com", To: []string{"192.0.2.10:53"}},
+	}
+	ds, err := desiredDNSDaemonSet(dns, "coredns", "kube-rbac-proxy", "", "dns-default-metrics-tls", "", nil, nil, nil, transfers, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, c := range ds.Spec.Template.Spec.Containers {
+		if c.Name != "dns" {
+			continue
+		}
+		for _, m := range c.VolumeMounts {
+			if m.MountPath == "/etc/coredns-zone-transfer-tsig/lab-transfer-key" {
+				found = true
+			}
+		}
+	}
+	if !found {
+		t.Errorf("expected the dns container to mount the TSIG secret, got %+v", ds.Spec.Template.Spec.Containers)
+	}
+	if actual := ds.Spec.Template.Annotations[zoneTransferTSIGHashAnnotation]; actual != "lab-transfer-key=1" {
+		t.Errorf("expected the pod template's TSIG hash annotation to be %q, got %q", "lab-transfer-key=1", actual)
+	}
+
+	transfers[0].TSIGHash = "2"
+	rotated, err := desiredDNSDaemonSet(dns, "coredns", "kube-rbac-proxy", "", "dns-default-metrics-tls", "", nil, nil, nil, transfers, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reflect.DeepEqual(ds.Spec.Template, rotated.Spec.Template) {
+		t.Error("expected the pod template to change when the TSIG keys change")
+	}
+}
@@ -3,6 +3,7 @@ package controller
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -18,8 +19,16 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
-func (r *reconciler) ensureServiceMonitor(dns *operatorv1.DNS, svc *corev1.Service, daemonsetRef metav1.OwnerReference) (bool, *unstructured.Unstructured, error) {
-	desired := desiredServiceMonitor(dns, svc, daemonsetRef)
+// serviceMonitorGVK is the group, version, and kind of the Prometheus
+// operator's ServiceMonitor resource.
+var serviceMonitorGVK = schema.GroupVersionKind{
+	Group:   "monitoring.coreos.com",
+	Kind:    "ServiceMonitor",
+	Version: "v1",
+}
+
+func (r *reconciler) ensureServiceMonitor(dns *operatorv1.DNS, svc *corev1.Service, daemonsetRef metav1.OwnerReference, metricsProxyAddress, metricsCASecretName string) (bool, *unstructured.Unstructured, error) {
+	desired := desiredServiceMonitor(dns, svc, daemonsetRef, metricsProxyAddress, metricsCASecretName)
 
 	haveSM, current, err := r.currentServiceMonitor(dns)
 	if err != nil {
@@ -43,8 +52,57 @@ func (r *reconciler) ensureServiceMonitor(dns *operatorv1.DNS, svc *corev1.Servi
 	return true, current, nil
 }
 
-func desiredServiceMonitor(dns *operatorv1.DNS, svc *corev1.Service, daemonsetRef metav1.OwnerReference) *unstructured.Unstructured {
+// desiredServiceMonitor returns the desired servicemonitor for the given dns.
+// If metricsProxyAddress is non-empty, prometheus scrapes each dns pod through
+// the operator's metrics proxy at that address, passing the pod's metrics
+// address in the "target" query parameter.  Otherwise, if metricsCASecretName
+// is non-empty, prometheus verifies the dns pods' metrics serving certificate
+// using the CA certificate in the secret with that name rather than the
+// service CA bundle.  If the dns enables query privacy, prometheus replaces
+// the query names in the labels of the scraped metrics.
+func desiredServiceMonitor(dns *operatorv1.DNS, svc *corev1.Service, daemonsetRef metav1.OwnerReference, metricsProxyAddress, metricsCASecretName string) *unstructured.Unstructured {
 	name := DNSServiceMonitorName(dns)
+	endpoint := map[string]interface{}{
+		"bearerTokenFile": "/var/run/secrets/kubernetes.io/serviceaccount/token",
+		"interval":        "30s",
+		"port":            "metrics",
+		"scheme":          "https",
+		"path":            "/metrics",
+		"tlsConfig": map[string]interface{}{
+			"caFile":     "/etc/prometheus/configmaps/serving-certs-ca-bundle/service-ca.crt",
+			"serverName": fmt.Sprintf("%s.%s.svc", svc.Name, svc.Namespace),
+		},
+	}
+	switch {
+	case len(metricsProxyAddress) != 0:
+		endpoint["tlsConfig"].(map[string]interface{})["serverName"] = strings.Split(metricsProxyAddress, ":")[0]
+		endpoint["relabelings"] = []interface{}{
+			map[string]interface{}{
+				"sourceLabels": []interface{}{"__address__"},
+				"targetLabel":  "__param_target",
+			},
+			map[string]interface{}{
+				"sourceLabels": []interface{}{"__param_target"},
+				"targetLabel":  "instance",
+			},
+			map[string]interface{}{
+				"targetLabel": "__address__",
+				"replacement": metricsProxyAddress,
+			},
+		}
+	case len(metricsCASecretName) != 0:
+		tlsConfig := endpoint["tlsConfig"].(map[string]interface{})
+		delete(tlsConfig, "caFile")
+		tlsConfig["ca"] = map[string]interface{}{
+			"secret": map[string]interface{}{
+				"name": metricsCASecretName,
+				"key":  metricsCASecretKey,
+			},
+		}
+	}
+	if relabelings := queryPrivacyMetricRelabelings(queryPrivacyMode(dns)); len(relabelings) != 0 {
+		endpoint["metricRelabelings"] = relabelings
+	}
 	sm := &unstructured.Unstructured{
 		Object: map[string]interface{}{
 			"metadata": map[string]interface{}{
@@ -59,37 +117,19 @@ func desiredServiceMonitor(dns *operatorv1.DNS, svc *corev1.Service, daemonsetRe
 				},
 				"selector": map[string]interface{}{},
 				"endpoints": []interface{}{
-					map[string]interface{}{
-						"bearerTokenFile": "/var/run/secrets/kubernetes.io/serviceaccount/token",
-						"interval":        "30s",
-						"port":            "metrics",
-						"scheme":          "https",
-						"path":            "/metrics",
-						"tlsConfig": map[string]interface{}{
-							"caFile":     "/etc/prometheus/configmaps/serving-certs-ca-bundle/service-ca.crt",
-							"serverName": fmt.Sprintf("%s.%s.svc", svc.Name, svc.Namespace),
-						},
-					},
+					endpoint,
 				},
 			},
 		},
 	}
-	sm.SetGroupVersionKind(schema.GroupVersionKind{
-		Group:   "monitoring.coreos.com",
-		Kind:    "ServiceMonitor",
-		Version: "v1",
-	})
+	sm.SetGroupVersionKind(serviceMonitorGVK)
 	sm.SetOwnerReferences([]metav1.OwnerReference{daemonsetRef})
 	return sm
 }
 
 func (r *reconciler) currentServiceMonitor(dns *operatorv1.DNS) (bool, *unstructured.Unstructured, error) {
 	sm := &unstructured.Unstructured{}
-	sm.SetGroupVersionKind(schema.GroupVersionKind{
-		Group:   "monitoring.coreos.com",
-		Kind:    "ServiceMonitor",
-		Version: "v1",
-	})
+	sm.SetGroupVersionKind(serviceMonitorGVK)
 	if err := r.client.Get(context.TODO(), DNSServiceMonitorName(dns), sm); err != nil {
 		if errors.IsNotFound(err) {
 			return false, nil, nil
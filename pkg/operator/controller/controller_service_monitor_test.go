@@ -1,8 +1,14 @@
 package controller
 
 import (
+	"reflect"
 	"testing"
 
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	corev1 "k8s.io/api/core/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
@@ -71,3 +77,84 @@ func TestDNSServiceMonitorChanged(t *testing.T) {
 		}
 	}
 }
+
+// TestDesiredServiceMonitorMetricsProxy verifies that desiredServiceMonitor
+// directs scrapes through the metrics proxy when a proxy address is given.
+func TestDesiredServiceMonitorMetricsProxy(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DefaultDNSController,
+		},
+	}
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "openshift-dns",
+			Name:      "dns-default",
+		},
+	}
+	endpoint := func(sm *unstructured.Unstructured) map[string]interface{} {
+		endpoints := sm.Object["spec"].(map[string]interface{})["endpoints"].([]interface{})
+		return endpoints[0].(map[string]interface{})
+	}
+
+	direct := endpoint(desiredServiceMonitor(dns, svc, metav1.OwnerReference{}, "", ""))
+	if _, ok := direct["relabelings"]; ok {
+		t.Errorf("expected no relabelings without a metrics proxy, got %#v", direct["relabelings"])
+	}
+	if e, a := "dns-default.openshift-dns.svc", direct["tlsConfig"].(map[string]interface{})["serverName"]; e != a {
+		t.Errorf("expected server name %q, got %q", e, a)
+	}
+
+	proxied := endpoint(desiredServiceMonitor(dns, svc, metav1.OwnerReference{}, "metrics.openshift-dns-operator.svc:9394", ""))
+	if e, a := "metrics.openshift-dns-operator.svc", proxied["tlsConfig"].(map[string]interface{})["serverName"]; e != a {
+		t.Errorf("expected server name %q, got %q", e, a)
+	}
+	expectedRelabelings := []interface{}{
+		map[string]interface{}{
+			"sourceLabels": []interface{}{"__address__"},
+			"targetLabel":  "__param_target",
+		},
+		map[string]interface{}{
+			"sourceLabels": []interface{}{"__param_target"},
+			"targetLabel":  "instance",
+		},
+		map[string]interface{}{
+			"targetLabel": "__address__",
+			"replacement": "metrics.openshift-dns-operator.svc:9394",
+		},
+	}
+	if !reflect.DeepEqual(proxied["relabelings"], expectedRelabelings) {
+		t.Errorf("expected relabelings %#v, got %#v", expectedRelabelings, proxied["relabelings"])
+	}
+}
+
+// TestDesiredServiceMonitorCustomCA verifies that desiredServiceMonitor uses
+// the CA certificate from a custom metrics serving certificate secret.
+func TestDesiredServiceMonitorCustomCA(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DefaultDNSController,
+		},
+	}
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "openshift-dns",
+			Name:      "dns-default",
+		},
+	}
+	sm := desiredServiceMonitor(dns, svc, metav1.OwnerReference{}, "", "custom-metrics-tls")
+	endpoints := sm.Object["spec"].(map[string]interface{})["endpoints"].([]interface{})
+	tlsConfig := endpoints[0].(map[string]interface{})["tlsConfig"].(map[string]interface{})
+	if _, ok := tlsConfig["caFile"]; ok {
+		t.Errorf("expected no caFile with a custom CA, got %q", tlsConfig["caFile"])
+	}
+	expectedCA := map[string]interface{}{
+		"secret": map[string]interface{}{
+			"name": "custom-metrics-tls",
+			"key":  "ca.crt",
+		},
+	}
+	if !reflect.DeepEqual(tlsConfig["ca"], expectedCA) {
+		t.Errorf("expected ca %#v, got %#v", expectedCA, tlsConfig["ca"])
+	}
+}
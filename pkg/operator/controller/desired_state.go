@@ -0,0 +1,271 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+const (
+	// ObjectInSync indicates that an object exists and has the fields that
+	// the operator rendered for it.
+	ObjectInSync = "InSync"
+	// ObjectDiffers indicates that an object exists but some of its fields
+	// differ from what the operator rendered for it.
+	ObjectDiffers = "Differs"
+	// ObjectMissing indicates that the operator rendered an object that
+	// does not exist.
+	ObjectMissing = "Missing"
+)
+
+// DesiredState records the operand objects that the operator rendered during
+// the most recent reconciliation of each dns so that they can be compared
+// with the objects that exist in the cluster.
+type DesiredState struct {
+	mu sync.Mutex
+	// published has the objects from each dns's most recent completed
+	// reconciliation, by dns name.
+	published map[string][]client.Object
+	// pending has the objects from each dns's reconciliation that is in
+	// progress, by dns name.
+	pending map[string][]client.Object
+}
+
+// NewDesiredState returns an empty DesiredState.
+func NewDesiredState() *DesiredState {
+	return &DesiredState{
+		published: map[string][]client.Object{},
+		pending:   map[string][]client.Object{},
+	}
+}
+
+// begin starts recording the objects for a reconciliation of the dns with the
+// given name.  The objects from the previous reconciliation remain published
+// until commit is called.  A nil DesiredState ignores all calls.
+func (s *DesiredState) begin(dnsName string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending[dnsName] = nil
+}
+
+// record records a copy of the given object for the reconciliation of the dns
+// with the given name.
+func (s *DesiredState) record(dnsName string, obj client.Object) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending[dnsName] = append(s.pending[dnsName], obj.DeepCopyObject().(client.Object))
+}
+
+// commit publishes the objects recorded for the reconciliation of the dns with
+// the given name.
+func (s *DesiredState) commit(dnsName string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.published[dnsName] = s.pending[dnsName]
+	delete(s.pending, dnsName)
+}
+
+// forget removes the objects for the dns with the given name.
+func (s *DesiredState) forget(dnsName string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.published, dnsName)
+	delete(s.pending, dnsName)
+}
+
+// objects returns the published objects for all dnses.
+func (s *DesiredState) objects() []client.Object {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var objects []client.Object
+	for _, objs := range s.published {
+		objects = append(objects, objs...)
+	}
+	return objects
+}
+
+// ObjectDiff describes the differences between an object that the operator
+// rendered and the object that exists in the cluster.
+type ObjectDiff struct {
+	// APIVersion and Kind are the object's API version and kind.
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	// Namespace and Name are the object's namespace and name.
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	// State is ObjectInSync, ObjectDiffers, or ObjectMissing.
+	State string `json:"state"`
+	// Fields describes each field that differs.
+	Fields []FieldDiff `json:"fields,omitempty"`
+}
+
+// FieldDiff describes a field whose rendered and applied values differ.
+type FieldDiff struct {
+	// Path is the JSON path of the field, such as
+	// spec.template.spec.containers[0].image.
+	Path string `json:"path"`
+	// Desired is the value that the operator rendered.
+	Desired interface{} `json:"desired"`
+	// Applied is the value in the cluster, or nil if the field is unset.
+	Applied interface{} `json:"applied"`
+}
+
+// Diff compares the published objects with the objects that exist in the
+// cluster, which it gets using the given client, and returns the differences,
+// sorted by kind, namespace, and name.
+//
+// Only the fields that the operator renders are compared: fields that the API
+// server defaults or that other controllers set are ignored, as is the
+// metadata other than labels and annotations.
+func (s *DesiredState) Diff(ctx context.Context, c client.Client) ([]ObjectDiff, error) {
+	var diffs []ObjectDiff
+	for _, desired := range s.objects() {
+		gvk, err := apiutil.GVKForObject(desired, c.Scheme())
+		if err != nil {
+			return nil, err
+		}
+		diff := ObjectDiff{
+			APIVersion: gvk.GroupVersion().String(),
+			Kind:       gvk.Kind,
+			Namespace:  desired.GetNamespace(),
+			Name:       desired.GetName(),
+			State:      ObjectInSync,
+		}
+		obj, err := c.Scheme().New(gvk)
+		if err != nil {
+			return nil, err
+		}
+		current := obj.(client.Object)
+		name := types.NamespacedName{Namespace: desired.GetNamespace(), Name: desired.GetName()}
+		if err := c.Get(ctx, name, current); err != nil {
+			if !errors.IsNotFound(err) {
+				return nil, fmt.Errorf("failed to get %s %s: %w", gvk.Kind, name, err)
+			}
+			diff.State = ObjectMissing
+			diffs = append(diffs, diff)
+			continue
+		}
+		fields, err := diffObjects(desired, current)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compare %s %s: %w", gvk.Kind, name, err)
+		}
+		if len(fields) != 0 {
+			diff.State = ObjectDiffers
+			diff.Fields = fields
+		}
+		diffs = append(diffs, diff)
+	}
+	sort.Slice(diffs, func(i, j int) bool {
+		if diffs[i].Kind != diffs[j].Kind {
+			return diffs[i].Kind < diffs[j].Kind
+		}
+		if diffs[i].Namespace != diffs[j].Namespace {
+			return diffs[i].Namespace < diffs[j].Namespace
+		}
+		return diffs[i].Name < diffs[j].Name
+	})
+	return diffs, nil
+}
+
+// diffObjects returns the fields of the desired object that differ in the
+// current object.
+func diffObjects(desired, current client.Object) ([]FieldDiff, error) {
+	desiredMap, err := runtime.DefaultUnstructuredConverter.ToUnstructured(desired)
+	if err != nil {
+		return nil, err
+	}
+	currentMap, err := runtime.DefaultUnstructuredConverter.ToUnstructured(current)
+	if err != nil {
+		return nil, err
+	}
+	// Compare only the labels and annotations in the metadata, and
+	// ignore the status.  The typed client does not set the type meta.
+	desiredMeta, _ := desiredMap["metadata"].(map[string]interface{})
+	metadata := map[string]interface{}{}
+	for _, key := range []string{"labels", "annotations"} {
+		if value, ok := desiredMeta[key]; ok {
+			metadata[key] = value
+		}
+	}
+	desiredMap["metadata"] = metadata
+	delete(desiredMap, "status")
+	delete(desiredMap, "apiVersion")
+	delete(desiredMap, "kind")
+
+	var diffs []FieldDiff
+	diffValues("", desiredMap, currentMap, &diffs)
+	return diffs, nil
+}
+
+// diffValues appends to diffs the fields at or below the given path that are
+// set in the desired value and differ in the current value.  Empty strings and
+// nulls in the desired value are treated as unset.  Lists of different lengths
+// are reported as a whole.
+func diffValues(path string, desired, current interface{}, diffs *[]FieldDiff) {
+	switch d := desired.(type) {
+	case nil:
+		return
+	case string:
+		if len(d) == 0 {
+			return
+		}
+	case map[string]interface{}:
+		c, _ := current.(map[string]interface{})
+		keys := make([]string, 0, len(d))
+		for k := range d {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			// Quote keys such as label names that contain
+			// dots so that the path is unambiguous.
+			var p string
+			switch {
+			case strings.ContainsAny(k, "./"):
+				p = path + "[" + strconv.Quote(k) + "]"
+			case len(path) == 0:
+				p = k
+			default:
+				p = path + "." + k
+			}
+			diffValues(p, d[k], c[k], diffs)
+		}
+		return
+	case []interface{}:
+		if c, ok := current.([]interface{}); ok && len(c) == len(d) {
+			for i := range d {
+				diffValues(path+"["+strconv.Itoa(i)+"]", d[i], c[i], diffs)
+			}
+			return
+		}
+		if len(d) == 0 && current == nil {
+			return
+		}
+	}
+	if !reflect.DeepEqual(desired, current) {
+		*diffs = append(*diffs, FieldDiff{Path: path, Desired: desired, Applied: current})
+	}
+}
@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	operatorclient "github.com/openshift/cluster-dns-operator/pkg/operator/client"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// fakeConfigMapClient is a client.Client that gets configmaps from a fixed
+// set.
+type fakeConfigMapClient struct {
+	client.Client
+	configMaps map[string]*corev1.ConfigMap
+}
+
+func (c *fakeConfigMapClient) Get(_ context.Context, key client.ObjectKey, obj client.Object) error {
+	cm, ok := c.configMaps[key.Name]
+	if !ok {
+		return errors.NewNotFound(schema.GroupResource{Resource: "configmaps"}, key.Name)
+	}
+	cm.DeepCopyInto(obj.(*corev1.ConfigMap))
+	return nil
+}
+
+func (c *fakeConfigMapClient) Scheme() *runtime.Scheme {
+	return operatorclient.GetScheme()
+}
+
+func configMap(name string, labels, data map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "openshift-dns", Name: name, Labels: labels},
+		Data:       data,
+	}
+}
+
+// TestDesiredStateDiff verifies that Diff reports rendered objects as in
+// sync, differing, or missing, and that it only reports objects from
+// committed reconciliations.
+func TestDesiredStateDiff(t *testing.T) {
+	labels := map[string]string{"dns.operator.openshift.io/owning-dns": "default"}
+	s := NewDesiredState()
+	s.begin("default")
+	s.record("default", configMap("in-sync", labels, map[string]string{"Corefile": "a"}))
+	s.record("default", configMap("differs", labels, map[string]string{"Corefile": "a"}))
+	s.record("default", configMap("missing", labels, map[string]string{"Corefile": "a"}))
+
+	// An applied object may have metadata and labels that the operator
+	// does not render.
+	inSync := configMap("in-sync", map[string]string{"dns.operator.openshift.io/owning-dns": "default", "other": "label"}, map[string]string{"Corefile": "a"})
+	inSync.ResourceVersion = "1"
+	c := &fakeConfigMapClient{configMaps: map[string]*corev1.ConfigMap{
+		"in-sync": inSync,
+		"differs": configMap("differs", nil, map[string]string{"Corefile": "b"}),
+	}}
+
+	diffs, err := s.Diff(context.Background(), c)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(diffs) != 0 {
+		t.Errorf("expected no objects before commit, got %#v", diffs)
+	}
+
+	s.commit("default")
+	diffs, err = s.Diff(context.Background(), c)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []ObjectDiff{
+		{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+			Namespace:  "openshift-dns",
+			Name:       "differs",
+			State:      ObjectDiffers,
+			Fields: []FieldDiff{
+				{Path: "data.Corefile", Desired: "a", Applied: "b"},
+				{Path: `metadata.labels["dns.operator.openshift.io/owning-dns"]`, Desired: "default", Applied: nil},
+			},
+		},
+		{APIVersion: "v1", Kind: "ConfigMap", Namespace: "openshift-dns", Name: "in-sync", State: ObjectInSync},
+		{APIVersion: "v1", Kind: "ConfigMap", Namespace: "openshift-dns", Name: "missing", State: ObjectMissing},
+	}
+	if !reflect.DeepEqual(diffs, expected) {
+		t.Errorf("expected %#v, got %#v", expected, diffs)
+	}
+
+	s.forget("default")
+	if diffs, err := s.Diff(context.Background(), c); err != nil || len(diffs) != 0 {
+		t.Errorf("expected no objects after forget, got %#v (%v)", diffs, err)
+	}
+}
+
+// TestDiffValues verifies that diffValues ignores fields that the desired
+// value does not set and reports lists of different lengths as a whole.
+func TestDiffValues(t *testing.T) {
+	desired := map[string]interface{}{
+		"name":  "",
+		"unset": nil,
+		"ports": []interface{}{
+			map[string]interface{}{"port": int64(53), "protocol": "UDP"},
+		},
+		"args":  []interface{}{"-a", "-b"},
+		"empty": []interface{}{},
+	}
+	current := map[string]interface{}{
+		"name": "defaulted",
+		"ports": []interface{}{
+			map[string]interface{}{"port": int64(5353), "protocol": "UDP", "defaulted": true},
+		},
+		"args": []interface{}{"-a"},
+	}
+	var diffs []FieldDiff
+	diffValues("", desired, current, &diffs)
+	expected := []FieldDiff{
+		{Path: "args", Desired: []interface{}{"-a", "-b"}, Applied: []interface{}{"-a"}},
+		{Path: "ports[0].port", Desired: int64(53), Applied: int64(5353)},
+	}
+	if !reflect.DeepEqual(diffs, expected) {
+		t.Errorf("expected %#v, got %#v", expected, diffs)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
 
 	"github.com/google/go-cmp/cmp"
@@ -11,22 +12,35 @@ import (
 	"github.com/sirupsen/logrus"
 
 	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
 
 	appsv1 "k8s.io/api/apps/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 // syncDNSStatus computes the current status of dns and
-// updates status upon any changes since last sync.
-func (r *reconciler) syncDNSStatus(dns *operatorv1.DNS, clusterIP, clusterDomain string, haveDNSDaemonset bool, dnsDaemonset *appsv1.DaemonSet, haveNodeResolverDaemonset bool, nodeResolverDaemonset *appsv1.DaemonSet) error {
+// updates status upon any changes since last sync.  Any additional conditions
+// that the caller computed are added after the Degraded, Progressing, and
+// Available conditions.  The status omits the fields that the given DNS CRD
+// schema does not have, and it is not updated if the schema does not allow
+// it.
+func (r *reconciler) syncDNSStatus(dns *operatorv1.DNS, clusterIP, clusterDomain string, topology nodeTopology, haveDNSDaemonset bool, dnsDaemonset *appsv1.DaemonSet, portConflicts []string, haveNodeResolverDaemonset bool, nodeResolverDaemonset *appsv1.DaemonSet, additionalConditions []operatorv1.OperatorCondition, schema *dnsCRDSchema) error {
 	updated := dns.DeepCopy()
 	updated.Status.ClusterIP = clusterIP
 	updated.Status.ClusterDomain = clusterDomain
-	updated.Status.Conditions = computeDNSStatusConditions(dns, clusterIP, haveDNSDaemonset, dnsDaemonset, haveNodeResolverDaemonset, nodeResolverDaemonset)
+	updated.Status.Conditions = computeDNSStatusConditions(dns, clusterIP, topology, haveDNSDaemonset, dnsDaemonset, portConflicts, haveNodeResolverDaemonset, nodeResolverDaemonset)
+	updated.Status.Conditions = append(updated.Status.Conditions, computeDNSAdditionalConditions(dns, additionalConditions)...)
+	schema.pruneStatus(&updated.Status)
 	if !dnsStatusesEqual(updated.Status, dns.Status) {
+		if !schema.canUpdateStatus() {
+			logrus.Warningf("not updating DNS %s status because the CRD %s does not allow it: new: %#v", dns.Name, dnsCRDName, updated.Status)
+			return nil
+		}
 		if err := r.client.Status().Update(context.TODO(), updated); err != nil {
-			return fmt.Errorf("failed to update dns status: %v", err)
+			return fmt.Errorf("failed to update dns status: %w", err)
 		}
 		logrus.Infof("updated DNS %s status: old: %#v, new: %#v", dns.ObjectMeta.Name, dns.Status, updated.Status)
 	}
@@ -34,9 +48,46 @@ func (r *reconciler) syncDNSStatus(dns *operatorv1.DNS, clusterIP, clusterDomain
 	return nil
 }
 
+// syncDNSObservedGeneration sets the ObservedGenerationAnnotation annotation
+// on dns to dns's generation if the annotation has a different value.  The
+// caller must have already updated the status of dns for that generation.
+func (r *reconciler) syncDNSObservedGeneration(dns *operatorv1.DNS) error {
+	if generation, ok := dnsObservedGeneration(dns); ok && generation == dns.Generation {
+		return nil
+	}
+	// Use a merge patch without a resource version because updating the
+	// status has changed the dns's resource version.
+	updated := dns.DeepCopy()
+	if updated.Annotations == nil {
+		updated.Annotations = map[string]string{}
+	}
+	updated.Annotations[ObservedGenerationAnnotation] = strconv.FormatInt(dns.Generation, 10)
+	if err := r.client.Patch(context.TODO(), updated, client.MergeFrom(dns)); err != nil {
+		return err
+	}
+	logrus.Infof("updated DNS %s observed generation to %d", dns.Name, dns.Generation)
+	return nil
+}
+
+// dnsObservedGeneration returns the generation in the given dns's
+// ObservedGenerationAnnotation annotation and a Boolean value indicating
+// whether the annotation is set to a valid generation.
+func dnsObservedGeneration(dns *operatorv1.DNS) (int64, bool) {
+	value, ok := dns.Annotations[ObservedGenerationAnnotation]
+	if !ok {
+		return 0, false
+	}
+	generation, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return generation, true
+}
+
 // computeDNSStatusConditions computes dns status conditions based on
-// the status of ds and clusterIP.
-func computeDNSStatusConditions(dns *operatorv1.DNS, clusterIP string, haveDNSDaemonset bool, dnsDaemonset *appsv1.DaemonSet, haveNodeResolverDaemonset bool, nodeResolverDaemonset *appsv1.DaemonSet) []operatorv1.OperatorCondition {
+// the status of ds and clusterIP, the cluster's node topology, and the dns
+// pods' port conflicts.
+func computeDNSStatusConditions(dns *operatorv1.DNS, clusterIP string, topology nodeTopology, haveDNSDaemonset bool, dnsDaemonset *appsv1.DaemonSet, portConflicts []string, haveNodeResolverDaemonset bool, nodeResolverDaemonset *appsv1.DaemonSet) []operatorv1.OperatorCondition {
 	var oldDegradedCondition, oldProgressingCondition, oldAvailableCondition *operatorv1.OperatorCondition
 	oldConditions := dns.Status.Conditions
 	for i := range oldConditions {
@@ -51,17 +102,40 @@ func computeDNSStatusConditions(dns *operatorv1.DNS, clusterIP string, haveDNSDa
 	}
 
 	conditions := []operatorv1.OperatorCondition{
-		computeDNSDegradedCondition(oldDegradedCondition, clusterIP, haveDNSDaemonset, dnsDaemonset, haveNodeResolverDaemonset, nodeResolverDaemonset),
-		computeDNSProgressingCondition(oldProgressingCondition, dns, clusterIP, haveDNSDaemonset, dnsDaemonset, haveNodeResolverDaemonset, nodeResolverDaemonset),
+		computeDNSDegradedCondition(oldDegradedCondition, clusterIP, topology, haveDNSDaemonset, dnsDaemonset, portConflicts, haveNodeResolverDaemonset, nodeResolverDaemonset),
+		computeDNSProgressingCondition(oldProgressingCondition, dns, clusterIP, topology, haveDNSDaemonset, dnsDaemonset, haveNodeResolverDaemonset, nodeResolverDaemonset),
 		computeDNSAvailableCondition(oldAvailableCondition, clusterIP, haveDNSDaemonset, dnsDaemonset),
 	}
 
 	return conditions
 }
 
+// computeDNSAdditionalConditions sets the last transition time for each of
+// the given conditions based on the corresponding condition in the dns's
+// current status, if any.
+func computeDNSAdditionalConditions(dns *operatorv1.DNS, conditions []operatorv1.OperatorCondition) []operatorv1.OperatorCondition {
+	var result []operatorv1.OperatorCondition
+	for i := range conditions {
+		var oldCondition *operatorv1.OperatorCondition
+		for j := range dns.Status.Conditions {
+			if dns.Status.Conditions[j].Type == conditions[i].Type {
+				oldCondition = &dns.Status.Conditions[j]
+				break
+			}
+		}
+		condition := conditions[i]
+		result = append(result, setDNSLastTransitionTime(&condition, oldCondition))
+	}
+	return result
+}
+
 // computeDNSDegradedCondition computes the dns Degraded status condition
-// based on the status of clusterIP and the DNS and node-resolver daemonsets.
-func computeDNSDegradedCondition(oldCondition *operatorv1.OperatorCondition, clusterIP string, haveDNSDaemonset bool, dnsDaemonset *appsv1.DaemonSet, haveNodeResolverDaemonset bool, nodeResolverDaemonset *appsv1.DaemonSet) operatorv1.OperatorCondition {
+// based on the status of clusterIP and the DNS and node-resolver daemonsets and
+// on the conflicts that keep DNS pods from listening on their ports.
+// If the cluster has no nodes, then no pods are desired, which is not a
+// degraded state.  During a control-plane window, pods on the unschedulable
+// worker nodes may be unavailable without the DNS being degraded.
+func computeDNSDegradedCondition(oldCondition *operatorv1.OperatorCondition, clusterIP string, topology nodeTopology, haveDNSDaemonset bool, dnsDaemonset *appsv1.DaemonSet, portConflicts []string, haveNodeResolverDaemonset bool, nodeResolverDaemonset *appsv1.DaemonSet) operatorv1.OperatorCondition {
 	degradedCondition := &operatorv1.OperatorCondition{
 		Type: operatorv1.OperatorStatusTypeDegraded,
 	}
@@ -70,12 +144,12 @@ func computeDNSDegradedCondition(oldCondition *operatorv1.OperatorCondition, clu
 	messages := []string{}
 	if len(clusterIP) == 0 {
 		status = operatorv1.ConditionTrue
-		degradedReasons = append(degradedReasons, "NoService")
+		degradedReasons = append(degradedReasons, conditions.ReasonNoService)
 		messages = append(messages, "No IP address is assigned to the DNS service.")
 	}
 	if !haveDNSDaemonset {
 		status = operatorv1.ConditionTrue
-		degradedReasons = append(degradedReasons, "NoDNSDaemonSet")
+		degradedReasons = append(degradedReasons, conditions.ReasonNoDNSDaemonSet)
 		messages = append(messages, "The DNS daemonset does not exist.")
 	} else {
 		want := dnsDaemonset.Status.DesiredNumberScheduled
@@ -87,26 +161,41 @@ func computeDNSDegradedCondition(oldCondition *operatorv1.OperatorCondition, clu
 		}
 		maxUnavailable, intstrErr := intstr.GetScaledValueFromIntOrPercent(&maxUnavailableIntStr, int(want), true)
 		switch {
+		case want == 0 && topology.noNodes:
+			// No DNS pods are desired because there are no nodes.
+		case want == 0 && topology.controlPlaneOnly:
+			status = operatorv1.ConditionTrue
+			degradedReasons = append(degradedReasons, conditions.ReasonNoDNSPodsDesired)
+			messages = append(messages, "No DNS pods are desired, and the cluster has no worker nodes; the DNS node placement must allow control-plane nodes.")
 		case want == 0:
 			status = operatorv1.ConditionTrue
-			degradedReasons = append(degradedReasons, "NoDNSPodsDesired")
+			degradedReasons = append(degradedReasons, conditions.ReasonNoDNSPodsDesired)
 			messages = append(messages, "No DNS pods are desired; this could mean all nodes are tainted or unschedulable.")
 		case have == 0:
 			status = operatorv1.ConditionTrue
-			degradedReasons = append(degradedReasons, "NoDNSPodsAvailable")
+			degradedReasons = append(degradedReasons, conditions.ReasonNoDNSPodsAvailable)
 			messages = append(messages, "No DNS pods are available.")
 		case intstrErr != nil:
-			degradedReasons = append(degradedReasons, "InvalidDNSMaxUnavailable")
+			degradedReasons = append(degradedReasons, conditions.ReasonInvalidDNSMaxUnavailable)
 			messages = append(messages, fmt.Sprintf("The DNS daemonset has an invalid MaxUnavailable value: %v", intstrErr))
+		case topology.controlPlaneWindow:
+			// DNS pods on control-plane nodes are serving.
 		case int(numberUnavailable) > maxUnavailable:
 			status = operatorv1.ConditionTrue
-			degradedReasons = append(degradedReasons, "MaxUnavailableDNSPodsExceeded")
+			degradedReasons = append(degradedReasons, conditions.ReasonMaxUnavailableDNSPodsExceeded)
 			messages = append(messages, fmt.Sprintf("Too many DNS pods are unavailable (%d > %d max unavailable).", numberUnavailable, maxUnavailable))
 		}
 	}
+	if len(portConflicts) != 0 {
+		// A port conflict does not resolve itself, however few pods
+		// it keeps from becoming available.
+		status = operatorv1.ConditionTrue
+		degradedReasons = append(degradedReasons, conditions.ReasonDNSPortConflicts)
+		messages = append(messages, fmt.Sprintf("Some DNS pods cannot listen on their ports: %s.", strings.Join(portConflicts, "; ")))
+	}
 	if !haveNodeResolverDaemonset {
 		status = operatorv1.ConditionTrue
-		degradedReasons = append(degradedReasons, "NoNodeResolverDaemonSet")
+		degradedReasons = append(degradedReasons, conditions.ReasonNoNodeResolverDaemonSet)
 		messages = append(messages, "The node-resolver daemonset does not exist.")
 	} else {
 		want := nodeResolverDaemonset.Status.DesiredNumberScheduled
@@ -118,20 +207,26 @@ func computeDNSDegradedCondition(oldCondition *operatorv1.OperatorCondition, clu
 		}
 		maxUnavailable, intstrErr := intstr.GetScaledValueFromIntOrPercent(&maxUnavailableIntStr, int(want), true)
 		switch {
+		case want == 0 && topology.noNodes:
+			// No node-resolver pods are desired because there are
+			// no nodes.
 		case want == 0:
 			status = operatorv1.ConditionTrue
-			degradedReasons = append(degradedReasons, "NoNodeResolverPodsDesired")
+			degradedReasons = append(degradedReasons, conditions.ReasonNoNodeResolverPodsDesired)
 			messages = append(messages, "No node-resolver pods are desired; this could mean all nodes are tainted or unschedulable.")
 		case have == 0:
 			status = operatorv1.ConditionTrue
-			degradedReasons = append(degradedReasons, "NoNodeResolverPodsAvailable")
+			degradedReasons = append(degradedReasons, conditions.ReasonNoNodeResolverPodsAvailable)
 			messages = append(messages, "No node-resolver pods are available.")
 		case intstrErr != nil:
-			degradedReasons = append(degradedReasons, "InvalidNodeResolverMaxUnavailable")
+			degradedReasons = append(degradedReasons, conditions.ReasonInvalidNodeResolverMaxUnavailable)
 			messages = append(messages, fmt.Sprintf("The node-resolver daemonset has an invalid MaxUnavailable value: %v", intstrErr))
+		case topology.controlPlaneWindow:
+			// Node-resolver pods on the unschedulable worker nodes
+			// may be drained.
 		case int(numberUnavailable) > maxUnavailable:
 			status = operatorv1.ConditionTrue
-			degradedReasons = append(degradedReasons, "MaxUnavailableNodeResolverPodsExceeded")
+			degradedReasons = append(degradedReasons, conditions.ReasonMaxUnavailableNodeResolverPodsExceeded)
 			messages = append(messages, fmt.Sprintf("Too many node-resolver pods are unavailable (%d > %d max unavailable).", numberUnavailable, maxUnavailable))
 		}
 	}
@@ -139,9 +234,17 @@ func computeDNSDegradedCondition(oldCondition *operatorv1.OperatorCondition, clu
 		degradedCondition.Status = status
 		degradedCondition.Reason = strings.Join(degradedReasons, "")
 		degradedCondition.Message = strings.Join(messages, "\n")
+	} else if topology.noNodes {
+		degradedCondition.Status = operatorv1.ConditionFalse
+		degradedCondition.Reason = conditions.ReasonNoNodes
+		degradedCondition.Message = "The cluster has no nodes, so no DNS or node-resolver pods are desired."
+	} else if topology.controlPlaneWindow {
+		degradedCondition.Status = operatorv1.ConditionFalse
+		degradedCondition.Reason = conditions.ReasonWorkersUnschedulable
+		degradedCondition.Message = "All worker nodes are unschedulable, but DNS pods on control-plane nodes are available, and the DNS service has a cluster IP address."
 	} else {
 		degradedCondition.Status = operatorv1.ConditionFalse
-		degradedCondition.Reason = "AsExpected"
+		degradedCondition.Reason = conditions.ReasonAsExpected
 		degradedCondition.Message = "Enough DNS and node-resolver pods are available, and the DNS service has a cluster IP address."
 	}
 
@@ -149,8 +252,9 @@ func computeDNSDegradedCondition(oldCondition *operatorv1.OperatorCondition, clu
 }
 
 // computeDNSProgressingCondition computes the dns Progressing status condition
-// based on the status of the DNS and node-resolver daemonsets.
-func computeDNSProgressingCondition(oldCondition *operatorv1.OperatorCondition, dns *operatorv1.DNS, clusterIP string, haveDNSDaemonset bool, dnsDaemonset *appsv1.DaemonSet, haveNodeResolverDaemonset bool, nodeResolverDaemonset *appsv1.DaemonSet) operatorv1.OperatorCondition {
+// based on the status of the DNS and node-resolver daemonsets.  A control-plane
+// window is reported as progressing.
+func computeDNSProgressingCondition(oldCondition *operatorv1.OperatorCondition, dns *operatorv1.DNS, clusterIP string, topology nodeTopology, haveDNSDaemonset bool, dnsDaemonset *appsv1.DaemonSet, haveNodeResolverDaemonset bool, nodeResolverDaemonset *appsv1.DaemonSet) operatorv1.OperatorCondition {
 	progressingCondition := &operatorv1.OperatorCondition{
 		Type: operatorv1.OperatorStatusTypeProgressing,
 	}
@@ -188,13 +292,16 @@ func computeDNSProgressingCondition(oldCondition *operatorv1.OperatorCondition,
 			messages = append(messages, fmt.Sprintf("Have %d available node-resolver pods, want %d.", have, want))
 		}
 	}
+	if topology.controlPlaneWindow {
+		messages = append(messages, "All worker nodes are unschedulable; DNS pods on control-plane nodes are serving until worker nodes are schedulable again.")
+	}
 	if len(messages) != 0 {
 		progressingCondition.Status = operatorv1.ConditionTrue
-		progressingCondition.Reason = "Reconciling"
+		progressingCondition.Reason = conditions.ReasonReconciling
 		progressingCondition.Message = strings.Join(messages, "\n")
 	} else {
 		progressingCondition.Status = operatorv1.ConditionFalse
-		progressingCondition.Reason = "AsExpected"
+		progressingCondition.Reason = conditions.ReasonAsExpected
 		progressingCondition.Message = "All DNS and node-resolver pods are available, and the DNS service has a cluster IP address."
 	}
 
@@ -210,14 +317,14 @@ func computeDNSAvailableCondition(oldCondition *operatorv1.OperatorCondition, cl
 	unavailableReasons := []string{}
 	messages := []string{}
 	if !haveDNSDaemonset {
-		unavailableReasons = append(unavailableReasons, "NoDaemonSet")
+		unavailableReasons = append(unavailableReasons, conditions.ReasonNoDaemonSet)
 		messages = append(messages, "The DNS daemonset does not exist.")
 	} else if dnsDaemonset.Status.NumberAvailable == 0 {
-		unavailableReasons = append(unavailableReasons, "NoDaemonSetPods")
+		unavailableReasons = append(unavailableReasons, conditions.ReasonNoDaemonSetPods)
 		messages = append(messages, "The DNS daemonset has no pods available.")
 	}
 	if len(clusterIP) == 0 {
-		unavailableReasons = append(unavailableReasons, "NoService")
+		unavailableReasons = append(unavailableReasons, conditions.ReasonNoService)
 		messages = append(messages, "No IP address is assigned to the DNS service.")
 	}
 	if len(unavailableReasons) != 0 {
@@ -226,7 +333,7 @@ func computeDNSAvailableCondition(oldCondition *operatorv1.OperatorCondition, cl
 		availableCondition.Message = strings.Join(messages, "\n")
 	} else {
 		availableCondition.Status = operatorv1.ConditionTrue
-		availableCondition.Reason = "AsExpected"
+		availableCondition.Reason = conditions.ReasonAsExpected
 		availableCondition.Message = "The DNS daemonset has available pods, and the DNS service has a cluster IP address."
 	}
 
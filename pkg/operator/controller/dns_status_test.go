@@ -132,7 +132,7 @@ func TestDNSStatusConditions(t *testing.T) {
 				Status: available,
 			},
 		}
-		actual := computeDNSStatusConditions(&operatorv1.DNS{}, clusterIP, tc.inputs.haveDNS, dnsDaemonset, tc.inputs.haveNR, nodeResolverDaemonset)
+		actual := computeDNSStatusConditions(&operatorv1.DNS{}, clusterIP, nodeTopology{}, tc.inputs.haveDNS, dnsDaemonset, nil, tc.inputs.haveNR, nodeResolverDaemonset)
 		gotExpected := true
 		if len(actual) != len(expected) {
 			gotExpected = false
@@ -182,11 +182,13 @@ func TestComputeDNSDegradedCondition(t *testing.T) {
 		}
 	}
 	testCases := []struct {
-		name         string
-		clusterIP    string
-		dnsDaemonset *appsv1.DaemonSet
-		nrDaemonset  *appsv1.DaemonSet
-		expected     operatorv1.ConditionStatus
+		name          string
+		clusterIP     string
+		topology      nodeTopology
+		dnsDaemonset  *appsv1.DaemonSet
+		portConflicts []string
+		nrDaemonset   *appsv1.DaemonSet
+		expected      operatorv1.ConditionStatus
 	}{
 		{
 			name:         "0 available, DNS invalid MaxUnavailable",
@@ -251,6 +253,54 @@ func TestComputeDNSDegradedCondition(t *testing.T) {
 			nrDaemonset:  makeDaemonSet(0, 0, intstr.FromString("10%")),
 			expected:     operatorv1.ConditionTrue,
 		},
+		{
+			name:         "0 desired, no nodes",
+			clusterIP:    "172.30.0.10",
+			topology:     nodeTopology{noNodes: true},
+			dnsDaemonset: makeDaemonSet(0, 0, intstr.FromString("10%")),
+			nrDaemonset:  makeDaemonSet(0, 0, intstr.FromString("10%")),
+			expected:     operatorv1.ConditionFalse,
+		},
+		{
+			name:         "0 desired, control-plane nodes only",
+			clusterIP:    "172.30.0.10",
+			topology:     nodeTopology{controlPlaneOnly: true},
+			dnsDaemonset: makeDaemonSet(0, 0, intstr.FromString("10%")),
+			nrDaemonset:  makeDaemonSet(3, 3, intstr.FromString("10%")),
+			expected:     operatorv1.ConditionTrue,
+		},
+		{
+			name:         "too few available, workers unschedulable",
+			clusterIP:    "172.30.0.10",
+			topology:     nodeTopology{workersUnschedulable: true},
+			dnsDaemonset: makeDaemonSet(6, 3, intstr.FromString("10%")),
+			nrDaemonset:  makeDaemonSet(6, 3, intstr.FromString("10%")),
+			expected:     operatorv1.ConditionTrue,
+		},
+		{
+			name:         "too few available, control-plane window",
+			clusterIP:    "172.30.0.10",
+			topology:     nodeTopology{workersUnschedulable: true, controlPlaneWindow: true},
+			dnsDaemonset: makeDaemonSet(6, 3, intstr.FromString("10%")),
+			nrDaemonset:  makeDaemonSet(6, 3, intstr.FromString("10%")),
+			expected:     operatorv1.ConditionFalse,
+		},
+		{
+			name:         "0 available, control-plane window",
+			clusterIP:    "172.30.0.10",
+			topology:     nodeTopology{workersUnschedulable: true, controlPlaneWindow: true},
+			dnsDaemonset: makeDaemonSet(6, 0, intstr.FromString("10%")),
+			nrDaemonset:  makeDaemonSet(6, 3, intstr.FromString("10%")),
+			expected:     operatorv1.ConditionTrue,
+		},
+		{
+			name:         "all available, control-plane nodes only",
+			clusterIP:    "172.30.0.10",
+			topology:     nodeTopology{controlPlaneOnly: true},
+			dnsDaemonset: makeDaemonSet(3, 3, intstr.FromString("10%")),
+			nrDaemonset:  makeDaemonSet(3, 3, intstr.FromString("10%")),
+			expected:     operatorv1.ConditionFalse,
+		},
 		{
 			name:         "0 available",
 			clusterIP:    "172.30.0.10",
@@ -307,6 +357,14 @@ func TestComputeDNSDegradedCondition(t *testing.T) {
 			nrDaemonset:  makeDaemonSet(6, 6, intstr.FromString("10%")),
 			expected:     operatorv1.ConditionFalse,
 		},
+		{
+			name:          "enough available, port conflict",
+			clusterIP:     "172.30.0.10",
+			dnsDaemonset:  makeDaemonSet(6, 5, intstr.FromInt(1)),
+			portConflicts: []string{"pod dns-default-abcde on node worker-0: container dns cannot listen on its ports: listen tcp :5353: bind: address already in use"},
+			nrDaemonset:   makeDaemonSet(6, 6, intstr.FromInt(1)),
+			expected:      operatorv1.ConditionTrue,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -314,7 +372,7 @@ func TestComputeDNSDegradedCondition(t *testing.T) {
 			Type:   operatorv1.OperatorStatusTypeDegraded,
 			Status: operatorv1.ConditionUnknown,
 		}
-		actual := computeDNSDegradedCondition(oldCondition, tc.clusterIP, true, tc.dnsDaemonset, true, tc.nrDaemonset)
+		actual := computeDNSDegradedCondition(oldCondition, tc.clusterIP, tc.topology, true, tc.dnsDaemonset, tc.portConflicts, true, tc.nrDaemonset)
 		if actual.Status != tc.expected {
 			t.Errorf("%q: expected status to be %s, got %s: %#v", tc.name, tc.expected, actual.Status, actual)
 		}
@@ -488,7 +546,7 @@ func TestComputeDNSProgressingCondition(t *testing.T) {
 				Conditions: []operatorv1.OperatorCondition{oldCondition},
 			},
 		}
-		actual := computeDNSProgressingCondition(&oldCondition, dns, tc.clusterIP, true, tc.dnsDaemonset, true, tc.nrDaemonset)
+		actual := computeDNSProgressingCondition(&oldCondition, dns, tc.clusterIP, nodeTopology{}, true, tc.dnsDaemonset, true, tc.nrDaemonset)
 		if actual.Status != tc.expected {
 			t.Errorf("%q: expected status to be %s, got %s: %#v", tc.name, tc.expected, actual.Status, actual)
 		}
@@ -656,3 +714,27 @@ func TestDNSStatusesEqual(t *testing.T) {
 		}
 	}
 }
+
+// TestDNSObservedGeneration verifies that dnsObservedGeneration parses the
+// observed generation annotation and ignores invalid values.
+func TestDNSObservedGeneration(t *testing.T) {
+	testCases := []struct {
+		value              string
+		expectedGeneration int64
+		expectedOK         bool
+	}{
+		{"", 0, false},
+		{"3", 3, true},
+		{"bogus", 0, false},
+	}
+	for _, tc := range testCases {
+		dns := &operatorv1.DNS{}
+		if len(tc.value) != 0 {
+			dns.Annotations = map[string]string{ObservedGenerationAnnotation: tc.value}
+		}
+		generation, ok := dnsObservedGeneration(dns)
+		if generation != tc.expectedGeneration || ok != tc.expectedOK {
+			t.Errorf("expected (%d, %t) for %q, got (%d, %t)", tc.expectedGeneration, tc.expectedOK, tc.value, generation, ok)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+const (
+	// DesiredHashAnnotation is the annotation that the operator stamps on
+	// managed objects with a hash of the content that it rendered for
+	// them.  Comparing the hash of the newly rendered content against this
+	// annotation detects changes to fields that the per-resource
+	// comparison functions do not inspect.
+	DesiredHashAnnotation = "dns.operator.openshift.io/desired-hash"
+)
+
+// computeHash returns a hex-encoded SHA-256 hash of the JSON encoding of the
+// given value.
+func computeHash(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal value for hashing: %w", err)
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
+}
+
+// hashChanged returns a Boolean value indicating whether the desired-hash
+// annotations on the current and expected annotation maps differ.
+func hashChanged(current, expected map[string]string) bool {
+	return current[DesiredHashAnnotation] != expected[DesiredHashAnnotation]
+}
+
+// setDesiredHash sets the desired-hash annotation on the given object to the
+// given hash value.
+func setDesiredHash(obj metav1.Object, hash string) {
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[DesiredHashAnnotation] = hash
+	obj.SetAnnotations(annotations)
+}
+
+// dnsChangedPredicate filters out update events for DNS resources that only
+// changed the resource's status.  The operator writes the DNS status itself,
+// so these events would otherwise cause a redundant reconcile after every
+// status update.
+var dnsChangedPredicate = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		if e.ObjectOld == nil || e.ObjectNew == nil {
+			return true
+		}
+		old, new := e.ObjectOld, e.ObjectNew
+		switch {
+		case old.GetGeneration() != new.GetGeneration():
+			return true
+		case !equality.Semantic.DeepEqual(old.GetDeletionTimestamp(), new.GetDeletionTimestamp()):
+			return true
+		case !equality.Semantic.DeepEqual(old.GetFinalizers(), new.GetFinalizers()):
+			return true
+		case !equality.Semantic.DeepEqual(old.GetAnnotations(), new.GetAnnotations()):
+			return true
+		case !equality.Semantic.DeepEqual(old.GetLabels(), new.GetLabels()):
+			return true
+		}
+		return false
+	},
+}
@@ -0,0 +1,117 @@
+package controller
+
+import (
+	"testing"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	corev1 "k8s.io/api/core/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+// TestDNSChangedPredicate verifies that dnsChangedPredicate ignores updates
+// that only change the DNS status.
+func TestDNSChangedPredicate(t *testing.T) {
+	testCases := []struct {
+		description string
+		mutate      func(*operatorv1.DNS)
+		expect      bool
+	}{
+		{
+			description: "if nothing changes",
+			mutate:      func(_ *operatorv1.DNS) {},
+			expect:      false,
+		},
+		{
+			description: "if only the status changes",
+			mutate: func(dns *operatorv1.DNS) {
+				dns.Status.ClusterIP = "1.2.3.4"
+			},
+			expect: false,
+		},
+		{
+			description: "if the generation changes",
+			mutate: func(dns *operatorv1.DNS) {
+				dns.Generation++
+			},
+			expect: true,
+		},
+		{
+			description: "if a finalizer is added",
+			mutate: func(dns *operatorv1.DNS) {
+				dns.Finalizers = append(dns.Finalizers, DNSControllerFinalizer)
+			},
+			expect: true,
+		},
+		{
+			description: "if the deletion timestamp is set",
+			mutate: func(dns *operatorv1.DNS) {
+				now := metav1.Now()
+				dns.DeletionTimestamp = &now
+			},
+			expect: true,
+		},
+		{
+			description: "if an annotation is added",
+			mutate: func(dns *operatorv1.DNS) {
+				dns.Annotations = map[string]string{"foo": "bar"}
+			},
+			expect: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		original := &operatorv1.DNS{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       DefaultDNSController,
+				Generation: 1,
+			},
+		}
+		mutated := original.DeepCopy()
+		tc.mutate(mutated)
+		e := event.UpdateEvent{ObjectOld: original, ObjectNew: mutated}
+		if actual := dnsChangedPredicate.Update(e); actual != tc.expect {
+			t.Errorf("%s, expected %t, got %t", tc.description, tc.expect, actual)
+		}
+	}
+}
+
+// TestDaemonsetConfigChangedDesiredHash verifies that daemonsetConfigChanged
+// detects changes to fields that it does not otherwise compare by way of the
+// desired-hash annotation.
+func TestDaemonsetConfigChangedDesiredHash(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DefaultDNSController,
+		},
+	}
+	current, err := desiredDNSDaemonSet(dns, "coredns", "kube-rbac-proxy", "", DNSMetricsSecretName(dns), "", nil, nil, nil, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed, _ := daemonsetConfigChanged(current, current.DeepCopy()); changed {
+		t.Fatal("expected no change when the desired daemonset is unchanged")
+	}
+
+	expected := current.DeepCopy()
+	expected.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "FOO", Value: "bar"}}
+	hash, err := computeHash(expected.Spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setDesiredHash(expected, hash)
+
+	changed, updated := daemonsetConfigChanged(current, expected)
+	if !changed {
+		t.Fatal("expected a change when the desired hash changes")
+	}
+	if len(updated.Spec.Template.Spec.Containers[0].Env) != 1 {
+		t.Errorf("expected the updated daemonset to have the new env, got %#v", updated.Spec.Template.Spec.Containers[0].Env)
+	}
+	if changedAgain, _ := daemonsetConfigChanged(updated, expected); changedAgain {
+		t.Error("daemonsetConfigChanged does not behave as a fixed point function")
+	}
+}
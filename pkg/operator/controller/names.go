@@ -1,6 +1,8 @@
 package controller
 
 import (
+	"strings"
+
 	operatorv1 "github.com/openshift/api/operator/v1"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -20,6 +22,645 @@ const (
 	// the certificates for secure DNS metrics.
 	MetricsServingCertAnnotation = "service.beta.openshift.io/serving-cert-secret-name"
 
+	// MetricsServingCertSecretAnnotation is the annotation on a DNS that
+	// names a secret in the operand namespace with a custom serving
+	// certificate for the DNS metrics endpoint.  The secret must have
+	// tls.crt, tls.key, and ca.crt keys.
+	MetricsServingCertSecretAnnotation = "dns.operator.openshift.io/metrics-serving-cert-secret"
+
+	// MetricsCertificateIssuerAnnotation is the annotation on a DNS that
+	// names a cert-manager issuer that issues the serving certificate for
+	// the DNS metrics endpoint.  The value is <name> or Issuer/<name> for
+	// an issuer in the operand namespace, or ClusterIssuer/<name> for a
+	// cluster issuer.  The operator creates a cert-manager Certificate
+	// and uses the secret that cert-manager stores it in unless the
+	// MetricsServingCertSecretAnnotation annotation names another secret.
+	MetricsCertificateIssuerAnnotation = "dns.operator.openshift.io/metrics-certificate-issuer"
+
+	// NodeResolverAdditionalServicesAnnotation is the annotation on a DNS
+	// that lists additional services for which the node resolver adds
+	// entries to /etc/hosts on each node.  The value is a comma- or
+	// space-delimited list of <namespace>/<name>=<hostname> entries; if
+	// "=<hostname>" is omitted, the service's cluster domain names are
+	// used.
+	NodeResolverAdditionalServicesAnnotation = "dns.operator.openshift.io/node-resolver-additional-services"
+
+	// TolerateUnschedulableWorkersAnnotation is the annotation on a DNS
+	// that, if set to "true", causes the DNS to be reported as progressing
+	// rather than degraded while all worker nodes are unschedulable as long
+	// as DNS pods on control-plane nodes are ready.
+	TolerateUnschedulableWorkersAnnotation = "dns.operator.openshift.io/tolerate-unschedulable-workers"
+
+	// PreferInfraNodesAnnotation is the annotation on a DNS that, if set to
+	// "true", causes the DNS's pods to tolerate infra nodes' taint and to
+	// prefer infra nodes, while still running on every node that the DNS's
+	// node placement selects.
+	PreferInfraNodesAnnotation = "dns.operator.openshift.io/prefer-infra-nodes"
+
+	// AllowNodeCoverageLossAnnotation is the annotation on a DNS that, if
+	// set to "true", lets the operator apply a change to the DNS's node
+	// placement that removes DNS pods from nodes that have them.  Without
+	// the annotation, the operator keeps the DNS daemonset's current node
+	// placement and reports the nodes that would lose DNS pods with the
+	// NodeCoveragePreserved condition.
+	AllowNodeCoverageLossAnnotation = "dns.operator.openshift.io/allow-node-coverage-loss"
+
+	// MaintenanceUpstreamsAnnotation is the annotation on a DNS that
+	// declares maintenance windows during which servers in the DNS's
+	// spec.servers use alternate upstreams.  The value is a JSON list of
+	// objects with "server", "upstreams", "days", "start", and "duration"
+	// fields, for example:
+	//
+	//	[{"server": "corp", "upstreams": ["10.0.0.53"], "days": ["Sat"], "start": "02:00", "duration": "4h"}]
+	//
+	// Start times are in UTC.  The operator switches to the alternate
+	// upstreams when a window starts and back when it ends.
+	MaintenanceUpstreamsAnnotation = "dns.operator.openshift.io/maintenance-upstreams"
+
+	// RolloutWindowsAnnotation is the annotation on a DNS that declares
+	// the windows during which the operator rolls out changes to the
+	// DNS's pods.  The value is a JSON list of objects with "days",
+	// "start", and "duration" fields, as in
+	// MaintenanceUpstreamsAnnotation, for example:
+	//
+	//	[{"days": ["Sat", "Sun"], "start": "01:00", "duration": "3h"}]
+	//
+	// Outside the windows, the operator defers updates that change the DNS
+	// daemonset's pod template, such as custom image or node placement
+	// changes, and reports them with the RolloutDeferred condition.
+	// Updates to the DNS's configmap are deferred along with the daemonset
+	// rollout so that CoreDNS does not load a Corefile before the pods that
+	// it needs roll out.  Updates that restore the release images, and
+	// updates while the daemonset has no available pods, roll out
+	// immediately.  Removing the annotation rolls out deferred updates.
+	RolloutWindowsAnnotation = "dns.operator.openshift.io/rollout-windows"
+
+	// QueryTimeoutsAnnotation is the annotation on a DNS that sets the
+	// overall timeout for queries in each server block using CoreDNS's
+	// cancel plugin.  The value is a comma- or space-delimited list of
+	// <server>=<duration> entries, where <server> is the name of a server
+	// in the DNS's spec.servers or "." for the default server block, and
+	// <duration> is in the format that time.ParseDuration accepts, for
+	// example ".=5s,corp=2s".
+	QueryTimeoutsAnnotation = "dns.operator.openshift.io/query-timeouts"
+
+	// ServerTimeoutsAnnotation is the annotation on a DNS that sets the
+	// read, write, and idle timeouts for client connections to CoreDNS
+	// over TCP using CoreDNS's timeouts plugin.  Shorter idle timeouts
+	// release the file descriptors of chatty TCP clients sooner.  The
+	// value is a comma- or space-delimited list of <timeout>=<duration>
+	// entries, where <timeout> is "read", "write", or "idle", for example
+	// "read=10s,idle=30s".  CoreDNS has no setting that limits the number
+	// of client connections.
+	ServerTimeoutsAnnotation = "dns.operator.openshift.io/server-timeouts"
+
+	// ForwardingAnnotation is the annotation on a DNS that tunes CoreDNS's
+	// forward plugin.  The value is a comma- or space-delimited list of
+	// [<server>/]<setting>=<value> entries, where <setting> is
+	// "max-concurrent", which caps the number of concurrent queries that
+	// a server block forwards and makes CoreDNS answer SERVFAIL to
+	// queries beyond the cap, or "expire" or "health-check", which set the
+	// duration after which cached connections to the upstreams expire and
+	// the interval between health checks of the upstreams.  An entry
+	// without <server> applies to every server block, including those
+	// for IdM DNS servers; an entry with <server>, the name of a server in
+	// the DNS's spec.servers or "." for the default server block, applies
+	// to that server block and overrides entries without <server>, for
+	// example "max-concurrent=5000,./max-concurrent=10000,corp/expire=30s".
+	// CoreDNS's defaults are no cap, 10s, and 0.5s.
+	ForwardingAnnotation = "dns.operator.openshift.io/forwarding"
+
+	// CacheAnnotation is the annotation on a DNS that tunes CoreDNS's cache
+	// plugin in the default server block.  The value is a comma- or
+	// space-delimited list of <setting>=<value> entries, where <setting>
+	// is "success-ttl" or "denial-ttl", which cap the time for which
+	// positive and negative responses are cached and take durations of
+	// whole seconds, or "success-max-entries" or "denial-max-entries",
+	// which cap the number of cached positive and negative responses, or
+	// "serve-stale", which enables CoreDNS's serve_stale option so that
+	// cached responses are served for up to the given duration past their
+	// TTLs while the upstreams are unreachable, or "prefetch-amount",
+	// "prefetch-duration", or "prefetch-percentage", which enable CoreDNS's
+	// prefetch option so that popular records are refreshed before they
+	// expire, for example
+	// "success-ttl=5m,denial-ttl=10s,serve-stale=1h,prefetch-amount=5".
+	// The defaults are 900s, 30s, 9984, and 9984, and serve_stale and
+	// prefetch are disabled.  Prefetching a record requires that it be
+	// requested prefetch-amount times, 2 by default, with no gap of
+	// prefetch-duration, 1m by default, and that only
+	// prefetch-percentage percent of its TTL, 10 by default, remain.  If
+	// serve_stale is enabled, the server blocks for the DNS's spec.servers
+	// also have the cache plugin.
+	CacheAnnotation = "dns.operator.openshift.io/cache"
+
+	// UDPTruncationAnnotation is the annotation on a DNS that sets the
+	// policy by which CoreDNS truncates UDP responses and so pushes
+	// clients to retry over TCP, which is useful on networks that drop
+	// fragmented UDP packets.  The value is a comma- or space-delimited
+	// list of <setting>=<value> entries, where <setting> is "max-size",
+	// the size in bytes, between 512 and 4096, above which CoreDNS
+	// truncates a UDP response and sets its TC bit, or
+	// "minimal-responses", which, if "true", makes CoreDNS omit the
+	// authority and additional sections from responses that do not
+	// require them so that fewer responses are truncated, for example
+	// "max-size=512,minimal-responses=true".  The defaults are 1232 and
+	// false.  The policy applies to every server block.
+	UDPTruncationAnnotation = "dns.operator.openshift.io/udp-truncation"
+
+	// DNS64Annotation is the annotation on a DNS that enables CoreDNS's
+	// dns64 plugin, which synthesizes AAAA records from A records so that
+	// IPv6 clients can reach IPv4-only destinations through a NAT64
+	// gateway.  The value is empty or a comma- or space-delimited list of
+	// <setting>=<value> entries, where <setting> is "prefix", the IPv6
+	// prefix of the NAT64 gateway with a length of 32, 40, 48, 56, 64, or
+	// 96 bits, or "translate-all", which, if "true", makes CoreDNS
+	// synthesize AAAA records for names that have AAAA records too, for
+	// example "prefix=2001:db8:64::/96,translate-all=true".  The defaults
+	// are 64:ff9b::/96 and false.  DNS64 applies to every server block,
+	// and only if the cluster network is IPv6-only or dual-stack.
+	DNS64Annotation = "dns.operator.openshift.io/dns64"
+
+	// PreferredAnswerPrefixesAnnotation is the annotation on a DNS that
+	// lists IPv4 and IPv6 prefixes, in CIDR notation and in order of
+	// preference, by which CoreDNS sorts the A and AAAA records in its
+	// answers, which is useful on multi-homed networks where clients
+	// should prefer the endpoint on their local subnet.  CoreDNS's
+	// loadbalance plugin puts the records whose addresses are in an
+	// earlier prefix first and shuffles the other records.  The value is a
+	// comma- or space-delimited list, for example
+	// "192.168.10.0/24,10.0.0.0/8".  Invalid prefixes are ignored.  The
+	// order applies to every server block.
+	PreferredAnswerPrefixesAnnotation = "dns.operator.openshift.io/preferred-answer-prefixes"
+
+	// ExternalNameAllowedDomainsAnnotation is the annotation on a DNS that
+	// restricts the names to which ExternalName services may point, so
+	// that tenants cannot use cluster DNS to make names that they control
+	// resolve to other tenants' services or to arbitrary external names.
+	// The value is a comma- or space-delimited list of domains; an
+	// ExternalName service may point to names in these domains and to
+	// names in the cluster domain in its own namespace.  The operator
+	// reports the services that violate the policy with the
+	// ExternalNamePolicyCompliant condition.
+	ExternalNameAllowedDomainsAnnotation = "dns.operator.openshift.io/externalname-allowed-domains"
+
+	// ExternalNameEnforcementAnnotation is the annotation on a DNS that
+	// sets whether CoreDNS enforces the policy in the DNS's
+	// ExternalNameAllowedDomainsAnnotation annotation.  The value is
+	// "audit", the default, which only reports violations, or "enforce",
+	// which makes CoreDNS answer NXDOMAIN for the names of the services
+	// that violate the policy.
+	ExternalNameEnforcementAnnotation = "dns.operator.openshift.io/externalname-enforcement"
+
+	// FallbackToDefaultUpstreamsAnnotation is the annotation on a DNS that
+	// lists the names of servers in the DNS's spec.servers that forward
+	// queries to the default upstreams in /etc/resolv.conf when their own
+	// upstreams fail.  The forward plugin then tries the server's
+	// upstreams in the listed order, rather than at random, before the
+	// default upstreams.  The value is a comma- or space-delimited list,
+	// for example "corp,lab".
+	FallbackToDefaultUpstreamsAnnotation = "dns.operator.openshift.io/fallback-to-default-upstreams"
+
+	// NodeTuningAnnotation is the annotation on a DNS that, if set to
+	// "true", causes the operator to manage a Tuned resource that adjusts
+	// conntrack UDP timeouts and socket buffer sizes on the nodes that run
+	// the DNS's pods.  The node tuning operator applies the Tuned resource.
+	NodeTuningAnnotation = "dns.operator.openshift.io/node-tuning"
+
+	// ObservedGenerationAnnotation is the annotation that the operator sets
+	// on a DNS to the most recent generation of the DNS for which the
+	// operator has updated the DNS's status.  Status conditions reflect the
+	// spec of that generation, so clients can compare the annotation with
+	// metadata.generation to tell whether the operator has processed the
+	// latest spec change.  DNSStatus has no observedGeneration field, so the
+	// operator uses an annotation instead.
+	ObservedGenerationAnnotation = "dns.operator.openshift.io/observed-generation"
+
+	// MigratedFieldsAnnotation is the annotation that the operator sets on
+	// a DNS to record the deprecated configuration shapes that it has
+	// rewritten in their current forms, such as forwardPlugin upstreams
+	// written as in a Corefile, "dns://<ip>" or several addresses in one
+	// entry.  The value is a JSON list of descriptions of the most recent
+	// migrations, which the DeprecatedFieldsMigrated condition reports.
+	MigratedFieldsAnnotation = "dns.operator.openshift.io/migrated-fields"
+
+	// AllowServiceRecreationAnnotation is the annotation on a DNS that, if
+	// set to "true", allows the operator to delete and recreate the DNS's
+	// service when the service's cluster IP or primary IP family must
+	// change.  These fields are immutable, and recreating the service
+	// briefly interrupts resolution through its cluster IP, so the
+	// operator only reports that recreation is required unless this
+	// annotation is set.
+	AllowServiceRecreationAnnotation = "dns.operator.openshift.io/allow-service-recreation"
+
+	// PublishNotReadyAddressesAnnotation is the annotation on a DNS that,
+	// if set to "true", makes the DNS's service publish the addresses of
+	// dns pods that are not ready.  Queries can then reach pods as soon as
+	// they start, and keep reaching pods that fail their readiness probes,
+	// which trades correctness for availability during rollouts.
+	PublishNotReadyAddressesAnnotation = "dns.operator.openshift.io/publish-not-ready-addresses"
+
+	// UnsafeAllowUnpinnedImagesAnnotation is the annotation on a DNS that,
+	// if set to "true", allows the operator to roll out operand images
+	// that are not pinned by digest even though the cluster version
+	// requires pinned images.  Tag references may resolve to different
+	// images over time, so this bypasses the release image's integrity.
+	UnsafeAllowUnpinnedImagesAnnotation = "dns.operator.openshift.io/unsafe-allow-unpinned-images"
+
+	// ChaosBlackholeServersAnnotation is the annotation on a DNS that
+	// lists the names of servers in the DNS's spec.servers whose upstreams
+	// the operator replaces with an unroutable address so that queries for
+	// their zones time out.  The annotation is a comma-separated list and
+	// is ignored unless the operator's chaos hooks are enabled.
+	ChaosBlackholeServersAnnotation = "dns.operator.openshift.io/chaos-blackhole-servers"
+
+	// ChaosKillPodsAnnotation is the annotation on a DNS that requests the
+	// operator to delete one dns pod in each zone.  The value is an
+	// arbitrary token; the operator deletes pods once for each new token
+	// and records the token in the ChaosKillPodsHandledAnnotation
+	// annotation.  The annotation is ignored unless the operator's chaos
+	// hooks are enabled.
+	ChaosKillPodsAnnotation = "dns.operator.openshift.io/chaos-kill-pods"
+
+	// ChaosKillPodsHandledAnnotation is the annotation on a DNS in which
+	// the operator records the most recent ChaosKillPodsAnnotation token
+	// for which it has deleted pods.
+	ChaosKillPodsHandledAnnotation = "dns.operator.openshift.io/chaos-kill-pods-handled"
+
+	// ManagementStateAnnotation is the annotation on a DNS that sets the
+	// DNS's management state, because the DNS API has no managementState
+	// field.  If set to "Removed", the operator deletes the DNS's operands
+	// so that the cluster can use another resolver; if unset or set to
+	// "Managed", the operator manages the operands as usual.
+	ManagementStateAnnotation = "dns.operator.openshift.io/management-state"
+
+	// CustomCoreDNSImageAnnotation is the annotation on a DNS that sets a
+	// coredns image, such as one built with additional plugins, to use
+	// instead of the release's coredns image.  The operator still renders
+	// the Corefile and uses the image only after verifying that it has
+	// every plugin that the Corefile uses.  The image must have /bin/sh.
+	CustomCoreDNSImageAnnotation = "dns.operator.openshift.io/custom-coredns-image"
+
+	// ObserveDefaultUpstreamsAnnotation is the annotation on a DNS that, if
+	// set to "true", makes the operator read /etc/resolv.conf on a node of
+	// each group of nodes that run the DNS's pods and report the upstream
+	// resolvers in it, to which the default server block forwards queries,
+	// in a status condition.  Nodes are grouped by their rendered machine
+	// config.  The operator reads the files using short-lived pods.
+	ObserveDefaultUpstreamsAnnotation = "dns.operator.openshift.io/observe-default-upstreams"
+
+	// IdMDiscoveryAnnotation is the annotation on a DNS that, if set to
+	// "true", makes the operator discover on-cluster IdM (FreeIPA) DNS
+	// servers and forward the zones of their realms to them over
+	// DNS-over-TLS.  An IdM DNS server is a service with the
+	// IdMResolverLabel label and the IdMZonesAnnotation annotation in one
+	// of the namespaces that the DNS's IdMNamespacesAnnotation annotation
+	// lists, together with a secret in the openshift-dns namespace named
+	// "idm-<namespace>-<name>" after the service whose "ca.crt" key has the
+	// CA certificate that signed the server's certificate.  Only the zones
+	// in the zones that the DNS's IdMAllowedZonesAnnotation annotation
+	// lists are forwarded.  The namespaces, the zones, and the CA
+	// certificates are set by the cluster administrator so that users who
+	// can create services cannot take over the resolution of arbitrary
+	// names.
+	IdMDiscoveryAnnotation = "dns.operator.openshift.io/idm-discovery"
+
+	// IdMNamespacesAnnotation is the annotation on a DNS that lists the
+	// namespaces in which the operator discovers IdM DNS servers if the
+	// DNS has the IdMDiscoveryAnnotation annotation.  The value is a
+	// comma- or space-delimited list of namespaces.  If the DNS does not
+	// have the annotation, no IdM DNS servers are discovered.
+	IdMNamespacesAnnotation = "dns.operator.openshift.io/idm-namespaces"
+
+	// IdMAllowedZonesAnnotation is the annotation on a DNS that lists the
+	// zones that IdM DNS servers may serve.  A zone in an IdM DNS server's
+	// IdMZonesAnnotation annotation is forwarded to the server only if it
+	// is one of the listed zones or a subdomain of one.  The value is a
+	// comma- or space-delimited list of zones.  If the DNS does not have
+	// the annotation, no zones are forwarded to IdM DNS servers.
+	IdMAllowedZonesAnnotation = "dns.operator.openshift.io/idm-allowed-zones"
+
+	// IdMResolverLabel is the label that, if set to "true" on a service,
+	// marks the service as an IdM DNS server that serves DNS-over-TLS on
+	// its port named "dns-tls" or, if it has no such port, on port 853.
+	IdMResolverLabel = "dns.operator.openshift.io/idm-resolver"
+
+	// IdMZonesAnnotation is the annotation on an IdM DNS server's service
+	// that lists the zones of the IdM realm, such as "ipa.example.com".
+	// The value is a comma- or space-delimited list of zones.
+	IdMZonesAnnotation = "dns.operator.openshift.io/idm-zones"
+
+	// IdMTLSServerNameAnnotation is the annotation on an IdM DNS server's
+	// service that sets the name that the server's certificate must
+	// have.  The default is the service's name, "<name>.<namespace>.svc".
+	IdMTLSServerNameAnnotation = "dns.operator.openshift.io/idm-tls-server-name"
+
+	// FleetManagerAnnotation is the annotation on a DNS that marks the DNS
+	// as managed by a fleet management system, such as a GitOps or
+	// multi-cluster management controller.  The value is the field
+	// manager that the system uses to write the DNS.  The operator then
+	// applies only the spec and dns.operator.openshift.io annotations that
+	// the fleet manager set.  If other field managers own any of these
+	// fields, the operator ignores their local edits and keeps applying the
+	// configuration that the fleet manager last set, until the fleet
+	// manager takes ownership of the fields again, for example by applying
+	// its configuration with force.
+	FleetManagerAnnotation = "dns.operator.openshift.io/fleet-manager"
+
+	// ForwardingConfigMapAnnotation is the annotation on a DNS that names a
+	// configmap in the openshift-dns namespace with conditional forwarding
+	// zones in addition to the DNS's spec.servers, so that a DNS with
+	// hundreds of zones does not approach the size limit of the DNS and its
+	// zones can be kept in their own file.  The configmap's "zones" key has
+	// one zone per line, "<zone> <upstream> [<upstream>...]", where each
+	// <upstream> is as in a server's forwardPlugin, for example
+	// "corp.example.com 10.0.0.53 10.0.0.54:5353".  Text from "#" to the end
+	// of a line is a comment.  A zone that a server in spec.servers
+	// forwards, that another line has, or that overlaps the cluster domain
+	// is ignored, and the ForwardingConfigMapApplied condition reports the
+	// ignored zones and a hash of the configmap's zones so that a change
+	// can be matched to the configuration that applies it.
+	ForwardingConfigMapAnnotation = "dns.operator.openshift.io/forwarding-configmap"
+
+	// BlocklistsAnnotation is the annotation on a DNS that makes CoreDNS
+	// sinkhole the names in blocklists, answering A queries for them with
+	// 0.0.0.0 and AAAA queries with no records, for example, to keep
+	// workloads from resolving known malware or tracking domains.  The
+	// value is a JSON list of objects with a "name" field and either a
+	// "configMap" field, the name of a configmap in the openshift-dns
+	// namespace whose "blocklist" key has the list, or a "url" field, the
+	// http or https URL of a feed that serves the list, with an optional
+	// "refreshInterval" field, a duration between 5m and 168h that
+	// defaults to 24h, for example:
+	//
+	//	[{"name": "corp", "configMap": "corp-blocklist"}, {"name": "malware", "url": "https://feeds.example.com/malware.txt", "refreshInterval": "6h"}]
+	//
+	// A list has one name per line or is in the format of /etc/hosts, whose
+	// addresses are ignored; text from "#" to the end of a line is a
+	// comment.  Only the listed names are sinkholed, not their subdomains.
+	// The default server block answers the names, so names in the cluster
+	// domain, in the zones of other server blocks, or in the static hosts
+	// configmap are not sinkholed, and at most maxBlocklistNames names are.
+	// The operator fetches each feed when its refresh interval elapses,
+	// retries failed fetches every few minutes, and keeps the feed's last
+	// names in the dns's blocklist feeds configmap until a fetch succeeds;
+	// the BlocklistsCurrent condition reports invalid lists and feeds
+	// that have not been fetched within twice their refresh interval.
+	BlocklistsAnnotation = "dns.operator.openshift.io/blocklists"
+
+	// ZoneFilesAnnotation is the annotation on a DNS that makes CoreDNS
+	// serve zones authoritatively from zone files.  The value is a comma-
+	// or space-delimited list of "<zone>=<configmap>" entries, where
+	// <configmap> is the name of a configmap in the openshift-dns namespace
+	// whose "db" key has the zone's file in RFC 1035 master file format,
+	// for example "lab.example.com=lab-zone".  The zone file must have
+	// exactly one SOA record, at the zone's apex.  The operator copies the
+	// zone files into the dns's configmap, so CoreDNS serves the new
+	// contents of a zone file soon after its configmap changes.
+	ZoneFilesAnnotation = "dns.operator.openshift.io/zone-files"
+
+	// WarmCacheNamesAnnotation is the annotation on a DNS that lists names
+	// that a newly started dns pod resolves through its own CoreDNS before
+	// it reports ready, so that the pod's cache already has the cluster's
+	// most critical external names when it starts receiving queries, for
+	// example after a node reboots or during a rollout.  The value is a
+	// comma- or space-delimited list of domain names.  At most
+	// maxWarmCacheNames names are resolved.
+	WarmCacheNamesAnnotation = "dns.operator.openshift.io/warm-cache-names"
+
+	// SecondaryZonesAnnotation is the annotation on a DNS that makes
+	// CoreDNS serve zones that it transfers from the zones' primary
+	// servers using CoreDNS's secondary plugin.  The value is a JSON list
+	// of objects with "zone" and "primaries" fields, where "primaries"
+	// lists the addresses, "<ip>" or "<ip>:<port>", of the zone's primary
+	// servers, for example:
+	//
+	//	[{"zone": "corp.example.com", "primaries": ["192.0.2.53", "192.0.2.54:5353"]}]
+	//
+	// CoreDNS cannot authenticate zone transfers with TSIG, so the
+	// primaries must allow transfers from the addresses of the dns's
+	// pods.  The SecondaryZonesTransferred condition reports zones that
+	// the pods have not transferred.
+	SecondaryZonesAnnotation = "dns.operator.openshift.io/secondary-zones"
+
+	// ZoneTransfersAnnotation is the annotation on a DNS that lets external
+	// secondary servers transfer, with AXFR or IXFR, zones that CoreDNS
+	// serves from the zone files in the ZoneFilesAnnotation annotation,
+	// using CoreDNS's transfer plugin.  The value is a JSON list of
+	// objects with "zone", "to", and optional "tsigSecret" fields, where
+	// "to" lists the addresses, "<ip>" or "<ip>:<port>", of the
+	// secondaries that may transfer the zone and that CoreDNS notifies
+	// when the zone changes, and "tsigSecret" names a secret in the
+	// openshift-dns namespace whose "tsig.key" key has TSIG keys in the
+	// format that tsig-keygen writes, for example:
+	//
+	//	[{"zone": "lab.example.com", "to": ["192.0.2.10"], "tsigSecret": "lab-transfer-key"}]
+	//
+	// "tsigSecret" may also be a SecretProviderReferencePrefix reference.
+	// If "tsigSecret" is set, transfers must be signed with one of the
+	// keys; this requires the tsig plugin, which the release's coredns
+	// image does not have.  The template plugin cannot transfer zones, so
+	// synthesized records cannot be transferred.  The secondaries must
+	// reach the dns's pods, for example through the HostPortAnnotation
+	// annotation's port, from an allowed address.  The ZoneTransfersAllowed
+	// condition reports the entries that are ignored.
+	ZoneTransfersAnnotation = "dns.operator.openshift.io/zone-transfers"
+
+	// SearchSuffixAnnotation is the annotation on a DNS that sets a domain
+	// that CoreDNS appends to single-label names, such as "intranet.",
+	// before it resolves them, like resolvers that append a domain suffix
+	// on the server side.  Pods append the suffixes in their resolv.conf
+	// search path first, so CoreDNS appends the suffix only to names that
+	// reach it unqualified, for example from pods that use dnsPolicy
+	// "None" or from clients outside the cluster.  The value is a single
+	// domain, for example "corp.example.com"; CoreDNS cannot try a list of
+	// suffixes in turn, and it appends the suffix to queries for top-level
+	// domains such as "com." too.  The default server block appends the suffix
+	// before it applies the dns's rewrite rules, so the suffix must not be
+	// in a zone that another server block serves.
+	SearchSuffixAnnotation = "dns.operator.openshift.io/search-suffix"
+
+	// DNSOverTLSAnnotation is the annotation on a DNS that makes CoreDNS
+	// serve DNS-over-TLS on port 853 of the DNS's service.  The value is
+	// "service-ca", to use the serving certificate that the service CA
+	// operator generates for the DNS's service, the name of a secret in the
+	// operand namespace with tls.crt and tls.key keys, or a
+	// SecretProviderReferencePrefix reference.  The dns pods restart when
+	// the certificate changes.  The DNSOverTLSAvailable condition reports
+	// whether the certificate is usable.
+	DNSOverTLSAnnotation = "dns.operator.openshift.io/dns-over-tls"
+
+	// DNSOverHTTPSAnnotation is the annotation on a DNS that makes CoreDNS
+	// serve DNS-over-HTTPS at https://<service>/dns-query on port 443 of
+	// the DNS's service.  The value is "service-ca" or the name of a
+	// secret, as for DNSOverTLSAnnotation; both annotations may select
+	// the same certificate.  The DNSOverHTTPSAvailable condition reports
+	// whether the certificate is usable.
+	DNSOverHTTPSAnnotation = "dns.operator.openshift.io/dns-over-https"
+
+	// QueryPrivacyAnnotation is the annotation on a DNS that keeps query
+	// names, which may be personal data, out of the DNS's logs and
+	// metrics.  The value is "hash", to replace the query names in metric
+	// labels with hashes, or "truncate", to replace them with their last
+	// two labels.  In either mode, CoreDNS logs counts of errors by kind
+	// instead of each error with its query name, because CoreDNS cannot
+	// rewrite the names that it logs.
+	QueryPrivacyAnnotation = "dns.operator.openshift.io/query-privacy"
+
+	// MetricsExcludedZonesAnnotation is the annotation on a DNS that
+	// keeps the queries of high-sensitivity zones out of CoreDNS's
+	// metrics.  The value is a comma-separated list of the names of
+	// servers in the DNS's spec.servers and of the zones of the DNS's zone
+	// files and secondary zones.  Their server blocks omit the prometheus
+	// plugin, so CoreDNS neither counts their queries nor labels metrics
+	// with their zones, while the other server blocks keep it.
+	MetricsExcludedZonesAnnotation = "dns.operator.openshift.io/metrics-excluded-zones"
+
+	// ViewsAnnotation is the annotation on a DNS that makes CoreDNS answer
+	// queries for some zones differently depending on the clients'
+	// addresses, for example to resolve internal names only for
+	// infrastructure subnets.  The value is a JSON list of objects with
+	// "name", "sourceCIDRs", and "zones" fields and either an "upstreams"
+	// field, the plain DNS upstreams to which the view forwards queries,
+	// or a "response" field, "NXDOMAIN" or "REFUSED", with which the view
+	// answers them, for example:
+	//
+	//	[{"name": "infra", "sourceCIDRs": ["10.0.0.0/16"], "zones": ["corp.example.com"], "upstreams": ["10.0.0.53"]},
+	//	 {"name": "workloads", "sourceCIDRs": ["10.128.0.0/14"], "zones": ["corp.example.com"], "response": "REFUSED"}]
+	//
+	// Queries from other clients are answered as if the views did not
+	// exist.  CoreDNS sees the addresses of the pods and nodes that query
+	// it, so the CIDRs are those of the cluster, host, or node networks.
+	// A query that several views select is answered by the first of
+	// them.  The ViewsApplied condition reports invalid views, which are
+	// ignored.
+	ViewsAnnotation = "dns.operator.openshift.io/views"
+
+	// QueryACLsAnnotation is the annotation on a DNS that configures
+	// CoreDNS to refuse or drop the queries from clients in the given
+	// networks, for example, to keep host-network pods in some node
+	// subnets from using the cluster's DNS.  The value is a JSON list of
+	// objects with "name" and "sourceCIDRs" fields and an "action" field,
+	// "Refuse", with which CoreDNS answers the clients' queries with
+	// REFUSED, or "Drop", with which it does not answer them, for example:
+	//
+	//	[{"name": "legacy-hosts", "sourceCIDRs": ["10.0.32.0/20"], "action": "Drop"}]
+	//
+	// Every server block has the ACLs, in the annotation's order, so the
+	// first ACL that matches a query's source applies.  An ACL may not
+	// cover a whole cluster network, which would refuse every pod, or
+	// overlap the service network or the loopback networks, from which
+	// the dns pods forward their encrypted listeners' queries.  The
+	// QueryACLsApplied condition reports invalid ACLs, which are ignored.
+	QueryACLsAnnotation = "dns.operator.openshift.io/query-acls"
+
+	// ResponseRateLimitingAnnotation is the annotation on a DNS that
+	// enables CoreDNS's response rate limiting so that a misbehaving
+	// workload cannot exhaust CoreDNS with a query storm.  CoreDNS counts
+	// the responses to each client network and, once a network exceeds
+	// its allowance over the window, drops its queries or answers some of
+	// them truncated, so legitimate clients retry over TCP.  The value is
+	// a comma- or space-delimited list of <setting>=<value> entries, where
+	// <setting> is "window", a duration of whole seconds between 1s and
+	// 1h, "responses-per-second", "nodata-per-second",
+	// "nxdomains-per-second", "errors-per-second", or
+	// "requests-per-second", the allowances per client network, between 0,
+	// which disables the limit, and 100000, "ipv4-prefix-length" or
+	// "ipv6-prefix-length", the lengths of the client networks,
+	// "slip-ratio", between 0 and 10, the ratio of limited queries that
+	// are answered truncated rather than dropped, or "report-only", which,
+	// if "true", makes CoreDNS only count the queries that exceed the
+	// allowances, for example "window=15s,responses-per-second=50".  The
+	// defaults are 15s, 100 responses per second, no other limits, /24,
+	// /56, 2, and false.  Every server block has the limits; the queries
+	// of the encrypted listeners reach the other server blocks from the
+	// loopback address, so they share one allowance there.  The rrl plugin
+	// is not in the release's coredns image, so the limits apply only if
+	// the DNS's CustomCoreDNSImageAnnotation annotation sets an image with
+	// the plugin; the ResponseRateLimitingConfigured condition reports
+	// whether they apply, and the CoreDNSResponseRateLimited alert fires
+	// while CoreDNS limits a client network.  Invalid entries are ignored.
+	ResponseRateLimitingAnnotation = "dns.operator.openshift.io/response-rate-limiting"
+
+	// UpstreamTLSAnnotation is the annotation on a DNS that configures
+	// the TLS client of the DNS-over-TLS upstreams, "tls://<ip>[:<port>]",
+	// of the DNS's servers.  The value is a JSON list of objects with a
+	// "server" field, the name of a server in the DNS's spec.servers, a
+	// "clientCertificateSecret" field, the name of a secret in the operand
+	// namespace, and an optional "serverName" field, the name that the
+	// upstreams' certificates must have, for example:
+	//
+	//	[{"server": "corp", "clientCertificateSecret": "corp-dns-client", "serverName": "dns.corp.example.com"}]
+	//
+	// CoreDNS authenticates to the server's DNS-over-TLS upstreams with the
+	// certificate and key in the secret's tls.crt and tls.key keys and, if
+	// the secret has a ca.crt key, verifies the upstreams' certificates
+	// with the CA certificates in it instead of the system's.  The dns pods
+	// restart when the secret changes, so rotating the client certificate
+	// rolls out like any other change to the daemonset.  An entry may
+	// also have a "caBundleConfigMap" field, the name of a configmap in
+	// the operand namespace whose "ca-bundle.crt" key has the CA
+	// certificates that verify the upstreams' certificates instead of the
+	// secret's; the operator copies the bundle into the dns's configmap,
+	// so CoreDNS reloads its configuration with a rotated bundle without
+	// restarting, and the UpstreamCABundlesValid condition reports
+	// bundles that are invalid or whose certificates all expire soon.  If
+	// the secret or the bundle is missing or invalid, the server's
+	// DNS-over-TLS upstreams are omitted and the UpstreamTLSConfigured
+	// condition reports why.  The secret and the configmap may instead be
+	// given as "<namespace>/<name>" to refer to a secret or configmap in
+	// another namespace that grants the DNS access with the
+	// UpstreamTLSGrantAnnotation annotation; the operator copies it into
+	// the operand namespace.  The secret may also be a
+	// SecretProviderReferencePrefix reference.
+	UpstreamTLSAnnotation = "dns.operator.openshift.io/upstream-tls"
+
+	// UpstreamTLSGrantAnnotation is the annotation on a secret or configmap
+	// outside the operand namespace that lets DNSes use it as a client
+	// certificate secret or CA bundle configmap in their
+	// UpstreamTLSAnnotation annotation.  The value is a comma- or
+	// space-delimited list of the names of the DNSes, for example
+	// "default".  The operator copies the secret or configmap into the
+	// operand namespace as "upstream-tls.<namespace>.<name>", updates the
+	// copy every few minutes if the original changed and is still valid,
+	// keeps the last valid copy if the original becomes invalid, and
+	// deletes the copy when the original is deleted, the grant is
+	// revoked, or no DNS refers to it.  The UpstreamTLSReferencesGranted
+	// condition reports the references that cannot be copied.
+	//
+	// The operator cannot read secrets outside the operand namespace, so
+	// the owner of a secret's namespace must also grant the operator's
+	// service account, openshift-dns-operator/dns-operator, get access to
+	// the secret with a role that lists the secret in its resourceNames
+	// and a role binding.  Revoking either grant deletes the copy.
+	UpstreamTLSGrantAnnotation = "dns.operator.openshift.io/upstream-tls-grant"
+
+	// SecretProviderReferencePrefix is the prefix of a credential
+	// reference that names a SecretProviderClass in the operand namespace
+	// instead of a secret, for clusters whose DNS credentials must not be
+	// stored in etcd.  The DNSOverTLSAnnotation and DNSOverHTTPSAnnotation
+	// annotations, the "clientCertificateSecret" fields of the
+	// UpstreamTLSAnnotation annotation, and the "tsigSecret" fields of the
+	// ZoneTransfersAnnotation annotation accept such a reference, for
+	// example "csi:corp-dns-client".  The dns pods mount the
+	// SecretProviderClass's objects with the Secrets Store CSI driver, so
+	// the class must write them under the file names that a secret would
+	// have as keys, such as tls.crt and tls.key; a client certificate's
+	// ca.crt is not used.  The operator cannot read the credentials, so it
+	// checks only that the class exists, and the dns pods restart to load
+	// rotated credentials only when the class changes or the pods are
+	// otherwise restarted.
+	SecretProviderReferencePrefix = "csi:"
+
+	// HostPortAnnotation is the annotation on a DNS that makes CoreDNS
+	// additionally answer queries on the given port of the IP addresses of
+	// the nodes that run the DNS's pods, over both UDP and TCP, without
+	// running the pods in the host network.  Host-level agents, such as
+	// node problem detectors, can then query cluster DNS at
+	// "<node-ip>:<port>" with simple firewall rules.  The value is a port
+	// from minDNSHostPort to 65535 that no other pod on the nodes uses as a
+	// host port, for example "5354".
+	HostPortAnnotation = "dns.operator.openshift.io/host-port"
+
 	// DefaultOperandNamespace is the default namespace name of operands.
 	DefaultOperandNamespace = "openshift-dns"
 
@@ -28,6 +669,42 @@ const (
 
 	// DefaultDNSName is the default name of dns resource.
 	DefaultDNSName = "default"
+
+	// CoreDNSPort is the port on which CoreDNS serves DNS.
+	CoreDNSPort = 5353
+
+	// CoreDNSMetricsPort is the port on which CoreDNS serves metrics.
+	CoreDNSMetricsPort = 9153
+
+	// CoreDNSTLSPort is the port on which CoreDNS serves DNS-over-TLS.
+	CoreDNSTLSPort = 8853
+
+	// DNSOverTLSServicePort is the port of the DNS service on which it
+	// serves DNS-over-TLS.
+	DNSOverTLSServicePort = 853
+
+	// CoreDNSHTTPSPort is the port on which CoreDNS serves DNS-over-HTTPS.
+	CoreDNSHTTPSPort = 8443
+
+	// DNSOverHTTPSServicePort is the port of the DNS service on which it
+	// serves DNS-over-HTTPS.
+	DNSOverHTTPSServicePort = 443
+
+	// OperatorMetricsServiceName is the name of the service in the
+	// operator's namespace that exposes the operator's metrics endpoints.
+	OperatorMetricsServiceName = "metrics"
+
+	// MetricsProxyPort is the port on which the operator's metrics proxy
+	// listens when metrics are authenticated with TokenReview.
+	MetricsProxyPort = 9394
+
+	// ConsoleAPIPort is the port on which the operator serves the console
+	// plugin endpoints when they are enabled.
+	ConsoleAPIPort = 9395
+
+	// DNSHealthPort is the port on which the operator serves the dns
+	// health endpoint when it is enabled.
+	DNSHealthPort = 9396
 )
 
 // DNSClusterOperatorName returns the namespaced name of the ClusterOperator
@@ -65,6 +742,24 @@ func DNSDaemonSetPodSelector(dns *operatorv1.DNS) *metav1.LabelSelector {
 	}
 }
 
+// AllDNSDaemonSetPodsSelector returns a label selector that matches the pods
+// of every dns daemonset.
+func AllDNSDaemonSetPodsSelector() *metav1.LabelSelector {
+	return &metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{{
+			Key:      controllerDaemonSetLabel,
+			Operator: metav1.LabelSelectorOpExists,
+		}},
+	}
+}
+
+// DNSNameForDaemonSetPod returns the name of the dns whose daemonset has a pod
+// with the given labels, or the empty string if the labels are not those of a
+// dns daemonset's pod.
+func DNSNameForDaemonSetPod(podLabels map[string]string) string {
+	return podLabels[controllerDaemonSetLabel]
+}
+
 func DNSServiceName(dns *operatorv1.DNS) types.NamespacedName {
 	return types.NamespacedName{
 		Namespace: "openshift-dns",
@@ -72,6 +767,15 @@ func DNSServiceName(dns *operatorv1.DNS) types.NamespacedName {
 	}
 }
 
+// DNSNetworkPolicyName returns the namespaced name of the network policy that
+// restricts access to the given dns's CoreDNS metrics port.
+func DNSNetworkPolicyName(dns *operatorv1.DNS) types.NamespacedName {
+	return types.NamespacedName{
+		Namespace: DefaultOperandNamespace,
+		Name:      "dns-" + dns.Name + "-metrics",
+	}
+}
+
 func DNSConfigMapName(dns *operatorv1.DNS) types.NamespacedName {
 	return types.NamespacedName{
 		Namespace: "openshift-dns",
@@ -86,10 +790,173 @@ func DNSServiceMonitorName(dns *operatorv1.DNS) types.NamespacedName {
 	}
 }
 
+// DNSImageVerificationPodName returns the namespaced name for the pod that
+// verifies that new operand images can be pulled for the given dns.
+func DNSImageVerificationPodName(dns *operatorv1.DNS) types.NamespacedName {
+	return types.NamespacedName{
+		Namespace: DefaultOperandNamespace,
+		Name:      "dns-" + dns.Name + "-image-verification",
+	}
+}
+
+// DNSPluginCheckPodName returns the namespaced name for the pod that lists the
+// plugins of the custom coredns image for the given dns.
+func DNSPluginCheckPodName(dns *operatorv1.DNS) types.NamespacedName {
+	return types.NamespacedName{
+		Namespace: DefaultOperandNamespace,
+		Name:      "dns-" + dns.Name + "-plugin-check",
+	}
+}
+
+// DNSQUICCheckPodName returns the namespaced name for the pod that checks
+// whether the coredns image of the given dns can forward queries over QUIC.
+func DNSQUICCheckPodName(dns *operatorv1.DNS) types.NamespacedName {
+	return types.NamespacedName{
+		Namespace: DefaultOperandNamespace,
+		Name:      "dns-" + dns.Name + "-quic-check",
+	}
+}
+
+// DNSTopologySnapshotConfigMapName returns the namespaced name for the
+// configmap with the machine-readable snapshot of the given dns's topology.
+func DNSTopologySnapshotConfigMapName(dns *operatorv1.DNS) types.NamespacedName {
+	return types.NamespacedName{
+		Namespace: DefaultOperandNamespace,
+		Name:      "dns-" + dns.Name + "-topology",
+	}
+}
+
+// DNSFleetConfigurationConfigMapName returns the namespaced name for the
+// configmap with the configuration of the given dns that its fleet manager
+// last set.
+func DNSFleetConfigurationConfigMapName(dns *operatorv1.DNS) types.NamespacedName {
+	return types.NamespacedName{
+		Namespace: DefaultOperandNamespace,
+		Name:      "dns-" + dns.Name + "-fleet-configuration",
+	}
+}
+
+// DNSBlocklistFeedsConfigMapName returns the namespaced name for the configmap
+// in which the operator keeps the names that it last fetched from the given
+// dns's blocklist feeds.
+func DNSBlocklistFeedsConfigMapName(dns *operatorv1.DNS) types.NamespacedName {
+	return types.NamespacedName{
+		Namespace: DefaultOperandNamespace,
+		Name:      "dns-" + dns.Name + "-blocklist-feeds",
+	}
+}
+
+// DNSTrustedCABundleConfigMapName returns the namespaced name for the configmap
+// into which the cluster network operator injects the cluster's trusted CA
+// bundle, with which the given dns verifies its DNS-over-HTTPS upstreams.
+func DNSTrustedCABundleConfigMapName(dns *operatorv1.DNS) types.NamespacedName {
+	return types.NamespacedName{
+		Namespace: DefaultOperandNamespace,
+		Name:      "dns-" + dns.Name + "-trusted-ca-bundle",
+	}
+}
+
+// DNSNodeHealthConfigMapName returns the namespaced name for the configmap with
+// the health of the dns pod on each node, for all dnses.
+func DNSNodeHealthConfigMapName() types.NamespacedName {
+	return types.NamespacedName{
+		Namespace: DefaultOperandNamespace,
+		Name:      "dns-node-health",
+	}
+}
+
+// Suffixes of the names of the configmaps that the cluster administrator
+// creates for a dns, which follow "dns-" and the dns's name.
+const (
+	dnsRewriteRulesConfigMapSuffix       = "-rewrite-rules"
+	dnsStaticHostsConfigMapSuffix        = "-hosts"
+	dnsSynthesizedRecordsConfigMapSuffix = "-synthesized-records"
+	dnsCustomCorefileConfigMapSuffix     = "-custom-corefile"
+)
+
+// DNSRewriteRulesConfigMapName returns the namespaced name for the configmap
+// with the rewrite rules that the cluster administrator defines for the given
+// dns.  The operator reads the configmap but does not create it.
+func DNSRewriteRulesConfigMapName(dns *operatorv1.DNS) types.NamespacedName {
+	return types.NamespacedName{
+		Namespace: DefaultOperandNamespace,
+		Name:      "dns-" + dns.Name + dnsRewriteRulesConfigMapSuffix,
+	}
+}
+
+// DNSStaticHostsConfigMapName returns the namespaced name for the configmap
+// with the static host entries that the cluster administrator defines for the
+// given dns.  The operator reads the configmap but does not create it.
+func DNSStaticHostsConfigMapName(dns *operatorv1.DNS) types.NamespacedName {
+	return types.NamespacedName{
+		Namespace: DefaultOperandNamespace,
+		Name:      "dns-" + dns.Name + dnsStaticHostsConfigMapSuffix,
+	}
+}
+
+// DNSSynthesizedRecordsConfigMapName returns the namespaced name for the
+// configmap with the synthesized records that the cluster administrator defines
+// for the given dns.  The operator reads the configmap but does not create it.
+func DNSSynthesizedRecordsConfigMapName(dns *operatorv1.DNS) types.NamespacedName {
+	return types.NamespacedName{
+		Namespace: DefaultOperandNamespace,
+		Name:      "dns-" + dns.Name + dnsSynthesizedRecordsConfigMapSuffix,
+	}
+}
+
+// DNSCustomCorefileConfigMapName returns the namespaced name for the configmap
+// with the custom Corefile snippets that the cluster administrator defines for
+// the given dns.  Each key whose name ends with ".server" has server blocks
+// that the Corefile has after the generated server blocks, and each key whose
+// name ends with ".override" has plugins that the default server block has in
+// addition to the generated plugins.  The operator checks that the snippets do
+// not conflict with the generated Corefile but does not validate the plugins'
+// configuration.  The operator reads the configmap but does not create it.
+func DNSCustomCorefileConfigMapName(dns *operatorv1.DNS) types.NamespacedName {
+	return types.NamespacedName{
+		Namespace: DefaultOperandNamespace,
+		Name:      "dns-" + dns.Name + dnsCustomCorefileConfigMapSuffix,
+	}
+}
+
+// dnsForAdministratorConfigMap returns the name of the dns for which the
+// cluster administrator creates the configmap with the given namespace and
+// name, or the empty string if the configmap is not such a configmap.
+func dnsForAdministratorConfigMap(namespace, name string) string {
+	if namespace != DefaultOperandNamespace {
+		return ""
+	}
+	for _, suffix := range []string{dnsRewriteRulesConfigMapSuffix, dnsStaticHostsConfigMapSuffix, dnsSynthesizedRecordsConfigMapSuffix, dnsCustomCorefileConfigMapSuffix} {
+		if strings.HasPrefix(name, "dns-") && strings.HasSuffix(name, suffix) && len(name) > len("dns-")+len(suffix) {
+			return strings.TrimSuffix(strings.TrimPrefix(name, "dns-"), suffix)
+		}
+	}
+	return ""
+}
+
+// DNSTunedName returns the namespaced name for the Tuned resource that tunes
+// the nodes that run the given dns's pods.
+func DNSTunedName(dns *operatorv1.DNS) types.NamespacedName {
+	return types.NamespacedName{
+		Namespace: tunedNamespace,
+		Name:      "openshift-dns-" + dns.Name,
+	}
+}
+
 func DNSMetricsSecretName(dns *operatorv1.DNS) string {
 	return "dns-" + dns.Name + "-metrics-tls"
 }
 
+// DNSMetricsCertificateName returns the namespaced name of the cert-manager
+// Certificate, and of the secret that cert-manager stores it in, for the
+// dns's metrics serving certificate.
+func DNSMetricsCertificateName(dns *operatorv1.DNS) types.NamespacedName {
+	return types.NamespacedName{
+		Namespace: DefaultOperandNamespace,
+		Name:      "dns-" + dns.Name + "-metrics-certificate",
+	}
+}
+
 // NodeResolverDaemonSetName returns the namespaced name for the node resolver
 // daemonset.
 func NodeResolverDaemonSetName() types.NamespacedName {
@@ -99,6 +966,15 @@ func NodeResolverDaemonSetName() types.NamespacedName {
 	}
 }
 
+// NodeResolverConfigMapName returns the namespaced name for the configmap with
+// the hosts file entries that the node resolver adds to /etc/hosts.
+func NodeResolverConfigMapName() types.NamespacedName {
+	return types.NamespacedName{
+		Namespace: DefaultOperandNamespace,
+		Name:      "node-resolver-hosts",
+	}
+}
+
 // NodeResolverDaemonSetPodSelector is label selector for node resolver pods.
 func NodeResolverDaemonSetPodSelector() *metav1.LabelSelector {
 	return &metav1.LabelSelector{
@@ -0,0 +1,39 @@
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ListNodes lists the nodes that match the given options using the given
+// reader and returns each node once.  The operator's cache is a
+// multi-namespace cache, which returns each cluster-scoped object once per
+// namespace that it covers, so callers that list nodes through the cache must
+// use this function rather than listing them directly.
+func ListNodes(ctx context.Context, reader client.Reader, opts ...client.ListOption) ([]corev1.Node, error) {
+	nodeList := &corev1.NodeList{}
+	if err := reader.List(ctx, nodeList, opts...); err != nil {
+		return nil, err
+	}
+	return distinctNodes(nodeList.Items), nil
+}
+
+// distinctNodes returns the given nodes, in order, without the nodes whose
+// names an earlier node has.
+func distinctNodes(nodes []corev1.Node) []corev1.Node {
+	seen := sets.NewString()
+	distinct := make([]corev1.Node, 0, len(nodes))
+	for _, node := range nodes {
+		if seen.Has(node.Name) {
+			continue
+		}
+		seen.Insert(node.Name)
+		distinct = append(distinct, node)
+	}
+	return distinct
+}
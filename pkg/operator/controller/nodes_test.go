@@ -0,0 +1,45 @@
+package controller
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// nodeListReader is a client.Reader that lists the given nodes.
+type nodeListReader struct {
+	client.Reader
+	nodes []corev1.Node
+}
+
+func (r *nodeListReader) List(_ context.Context, list client.ObjectList, _ ...client.ListOption) error {
+	list.(*corev1.NodeList).Items = r.nodes
+	return nil
+}
+
+// TestListNodes verifies that ListNodes returns each node once, in the order
+// in which the reader first returns it, when the reader returns each node once
+// per namespace as the operator's multi-namespace cache does.
+func TestListNodes(t *testing.T) {
+	node := func(name string) corev1.Node {
+		return corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	}
+	reader := &nodeListReader{nodes: []corev1.Node{node("b"), node("a"), node("b"), node("a"), node("c")}}
+	nodes, err := ListNodes(context.TODO(), reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, node := range nodes {
+		names = append(names, node.Name)
+	}
+	if expected := []string{"b", "a", "c"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected nodes %q, got %q", expected, names)
+	}
+}
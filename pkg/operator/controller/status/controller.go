@@ -1,6 +1,7 @@
 package status
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"strings"
@@ -10,21 +11,28 @@ import (
 
 	configv1 "github.com/openshift/api/config/v1"
 	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/conditions"
 
 	operatorconfig "github.com/openshift/cluster-dns-operator/pkg/operator/config"
 	operatorcontroller "github.com/openshift/cluster-dns-operator/pkg/operator/controller"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/statusbreaker"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	utilclock "k8s.io/apimachinery/pkg/util/clock"
 
 	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 )
@@ -51,6 +59,8 @@ type reconciler struct {
 
 	client client.Client
 	cache  cache.Cache
+	// metrics reports the conditions that the reconciler computes.
+	metrics *conditionMetrics
 }
 
 // New creates the status controller. This is the controller that handles all
@@ -60,17 +70,42 @@ type reconciler struct {
 // to compute the operator status.
 func New(mgr manager.Manager, config operatorconfig.Config) (controller.Controller, error) {
 	reconciler := &reconciler{
-		Config: config,
-		client: mgr.GetClient(),
-		cache:  mgr.GetCache(),
-	}
-	c, err := controller.New(controllerName, mgr, controller.Options{Reconciler: reconciler})
+		Config:  config,
+		client:  mgr.GetClient(),
+		cache:   mgr.GetCache(),
+		metrics: &conditionMetrics{},
+	}
+	if err := metrics.Registry.Register(reconciler.metrics); err != nil {
+		return nil, fmt.Errorf("failed to register condition metrics: %w", err)
+	}
+	c, err := controller.New(controllerName, mgr, controller.Options{
+		Reconciler:              reconciler,
+		MaxConcurrentReconciles: config.StatusControllerMaxConcurrentReconciles,
+	})
 	if err != nil {
 		return nil, err
 	}
 	if err := c.Watch(&source.Kind{Type: &operatorv1.DNS{}}, &handler.EnqueueRequestForObject{}); err != nil {
 		return nil, err
 	}
+	// The status extension summarizes the dns's daemonset, configmap,
+	// and node coverage, so refresh it when these change.
+	toDefaultDNS := handler.EnqueueRequestsFromMapFunc(func(_ client.Object) []reconcile.Request {
+		return []reconcile.Request{{NamespacedName: operatorcontroller.DefaultDNSNamespaceName()}}
+	})
+	if err := c.Watch(&source.Kind{Type: &appsv1.DaemonSet{}}, toDefaultDNS); err != nil {
+		return nil, err
+	}
+	if err := c.Watch(&source.Kind{Type: &corev1.ConfigMap{}}, toDefaultDNS); err != nil {
+		return nil, err
+	}
+	if err := c.Watch(&source.Kind{Type: &corev1.Node{}}, toDefaultDNS, predicate.Funcs{
+		// Node status updates are frequent and do not affect the
+		// node count.
+		UpdateFunc: func(_ event.UpdateEvent) bool { return false },
+	}); err != nil {
+		return nil, err
+	}
 	return c, nil
 }
 
@@ -148,15 +183,45 @@ func (r *reconciler) Reconcile(ctx context.Context, request reconcile.Request) (
 		computeOperatorAvailableCondition(state.haveDNS, &state.dns),
 		operatorProgressingCondition,
 		computeOperatorDegradedCondition(state.haveDNS, &state.dns),
+		computeOperatorUpgradeableCondition(state.haveDNS, &state.dns),
 	)
+	if state.haveDNS {
+		r.metrics.update(co.Status.Conditions, &state.dns)
+	} else {
+		r.metrics.update(co.Status.Conditions, nil)
+	}
 	co.Status.Versions = r.computeOperatorStatusVersions(
 		&operatorProgressingCondition,
 		oldStatus.Versions,
 		newVersions,
 	)
 
+	if state.haveDNS {
+		var cm *corev1.ConfigMap
+		if state.haveConfigMap {
+			cm = &state.configMap
+		}
+		var ds *appsv1.DaemonSet
+		if state.haveDaemonSet {
+			ds = &state.daemonSet
+		}
+		extension, err := encodeDNSInsights(computeDNSInsights(&state.dns, cm, ds, state.nodeCount))
+		if err != nil {
+			return reconcile.Result{}, fmt.Errorf("failed to encode dns insights: %w", err)
+		}
+		co.Status.Extension = runtime.RawExtension{Raw: extension}
+	} else {
+		co.Status.Extension = runtime.RawExtension{}
+	}
+
 	if !operatorStatusesEqual(*oldStatus, co.Status) {
 		if err := r.client.Status().Update(ctx, co); err != nil {
+			// Returning an error would retry immediately, so
+			// back off until status writes resume if the API
+			// server is overloaded.
+			if retryAfter, ok := statusbreaker.RetryAfter(err); ok {
+				return reconcile.Result{RequeueAfter: retryAfter}, nil
+			}
 			return reconcile.Result{}, fmt.Errorf("failed to update clusteroperator %q: %w", name.Name, err)
 		}
 	}
@@ -206,6 +271,11 @@ type operatorState struct {
 	namespace     corev1.Namespace
 	haveDNS       bool
 	dns           operatorv1.DNS
+	haveConfigMap bool
+	configMap     corev1.ConfigMap
+	haveDaemonSet bool
+	daemonSet     appsv1.DaemonSet
+	nodeCount     int
 }
 
 // getOperatorState gets and returns the resources necessary to compute the
@@ -235,6 +305,29 @@ func (r *reconciler) getOperatorState() (operatorState, error) {
 		state.haveDNS = true
 		state.dns = dnsList.Items[0]
 	}
+	if !state.haveDNS {
+		return state, nil
+	}
+
+	if err := r.cache.Get(context.TODO(), operatorcontroller.DNSConfigMapName(&state.dns), &state.configMap); err != nil {
+		if !errors.IsNotFound(err) {
+			return state, fmt.Errorf("failed to get configmap for dns %q: %w", state.dns.Name, err)
+		}
+	} else {
+		state.haveConfigMap = true
+	}
+	if err := r.cache.Get(context.TODO(), operatorcontroller.DNSDaemonSetName(&state.dns), &state.daemonSet); err != nil {
+		if !errors.IsNotFound(err) {
+			return state, fmt.Errorf("failed to get daemonset for dns %q: %w", state.dns.Name, err)
+		}
+	} else {
+		state.haveDaemonSet = true
+	}
+	nodes, err := operatorcontroller.ListNodes(context.TODO(), r.cache)
+	if err != nil {
+		return state, fmt.Errorf("failed to list nodes: %w", err)
+	}
+	state.nodeCount = len(nodes)
 
 	return state, nil
 }
@@ -253,13 +346,29 @@ func (r *reconciler) computeOperatorStatusVersions(operatorProgressingCondition
 
 // checkDNSAvailable checks if the dns is available.
 func checkDNSAvailable(dns *operatorv1.DNS) bool {
-	for _, c := range dns.Status.Conditions {
-		if c.Type == operatorv1.OperatorStatusTypeAvailable && c.Status == operatorv1.ConditionTrue {
-			return true
+	return conditions.IsAvailable(dns.Status.Conditions)
+}
+
+// computeOperatorUpgradeableCondition computes the operator's current
+// Upgradeable status state.  The operator is not upgradeable while the dns uses
+// deprecated fields that the operator could not migrate, because a later
+// release may no longer accept them.
+func computeOperatorUpgradeableCondition(haveDNS bool, dns *operatorv1.DNS) configv1.ClusterOperatorStatusCondition {
+	if haveDNS {
+		if cond := conditions.FindCondition(dns.Status.Conditions, operatorcontroller.DNSDeprecatedFieldsMigratedConditionType); cond != nil && cond.Status == operatorv1.ConditionFalse {
+			return configv1.ClusterOperatorStatusCondition{
+				Type:    configv1.OperatorUpgradeable,
+				Status:  configv1.ConditionFalse,
+				Reason:  conditions.ReasonDeprecatedFieldsInUse,
+				Message: fmt.Sprintf("DNS %s uses deprecated fields: %s", dns.Name, cond.Message),
+			}
 		}
 	}
-
-	return false
+	return configv1.ClusterOperatorStatusCondition{
+		Type:   configv1.OperatorUpgradeable,
+		Status: configv1.ConditionTrue,
+		Reason: conditions.ReasonAsExpected,
+	}
 }
 
 // computeOperatorDegradedCondition computes the operator's current Degraded status state.
@@ -268,29 +377,23 @@ func computeOperatorDegradedCondition(haveDNS bool, dns *operatorv1.DNS) configv
 		return configv1.ClusterOperatorStatusCondition{
 			Type:    configv1.OperatorDegraded,
 			Status:  configv1.ConditionTrue,
-			Reason:  "DNSDoesNotExist",
+			Reason:  conditions.ReasonDNSDoesNotExist,
 			Message: `DNS "default" does not exist.`,
 		}
 	}
 
-	var degraded bool
-	for _, cond := range dns.Status.Conditions {
-		if cond.Type == operatorv1.OperatorStatusTypeDegraded && cond.Status == operatorv1.ConditionTrue {
-			degraded = true
-		}
-	}
-	if degraded {
+	if conditions.IsDegraded(dns.Status.Conditions) {
 		return configv1.ClusterOperatorStatusCondition{
 			Type:    configv1.OperatorDegraded,
 			Status:  configv1.ConditionTrue,
-			Reason:  "DNSDegraded",
+			Reason:  conditions.ReasonDNSDegraded,
 			Message: fmt.Sprintf("DNS %s is degraded", dns.Name),
 		}
 	}
 	return configv1.ClusterOperatorStatusCondition{
 		Type:   configv1.OperatorDegraded,
 		Status: configv1.ConditionFalse,
-		Reason: "DNSNotDegraded",
+		Reason: conditions.ReasonDNSNotDegraded,
 	}
 }
 
@@ -304,7 +407,7 @@ func computeOperatorProgressingCondition(haveDNS bool, dns *operatorv1.DNS, oldV
 
 	if !haveDNS {
 		status = configv1.ConditionTrue
-		progressingReasons = append(progressingReasons, "DNSDoesNotExist")
+		progressingReasons = append(progressingReasons, conditions.ReasonDNSDoesNotExist)
 		messages = append(messages, `DNS "default" does not exist`)
 	} else {
 		foundProgressingCondition := false
@@ -316,16 +419,16 @@ func computeOperatorProgressingCondition(haveDNS bool, dns *operatorv1.DNS, oldV
 			switch cond.Status {
 			case operatorv1.ConditionTrue:
 				status = configv1.ConditionTrue
-				progressingReasons = append(progressingReasons, "DNSReportsProgressingIsTrue")
+				progressingReasons = append(progressingReasons, conditions.ReasonDNSReportsProgressingIsTrue)
 				messages = append(messages, fmt.Sprintf("DNS %q reports Progressing=True: %q", dns.Name, cond.Message))
 			case operatorv1.ConditionUnknown:
-				progressingReasons = append(progressingReasons, "DNSReportsProgressingIsUnknown")
+				progressingReasons = append(progressingReasons, conditions.ReasonDNSReportsProgressingIsUnknown)
 				messages = append(messages, fmt.Sprintf("DNS %q reports Progressing=Unknown: %q", dns.Name, cond.Message))
 			}
 			break
 		}
 		if !foundProgressingCondition {
-			progressingReasons = append(progressingReasons, "DNSDoesNotReportProgressingStatus")
+			progressingReasons = append(progressingReasons, conditions.ReasonDNSDoesNotReportProgressingStatus)
 			messages = append(messages, fmt.Sprintf("DNS %q is not reporting a Progressing status condition", dns.Name))
 		}
 	}
@@ -343,25 +446,25 @@ func computeOperatorProgressingCondition(haveDNS bool, dns *operatorv1.DNS, oldV
 		case OperatorVersionName:
 			if opv.Version != operatorReleaseVersion {
 				status = configv1.ConditionTrue
-				progressingReasons = append(progressingReasons, "UpgradingOperator")
+				progressingReasons = append(progressingReasons, conditions.ReasonUpgradingOperator)
 				messages = append(messages, fmt.Sprintf("Moving to release version %q.", operatorReleaseVersion))
 			}
 		case CoreDNSVersionName:
 			if opv.Version != coreDNSImage {
 				status = configv1.ConditionTrue
-				progressingReasons = append(progressingReasons, "UpgradingCoreDNS")
+				progressingReasons = append(progressingReasons, conditions.ReasonUpgradingCoreDNS)
 				messages = append(messages, fmt.Sprintf("Moving to coredns image version %q.", coreDNSImage))
 			}
 		case OpenshiftCLIVersionName:
 			if opv.Version != openshiftCLIImage {
 				status = configv1.ConditionTrue
-				progressingReasons = append(progressingReasons, "UpgradingOpenShiftCLI")
+				progressingReasons = append(progressingReasons, conditions.ReasonUpgradingOpenShiftCLI)
 				messages = append(messages, fmt.Sprintf("Moving to openshift-cli image version %q.", openshiftCLIImage))
 			}
 		case KubeRBACProxyName:
 			if opv.Version != kubeRBACProxyImage {
 				status = configv1.ConditionTrue
-				progressingReasons = append(progressingReasons, "UpgradingKubeRBACProxy")
+				progressingReasons = append(progressingReasons, conditions.ReasonUpgradingKubeRBACProxy)
 				messages = append(messages, fmt.Sprintf("Moving to kube-rbac-proxy image version %q.", kubeRBACProxyImage))
 			}
 		}
@@ -369,11 +472,11 @@ func computeOperatorProgressingCondition(haveDNS bool, dns *operatorv1.DNS, oldV
 
 	if len(progressingReasons) != 0 {
 		progressingCondition.Status = status
-		progressingCondition.Reason = strings.Join(progressingReasons, "And")
+		progressingCondition.Reason = strings.Join(progressingReasons, conditions.ClusterOperatorReasonSeparator)
 		progressingCondition.Message = strings.Join(messages, "\n")
 	} else {
 		progressingCondition.Status = configv1.ConditionFalse
-		progressingCondition.Reason = "AsExpected"
+		progressingCondition.Reason = conditions.ReasonAsExpected
 		progressingCondition.Message = dnsEqualConditionMessage
 	}
 
@@ -389,15 +492,15 @@ func computeOperatorAvailableCondition(haveDNS bool, dns *operatorv1.DNS) config
 	switch {
 	case !haveDNS:
 		availableCondition.Status = configv1.ConditionFalse
-		availableCondition.Reason = "DNSDoesNotExist"
+		availableCondition.Reason = conditions.ReasonDNSDoesNotExist
 		availableCondition.Message = `DNS "default" does not exist.`
 	case !checkDNSAvailable(dns):
 		availableCondition.Status = configv1.ConditionFalse
-		availableCondition.Reason = "DNSUnavailable"
+		availableCondition.Reason = conditions.ReasonDNSUnavailable
 		availableCondition.Message = `DNS "default" is unavailable.`
 	default:
 		availableCondition.Status = configv1.ConditionTrue
-		availableCondition.Reason = "AsExpected"
+		availableCondition.Reason = conditions.ReasonAsExpected
 		availableCondition.Message = `DNS "default" is available.`
 	}
 
@@ -465,5 +568,9 @@ func operatorStatusesEqual(a, b configv1.ClusterOperatorStatus) bool {
 		return false
 	}
 
+	if !bytes.Equal(a.Extension.Raw, b.Extension.Raw) {
+		return false
+	}
+
 	return true
 }
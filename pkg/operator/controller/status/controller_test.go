@@ -602,3 +602,29 @@ func TestComputeOperatorStatusVersions(t *testing.T) {
 		}
 	}
 }
+
+// TestComputeOperatorUpgradeableCondition verifies that the operator is not
+// upgradeable while the dns uses deprecated fields that were not migrated.
+func TestComputeOperatorUpgradeableCondition(t *testing.T) {
+	testCases := []struct {
+		description string
+		haveDNS     bool
+		conditions  []operatorv1.OperatorCondition
+		expected    configv1.ConditionStatus
+	}{
+		{"no dns", false, nil, configv1.ConditionTrue},
+		{"no deprecated fields", true, nil, configv1.ConditionTrue},
+		{"deprecated fields migrated", true, []operatorv1.OperatorCondition{{Type: "DeprecatedFieldsMigrated", Status: operatorv1.ConditionTrue}}, configv1.ConditionTrue},
+		{"deprecated fields in use", true, []operatorv1.OperatorCondition{{Type: "DeprecatedFieldsMigrated", Status: operatorv1.ConditionFalse}}, configv1.ConditionFalse},
+	}
+	for _, tc := range testCases {
+		dns := &operatorv1.DNS{
+			ObjectMeta: metav1.ObjectMeta{Name: "default"},
+			Status:     operatorv1.DNSStatus{Conditions: tc.conditions},
+		}
+		actual := computeOperatorUpgradeableCondition(tc.haveDNS, dns)
+		if actual.Type != configv1.OperatorUpgradeable || actual.Status != tc.expected {
+			t.Errorf("%q: expected Upgradeable=%s, got %#v", tc.description, tc.expected, actual)
+		}
+	}
+}
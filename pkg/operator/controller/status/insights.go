@@ -0,0 +1,130 @@
+package status
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	operatorcontroller "github.com/openshift/cluster-dns-operator/pkg/operator/controller"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// The types in this file define the schema of the structured data that the
+// operator publishes in the ClusterOperator's status.extension field for
+// consumption by console plugins and fleet management tools.  Consumers should
+// check apiVersion and kind before interpreting the data.  Fields may be added
+// to a schema version, but existing fields are not removed or changed in an
+// incompatible way without incrementing the version.
+
+const (
+	// DNSInsightsAPIVersion is the version of the DNSInsights schema.
+	DNSInsightsAPIVersion = "dns.operator.openshift.io/v1alpha1"
+	// DNSInsightsKind is the kind of the DNSInsights schema.
+	DNSInsightsKind = "DNSInsights"
+
+	// ZoneHealthActive indicates that a forwarding zone is in the active
+	// Corefile and the DNS is available.
+	ZoneHealthActive = "Active"
+	// ZoneHealthPending indicates that a forwarding zone is configured on
+	// the DNS but is not yet in the active Corefile.
+	ZoneHealthPending = "Pending"
+	// ZoneHealthUnavailable indicates that a forwarding zone is in the
+	// active Corefile but the DNS is unavailable.
+	ZoneHealthUnavailable = "Unavailable"
+)
+
+// DNSInsights is a summary of the state of the default DNS.
+type DNSInsights struct {
+	// APIVersion is the version of the schema, DNSInsightsAPIVersion.
+	APIVersion string `json:"apiVersion"`
+	// Kind is the kind of the schema, DNSInsightsKind.
+	Kind string `json:"kind"`
+
+	// CorefileRevision identifies the content of the active Corefile.  It
+	// changes whenever the Corefile changes.  It is empty if the Corefile
+	// configmap does not exist.
+	CorefileRevision string `json:"corefileRevision,omitempty"`
+	// Zones describes each forwarding zone server that is configured on
+	// the DNS.
+	Zones []ZoneInsight `json:"zones,omitempty"`
+	// NodeCoverage describes the nodes on which DNS pods run.
+	NodeCoverage NodeCoverage `json:"nodeCoverage"`
+}
+
+// ZoneInsight describes a forwarding zone server.
+type ZoneInsight struct {
+	// Server is the name of the server in the DNS spec.
+	Server string `json:"server"`
+	// Zones are the zones that the server forwards.
+	Zones []string `json:"zones"`
+	// Upstreams are the resolvers to which the server forwards queries.
+	Upstreams []string `json:"upstreams"`
+	// Health is one of ZoneHealthActive, ZoneHealthPending, or
+	// ZoneHealthUnavailable.
+	Health string `json:"health"`
+}
+
+// NodeCoverage describes the nodes on which DNS pods run.
+type NodeCoverage struct {
+	// Nodes is the number of nodes in the cluster.
+	Nodes int `json:"nodes"`
+	// Scheduled is the number of nodes that should run a DNS pod.
+	Scheduled int `json:"scheduled"`
+	// Available is the number of nodes that run an available DNS pod.
+	Available int `json:"available"`
+}
+
+// computeDNSInsights returns the insights for the given DNS using the given
+// Corefile configmap, DNS daemonset, and node count.  The configmap and
+// daemonset may be nil if they do not exist.
+func computeDNSInsights(dns *operatorv1.DNS, cm *corev1.ConfigMap, ds *appsv1.DaemonSet, nodes int) DNSInsights {
+	insights := DNSInsights{
+		APIVersion: DNSInsightsAPIVersion,
+		Kind:       DNSInsightsKind,
+		NodeCoverage: NodeCoverage{
+			Nodes: nodes,
+		},
+	}
+	var corefile string
+	if cm != nil {
+		insights.CorefileRevision = cm.Annotations[operatorcontroller.DesiredHashAnnotation]
+		corefile = cm.Data["Corefile"]
+	}
+	if ds != nil {
+		insights.NodeCoverage.Scheduled = int(ds.Status.DesiredNumberScheduled)
+		insights.NodeCoverage.Available = int(ds.Status.NumberAvailable)
+	}
+
+	available := checkDNSAvailable(dns)
+	for _, server := range dns.Spec.Servers {
+		zone := ZoneInsight{
+			Server:    server.Name,
+			Zones:     append([]string{}, server.Zones...),
+			Upstreams: append([]string{}, server.ForwardPlugin.Upstreams...),
+		}
+		switch {
+		case !strings.Contains(corefile, "# "+server.Name+"\n"):
+			zone.Health = ZoneHealthPending
+		case !available:
+			zone.Health = ZoneHealthUnavailable
+		default:
+			zone.Health = ZoneHealthActive
+		}
+		insights.Zones = append(insights.Zones, zone)
+	}
+	sort.Slice(insights.Zones, func(i, j int) bool {
+		return insights.Zones[i].Server < insights.Zones[j].Server
+	})
+
+	return insights
+}
+
+// encodeDNSInsights returns the JSON encoding of the given insights for use
+// as the ClusterOperator's status extension.
+func encodeDNSInsights(insights DNSInsights) ([]byte, error) {
+	return json.Marshal(insights)
+}
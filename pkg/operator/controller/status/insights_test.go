@@ -0,0 +1,92 @@
+package status
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	operatorcontroller "github.com/openshift/cluster-dns-operator/pkg/operator/controller"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestComputeDNSInsights verifies that computeDNSInsights summarizes the
+// Corefile revision, forwarding zones, and node coverage.
+func TestComputeDNSInsights(t *testing.T) {
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{Name: "default"},
+		Spec: operatorv1.DNSSpec{
+			Servers: []operatorv1.Server{
+				{
+					Name:          "foo",
+					Zones:         []string{"foo.com"},
+					ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"1.1.1.1"}},
+				},
+				{
+					Name:          "bar",
+					Zones:         []string{"bar.com"},
+					ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{"2.2.2.2"}},
+				},
+			},
+		},
+		Status: operatorv1.DNSStatus{
+			Conditions: []operatorv1.OperatorCondition{{
+				Type:   operatorv1.OperatorStatusTypeAvailable,
+				Status: operatorv1.ConditionTrue,
+			}},
+		},
+	}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{operatorcontroller.DesiredHashAnnotation: "abc123"},
+		},
+		Data: map[string]string{"Corefile": "# foo\nfoo.com:5353 {\n}\n"},
+	}
+	ds := &appsv1.DaemonSet{
+		Status: appsv1.DaemonSetStatus{
+			DesiredNumberScheduled: 3,
+			NumberAvailable:        2,
+		},
+	}
+
+	expected := DNSInsights{
+		APIVersion:       DNSInsightsAPIVersion,
+		Kind:             DNSInsightsKind,
+		CorefileRevision: "abc123",
+		Zones: []ZoneInsight{
+			{Server: "bar", Zones: []string{"bar.com"}, Upstreams: []string{"2.2.2.2"}, Health: ZoneHealthPending},
+			{Server: "foo", Zones: []string{"foo.com"}, Upstreams: []string{"1.1.1.1"}, Health: ZoneHealthActive},
+		},
+		NodeCoverage: NodeCoverage{Nodes: 4, Scheduled: 3, Available: 2},
+	}
+	actual := computeDNSInsights(dns, cm, ds, 4)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %#v, got %#v", expected, actual)
+	}
+
+	dns.Status.Conditions[0].Status = operatorv1.ConditionFalse
+	actual = computeDNSInsights(dns, cm, nil, 4)
+	if actual.Zones[1].Health != ZoneHealthUnavailable {
+		t.Errorf("expected zone %q to be %s, got %s", actual.Zones[1].Server, ZoneHealthUnavailable, actual.Zones[1].Health)
+	}
+	if actual.NodeCoverage.Scheduled != 0 || actual.NodeCoverage.Available != 0 {
+		t.Errorf("expected no node coverage without a daemonset, got %#v", actual.NodeCoverage)
+	}
+
+	data, err := encodeDNSInsights(expected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded["apiVersion"] != DNSInsightsAPIVersion || decoded["kind"] != DNSInsightsKind {
+		t.Errorf("unexpected encoding: %s", data)
+	}
+}
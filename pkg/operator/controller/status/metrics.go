@@ -0,0 +1,81 @@
+package status
+
+import (
+	"sync"
+
+	configv1 "github.com/openshift/api/config/v1"
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	clusterOperatorConditionDesc = prometheus.NewDesc(
+		"dns_clusteroperator_condition",
+		"Status of each condition of the dns clusteroperator: 1 if True, 0 if False, or -1 if Unknown.",
+		[]string{"condition", "reason"}, nil,
+	)
+	dnsConditionDesc = prometheus.NewDesc(
+		"dns_condition",
+		"Status of each condition of the default DNS: 1 if True, 0 if False, or -1 if Unknown.",
+		[]string{"dns", "condition", "reason"}, nil,
+	)
+)
+
+// conditionMetrics is a prometheus.Collector that reports the conditions that
+// the status controller most recently computed for the clusteroperator and
+// observed on the default DNS, so that alerts and dashboards need not query
+// the API for them.
+type conditionMetrics struct {
+	lock sync.Mutex
+
+	clusterOperatorConditions []configv1.ClusterOperatorStatusCondition
+	// dnsName is the name of the DNS whose conditions are in
+	// dnsConditions, or empty if the DNS does not exist.
+	dnsName       string
+	dnsConditions []operatorv1.OperatorCondition
+}
+
+// update records the given clusteroperator conditions and the conditions of
+// the given DNS, which is nil if the DNS does not exist.
+func (m *conditionMetrics) update(coConditions []configv1.ClusterOperatorStatusCondition, dns *operatorv1.DNS) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.clusterOperatorConditions = append([]configv1.ClusterOperatorStatusCondition(nil), coConditions...)
+	m.dnsName, m.dnsConditions = "", nil
+	if dns != nil {
+		m.dnsName = dns.Name
+		m.dnsConditions = append([]operatorv1.OperatorCondition(nil), dns.Status.Conditions...)
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (m *conditionMetrics) Describe(ch chan<- *prometheus.Desc) {
+	ch <- clusterOperatorConditionDesc
+	ch <- dnsConditionDesc
+}
+
+// Collect implements prometheus.Collector.
+func (m *conditionMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for _, cond := range m.clusterOperatorConditions {
+		ch <- prometheus.MustNewConstMetric(clusterOperatorConditionDesc, prometheus.GaugeValue, conditionValue(string(cond.Status)), string(cond.Type), cond.Reason)
+	}
+	for _, cond := range m.dnsConditions {
+		ch <- prometheus.MustNewConstMetric(dnsConditionDesc, prometheus.GaugeValue, conditionValue(string(cond.Status)), m.dnsName, cond.Type, cond.Reason)
+	}
+}
+
+// conditionValue returns the metric value for the given condition status.
+func conditionValue(status string) float64 {
+	switch status {
+	case string(configv1.ConditionTrue):
+		return 1
+	case string(configv1.ConditionFalse):
+		return 0
+	}
+	return -1
+}
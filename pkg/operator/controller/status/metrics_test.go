@@ -0,0 +1,83 @@
+package status
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	configv1 "github.com/openshift/api/config/v1"
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestConditionMetrics verifies that conditionMetrics reports the most
+// recently recorded conditions with their reasons and encodes their statuses.
+func TestConditionMetrics(t *testing.T) {
+	m := &conditionMetrics{}
+	coConditions := []configv1.ClusterOperatorStatusCondition{
+		{Type: configv1.OperatorAvailable, Status: configv1.ConditionTrue, Reason: "AsExpected"},
+		{Type: configv1.OperatorDegraded, Status: configv1.ConditionFalse, Reason: "DNSNotDegraded"},
+		{Type: configv1.OperatorProgressing, Status: configv1.ConditionUnknown},
+	}
+	dns := &operatorv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{Name: "default"},
+		Status: operatorv1.DNSStatus{
+			Conditions: []operatorv1.OperatorCondition{
+				{Type: "UpstreamsReachable", Status: operatorv1.ConditionFalse, Reason: "UpstreamsUnreachable"},
+			},
+		},
+	}
+	m.update(coConditions, dns)
+	expected := []string{
+		`dns_clusteroperator_condition{condition="Available",reason="AsExpected"} 1`,
+		`dns_clusteroperator_condition{condition="Degraded",reason="DNSNotDegraded"} 0`,
+		`dns_clusteroperator_condition{condition="Progressing",reason=""} -1`,
+		`dns_condition{dns="default",condition="UpstreamsReachable",reason="UpstreamsUnreachable"} 0`,
+	}
+	if actual := collectConditionMetrics(m); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected metrics %q, got %q", expected, actual)
+	}
+
+	// Once the DNS no longer exists, only the clusteroperator's conditions
+	// are reported.
+	m.update(coConditions[:1], nil)
+	expected = expected[:1]
+	if actual := collectConditionMetrics(m); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected metrics %q, got %q", expected, actual)
+	}
+}
+
+// collectConditionMetrics returns the metrics that the given collector reports,
+// formatted as "name{label="value",...} value" with the labels in the order
+// of their descriptor.
+func collectConditionMetrics(c prometheus.Collector) []string {
+	ch := make(chan prometheus.Metric, 10)
+	c.Collect(ch)
+	close(ch)
+	var metrics []string
+	for m := range ch {
+		out := &dto.Metric{}
+		if err := m.Write(out); err != nil {
+			panic(err)
+		}
+		name, labelNames := "dns_clusteroperator_condition", []string{"condition", "reason"}
+		if m.Desc() == dnsConditionDesc {
+			name, labelNames = "dns_condition", []string{"dns", "condition", "reason"}
+		}
+		values := map[string]string{}
+		for _, label := range out.GetLabel() {
+			values[label.GetName()] = label.GetValue()
+		}
+		var labels []string
+		for _, label := range labelNames {
+			labels = append(labels, fmt.Sprintf("%s=%q", label, values[label]))
+		}
+		metrics = append(metrics, fmt.Sprintf("%s{%s} %v", name, strings.Join(labels, ","), out.GetGauge().GetValue()))
+	}
+	return metrics
+}
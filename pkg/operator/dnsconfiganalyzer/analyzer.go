@@ -0,0 +1,199 @@
+// Package dnsconfiganalyzer periodically inspects the DNS settings of every
+// pod in the cluster and reports pods whose settings are likely to cause DNS
+// problems as metrics on the operator's metrics endpoint.  The analyzer only
+// reports findings; it never modifies workloads.
+package dnsconfiganalyzer
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	operatorcontroller "github.com/openshift/cluster-dns-operator/pkg/operator/controller"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// analysisInterval is the interval at which the analyzer inspects the
+	// cluster's pods.
+	analysisInterval = 10 * time.Minute
+	// listPageSize is the number of pods that the analyzer requests at a
+	// time.
+	listPageSize = 500
+
+	// maxNdots is the largest ndots value that the analyzer accepts.  The
+	// default for pods that use cluster DNS is 5; larger values cause
+	// even more names to be tried with each search domain first.
+	maxNdots = 5
+	// maxSearches is the largest number of search domains that the
+	// analyzer accepts, including the three that cluster DNS adds.  Older
+	// versions of glibc ignore search domains beyond the sixth.
+	maxSearches = 6
+	// clusterFirstSearches is the number of search domains that the
+	// kubelet adds for pods that use cluster DNS.
+	clusterFirstSearches = 3
+)
+
+const (
+	// ReasonBypassesClusterDNS indicates that a pod resolves names using
+	// nameservers other than cluster DNS and therefore cannot resolve
+	// service names.
+	ReasonBypassesClusterDNS = "BypassesClusterDNS"
+	// ReasonHighNdots indicates that a pod sets ndots higher than the
+	// default, which multiplies the queries for external names.
+	ReasonHighNdots = "HighNdots"
+	// ReasonTooManySearches indicates that a pod has more search domains
+	// than some resolvers honor.
+	ReasonTooManySearches = "TooManySearches"
+)
+
+var findingsDesc = prometheus.NewDesc(
+	"dns_workload_dns_config_findings",
+	"Number of pods with DNS settings that are likely to cause DNS problems, by namespace and reason.",
+	[]string{"namespace", "reason"}, nil,
+)
+
+// findingKey identifies a group of findings.
+type findingKey struct {
+	namespace string
+	reason    string
+}
+
+// Analyzer is a prometheus.Collector that reports pods with problematic DNS
+// settings.  Start must be called to inspect the pods.
+type Analyzer struct {
+	// client is used to list pods in all namespaces and to get the
+	// default dns.
+	client client.Reader
+
+	mu       sync.Mutex
+	findings map[findingKey]int
+}
+
+// New returns an analyzer that uses the given client to list pods.  The client
+// should not be backed by a namespace-scoped cache.
+func New(client client.Reader) *Analyzer {
+	return &Analyzer{client: client}
+}
+
+// Start inspects the cluster's pods periodically until the given context is
+// done.  Start implements manager.Runnable.
+func (a *Analyzer) Start(ctx context.Context) error {
+	wait.UntilWithContext(ctx, a.update, analysisInterval)
+	return nil
+}
+
+// Describe implements prometheus.Collector.
+func (a *Analyzer) Describe(ch chan<- *prometheus.Desc) {
+	ch <- findingsDesc
+}
+
+// Collect implements prometheus.Collector.
+func (a *Analyzer) Collect(ch chan<- prometheus.Metric) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for key, count := range a.findings {
+		ch <- prometheus.MustNewConstMetric(findingsDesc, prometheus.GaugeValue, float64(count), key.namespace, key.reason)
+	}
+}
+
+// update lists the cluster's pods and replaces the findings.
+func (a *Analyzer) update(ctx context.Context) {
+	dns := &operatorv1.DNS{}
+	if err := a.client.Get(ctx, operatorcontroller.DefaultDNSNamespaceName(), dns); err != nil {
+		logrus.Errorf("dns config analyzer failed to get default dns: %v", err)
+		return
+	}
+	if len(dns.Status.ClusterIP) == 0 {
+		return
+	}
+
+	findings := map[findingKey]int{}
+	podList := &corev1.PodList{}
+	opts := []client.ListOption{client.Limit(listPageSize)}
+	for {
+		if err := a.client.List(ctx, podList, opts...); err != nil {
+			logrus.Errorf("dns config analyzer failed to list pods: %v", err)
+			return
+		}
+		for i := range podList.Items {
+			for _, reason := range analyzePod(&podList.Items[i], dns.Status.ClusterIP) {
+				findings[findingKey{namespace: podList.Items[i].Namespace, reason: reason}]++
+			}
+		}
+		if len(podList.Continue) == 0 {
+			break
+		}
+		opts = []client.ListOption{client.Limit(listPageSize), client.Continue(podList.Continue)}
+	}
+
+	a.mu.Lock()
+	a.findings = findings
+	a.mu.Unlock()
+}
+
+// analyzePod returns the reasons for which the DNS settings of the given pod
+// are likely to cause problems.  The given cluster IP is the address of the
+// cluster DNS service.
+func analyzePod(pod *corev1.Pod, clusterIP string) []string {
+	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return nil
+	}
+	var reasons []string
+	policy := pod.Spec.DNSPolicy
+	if len(policy) == 0 {
+		policy = corev1.DNSClusterFirst
+	}
+	// Host-network pods are expected to use the node's resolver unless
+	// they use the ClusterFirstWithHostNet or None policy.
+	bypassesClusterDNS := false
+	switch policy {
+	case corev1.DNSDefault:
+		bypassesClusterDNS = !pod.Spec.HostNetwork
+	case corev1.DNSNone:
+		bypassesClusterDNS = true
+		if pod.Spec.DNSConfig != nil {
+			for _, nameserver := range pod.Spec.DNSConfig.Nameservers {
+				if nameserver == clusterIP {
+					bypassesClusterDNS = false
+				}
+			}
+		}
+	}
+	if bypassesClusterDNS {
+		reasons = append(reasons, ReasonBypassesClusterDNS)
+	}
+
+	if pod.Spec.DNSConfig == nil {
+		return reasons
+	}
+	for _, option := range pod.Spec.DNSConfig.Options {
+		if option.Name != "ndots" || option.Value == nil {
+			continue
+		}
+		if ndots, err := strconv.Atoi(strings.TrimSpace(*option.Value)); err == nil && ndots > maxNdots {
+			reasons = append(reasons, ReasonHighNdots)
+		}
+	}
+	searches := len(pod.Spec.DNSConfig.Searches)
+	if (policy == corev1.DNSClusterFirst && !pod.Spec.HostNetwork) || policy == corev1.DNSClusterFirstWithHostNet {
+		searches += clusterFirstSearches
+	}
+	if searches > maxSearches {
+		reasons = append(reasons, ReasonTooManySearches)
+	}
+	return reasons
+}
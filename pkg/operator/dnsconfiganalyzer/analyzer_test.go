@@ -0,0 +1,173 @@
+package dnsconfiganalyzer
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	corev1 "k8s.io/api/core/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const testClusterIP = "172.30.0.10"
+
+// fakeReader is a client.Reader that gets a fixed dns and lists a fixed set of
+// pods in pages of one pod.
+type fakeReader struct {
+	client.Reader
+	dns  *operatorv1.DNS
+	pods []corev1.Pod
+}
+
+func (r *fakeReader) Get(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+	r.dns.DeepCopyInto(obj.(*operatorv1.DNS))
+	return nil
+}
+
+func (r *fakeReader) List(_ context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	listOpts := &client.ListOptions{}
+	listOpts.ApplyOptions(opts)
+	i := 0
+	if len(listOpts.Continue) != 0 {
+		i = int(listOpts.Continue[0] - '0')
+	}
+	podList := list.(*corev1.PodList)
+	podList.Items = r.pods[i : i+1]
+	podList.Continue = ""
+	if i+1 < len(r.pods) {
+		podList.Continue = string(rune('0' + i + 1))
+	}
+	return nil
+}
+
+// TestAnalyzePod verifies that analyzePod reports pods that bypass cluster
+// DNS, set a high ndots value, or have too many search domains.
+func TestAnalyzePod(t *testing.T) {
+	ndots := func(value string) corev1.PodDNSConfigOption {
+		return corev1.PodDNSConfigOption{Name: "ndots", Value: &value}
+	}
+	testCases := []struct {
+		description string
+		spec        corev1.PodSpec
+		phase       corev1.PodPhase
+		expected    []string
+	}{
+		{
+			description: "default policy",
+			expected:    nil,
+		},
+		{
+			description: "Default policy",
+			spec:        corev1.PodSpec{DNSPolicy: corev1.DNSDefault},
+			expected:    []string{ReasonBypassesClusterDNS},
+		},
+		{
+			description: "Default policy with host network",
+			spec:        corev1.PodSpec{DNSPolicy: corev1.DNSDefault, HostNetwork: true},
+			expected:    nil,
+		},
+		{
+			description: "Default policy for a completed pod",
+			spec:        corev1.PodSpec{DNSPolicy: corev1.DNSDefault},
+			phase:       corev1.PodSucceeded,
+			expected:    nil,
+		},
+		{
+			description: "None policy with external nameservers",
+			spec: corev1.PodSpec{
+				DNSPolicy: corev1.DNSNone,
+				DNSConfig: &corev1.PodDNSConfig{Nameservers: []string{"8.8.8.8"}},
+			},
+			expected: []string{ReasonBypassesClusterDNS},
+		},
+		{
+			description: "None policy with the cluster DNS nameserver",
+			spec: corev1.PodSpec{
+				DNSPolicy: corev1.DNSNone,
+				DNSConfig: &corev1.PodDNSConfig{Nameservers: []string{testClusterIP}},
+			},
+			expected: nil,
+		},
+		{
+			description: "ndots of 5",
+			spec: corev1.PodSpec{
+				DNSConfig: &corev1.PodDNSConfig{Options: []corev1.PodDNSConfigOption{ndots("5")}},
+			},
+			expected: nil,
+		},
+		{
+			description: "ndots of 10",
+			spec: corev1.PodSpec{
+				DNSConfig: &corev1.PodDNSConfig{Options: []corev1.PodDNSConfigOption{ndots("10")}},
+			},
+			expected: []string{ReasonHighNdots},
+		},
+		{
+			description: "three additional search domains",
+			spec: corev1.PodSpec{
+				DNSConfig: &corev1.PodDNSConfig{Searches: []string{"a.com", "b.com", "c.com"}},
+			},
+			expected: nil,
+		},
+		{
+			description: "four additional search domains",
+			spec: corev1.PodSpec{
+				DNSConfig: &corev1.PodDNSConfig{Searches: []string{"a.com", "b.com", "c.com", "d.com"}},
+			},
+			expected: []string{ReasonTooManySearches},
+		},
+		{
+			description: "four search domains with the None policy",
+			spec: corev1.PodSpec{
+				DNSPolicy: corev1.DNSNone,
+				DNSConfig: &corev1.PodDNSConfig{
+					Nameservers: []string{testClusterIP},
+					Searches:    []string{"a.com", "b.com", "c.com", "d.com"},
+				},
+			},
+			expected: nil,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			pod := &corev1.Pod{Spec: tc.spec, Status: corev1.PodStatus{Phase: tc.phase}}
+			if actual := analyzePod(pod, testClusterIP); !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+// TestUpdate verifies that update counts the findings for all pages of pods by
+// namespace and reason.
+func TestUpdate(t *testing.T) {
+	pod := func(namespace string, policy corev1.DNSPolicy) corev1.Pod {
+		return corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace},
+			Spec:       corev1.PodSpec{DNSPolicy: policy},
+		}
+	}
+	reader := &fakeReader{
+		dns: &operatorv1.DNS{Status: operatorv1.DNSStatus{ClusterIP: testClusterIP}},
+		pods: []corev1.Pod{
+			pod("a", corev1.DNSDefault),
+			pod("a", corev1.DNSDefault),
+			pod("a", corev1.DNSClusterFirst),
+			pod("b", corev1.DNSDefault),
+		},
+	}
+	a := New(reader)
+	a.update(context.Background())
+	expected := map[findingKey]int{
+		{namespace: "a", reason: ReasonBypassesClusterDNS}: 2,
+		{namespace: "b", reason: ReasonBypassesClusterDNS}: 1,
+	}
+	if !reflect.DeepEqual(a.findings, expected) {
+		t.Errorf("expected %v, got %v", expected, a.findings)
+	}
+}
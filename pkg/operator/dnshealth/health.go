@@ -0,0 +1,206 @@
+// Package dnshealth implements an HTTP endpoint in the operator that reports
+// whether the cluster DNS tier is healthy, for use as a health check by
+// external load balancers or virtual IP managers when DNS is exposed outside
+// the cluster.
+//
+// The endpoint does not require authentication because health checkers
+// typically cannot present credentials; it only reveals whether DNS is
+// healthy and how many dns pods are available.  For the same reason, a request
+// never reaches the API server: the server checks the health of the DNS tier
+// periodically and serves the result of the last check.
+//
+// The operator publishes the endpoint only on its ClusterIP service.  The
+// operator runs as a single pod on a control-plane node, and an
+// unauthenticated port on the cluster's nodes or on a load balancer is a
+// decision for the cluster administrator, who can expose the service's
+// dns-health port with a NodePort or LoadBalancer service, or a passthrough
+// route, that selects the operator's pod.
+package dnshealth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	operatorcontroller "github.com/openshift/cluster-dns-operator/pkg/operator/controller"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/httpauth"
+
+	"github.com/sirupsen/logrus"
+
+	appsv1 "k8s.io/api/apps/v1"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// HealthPath is the path of the health endpoint.
+const HealthPath = "/healthz"
+
+const (
+	// checkInterval is how often the server checks the health of the DNS
+	// tier.
+	checkInterval = 5 * time.Second
+	// staleAfter is how old the last successful check may be before the
+	// server stops serving it.  The server then responds with an error
+	// until a check succeeds.
+	staleAfter = time.Minute
+)
+
+// Health describes the health of the DNS tier.
+type Health struct {
+	// Healthy indicates whether the DNS tier can serve queries.
+	Healthy bool `json:"healthy"`
+	// Reason explains why the DNS tier is unhealthy, or is empty if it is
+	// healthy.
+	Reason string `json:"reason,omitempty"`
+	// AvailablePods is the number of available dns pods.
+	AvailablePods int32 `json:"availablePods"`
+	// DesiredPods is the number of dns pods that should be running.
+	DesiredPods int32 `json:"desiredPods"`
+}
+
+// Server is an http.Handler that serves the health endpoint.
+type Server struct {
+	// client is used to get the default DNS.  The DNS is cluster-scoped,
+	// and the operator's cache only serves the namespaces that it watches.
+	client client.Reader
+	// cache is used to get the DNS's daemonset.
+	cache client.Reader
+
+	listenAddress string
+	certFile      string
+	keyFile       string
+
+	// mu protects the following fields.
+	mu sync.Mutex
+	// health is the result of the last successful check, and checked is
+	// when the check was done, or the zero time if no check has
+	// succeeded.
+	health  Health
+	checked time.Time
+}
+
+// New returns a health endpoint server that listens on the given address and
+// serves TLS using the given certificate and key files.
+func New(client, cache client.Reader, listenAddress, certFile, keyFile string) *Server {
+	return &Server{
+		client:        client,
+		cache:         cache,
+		listenAddress: listenAddress,
+		certFile:      certFile,
+		keyFile:       keyFile,
+	}
+}
+
+// Start checks the health of the DNS tier periodically and runs the server
+// until the given context is done.  Start implements manager.Runnable.
+func (s *Server) Start(ctx context.Context) error {
+	go wait.UntilWithContext(ctx, s.update, checkInterval)
+	return httpauth.ServeTLS(ctx, "dns health endpoint", s.listenAddress, s.certFile, s.keyFile, s)
+}
+
+// update checks the health of the DNS tier and stores the result.
+func (s *Server) update(ctx context.Context) {
+	health, err := s.getHealth(ctx)
+	if err != nil {
+		logrus.Errorf("dns health endpoint failed to get dns health: %v", err)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.health = health
+	s.checked = time.Now()
+}
+
+// lastHealth returns the result of the last successful check and a Boolean
+// value indicating whether the result is recent enough to serve at the given
+// time.
+func (s *Server) lastHealth(now time.Time) (Health, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.checked.IsZero() || now.Sub(s.checked) > staleAfter {
+		return Health{}, false
+	}
+	return s.health, true
+}
+
+// ServeHTTP responds with the health of the DNS tier as of the last check and
+// status 200 if it is healthy or 503 if it is not, or with status 500 if no
+// recent check succeeded.
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != HealthPath {
+		http.NotFound(w, req)
+		return
+	}
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	health, ok := s.lastHealth(time.Now())
+	if !ok {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if !health.Healthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if req.Method == http.MethodHead {
+		return
+	}
+	if err := json.NewEncoder(w).Encode(health); err != nil {
+		logrus.Warningf("dns health endpoint failed to write dns health: %v", err)
+	}
+}
+
+// getHealth gets the default DNS and its daemonset and returns the health of
+// the DNS tier.
+func (s *Server) getHealth(ctx context.Context) (Health, error) {
+	dns := &operatorv1.DNS{}
+	if err := s.client.Get(ctx, operatorcontroller.DefaultDNSNamespaceName(), dns); err != nil {
+		if errors.IsNotFound(err) {
+			return Health{Reason: "The default DNS does not exist."}, nil
+		}
+		return Health{}, fmt.Errorf("failed to get dns: %w", err)
+	}
+	ds := &appsv1.DaemonSet{}
+	if err := s.cache.Get(ctx, operatorcontroller.DNSDaemonSetName(dns), ds); err != nil {
+		if errors.IsNotFound(err) {
+			return Health{Reason: "The DNS daemonset does not exist."}, nil
+		}
+		return Health{}, fmt.Errorf("failed to get dns daemonset: %w", err)
+	}
+	return computeHealth(dns, ds), nil
+}
+
+// computeHealth returns the health of the DNS tier for the given DNS and its
+// daemonset.  The DNS tier is healthy if the DNS is available and at least one
+// dns pod is available.
+func computeHealth(dns *operatorv1.DNS, ds *appsv1.DaemonSet) Health {
+	health := Health{
+		AvailablePods: ds.Status.NumberAvailable,
+		DesiredPods:   ds.Status.DesiredNumberScheduled,
+	}
+	available := false
+	for _, cond := range dns.Status.Conditions {
+		if cond.Type == operatorv1.OperatorStatusTypeAvailable {
+			available = cond.Status == operatorv1.ConditionTrue
+		}
+	}
+	switch {
+	case health.AvailablePods == 0:
+		health.Reason = "No DNS pods are available."
+	case !available:
+		health.Reason = "The DNS is not available."
+	default:
+		health.Healthy = true
+	}
+	return health
+}
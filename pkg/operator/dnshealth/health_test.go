@@ -0,0 +1,179 @@
+package dnshealth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	appsv1 "k8s.io/api/apps/v1"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// fakeReader is a client.Reader that returns a fixed dns and daemonset and
+// counts its gets.
+type fakeReader struct {
+	client.Reader
+	dns  *operatorv1.DNS
+	ds   *appsv1.DaemonSet
+	gets int
+}
+
+func (r *fakeReader) Get(_ context.Context, key client.ObjectKey, obj client.Object) error {
+	r.gets++
+	switch o := obj.(type) {
+	case *operatorv1.DNS:
+		r.dns.DeepCopyInto(o)
+	case *appsv1.DaemonSet:
+		if r.ds == nil {
+			return errors.NewNotFound(schema.GroupResource{Resource: "daemonsets"}, key.Name)
+		}
+		r.ds.DeepCopyInto(o)
+	}
+	return nil
+}
+
+// TestServeHTTP verifies that the health endpoint reports the DNS tier as
+// healthy only if the DNS is available and has available pods.
+func TestServeHTTP(t *testing.T) {
+	dns := func(available operatorv1.ConditionStatus) *operatorv1.DNS {
+		return &operatorv1.DNS{
+			Status: operatorv1.DNSStatus{
+				Conditions: []operatorv1.OperatorCondition{{Type: operatorv1.OperatorStatusTypeAvailable, Status: available}},
+			},
+		}
+	}
+	daemonset := func(available, desired int32) *appsv1.DaemonSet {
+		return &appsv1.DaemonSet{
+			Status: appsv1.DaemonSetStatus{NumberAvailable: available, DesiredNumberScheduled: desired},
+		}
+	}
+	testCases := []struct {
+		description  string
+		dns          *operatorv1.DNS
+		ds           *appsv1.DaemonSet
+		method       string
+		path         string
+		expectStatus int
+	}{
+		{
+			description:  "healthy",
+			dns:          dns(operatorv1.ConditionTrue),
+			ds:           daemonset(2, 3),
+			expectStatus: http.StatusOK,
+		},
+		{
+			description:  "healthy with HEAD",
+			dns:          dns(operatorv1.ConditionTrue),
+			ds:           daemonset(2, 3),
+			method:       http.MethodHead,
+			expectStatus: http.StatusOK,
+		},
+		{
+			description:  "no available pods",
+			dns:          dns(operatorv1.ConditionTrue),
+			ds:           daemonset(0, 3),
+			expectStatus: http.StatusServiceUnavailable,
+		},
+		{
+			description:  "dns not available",
+			dns:          dns(operatorv1.ConditionFalse),
+			ds:           daemonset(1, 3),
+			expectStatus: http.StatusServiceUnavailable,
+		},
+		{
+			description:  "no daemonset",
+			dns:          dns(operatorv1.ConditionTrue),
+			expectStatus: http.StatusServiceUnavailable,
+		},
+		{
+			description:  "unsupported method",
+			dns:          dns(operatorv1.ConditionTrue),
+			ds:           daemonset(2, 3),
+			method:       http.MethodPost,
+			expectStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			description:  "unknown path",
+			dns:          dns(operatorv1.ConditionTrue),
+			ds:           daemonset(2, 3),
+			path:         "/other",
+			expectStatus: http.StatusNotFound,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			reader := &fakeReader{dns: tc.dns, ds: tc.ds}
+			s := New(reader, reader, "", "", "")
+			s.update(context.Background())
+			method, path := tc.method, tc.path
+			if len(method) == 0 {
+				method = http.MethodGet
+			}
+			if len(path) == 0 {
+				path = HealthPath
+			}
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+			if w.Code != tc.expectStatus {
+				t.Fatalf("expected status %d, got %d: %s", tc.expectStatus, w.Code, w.Body.String())
+			}
+			if method != http.MethodGet || (w.Code != http.StatusOK && w.Code != http.StatusServiceUnavailable) {
+				return
+			}
+			var health Health
+			if err := json.Unmarshal(w.Body.Bytes(), &health); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if health.Healthy != (w.Code == http.StatusOK) {
+				t.Errorf("expected healthy to be %t, got %#v", w.Code == http.StatusOK, health)
+			}
+		})
+	}
+}
+
+// TestServeHTTPServesLastCheck verifies that the health endpoint does not get
+// the DNS for each request and responds with an error until the first check
+// succeeds and once the last successful check is stale.
+func TestServeHTTPServesLastCheck(t *testing.T) {
+	reader := &fakeReader{
+		dns: &operatorv1.DNS{
+			Status: operatorv1.DNSStatus{
+				Conditions: []operatorv1.OperatorCondition{{Type: operatorv1.OperatorStatusTypeAvailable, Status: operatorv1.ConditionTrue}},
+			},
+		},
+		ds: &appsv1.DaemonSet{Status: appsv1.DaemonSetStatus{NumberAvailable: 1, DesiredNumberScheduled: 1}},
+	}
+	s := New(reader, reader, "", "", "")
+	serve := func() int {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, HealthPath, nil))
+		return w.Code
+	}
+
+	if code := serve(); code != http.StatusInternalServerError {
+		t.Errorf("expected status %d before the first check, got %d", http.StatusInternalServerError, code)
+	}
+	s.update(context.Background())
+	gets := reader.gets
+	for i := 0; i < 10; i++ {
+		if code := serve(); code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, code)
+		}
+	}
+	if reader.gets != gets {
+		t.Errorf("expected requests not to get the dns, got %d gets", reader.gets-gets)
+	}
+	s.checked = time.Now().Add(-2 * staleAfter)
+	if code := serve(); code != http.StatusInternalServerError {
+		t.Errorf("expected status %d for a stale check, got %d", http.StatusInternalServerError, code)
+	}
+}
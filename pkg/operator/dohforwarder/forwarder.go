@@ -0,0 +1,267 @@
+// Package dohforwarder implements a DNS-over-HTTPS forwarder that runs in a
+// sidecar of the dns pods.  CoreDNS cannot forward queries over HTTPS, so the
+// operator configures CoreDNS to forward queries for each DNS-over-HTTPS
+// upstream to a local address on which the forwarder listens, and the
+// forwarder sends each query to the upstream in an RFC 8484 POST request.
+//
+// The forwarder reaches upstreams through the proxy that the HTTPS_PROXY and
+// NO_PROXY environment variables specify, and it verifies their certificates
+// with the CA bundle that the SSL_CERT_FILE environment variable names, or
+// with the system's CA bundle.  If a query fails, the forwarder does not
+// respond, so that CoreDNS times out and tries another upstream.
+package dohforwarder
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// dnsMessageContentType is the media type of DNS messages in
+	// DNS-over-HTTPS requests and responses.
+	dnsMessageContentType = "application/dns-message"
+	// queryTimeout is the maximum time to wait for an upstream to respond
+	// to a query.  CoreDNS's forward plugin waits at most 5 seconds.
+	queryTimeout = 5 * time.Second
+	// tcpIdleTimeout is the time after which the forwarder closes a TCP
+	// connection on which CoreDNS has sent no query.
+	tcpIdleTimeout = 10 * time.Second
+	// maxMessageSize is the size of the largest DNS message.
+	maxMessageSize = 65535
+)
+
+// Route is a local address on which the forwarder listens and the URL of the
+// DNS-over-HTTPS upstream to which it forwards the queries that it receives
+// on that address.
+type Route struct {
+	// ListenAddress is the host:port address on which the forwarder
+	// listens for queries over both UDP and TCP.
+	ListenAddress string
+	// URL is the upstream's https URL.
+	URL string
+}
+
+// String returns the route in the form that ParseRoute accepts.
+func (r Route) String() string {
+	return r.ListenAddress + "=" + r.URL
+}
+
+// ParseRoute parses a route in the form "<listen address>=<https URL>".
+func ParseRoute(value string) (Route, error) {
+	i := strings.Index(value, "=")
+	if i < 0 {
+		return Route{}, fmt.Errorf("route %q must have the form <listen address>=<https URL>", value)
+	}
+	route := Route{ListenAddress: value[:i], URL: value[i+1:]}
+	if _, _, err := net.SplitHostPort(route.ListenAddress); err != nil {
+		return Route{}, fmt.Errorf("route %q has an invalid listen address: %w", value, err)
+	}
+	u, err := url.Parse(route.URL)
+	if err != nil {
+		return Route{}, fmt.Errorf("route %q has an invalid URL: %w", value, err)
+	}
+	if u.Scheme != "https" || len(u.Host) == 0 {
+		return Route{}, fmt.Errorf("route %q must have an https URL", value)
+	}
+	return route, nil
+}
+
+// Forwarder forwards DNS queries to DNS-over-HTTPS upstreams.
+type Forwarder struct {
+	routes []Route
+	client *http.Client
+}
+
+// New returns a forwarder for the given routes.
+func New(routes []Route) *Forwarder {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	return &Forwarder{
+		routes: routes,
+		client: &http.Client{Transport: transport, Timeout: queryTimeout},
+	}
+}
+
+// Start listens on the routes' addresses and forwards queries until the given
+// context is done or a listener fails.
+func (f *Forwarder) Start(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		wg       sync.WaitGroup
+		errsLock sync.Mutex
+		errs     []error
+	)
+	fail := func(err error) {
+		errsLock.Lock()
+		defer errsLock.Unlock()
+		errs = append(errs, err)
+		cancel()
+	}
+	for _, route := range f.routes {
+		conn, err := net.ListenPacket("udp", route.ListenAddress)
+		if err != nil {
+			fail(fmt.Errorf("failed to listen on udp %s: %w", route.ListenAddress, err))
+			break
+		}
+		listener, err := net.Listen("tcp", route.ListenAddress)
+		if err != nil {
+			conn.Close()
+			fail(fmt.Errorf("failed to listen on tcp %s: %w", route.ListenAddress, err))
+			break
+		}
+		logrus.Infof("forwarding queries on %s to %s", route.ListenAddress, route.URL)
+		wg.Add(2)
+		go func(route Route) {
+			defer wg.Done()
+			if err := f.serveUDP(ctx, conn, route.URL); err != nil {
+				fail(fmt.Errorf("failed to serve udp %s: %w", route.ListenAddress, err))
+			}
+		}(route)
+		go func(route Route) {
+			defer wg.Done()
+			if err := f.serveTCP(ctx, listener, route.URL); err != nil {
+				fail(fmt.Errorf("failed to serve tcp %s: %w", route.ListenAddress, err))
+			}
+		}(route)
+	}
+	<-ctx.Done()
+	wg.Wait()
+	if len(errs) != 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// serveUDP forwards the queries that the given connection receives to the
+// upstream with the given URL until the given context is done.  It closes the
+// connection.
+func (f *Forwarder) serveUDP(ctx context.Context, conn net.PacketConn, upstream string) error {
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		query := append([]byte(nil), buf[:n]...)
+		go func() {
+			response, err := f.exchange(ctx, upstream, query)
+			if err != nil {
+				logrus.Warningf("failed to forward query to %s: %v", upstream, err)
+				return
+			}
+			if _, err := conn.WriteTo(response, addr); err != nil && ctx.Err() == nil {
+				logrus.Warningf("failed to write response from %s to %s: %v", upstream, addr, err)
+			}
+		}()
+	}
+}
+
+// serveTCP forwards the queries on the connections that the given listener
+// accepts to the upstream with the given URL until the given context is done.
+// It closes the listener.
+func (f *Forwarder) serveTCP(ctx context.Context, listener net.Listener, upstream string) error {
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		go f.serveTCPConn(ctx, conn, upstream)
+	}
+}
+
+// serveTCPConn forwards the queries that the given connection receives, each
+// preceded by its length, to the upstream with the given URL until the client
+// closes the connection or is idle.
+func (f *Forwarder) serveTCPConn(ctx context.Context, conn net.Conn, upstream string) {
+	defer conn.Close()
+	for {
+		if err := conn.SetReadDeadline(time.Now().Add(tcpIdleTimeout)); err != nil {
+			return
+		}
+		var length uint16
+		if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+			return
+		}
+		query := make([]byte, length)
+		if _, err := io.ReadFull(conn, query); err != nil {
+			return
+		}
+		response, err := f.exchange(ctx, upstream, query)
+		if err != nil {
+			logrus.Warningf("failed to forward query to %s: %v", upstream, err)
+			return
+		}
+		framed := make([]byte, 2+len(response))
+		binary.BigEndian.PutUint16(framed, uint16(len(response)))
+		copy(framed[2:], response)
+		if _, err := conn.Write(framed); err != nil {
+			return
+		}
+	}
+}
+
+// exchange sends the given query to the upstream with the given URL and
+// returns the upstream's response.  The query is sent with ID 0, as RFC 8484
+// recommends so that HTTP caches can serve it, and the response has the
+// query's ID.
+func (f *Forwarder) exchange(ctx context.Context, upstream string, query []byte) ([]byte, error) {
+	if len(query) < 12 {
+		return nil, errors.New("query is shorter than a DNS message header")
+	}
+	id := binary.BigEndian.Uint16(query)
+	body := append([]byte(nil), query...)
+	binary.BigEndian.PutUint16(body, 0)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, upstream, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", dnsMessageContentType)
+	req.Header.Set("Accept", dnsMessageContentType)
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("upstream responded with status %s", resp.Status)
+	}
+	if contentType := resp.Header.Get("Content-Type"); contentType != dnsMessageContentType {
+		return nil, fmt.Errorf("upstream responded with content type %q", contentType)
+	}
+	response, err := io.ReadAll(io.LimitReader(resp.Body, maxMessageSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(response) < 12 || len(response) > maxMessageSize {
+		return nil, fmt.Errorf("upstream responded with a message of %d bytes", len(response))
+	}
+	binary.BigEndian.PutUint16(response, id)
+	return response, nil
+}
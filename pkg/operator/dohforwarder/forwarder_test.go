@@ -0,0 +1,159 @@
+package dohforwarder
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// testQuery is a query for "example.com. IN A" with ID 0x1234.
+var testQuery = []byte{
+	0x12, 0x34, 0x01, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 3, 'c', 'o', 'm', 0,
+	0x00, 0x01, 0x00, 0x01,
+}
+
+// newTestUpstream returns a DNS-over-HTTPS server that responds to each query
+// with the query with the QR bit set and fails the test if the query does not
+// have ID 0.
+func newTestUpstream(t *testing.T) *httptest.Server {
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost || req.Header.Get("Content-Type") != dnsMessageContentType {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+		query, err := io.ReadAll(req.Body)
+		if err != nil {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+		if id := binary.BigEndian.Uint16(query); id != 0 {
+			t.Errorf("expected query ID 0, got %#x", id)
+		}
+		query[2] |= 0x80
+		w.Header().Set("Content-Type", dnsMessageContentType)
+		w.Write(query)
+	}))
+}
+
+// expectedResponse returns the response that the test upstream sends for the
+// test query, with the query's ID.
+func expectedResponse() []byte {
+	response := append([]byte(nil), testQuery...)
+	response[2] |= 0x80
+	return response
+}
+
+// TestParseRoute verifies that ParseRoute accepts only a listen address and an
+// https URL.
+func TestParseRoute(t *testing.T) {
+	testCases := []struct {
+		value       string
+		expectError bool
+	}{
+		{"127.0.0.1:5400=https://dns.example.com/dns-query", false},
+		{"127.0.0.1:5400=http://dns.example.com/dns-query", true},
+		{"127.0.0.1=https://dns.example.com/dns-query", true},
+		{"https://dns.example.com/dns-query", true},
+	}
+	for _, tc := range testCases {
+		route, err := ParseRoute(tc.value)
+		switch {
+		case tc.expectError && err == nil:
+			t.Errorf("%q: expected an error, got %v", tc.value, route)
+		case !tc.expectError && err != nil:
+			t.Errorf("%q: unexpected error: %v", tc.value, err)
+		case !tc.expectError && route.String() != tc.value:
+			t.Errorf("%q: expected the route to round-trip, got %q", tc.value, route.String())
+		}
+	}
+}
+
+// TestForwarder verifies that the forwarder forwards queries over UDP and TCP
+// to the upstream and relays its responses with the queries' IDs.
+func TestForwarder(t *testing.T) {
+	upstream := newTestUpstream(t)
+	defer upstream.Close()
+	f := New(nil)
+	f.client = upstream.Client()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	udpConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go f.serveUDP(ctx, udpConn, upstream.URL+"/dns-query")
+	client, err := net.Dial("udp", udpConn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	client.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := client.Write(testQuery); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 512)
+	n, err := client.Read(buf)
+	if err != nil {
+		t.Fatalf("failed to read udp response: %v", err)
+	}
+	if !bytes.Equal(buf[:n], expectedResponse()) {
+		t.Errorf("expected udp response %x, got %x", expectedResponse(), buf[:n])
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go f.serveTCP(ctx, listener, upstream.URL+"/dns-query")
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	for i := 0; i < 2; i++ {
+		framed := make([]byte, 2+len(testQuery))
+		binary.BigEndian.PutUint16(framed, uint16(len(testQuery)))
+		copy(framed[2:], testQuery)
+		if _, err := conn.Write(framed); err != nil {
+			t.Fatal(err)
+		}
+		var length uint16
+		if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+			t.Fatalf("failed to read tcp response: %v", err)
+		}
+		response := make([]byte, length)
+		if _, err := io.ReadFull(conn, response); err != nil {
+			t.Fatalf("failed to read tcp response: %v", err)
+		}
+		if !bytes.Equal(response, expectedResponse()) {
+			t.Errorf("expected tcp response %x, got %x", expectedResponse(), response)
+		}
+	}
+}
+
+// TestExchangeFailure verifies that the forwarder reports an upstream that does
+// not respond with a DNS message.
+func TestExchangeFailure(t *testing.T) {
+	upstream := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html></html>"))
+	}))
+	defer upstream.Close()
+	f := New(nil)
+	f.client = upstream.Client()
+	if _, err := f.exchange(context.Background(), upstream.URL, testQuery); err == nil {
+		t.Errorf("expected an error for a response that is not a DNS message")
+	}
+	if _, err := f.exchange(context.Background(), upstream.URL, testQuery[:4]); err == nil {
+		t.Errorf("expected an error for a truncated query")
+	}
+}
@@ -0,0 +1,199 @@
+// Package dryrun implements a client for running the operator in dry-run mode.
+// In dry-run mode, the operator reconciles as usual, but the API server
+// validates and defaults each write without persisting it, and the operator
+// logs the change that each write would have made.  This allows evaluating
+// the impact of an operator upgrade on a heavily customized cluster before
+// letting the new operator manage it.
+package dryrun
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/sirupsen/logrus"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+const (
+	// VerbCreate, VerbUpdate, VerbPatch, and VerbDelete are the values of
+	// the verb label of the writes metric.
+	VerbCreate = "create"
+	VerbUpdate = "update"
+	VerbPatch  = "patch"
+	VerbDelete = "delete"
+)
+
+// ignoredMetadata are the metadata fields that the API server sets on every
+// write and that are omitted from diffs.
+var ignoredMetadata = cmpopts.IgnoreFields(metav1.ObjectMeta{}, "ResourceVersion", "Generation", "ManagedFields")
+
+// Client is a client.Client that enforces dry-run mode on every write and logs
+// the change that the write would have made.  It is also a
+// prometheus.Collector that counts the writes.
+type Client struct {
+	client.Client
+
+	writes *prometheus.CounterVec
+}
+
+var _ client.Client = &Client{}
+var _ prometheus.Collector = &Client{}
+
+// New returns a client that wraps the given client in dry-run mode.
+func New(c client.Client) *Client {
+	return &Client{
+		Client: client.NewDryRunClient(c),
+		writes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "dns_operator_dry_run_writes_total",
+			Help: "Number of writes that the operator would have made if it were not in dry-run mode, by verb and kind.",
+		}, []string{"verb", "kind"}),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Client) Describe(ch chan<- *prometheus.Desc) {
+	c.writes.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *Client) Collect(ch chan<- prometheus.Metric) {
+	c.writes.Collect(ch)
+}
+
+// Create implements client.Client.
+func (c *Client) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if err := c.Client.Create(ctx, obj, opts...); err != nil {
+		return err
+	}
+	c.record(VerbCreate, obj, "")
+	return nil
+}
+
+// Update implements client.Client.
+func (c *Client) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	current := c.current(ctx, obj)
+	if err := c.Client.Update(ctx, obj, opts...); err != nil {
+		return err
+	}
+	c.record(VerbUpdate, obj, diff(current, obj))
+	return nil
+}
+
+// Patch implements client.Client.
+func (c *Client) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	current := c.current(ctx, obj)
+	if err := c.Client.Patch(ctx, obj, patch, opts...); err != nil {
+		return err
+	}
+	c.record(VerbPatch, obj, diff(current, obj))
+	return nil
+}
+
+// Delete implements client.Client.
+func (c *Client) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	if err := c.Client.Delete(ctx, obj, opts...); err != nil {
+		return err
+	}
+	c.record(VerbDelete, obj, "")
+	return nil
+}
+
+// Status implements client.StatusClient.
+func (c *Client) Status() client.StatusWriter {
+	return &statusWriter{StatusWriter: c.Client.Status(), client: c}
+}
+
+// statusWriter is a client.StatusWriter that enforces dry-run mode on every
+// write and logs the change that the write would have made.
+type statusWriter struct {
+	client.StatusWriter
+
+	client *Client
+}
+
+// Update implements client.StatusWriter.
+func (sw *statusWriter) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	current := sw.client.current(ctx, obj)
+	if err := sw.StatusWriter.Update(ctx, obj, opts...); err != nil {
+		return err
+	}
+	sw.client.record(VerbUpdate, obj, diff(current, obj))
+	return nil
+}
+
+// Patch implements client.StatusWriter.
+func (sw *statusWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	current := sw.client.current(ctx, obj)
+	if err := sw.StatusWriter.Patch(ctx, obj, patch, opts...); err != nil {
+		return err
+	}
+	sw.client.record(VerbPatch, obj, diff(current, obj))
+	return nil
+}
+
+// current returns the current state of the given object, or nil if it cannot
+// be read, in which case the write is logged without a diff.
+func (c *Client) current(ctx context.Context, obj client.Object) client.Object {
+	current, ok := obj.DeepCopyObject().(client.Object)
+	if !ok {
+		return nil
+	}
+	if err := c.Client.Get(ctx, client.ObjectKeyFromObject(obj), current); err != nil {
+		logrus.Warningf("dry run: failed to get %s for diff: %v", describe(c.kind(obj), obj), err)
+		return nil
+	}
+	return current
+}
+
+// record logs and counts the given write of the given object, which has the
+// given diff.
+func (c *Client) record(verb string, obj client.Object, diff string) {
+	kind := c.kind(obj)
+	c.writes.WithLabelValues(verb, kind).Inc()
+	if len(diff) == 0 {
+		logrus.Infof("dry run: would %s %s", verb, describe(kind, obj))
+		return
+	}
+	logrus.Infof("dry run: would %s %s:\n%s", verb, describe(kind, obj), diff)
+}
+
+// kind returns the kind of the given object, or the empty string if the
+// client's scheme does not know it.
+func (c *Client) kind(obj client.Object) string {
+	gvk, err := apiutil.GVKForObject(obj, c.Scheme())
+	if err != nil {
+		return ""
+	}
+	return gvk.Kind
+}
+
+// describe returns the kind, namespace, and name of the given object for a log
+// message.
+func describe(kind string, obj client.Object) string {
+	if len(obj.GetNamespace()) == 0 {
+		return fmt.Sprintf("%s %s", kind, obj.GetName())
+	}
+	return fmt.Sprintf("%s %s/%s", kind, obj.GetNamespace(), obj.GetName())
+}
+
+// diff returns the difference between the given current object and the given
+// object as the API server would have written it, ignoring the metadata that
+// every write changes, or the empty string if the current object is unknown.
+func diff(current, updated client.Object) string {
+	if current == nil {
+		return ""
+	}
+	if d := cmp.Diff(current, updated, cmpopts.EquateEmpty(), ignoredMetadata); len(d) != 0 {
+		return d
+	}
+	return "(no changes)"
+}
@@ -0,0 +1,100 @@
+package dryrun
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	kscheme "k8s.io/client-go/kubernetes/scheme"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// fakeClient is a client.Client that returns a fixed configmap from Get and
+// records whether each write was a dry run.  Its other methods panic.
+type fakeClient struct {
+	client.Client
+
+	current *corev1.ConfigMap
+	dryRuns []bool
+}
+
+func (c *fakeClient) Scheme() *runtime.Scheme {
+	return kscheme.Scheme
+}
+
+func (c *fakeClient) Get(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+	c.current.DeepCopyInto(obj.(*corev1.ConfigMap))
+	return nil
+}
+
+func (c *fakeClient) Create(_ context.Context, _ client.Object, opts ...client.CreateOption) error {
+	c.dryRuns = append(c.dryRuns, len((&client.CreateOptions{}).ApplyOptions(opts).DryRun) != 0)
+	return nil
+}
+
+func (c *fakeClient) Update(_ context.Context, _ client.Object, opts ...client.UpdateOption) error {
+	c.dryRuns = append(c.dryRuns, len((&client.UpdateOptions{}).ApplyOptions(opts).DryRun) != 0)
+	return nil
+}
+
+func (c *fakeClient) Delete(_ context.Context, _ client.Object, opts ...client.DeleteOption) error {
+	c.dryRuns = append(c.dryRuns, len((&client.DeleteOptions{}).ApplyOptions(opts).DryRun) != 0)
+	return nil
+}
+
+// TestClient verifies that the client makes every write a dry run.
+func TestClient(t *testing.T) {
+	current := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "dns-default", Namespace: "openshift-dns", ResourceVersion: "1"},
+		Data:       map[string]string{"Corefile": "old"},
+	}
+	fake := &fakeClient{current: current}
+	c := New(fake)
+	updated := current.DeepCopy()
+	updated.Data["Corefile"] = "new"
+	if err := c.Create(context.TODO(), updated); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Update(context.TODO(), updated); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Delete(context.TODO(), updated); err != nil {
+		t.Fatal(err)
+	}
+	for i, dryRun := range fake.dryRuns {
+		if !dryRun {
+			t.Errorf("expected write %d to be a dry run", i)
+		}
+	}
+	if len(fake.dryRuns) != 3 {
+		t.Errorf("expected 3 writes, got %d", len(fake.dryRuns))
+	}
+}
+
+// TestDiff verifies that diff reports changed fields and ignores the metadata
+// that every write changes.
+func TestDiff(t *testing.T) {
+	current := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "dns-default", ResourceVersion: "1"},
+		Data:       map[string]string{"Corefile": "old"},
+	}
+	unchanged := current.DeepCopy()
+	unchanged.ResourceVersion = "2"
+	if d := diff(current, unchanged); d != "(no changes)" {
+		t.Errorf("expected no changes, got %q", d)
+	}
+	changed := unchanged.DeepCopy()
+	changed.Data["Corefile"] = "new"
+	if d := diff(current, changed); !strings.Contains(d, `"old"`) || !strings.Contains(d, `"new"`) {
+		t.Errorf("expected diff of Corefile, got %q", d)
+	}
+	if d := diff(nil, changed); len(d) != 0 {
+		t.Errorf("expected no diff for an unknown current object, got %q", d)
+	}
+}
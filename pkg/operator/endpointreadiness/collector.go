@@ -0,0 +1,167 @@
+// Package endpointreadiness reports, as metrics on the operator's metrics
+// endpoint, whether the default DNS's service publishes not-ready addresses
+// and how far the readiness of the service's endpoints lags behind the
+// readiness of the dns pods.  During a rollout, endpoints can stay ready after
+// their pods have become not ready, which sends queries to pods that cannot
+// answer them, or stay not ready after their pods have become ready, which
+// reduces the capacity of the service.
+package endpointreadiness
+
+import (
+	"context"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	operatorcontroller "github.com/openshift/cluster-dns-operator/pkg/operator/controller"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/sirupsen/logrus"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// StateReady is the state of an endpoint that receives traffic.
+	StateReady = "ready"
+	// StateNotReady is the state of an endpoint that does not receive
+	// traffic because its pod is not ready.
+	StateNotReady = "not_ready"
+	// StateTerminating is the state of an endpoint whose pod is
+	// terminating.
+	StateTerminating = "terminating"
+)
+
+var (
+	publishNotReadyAddressesDesc = prometheus.NewDesc(
+		"dns_service_publish_not_ready_addresses",
+		"Whether the default DNS's service publishes the addresses of dns pods that are not ready (1) or not (0).",
+		nil, nil,
+	)
+	endpointsDesc = prometheus.NewDesc(
+		"dns_service_endpoints",
+		"Number of endpoints of the default DNS's service, by state.",
+		[]string{"state"}, nil,
+	)
+	readinessSkewDesc = prometheus.NewDesc(
+		"dns_service_endpoint_readiness_skew",
+		"Number of ready endpoints of the default DNS's service minus the number of ready dns pods.  A positive value means that endpoints send queries to pods that are not ready; a negative value means that ready pods do not receive queries yet.",
+		nil, nil,
+	)
+)
+
+// Collector is a prometheus.Collector that reports the readiness of the
+// default DNS's service endpoints.  Metrics are computed from the cache when
+// they are collected.
+type Collector struct {
+	// client is used to get the default DNS.  The DNS is cluster-scoped,
+	// and the operator's cache only serves the namespaces that it watches.
+	client client.Reader
+	// cache is used to get the DNS's service and daemonset and the
+	// service's endpoint slices.
+	cache client.Reader
+}
+
+// New returns a collector that uses the given client and cache.
+func New(client, cache client.Reader) *Collector {
+	return &Collector{client: client, cache: cache}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- publishNotReadyAddressesDesc
+	ch <- endpointsDesc
+	ch <- readinessSkewDesc
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	ctx := context.TODO()
+	dns := &operatorv1.DNS{}
+	if err := c.client.Get(ctx, operatorcontroller.DefaultDNSNamespaceName(), dns); err != nil {
+		if !errors.IsNotFound(err) {
+			logrus.Errorf("endpoint readiness collector failed to get default dns: %v", err)
+		}
+		return
+	}
+	svc := &corev1.Service{}
+	svcName := operatorcontroller.DNSServiceName(dns)
+	if err := c.cache.Get(ctx, svcName, svc); err != nil {
+		if !errors.IsNotFound(err) {
+			logrus.Errorf("endpoint readiness collector failed to get service %s: %v", svcName, err)
+		}
+		return
+	}
+	publish := 0.0
+	if svc.Spec.PublishNotReadyAddresses {
+		publish = 1
+	}
+	ch <- prometheus.MustNewConstMetric(publishNotReadyAddressesDesc, prometheus.GaugeValue, publish)
+
+	sliceList := &discoveryv1.EndpointSliceList{}
+	listOpts := []client.ListOption{
+		client.InNamespace(svcName.Namespace),
+		client.MatchingLabels{discoveryv1.LabelServiceName: svcName.Name},
+	}
+	if err := c.cache.List(ctx, sliceList, listOpts...); err != nil {
+		logrus.Errorf("endpoint readiness collector failed to list endpoint slices for service %s: %v", svcName, err)
+		return
+	}
+	counts := countEndpoints(sliceList.Items)
+	for _, state := range []string{StateReady, StateNotReady, StateTerminating} {
+		ch <- prometheus.MustNewConstMetric(endpointsDesc, prometheus.GaugeValue, float64(counts[state]), state)
+	}
+
+	ds := &appsv1.DaemonSet{}
+	if err := c.cache.Get(ctx, operatorcontroller.DNSDaemonSetName(dns), ds); err != nil {
+		if !errors.IsNotFound(err) {
+			logrus.Errorf("endpoint readiness collector failed to get dns daemonset: %v", err)
+		}
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(readinessSkewDesc, prometheus.GaugeValue, float64(counts[StateReady]-int(ds.Status.NumberReady)))
+}
+
+// countEndpoints returns the number of endpoints in the given endpoint slices
+// by state.  A dual-stack service has an endpoint slice for each IP family,
+// so endpoints are counted once per target pod.
+func countEndpoints(slices []discoveryv1.EndpointSlice) map[string]int {
+	states := map[string]string{}
+	for _, slice := range slices {
+		for _, endpoint := range slice.Endpoints {
+			key := ""
+			switch {
+			case endpoint.TargetRef != nil:
+				key = endpoint.TargetRef.Namespace + "/" + endpoint.TargetRef.Name
+			case len(endpoint.Addresses) != 0:
+				key = endpoint.Addresses[0]
+			default:
+				continue
+			}
+			states[key] = endpointState(endpoint.Conditions)
+		}
+	}
+	counts := map[string]int{}
+	for _, state := range states {
+		counts[state]++
+	}
+	return counts
+}
+
+// endpointState returns the state of an endpoint with the given conditions.
+// An unknown ready condition means that the endpoint is ready.
+func endpointState(conditions discoveryv1.EndpointConditions) string {
+	switch {
+	case conditions.Terminating != nil && *conditions.Terminating:
+		return StateTerminating
+	case conditions.Ready != nil && !*conditions.Ready:
+		return StateNotReady
+	}
+	return StateReady
+}
@@ -0,0 +1,48 @@
+package endpointreadiness
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+)
+
+// TestCountEndpoints verifies that countEndpoints counts each target pod once
+// across the endpoint slices of a dual-stack service, by state.
+func TestCountEndpoints(t *testing.T) {
+	boolPtr := func(b bool) *bool { return &b }
+	endpoint := func(pod, address string, conditions discoveryv1.EndpointConditions) discoveryv1.Endpoint {
+		return discoveryv1.Endpoint{
+			Addresses:  []string{address},
+			Conditions: conditions,
+			TargetRef:  &corev1.ObjectReference{Kind: "Pod", Namespace: "openshift-dns", Name: pod},
+		}
+	}
+	slices := []discoveryv1.EndpointSlice{
+		{
+			AddressType: discoveryv1.AddressTypeIPv4,
+			Endpoints: []discoveryv1.Endpoint{
+				endpoint("dns-a", "10.0.0.1", discoveryv1.EndpointConditions{Ready: boolPtr(true)}),
+				endpoint("dns-b", "10.0.0.2", discoveryv1.EndpointConditions{Ready: boolPtr(false)}),
+				endpoint("dns-c", "10.0.0.3", discoveryv1.EndpointConditions{Ready: boolPtr(false), Terminating: boolPtr(true)}),
+				endpoint("dns-d", "10.0.0.4", discoveryv1.EndpointConditions{}),
+			},
+		},
+		{
+			AddressType: discoveryv1.AddressTypeIPv6,
+			Endpoints: []discoveryv1.Endpoint{
+				endpoint("dns-a", "fd00::1", discoveryv1.EndpointConditions{Ready: boolPtr(true)}),
+				endpoint("dns-b", "fd00::2", discoveryv1.EndpointConditions{Ready: boolPtr(false)}),
+			},
+		},
+	}
+	expected := map[string]int{
+		StateReady:       2,
+		StateNotReady:    1,
+		StateTerminating: 1,
+	}
+	if actual := countEndpoints(slices); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
@@ -0,0 +1,294 @@
+// Package harness runs the operator's controllers against a local API server
+// so that DNS behaviors can be tested without a full cluster.  The harness
+// uses controller-runtime's envtest package, which starts etcd and
+// kube-apiserver from the binaries in the directory that the
+// KUBEBUILDER_ASSETS environment variable names.  The API server has no
+// kubelets or controllers other than the operator's, so pods are never
+// scheduled and daemonsets never report available pods; tests can set the
+// status of such objects themselves to simulate cluster behavior.
+//
+// A test typically starts the harness, waits for the operator to create the
+// default DNS and its operands, and then inspects or modifies them:
+//
+//	h, err := harness.Start(harness.Options{})
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	defer h.Stop()
+//	dns, err := h.WaitForDNS(ctx, func(dns *operatorv1.DNS) bool {
+//		return len(dns.Status.ClusterIP) != 0
+//	})
+package harness
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	configv1 "github.com/openshift/api/config/v1"
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	"github.com/openshift/cluster-dns-operator/pkg/operator"
+	operatorclient "github.com/openshift/cluster-dns-operator/pkg/operator/client"
+	operatorconfig "github.com/openshift/cluster-dns-operator/pkg/operator/config"
+	operatorcontroller "github.com/openshift/cluster-dns-operator/pkg/operator/controller"
+
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"k8s.io/client-go/rest"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+)
+
+const (
+	// DefaultOperatorNamespace is the operator namespace that the harness
+	// uses if Options.OperatorConfig does not specify one.
+	DefaultOperatorNamespace = "openshift-dns-operator"
+	// DefaultServiceNetwork is the service network that the harness
+	// publishes in the cluster network config if Options.ServiceNetwork is
+	// empty.  The DNS service's cluster IP is the tenth address in the
+	// service network.
+	DefaultServiceNetwork = "172.30.0.0/16"
+
+	// pollInterval is the interval at which the harness's wait functions
+	// poll the API server.
+	pollInterval = 250 * time.Millisecond
+)
+
+// Options configures the harness.
+type Options struct {
+	// OperatorConfig is the configuration for the operator.  Images that
+	// are not set default to placeholder values; the images are never
+	// pulled.
+	OperatorConfig operatorconfig.Config
+	// ServiceNetwork is the service network CIDR to publish in the cluster
+	// network config.  The default is DefaultServiceNetwork.
+	ServiceNetwork string
+	// CRDDirectoryPaths are additional directories or files from which to
+	// load CRDs, for example for resources that a downstream test creates.
+	CRDDirectoryPaths []string
+	// AttachControlPlaneOutput causes the output of etcd and
+	// kube-apiserver to be written to the test's standard output and
+	// standard error.
+	AttachControlPlaneOutput bool
+}
+
+// Harness is a running API server with the operator's controllers.
+type Harness struct {
+	// Config is the REST config for the API server.
+	Config *rest.Config
+	// Client is a client for the API server that is configured with the
+	// operator's scheme.
+	Client client.Client
+
+	env     *envtest.Environment
+	cancel  context.CancelFunc
+	errChan chan error
+}
+
+// Start starts an API server, installs the CRDs that the operator uses,
+// publishes a cluster network config, and starts the operator.  The caller
+// must call Stop when it is done with the harness.
+func Start(opts Options) (*Harness, error) {
+	crdPaths, err := defaultCRDPaths()
+	if err != nil {
+		return nil, err
+	}
+	env := &envtest.Environment{
+		CRDDirectoryPaths:        append(crdPaths, opts.CRDDirectoryPaths...),
+		CRDs:                     []client.Object{serviceMonitorCRD()},
+		ErrorIfCRDPathMissing:    true,
+		AttachControlPlaneOutput: opts.AttachControlPlaneOutput,
+	}
+	kubeConfig, err := env.Start()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start test environment: %w", err)
+	}
+	h := &Harness{Config: kubeConfig, env: env}
+
+	h.Client, err = client.New(kubeConfig, client.Options{Scheme: operatorclient.GetScheme()})
+	if err != nil {
+		h.stopEnvironment()
+		return nil, fmt.Errorf("failed to create client: %w", err)
+	}
+
+	config := opts.OperatorConfig
+	setConfigDefaults(&config)
+	serviceNetwork := opts.ServiceNetwork
+	if len(serviceNetwork) == 0 {
+		serviceNetwork = DefaultServiceNetwork
+	}
+	if err := h.bootstrap(config.OperatorNamespace, serviceNetwork); err != nil {
+		h.stopEnvironment()
+		return nil, err
+	}
+
+	op, err := operator.New(config, kubeConfig)
+	if err != nil {
+		h.stopEnvironment()
+		return nil, fmt.Errorf("failed to create operator: %w", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	h.cancel = cancel
+	h.errChan = make(chan error, 1)
+	go func() {
+		h.errChan <- op.Start(ctx)
+	}()
+
+	return h, nil
+}
+
+// Stop stops the operator and the API server.
+func (h *Harness) Stop() error {
+	h.cancel()
+	var opErr error
+	select {
+	case opErr = <-h.errChan:
+	case <-time.After(30 * time.Second):
+		opErr = fmt.Errorf("timed out waiting for the operator to stop")
+	}
+	if err := h.env.Stop(); err != nil {
+		return fmt.Errorf("failed to stop test environment: %w", err)
+	}
+	return opErr
+}
+
+// WaitForDNS polls the default DNS until the given function returns true for
+// it or the given context is done, and returns the last DNS that was gotten.
+func (h *Harness) WaitForDNS(ctx context.Context, done func(*operatorv1.DNS) bool) (*operatorv1.DNS, error) {
+	dns := &operatorv1.DNS{}
+	err := wait.PollImmediateUntil(pollInterval, func() (bool, error) {
+		if err := h.Client.Get(ctx, operatorcontroller.DefaultDNSNamespaceName(), dns); err != nil {
+			if errors.IsNotFound(err) {
+				return false, nil
+			}
+			return false, err
+		}
+		return done(dns), nil
+	}, ctx.Done())
+	return dns, err
+}
+
+// WaitForObject polls the object with the given key until it exists and the
+// given function returns true for it or the given context is done.  The
+// object is updated in place.
+func (h *Harness) WaitForObject(ctx context.Context, key client.ObjectKey, obj client.Object, done func() bool) error {
+	return wait.PollImmediateUntil(pollInterval, func() (bool, error) {
+		if err := h.Client.Get(ctx, key, obj); err != nil {
+			if errors.IsNotFound(err) {
+				return false, nil
+			}
+			return false, err
+		}
+		return done(), nil
+	}, ctx.Done())
+}
+
+// stopEnvironment stops the API server after a failure to start the harness.
+func (h *Harness) stopEnvironment() {
+	if err := h.env.Stop(); err != nil {
+		fmt.Printf("failed to stop test environment: %v\n", err)
+	}
+}
+
+// bootstrap creates the resources that the installer and other operators
+// create on a real cluster and that the operator expects to exist.
+func (h *Harness) bootstrap(operatorNamespace, serviceNetwork string) error {
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: operatorNamespace},
+	}
+	if err := h.Client.Create(context.TODO(), ns); err != nil && !errors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create namespace %s: %w", operatorNamespace, err)
+	}
+	// The network CRD has no status subresource, so the status is set on
+	// create.
+	network := &configv1.Network{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster"},
+		Status: configv1.NetworkStatus{
+			ServiceNetwork: []string{serviceNetwork},
+		},
+	}
+	if err := h.Client.Create(context.TODO(), network); err != nil {
+		return fmt.Errorf("failed to create network config: %w", err)
+	}
+	return nil
+}
+
+// setConfigDefaults sets defaults for the unset fields of the given operator
+// config.
+func setConfigDefaults(config *operatorconfig.Config) {
+	if len(config.OperatorNamespace) == 0 {
+		config.OperatorNamespace = DefaultOperatorNamespace
+	}
+	if len(config.CoreDNSImage) == 0 {
+		config.CoreDNSImage = "quay.io/openshift/origin-coredns:harness"
+	}
+	if len(config.OpenshiftCLIImage) == 0 {
+		config.OpenshiftCLIImage = "quay.io/openshift/origin-cli:harness"
+	}
+	if len(config.KubeRBACProxyImage) == 0 {
+		config.KubeRBACProxyImage = "quay.io/openshift/origin-kube-rbac-proxy:harness"
+	}
+	if len(config.OperatorReleaseVersion) == 0 {
+		config.OperatorReleaseVersion = "0.0.1-harness"
+	}
+	if len(config.MetricsAuthentication) == 0 {
+		config.MetricsAuthentication = operatorconfig.MetricsAuthenticationKubeRBACProxy
+	}
+}
+
+// defaultCRDPaths returns the paths of the CRDs for the DNS, network config,
+// and ClusterOperator resources, relative to the root of this module.
+func defaultCRDPaths() ([]string, error) {
+	_, file, _, ok := runtime.Caller(0)
+	if !ok {
+		return nil, fmt.Errorf("failed to determine the location of the harness package")
+	}
+	root := filepath.Join(filepath.Dir(file), "..", "..", "..")
+	configAPI := filepath.Join(root, "vendor", "github.com", "openshift", "api", "config", "v1")
+	return []string{
+		filepath.Join(root, "manifests", "0000_70_dns-operator_00-custom-resource-definition.yaml"),
+		filepath.Join(configAPI, "0000_00_cluster-version-operator_01_clusteroperator.crd.yaml"),
+		filepath.Join(configAPI, "0000_10_config-operator_01_network.crd.yaml"),
+	}, nil
+}
+
+// serviceMonitorCRD returns a minimal CRD for the Prometheus operator's
+// ServiceMonitor resource, which the operator creates for each DNS.
+func serviceMonitorCRD() *apiextensionsv1.CustomResourceDefinition {
+	preserveUnknownFields := true
+	return &apiextensionsv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "servicemonitors.monitoring.coreos.com",
+		},
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Group: "monitoring.coreos.com",
+			Names: apiextensionsv1.CustomResourceDefinitionNames{
+				Kind:     "ServiceMonitor",
+				ListKind: "ServiceMonitorList",
+				Plural:   "servicemonitors",
+				Singular: "servicemonitor",
+			},
+			Scope: apiextensionsv1.NamespaceScoped,
+			Versions: []apiextensionsv1.CustomResourceDefinitionVersion{{
+				Name:    "v1",
+				Served:  true,
+				Storage: true,
+				Schema: &apiextensionsv1.CustomResourceValidation{
+					OpenAPIV3Schema: &apiextensionsv1.JSONSchemaProps{
+						Type:                   "object",
+						XPreserveUnknownFields: &preserveUnknownFields,
+					},
+				},
+			}},
+		},
+	}
+}
@@ -0,0 +1,56 @@
+package harness
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	operatorcontroller "github.com/openshift/cluster-dns-operator/pkg/operator/controller"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// TestHarness verifies that the operator creates the default DNS and its
+// operands when it runs in the harness.
+func TestHarness(t *testing.T) {
+	if len(os.Getenv("KUBEBUILDER_ASSETS")) == 0 {
+		t.Skip("KUBEBUILDER_ASSETS is not set")
+	}
+	h, err := Start(Options{})
+	if err != nil {
+		t.Fatalf("failed to start harness: %v", err)
+	}
+	defer func() {
+		if err := h.Stop(); err != nil {
+			t.Errorf("failed to stop harness: %v", err)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	dns, err := h.WaitForDNS(ctx, func(dns *operatorv1.DNS) bool {
+		return len(dns.Status.ClusterIP) != 0
+	})
+	if err != nil {
+		t.Fatalf("failed to observe dns status: %v", err)
+	}
+	if e, a := "172.30.0.10", dns.Status.ClusterIP; e != a {
+		t.Errorf("expected cluster IP %s, got %s", e, a)
+	}
+
+	ds := &appsv1.DaemonSet{}
+	if err := h.WaitForObject(ctx, operatorcontroller.DNSDaemonSetName(dns), ds, func() bool { return true }); err != nil {
+		t.Fatalf("failed to observe dns daemonset: %v", err)
+	}
+	cm := &corev1.ConfigMap{}
+	if err := h.WaitForObject(ctx, operatorcontroller.DNSConfigMapName(dns), cm, func() bool {
+		return len(cm.Data["Corefile"]) != 0
+	}); err != nil {
+		t.Fatalf("failed to observe dns configmap: %v", err)
+	}
+}
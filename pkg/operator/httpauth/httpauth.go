@@ -0,0 +1,103 @@
+// Package httpauth provides the authentication, authorization, and TLS
+// serving helpers that the operator's HTTP endpoints share.  Requests are
+// authenticated with a bearer token using a TokenReview and authorized using a
+// SubjectAccessReview, which matches what kube-rbac-proxy does.
+package httpauth
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// BearerToken returns the bearer token from the request's Authorization
+// header, or the empty string if the request has none.
+func BearerToken(req *http.Request) string {
+	auth := strings.TrimSpace(req.Header.Get("Authorization"))
+	parts := strings.SplitN(auth, " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "bearer") {
+		return ""
+	}
+	return strings.TrimSpace(parts[1])
+}
+
+// TokenReview authenticates the given bearer token using a TokenReview.  It
+// returns the user that the token identifies, or nil if the token is not
+// valid.
+func TokenReview(ctx context.Context, cl client.Client, token string) (*authenticationv1.UserInfo, error) {
+	review := &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{
+			Token: token,
+		},
+	}
+	if err := cl.Create(ctx, review); err != nil {
+		return nil, fmt.Errorf("failed to create tokenreview: %w", err)
+	}
+	if !review.Status.Authenticated {
+		return nil, nil
+	}
+	return &review.Status.User, nil
+}
+
+// SubjectAccessReview returns a Boolean value indicating whether the given
+// user is allowed to perform the request that the given spec describes.  The
+// user fields of the spec are set from the given user.
+func SubjectAccessReview(ctx context.Context, cl client.Client, user *authenticationv1.UserInfo, spec authorizationv1.SubjectAccessReviewSpec) (bool, error) {
+	extra := map[string]authorizationv1.ExtraValue{}
+	for k, v := range user.Extra {
+		extra[k] = authorizationv1.ExtraValue(v)
+	}
+	spec.User = user.Username
+	spec.UID = user.UID
+	spec.Groups = user.Groups
+	spec.Extra = extra
+	review := &authorizationv1.SubjectAccessReview{Spec: spec}
+	if err := cl.Create(ctx, review); err != nil {
+		return false, fmt.Errorf("failed to create subjectaccessreview: %w", err)
+	}
+	return review.Status.Allowed, nil
+}
+
+// ServeTLS serves the given handler on the given address until the given
+// context is done.  The serving certificate and key are loaded from the given
+// files on each handshake so that rotated certificates are picked up without
+// restarting the operator.
+func ServeTLS(ctx context.Context, name, address, certFile, keyFile string, handler http.Handler) error {
+	server := &http.Server{
+		Addr:    address,
+		Handler: handler,
+		TLSConfig: &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			GetCertificate: func(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+				cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+				if err != nil {
+					return nil, fmt.Errorf("failed to load serving certificate: %w", err)
+				}
+				return &cert, nil
+			},
+		},
+	}
+	errChan := make(chan error, 1)
+	go func() {
+		logrus.Infof("starting %s on %s", name, address)
+		errChan <- server.ListenAndServeTLS("", "")
+	}()
+	select {
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return server.Shutdown(shutdownCtx)
+	case err := <-errChan:
+		return fmt.Errorf("%s failed: %w", name, err)
+	}
+}
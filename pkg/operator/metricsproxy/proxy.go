@@ -0,0 +1,235 @@
+// Package metricsproxy implements an authenticating proxy for CoreDNS metrics
+// that runs inside the operator.  It is an alternative to running a
+// kube-rbac-proxy sidecar in every dns pod: scrapes are sent to the operator
+// with the address of a dns pod in the "target" query parameter, and the
+// proxy authenticates and authorizes the scrape using TokenReview and
+// SubjectAccessReview before fetching the metrics from the pod.
+package metricsproxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	operatorcontroller "github.com/openshift/cluster-dns-operator/pkg/operator/controller"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/httpauth"
+
+	"github.com/sirupsen/logrus"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// upstreamTimeout is the maximum time to wait for a dns pod to respond
+	// to a metrics request.
+	upstreamTimeout = 10 * time.Second
+)
+
+// Proxy is an http.Handler that authenticates and authorizes metrics scrapes
+// and forwards them to dns pods.
+type Proxy struct {
+	// client is used to create TokenReviews and SubjectAccessReviews.
+	client client.Client
+	// cache is used to look up dns pods.
+	cache client.Reader
+
+	listenAddress string
+	certFile      string
+	keyFile       string
+
+	// authenticate returns the user that the given bearer token
+	// identifies, or nil if the token is not valid.
+	authenticate func(ctx context.Context, token string) (*authenticationv1.UserInfo, error)
+	// authorize returns a Boolean value indicating whether the given
+	// user may get the given non-resource path.
+	authorize func(ctx context.Context, user *authenticationv1.UserInfo, path string) (bool, error)
+	// reviews caches the results of TokenReviews and
+	// SubjectAccessReviews.
+	reviews *reviewCache
+
+	httpClient *http.Client
+}
+
+// New returns a metrics proxy that listens on the given address and serves
+// TLS using the given certificate and key files.
+func New(client client.Client, cache client.Reader, listenAddress, certFile, keyFile string) *Proxy {
+	p := &Proxy{
+		client:        client,
+		cache:         cache,
+		listenAddress: listenAddress,
+		certFile:      certFile,
+		keyFile:       keyFile,
+		httpClient:    &http.Client{Timeout: upstreamTimeout},
+		reviews:       newReviewCache(),
+	}
+	p.authenticate = p.tokenReview
+	p.authorize = p.subjectAccessReview
+	return p
+}
+
+// Start runs the proxy until the given context is done.  Start implements
+// manager.Runnable.
+func (p *Proxy) Start(ctx context.Context) error {
+	return httpauth.ServeTLS(ctx, "metrics proxy", p.listenAddress, p.certFile, p.keyFile, p)
+}
+
+// ServeHTTP authenticates and authorizes the request and then proxies it to
+// the dns pod that the request's "target" query parameter specifies.
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	token := httpauth.BearerToken(req)
+	if len(token) == 0 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	user, err := p.authenticate(ctx, token)
+	if err != nil {
+		logrus.Errorf("metrics proxy failed to authenticate request: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	allowed, err := p.authorize(ctx, user, req.URL.Path)
+	if err != nil {
+		logrus.Errorf("metrics proxy failed to authorize request for user %s: %v", user.Username, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if !allowed {
+		http.Error(w, fmt.Sprintf("Forbidden (user=%s, verb=get, path=%s)", user.Username, req.URL.Path), http.StatusForbidden)
+		return
+	}
+
+	target := req.URL.Query().Get("target")
+	ok, err := p.isDNSPodMetricsAddress(ctx, target)
+	if err != nil {
+		logrus.Errorf("metrics proxy failed to validate target %q: %v", target, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, fmt.Sprintf("target %q is not a dns pod metrics address", target), http.StatusBadRequest)
+		return
+	}
+
+	upstreamReq, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+target+req.URL.Path, nil)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if accept := req.Header.Get("Accept"); len(accept) != 0 {
+		upstreamReq.Header.Set("Accept", accept)
+	}
+	resp, err := p.httpClient.Do(upstreamReq)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to get metrics from %s: %v", target, err), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	for _, h := range []string{"Content-Type", "Content-Encoding"} {
+		if v := resp.Header.Get(h); len(v) != 0 {
+			w.Header().Set(h, v)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		logrus.Warningf("metrics proxy failed to copy response from %s: %v", target, err)
+	}
+}
+
+// tokenReview authenticates the given bearer token using a TokenReview.  The
+// result is cached for a short time.
+func (p *Proxy) tokenReview(ctx context.Context, token string) (*authenticationv1.UserInfo, error) {
+	key, err := reviewCacheKey("tokenreview", token)
+	if err != nil {
+		return nil, err
+	}
+	if user, ok := p.reviews.get(key); ok {
+		return user.(*authenticationv1.UserInfo), nil
+	}
+	user, err := httpauth.TokenReview(ctx, p.client, token)
+	if err != nil {
+		return nil, err
+	}
+	p.reviews.set(key, user, user != nil)
+	return user, nil
+}
+
+// subjectAccessReview authorizes a get request by the given user for the
+// given non-resource path using a SubjectAccessReview, which is the same
+// check that kube-rbac-proxy performs.  The result is cached for a short
+// time.
+func (p *Proxy) subjectAccessReview(ctx context.Context, user *authenticationv1.UserInfo, path string) (bool, error) {
+	key, err := reviewCacheKey("subjectaccessreview", struct {
+		User *authenticationv1.UserInfo
+		Path string
+	}{user, path})
+	if err != nil {
+		return false, err
+	}
+	if allowed, ok := p.reviews.get(key); ok {
+		return allowed.(bool), nil
+	}
+	allowed, err := httpauth.SubjectAccessReview(ctx, p.client, user, authorizationv1.SubjectAccessReviewSpec{
+		NonResourceAttributes: &authorizationv1.NonResourceAttributes{
+			Path: path,
+			Verb: "get",
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+	p.reviews.set(key, allowed, allowed)
+	return allowed, nil
+}
+
+// isDNSPodMetricsAddress returns a Boolean value indicating whether the given
+// host:port address is the CoreDNS metrics address of a dns pod.  Restricting
+// targets to dns pods prevents the proxy from being used to reach arbitrary
+// endpoints with the operator's network identity.
+func (p *Proxy) isDNSPodMetricsAddress(ctx context.Context, target string) (bool, error) {
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		return false, nil
+	}
+	if port != strconv.Itoa(operatorcontroller.CoreDNSMetricsPort) {
+		return false, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(operatorcontroller.AllDNSDaemonSetPodsSelector())
+	if err != nil {
+		return false, err
+	}
+	podList := &corev1.PodList{}
+	listOpts := []client.ListOption{
+		client.MatchingLabelsSelector{Selector: selector},
+		client.InNamespace(operatorcontroller.DefaultOperandNamespace),
+	}
+	if err := p.cache.List(ctx, podList, listOpts...); err != nil {
+		return false, fmt.Errorf("failed to list dns pods: %w", err)
+	}
+	for _, pod := range podList.Items {
+		if len(pod.Status.PodIP) != 0 && pod.Status.PodIP == host {
+			return true, nil
+		}
+		for _, podIP := range pod.Status.PodIPs {
+			if podIP.IP == host {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,223 @@
+package metricsproxy
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// fakePodReader is a client.Reader that lists a fixed set of pods.
+type fakePodReader struct {
+	client.Reader
+	pods []corev1.Pod
+}
+
+func (r *fakePodReader) List(_ context.Context, list client.ObjectList, _ ...client.ListOption) error {
+	list.(*corev1.PodList).Items = r.pods
+	return nil
+}
+
+// fakeReviewClient is a client.Client that counts the TokenReviews and
+// SubjectAccessReviews that it creates.  It authenticates the token "valid" as
+// the user "prometheus", which may get only "/metrics".
+type fakeReviewClient struct {
+	client.Client
+	tokenReviews, subjectAccessReviews int
+}
+
+func (c *fakeReviewClient) Create(_ context.Context, obj client.Object, _ ...client.CreateOption) error {
+	switch review := obj.(type) {
+	case *authenticationv1.TokenReview:
+		c.tokenReviews++
+		if review.Spec.Token == "valid" {
+			review.Status.Authenticated = true
+			review.Status.User = authenticationv1.UserInfo{Username: "prometheus"}
+		}
+	case *authorizationv1.SubjectAccessReview:
+		c.subjectAccessReviews++
+		review.Status.Allowed = review.Spec.User == "prometheus" && review.Spec.NonResourceAttributes.Path == "/metrics"
+	}
+	return nil
+}
+
+// roundTripFunc is an http.RoundTripper backed by a function.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// TestServeHTTP verifies that the proxy authenticates and authorizes scrapes,
+// restricts targets to dns pods, and forwards permitted scrapes.
+func TestServeHTTP(t *testing.T) {
+	p := New(nil, &fakePodReader{pods: []corev1.Pod{{
+		Status: corev1.PodStatus{PodIP: "10.0.0.1"},
+	}}}, "", "", "")
+	p.authenticate = func(_ context.Context, token string) (*authenticationv1.UserInfo, error) {
+		switch token {
+		case "allowed", "forbidden":
+			return &authenticationv1.UserInfo{Username: token}, nil
+		}
+		return nil, nil
+	}
+	p.authorize = func(_ context.Context, user *authenticationv1.UserInfo, path string) (bool, error) {
+		return user.Username == "allowed" && path == "/metrics", nil
+	}
+	var upstreamURL string
+	p.httpClient = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		upstreamURL = req.URL.String()
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"text/plain"}},
+			Body:       ioutil.NopCloser(strings.NewReader("coredns_build_info 1\n")),
+		}, nil
+	})}
+
+	testCases := []struct {
+		description    string
+		token          string
+		target         string
+		expectStatus   int
+		expectUpstream string
+	}{
+		{
+			description:  "no token",
+			target:       "10.0.0.1:9153",
+			expectStatus: http.StatusUnauthorized,
+		},
+		{
+			description:  "invalid token",
+			token:        "invalid",
+			target:       "10.0.0.1:9153",
+			expectStatus: http.StatusUnauthorized,
+		},
+		{
+			description:  "unauthorized user",
+			token:        "forbidden",
+			target:       "10.0.0.1:9153",
+			expectStatus: http.StatusForbidden,
+		},
+		{
+			description:  "target is not a dns pod",
+			token:        "allowed",
+			target:       "10.0.0.2:9153",
+			expectStatus: http.StatusBadRequest,
+		},
+		{
+			description:  "target is not the metrics port",
+			token:        "allowed",
+			target:       "10.0.0.1:8080",
+			expectStatus: http.StatusBadRequest,
+		},
+		{
+			description:    "authorized scrape of a dns pod",
+			token:          "allowed",
+			target:         "10.0.0.1:9153",
+			expectStatus:   http.StatusOK,
+			expectUpstream: "http://10.0.0.1:9153/metrics",
+		},
+	}
+	for _, tc := range testCases {
+		upstreamURL = ""
+		req := httptest.NewRequest(http.MethodGet, "/metrics?target="+tc.target, nil)
+		if len(tc.token) != 0 {
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+		}
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, req)
+		if w.Code != tc.expectStatus {
+			t.Errorf("%s: expected status %d, got %d: %s", tc.description, tc.expectStatus, w.Code, w.Body.String())
+		}
+		if upstreamURL != tc.expectUpstream {
+			t.Errorf("%s: expected upstream request %q, got %q", tc.description, tc.expectUpstream, upstreamURL)
+		}
+	}
+}
+
+// TestReviewCache verifies that the proxy caches TokenReviews and
+// SubjectAccessReviews, that denied reviews expire sooner than allowed ones,
+// and that the cache does not grow beyond its maximum size.
+func TestReviewCache(t *testing.T) {
+	cl := &fakeReviewClient{}
+	p := New(cl, nil, "", "", "")
+	now := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	p.reviews.now = func() time.Time { return now }
+	ctx := context.TODO()
+
+	review := func(token, path string) {
+		user, err := p.authenticate(ctx, token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user == nil {
+			return
+		}
+		if _, err := p.authorize(ctx, user, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expect := func(description string, tokenReviews, subjectAccessReviews int) {
+		t.Helper()
+		if cl.tokenReviews != tokenReviews || cl.subjectAccessReviews != subjectAccessReviews {
+			t.Errorf("%s: expected %d tokenreviews and %d subjectaccessreviews, got %d and %d", description, tokenReviews, subjectAccessReviews, cl.tokenReviews, cl.subjectAccessReviews)
+		}
+	}
+
+	review("valid", "/metrics")
+	review("valid", "/metrics")
+	review("invalid", "/metrics")
+	review("invalid", "/metrics")
+	expect("repeated reviews", 2, 1)
+	review("valid", "/debug")
+	expect("another path", 2, 2)
+
+	now = now.Add(deniedReviewTTL)
+	review("valid", "/metrics")
+	review("valid", "/debug")
+	review("invalid", "/metrics")
+	expect("after denied reviews expire", 3, 3)
+
+	now = now.Add(allowedReviewTTL)
+	review("valid", "/metrics")
+	expect("after allowed reviews expire", 4, 4)
+
+	for i := 0; i < 2*maxReviewCacheEntries; i++ {
+		if _, err := p.authenticate(ctx, fmt.Sprintf("invalid-%d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(p.reviews.entries) > maxReviewCacheEntries {
+		t.Errorf("expected at most %d cached reviews, got %d", maxReviewCacheEntries, len(p.reviews.entries))
+	}
+}
+
+// TestReviewCacheKey verifies that reviewCacheKey returns an error rather than
+// panicking for a value that cannot be encoded, and distinct keys for distinct
+// kinds of reviews.
+func TestReviewCacheKey(t *testing.T) {
+	if _, err := reviewCacheKey("tokenreview", make(chan int)); err == nil {
+		t.Error("expected an error for a value that cannot be encoded")
+	}
+	a, err := reviewCacheKey("tokenreview", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := reviewCacheKey("subjectaccessreview", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == b {
+		t.Errorf("expected distinct keys for distinct kinds of reviews, got %q", a)
+	}
+}
@@ -0,0 +1,94 @@
+package metricsproxy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// allowedReviewTTL is how long the proxy caches a TokenReview that
+	// authenticated a token or a SubjectAccessReview that allowed a
+	// request.  Prometheus scrapes every dns pod with the same token
+	// several times a minute, so caching the reviews saves two API
+	// requests for nearly every scrape.
+	allowedReviewTTL = 2 * time.Minute
+	// deniedReviewTTL is how long the proxy caches a TokenReview that did
+	// not authenticate a token or a SubjectAccessReview that denied a
+	// request.  It is shorter than allowedReviewTTL so that a newly
+	// granted permission takes effect quickly.
+	deniedReviewTTL = 30 * time.Second
+	// maxReviewCacheEntries is the number of reviews that the proxy caches
+	// at most.  Requests with many distinct invalid tokens cannot grow the
+	// cache beyond this size.
+	maxReviewCacheEntries = 1024
+)
+
+// reviewCacheEntry is a cached review result.
+type reviewCacheEntry struct {
+	value   interface{}
+	expires time.Time
+}
+
+// reviewCache caches the results of TokenReviews and SubjectAccessReviews for
+// a short time.  Keys are hashes so that the cache does not hold tokens.
+type reviewCache struct {
+	lock    sync.Mutex
+	entries map[string]reviewCacheEntry
+	// now returns the current time.
+	now func() time.Time
+}
+
+// newReviewCache returns an empty review cache.
+func newReviewCache() *reviewCache {
+	return &reviewCache{entries: map[string]reviewCacheEntry{}, now: time.Now}
+}
+
+// reviewCacheKey returns the cache key for the given kind of review of the
+// given value, or an error if the value cannot be encoded.
+func reviewCacheKey(kind string, value interface{}) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode %s cache key: %w", kind, err)
+	}
+	sum := sha256.Sum256(append([]byte(kind+":"), data...))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// get returns the cached value for the given key and a Boolean value
+// indicating whether the cache has an unexpired value for the key.
+func (c *reviewCache) get(key string) (interface{}, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || !c.now().Before(entry.expires) {
+		return nil, false
+	}
+	return entry.value, true
+}
+
+// set caches the given value for the given key.  The value expires after
+// allowedReviewTTL if allowed is true and after deniedReviewTTL otherwise.
+func (c *reviewCache) set(key string, value interface{}, allowed bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	now := c.now()
+	if len(c.entries) >= maxReviewCacheEntries {
+		for k, entry := range c.entries {
+			if !now.Before(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= maxReviewCacheEntries {
+			c.entries = map[string]reviewCacheEntry{}
+		}
+	}
+	ttl := deniedReviewTTL
+	if allowed {
+		ttl = allowedReviewTTL
+	}
+	c.entries[key] = reviewCacheEntry{value: value, expires: now.Add(ttl)}
+}
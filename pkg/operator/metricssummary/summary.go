@@ -0,0 +1,331 @@
+// Package metricssummary periodically scrapes the CoreDNS metrics of every
+// dns pod and summarizes them as a few cluster-level gauges on the operator's
+// own metrics endpoint.  The summary is useful in environments that scrape
+// only the operator rather than every dns pod.
+//
+// The summarizer reaches CoreDNS's metrics port over the pod network, so it
+// can be used only when the operator's metrics proxy rather than
+// kube-rbac-proxy sidecars authenticates dns metrics scrapes.
+package metricssummary
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	operatorcontroller "github.com/openshift/cluster-dns-operator/pkg/operator/controller"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// scrapeInterval is the interval at which the summarizer scrapes the
+	// dns pods.  Rates are computed over this interval.
+	scrapeInterval = 30 * time.Second
+	// scrapeTimeout is the maximum time to wait for a dns pod to respond
+	// to a metrics request.
+	scrapeTimeout = 10 * time.Second
+)
+
+var (
+	requestsPerSecondDesc = prometheus.NewDesc(
+		"dns_cluster_requests_per_second",
+		"Rate of DNS requests across all dns pods over the last scrape interval.",
+		nil, nil,
+	)
+	errorRatioDesc = prometheus.NewDesc(
+		"dns_cluster_error_ratio",
+		"Fraction of DNS responses across all dns pods over the last scrape interval with the SERVFAIL or REFUSED response code.",
+		nil, nil,
+	)
+	cacheHitRatioDesc = prometheus.NewDesc(
+		"dns_cluster_cache_hit_ratio",
+		"Fraction of cache lookups across all dns pods over the last scrape interval that were hits.",
+		nil, nil,
+	)
+	podsDesc = prometheus.NewDesc(
+		"dns_cluster_summary_pods",
+		"Number of dns pods that the last summary covered, by whether the pods' metrics could be scraped.",
+		[]string{"scraped"}, nil,
+	)
+)
+
+// podCounters are the values of the CoreDNS counters that the summary uses,
+// summed over all label values, for one dns pod.
+type podCounters struct {
+	requests    float64
+	responses   float64
+	errors      float64
+	cacheHits   float64
+	cacheMisses float64
+}
+
+// summary is a cluster-level summary of dns metrics over one scrape interval.
+type summary struct {
+	requestsPerSecond float64
+	errorRatio        float64
+	cacheHitRatio     float64
+	scrapedPods       int
+	failedPods        int
+}
+
+// Summarizer is a prometheus.Collector that reports a summary of the dns
+// pods' metrics.  Start must be called to scrape the pods.
+type Summarizer struct {
+	// cache is used to look up dns pods.
+	cache      client.Reader
+	httpClient *http.Client
+
+	// scrape returns the counters of the dns pod at the given host:port
+	// address.
+	scrape func(ctx context.Context, address string) (podCounters, error)
+
+	mu sync.Mutex
+	// previous is the counters of each pod from the last scrape.
+	previous map[types.UID]podCounters
+	// lastScrape is the time of the last scrape.
+	lastScrape time.Time
+	// current is the latest summary, or nil if no summary has been
+	// computed yet.
+	current *summary
+	// podErrorRatios is the error ratio of each pod over the last scrape
+	// interval, or nil if no summary has been computed yet.
+	podErrorRatios map[types.UID]float64
+}
+
+// New returns a summarizer that uses the given cache to look up dns pods.
+func New(cache client.Reader) *Summarizer {
+	s := &Summarizer{
+		cache:      cache,
+		httpClient: &http.Client{Timeout: scrapeTimeout},
+		previous:   map[types.UID]podCounters{},
+	}
+	s.scrape = s.scrapePod
+	return s
+}
+
+// Start scrapes the dns pods periodically until the given context is done.
+// Start implements manager.Runnable.
+func (s *Summarizer) Start(ctx context.Context) error {
+	wait.UntilWithContext(ctx, s.update, scrapeInterval)
+	return nil
+}
+
+// Describe implements prometheus.Collector.
+func (s *Summarizer) Describe(ch chan<- *prometheus.Desc) {
+	ch <- requestsPerSecondDesc
+	ch <- errorRatioDesc
+	ch <- cacheHitRatioDesc
+	ch <- podsDesc
+}
+
+// Collect implements prometheus.Collector.  No metrics are reported until
+// the pods have been scraped twice, which is necessary to compute rates.
+func (s *Summarizer) Collect(ch chan<- prometheus.Metric) {
+	s.mu.Lock()
+	current := s.current
+	s.mu.Unlock()
+	if current == nil {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(requestsPerSecondDesc, prometheus.GaugeValue, current.requestsPerSecond)
+	ch <- prometheus.MustNewConstMetric(errorRatioDesc, prometheus.GaugeValue, current.errorRatio)
+	ch <- prometheus.MustNewConstMetric(cacheHitRatioDesc, prometheus.GaugeValue, current.cacheHitRatio)
+	ch <- prometheus.MustNewConstMetric(podsDesc, prometheus.GaugeValue, float64(current.scrapedPods), "true")
+	ch <- prometheus.MustNewConstMetric(podsDesc, prometheus.GaugeValue, float64(current.failedPods), "false")
+}
+
+// PodErrorRatios returns the fraction of each scraped dns pod's responses over
+// the last scrape interval with the SERVFAIL or REFUSED response code, by pod
+// UID.  A pod that sent no responses has a ratio of 0.  PodErrorRatios returns
+// nil until the pods have been scraped twice.
+func (s *Summarizer) PodErrorRatios() map[types.UID]float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.podErrorRatios == nil {
+		return nil
+	}
+	ratios := make(map[types.UID]float64, len(s.podErrorRatios))
+	for uid, ratio := range s.podErrorRatios {
+		ratios[uid] = ratio
+	}
+	return ratios
+}
+
+// update scrapes the dns pods and updates the summary.
+func (s *Summarizer) update(ctx context.Context) {
+	pods, err := s.listDNSPods(ctx)
+	if err != nil {
+		logrus.Errorf("metrics summary failed to list dns pods: %v", err)
+		return
+	}
+	now := time.Now()
+	counters := map[types.UID]podCounters{}
+	failed := 0
+	for _, pod := range pods {
+		if len(pod.Status.PodIP) == 0 {
+			continue
+		}
+		address := net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(operatorcontroller.CoreDNSMetricsPort))
+		c, err := s.scrape(ctx, address)
+		if err != nil {
+			logrus.Warningf("metrics summary failed to scrape dns pod %s/%s: %v", pod.Namespace, pod.Name, err)
+			failed++
+			continue
+		}
+		counters[pod.UID] = c
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.lastScrape.IsZero() {
+		current := computeSummary(s.previous, counters, now.Sub(s.lastScrape))
+		current.failedPods = failed
+		s.current = &current
+		s.podErrorRatios = computePodErrorRatios(s.previous, counters)
+	}
+	s.previous = counters
+	s.lastScrape = now
+}
+
+// listDNSPods returns the pods of all dns daemonsets.
+func (s *Summarizer) listDNSPods(ctx context.Context) ([]corev1.Pod, error) {
+	selector, err := metav1.LabelSelectorAsSelector(operatorcontroller.AllDNSDaemonSetPodsSelector())
+	if err != nil {
+		return nil, err
+	}
+	podList := &corev1.PodList{}
+	listOpts := []client.ListOption{
+		client.MatchingLabelsSelector{Selector: selector},
+		client.InNamespace(operatorcontroller.DefaultOperandNamespace),
+	}
+	if err := s.cache.List(ctx, podList, listOpts...); err != nil {
+		return nil, err
+	}
+	return podList.Items, nil
+}
+
+// scrapePod gets the metrics of the dns pod at the given address and returns
+// its counters.
+func (s *Summarizer) scrapePod(ctx context.Context, address string) (podCounters, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+address+"/metrics", nil)
+	if err != nil {
+		return podCounters{}, err
+	}
+	req.Header.Set("Accept", string(expfmt.FmtText))
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return podCounters{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return podCounters{}, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return parseCounters(resp.Body)
+}
+
+// parseCounters parses CoreDNS metrics in the Prometheus text format and
+// returns the counters that the summary uses.
+func parseCounters(r io.Reader) (podCounters, error) {
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(r)
+	if err != nil {
+		return podCounters{}, fmt.Errorf("failed to parse metrics: %w", err)
+	}
+	var c podCounters
+	c.requests = sumCounter(families["coredns_dns_requests_total"], nil)
+	c.responses = sumCounter(families["coredns_dns_responses_total"], nil)
+	c.errors = sumCounter(families["coredns_dns_responses_total"], func(m *dto.Metric) bool {
+		for _, label := range m.GetLabel() {
+			if label.GetName() == "rcode" {
+				return label.GetValue() == "SERVFAIL" || label.GetValue() == "REFUSED"
+			}
+		}
+		return false
+	})
+	c.cacheHits = sumCounter(families["coredns_cache_hits_total"], nil)
+	c.cacheMisses = sumCounter(families["coredns_cache_misses_total"], nil)
+	return c, nil
+}
+
+// sumCounter returns the sum of the values of the metrics in the given
+// counter family for which the given filter, if any, returns true.
+func sumCounter(family *dto.MetricFamily, filter func(*dto.Metric) bool) float64 {
+	if family == nil {
+		return 0
+	}
+	var sum float64
+	for _, m := range family.GetMetric() {
+		if filter != nil && !filter(m) {
+			continue
+		}
+		sum += m.GetCounter().GetValue()
+	}
+	return sum
+}
+
+// computeSummary returns the summary of the change from the given previous
+// counters to the given current counters over the given elapsed time.  A pod
+// that has no previous counters, or whose counters decreased because CoreDNS
+// restarted, contributes its entire current counters.
+func computeSummary(previous, current map[types.UID]podCounters, elapsed time.Duration) summary {
+	var delta podCounters
+	for uid, cur := range current {
+		prev := previous[uid]
+		if cur.requests < prev.requests || cur.responses < prev.responses || cur.cacheHits < prev.cacheHits || cur.cacheMisses < prev.cacheMisses {
+			prev = podCounters{}
+		}
+		delta.requests += cur.requests - prev.requests
+		delta.responses += cur.responses - prev.responses
+		delta.errors += cur.errors - prev.errors
+		delta.cacheHits += cur.cacheHits - prev.cacheHits
+		delta.cacheMisses += cur.cacheMisses - prev.cacheMisses
+	}
+	s := summary{scrapedPods: len(current)}
+	if elapsed > 0 {
+		s.requestsPerSecond = delta.requests / elapsed.Seconds()
+	}
+	if delta.responses > 0 {
+		s.errorRatio = delta.errors / delta.responses
+	}
+	if lookups := delta.cacheHits + delta.cacheMisses; lookups > 0 {
+		s.cacheHitRatio = delta.cacheHits / lookups
+	}
+	return s
+}
+
+// computePodErrorRatios returns the error ratio of each pod from the change
+// from the given previous counters to the given current counters.  As in
+// computeSummary, a pod whose counters decreased contributes its entire
+// current counters.
+func computePodErrorRatios(previous, current map[types.UID]podCounters) map[types.UID]float64 {
+	ratios := make(map[types.UID]float64, len(current))
+	for uid, cur := range current {
+		prev := previous[uid]
+		if cur.responses < prev.responses || cur.errors < prev.errors {
+			prev = podCounters{}
+		}
+		ratios[uid] = 0
+		if responses := cur.responses - prev.responses; responses > 0 {
+			ratios[uid] = (cur.errors - prev.errors) / responses
+		}
+	}
+	return ratios
+}
@@ -0,0 +1,198 @@
+package metricssummary
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	corev1 "k8s.io/api/core/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// fakePodReader is a client.Reader that lists a fixed set of pods.
+type fakePodReader struct {
+	client.Reader
+	pods []corev1.Pod
+}
+
+func (r *fakePodReader) List(_ context.Context, list client.ObjectList, _ ...client.ListOption) error {
+	list.(*corev1.PodList).Items = r.pods
+	return nil
+}
+
+// TestParseCounters verifies that parseCounters sums CoreDNS counters across
+// their labels and counts SERVFAIL and REFUSED responses as errors.
+func TestParseCounters(t *testing.T) {
+	metrics := `# TYPE coredns_dns_requests_total counter
+coredns_dns_requests_total{family="1",proto="udp",server="dns://:5353",type="A",zone="."} 100
+coredns_dns_requests_total{family="1",proto="tcp",server="dns://:5353",type="AAAA",zone="."} 20
+# TYPE coredns_dns_responses_total counter
+coredns_dns_responses_total{rcode="NOERROR",server="dns://:5353",zone="."} 100
+coredns_dns_responses_total{rcode="NXDOMAIN",server="dns://:5353",zone="."} 10
+coredns_dns_responses_total{rcode="SERVFAIL",server="dns://:5353",zone="."} 6
+coredns_dns_responses_total{rcode="REFUSED",server="dns://:5353",zone="."} 4
+# TYPE coredns_cache_hits_total counter
+coredns_cache_hits_total{server="dns://:5353",type="success"} 30
+coredns_cache_hits_total{server="dns://:5353",type="denial"} 10
+# TYPE coredns_cache_misses_total counter
+coredns_cache_misses_total{server="dns://:5353"} 60
+# TYPE coredns_build_info gauge
+coredns_build_info{goversion="go1.15",revision="",version="1.8.1"} 1
+`
+	c, err := parseCounters(strings.NewReader(metrics))
+	if err != nil {
+		t.Fatalf("failed to parse counters: %v", err)
+	}
+	expected := podCounters{requests: 120, responses: 120, errors: 10, cacheHits: 40, cacheMisses: 60}
+	if c != expected {
+		t.Errorf("expected %+v, got %+v", expected, c)
+	}
+
+	if _, err := parseCounters(strings.NewReader("not metrics {")); err == nil {
+		t.Errorf("expected an error for invalid metrics")
+	}
+}
+
+// TestComputeSummary verifies that computeSummary computes rates and ratios
+// from counter deltas and handles new and restarted pods.
+func TestComputeSummary(t *testing.T) {
+	previous := map[types.UID]podCounters{
+		"a":    {requests: 100, responses: 100, errors: 10, cacheHits: 50, cacheMisses: 50},
+		"b":    {requests: 500, responses: 500, errors: 0, cacheHits: 100, cacheMisses: 0},
+		"gone": {requests: 1000, responses: 1000},
+	}
+	current := map[types.UID]podCounters{
+		// 200 requests, 10 errors, 75% hits.
+		"a": {requests: 300, responses: 300, errors: 20, cacheHits: 200, cacheMisses: 100},
+		// Restarted: 100 requests, 10 errors, 50% hits.
+		"b": {requests: 100, responses: 100, errors: 10, cacheHits: 25, cacheMisses: 25},
+		// New: 0 requests.
+		"c": {},
+	}
+	s := computeSummary(previous, current, 30*time.Second)
+	if e, a := 10.0, s.requestsPerSecond; !approximately(e, a) {
+		t.Errorf("expected %v requests per second, got %v", e, a)
+	}
+	if e, a := 20.0/300.0, s.errorRatio; !approximately(e, a) {
+		t.Errorf("expected error ratio %v, got %v", e, a)
+	}
+	if e, a := 175.0/250.0, s.cacheHitRatio; !approximately(e, a) {
+		t.Errorf("expected cache hit ratio %v, got %v", e, a)
+	}
+	if e, a := 3, s.scrapedPods; e != a {
+		t.Errorf("expected %d scraped pods, got %d", e, a)
+	}
+
+	if s := computeSummary(nil, nil, 30*time.Second); s != (summary{}) {
+		t.Errorf("expected zero summary without pods, got %+v", s)
+	}
+}
+
+// TestComputePodErrorRatios verifies that computePodErrorRatios computes each
+// pod's error ratio over the interval and handles restarted and idle pods.
+func TestComputePodErrorRatios(t *testing.T) {
+	previous := map[types.UID]podCounters{
+		"a": {responses: 100, errors: 10},
+		"b": {responses: 500, errors: 50},
+		"c": {responses: 100, errors: 0},
+	}
+	current := map[types.UID]podCounters{
+		"a": {responses: 200, errors: 30},
+		"b": {responses: 50, errors: 5},
+		"c": {responses: 100, errors: 0},
+		"d": {responses: 10, errors: 1},
+	}
+	expected := map[types.UID]float64{"a": 0.2, "b": 0.1, "c": 0, "d": 0.1}
+	actual := computePodErrorRatios(previous, current)
+	if len(actual) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, actual)
+	}
+	for uid, ratio := range expected {
+		if !approximately(actual[uid], ratio) {
+			t.Errorf("pod %s: expected error ratio %v, got %v", uid, ratio, actual[uid])
+		}
+	}
+}
+
+// TestSummarizerCollect verifies that the summarizer reports no metrics until
+// it has scraped the dns pods twice and then reports the summary, including
+// pods that could not be scraped.
+func TestSummarizerCollect(t *testing.T) {
+	pod := func(name, ip string) corev1.Pod {
+		return corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, UID: types.UID(name)},
+			Status:     corev1.PodStatus{PodIP: ip},
+		}
+	}
+	s := New(&fakePodReader{pods: []corev1.Pod{
+		pod("a", "10.0.0.1"),
+		pod("b", "10.0.0.2"),
+		pod("pending", ""),
+	}})
+	requests := 0.0
+	s.scrape = func(_ context.Context, address string) (podCounters, error) {
+		if address != "10.0.0.1:9153" {
+			return podCounters{}, fmt.Errorf("connection refused")
+		}
+		return podCounters{requests: requests}, nil
+	}
+
+	s.update(context.Background())
+	if metrics := collect(s); len(metrics) != 0 {
+		t.Fatalf("expected no metrics after one scrape, got %d", len(metrics))
+	}
+	if ratios := s.PodErrorRatios(); ratios != nil {
+		t.Fatalf("expected no pod error ratios after one scrape, got %v", ratios)
+	}
+	requests = 300
+	s.lastScrape = s.lastScrape.Add(-30 * time.Second)
+	s.update(context.Background())
+	metrics := collect(s)
+	if len(metrics) != 5 {
+		t.Fatalf("expected 5 metrics, got %d", len(metrics))
+	}
+	if rps := metrics[0].GetGauge().GetValue(); rps < 9 || rps > 11 {
+		t.Errorf("expected about 10 requests per second, got %v", rps)
+	}
+	if e, a := 1.0, metrics[3].GetGauge().GetValue(); e != a {
+		t.Errorf("expected %v scraped pods, got %v", e, a)
+	}
+	if e, a := 1.0, metrics[4].GetGauge().GetValue(); e != a {
+		t.Errorf("expected %v failed pods, got %v", e, a)
+	}
+	if ratios := s.PodErrorRatios(); len(ratios) != 1 || ratios["a"] != 0 {
+		t.Errorf("expected an error ratio of 0 for pod a only, got %v", ratios)
+	}
+}
+
+// collect returns the metrics that the given collector reports.
+func collect(c prometheus.Collector) []*dto.Metric {
+	ch := make(chan prometheus.Metric, 10)
+	c.Collect(ch)
+	close(ch)
+	var metrics []*dto.Metric
+	for m := range ch {
+		out := &dto.Metric{}
+		if err := m.Write(out); err != nil {
+			panic(err)
+		}
+		metrics = append(metrics, out)
+	}
+	return metrics
+}
+
+// approximately returns a Boolean value indicating whether the given values
+// are equal within a small tolerance.
+func approximately(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
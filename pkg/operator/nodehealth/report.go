@@ -0,0 +1,284 @@
+// Package nodehealth periodically reports the health of the dns pod on each
+// node in a configmap, which gives cluster administrators and tools a single
+// place to find the nodes whose DNS is unhealthy.  For each dns pod, the report
+// lists the pod's phase, readiness, and container restarts and, if the
+// operator summarizes the dns pods' metrics, the pod's recent error ratio.
+//
+// The report is computed from the operator's cache and the metrics summary, so
+// it does not add load to the API server or the dns pods, and the configmap is
+// updated only when the report changes.
+package nodehealth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	operatorcontroller "github.com/openshift/cluster-dns-operator/pkg/operator/controller"
+
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// The types in this file define the schema of the report that the operator
+// publishes in the DNSNodeHealthConfigMapName configmap.  Consumers should
+// check apiVersion and kind before interpreting the data.  Fields may be added
+// to a schema version, but existing fields are not removed or changed in an
+// incompatible way without incrementing the version.
+
+const (
+	// DNSNodeHealthReportAPIVersion is the version of the
+	// DNSNodeHealthReport schema.
+	DNSNodeHealthReportAPIVersion = "dns.operator.openshift.io/v1alpha1"
+	// DNSNodeHealthReportKind is the kind of the DNSNodeHealthReport
+	// schema.
+	DNSNodeHealthReportKind = "DNSNodeHealthReport"
+	// DNSNodeHealthReportKey is the key in the configmap whose value is
+	// the JSON encoding of the report.
+	DNSNodeHealthReportKey = "health.json"
+
+	// ReasonPodNotRunning indicates that a dns pod is not running.
+	ReasonPodNotRunning = "PodNotRunning"
+	// ReasonPodCrashLooping indicates that a container of a dns pod is
+	// waiting to restart after repeated failures.
+	ReasonPodCrashLooping = "PodCrashLooping"
+	// ReasonPodNotReady indicates that a dns pod is running but not
+	// ready.
+	ReasonPodNotReady = "PodNotReady"
+	// ReasonHighErrorRatio indicates that a dns pod recently responded
+	// with SERVFAIL or REFUSED to a large fraction of queries.
+	ReasonHighErrorRatio = "HighErrorRatio"
+)
+
+const (
+	// reportInterval is the interval at which the report is computed.
+	// It matches the metrics summary's scrape interval.
+	reportInterval = 30 * time.Second
+	// highErrorRatio is the error ratio at or above which a dns pod is
+	// reported as unhealthy.
+	highErrorRatio = 0.1
+)
+
+// DNSNodeHealthReport describes the health of the dns pod on each node.
+type DNSNodeHealthReport struct {
+	// APIVersion is the version of the schema,
+	// DNSNodeHealthReportAPIVersion.
+	APIVersion string `json:"apiVersion"`
+	// Kind is the kind of the schema, DNSNodeHealthReportKind.
+	Kind string `json:"kind"`
+
+	// UnhealthyNodes are the names of the nodes with an unhealthy dns
+	// pod, in sorted order.
+	UnhealthyNodes []string `json:"unhealthyNodes,omitempty"`
+	// Pods describes each dns pod that is scheduled to a node, ordered by
+	// node and dns.  Nodes on which no dns pod is scheduled are not
+	// listed; the dns's topology snapshot lists them.
+	Pods []NodeDNSPod `json:"pods,omitempty"`
+}
+
+// NodeDNSPod describes the health of a dns pod on a node.
+type NodeDNSPod struct {
+	// Node is the name of the node.
+	Node string `json:"node"`
+	// DNS is the name of the dns that the pod belongs to.
+	DNS string `json:"dns"`
+	// Pod is the name of the pod.
+	Pod string `json:"pod"`
+	// Phase is the pod's phase.
+	Phase corev1.PodPhase `json:"phase"`
+	// Ready indicates whether the pod is ready.
+	Ready bool `json:"ready"`
+	// Restarts is the total number of restarts of the pod's containers.
+	Restarts int32 `json:"restarts"`
+	// ErrorRatio is the fraction of the pod's responses over the last
+	// scrape interval with the SERVFAIL or REFUSED response code, rounded
+	// to two decimal places, or nil if the operator does not summarize
+	// the dns pods' metrics or could not scrape the pod.
+	ErrorRatio *float64 `json:"errorRatio,omitempty"`
+	// Healthy indicates whether the pod is running, ready, and not
+	// responding with a high error ratio.
+	Healthy bool `json:"healthy"`
+	// Reason is one of ReasonPodNotRunning, ReasonPodCrashLooping,
+	// ReasonPodNotReady, or ReasonHighErrorRatio if the pod is unhealthy,
+	// or empty if it is healthy.
+	Reason string `json:"reason,omitempty"`
+}
+
+// ErrorRatioSource provides the recent error ratio of each dns pod by pod UID.
+// The metrics summarizer implements it.
+type ErrorRatioSource interface {
+	PodErrorRatios() map[types.UID]float64
+}
+
+// Reporter maintains the node health report.  Start must be called to compute
+// the report.
+type Reporter struct {
+	// client is used to create and update the report's configmap.
+	client client.Client
+	// cache is used to list dns pods.
+	cache client.Reader
+	// errorRatios provides the dns pods' error ratios, or is nil if the
+	// operator does not summarize the dns pods' metrics.
+	errorRatios ErrorRatioSource
+}
+
+// New returns a reporter that uses the given cache to list dns pods and the
+// given source, which may be nil, for their error ratios.
+func New(client client.Client, cache client.Reader, errorRatios ErrorRatioSource) *Reporter {
+	return &Reporter{
+		client:      client,
+		cache:       cache,
+		errorRatios: errorRatios,
+	}
+}
+
+// Start updates the report periodically until the given context is done.
+// Start implements manager.Runnable.
+func (r *Reporter) Start(ctx context.Context) error {
+	wait.UntilWithContext(ctx, r.update, reportInterval)
+	return nil
+}
+
+// update computes the report and ensures that the configmap has it.
+func (r *Reporter) update(ctx context.Context) {
+	selector, err := metav1.LabelSelectorAsSelector(operatorcontroller.AllDNSDaemonSetPodsSelector())
+	if err != nil {
+		logrus.Errorf("node health report failed to build dns pod selector: %v", err)
+		return
+	}
+	podList := &corev1.PodList{}
+	listOpts := []client.ListOption{
+		client.MatchingLabelsSelector{Selector: selector},
+		client.InNamespace(operatorcontroller.DefaultOperandNamespace),
+	}
+	if err := r.cache.List(ctx, podList, listOpts...); err != nil {
+		logrus.Errorf("node health report failed to list dns pods: %v", err)
+		return
+	}
+	var ratios map[types.UID]float64
+	if r.errorRatios != nil {
+		ratios = r.errorRatios.PodErrorRatios()
+	}
+	if err := r.ensureReport(ctx, computeReport(podList.Items, ratios)); err != nil {
+		logrus.Errorf("node health report failed to update: %v", err)
+	}
+}
+
+// ensureReport ensures that the report's configmap exists and has the given
+// report.
+func (r *Reporter) ensureReport(ctx context.Context, report DNSNodeHealthReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode report: %w", err)
+	}
+	name := operatorcontroller.DNSNodeHealthConfigMapName()
+	current := &corev1.ConfigMap{}
+	if err := r.client.Get(ctx, name, current); err != nil {
+		if !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to get configmap %s: %w", name, err)
+		}
+		desired := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name.Name,
+				Namespace: name.Namespace,
+			},
+			Data: map[string]string{DNSNodeHealthReportKey: string(data)},
+		}
+		if err := r.client.Create(ctx, desired); err != nil {
+			return fmt.Errorf("failed to create configmap %s: %w", name, err)
+		}
+		logrus.Infof("created node health report configmap %s", name)
+		return nil
+	}
+	if current.Data[DNSNodeHealthReportKey] == string(data) {
+		return nil
+	}
+	updated := current.DeepCopy()
+	updated.Data = map[string]string{DNSNodeHealthReportKey: string(data)}
+	if err := r.client.Update(ctx, updated); err != nil {
+		return fmt.Errorf("failed to update configmap %s: %w", name, err)
+	}
+	return nil
+}
+
+// computeReport returns the report for the given dns pods with the given error
+// ratios by pod UID, which may be nil.  The report has no timestamps so that
+// it changes only when the pods' health does.
+func computeReport(pods []corev1.Pod, errorRatios map[types.UID]float64) DNSNodeHealthReport {
+	report := DNSNodeHealthReport{
+		APIVersion: DNSNodeHealthReportAPIVersion,
+		Kind:       DNSNodeHealthReportKind,
+	}
+	unhealthy := map[string]bool{}
+	for i := range pods {
+		pod := &pods[i]
+		if len(pod.Spec.NodeName) == 0 {
+			continue
+		}
+		entry := NodeDNSPod{
+			Node:  pod.Spec.NodeName,
+			DNS:   operatorcontroller.DNSNameForDaemonSetPod(pod.Labels),
+			Pod:   pod.Name,
+			Phase: pod.Status.Phase,
+			Ready: podReady(pod),
+		}
+		crashLooping := false
+		for _, status := range pod.Status.ContainerStatuses {
+			entry.Restarts += status.RestartCount
+			if status.State.Waiting != nil && status.State.Waiting.Reason == "CrashLoopBackOff" {
+				crashLooping = true
+			}
+		}
+		if ratio, ok := errorRatios[pod.UID]; ok {
+			rounded := math.Round(ratio*100) / 100
+			entry.ErrorRatio = &rounded
+		}
+		switch {
+		case crashLooping:
+			entry.Reason = ReasonPodCrashLooping
+		case entry.Phase != corev1.PodRunning:
+			entry.Reason = ReasonPodNotRunning
+		case !entry.Ready:
+			entry.Reason = ReasonPodNotReady
+		case entry.ErrorRatio != nil && *entry.ErrorRatio >= highErrorRatio:
+			entry.Reason = ReasonHighErrorRatio
+		}
+		entry.Healthy = len(entry.Reason) == 0
+		if !entry.Healthy {
+			unhealthy[entry.Node] = true
+		}
+		report.Pods = append(report.Pods, entry)
+	}
+	sort.Slice(report.Pods, func(i, j int) bool {
+		if report.Pods[i].Node != report.Pods[j].Node {
+			return report.Pods[i].Node < report.Pods[j].Node
+		}
+		return report.Pods[i].DNS < report.Pods[j].DNS
+	})
+	for node := range unhealthy {
+		report.UnhealthyNodes = append(report.UnhealthyNodes, node)
+	}
+	sort.Strings(report.UnhealthyNodes)
+	return report
+}
+
+// podReady returns a Boolean value indicating whether the given pod is ready.
+func podReady(pod *corev1.Pod) bool {
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package nodehealth
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// TestComputeReport verifies that computeReport describes each scheduled dns
+// pod and lists the nodes with unhealthy dns pods.
+func TestComputeReport(t *testing.T) {
+	pod := func(name, node string, phase corev1.PodPhase, ready bool, restarts int32, waiting string) corev1.Pod {
+		readyStatus := corev1.ConditionFalse
+		if ready {
+			readyStatus = corev1.ConditionTrue
+		}
+		status := corev1.ContainerStatus{Name: "dns", RestartCount: restarts}
+		if len(waiting) != 0 {
+			status.State.Waiting = &corev1.ContainerStateWaiting{Reason: waiting}
+		}
+		return corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				UID:    types.UID(name),
+				Labels: map[string]string{"dns.operator.openshift.io/daemonset-dns": "default"},
+			},
+			Spec: corev1.PodSpec{NodeName: node},
+			Status: corev1.PodStatus{
+				Phase:             phase,
+				Conditions:        []corev1.PodCondition{{Type: corev1.PodReady, Status: readyStatus}},
+				ContainerStatuses: []corev1.ContainerStatus{status, {Name: "kube-rbac-proxy", RestartCount: 1}},
+			},
+		}
+	}
+	pods := []corev1.Pod{
+		pod("dns-default-e", "node-e", corev1.PodRunning, false, 7, "CrashLoopBackOff"),
+		pod("dns-default-a", "node-a", corev1.PodRunning, true, 0, ""),
+		pod("dns-default-b", "node-b", corev1.PodPending, false, 0, ""),
+		pod("dns-default-c", "node-c", corev1.PodRunning, false, 2, ""),
+		pod("dns-default-d", "node-d", corev1.PodRunning, true, 0, ""),
+		pod("dns-default-unscheduled", "", corev1.PodPending, false, 0, ""),
+	}
+	ratios := map[types.UID]float64{
+		"dns-default-a": 0.0123,
+		"dns-default-d": 0.25,
+	}
+	report := computeReport(pods, ratios)
+
+	if report.APIVersion != DNSNodeHealthReportAPIVersion || report.Kind != DNSNodeHealthReportKind {
+		t.Errorf("expected apiVersion %q and kind %q, got %q and %q", DNSNodeHealthReportAPIVersion, DNSNodeHealthReportKind, report.APIVersion, report.Kind)
+	}
+	if expected := []string{"node-b", "node-c", "node-d", "node-e"}; !reflect.DeepEqual(report.UnhealthyNodes, expected) {
+		t.Errorf("expected unhealthy nodes %v, got %v", expected, report.UnhealthyNodes)
+	}
+	expected := []struct {
+		node       string
+		restarts   int32
+		errorRatio float64
+		reason     string
+	}{
+		{"node-a", 1, 0.01, ""},
+		{"node-b", 1, -1, ReasonPodNotRunning},
+		{"node-c", 3, -1, ReasonPodNotReady},
+		{"node-d", 1, 0.25, ReasonHighErrorRatio},
+		{"node-e", 8, -1, ReasonPodCrashLooping},
+	}
+	if len(report.Pods) != len(expected) {
+		t.Fatalf("expected %d pods, got %#v", len(expected), report.Pods)
+	}
+	for i, e := range expected {
+		actual := report.Pods[i]
+		if actual.Node != e.node || actual.DNS != "default" || actual.Restarts != e.restarts || actual.Reason != e.reason || actual.Healthy != (len(e.reason) == 0) {
+			t.Errorf("expected node %s with dns default, %d restarts, and reason %q, got %+v", e.node, e.restarts, e.reason, actual)
+		}
+		switch {
+		case e.errorRatio < 0 && actual.ErrorRatio != nil:
+			t.Errorf("%s: expected no error ratio, got %v", e.node, *actual.ErrorRatio)
+		case e.errorRatio >= 0 && (actual.ErrorRatio == nil || *actual.ErrorRatio != e.errorRatio):
+			t.Errorf("%s: expected error ratio %v, got %v", e.node, e.errorRatio, actual.ErrorRatio)
+		}
+	}
+
+	if report := computeReport(pods, nil); report.Pods[0].ErrorRatio != nil {
+		t.Errorf("expected no error ratios without the metrics summary, got %v", *report.Pods[0].ErrorRatio)
+	}
+}
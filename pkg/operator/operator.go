@@ -8,8 +8,17 @@ import (
 	operatorv1 "github.com/openshift/api/operator/v1"
 	operatorclient "github.com/openshift/cluster-dns-operator/pkg/operator/client"
 	operatorconfig "github.com/openshift/cluster-dns-operator/pkg/operator/config"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/consoleapi"
 	operatorcontroller "github.com/openshift/cluster-dns-operator/pkg/operator/controller"
 	statuscontroller "github.com/openshift/cluster-dns-operator/pkg/operator/controller/status"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/dnsconfiganalyzer"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/dnshealth"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/dryrun"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/endpointreadiness"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/metricsproxy"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/metricssummary"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/nodehealth"
+	"github.com/openshift/cluster-dns-operator/pkg/operator/statusbreaker"
 
 	"github.com/sirupsen/logrus"
 
@@ -23,6 +32,15 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	// servingCertFile and servingKeyFile are the paths of the serving
+	// certificate and key for the operator's HTTP endpoints, which are
+	// mounted from the operator's metrics-tls secret.
+	servingCertFile = "/etc/tls/private/tls.crt"
+	servingKeyFile  = "/etc/tls/private/tls.key"
 )
 
 // Operator is the scaffolding for the dns operator. It sets up dependencies
@@ -36,12 +54,15 @@ type Operator struct {
 
 // New creates (but does not start) a new operator from configuration.
 func New(config operatorconfig.Config, kubeConfig *rest.Config) (*Operator, error) {
+	var dryRunClient *dryrun.Client
+	var statusBreakerClient *statusbreaker.Client
 	operatorManager, err := manager.New(kubeConfig, manager.Options{
 		Scheme:    operatorclient.GetScheme(),
 		Namespace: "openshift-dns",
-		NewCache: cache.MultiNamespacedCacheBuilder([]string{
+		NewCache: cache.MultiNamespacedCacheBuilder(append([]string{
 			config.OperatorNamespace,
-			operatorcontroller.DefaultOperandNamespace}),
+			operatorcontroller.DefaultOperandNamespace},
+			operatorcontroller.NodeResolverServiceNamespaces()...)),
 		// Use a non-caching client everywhere. The default split client does not
 		// promise to invalidate the cache during writes (nor does it promise
 		// sequential create/get coherence), and we have code which (probably
@@ -49,23 +70,62 @@ func New(config operatorconfig.Config, kubeConfig *rest.Config) (*Operator, erro
 		// return the updated resource. All client consumers will need audited to
 		// ensure they are tolerant of stale data (or we need a cache or client that
 		// makes stronger coherence guarantees).
-		NewClient: func(_ cache.Cache, config *rest.Config, options client.Options, uncachedObjects ...client.Object) (client.Client, error) {
-			return client.New(config, options)
+		//
+		// In dry-run mode, the client makes every write a dry run, so
+		// the controllers and the other components that use the
+		// manager's client write nothing.  The client suspends status
+		// writes while the API server is overloaded.
+		NewClient: func(_ cache.Cache, kubeConfig *rest.Config, options client.Options, uncachedObjects ...client.Object) (client.Client, error) {
+			c, err := client.New(kubeConfig, options)
+			if err != nil {
+				return nil, err
+			}
+			if config.DryRun {
+				dryRunClient = dryrun.New(c)
+				c = dryRunClient
+			}
+			statusBreakerClient = statusbreaker.New(c)
+			return statusBreakerClient, nil
 		},
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create operator manager: %v", err)
 	}
 
+	// Count the writes that the operator would have made in dry-run mode.
+	if dryRunClient != nil {
+		if err := metrics.Registry.Register(dryRunClient); err != nil {
+			return nil, fmt.Errorf("failed to register dry run client: %v", err)
+		}
+	}
+
+	// Report suspended status writes.
+	if err := metrics.Registry.Register(statusBreakerClient); err != nil {
+		return nil, fmt.Errorf("failed to register status write circuit breaker: %v", err)
+	}
+
 	// Create and register the operator controller with the operator manager.
 	cfg := operatorconfig.Config{
 		OperatorNamespace:      config.OperatorNamespace,
 		CoreDNSImage:           config.CoreDNSImage,
 		OpenshiftCLIImage:      config.OpenshiftCLIImage,
 		KubeRBACProxyImage:     config.KubeRBACProxyImage,
+		OperatorImage:          config.OperatorImage,
 		OperatorReleaseVersion: config.OperatorReleaseVersion,
+		VerifyOperandImages:    config.VerifyOperandImages,
+		MetricsAuthentication:  config.MetricsAuthentication,
+		ConsoleAPI:             config.ConsoleAPI,
+		MetricsSummary:         config.MetricsSummary,
+		DNSConfigAnalyzer:      config.DNSConfigAnalyzer,
+		DNSHealthEndpoint:      config.DNSHealthEndpoint,
+		ChaosHooks:             config.ChaosHooks,
+		DryRun:                 config.DryRun,
+
+		DNSControllerMaxConcurrentReconciles:    config.DNSControllerMaxConcurrentReconciles,
+		StatusControllerMaxConcurrentReconciles: config.StatusControllerMaxConcurrentReconciles,
 	}
-	if _, err := operatorcontroller.New(operatorManager, cfg); err != nil {
+	desiredState := operatorcontroller.NewDesiredState()
+	if _, err := operatorcontroller.New(operatorManager, cfg, desiredState); err != nil {
 		return nil, fmt.Errorf("failed to create operator controller: %v", err)
 	}
 
@@ -74,6 +134,70 @@ func New(config operatorconfig.Config, kubeConfig *rest.Config) (*Operator, erro
 		return nil, fmt.Errorf("failed to create status controller: %v", err)
 	}
 
+	// Serve dns metrics through the operator if the operator rather than
+	// kube-rbac-proxy sidecars authenticates metrics scrapes.
+	if cfg.MetricsAuthentication == operatorconfig.MetricsAuthenticationTokenReview {
+		proxy := metricsproxy.New(operatorManager.GetClient(), operatorManager.GetCache(), fmt.Sprintf(":%d", operatorcontroller.MetricsProxyPort), servingCertFile, servingKeyFile)
+		if err := operatorManager.Add(proxy); err != nil {
+			return nil, fmt.Errorf("failed to add metrics proxy: %v", err)
+		}
+	}
+
+	// Summarize the dns pods' metrics on the operator's metrics endpoint
+	// if the summary is enabled.
+	var errorRatios nodehealth.ErrorRatioSource
+	if cfg.MetricsSummary {
+		summarizer := metricssummary.New(operatorManager.GetCache())
+		if err := metrics.Registry.Register(summarizer); err != nil {
+			return nil, fmt.Errorf("failed to register metrics summary: %v", err)
+		}
+		if err := operatorManager.Add(summarizer); err != nil {
+			return nil, fmt.Errorf("failed to add metrics summary: %v", err)
+		}
+		errorRatios = summarizer
+	}
+
+	// Report the health of the dns pod on each node, with the pods' error
+	// ratios if the metrics summary is enabled.
+	if err := operatorManager.Add(nodehealth.New(operatorManager.GetClient(), operatorManager.GetCache(), errorRatios)); err != nil {
+		return nil, fmt.Errorf("failed to add node health report: %v", err)
+	}
+
+	// Report the readiness of the dns service's endpoints.  The metrics
+	// are computed from the cache when they are scraped.
+	if err := metrics.Registry.Register(endpointreadiness.New(operatorManager.GetClient(), operatorManager.GetCache())); err != nil {
+		return nil, fmt.Errorf("failed to register endpoint readiness collector: %v", err)
+	}
+
+	// Report pods with problematic DNS settings if the analyzer is
+	// enabled.  The analyzer lists pods in all namespaces, so it uses the
+	// manager's client rather than its namespace-scoped cache.
+	if cfg.DNSConfigAnalyzer {
+		analyzer := dnsconfiganalyzer.New(operatorManager.GetClient())
+		if err := metrics.Registry.Register(analyzer); err != nil {
+			return nil, fmt.Errorf("failed to register dns config analyzer: %v", err)
+		}
+		if err := operatorManager.Add(analyzer); err != nil {
+			return nil, fmt.Errorf("failed to add dns config analyzer: %v", err)
+		}
+	}
+
+	// Serve the console plugin endpoints if they are enabled.
+	if cfg.ConsoleAPI {
+		server := consoleapi.New(operatorManager.GetClient(), operatorManager.GetCache(), desiredState, fmt.Sprintf(":%d", operatorcontroller.ConsoleAPIPort), servingCertFile, servingKeyFile)
+		if err := operatorManager.Add(server); err != nil {
+			return nil, fmt.Errorf("failed to add console api server: %v", err)
+		}
+	}
+
+	// Serve the dns health endpoint if it is enabled.
+	if cfg.DNSHealthEndpoint {
+		server := dnshealth.New(operatorManager.GetClient(), operatorManager.GetCache(), fmt.Sprintf(":%d", operatorcontroller.DNSHealthPort), servingCertFile, servingKeyFile)
+		if err := operatorManager.Add(server); err != nil {
+			return nil, fmt.Errorf("failed to add dns health endpoint: %v", err)
+		}
+	}
+
 	return &Operator{
 		manager: operatorManager,
 
@@ -0,0 +1,167 @@
+package query
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Record types that the query subcommand asks for and decodes.
+const (
+	typeA     = 1
+	typeCNAME = 5
+	typeAAAA  = 28
+
+	classINET = 1
+
+	// headerLength is the length of a DNS message's header.
+	headerLength = 12
+)
+
+// rcodeNames are the names of the response codes that CoreDNS and upstreams
+// commonly return.
+var rcodeNames = map[int]string{
+	0: "NOERROR",
+	1: "FORMERR",
+	2: "SERVFAIL",
+	3: "NXDOMAIN",
+	4: "NOTIMP",
+	5: "REFUSED",
+}
+
+// queryTypes maps the names of the record types that a query may ask for to
+// their values.
+var queryTypes = map[string]uint16{
+	"A":    typeA,
+	"AAAA": typeAAAA,
+}
+
+// response is a decoded DNS response.
+type response struct {
+	// Rcode is the name of the response code.
+	Rcode string
+	// Answers are the A, AAAA, and CNAME records in the answer section,
+	// as "CNAME <name>" for CNAME records and as addresses otherwise.
+	Answers []string
+}
+
+// newQuery returns a recursive query with the given ID for the given name and
+// record type.
+func newQuery(id uint16, name string, qtype uint16) ([]byte, error) {
+	msg := make([]byte, headerLength, headerLength+len(name)+6)
+	binary.BigEndian.PutUint16(msg[0:], id)
+	// Set the RD bit so that forwarders recurse.
+	msg[2] = 0x01
+	binary.BigEndian.PutUint16(msg[4:], 1)
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return nil, fmt.Errorf("%q is not a valid domain name", name)
+		}
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	msg = append(msg, 0, 0, 0, 0, 0)
+	binary.BigEndian.PutUint16(msg[len(msg)-4:], qtype)
+	binary.BigEndian.PutUint16(msg[len(msg)-2:], classINET)
+	return msg, nil
+}
+
+// parseResponse decodes the given response to the query with the given ID.
+func parseResponse(id uint16, msg []byte) (response, error) {
+	var resp response
+	if len(msg) < headerLength {
+		return resp, fmt.Errorf("the response is truncated")
+	}
+	if binary.BigEndian.Uint16(msg[0:]) != id {
+		return resp, fmt.Errorf("the response has ID %d instead of %d", binary.BigEndian.Uint16(msg[0:]), id)
+	}
+	rcode := int(msg[3] & 0x0f)
+	resp.Rcode = rcodeNames[rcode]
+	if len(resp.Rcode) == 0 {
+		resp.Rcode = fmt.Sprintf("RCODE%d", rcode)
+	}
+	questions := int(binary.BigEndian.Uint16(msg[4:]))
+	answers := int(binary.BigEndian.Uint16(msg[6:]))
+	offset := headerLength
+	for i := 0; i < questions; i++ {
+		next, err := skipName(msg, offset)
+		if err != nil || next+4 > len(msg) {
+			return resp, fmt.Errorf("the response has a malformed question")
+		}
+		offset = next + 4
+	}
+	for i := 0; i < answers; i++ {
+		next, err := skipName(msg, offset)
+		if err != nil || next+10 > len(msg) {
+			return resp, fmt.Errorf("the response has a malformed answer")
+		}
+		rrtype := binary.BigEndian.Uint16(msg[next:])
+		length := int(binary.BigEndian.Uint16(msg[next+8:]))
+		data := next + 10
+		if data+length > len(msg) {
+			return resp, fmt.Errorf("the response has a malformed answer")
+		}
+		switch {
+		case rrtype == typeA && length == net.IPv4len, rrtype == typeAAAA && length == net.IPv6len:
+			resp.Answers = append(resp.Answers, net.IP(msg[data:data+length]).String())
+		case rrtype == typeCNAME:
+			target, err := readName(msg, data)
+			if err != nil {
+				return resp, fmt.Errorf("the response has a malformed CNAME record")
+			}
+			resp.Answers = append(resp.Answers, "CNAME "+target)
+		}
+		offset = data + length
+	}
+	return resp, nil
+}
+
+// skipName returns the offset that follows the possibly compressed name at the
+// given offset of the given message.
+func skipName(msg []byte, offset int) (int, error) {
+	for {
+		if offset >= len(msg) {
+			return 0, fmt.Errorf("the name is truncated")
+		}
+		length := int(msg[offset])
+		switch {
+		case length == 0:
+			return offset + 1, nil
+		case length&0xc0 == 0xc0:
+			return offset + 2, nil
+		}
+		offset += 1 + length
+	}
+}
+
+// readName returns the possibly compressed name at the given offset of the
+// given message, with a trailing dot.
+func readName(msg []byte, offset int) (string, error) {
+	var labels []string
+	// A pointer must point backward, so a name cannot have more pointers
+	// than the message has bytes.
+	for jumps := 0; jumps <= len(msg); {
+		if offset >= len(msg) {
+			return "", fmt.Errorf("the name is truncated")
+		}
+		length := int(msg[offset])
+		switch {
+		case length == 0:
+			return strings.Join(labels, ".") + ".", nil
+		case length&0xc0 == 0xc0:
+			if offset+1 >= len(msg) {
+				return "", fmt.Errorf("the name is truncated")
+			}
+			offset = int(binary.BigEndian.Uint16(msg[offset:]) & 0x3fff)
+			jumps++
+			continue
+		}
+		if offset+1+length > len(msg) {
+			return "", fmt.Errorf("the name is truncated")
+		}
+		labels = append(labels, string(msg[offset+1:offset+1+length]))
+		offset += 1 + length
+	}
+	return "", fmt.Errorf("the name has a pointer loop")
+}
@@ -0,0 +1,356 @@
+// Package query resolves a name through the cluster's DNS service, through each
+// dns pod, and directly against the upstreams that the rendered Corefile
+// forwards the name to, and compares the results, which codifies the usual
+// triage steps for a name that does not resolve as expected.
+package query
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+
+	operatorcontroller "github.com/openshift/cluster-dns-operator/pkg/operator/controller"
+
+	corev1 "k8s.io/api/core/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// The ways in which a name can be resolved.
+const (
+	// ViaService resolves the name through the dns's service, as pods do.
+	ViaService = "service"
+	// ViaPod resolves the name through each of the dns's pods.
+	ViaPod = "pod"
+	// ViaUpstream resolves the name directly against each upstream that
+	// the rendered Corefile forwards the name to.
+	ViaUpstream = "upstream"
+)
+
+// Vias are the ways in which a name can be resolved, in the order in which they
+// are compared.
+var Vias = []string{ViaService, ViaPod, ViaUpstream}
+
+// Options are the options of a query.
+type Options struct {
+	// Name is the name to resolve.
+	Name string
+	// Type is the record type to ask for, "A" or "AAAA".
+	Type string
+	// Vias are the ways in which to resolve the name.
+	Vias []string
+	// Pod is the name of the only dns pod to query, or empty to query
+	// every dns pod.
+	Pod string
+	// Timeout is how long to wait for each response.
+	Timeout time.Duration
+}
+
+// Target is a DNS server that a query asks.
+type Target struct {
+	// Via is how the target is reached.
+	Via string
+	// Name describes the target, such as the name of a pod.
+	Name string
+	// Address is the target's address and port, or empty if the target
+	// cannot be queried.
+	Address string
+	// Skipped is why the target is not queried, if it is not.
+	Skipped string
+}
+
+// Result is the result of a query of a target.
+type Result struct {
+	Target
+	// Rcode is the response code, or empty if there was no response.
+	Rcode string
+	// Answers are the sorted answers.
+	Answers []string
+	// Error is why there was no response, if there was none.
+	Error string
+	// Duration is how long the response took.
+	Duration time.Duration
+}
+
+// Targets returns the targets for the given options and dns.
+func Targets(ctx context.Context, c client.Reader, dns *operatorv1.DNS, opts Options) ([]Target, error) {
+	var targets []Target
+	for _, via := range opts.Vias {
+		switch via {
+		case ViaService:
+			svc := &corev1.Service{}
+			name := operatorcontroller.DNSServiceName(dns)
+			if err := c.Get(ctx, name, svc); err != nil {
+				return nil, fmt.Errorf("failed to get service %s: %w", name, err)
+			}
+			targets = append(targets, Target{Via: via, Name: name.String(), Address: net.JoinHostPort(svc.Spec.ClusterIP, "53")})
+		case ViaPod:
+			selector, err := metav1.LabelSelectorAsSelector(operatorcontroller.DNSDaemonSetPodSelector(dns))
+			if err != nil {
+				return nil, err
+			}
+			pods := &corev1.PodList{}
+			if err := c.List(ctx, pods, client.MatchingLabelsSelector{Selector: selector}, client.InNamespace(operatorcontroller.DefaultOperandNamespace)); err != nil {
+				return nil, fmt.Errorf("failed to list dns pods: %w", err)
+			}
+			targets = append(targets, podTargets(pods.Items, opts.Pod)...)
+		case ViaUpstream:
+			cm := &corev1.ConfigMap{}
+			name := operatorcontroller.DNSConfigMapName(dns)
+			if err := c.Get(ctx, name, cm); err != nil {
+				return nil, fmt.Errorf("failed to get configmap %s: %w", name, err)
+			}
+			targets = append(targets, upstreamTargets(cm.Data["Corefile"], opts.Name)...)
+		default:
+			return nil, fmt.Errorf("unknown via %q: must be one of %s", via, strings.Join(Vias, ", "))
+		}
+	}
+	return targets, nil
+}
+
+// podTargets returns a target for each of the given dns pods, or for the one
+// with the given name if it is not empty.  Pods without an address are
+// skipped.
+func podTargets(pods []corev1.Pod, only string) []Target {
+	sort.Slice(pods, func(i, j int) bool {
+		return pods[i].Name < pods[j].Name
+	})
+	var targets []Target
+	for _, pod := range pods {
+		if len(only) != 0 && pod.Name != only {
+			continue
+		}
+		target := Target{Via: ViaPod, Name: pod.Name + " (" + pod.Spec.NodeName + ")"}
+		if len(pod.Status.PodIP) == 0 {
+			target.Skipped = "the pod has no address"
+		} else {
+			target.Address = net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(operatorcontroller.CoreDNSPort))
+		}
+		targets = append(targets, target)
+	}
+	if len(targets) == 0 && len(only) != 0 {
+		targets = append(targets, Target{Via: ViaPod, Name: only, Skipped: "the dns has no such pod"})
+	}
+	return targets
+}
+
+// corefileBlock is a server block of a Corefile.
+type corefileBlock struct {
+	// Zones are the block's zones, with trailing dots.
+	Zones []string
+	// Upstreams are the arguments of the block's forward plugin after
+	// the "." source.
+	Upstreams []string
+	// View is whether the block applies only to some clients.
+	View bool
+}
+
+// parseCorefileBlocks returns the plain DNS server blocks on CoreDNS's port in
+// the given Corefile.
+func parseCorefileBlocks(corefile string) []corefileBlock {
+	var (
+		blocks  []corefileBlock
+		current *corefileBlock
+		depth   int
+	)
+	port := ":" + strconv.Itoa(operatorcontroller.CoreDNSPort)
+	scanner := bufio.NewScanner(strings.NewReader(corefile))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		switch {
+		case depth == 0 && strings.HasSuffix(line, "{"):
+			current = nil
+			block := corefileBlock{}
+			for _, key := range fields[:len(fields)-1] {
+				if !strings.HasSuffix(key, port) || strings.Contains(key, "://") {
+					block.Zones = nil
+					break
+				}
+				zone := strings.TrimSuffix(key, port)
+				if !strings.HasSuffix(zone, ".") {
+					zone += "."
+				}
+				block.Zones = append(block.Zones, strings.ToLower(zone))
+			}
+			if len(block.Zones) != 0 {
+				blocks = append(blocks, block)
+				current = &blocks[len(blocks)-1]
+			}
+		case depth == 1 && current != nil && fields[0] == "view":
+			current.View = true
+		case depth == 1 && current != nil && fields[0] == "forward" && len(fields) > 2:
+			for _, upstream := range fields[2:] {
+				if upstream == "{" {
+					break
+				}
+				current.Upstreams = append(current.Upstreams, upstream)
+			}
+		}
+		depth += strings.Count(line, "{") - strings.Count(line, "}")
+	}
+	return blocks
+}
+
+// upstreamTargets returns a target for each upstream of the server block of the
+// given Corefile that answers the given name for every client, which is the
+// block with the longest zone that has the name.  Upstreams that are not plain
+// DNS addresses are skipped.
+func upstreamTargets(corefile, name string) []Target {
+	name = strings.ToLower(strings.TrimSuffix(name, ".")) + "."
+	var (
+		best     *corefileBlock
+		bestZone string
+	)
+	blocks := parseCorefileBlocks(corefile)
+	for i := range blocks {
+		if blocks[i].View {
+			continue
+		}
+		for _, zone := range blocks[i].Zones {
+			if (zone == "." || name == zone || strings.HasSuffix(name, "."+zone)) && len(zone) > len(bestZone) {
+				best, bestZone = &blocks[i], zone
+			}
+		}
+	}
+	if best == nil || len(best.Upstreams) == 0 {
+		return []Target{{Via: ViaUpstream, Name: "none", Skipped: "no server block forwards the name"}}
+	}
+	var targets []Target
+	for _, upstream := range best.Upstreams {
+		target := Target{Via: ViaUpstream, Name: upstream + " (zone " + bestZone + ")"}
+		switch {
+		case strings.HasPrefix(upstream, "/"):
+			target.Skipped = "the nodes' resolv.conf cannot be queried from here"
+		case strings.Contains(upstream, "://"):
+			target.Skipped = "only plain DNS upstreams can be queried"
+		default:
+			if ip := net.ParseIP(upstream); ip != nil {
+				target.Address = net.JoinHostPort(upstream, "53")
+			} else if _, _, err := net.SplitHostPort(upstream); err == nil {
+				target.Address = upstream
+			} else {
+				target.Skipped = "the upstream is not an address"
+			}
+		}
+		targets = append(targets, target)
+	}
+	return targets
+}
+
+// Resolve asks the given target for the records of the given type for the
+// given name over UDP.
+func Resolve(ctx context.Context, target Target, name, qtype string, timeout time.Duration) Result {
+	result := Result{Target: target}
+	if len(target.Address) == 0 {
+		return result
+	}
+	t, ok := queryTypes[strings.ToUpper(qtype)]
+	if !ok {
+		result.Error = fmt.Sprintf("unsupported record type %q", qtype)
+		return result
+	}
+	id := uint16(rand.Intn(1 << 16))
+	msg, err := newQuery(id, name, t)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	start := time.Now()
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", target.Address)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			result.Error = err.Error()
+			return result
+		}
+	}
+	if _, err := conn.Write(msg); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	buf := make([]byte, 4096)
+	n, err := conn.Read(buf)
+	result.Duration = time.Since(start)
+	if err != nil {
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			result.Error = fmt.Sprintf("no response within %s", timeout)
+		} else {
+			result.Error = err.Error()
+		}
+		return result
+	}
+	resp, err := parseResponse(id, buf[:n])
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Rcode = resp.Rcode
+	result.Answers = append([]string(nil), resp.Answers...)
+	sort.Strings(result.Answers)
+	return result
+}
+
+// answer returns a summary of the given result's response for comparison with
+// other results, or the empty string if there was no response.
+func (r Result) answer() string {
+	if len(r.Rcode) == 0 {
+		return ""
+	}
+	return r.Rcode + " " + strings.Join(r.Answers, ",")
+}
+
+// WriteTable writes a table that compares the given results to the given
+// writer.  The MATCH column compares each response with the first response.
+func WriteTable(w io.Writer, results []Result) error {
+	var baseline string
+	for _, result := range results {
+		if baseline = result.answer(); len(baseline) != 0 {
+			break
+		}
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "VIA\tTARGET\tRCODE\tANSWERS\tTIME\tMATCH")
+	for _, result := range results {
+		rcode, answers, duration, match := result.Rcode, strings.Join(result.Answers, ","), "-", "-"
+		switch {
+		case len(result.Skipped) != 0:
+			rcode, answers = "skipped", result.Skipped
+		case len(result.Error) != 0:
+			rcode, answers = "error", result.Error
+		default:
+			duration = result.Duration.Round(time.Millisecond).String()
+			match = "yes"
+			if result.answer() != baseline {
+				match = "no"
+			}
+		}
+		if len(answers) == 0 {
+			answers = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", result.Via, result.Name, rcode, answers, duration, match)
+	}
+	return tw.Flush()
+}
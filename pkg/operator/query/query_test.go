@@ -0,0 +1,140 @@
+package query
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const testCorefile = `# view infra
+corp.example.com:5353 {
+    view infra {
+        expr incidr(client_ip(), '10.0.0.0/16')
+    }
+    forward . 10.9.9.9
+}
+# corp
+corp.example.com:5353 lab.example.com:5353 {
+    forward . 10.0.0.53 10.0.0.54:5353 tls://10.0.0.55 /etc/resolv.conf {
+        policy sequential
+    }
+    prometheus 127.0.0.1:9153
+}
+# dns-over-tls
+tls://.:8853 {
+    forward . 127.0.0.1:5353
+}
+.:5353 {
+    kubernetes cluster.local in-addr.arpa ip6.arpa {
+        pods insecure
+    }
+    forward . /etc/resolv.conf {
+        policy sequential
+    }
+}
+`
+
+// TestUpstreamTargets verifies that upstreamTargets returns the upstreams of
+// the server block with the longest zone that has the name, ignoring view
+// blocks, and skips upstreams that cannot be queried.
+func TestUpstreamTargets(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expected []Target
+	}{
+		{
+			name: "www.Corp.Example.com.",
+			expected: []Target{
+				{Via: ViaUpstream, Name: "10.0.0.53 (zone corp.example.com.)", Address: "10.0.0.53:53"},
+				{Via: ViaUpstream, Name: "10.0.0.54:5353 (zone corp.example.com.)", Address: "10.0.0.54:5353"},
+				{Via: ViaUpstream, Name: "tls://10.0.0.55 (zone corp.example.com.)", Skipped: "only plain DNS upstreams can be queried"},
+				{Via: ViaUpstream, Name: "/etc/resolv.conf (zone corp.example.com.)", Skipped: "the nodes' resolv.conf cannot be queried from here"},
+			},
+		},
+		{
+			name: "www.example.com",
+			expected: []Target{
+				{Via: ViaUpstream, Name: "/etc/resolv.conf (zone .)", Skipped: "the nodes' resolv.conf cannot be queried from here"},
+			},
+		},
+		{
+			name: "notcorp.example.com",
+			expected: []Target{
+				{Via: ViaUpstream, Name: "/etc/resolv.conf (zone .)", Skipped: "the nodes' resolv.conf cannot be queried from here"},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		if actual := upstreamTargets(testCorefile, tc.name); !reflect.DeepEqual(actual, tc.expected) {
+			t.Errorf("%q: expected %#v, got %#v", tc.name, tc.expected, actual)
+		}
+	}
+}
+
+// TestResolve verifies that Resolve sends a query for the name and decodes the
+// response's rcode and answers, including compressed names.
+func TestResolve(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	go func() {
+		buf := make([]byte, 512)
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		resp := append([]byte(nil), buf[:n]...)
+		// Set QR and RA, and one answer of each kind.
+		resp[2] |= 0x80
+		resp[3] = 0x80
+		binary.BigEndian.PutUint16(resp[6:], 2)
+		// www.example.com. CNAME web.example.com., with the target's
+		// parent compressed to the question's.
+		resp = append(resp, 0xc0, headerLength, 0, typeCNAME, 0, classINET, 0, 0, 0, 30, 0, 6, 3, 'w', 'e', 'b', 0xc0, headerLength+4)
+		resp = append(resp, 0xc0, headerLength, 0, typeA, 0, classINET, 0, 0, 0, 30, 0, 4, 192, 0, 2, 1)
+		conn.WriteTo(resp, addr)
+	}()
+
+	result := Resolve(context.TODO(), Target{Via: ViaService, Name: "test", Address: conn.LocalAddr().String()}, "www.example.com", "A", time.Second)
+	if len(result.Error) != 0 {
+		t.Fatalf("unexpected error: %s", result.Error)
+	}
+	if result.Rcode != "NOERROR" {
+		t.Errorf("expected NOERROR, got %q", result.Rcode)
+	}
+	if expected := []string{"192.0.2.1", "CNAME web.example.com."}; !reflect.DeepEqual(result.Answers, expected) {
+		t.Errorf("expected answers %q, got %q", expected, result.Answers)
+	}
+}
+
+// TestWriteTable verifies that the table compares each response with the first
+// one and shows why targets were skipped.
+func TestWriteTable(t *testing.T) {
+	results := []Result{
+		{Target: Target{Via: ViaService, Name: "openshift-dns/dns-default"}, Rcode: "NOERROR", Answers: []string{"192.0.2.1"}, Duration: 3 * time.Millisecond},
+		{Target: Target{Via: ViaPod, Name: "dns-default-abcde (node-1)"}, Rcode: "SERVFAIL", Duration: time.Millisecond},
+		{Target: Target{Via: ViaPod, Name: "dns-default-fghij (node-2)"}, Error: "no response within 2s"},
+		{Target: Target{Via: ViaUpstream, Name: "10.0.0.53 (zone corp.example.com.)"}, Rcode: "NOERROR", Answers: []string{"192.0.2.1"}, Duration: 5 * time.Millisecond},
+		{Target: Target{Via: ViaUpstream, Name: "tls://10.0.0.55 (zone corp.example.com.)", Skipped: "only plain DNS upstreams can be queried"}},
+	}
+	var buf bytes.Buffer
+	if err := WriteTable(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+	expected := `VIA       TARGET                                    RCODE     ANSWERS                                  TIME  MATCH
+service   openshift-dns/dns-default                 NOERROR   192.0.2.1                                3ms   yes
+pod       dns-default-abcde (node-1)                SERVFAIL  -                                        1ms   no
+pod       dns-default-fghij (node-2)                error     no response within 2s                    -     -
+upstream  10.0.0.53 (zone corp.example.com.)        NOERROR   192.0.2.1                                5ms   yes
+upstream  tls://10.0.0.55 (zone corp.example.com.)  skipped   only plain DNS upstreams can be queried  -     -
+`
+	if actual := buf.String(); actual != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, actual)
+	}
+}
@@ -0,0 +1,220 @@
+// Package statusbreaker implements a client that stops writing status when the
+// API server is overloaded.  When the API server rejects a status write with
+// 429 Too Many Requests, the client opens a circuit and fails every status
+// write without sending it until the circuit closes, so that the operator backs
+// off rather than adding to the overload with retries.  The circuit stays open
+// for at least as long as the API server asks and for twice as long after each
+// consecutive rejection, up to a limit.  The controllers compute status from
+// their caches on each reconciliation, so the status that was not written is
+// written when they reconcile after the circuit closes.
+package statusbreaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/sirupsen/logrus"
+
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	utilclock "k8s.io/apimachinery/pkg/util/clock"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// minOpenDuration is how long the circuit stays open after the first
+	// rejection if the API server does not ask for longer.
+	minOpenDuration = time.Second
+	// maxOpenDuration is the longest that the circuit stays open.
+	maxOpenDuration = 5 * time.Minute
+
+	// ResultThrottled and ResultSuspended are the values of the result
+	// label of the rejected writes metric: the API server rejected the
+	// write, or the client did not send it because the circuit was open.
+	ResultThrottled = "throttled"
+	ResultSuspended = "suspended"
+)
+
+var openDesc = prometheus.NewDesc(
+	"dns_operator_status_write_circuit_open",
+	"Whether the operator has suspended status writes because the API server is overloaded (1) or not (0).",
+	nil, nil,
+)
+
+// SuspendedError is the error that the client returns for a status write that
+// the API server rejected as overloaded or that the client did not send
+// because the circuit was open.
+type SuspendedError struct {
+	// RetryAfter is how long until the circuit closes.
+	RetryAfter time.Duration
+	// Err is the API server's rejection, or nil if the write was not
+	// sent.
+	Err error
+}
+
+func (e *SuspendedError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("status writes are suspended for %v because the API server is overloaded: %v", e.RetryAfter, e.Err)
+	}
+	return fmt.Sprintf("status writes are suspended for %v because the API server is overloaded", e.RetryAfter)
+}
+
+func (e *SuspendedError) Unwrap() error {
+	return e.Err
+}
+
+// RetryAfter returns how long the caller should wait before reconciling again
+// and a Boolean value indicating whether the given error, or any error that it
+// wraps or aggregates, is a SuspendedError.
+func RetryAfter(err error) (time.Duration, bool) {
+	if err == nil {
+		return 0, false
+	}
+	var suspended *SuspendedError
+	if errors.As(err, &suspended) {
+		return suspended.RetryAfter, true
+	}
+	var agg utilerrors.Aggregate
+	if errors.As(err, &agg) {
+		for _, err := range agg.Errors() {
+			if retryAfter, ok := RetryAfter(err); ok {
+				return retryAfter, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// Client is a client.Client whose status writer stops writing while the API
+// server is overloaded.  It is also a prometheus.Collector that reports
+// whether the circuit is open and counts the rejected writes.
+type Client struct {
+	client.Client
+
+	clock utilclock.PassiveClock
+
+	lock sync.Mutex
+	// rejections is the number of consecutive status writes that the API
+	// server rejected as overloaded.
+	rejections int
+	// openUntil is when the circuit closes.
+	openUntil time.Time
+
+	rejected *prometheus.CounterVec
+}
+
+var _ client.Client = &Client{}
+var _ prometheus.Collector = &Client{}
+
+// New returns a client that wraps the given client.
+func New(c client.Client) *Client {
+	return &Client{
+		Client: c,
+		clock:  utilclock.RealClock{},
+		rejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "dns_operator_status_writes_rejected_total",
+			Help: "Number of status writes that the API server rejected as overloaded or that the operator suspended, by result.",
+		}, []string{"result"}),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Client) Describe(ch chan<- *prometheus.Desc) {
+	ch <- openDesc
+	c.rejected.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *Client) Collect(ch chan<- prometheus.Metric) {
+	open := 0.0
+	if _, ok := c.suspended(); ok {
+		open = 1
+	}
+	ch <- prometheus.MustNewConstMetric(openDesc, prometheus.GaugeValue, open)
+	c.rejected.Collect(ch)
+}
+
+// Status implements client.StatusClient.
+func (c *Client) Status() client.StatusWriter {
+	return &statusWriter{StatusWriter: c.Client.Status(), client: c}
+}
+
+// suspended returns how long until the circuit closes and a Boolean value
+// indicating whether it is open.
+func (c *Client) suspended() (time.Duration, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if remaining := c.openUntil.Sub(c.clock.Now()); remaining > 0 {
+		return remaining, true
+	}
+	return 0, false
+}
+
+// write sends a status write with the given function unless the circuit is
+// open, and opens the circuit if the API server rejects the write as
+// overloaded.
+func (c *Client) write(fn func() error) error {
+	if retryAfter, ok := c.suspended(); ok {
+		c.rejected.WithLabelValues(ResultSuspended).Inc()
+		return &SuspendedError{RetryAfter: retryAfter}
+	}
+	err := fn()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if err == nil || !kerrors.IsTooManyRequests(err) {
+		c.rejections = 0
+		return err
+	}
+	c.rejections++
+	retryAfter := openDuration(c.rejections, err)
+	c.openUntil = c.clock.Now().Add(retryAfter)
+	c.rejected.WithLabelValues(ResultThrottled).Inc()
+	logrus.Warningf("suspending status writes for %v because the API server is overloaded: %v", retryAfter, err)
+	return &SuspendedError{RetryAfter: retryAfter, Err: err}
+}
+
+// openDuration returns how long the circuit stays open after the given number
+// of consecutive rejections, the last of which is the given error.
+func openDuration(rejections int, err error) time.Duration {
+	d := minOpenDuration
+	for i := 1; i < rejections && d < maxOpenDuration; i++ {
+		d *= 2
+	}
+	if seconds, ok := kerrors.SuggestsClientDelay(err); ok {
+		if requested := time.Duration(seconds) * time.Second; requested > d {
+			d = requested
+		}
+	}
+	if d > maxOpenDuration {
+		d = maxOpenDuration
+	}
+	return d
+}
+
+// statusWriter is a client.StatusWriter that stops writing while the API
+// server is overloaded.
+type statusWriter struct {
+	client.StatusWriter
+
+	client *Client
+}
+
+// Update implements client.StatusWriter.
+func (sw *statusWriter) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	return sw.client.write(func() error {
+		return sw.StatusWriter.Update(ctx, obj, opts...)
+	})
+}
+
+// Patch implements client.StatusWriter.
+func (sw *statusWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	return sw.client.write(func() error {
+		return sw.StatusWriter.Patch(ctx, obj, patch, opts...)
+	})
+}
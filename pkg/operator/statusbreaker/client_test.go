@@ -0,0 +1,131 @@
+package statusbreaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	utilclock "k8s.io/apimachinery/pkg/util/clock"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// fakeClient is a client.Client whose status writer returns the next of a
+// list of errors for each write and counts the writes.  Its other methods
+// panic.
+type fakeClient struct {
+	client.Client
+
+	errs   []error
+	writes int
+}
+
+func (c *fakeClient) Status() client.StatusWriter {
+	return &fakeStatusWriter{client: c}
+}
+
+type fakeStatusWriter struct {
+	client.StatusWriter
+
+	client *fakeClient
+}
+
+func (sw *fakeStatusWriter) Update(_ context.Context, _ client.Object, _ ...client.UpdateOption) error {
+	c := sw.client
+	c.writes++
+	if len(c.errs) == 0 {
+		return nil
+	}
+	err := c.errs[0]
+	c.errs = c.errs[1:]
+	return err
+}
+
+// TestClient verifies that the client suspends status writes after the API
+// server rejects one as overloaded, for longer after each consecutive
+// rejection and for at least as long as the API server asks, and resumes them
+// when the circuit closes.
+func TestClient(t *testing.T) {
+	throttled := kerrors.NewTooManyRequests("slow down", 0)
+	fake := &fakeClient{errs: []error{
+		throttled,
+		throttled,
+		kerrors.NewTooManyRequests("slow down", 30),
+		nil,
+		throttled,
+	}}
+	clock := utilclock.NewFakeClock(time.Now())
+	c := New(fake)
+	c.clock = clock
+	cm := &corev1.ConfigMap{}
+
+	steps := []struct {
+		advance        time.Duration
+		expectedWrites int
+		expectedRetry  time.Duration
+		expectedOK     bool
+	}{
+		// The first rejection opens the circuit for a second.
+		{0, 1, time.Second, true},
+		// The write is suspended without being sent.
+		{500 * time.Millisecond, 1, 500 * time.Millisecond, true},
+		// The second rejection doubles the duration.
+		{500 * time.Millisecond, 2, 2 * time.Second, true},
+		// The API server asks for longer than the doubled duration.
+		{2 * time.Second, 3, 30 * time.Second, true},
+		// The circuit closes and the write succeeds.
+		{30 * time.Second, 4, 0, false},
+		// The success reset the duration.
+		{0, 5, time.Second, true},
+	}
+	for i, step := range steps {
+		clock.Step(step.advance)
+		err := c.Status().Update(context.TODO(), cm)
+		retryAfter, ok := RetryAfter(err)
+		if fake.writes != step.expectedWrites || retryAfter != step.expectedRetry || ok != step.expectedOK {
+			t.Errorf("step %d: expected %d writes and (%v, %t), got %d writes and (%v, %t) with error %v", i, step.expectedWrites, step.expectedRetry, step.expectedOK, fake.writes, retryAfter, ok, err)
+		}
+	}
+	if !kerrors.IsTooManyRequests(errors.Unwrap(&SuspendedError{Err: throttled})) {
+		t.Errorf("expected a suspended error to wrap the API server's rejection")
+	}
+}
+
+// TestOpenDuration verifies that the circuit stays open for at most
+// maxOpenDuration.
+func TestOpenDuration(t *testing.T) {
+	if d := openDuration(20, kerrors.NewTooManyRequests("slow down", 0)); d != maxOpenDuration {
+		t.Errorf("expected %v, got %v", maxOpenDuration, d)
+	}
+	if d := openDuration(1, kerrors.NewTooManyRequests("slow down", 3600)); d != maxOpenDuration {
+		t.Errorf("expected %v, got %v", maxOpenDuration, d)
+	}
+}
+
+// TestRetryAfter verifies that RetryAfter finds a suspended error that is
+// wrapped or aggregated with other errors.
+func TestRetryAfter(t *testing.T) {
+	suspended := &SuspendedError{RetryAfter: 5 * time.Second}
+	testCases := []struct {
+		err        error
+		expectedOK bool
+	}{
+		{nil, false},
+		{errors.New("failed"), false},
+		{suspended, true},
+		{fmt.Errorf("failed to update dns status: %w", suspended), true},
+		{utilerrors.NewAggregate([]error{errors.New("failed"), fmt.Errorf("failed to ensure dns: %w", utilerrors.NewAggregate([]error{suspended}))}), true},
+	}
+	for _, tc := range testCases {
+		retryAfter, ok := RetryAfter(tc.err)
+		if ok != tc.expectedOK || (ok && retryAfter != 5*time.Second) {
+			t.Errorf("%v: expected %t, got (%v, %t)", tc.err, tc.expectedOK, retryAfter, ok)
+		}
+	}
+}
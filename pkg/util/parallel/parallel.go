@@ -0,0 +1,26 @@
+package parallel
+
+import (
+	"sync"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// Run calls each of the given functions in its own goroutine, waits for all
+// of them to return, and returns an aggregate of the non-nil errors that
+// they returned, or nil if none of them returned an error.
+func Run(fns ...func() error) error {
+	var (
+		wg   sync.WaitGroup
+		errs = make([]error, len(fns))
+	)
+	wg.Add(len(fns))
+	for i := range fns {
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = fns[i]()
+		}(i)
+	}
+	wg.Wait()
+	return utilerrors.NewAggregate(errs)
+}
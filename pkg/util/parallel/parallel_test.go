@@ -0,0 +1,38 @@
+package parallel
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	var calls int32
+	ok := func() error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	}
+	if err := Run(ok, ok, ok); err != nil {
+		t.Errorf("expected nil error, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 calls, got %d", calls)
+	}
+
+	fail := func(msg string) func() error {
+		return func() error {
+			return errors.New(msg)
+		}
+	}
+	err := Run(ok, fail("foo"), fail("bar"))
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if e, a := "[foo, bar]", err.Error(); e != a {
+		t.Errorf("expected error %q, got %q", e, a)
+	}
+
+	if err := Run(); err != nil {
+		t.Errorf("expected nil error for no functions, got %v", err)
+	}
+}
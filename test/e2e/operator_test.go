@@ -4,8 +4,11 @@ package e2e
 
 import (
 	"context"
+	"encoding/hex"
 	"fmt"
+	"net"
 	"reflect"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -22,6 +25,7 @@ import (
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/sets"
@@ -45,6 +49,23 @@ const (
     errors
     log
 }
+`
+	// staleUpstreamPodName is the name of the upstream CoreDNS server
+	// used for testing serve_stale.
+	staleUpstreamPodName = "test-stale-upstream"
+	// staleUpstreamCorefile is the Corefile used by the upstream CoreDNS
+	// server used for testing serve_stale.  Its responses expire after a
+	// second so that the test need not wait long for them to become
+	// stale.
+	staleUpstreamCorefile = `.:5353 {
+    hosts {
+      1.2.3.4 www.foo.com
+      ttl 1
+    }
+    health
+    errors
+    log
+}
 `
 )
 
@@ -447,6 +468,511 @@ func TestDNSForwarding(t *testing.T) {
 	}
 }
 
+// TestDNSServeStale verifies that enabling serve_stale with the cache
+// annotation makes the DNS pods keep answering queries for a forwarded zone
+// with cached responses after the zone's upstream becomes unreachable and the
+// responses' TTLs expire.
+func TestDNSServeStale(t *testing.T) {
+	cl, err := getClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	coreImage, err := clusterOperatorVersion(cl, statuscontroller.CoreDNSVersionName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cliImage, err := clusterOperatorVersion(cl, statuscontroller.OpenshiftCLIVersionName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create an upstream resolver whose responses expire after a second.
+	upstreamCfgMap := buildConfigMap(staleUpstreamPodName, upstreamPodNs, "Corefile", staleUpstreamCorefile)
+	if err := cl.Create(context.TODO(), upstreamCfgMap); err != nil {
+		t.Fatalf("failed to create configmap %s/%s: %v", upstreamCfgMap.Namespace, upstreamCfgMap.Name, err)
+	}
+	defer func() {
+		if err := cl.Delete(context.TODO(), upstreamCfgMap); err != nil {
+			t.Errorf("failed to delete configmap %s/%s: %v", upstreamCfgMap.Namespace, upstreamCfgMap.Name, err)
+		}
+	}()
+	upstreamResolver := upstreamPod(staleUpstreamPodName, upstreamPodNs, coreImage, staleUpstreamPodName)
+	if err := cl.Create(context.TODO(), upstreamResolver); err != nil {
+		t.Fatalf("failed to create pod %s/%s: %v", upstreamResolver.Namespace, upstreamResolver.Name, err)
+	}
+	defer func() {
+		if err := cl.Delete(context.TODO(), upstreamResolver); err != nil && !kerrors.IsNotFound(err) {
+			t.Errorf("failed to delete pod %s/%s: %v", upstreamResolver.Namespace, upstreamResolver.Name, err)
+		}
+	}()
+	if err := waitForPodContainersReady(t, cl, upstreamResolver, 2*time.Minute); err != nil {
+		t.Fatalf("failed to observe ContainersReady condition for pod %s/%s: %v", upstreamResolver.Namespace, upstreamResolver.Name, err)
+	}
+	upstreamSvc := upstreamService(staleUpstreamPodName, upstreamPodNs)
+	if err := cl.Create(context.TODO(), upstreamSvc); err != nil {
+		t.Fatalf("failed to create service %s/%s: %v", upstreamSvc.Namespace, upstreamSvc.Name, err)
+	}
+	defer func() {
+		if err := cl.Delete(context.TODO(), upstreamSvc); err != nil {
+			t.Errorf("failed to delete service %s/%s: %v", upstreamSvc.Namespace, upstreamSvc.Name, err)
+		}
+	}()
+	if err := cl.Get(context.TODO(), types.NamespacedName{Namespace: upstreamSvc.Namespace, Name: upstreamSvc.Name}, upstreamSvc); err != nil {
+		t.Fatalf("failed to get service %s/%s: %v", upstreamSvc.Namespace, upstreamSvc.Name, err)
+	}
+	upstreamIP := upstreamSvc.Spec.ClusterIP
+	if len(upstreamIP) == 0 {
+		t.Fatalf("failed to get clusterIP for service %s/%s", upstreamSvc.Namespace, upstreamSvc.Name)
+	}
+
+	// Forward foo.com to the upstream resolver and enable serve_stale.
+	defaultDNS := &operatorv1.DNS{}
+	if err := cl.Get(context.TODO(), dnsName, defaultDNS); err != nil {
+		t.Fatalf("failed to get default dns: %v", err)
+	}
+	defaultDNS.Spec.Servers = []operatorv1.Server{{
+		Name:          "test",
+		Zones:         []string{"foo.com"},
+		ForwardPlugin: operatorv1.ForwardPlugin{Upstreams: []string{upstreamIP}},
+	}}
+	if defaultDNS.Annotations == nil {
+		defaultDNS.Annotations = map[string]string{}
+	}
+	defaultDNS.Annotations[operatorcontroller.CacheAnnotation] = "serve-stale=10m"
+	if err := cl.Update(context.TODO(), defaultDNS); err != nil {
+		t.Fatalf("failed to update dns %s: %v", defaultDNS.Name, err)
+	}
+	defer func() {
+		defaultDNS = &operatorv1.DNS{}
+		if err := cl.Get(context.TODO(), dnsName, defaultDNS); err != nil {
+			t.Fatalf("failed to get default dns: %v", err)
+		}
+		defaultDNS.Spec = operatorv1.DNSSpec{}
+		delete(defaultDNS.Annotations, operatorcontroller.CacheAnnotation)
+		if err := cl.Update(context.TODO(), defaultDNS); err != nil {
+			t.Fatalf("failed to update dns %s: %v", defaultDNS.Name, err)
+		}
+	}()
+
+	// Wait for every DNS pod to load the new Corefile.
+	dnsDaemonSet := &appsv1.DaemonSet{}
+	if err := cl.Get(context.TODO(), operatorcontroller.DNSDaemonSetName(defaultDNS), dnsDaemonSet); err != nil {
+		t.Fatalf("failed to get daemonset %s/%s: %v", dnsDaemonSet.Namespace, dnsDaemonSet.Name, err)
+	}
+	selector, err := metav1.LabelSelectorAsSelector(dnsDaemonSet.Spec.Selector)
+	if err != nil {
+		t.Fatalf("daemonset %s/%s has invalid spec.selector: %v", dnsDaemonSet.Namespace, dnsDaemonSet.Name, err)
+	}
+	dnsPods := &corev1.PodList{}
+	if err := cl.List(context.TODO(), dnsPods, client.MatchingLabelsSelector{Selector: selector}, client.InNamespace(dnsDaemonSet.Namespace)); err != nil {
+		t.Fatalf("failed to list pods for dns daemonset %s/%s: %v", dnsDaemonSet.Namespace, dnsDaemonSet.Name, err)
+	}
+	catCmd := []string{"cat", "/etc/coredns/Corefile"}
+	for _, pod := range dnsPods.Items {
+		if err := lookForStringInPodExec(pod.Namespace, pod.Name, "dns", catCmd, "serve_stale", 2*time.Minute); err != nil {
+			t.Fatalf("failed to find serve_stale in %s of pod %s/%s: %v", catCmd[1], pod.Namespace, pod.Name, err)
+		}
+	}
+
+	// Query each DNS pod directly so that every pod caches the response.
+	testClient := buildPod("test-stale-client", "default", cliImage, []string{"sleep", "3600"})
+	if err := cl.Create(context.TODO(), testClient); err != nil {
+		t.Fatalf("failed to create pod %s/%s: %v", testClient.Namespace, testClient.Name, err)
+	}
+	defer func() {
+		if err := cl.Delete(context.TODO(), testClient); err != nil {
+			t.Errorf("failed to delete pod %s/%s: %v", testClient.Namespace, testClient.Name, err)
+		}
+	}()
+	if err := waitForPodContainersReady(t, cl, testClient, time.Minute); err != nil {
+		t.Fatalf("failed to observe ContainersReady condition for pod %s/%s: %v", testClient.Namespace, testClient.Name, err)
+	}
+	fooHost := "1.2.3.4"
+	for _, pod := range dnsPods.Items {
+		digCmd := []string{"dig", "@" + pod.Status.PodIP, "-p", "5353", "+short", "www.foo.com", "A"}
+		if err := lookForStringInPodExec(testClient.Namespace, testClient.Name, testClient.Name, digCmd, fooHost, time.Minute); err != nil {
+			t.Fatalf("failed to dig www.foo.com through pod %s/%s: %v", pod.Namespace, pod.Name, err)
+		}
+	}
+
+	// Make the upstream resolver unreachable, let the cached responses
+	// expire, and verify that every DNS pod still answers.
+	if err := cl.Delete(context.TODO(), upstreamResolver); err != nil {
+		t.Fatalf("failed to delete pod %s/%s: %v", upstreamResolver.Namespace, upstreamResolver.Name, err)
+	}
+	err = wait.PollImmediate(1*time.Second, 2*time.Minute, func() (bool, error) {
+		if err := cl.Get(context.TODO(), types.NamespacedName{Namespace: upstreamResolver.Namespace, Name: upstreamResolver.Name}, &corev1.Pod{}); err != nil {
+			return kerrors.IsNotFound(err), nil
+		}
+		return false, nil
+	})
+	if err != nil {
+		t.Fatalf("failed to observe deletion of pod %s/%s: %v", upstreamResolver.Namespace, upstreamResolver.Name, err)
+	}
+	time.Sleep(5 * time.Second)
+	for _, pod := range dnsPods.Items {
+		digCmd := []string{"dig", "@" + pod.Status.PodIP, "-p", "5353", "+short", "www.foo.com", "A"}
+		if err := lookForStringInPodExec(testClient.Namespace, testClient.Name, testClient.Name, digCmd, fooHost, 30*time.Second); err != nil {
+			t.Errorf("expected pod %s/%s to serve a stale response for www.foo.com: %v", pod.Namespace, pod.Name, err)
+		}
+	}
+}
+
+// TestDNSPrefetch verifies that the prefetch cache settings make CoreDNS
+// refresh popular records before they expire, as reported by CoreDNS's
+// coredns_cache_prefetch_total metric.
+func TestDNSPrefetch(t *testing.T) {
+	cl, err := getClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cliImage, err := clusterOperatorVersion(cl, statuscontroller.OpenshiftCLIVersionName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Prefetch every record that is requested while it is cached.
+	defaultDNS := &operatorv1.DNS{}
+	if err := cl.Get(context.TODO(), dnsName, defaultDNS); err != nil {
+		t.Fatalf("failed to get default dns: %v", err)
+	}
+	if defaultDNS.Annotations == nil {
+		defaultDNS.Annotations = map[string]string{}
+	}
+	defaultDNS.Annotations[operatorcontroller.CacheAnnotation] = "prefetch-amount=1,prefetch-percentage=100"
+	if err := cl.Update(context.TODO(), defaultDNS); err != nil {
+		t.Fatalf("failed to update dns %s: %v", defaultDNS.Name, err)
+	}
+	defer func() {
+		defaultDNS = &operatorv1.DNS{}
+		if err := cl.Get(context.TODO(), dnsName, defaultDNS); err != nil {
+			t.Fatalf("failed to get default dns: %v", err)
+		}
+		delete(defaultDNS.Annotations, operatorcontroller.CacheAnnotation)
+		if err := cl.Update(context.TODO(), defaultDNS); err != nil {
+			t.Fatalf("failed to update dns %s: %v", defaultDNS.Name, err)
+		}
+	}()
+
+	// Wait for every DNS pod to load the new Corefile.
+	dnsDaemonSet := &appsv1.DaemonSet{}
+	if err := cl.Get(context.TODO(), operatorcontroller.DNSDaemonSetName(defaultDNS), dnsDaemonSet); err != nil {
+		t.Fatalf("failed to get daemonset %s/%s: %v", dnsDaemonSet.Namespace, dnsDaemonSet.Name, err)
+	}
+	selector, err := metav1.LabelSelectorAsSelector(dnsDaemonSet.Spec.Selector)
+	if err != nil {
+		t.Fatalf("daemonset %s/%s has invalid spec.selector: %v", dnsDaemonSet.Namespace, dnsDaemonSet.Name, err)
+	}
+	dnsPods := &corev1.PodList{}
+	if err := cl.List(context.TODO(), dnsPods, client.MatchingLabelsSelector{Selector: selector}, client.InNamespace(dnsDaemonSet.Namespace)); err != nil {
+		t.Fatalf("failed to list pods for dns daemonset %s/%s: %v", dnsDaemonSet.Namespace, dnsDaemonSet.Name, err)
+	}
+	catCmd := []string{"cat", "/etc/coredns/Corefile"}
+	for _, pod := range dnsPods.Items {
+		if err := lookForStringInPodExec(pod.Namespace, pod.Name, "dns", catCmd, "prefetch 1 1m0s 100%", 2*time.Minute); err != nil {
+			t.Fatalf("failed to find prefetch in %s of pod %s/%s: %v", catCmd[1], pod.Namespace, pod.Name, err)
+		}
+	}
+
+	// Query each DNS pod directly, repeatedly, for a record in the cluster
+	// domain, whose TTL is short, and verify that every pod prefetches it.
+	testClient := buildPod("test-prefetch-client", "default", cliImage, []string{"sleep", "3600"})
+	if err := cl.Create(context.TODO(), testClient); err != nil {
+		t.Fatalf("failed to create pod %s/%s: %v", testClient.Namespace, testClient.Name, err)
+	}
+	defer func() {
+		if err := cl.Delete(context.TODO(), testClient); err != nil {
+			t.Errorf("failed to delete pod %s/%s: %v", testClient.Namespace, testClient.Name, err)
+		}
+	}()
+	if err := waitForPodContainersReady(t, cl, testClient, time.Minute); err != nil {
+		t.Fatalf("failed to observe ContainersReady condition for pod %s/%s: %v", testClient.Namespace, testClient.Name, err)
+	}
+	host := "kubernetes.default.svc." + defaultDNS.Status.ClusterDomain
+	for _, pod := range dnsPods.Items {
+		digCmd := []string{"dig", "@" + pod.Status.PodIP, "-p", "5353", "+short", host, "A"}
+		err := wait.PollImmediate(1*time.Second, 2*time.Minute, func() (bool, error) {
+			if _, err := runCmd("oc", append([]string{"exec", testClient.Name, "-c", testClient.Name, "--namespace=" + testClient.Namespace, "--"}, digCmd...)); err != nil {
+				t.Logf("failed to dig %s through pod %s/%s: %v", host, pod.Namespace, pod.Name, err)
+				return false, nil
+			}
+			prefetches, err := coreDNSMetricValue(pod.Namespace, pod.Name, "coredns_cache_prefetch_total")
+			if err != nil {
+				t.Logf("failed to get metrics of pod %s/%s: %v", pod.Namespace, pod.Name, err)
+				return false, nil
+			}
+			return prefetches > 0, nil
+		})
+		if err != nil {
+			t.Errorf("expected pod %s/%s to prefetch %s: %v", pod.Namespace, pod.Name, host, err)
+		}
+	}
+}
+
+// TestDNSOverHTTPS verifies that the dns serves DNS-over-HTTPS on its service,
+// using the serving certificate that the service CA operator generates, when
+// the dns has the DNS-over-HTTPS annotation.
+func TestDNSOverHTTPS(t *testing.T) {
+	cl, err := getClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cliImage, err := clusterOperatorVersion(cl, statuscontroller.OpenshiftCLIVersionName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defaultDNS := &operatorv1.DNS{}
+	if err := cl.Get(context.TODO(), dnsName, defaultDNS); err != nil {
+		t.Fatalf("failed to get default dns: %v", err)
+	}
+	if defaultDNS.Annotations == nil {
+		defaultDNS.Annotations = map[string]string{}
+	}
+	defaultDNS.Annotations[operatorcontroller.DNSOverHTTPSAnnotation] = "service-ca"
+	if err := cl.Update(context.TODO(), defaultDNS); err != nil {
+		t.Fatalf("failed to update dns %s: %v", defaultDNS.Name, err)
+	}
+	defer func() {
+		defaultDNS = &operatorv1.DNS{}
+		if err := cl.Get(context.TODO(), dnsName, defaultDNS); err != nil {
+			t.Fatalf("failed to get default dns: %v", err)
+		}
+		delete(defaultDNS.Annotations, operatorcontroller.DNSOverHTTPSAnnotation)
+		if err := cl.Update(context.TODO(), defaultDNS); err != nil {
+			t.Fatalf("failed to update dns %s: %v", defaultDNS.Name, err)
+		}
+	}()
+	available := operatorv1.OperatorCondition{Type: operatorcontroller.DNSOverHTTPSAvailableConditionType, Status: operatorv1.ConditionTrue}
+	if err := waitForDNSConditions(t, cl, 2*time.Minute, dnsName, available); err != nil {
+		t.Fatalf("failed to observe expected conditions: %v", err)
+	}
+
+	// The service must have the DNS-over-HTTPS port.
+	svc := &corev1.Service{}
+	svcName := operatorcontroller.DNSServiceName(defaultDNS)
+	if err := cl.Get(context.TODO(), svcName, svc); err != nil {
+		t.Fatalf("failed to get service %s: %v", svcName, err)
+	}
+	var portFound bool
+	for _, port := range svc.Spec.Ports {
+		if port.Port == operatorcontroller.DNSOverHTTPSServicePort && port.TargetPort.StrVal == "dns-over-https" {
+			portFound = true
+		}
+	}
+	if !portFound {
+		t.Fatalf("expected service %s to have port %d, got %v", svcName, operatorcontroller.DNSOverHTTPSServicePort, svc.Spec.Ports)
+	}
+
+	// Resolve the kubernetes service's name over HTTPS, verifying the
+	// dns's serving certificate with the service CA, and look for the
+	// service's address in the response message.
+	kubernetesSvc := &corev1.Service{}
+	if err := cl.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "kubernetes"}, kubernetesSvc); err != nil {
+		t.Fatalf("failed to get service default/kubernetes: %v", err)
+	}
+	ip := net.ParseIP(kubernetesSvc.Spec.ClusterIP)
+	if ip == nil {
+		t.Fatalf("failed to parse clusterIP %q of service default/kubernetes", kubernetesSvc.Spec.ClusterIP)
+	}
+	qtype := uint16(28) // AAAA
+	if ip.To4() != nil {
+		ip, qtype = ip.To4(), uint16(1) // A
+	}
+	testClient := buildPod("test-doh-client", "default", cliImage, []string{"sleep", "3600"})
+	if err := cl.Create(context.TODO(), testClient); err != nil {
+		t.Fatalf("failed to create pod %s/%s: %v", testClient.Namespace, testClient.Name, err)
+	}
+	defer func() {
+		if err := cl.Delete(context.TODO(), testClient); err != nil {
+			t.Errorf("failed to delete pod %s/%s: %v", testClient.Namespace, testClient.Name, err)
+		}
+	}()
+	if err := waitForPodContainersReady(t, cl, testClient, time.Minute); err != nil {
+		t.Fatalf("failed to observe ContainersReady condition for pod %s/%s: %v", testClient.Namespace, testClient.Name, err)
+	}
+	server := fmt.Sprintf("%s.%s.svc", svcName.Name, svcName.Namespace)
+	url := dnsOverHTTPSQueryURL(server, "kubernetes.default.svc."+defaultDNS.Status.ClusterDomain, qtype)
+	script := fmt.Sprintf("curl -sf --cacert /var/run/secrets/kubernetes.io/serviceaccount/service-ca.crt -H 'Accept: application/dns-message' '%s' | od -An -tx1 | tr -d ' \\n'", url)
+	// The dns pods restart to serve DNS-over-HTTPS after the condition is
+	// reported, so allow time for the rollout.
+	if err := lookForStringInPodExec(testClient.Namespace, testClient.Name, testClient.Name, []string{"/bin/sh", "-c", script}, hex.EncodeToString(ip), 5*time.Minute); err != nil {
+		t.Fatalf("failed to resolve service default/kubernetes over HTTPS through %s: %v", server, err)
+	}
+}
+
+// TestDNSHostPort verifies that the dns pods answer queries on the IP address
+// of their nodes at the port that the host-port annotation sets.
+func TestDNSHostPort(t *testing.T) {
+	cl, err := getClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cliImage, err := clusterOperatorVersion(cl, statuscontroller.OpenshiftCLIVersionName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const hostPort = 5354
+	defaultDNS := &operatorv1.DNS{}
+	if err := cl.Get(context.TODO(), dnsName, defaultDNS); err != nil {
+		t.Fatalf("failed to get default dns: %v", err)
+	}
+	if defaultDNS.Annotations == nil {
+		defaultDNS.Annotations = map[string]string{}
+	}
+	defaultDNS.Annotations[operatorcontroller.HostPortAnnotation] = strconv.Itoa(hostPort)
+	if err := cl.Update(context.TODO(), defaultDNS); err != nil {
+		t.Fatalf("failed to update dns %s: %v", defaultDNS.Name, err)
+	}
+	defer func() {
+		defaultDNS = &operatorv1.DNS{}
+		if err := cl.Get(context.TODO(), dnsName, defaultDNS); err != nil {
+			t.Fatalf("failed to get default dns: %v", err)
+		}
+		delete(defaultDNS.Annotations, operatorcontroller.HostPortAnnotation)
+		if err := cl.Update(context.TODO(), defaultDNS); err != nil {
+			t.Fatalf("failed to update dns %s: %v", defaultDNS.Name, err)
+		}
+	}()
+
+	// Wait for a dns pod with the host port to become ready, and query the
+	// IP address of its node.
+	dsName := operatorcontroller.DNSDaemonSetName(defaultDNS)
+	var hostIP string
+	err = wait.PollImmediate(2*time.Second, 5*time.Minute, func() (bool, error) {
+		pods := &corev1.PodList{}
+		if err := cl.List(context.TODO(), pods, client.MatchingLabels(operatorcontroller.DNSDaemonSetPodSelector(defaultDNS).MatchLabels), client.InNamespace(dsName.Namespace)); err != nil {
+			t.Logf("failed to list pods for dns daemonset %s: %v", dsName, err)
+			return false, nil
+		}
+		for _, pod := range pods.Items {
+			if len(pod.Status.HostIP) == 0 || !podHasHostPort(&pod, hostPort) {
+				continue
+			}
+			for _, cond := range pod.Status.Conditions {
+				if cond.Type == corev1.PodReady && cond.Status == corev1.ConditionTrue {
+					hostIP = pod.Status.HostIP
+					return true, nil
+				}
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		t.Fatalf("failed to observe a ready dns pod with host port %d: %v", hostPort, err)
+	}
+
+	testClient := buildPod("test-host-port-client", "default", cliImage, []string{"sleep", "3600"})
+	if err := cl.Create(context.TODO(), testClient); err != nil {
+		t.Fatalf("failed to create pod %s/%s: %v", testClient.Namespace, testClient.Name, err)
+	}
+	defer func() {
+		if err := cl.Delete(context.TODO(), testClient); err != nil {
+			t.Errorf("failed to delete pod %s/%s: %v", testClient.Namespace, testClient.Name, err)
+		}
+	}()
+	if err := waitForPodContainersReady(t, cl, testClient, time.Minute); err != nil {
+		t.Fatalf("failed to observe ContainersReady condition for pod %s/%s: %v", testClient.Namespace, testClient.Name, err)
+	}
+	kubernetesSvc := &corev1.Service{}
+	if err := cl.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "kubernetes"}, kubernetesSvc); err != nil {
+		t.Fatalf("failed to get service default/kubernetes: %v", err)
+	}
+	qtype := "A"
+	if net.ParseIP(kubernetesSvc.Spec.ClusterIP).To4() == nil {
+		qtype = "AAAA"
+	}
+	name := "kubernetes.default.svc." + defaultDNS.Status.ClusterDomain
+	for _, transport := range []string{"+notcp", "+tcp"} {
+		cmd := []string{"dig", "@" + hostIP, "-p", strconv.Itoa(hostPort), transport, "+short", name, qtype}
+		if err := lookForStringInPodExec(testClient.Namespace, testClient.Name, testClient.Name, cmd, kubernetesSvc.Spec.ClusterIP, time.Minute); err != nil {
+			t.Fatalf("failed to resolve %s through %s: %v", name, net.JoinHostPort(hostIP, strconv.Itoa(hostPort)), err)
+		}
+	}
+}
+
+// TestDNSRollingRestartNoFailedQueries verifies that a terminating dns pod is
+// removed from the dns service's endpoints before it stops answering queries
+// by querying the service continuously, without retries, while the dns pods are
+// deleted one at a time, and expecting every query to succeed.
+func TestDNSRollingRestartNoFailedQueries(t *testing.T) {
+	cl, err := getClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cliImage, err := clusterOperatorVersion(cl, statuscontroller.OpenshiftCLIVersionName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defaultDNS := &operatorv1.DNS{}
+	if err := cl.Get(context.TODO(), dnsName, defaultDNS); err != nil {
+		t.Fatalf("failed to get default dns: %v", err)
+	}
+	name := "kubernetes.default.svc." + defaultDNS.Status.ClusterDomain
+	script := fmt.Sprintf("while true; do if dig @%s +tries=1 +time=2 +short %s A | grep -q .; then echo QUERY_OK; else echo QUERY_FAILED; fi; sleep 0.2; done", defaultDNS.Status.ClusterIP, name)
+	testClient := buildPod("test-rolling-restart-client", "default", cliImage, []string{"/bin/sh", "-c", script})
+	if err := cl.Create(context.TODO(), testClient); err != nil {
+		t.Fatalf("failed to create pod %s/%s: %v", testClient.Namespace, testClient.Name, err)
+	}
+	defer func() {
+		if err := cl.Delete(context.TODO(), testClient); err != nil {
+			t.Errorf("failed to delete pod %s/%s: %v", testClient.Namespace, testClient.Name, err)
+		}
+	}()
+	if err := waitForPodContainersReady(t, cl, testClient, time.Minute); err != nil {
+		t.Fatalf("failed to observe ContainersReady condition for pod %s/%s: %v", testClient.Namespace, testClient.Name, err)
+	}
+	if err := lookForStringInPodLog(testClient.Namespace, testClient.Name, testClient.Name, "QUERY_OK", time.Minute); err != nil {
+		t.Fatalf("failed to observe a successful query from pod %s/%s: %v", testClient.Namespace, testClient.Name, err)
+	}
+
+	// Delete the dns pods one at a time, waiting for the daemonset to
+	// replace each one, as a rolling restart or a node drain does.
+	dnsDaemonSet := &appsv1.DaemonSet{}
+	dsName := operatorcontroller.DNSDaemonSetName(defaultDNS)
+	if err := cl.Get(context.TODO(), dsName, dnsDaemonSet); err != nil {
+		t.Fatalf("failed to get daemonset %s: %v", dsName, err)
+	}
+	selector, err := metav1.LabelSelectorAsSelector(dnsDaemonSet.Spec.Selector)
+	if err != nil {
+		t.Fatalf("daemonset %s has invalid spec.selector: %v", dsName, err)
+	}
+	dnsPods := &corev1.PodList{}
+	if err := cl.List(context.TODO(), dnsPods, client.MatchingLabelsSelector{Selector: selector}, client.InNamespace(dsName.Namespace)); err != nil {
+		t.Fatalf("failed to list pods for dns daemonset %s: %v", dsName, err)
+	}
+	for i := range dnsPods.Items {
+		pod := &dnsPods.Items[i]
+		if err := cl.Delete(context.TODO(), pod); err != nil {
+			t.Fatalf("failed to delete pod %s/%s: %v", pod.Namespace, pod.Name, err)
+		}
+		err := wait.PollImmediate(2*time.Second, 3*time.Minute, func() (bool, error) {
+			if err := cl.Get(context.TODO(), types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}, &corev1.Pod{}); err == nil || !kerrors.IsNotFound(err) {
+				return false, nil
+			}
+			if err := cl.Get(context.TODO(), dsName, dnsDaemonSet); err != nil {
+				return false, nil
+			}
+			return dnsDaemonSet.Status.NumberAvailable == dnsDaemonSet.Status.DesiredNumberScheduled, nil
+		})
+		if err != nil {
+			t.Fatalf("failed to observe the replacement of pod %s/%s: %v", pod.Namespace, pod.Name, err)
+		}
+	}
+
+	out, err := runCmd("oc", []string{"logs", testClient.Name, "-c", testClient.Name, "--namespace=" + testClient.Namespace})
+	if err != nil {
+		t.Fatalf("failed to get the log of pod %s/%s: %v", testClient.Namespace, testClient.Name, err)
+	}
+	succeeded, failed := strings.Count(out, "QUERY_OK"), strings.Count(out, "QUERY_FAILED")
+	if failed != 0 {
+		t.Errorf("expected no failed queries during the rolling restart, got %d failed and %d successful queries", failed, succeeded)
+	}
+	t.Logf("%d queries succeeded during the rolling restart of %d dns pods", succeeded, len(dnsPods.Items))
+}
+
 // TestDNSNodePlacement verifies that the node placement API works properly by
 // first configuring DNS pods to run only on master nodes and verifying that
 // this configuration results in having the expected number of DNS pods, then
@@ -4,9 +4,11 @@ package e2e
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
 	"os/exec"
 	"reflect"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -293,3 +295,93 @@ func conditionsMatchExpected(expected, actual map[string]string) bool {
 	}
 	return reflect.DeepEqual(expected, filtered)
 }
+
+// clusterOperatorVersion returns the version with the given name in the dns
+// cluster operator's status, such as the pull spec of an operand image.
+func clusterOperatorVersion(cl client.Client, name string) (string, error) {
+	co := &configv1.ClusterOperator{}
+	coName := controller.DNSClusterOperatorName()
+	if err := cl.Get(context.TODO(), coName, co); err != nil {
+		return "", fmt.Errorf("failed to get clusteroperator %s: %v", coName.Name, err)
+	}
+	for _, ver := range co.Status.Versions {
+		if ver.Name == name && len(ver.Version) != 0 {
+			return ver.Version, nil
+		}
+	}
+	return "", fmt.Errorf("version %s not found for clusteroperator %s", name, coName.Name)
+}
+
+// waitForPodContainersReady waits for the given pod to report that its
+// containers are ready and updates the pod.
+func waitForPodContainersReady(t *testing.T, cl client.Client, pod *corev1.Pod, timeout time.Duration) error {
+	name := types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}
+	return wait.PollImmediate(1*time.Second, timeout, func() (bool, error) {
+		if err := cl.Get(context.TODO(), name, pod); err != nil {
+			t.Logf("failed to get pod %s/%s: %v", name.Namespace, name.Name, err)
+			return false, nil
+		}
+		for _, cond := range pod.Status.Conditions {
+			if cond.Type == corev1.ContainersReady && cond.Status == corev1.ConditionTrue {
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+}
+
+// coreDNSMetricValue returns the sum of the samples of the given metric that
+// CoreDNS serves in the dns container of the specified pod.
+func coreDNSMetricValue(ns, pod, metric string) (float64, error) {
+	cmdPath, err := exec.LookPath("oc")
+	if err != nil {
+		return 0, err
+	}
+	url := fmt.Sprintf("http://127.0.0.1:%d/metrics", controller.CoreDNSMetricsPort)
+	args := []string{"exec", pod, "-c", "dns", fmt.Sprintf("--namespace=%v", ns), "--", "curl", "-s", url}
+	result, err := runCmd(cmdPath, args)
+	if err != nil {
+		return 0, err
+	}
+	var sum float64
+	for _, line := range strings.Split(result, "\n") {
+		if !strings.HasPrefix(line, metric+"{") && !strings.HasPrefix(line, metric+" ") {
+			continue
+		}
+		fields := strings.Fields(line)
+		value, err := strconv.ParseFloat(fields[len(fields)-1], 64)
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse sample %q of metric %s: %v", line, metric, err)
+		}
+		sum += value
+	}
+	return sum, nil
+}
+
+// podHasHostPort returns a Boolean value indicating whether a container of the
+// given pod has the given host port.
+func podHasHostPort(pod *corev1.Pod, hostPort int32) bool {
+	for _, c := range pod.Spec.Containers {
+		for _, p := range c.Ports {
+			if p.HostPort == hostPort {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// dnsOverHTTPSQueryURL returns the URL of a DNS-over-HTTPS GET request to the
+// specified server for the records of the given type for the given name.
+func dnsOverHTTPSQueryURL(server, name string, qtype uint16) string {
+	// Build a query message with ID 0, as RFC 8484 recommends, and the RD
+	// bit set.
+	msg := []byte{0, 0, 1, 0, 0, 1, 0, 0, 0, 0, 0, 0}
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	msg = append(msg, 0)
+	msg = append(msg, byte(qtype>>8), byte(qtype), 0, 1)
+	return fmt.Sprintf("https://%s/dns-query?dns=%s", server, base64.RawURLEncoding.EncodeToString(msg))
+}